@@ -0,0 +1,104 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/YusufOzmen01/veri-kontrol-backend/core/redact"
+	"github.com/YusufOzmen01/veri-kontrol-backend/repository/locations"
+	"github.com/YusufOzmen01/veri-kontrol-backend/tools"
+	"github.com/spf13/cobra"
+)
+
+func newExportCommand() *cobra.Command {
+	var (
+		format, status, eventID, reason, fields, out, since string
+		showPII                                             bool
+	)
+
+	export := &cobra.Command{
+		Use:   "export",
+		Short: "Write the entries as CSV, GeoJSON or KML, the same files the export endpoints serve",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			filter := &locations.LocationFilter{
+				EventID: eventID,
+				Reason:  reason,
+				Status:  status,
+			}
+
+			if since != "" {
+				resolvedSince, err := time.Parse(time.RFC3339, since)
+				if err != nil {
+					return fmt.Errorf("invalid since: %w", err)
+				}
+
+				filter.ResolvedSince = resolvedSince
+			}
+
+			redactor := redact.Redactor{ShowPII: showPII}
+
+			var write func(ctx context.Context, w io.Writer, locationRepository locations.Repository) error
+
+			switch format {
+			case "csv":
+				columns, err := tools.ParseCSVColumns(fields)
+				if err != nil {
+					return err
+				}
+
+				if filter.Status == "" {
+					filter.Status = locations.StatusResolved
+				}
+
+				write = func(ctx context.Context, w io.Writer, l locations.Repository) error {
+					return tools.WriteCSV(ctx, w, l, filter, columns, redactor)
+				}
+			case "geojson":
+				write = func(ctx context.Context, w io.Writer, l locations.Repository) error {
+					return tools.WriteGeoJSON(ctx, w, l, filter, redactor)
+				}
+			case "kml":
+				filter.Status = locations.StatusResolved
+				write = func(ctx context.Context, w io.Writer, l locations.Repository) error {
+					return tools.WriteKML(ctx, w, l, filter, redactor)
+				}
+			default:
+				return fmt.Errorf("unknown format %q, use csv, geojson or kml", format)
+			}
+
+			var file io.Writer = os.Stdout
+			if out != "" && out != "-" {
+				f, err := os.Create(out)
+				if err != nil {
+					return err
+				}
+				defer f.Close()
+
+				file = f
+			}
+
+			w := bufio.NewWriter(file)
+			if err := write(cmd.Context(), w, locations.NewRepository(database(cmd.Context()))); err != nil {
+				return err
+			}
+
+			return w.Flush()
+		},
+	}
+
+	export.Flags().StringVar(&format, "format", "csv", "csv, geojson or kml")
+	export.Flags().StringVar(&status, "status", "", "only entries with this status, csv defaults to resolved and kml is always resolved")
+	export.Flags().StringVar(&eventID, "event", "", "only entries of this event")
+	export.Flags().StringVar(&reason, "reason", "", "only entries with this reason")
+	export.Flags().StringVar(&since, "since", "", "only entries resolved since this RFC 3339 time")
+	export.Flags().StringVar(&fields, "fields", "", "comma separated csv columns, all of them by default")
+	export.Flags().StringVarP(&out, "out", "o", "", "file to write, stdout by default")
+	export.Flags().BoolVar(&showPII, "pii", false, "leave the personal data in, it is redacted by default")
+
+	return export
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	auditRepository "github.com/YusufOzmen01/veri-kontrol-backend/repository/audit"
+	commentsRepository "github.com/YusufOzmen01/veri-kontrol-backend/repository/comments"
+	idempotencyRepository "github.com/YusufOzmen01/veri-kontrol-backend/repository/idempotency"
+	invalidationsRepository "github.com/YusufOzmen01/veri-kontrol-backend/repository/invalidations"
+	locationsRepository "github.com/YusufOzmen01/veri-kontrol-backend/repository/locations"
+	outboxRepository "github.com/YusufOzmen01/veri-kontrol-backend/repository/outbox"
+	qualityRepository "github.com/YusufOzmen01/veri-kontrol-backend/repository/quality"
+	usersRepository "github.com/YusufOzmen01/veri-kontrol-backend/repository/users"
+	webhooksRepository "github.com/YusufOzmen01/veri-kontrol-backend/repository/webhooks"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+func newIndexesCommand() *cobra.Command {
+	indexes := &cobra.Command{
+		Use:   "indexes",
+		Short: "Manage the Mongo indexes",
+	}
+
+	var drop, force bool
+
+	rebuild := &cobra.Command{
+		Use:   "rebuild",
+		Short: "Create the indexes the repositories need, after dropping every existing one with --drop",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			// Without the unique indexes duplicate resolutions get in, and the index can't be created again
+			// afterwards.
+			if drop && !force {
+				return fmt.Errorf("--drop removes the unique indexes until they are created again, stop the API first and pass --force")
+			}
+
+			mongo := database(cmd.Context())
+
+			if drop {
+				collections, err := mongo.CollectionNames(cmd.Context())
+				if err != nil {
+					return err
+				}
+
+				for _, collection := range collections {
+					if err := mongo.DropIndexes(cmd.Context(), collection); err != nil {
+						return err
+					}
+
+					logrus.Infof("Dropped the indexes of %s", collection)
+				}
+			}
+
+			// The repositories create their indexes when they are built, the ones which exist already are left alone.
+			start := time.Now()
+
+			locationsRepository.NewRepository(mongo)
+			usersRepository.NewRepository(mongo)
+			auditRepository.NewRepository(mongo)
+			outboxRepository.NewRepository(mongo)
+			commentsRepository.NewRepository(mongo)
+			webhooksRepository.NewRepository(mongo)
+			qualityRepository.NewRepository(mongo)
+			idempotencyRepository.NewRepository(mongo)
+			invalidationsRepository.NewRepository(mongo)
+
+			logrus.Infof("Indexes are in place after %s, failures were logged above", time.Since(start).Round(time.Millisecond))
+
+			return nil
+		},
+	}
+
+	rebuild.Flags().BoolVar(&drop, "drop", false, "drop every index but _id first, e.g. to pick up changed options")
+	rebuild.Flags().BoolVar(&force, "force", false, "drop the indexes even though the API may be running")
+
+	indexes.AddCommand(rebuild)
+
+	return indexes
+}
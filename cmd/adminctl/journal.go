@@ -0,0 +1,173 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/YusufOzmen01/veri-kontrol-backend/core/wal"
+	auditRepository "github.com/YusufOzmen01/veri-kontrol-backend/repository/audit"
+	locationsRepository "github.com/YusufOzmen01/veri-kontrol-backend/repository/locations"
+	outboxRepository "github.com/YusufOzmen01/veri-kontrol-backend/repository/outbox"
+	"github.com/YusufOzmen01/veri-kontrol-backend/tools"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func newJournalCommand() *cobra.Command {
+	journal := &cobra.Command{
+		Use:   "journal",
+		Short: "Work with the local journal of stored resolutions",
+	}
+
+	var path, since string
+
+	replay := &cobra.Command{
+		Use:   "replay",
+		Short: "Write the resolutions of the journal which aren't in Mongo",
+		Long: "Write the resolutions of the journal which aren't in Mongo, after the database lost them. The files " +
+			"rotated away from the journal are read too. Resolutions which were rejected are left out, and so are " +
+			"entries which have a resolution already or were unresolved or hidden after it, so running it again " +
+			"is harmless.",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if path == "" {
+				path = environment.JournalPath
+			}
+
+			var from time.Time
+			if since != "" {
+				var err error
+				if from, err = time.Parse(time.RFC3339, since); err != nil {
+					return fmt.Errorf("invalid since: %w", err)
+				}
+			}
+
+			resolutions, outcomes, failed, err := readJournal(path)
+			if err != nil {
+				return err
+			}
+
+			ctx := cmd.Context()
+			mongo := database(ctx)
+			locationRepository := locationsRepository.NewRepository(mongo)
+			auditLog := auditRepository.NewRepository(mongo)
+			outboxMessages := outboxRepository.NewRepository(mongo)
+
+			written, skipped := 0, 0
+
+			for _, resolution := range resolutions {
+				if err := ctx.Err(); err != nil {
+					return err
+				}
+
+				entry := resolution.Entry
+				acceptedAt := entry.ID.Timestamp()
+				if resolution.Event != nil {
+					acceptedAt = resolution.Event.At
+				}
+
+				if acceptedAt.Before(from) || outcomes[entry.ID] == tools.OutcomeRejected {
+					continue
+				}
+
+				removed, err := removedSince(ctx, auditLog, entry.EntryID, acceptedAt)
+				if err != nil {
+					return err
+				}
+
+				if removed {
+					logrus.Infof("Skipping entry %d, it was unresolved or hidden after the resolution", entry.EntryID)
+					skipped++
+
+					continue
+				}
+
+				if err := tools.WriteResolution(ctx, locationRepository, auditLog, outboxMessages, resolution); err != nil {
+					if errors.Is(err, locationsRepository.ErrAlreadyResolved) {
+						skipped++
+
+						continue
+					}
+
+					logrus.Errorf("Couldn't write the resolution of entry %d: %s", entry.EntryID, err)
+					failed++
+
+					continue
+				}
+
+				logrus.Infof("Wrote the resolution of entry %d", entry.EntryID)
+				written++
+			}
+
+			logrus.Infof("Wrote %d resolutions, %d were resolved, unresolved or hidden already, %d failed", written, skipped, failed)
+
+			if failed > 0 {
+				return fmt.Errorf("%d resolutions couldn't be written", failed)
+			}
+
+			return nil
+		},
+	}
+
+	replay.Flags().StringVar(&path, "path", "", "journal to replay, journal_path of the environment by default")
+	replay.Flags().StringVar(&since, "since", "", "skip the resolutions accepted before this RFC 3339 time")
+
+	journal.AddCommand(replay)
+
+	return journal
+}
+
+// readJournal returns the resolutions of the journal in the order they were accepted, the last outcome of each
+// and how many records couldn't be read.
+func readJournal(path string) ([]*tools.Resolution, map[primitive.ObjectID]string, int, error) {
+	resolutions := make([]*tools.Resolution, 0)
+	outcomes := make(map[primitive.ObjectID]string)
+	failed := 0
+
+	err := wal.ReadJournal(path, func(record json.RawMessage) error {
+		outcome := &tools.Outcome{}
+		if err := json.Unmarshal(record, outcome); err == nil && outcome.Outcome != "" {
+			outcomes[outcome.ResolutionID] = outcome.Outcome
+
+			return nil
+		}
+
+		resolution := &tools.Resolution{}
+		if err := json.Unmarshal(record, resolution); err != nil || resolution.Entry == nil {
+			logrus.Errorf("Skipping unreadable record: %s", record)
+			failed++
+
+			return nil
+		}
+
+		resolutions = append(resolutions, resolution)
+
+		return nil
+	})
+
+	return resolutions, outcomes, failed, err
+}
+
+// removedSince tells whether a moderator unresolved or hid the entry after at.
+func removedSince(ctx context.Context, auditLog auditRepository.Repository, entryID int, at time.Time) (bool, error) {
+	for _, action := range []string{auditRepository.ActionUnresolve, auditRepository.ActionHide} {
+		count, err := auditLog.CountEvents(ctx, &auditRepository.EventFilter{
+			Action:  action,
+			EntryID: entryID,
+			From:    at,
+		})
+		if err != nil {
+			return false, err
+		}
+
+		if count > 0 {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"os/user"
+	"syscall"
+
+	"github.com/Netflix/go-env"
+	"github.com/YusufOzmen01/veri-kontrol-backend/core/sources"
+	usersRepository "github.com/YusufOzmen01/veri-kontrol-backend/repository/users"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+// Environment is read the same way the server reads it, adminctl runs next to it with the same settings.
+type Environment struct {
+	MongoUri    string `env:"mongo_uri"`
+	JournalPath string `env:"journal_path,default=data/resolutions.journal"`
+}
+
+var (
+	environment Environment
+	mongoClient sources.MongoClient
+	actorName   string
+)
+
+// actor is who the audit events of adminctl are recorded for. It isn't an account, so it has no ID.
+func actor() *usersRepository.User {
+	return &usersRepository.User{Name: actorName}
+}
+
+// defaultActor is the OS user running adminctl and the host it runs on.
+func defaultActor() string {
+	name := "unknown"
+	if current, err := user.Current(); err == nil {
+		name = current.Username
+	}
+
+	host, _ := os.Hostname()
+
+	return fmt.Sprintf("adminctl:%s@%s", name, host)
+}
+
+// database connects on first use, commands which don't need Mongo don't wait for it.
+func database(ctx context.Context) sources.MongoClient {
+	if mongoClient == nil {
+		mongoClient = sources.NewMongoClient(ctx, environment.MongoUri, "database")
+	}
+
+	return mongoClient
+}
+
+func newRootCommand() *cobra.Command {
+	root := &cobra.Command{
+		Use:           "adminctl",
+		Short:         "Operational tasks against the database of the server",
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			_, err := env.UnmarshalFromEnviron(&environment)
+
+			return err
+		},
+	}
+
+	root.PersistentFlags().StringVar(&actorName, "actor", defaultActor(), "who the audit events are recorded for")
+
+	root.AddCommand(
+		newUsersCommand(),
+		newExportCommand(),
+		newIndexesCommand(),
+		newOutboxCommand(),
+		newJournalCommand(),
+		newStatsCommand(),
+	)
+
+	return root
+}
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	err := newRootCommand().ExecuteContext(ctx)
+
+	if mongoClient != nil {
+		if err := mongoClient.Disconnect(context.Background()); err != nil {
+			logrus.Errorf("Couldn't disconnect from Mongo: %s", err)
+		}
+	}
+
+	if err != nil {
+		logrus.Errorln(err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	auditRepository "github.com/YusufOzmen01/veri-kontrol-backend/repository/audit"
+	outboxRepository "github.com/YusufOzmen01/veri-kontrol-backend/repository/outbox"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func newOutboxCommand() *cobra.Command {
+	outbox := &cobra.Command{
+		Use:   "outbox",
+		Short: "Look after the outbox",
+	}
+
+	var topic string
+
+	replay := &cobra.Command{
+		Use:   "replay [message-id...]",
+		Short: "Give dead messages a fresh set of attempts, every dead one without ids",
+		Long: "Give dead messages a fresh set of attempts, the dispatcher of the running server delivers them again. " +
+			"Without ids every dead message is replayed, of --topic only when it is set.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			mongo := database(cmd.Context())
+			outboxMessages := outboxRepository.NewRepository(mongo)
+			auditLog := auditRepository.NewRepository(mongo)
+
+			messages := make([]*outboxRepository.Message, 0, len(args))
+
+			for _, arg := range args {
+				id, err := primitive.ObjectIDFromHex(arg)
+				if err != nil {
+					return fmt.Errorf("invalid message id %s: %w", arg, err)
+				}
+
+				message, err := outboxMessages.GetMessage(cmd.Context(), id)
+				if err != nil {
+					return fmt.Errorf("%s: %w", arg, err)
+				}
+
+				if message.Status != outboxRepository.StatusDead {
+					return fmt.Errorf("%s is %s, only dead messages can be replayed", arg, message.Status)
+				}
+
+				messages = append(messages, message)
+			}
+
+			if len(args) == 0 {
+				dead, err := outboxMessages.GetMessages(cmd.Context(), &outboxRepository.MessageFilter{
+					Status: outboxRepository.StatusDead,
+					Topic:  topic,
+				})
+				if err != nil {
+					return err
+				}
+
+				messages = dead
+			}
+
+			for _, message := range messages {
+				if err := requeue(cmd.Context(), outboxMessages, auditLog, message); err != nil {
+					return err
+				}
+			}
+
+			logrus.Infof("Replayed %d messages", len(messages))
+
+			return nil
+		},
+	}
+
+	replay.Flags().StringVar(&topic, "topic", "", "only replay the dead messages of this topic")
+
+	outbox.AddCommand(replay)
+
+	return outbox
+}
+
+// requeue resets a dead message the way the retry endpoint does.
+func requeue(ctx context.Context, outboxMessages outboxRepository.Repository, auditLog auditRepository.Repository, message *outboxRepository.Message) error {
+	message.Status = outboxRepository.StatusPending
+	message.Attempts = 0
+	message.NextAttemptAt = time.Now()
+
+	if err := outboxMessages.UpdateMessage(ctx, message); err != nil {
+		return err
+	}
+
+	if err := auditLog.Record(ctx, &auditRepository.Event{
+		Actor:  actor(),
+		Action: auditRepository.ActionOutboxRetry,
+		Target: message.ID.Hex(),
+	}); err != nil {
+		logrus.Errorf("Couldn't record %s audit event: %s", auditRepository.ActionOutboxRetry, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	locationsRepository "github.com/YusufOzmen01/veri-kontrol-backend/repository/locations"
+	outboxRepository "github.com/YusufOzmen01/veri-kontrol-backend/repository/outbox"
+	usersRepository "github.com/YusufOzmen01/veri-kontrol-backend/repository/users"
+	"github.com/spf13/cobra"
+)
+
+func newStatsCommand() *cobra.Command {
+	var (
+		days, top int
+		eventID   string
+	)
+
+	stats := &cobra.Command{
+		Use:   "stats",
+		Short: "Print the resolutions, claims, users and outbox at a glance",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if days < 1 || top < 0 {
+				return fmt.Errorf("days has to be at least 1 and top can't be negative")
+			}
+
+			ctx := cmd.Context()
+			mongo := database(ctx)
+			locationRepository := locationsRepository.NewRepository(mongo)
+			userRepository := usersRepository.NewRepository(mongo)
+			outboxMessages := outboxRepository.NewRepository(mongo)
+
+			w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+
+			fmt.Fprintln(w, "RESOLUTIONS\t")
+			for _, status := range []string{locationsRepository.StatusResolved, locationsRepository.StatusPendingReview} {
+				count, err := locationRepository.CountLocations(ctx, &locationsRepository.LocationFilter{Status: status})
+				if err != nil {
+					return err
+				}
+
+				fmt.Fprintf(w, "  %s\t%d\n", status, count)
+			}
+
+			claims, err := locationRepository.CountActiveClaims(ctx)
+			if err != nil {
+				return err
+			}
+
+			activeClaims := 0
+			for _, count := range claims {
+				activeClaims += count
+			}
+
+			fmt.Fprintf(w, "  claimed now\t%d (%d owners)\n", activeClaims, len(claims))
+
+			userList, err := userRepository.GetUsers(ctx)
+			if err != nil {
+				return err
+			}
+
+			active, pending := 0, 0
+			for _, user := range userList {
+				switch {
+				case user.Pending:
+					pending++
+				case !user.Deactivated:
+					active++
+				}
+			}
+
+			fmt.Fprintln(w, "USERS\t")
+			fmt.Fprintf(w, "  active\t%d\n", active)
+			fmt.Fprintf(w, "  pending\t%d\n", pending)
+			fmt.Fprintf(w, "  deactivated\t%d\n", len(userList)-active-pending)
+
+			fmt.Fprintln(w, "OUTBOX\t")
+			for _, status := range outboxRepository.Statuses {
+				count, err := outboxMessages.CountMessages(ctx, &outboxRepository.MessageFilter{Status: status})
+				if err != nil {
+					return err
+				}
+
+				fmt.Fprintf(w, "  %s\t%d\n", status, count)
+			}
+
+			now := time.Now().UTC()
+			since := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC).AddDate(0, 0, -(days - 1))
+
+			daily, err := locationRepository.GetDailyStats(ctx, eventID, since)
+			if err != nil {
+				return err
+			}
+
+			fmt.Fprintln(w, "DAY\tRESOLVED\tSPAM\tAVG LATENCY")
+			for _, day := range daily {
+				latency := time.Duration(day.AvgResolutionSeconds * float64(time.Second)).Round(time.Second)
+				fmt.Fprintf(w, "  %s\t%d\t%d\t%s\n", day.Day, day.Resolved, day.Spam, latency)
+			}
+
+			moderators, err := locationRepository.GetModeratorStats(ctx, eventID)
+			if err != nil {
+				return err
+			}
+
+			sort.SliceStable(moderators, func(i, j int) bool { return moderators[i].Total > moderators[j].Total })
+			if len(moderators) > top {
+				moderators = moderators[:top]
+			}
+
+			fmt.Fprintln(w, "MODERATOR\tRESOLVED")
+			for _, moderator := range moderators {
+				fmt.Fprintf(w, "  %s\t%d\n", moderator.Name, moderator.Total)
+			}
+
+			return w.Flush()
+		},
+	}
+
+	stats.Flags().IntVar(&days, "days", 7, "days of daily stats, today included")
+	stats.Flags().IntVar(&top, "top", 10, "moderators to list")
+	stats.Flags().StringVar(&eventID, "event", "", "only the daily stats and moderators of this event, all of them by default")
+
+	return stats
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	auditRepository "github.com/YusufOzmen01/veri-kontrol-backend/repository/audit"
+	usersRepository "github.com/YusufOzmen01/veri-kontrol-backend/repository/users"
+	"github.com/YusufOzmen01/veri-kontrol-backend/validation"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func newUsersCommand() *cobra.Command {
+	users := &cobra.Command{
+		Use:   "users",
+		Short: "List, create and update users",
+	}
+
+	users.AddCommand(newUsersListCommand(), newUsersCreateCommand(), newUsersSetPermCommand())
+
+	return users
+}
+
+func newUsersListCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "list",
+		Short: "List the users",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			userList, err := usersRepository.NewRepository(database(cmd.Context())).GetUsers(cmd.Context())
+			if err != nil {
+				return err
+			}
+
+			w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+			fmt.Fprintln(w, "ID\tNAME\tPERM\tSCOPES\tSTATE")
+
+			for _, user := range userList {
+				state := "active"
+				switch {
+				case user.Deactivated:
+					state = "deactivated"
+				case user.Pending:
+					state = "pending"
+				}
+
+				fmt.Fprintf(w, "%s\t%s\t%d\t%s\t%s\n", user.ID.Hex(), user.Name, user.PermLevel, strings.Join(user.Scopes, ","), state)
+			}
+
+			return w.Flush()
+		},
+	}
+}
+
+// validateUser checks a perm level and scopes the way the users endpoints do.
+func validateUser(v *validation.Validator, permLevel int, scopes []string) error {
+	v.OneOfInt("perm_level", permLevel, usersRepository.PermLevels)
+	for i, scope := range scopes {
+		scopes[i], _ = v.OneOf("scopes", scope, usersRepository.Scopes)
+	}
+
+	return v.Err()
+}
+
+func newUsersCreateCommand() *cobra.Command {
+	var (
+		name, discord string
+		permLevel     int
+		scopes        []string
+	)
+
+	create := &cobra.Command{
+		Use:   "create",
+		Short: "Create a user and print its Auth-Key, which isn't shown again",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			name = validation.NormalizeText(name)
+			discord = validation.NormalizeText(discord)
+
+			v := validation.New()
+			v.Required("name", name)
+
+			if err := validateUser(v, permLevel, scopes); err != nil {
+				return err
+			}
+
+			// Without --scope the user gets the scopes of its level, like the endpoint does it.
+			if len(scopes) == 0 {
+				scopes = nil
+			}
+
+			mongo := database(cmd.Context())
+
+			user, authKey, err := usersRepository.NewRepository(mongo).AddUser(cmd.Context(), name, discord, permLevel, scopes)
+			if err != nil {
+				return err
+			}
+
+			if err := auditRepository.NewRepository(mongo).Record(cmd.Context(), &auditRepository.Event{
+				Actor:   actor(),
+				Action:  auditRepository.ActionUserCreate,
+				Target:  user.ID.Hex(),
+				Changes: auditRepository.Diff(nil, user),
+			}); err != nil {
+				logrus.Errorf("Couldn't record %s audit event: %s", auditRepository.ActionUserCreate, err)
+			}
+
+			fmt.Printf("ID:       %s\nAuth-Key: %s\n", user.ID.Hex(), authKey)
+
+			return nil
+		},
+	}
+
+	create.Flags().StringVar(&name, "name", "", "name of the user")
+	create.Flags().StringVar(&discord, "discord", "", "Discord handle of the user")
+	create.Flags().IntVar(&permLevel, "perm-level", usersRepository.PermSubmit, "0 read-only, 1 submit, 2 moderator, 3 admin")
+	create.Flags().StringSliceVar(&scopes, "scope", nil, "scopes of the user, the ones of the perm level when left out")
+	_ = create.MarkFlagRequired("name")
+
+	return create
+}
+
+func newUsersSetPermCommand() *cobra.Command {
+	var (
+		permLevel int
+		scopes    []string
+	)
+
+	setPerm := &cobra.Command{
+		Use:   "set-perm <user-id>",
+		Short: "Change the perm level and scopes of a user, its sessions have to log in again",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			id, err := primitive.ObjectIDFromHex(args[0])
+			if err != nil {
+				return fmt.Errorf("invalid user id: %w", err)
+			}
+
+			if err := validateUser(validation.New(), permLevel, scopes); err != nil {
+				return err
+			}
+
+			if len(scopes) == 0 {
+				scopes = usersRepository.ScopesForPermLevel(permLevel)
+			}
+
+			mongo := database(cmd.Context())
+			userRepository := usersRepository.NewRepository(mongo)
+
+			user, err := userRepository.GetUserByID(cmd.Context(), id)
+			if err != nil {
+				return err
+			}
+
+			if err := userRepository.SetPermLevel(cmd.Context(), id, permLevel); err != nil {
+				return err
+			}
+
+			if err := userRepository.SetScopes(cmd.Context(), id, scopes); err != nil {
+				return err
+			}
+
+			// Issued tokens carry the old level and scopes.
+			if err := userRepository.RevokeUserRefreshTokens(cmd.Context(), id); err != nil {
+				return err
+			}
+
+			updated := *user
+			updated.PermLevel = permLevel
+			updated.Scopes = scopes
+
+			if err := auditRepository.NewRepository(mongo).Record(cmd.Context(), &auditRepository.Event{
+				Actor:   actor(),
+				Action:  auditRepository.ActionUserUpdate,
+				Target:  id.Hex(),
+				Changes: auditRepository.Diff(user, &updated),
+			}); err != nil {
+				logrus.Errorf("Couldn't record %s audit event: %s", auditRepository.ActionUserUpdate, err)
+			}
+
+			logrus.Infof("%s is now perm level %d with %s", user.Name, permLevel, strings.Join(scopes, ","))
+
+			return nil
+		},
+	}
+
+	setPerm.Flags().IntVar(&permLevel, "perm-level", 0, "0 read-only, 1 submit, 2 moderator, 3 admin")
+	setPerm.Flags().StringSliceVar(&scopes, "scope", nil, "scopes of the user, the ones of the perm level when left out")
+	_ = setPerm.MarkFlagRequired("perm-level")
+
+	return setPerm
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	locationsRepository "github.com/YusufOzmen01/veri-kontrol-backend/repository/locations"
+	usersRepository "github.com/YusufOzmen01/veri-kontrol-backend/repository/users"
+	"github.com/gofiber/fiber/v2"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	defaultActivityFlush = 30 * time.Second
+	activeWindow         = 15 * time.Minute
+)
+
+type Activity interface {
+	Track(c *fiber.Ctx) error
+	Run(ctx context.Context, interval time.Duration)
+	GetActiveUsers(c *fiber.Ctx) error
+}
+
+// activity remembers when every user was last seen and writes it in batches, a write per request would
+// double the load on Mongo.
+type activity struct {
+	users     usersRepository.Repository
+	locations locationsRepository.Repository
+
+	mu      sync.Mutex
+	pending map[primitive.ObjectID]time.Time
+}
+
+type ActiveUser struct {
+	ID       primitive.ObjectID `json:"_id"`
+	Name     string             `json:"name"`
+	LastSeen time.Time          `json:"last_seen"`
+	Claims   int                `json:"claims"`
+}
+
+type ActiveUsersResponse struct {
+	Count int           `json:"count"`
+	Users []*ActiveUser `json:"users"`
+}
+
+func NewActivity(userRepository usersRepository.Repository, locations locationsRepository.Repository) Activity {
+	return &activity{
+		users:     userRepository,
+		locations: locations,
+		pending:   make(map[primitive.ObjectID]time.Time),
+	}
+}
+
+// Track runs before the routes, the user is only known once their auth middleware ran.
+func (a *activity) Track(c *fiber.Ctx) error {
+	err := c.Next()
+
+	if user := currentUser(c); user != nil {
+		a.mu.Lock()
+		a.pending[user.ID] = time.Now()
+		a.mu.Unlock()
+	}
+
+	return err
+}
+
+func (a *activity) flush(ctx context.Context) {
+	a.mu.Lock()
+	pending := a.pending
+	a.pending = make(map[primitive.ObjectID]time.Time)
+	a.mu.Unlock()
+
+	if len(pending) == 0 {
+		return
+	}
+
+	if err := a.users.SetLastSeen(ctx, pending); err != nil {
+		logrus.Errorf("Couldn't save last seen times: %s", err)
+	}
+}
+
+// Run flushes until ctx is cancelled and once more afterwards, so the last batch isn't lost on shutdown.
+func (a *activity) Run(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = defaultActivityFlush
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			flushCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+
+			a.flush(flushCtx)
+
+			return
+		case <-time.After(interval):
+			a.flush(ctx)
+		}
+	}
+}
+
+// GetActiveUsers lists who was seen in the last 15 minutes. Flushes are batched, so last_seen can be behind
+// by up to one flush interval.
+func (a *activity) GetActiveUsers(c *fiber.Ctx) error {
+	list, err := a.users.GetActiveUsers(c.Context(), time.Now().Add(-activeWindow))
+	if err != nil {
+		return err
+	}
+
+	claims, err := a.locations.CountActiveClaims(c.Context())
+	if err != nil {
+		return err
+	}
+
+	response := &ActiveUsersResponse{
+		Count: len(list),
+		Users: make([]*ActiveUser, 0, len(list)),
+	}
+
+	for _, user := range list {
+		activeUser := &ActiveUser{
+			ID:     user.ID,
+			Name:   user.Name,
+			Claims: claims[user.ID.Hex()],
+		}
+
+		if user.LastSeen != nil {
+			activeUser.LastSeen = *user.LastSeen
+		}
+
+		response.Users = append(response.Users, activeUser)
+	}
+
+	return c.JSON(response)
+}
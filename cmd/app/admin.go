@@ -2,100 +2,644 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"strconv"
+	"time"
 
+	"github.com/YusufOzmen01/veri-kontrol-backend/core/address"
+	"github.com/YusufOzmen01/veri-kontrol-backend/core/geohash"
+	"github.com/YusufOzmen01/veri-kontrol-backend/core/heatmap"
 	"github.com/YusufOzmen01/veri-kontrol-backend/core/sources"
+	auditRepository "github.com/YusufOzmen01/veri-kontrol-backend/repository/audit"
+	citiesRepository "github.com/YusufOzmen01/veri-kontrol-backend/repository/cities"
+	commentsRepository "github.com/YusufOzmen01/veri-kontrol-backend/repository/comments"
+	eventsRepository "github.com/YusufOzmen01/veri-kontrol-backend/repository/events"
 	"github.com/YusufOzmen01/veri-kontrol-backend/repository/locations"
+	outboxRepository "github.com/YusufOzmen01/veri-kontrol-backend/repository/outbox"
+	reasonsRepository "github.com/YusufOzmen01/veri-kontrol-backend/repository/reasons"
+	typesRepository "github.com/YusufOzmen01/veri-kontrol-backend/repository/types"
 	"github.com/YusufOzmen01/veri-kontrol-backend/tools"
 	"github.com/gofiber/fiber/v2"
-	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 type Admin interface {
 	GetLocationEntries(c *fiber.Ctx) error
+	GetNearEntries(c *fiber.Ctx) error
+	GetEntryClusters(c *fiber.Ctx) error
 	GetSingleEntry(c *fiber.Ctx) error
 	UpdateEntry(c *fiber.Ctx) error
+	GetEntryHistory(c *fiber.Ctx) error
+	RollbackEntry(c *fiber.Ctx) error
+	UnresolveEntry(c *fiber.Ctx) error
+	ApproveEntry(c *fiber.Ctx) error
+	HideEntry(c *fiber.Ctx) error
+	RestoreEntry(c *fiber.Ctx) error
 }
 
 type admin struct {
 	locations locations.Repository
 	cache     sources.Cache
+	cities    citiesRepository.Repository
+	processed *ProcessedIDs
+	events    eventsRepository.Repository
+	reasons   reasonsRepository.Repository
+	types     typesRepository.Repository
+	audit     auditRepository.Repository
+	comments  commentsRepository.Repository
+	outbox    outboxRepository.Repository
+	masker    *Masker
 }
 
-func NewAdmin(locations locations.Repository, cache sources.Cache) Admin {
+type EntriesResponse struct {
+	Count    int64                   `json:"count"`
+	Page     int                     `json:"page"`
+	PageSize int                     `json:"page_size"`
+	Entries  []*locations.LocationDB `json:"entries"`
+}
+
+// SparseEntriesResponse is EntriesResponse when ?fields= picks what every entry has.
+type SparseEntriesResponse struct {
+	Count    int64                        `json:"count"`
+	Page     int                          `json:"page"`
+	PageSize int                          `json:"page_size"`
+	Entries  []map[string]json.RawMessage `json:"entries"`
+}
+
+// UpdateEntryResponse shows what was understood from the corrected address, so it can be checked right away.
+type UpdateEntryResponse struct {
+	EntryID       int             `json:"entry_id"`
+	ParsedAddress *address.Parsed `json:"parsed_address"`
+}
+
+type EntryClustersResponse struct {
+	Zoom     int                `json:"zoom"`
+	Count    int                `json:"count"`
+	Clusters []*heatmap.Cluster `json:"clusters"`
+}
+
+// SingleEntryResponse is the resolution with the moderators' comments on it.
+type SingleEntryResponse struct {
+	*locations.LocationDB
+	Comments []*commentsRepository.Comment `json:"comments"`
+}
+
+type EntryHistoryResponse struct {
+	EntryID   int                   `json:"entry_id"`
+	Revisions []*locations.Revision `json:"revisions"`
+}
+
+const (
+	defaultPageSize = 50
+	maxPageSize     = 500
+
+	defaultNearRadius = 1000
+	maxNearRadius     = 50000
+	defaultMapZoom    = 8
+)
+
+func NewAdmin(locations locations.Repository, cache sources.Cache, cities citiesRepository.Repository, processed *ProcessedIDs, events eventsRepository.Repository, reasons reasonsRepository.Repository, types typesRepository.Repository, auditLog auditRepository.Repository, comments commentsRepository.Repository, outboxMessages outboxRepository.Repository, masker *Masker) Admin {
 	return &admin{
 		locations: locations,
 		cache:     cache,
+		cities:    cities,
+		processed: processed,
+		events:    events,
+		reasons:   reasons,
+		types:     types,
+		audit:     auditLog,
+		comments:  comments,
+		outbox:    outboxMessages,
+		masker:    masker,
+	}
+}
+
+// locationFilterFromQuery builds the common entry filters shared by the listing and export endpoints.
+func locationFilterFromQuery(c *fiber.Ctx, cityRepository citiesRepository.Repository, eventRepository eventsRepository.Repository) (*locations.LocationFilter, error) {
+	eventID, err := eventIDFromQuery(c, eventRepository)
+	if err != nil {
+		return nil, err
+	}
+
+	filter := &locations.LocationFilter{
+		EventID:   eventID,
+		Reason:    c.Query("reason"),
+		Status:    c.Query("status"),
+		EpochFrom: c.QueryInt("epoch_from"),
+		EpochTo:   c.QueryInt("epoch_to"),
+	}
+
+	if resolvedFrom := c.QueryInt("resolved_from"); resolvedFrom > 0 {
+		filter.ResolvedSince = time.Unix(int64(resolvedFrom), 0)
+	}
+
+	if resolvedTo := c.QueryInt("resolved_to"); resolvedTo > 0 {
+		filter.ResolvedUntil = time.Unix(int64(resolvedTo), 0)
+	}
+
+	if locationType := c.QueryInt("type"); locationType > 0 {
+		filter.Types = []int{locationType}
+	}
+
+	if corrected := c.Query("corrected"); corrected != "" {
+		value, err := strconv.ParseBool(corrected)
+		if err != nil {
+			return nil, fmt.Errorf("corrected must be true or false")
+		}
+
+		filter.Corrected = &value
+	}
+
+	if senderID := c.Query("sender_id"); senderID != "" {
+		id, err := primitive.ObjectIDFromHex(senderID)
+		if err != nil {
+			return nil, fmt.Errorf("invalid sender_id")
+		}
+
+		filter.SenderID = &id
+	}
+
+	if hash := c.Query("geohash"); hash != "" {
+		if !geohash.Valid(hash) {
+			return nil, fmt.Errorf("geohash must be at most %d lowercase geohash characters", geohash.Precision)
+		}
+
+		filter.Geohash = hash
 	}
+
+	if cityID := c.QueryInt("city_id"); cityID > 0 {
+		city, err := cityRepository.GetCity(c.Context(), cityID)
+		if err != nil {
+			return nil, fmt.Errorf("unknown city_id")
+		}
+
+		filter.Polygon = city.Polygon
+	}
+
+	return filter, nil
 }
 
 func (a *admin) GetLocationEntries(c *fiber.Ctx) error {
-	entries, err := a.locations.GetLocations(c.Context())
+	filter, err := locationFilterFromQuery(c, a.cities, a.events)
 	if err != nil {
-		return c.SendString(err.Error())
+		return badRequest(err.Error())
 	}
 
-	return c.JSON(entries)
+	return a.listEntries(c, filter)
+}
+
+// GetNearEntries lists the resolutions within radius metres of lat, lng. The other entry filters still apply.
+func (a *admin) GetNearEntries(c *fiber.Ctx) error {
+	filter, err := locationFilterFromQuery(c, a.cities, a.events)
+	if err != nil {
+		return badRequest(err.Error())
+	}
+
+	lat, err := strconv.ParseFloat(c.Query("lat"), 64)
+	if err != nil || lat < -90 || lat > 90 {
+		return badRequest("lat must be between -90 and 90")
+	}
+
+	lng, err := strconv.ParseFloat(c.Query("lng"), 64)
+	if err != nil || lng < -180 || lng > 180 {
+		return badRequest("lng must be between -180 and 180")
+	}
+
+	radius := c.QueryInt("radius", defaultNearRadius)
+	if radius < 1 || radius > maxNearRadius {
+		return badRequest(fmt.Sprintf("radius must be between 1 and %d metres", maxNearRadius))
+	}
+
+	filter.Near = &locations.Near{
+		Lat:          lat,
+		Lng:          lng,
+		RadiusMeters: float64(radius),
+	}
+
+	return a.listEntries(c, filter)
+}
+
+// GetEntryClusters groups the unresolved entries into geohash cells sized for the map zoom, so a heatmap gets
+// a centroid per cell instead of every point. Count is the number of entries behind the clusters.
+func (a *admin) GetEntryClusters(c *fiber.Ctx) error {
+	zoom := c.QueryInt("zoom", defaultMapZoom)
+	if zoom < heatmap.MinZoom || zoom > heatmap.MaxZoom {
+		return badRequest(fmt.Sprintf("zoom must be between %d and %d", heatmap.MinZoom, heatmap.MaxZoom))
+	}
+
+	eventID, err := eventIDFromQuery(c, a.events)
+	if err != nil {
+		return badRequest(err.Error())
+	}
+
+	raw, err := tools.GetAllLocations(c.Context(), a.cache)
+	if err != nil {
+		return serviceUnavailable("The locations feed is unavailable, try again later.")
+	}
+
+	raw = entriesOfEvent(raw, eventID)
+
+	hiddenIDs, err := a.locations.GetHiddenIDs(c.Context())
+	if err != nil {
+		return err
+	}
+
+	hidden := make(map[int]bool, len(hiddenIDs))
+	for _, id := range hiddenIDs {
+		hidden[id] = true
+	}
+
+	points := make([]heatmap.Point, 0, len(raw))
+	for _, loc := range raw {
+		if len(loc.Loc) == 2 && !a.processed.Contains(loc.EntryID) && !hidden[loc.EntryID] {
+			points = append(points, heatmap.Point{Lat: loc.Loc[0], Lng: loc.Loc[1], Geohash: loc.Geohash})
+		}
+	}
+
+	return c.JSON(&EntryClustersResponse{
+		Zoom:     zoom,
+		Count:    len(points),
+		Clusters: heatmap.Group(points, heatmap.Precision(zoom)),
+	})
+}
+
+func (a *admin) listEntries(c *fiber.Ctx, filter *locations.LocationFilter) error {
+	fields, err := fieldsFromQuery(c, &locations.LocationDB{})
+	if err != nil {
+		return err
+	}
+
+	filter.Page = c.QueryInt("page", 1)
+	filter.PageSize = c.QueryInt("page_size", defaultPageSize)
+
+	if filter.Page < 1 {
+		filter.Page = 1
+	}
+
+	if filter.PageSize < 1 || filter.PageSize > maxPageSize {
+		filter.PageSize = defaultPageSize
+	}
+
+	count, err := a.locations.CountLocations(c.Context(), filter)
+	if err != nil {
+		return err
+	}
+
+	entries, err := a.locations.GetLocations(c.Context(), filter)
+	if err != nil {
+		return err
+	}
+
+	entries = a.masker.Entries(c, entries)
+
+	if fields != nil {
+		picked := make([]map[string]json.RawMessage, 0, len(entries))
+		for _, entry := range entries {
+			p, err := sparse(entry, fields)
+			if err != nil {
+				return err
+			}
+
+			picked = append(picked, p)
+		}
+
+		return c.JSON(&SparseEntriesResponse{
+			Count:    count,
+			Page:     filter.Page,
+			PageSize: filter.PageSize,
+			Entries:  picked,
+		})
+	}
+
+	return c.JSON(&EntriesResponse{
+		Count:    count,
+		Page:     filter.Page,
+		PageSize: filter.PageSize,
+		Entries:  entries,
+	})
 }
 
 func (a *admin) GetSingleEntry(c *fiber.Ctx) error {
 	entryID, _ := strconv.ParseInt(c.Params("entry_id"), 10, 32)
 
-	entries, err := a.locations.GetLocations(c.Context())
+	fields, err := fieldsFromQuery(c, &SingleEntryResponse{})
+	if err != nil {
+		return err
+	}
+
+	entries, err := a.locations.GetLocations(c.Context(), nil)
 	if err != nil {
-		return c.SendString(err.Error())
+		return err
 	}
 
 	for _, entry := range entries {
 		if entry.EntryID == int(entryID) {
-			return c.JSON(entry)
+			comments, err := a.comments.GetComments(c.Context(), entry.EntryID)
+			if err != nil {
+				return err
+			}
+
+			response := &SingleEntryResponse{
+				LocationDB: a.masker.Entry(c, entry),
+				Comments:   a.masker.Comments(c, comments),
+			}
+
+			if fields != nil {
+				picked, err := sparse(response, fields)
+				if err != nil {
+					return err
+				}
+
+				return c.JSON(picked)
+			}
+
+			return c.JSON(response)
 		}
 	}
 
-	return c.SendString("Entry not found.")
+	return notFound("Entry not found.")
 }
 
 func (a *admin) UpdateEntry(c *fiber.Ctx) error {
 	body := &ResolveBody{}
 
 	if err := json.Unmarshal(c.Body(), body); err != nil {
-		return c.SendString(err.Error())
+		return badRequest(err.Error())
 	}
 
-	locs, err := tools.GetAllLocations(c.Context(), a.cache)
+	reasonList, err := a.reasons.GetReasons(c.Context())
 	if err != nil {
-		logrus.Errorln(err)
+		return err
+	}
+
+	typeList, err := a.types.GetTypes(c.Context())
+	if err != nil {
+		return err
+	}
 
-		return c.SendString(err.Error())
+	if err := body.Validate(reasonList, typeList); err != nil {
+		return validationFailed(err)
+	}
+
+	locs, err := tools.GetAllLocations(c.Context(), a.cache)
+	if err != nil {
+		return err
 	}
 
 	originalLocation := ""
 	location := make([]float64, 0)
+	epoch := 0
+	var phoneNumbers []string
+	eventID := ""
 
 	for _, loc := range locs {
 		if loc.EntryID == body.ID {
 			originalLocation = fmt.Sprintf("https://www.google.com/maps/?q=%f,%f&ll=%f,%f&z=21", loc.Loc[0], loc.Loc[1], loc.Loc[0], loc.Loc[1])
 			location = loc.Loc
+			epoch = loc.Epoch
+			phoneNumbers = loc.PhoneNumbers
+			eventID = loc.EventID
 		}
 	}
 
-	if err := a.locations.ResolveLocation(c.Context(), &locations.LocationDB{
+	if fromBody := body.PhoneNumbers(); len(fromBody) > 0 {
+		phoneNumbers = fromBody
+	}
+
+	// A missing resolution is fine, admins can resolve entries directly.
+	before, _ := a.locations.GetLocation(c.Context(), body.ID)
+
+	// Entries of past events aren't in the feed anymore, correcting them keeps their event.
+	switch {
+	case eventID != "":
+	case before != nil && before.EventID != "":
+		eventID = before.EventID
+	default:
+		eventID = tools.ActiveEventID(c.Context())
+	}
+
+	entry := &locations.LocationDB{
+		ID:               primitive.NewObjectIDFromTimestamp(time.Now()),
 		EntryID:          body.ID,
 		Type:             body.LocationType,
+		TypeCode:         body.TypeCode(),
 		Location:         location,
-		Corrected:        body.Reason == "Hata Yok",
+		Corrected:        body.Corrected(),
 		Verified:         true,
+		Status:           locations.StatusResolved,
 		OriginalAddress:  originalLocation,
 		CorrectedAddress: body.NewAddress,
+		CorrectedLat:     body.CorrectedLat,
+		CorrectedLng:     body.CorrectedLng,
+		ParsedAddress:    body.ParsedAddress(),
 		Reason:           body.Reason,
 		OpenAddress:      body.OpenAddress,
 		Apartment:        body.Apartment,
-	}); err != nil {
-		logrus.Errorln(err)
+		PhoneNumbers:     phoneNumbers,
+		Epoch:            epoch,
+		EventID:          eventID,
+	}
+
+	if err := a.locations.ReplaceLocation(c.Context(), entry, currentUser(c)); err != nil {
+		if errors.Is(err, locations.ErrAlreadyResolved) {
+			return conflict("this location was resolved while it was being updated")
+		}
+
+		return err
+	}
+
+	recordAudit(c, a.audit, &auditRepository.Event{
+		Action:  auditRepository.ActionUpdate,
+		EntryID: body.ID,
+		Changes: auditRepository.Diff(before, entry),
+	})
+	queueResolved(c, a.outbox, entry)
+
+	return c.JSON(&UpdateEntryResponse{
+		EntryID:       entry.EntryID,
+		ParsedAddress: entry.ParsedAddress,
+	})
+}
+
+func (a *admin) GetEntryHistory(c *fiber.Ctx) error {
+	entryID, err := c.ParamsInt("entry_id")
+	if err != nil {
+		return badRequest(err.Error())
+	}
+
+	revisions, err := a.locations.GetRevisions(c.Context(), entryID)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(&EntryHistoryResponse{
+		EntryID:   entryID,
+		Revisions: a.masker.Revisions(c, revisions),
+	})
+}
+
+func (a *admin) RollbackEntry(c *fiber.Ctx) error {
+	entryID, err := c.ParamsInt("entry_id")
+	if err != nil {
+		return badRequest(err.Error())
+	}
+
+	revisionID, err := primitive.ObjectIDFromHex(c.Params("revision_id"))
+	if err != nil {
+		return badRequest("invalid revision_id")
+	}
+
+	before, _ := a.locations.GetLocation(c.Context(), entryID)
+
+	restored, err := a.locations.RollbackLocation(c.Context(), entryID, revisionID, currentUser(c))
+	if err != nil {
+		switch err {
+		case locations.ErrRevisionNotFound:
+			return notFound(err.Error())
+		case locations.ErrNothingToRestore, locations.ErrAlreadyResolved:
+			return conflict(err.Error())
+		}
+
+		return err
+	}
+
+	if !restored.IsPendingReview() {
+		a.processed.Add(entryID)
+	}
+
+	recordAudit(c, a.audit, &auditRepository.Event{
+		Action:  auditRepository.ActionRollback,
+		EntryID: entryID,
+		Target:  revisionID.Hex(),
+		Changes: auditRepository.Diff(before, restored),
+	})
+	queueResolved(c, a.outbox, restored)
+
+	return c.JSON(a.masker.Entry(c, restored))
+}
+
+func (a *admin) UnresolveEntry(c *fiber.Ctx) error {
+	entryID, err := c.ParamsInt("entry_id")
+	if err != nil {
+		return badRequest(err.Error())
+	}
+
+	before, err := a.locations.GetLocation(c.Context(), entryID)
+	if err != nil {
+		if err == locations.ErrLocationNotFound {
+			return notFound(err.Error())
+		}
+
+		return err
+	}
+
+	if err := a.locations.UnresolveLocation(c.Context(), entryID, currentUser(c)); err != nil {
+		if err == locations.ErrLocationNotFound {
+			return notFound(err.Error())
+		}
+
+		return err
+	}
+
+	a.processed.Remove(entryID)
+
+	recordAudit(c, a.audit, &auditRepository.Event{
+		Action:  auditRepository.ActionUnresolve,
+		EntryID: entryID,
+		Changes: auditRepository.Diff(before, nil),
+	})
+
+	return c.SendString("Successfully unresolved!")
+}
+
+func (a *admin) ApproveEntry(c *fiber.Ctx) error {
+	entryID, err := c.ParamsInt("entry_id")
+	if err != nil {
+		return badRequest(err.Error())
+	}
+
+	entry, err := a.locations.GetLocation(c.Context(), entryID)
+	if err != nil {
+		if err == locations.ErrLocationNotFound {
+			return notFound(err.Error())
+		}
 
-		return c.SendString(err.Error())
+		return err
 	}
 
-	return c.SendString("")
+	if !entry.IsPendingReview() {
+		return conflict("entry is not pending review")
+	}
+
+	approver := currentUser(c)
+
+	// The reviewer has to be someone else, and ranked above whoever submitted the resolution.
+	if entry.Sender != nil {
+		if entry.Sender.ID == approver.ID {
+			return forbidden("You can't approve your own resolution.")
+		}
+
+		if approver.PermLevel <= entry.Sender.PermLevel {
+			return forbidden("You need a higher permission level than the submitter to approve.")
+		}
+	}
+
+	if err := a.locations.ApproveLocation(c.Context(), entryID, approver); err != nil {
+		return err
+	}
+
+	a.processed.Add(entryID)
+
+	approved := *entry
+	approved.Status = locations.StatusResolved
+	approved.ApprovedBy = approver
+
+	recordAudit(c, a.audit, &auditRepository.Event{
+		Action:  auditRepository.ActionApprove,
+		EntryID: entryID,
+		Changes: auditRepository.Diff(entry, &approved),
+	})
+
+	return c.SendString("Successfully approved!")
+}
+
+func (a *admin) HideEntry(c *fiber.Ctx) error {
+	entryID, err := c.ParamsInt("entry_id")
+	if err != nil {
+		return badRequest(err.Error())
+	}
+
+	if err := a.locations.HideEntry(c.Context(), entryID, currentUser(c)); err != nil {
+		return err
+	}
+
+	recordAudit(c, a.audit, &auditRepository.Event{
+		Action:  auditRepository.ActionHide,
+		EntryID: entryID,
+	})
+
+	return c.SendString("Successfully hidden!")
+}
+
+func (a *admin) RestoreEntry(c *fiber.Ctx) error {
+	entryID, err := c.ParamsInt("entry_id")
+	if err != nil {
+		return badRequest(err.Error())
+	}
+
+	if err := a.locations.RestoreEntry(c.Context(), entryID); err != nil {
+		if err == locations.ErrEntryNotHidden {
+			return notFound(err.Error())
+		}
+
+		return err
+	}
+
+	recordAudit(c, a.audit, &auditRepository.Event{
+		Action:  auditRepository.ActionRestore,
+		EntryID: entryID,
+	})
+
+	return c.SendString("Successfully restored!")
 }
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"encoding/json"
+	"time"
+
+	auditRepository "github.com/YusufOzmen01/veri-kontrol-backend/repository/audit"
+	citiesRepository "github.com/YusufOzmen01/veri-kontrol-backend/repository/cities"
+	usersRepository "github.com/YusufOzmen01/veri-kontrol-backend/repository/users"
+	"github.com/YusufOzmen01/veri-kontrol-backend/validation"
+	"github.com/gofiber/fiber/v2"
+	"github.com/samber/lo"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type Assignments interface {
+	GetAssignments(c *fiber.Ctx) error
+	SetAssignment(c *fiber.Ctx) error
+	DeleteAssignment(c *fiber.Ctx) error
+}
+
+type assignments struct {
+	users  usersRepository.Repository
+	cities citiesRepository.Repository
+	audit  auditRepository.Repository
+}
+
+// AssignmentBody replaces the cities of a user, an empty list is the same as deleting the assignment.
+type AssignmentBody struct {
+	CityIDs []int `json:"city_ids"`
+}
+
+func NewAssignments(users usersRepository.Repository, cities citiesRepository.Repository, auditLog auditRepository.Repository) Assignments {
+	return &assignments{
+		users:  users,
+		cities: cities,
+		audit:  auditLog,
+	}
+}
+
+// assignedUser loads the user from the :user_id param.
+func (a *assignments) assignedUser(c *fiber.Ctx) (*usersRepository.User, error) {
+	id, err := primitive.ObjectIDFromHex(c.Params("user_id"))
+	if err != nil {
+		return nil, badRequest("invalid user_id")
+	}
+
+	user, err := a.users.GetUserByID(c.Context(), id)
+	if err != nil {
+		return nil, notFound(err.Error())
+	}
+
+	return user, nil
+}
+
+func (a *assignments) GetAssignments(c *fiber.Ctx) error {
+	list, err := a.users.GetAssignments(c.Context())
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(list)
+}
+
+func (a *assignments) SetAssignment(c *fiber.Ctx) error {
+	user, err := a.assignedUser(c)
+	if err != nil {
+		return err
+	}
+
+	body := &AssignmentBody{}
+
+	if err := json.Unmarshal(c.Body(), body); err != nil {
+		return badRequest(err.Error())
+	}
+
+	if len(body.CityIDs) == 0 {
+		return a.DeleteAssignment(c)
+	}
+
+	cityList, err := a.cities.GetCities(c.Context())
+	if err != nil {
+		return err
+	}
+
+	body.CityIDs = lo.Uniq(body.CityIDs)
+
+	v := validation.New()
+
+	cityIDs := lo.Map(cityList, func(city *citiesRepository.City, _ int) int { return city.ID })
+	for _, cityID := range body.CityIDs {
+		v.OneOfInt("city_ids", cityID, cityIDs)
+	}
+
+	if err := v.Err(); err != nil {
+		return validationFailed(err)
+	}
+
+	before, err := a.users.GetAssignment(c.Context(), user.ID)
+	if err != nil {
+		return err
+	}
+
+	assignment := &usersRepository.Assignment{
+		UserID:     user.ID,
+		CityIDs:    body.CityIDs,
+		AssignedBy: currentUser(c).Name,
+		UpdatedAt:  time.Now(),
+	}
+
+	if err := a.users.SetAssignment(c.Context(), assignment); err != nil {
+		return err
+	}
+
+	recordAudit(c, a.audit, &auditRepository.Event{
+		Action:  auditRepository.ActionAssignmentSet,
+		Target:  user.ID.Hex(),
+		Changes: auditRepository.Diff(before, assignment),
+	})
+
+	return c.JSON(assignment)
+}
+
+func (a *assignments) DeleteAssignment(c *fiber.Ctx) error {
+	user, err := a.assignedUser(c)
+	if err != nil {
+		return err
+	}
+
+	before, err := a.users.GetAssignment(c.Context(), user.ID)
+	if err != nil {
+		return err
+	}
+
+	if before == nil {
+		return notFound("user isn't assigned to any city")
+	}
+
+	if err := a.users.DeleteAssignment(c.Context(), user.ID); err != nil {
+		return err
+	}
+
+	recordAudit(c, a.audit, &auditRepository.Event{
+		Action:  auditRepository.ActionAssignmentDelete,
+		Target:  user.ID.Hex(),
+		Changes: auditRepository.Diff(before, nil),
+	})
+
+	return c.SendString("Successfully deleted!")
+}
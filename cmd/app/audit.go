@@ -0,0 +1,99 @@
+package main
+
+import (
+	"time"
+
+	auditRepository "github.com/YusufOzmen01/veri-kontrol-backend/repository/audit"
+	"github.com/gofiber/fiber/v2"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type Audit interface {
+	GetEvents(c *fiber.Ctx) error
+}
+
+type audit struct {
+	audit  auditRepository.Repository
+	masker *Masker
+}
+
+type AuditResponse struct {
+	Count    int64                    `json:"count"`
+	Page     int                      `json:"page"`
+	PageSize int                      `json:"page_size"`
+	Events   []*auditRepository.Event `json:"events"`
+}
+
+func NewAudit(auditLog auditRepository.Repository, masker *Masker) Audit {
+	return &audit{
+		audit:  auditLog,
+		masker: masker,
+	}
+}
+
+// auditEvent fills in who did it, for events which are recorded together with the action in a transaction.
+func auditEvent(c *fiber.Ctx, event *auditRepository.Event) *auditRepository.Event {
+	event.Actor = currentUser(c)
+	event.IP = c.IP()
+
+	return event
+}
+
+// recordAudit fills in who did it and when. A failed write is only logged, the action itself already happened.
+func recordAudit(c *fiber.Ctx, auditLog auditRepository.Repository, event *auditRepository.Event) {
+	if err := auditLog.Record(c.Context(), auditEvent(c, event)); err != nil {
+		logrus.Errorf("Couldn't record %s audit event: %s", event.Action, err)
+	}
+}
+
+func (a *audit) GetEvents(c *fiber.Ctx) error {
+	filter := &auditRepository.EventFilter{
+		Action:   c.Query("action"),
+		EntryID:  c.QueryInt("entry_id"),
+		Page:     c.QueryInt("page", 1),
+		PageSize: c.QueryInt("page_size", defaultPageSize),
+	}
+
+	if filter.Page < 1 {
+		filter.Page = 1
+	}
+
+	if filter.PageSize < 1 || filter.PageSize > maxPageSize {
+		filter.PageSize = defaultPageSize
+	}
+
+	if actorID := c.Query("actor_id"); actorID != "" {
+		id, err := primitive.ObjectIDFromHex(actorID)
+		if err != nil {
+			return badRequest("invalid actor_id")
+		}
+
+		filter.ActorID = &id
+	}
+
+	if from := c.QueryInt("from"); from > 0 {
+		filter.From = time.Unix(int64(from), 0)
+	}
+
+	if to := c.QueryInt("to"); to > 0 {
+		filter.To = time.Unix(int64(to), 0)
+	}
+
+	count, err := a.audit.CountEvents(c.Context(), filter)
+	if err != nil {
+		return err
+	}
+
+	events, err := a.audit.GetEvents(c.Context(), filter)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(&AuditResponse{
+		Count:    count,
+		Page:     filter.Page,
+		PageSize: filter.PageSize,
+		Events:   a.masker.Events(c, events),
+	})
+}
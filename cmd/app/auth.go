@@ -0,0 +1,432 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/YusufOzmen01/veri-kontrol-backend/core/ratelimit"
+	"github.com/YusufOzmen01/veri-kontrol-backend/core/tokens"
+	usersRepository "github.com/YusufOzmen01/veri-kontrol-backend/repository/users"
+	"github.com/YusufOzmen01/veri-kontrol-backend/util"
+	"github.com/gofiber/fiber/v2"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	defaultAuthMaxFailures = 10
+	defaultAuthLockout     = 15 * time.Minute
+
+	// knownKeyTTL is how long a remembered user keeps working while Mongo is down after its key was last
+	// checked. Deactivations and rotations on other replicas aren't seen here, it bounds how long they aren't.
+	knownKeyTTL = 12 * time.Hour
+)
+
+type Auth interface {
+	Login(c *fiber.Ctx) error
+	Refresh(c *fiber.Ctx) error
+	Logout(c *fiber.Ctx) error
+	Identify(c *fiber.Ctx) error
+	RequirePerm(level int) fiber.Handler
+	RequireScope(scope string) fiber.Handler
+	RequireCurrentKey(c *fiber.Ctx) error
+	GetAuthFailures(c *fiber.Ctx) error
+	Forget(id primitive.ObjectID)
+}
+
+// auth counts failed Auth-Keys per IP and per key in lockout. The counts are kept in memory, with several
+// replicas every one of them locks out on its own.
+//
+// Users whose Auth-Key was accepted before are remembered, they can keep working while Mongo is down.
+type auth struct {
+	users       usersRepository.Repository
+	degradation *Degradation
+	tokens      tokens.Manager
+	lockout     *ratelimit.Lockout
+
+	known sync.Map
+}
+
+type knownUser struct {
+	user      *usersRepository.User
+	checkedAt time.Time
+}
+
+// AuthFailuresResponse lists the logged failures, Locked are the IPs and key hashes this replica locks out
+// right now.
+type AuthFailuresResponse struct {
+	Count    int64                          `json:"count"`
+	Page     int                            `json:"page"`
+	PageSize int                            `json:"page_size"`
+	Failures []*usersRepository.AuthFailure `json:"failures"`
+	Locked   []string                       `json:"locked"`
+}
+
+type LoginBody struct {
+	AuthKey string `json:"auth_key"`
+}
+
+type RefreshBody struct {
+	RefreshToken string `json:"refresh_token"`
+	All          bool   `json:"all"`
+}
+
+type TokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresAt    int64  `json:"expires_at"`
+}
+
+func NewAuth(users usersRepository.Repository, degradation *Degradation, tokens tokens.Manager, lockout *ratelimit.Lockout) Auth {
+	return &auth{
+		users:       users,
+		degradation: degradation,
+		tokens:      tokens,
+		lockout:     lockout,
+	}
+}
+
+func currentUser(c *fiber.Ctx) *usersRepository.User {
+	user, _ := c.Locals("user").(*usersRepository.User)
+
+	return user
+}
+
+func (a *auth) issueTokens(c *fiber.Ctx, user *usersRepository.User) error {
+	accessToken, expiresAt, err := a.tokens.Issue(user.ID.Hex(), user.Name, user.PermLevel, user.Scopes)
+	if err != nil {
+		return err
+	}
+
+	refreshToken, err := a.users.CreateRefreshToken(c.Context(), user.ID)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(&TokenResponse{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		ExpiresAt:    expiresAt.Unix(),
+	})
+}
+
+func (a *auth) Login(c *fiber.Ctx) error {
+	body := &LoginBody{}
+
+	if err := json.Unmarshal(c.Body(), body); err != nil {
+		return badRequest(err.Error())
+	}
+
+	user, err := a.userByKey(c, body.AuthKey)
+	if err != nil {
+		return err
+	}
+
+	if user == nil {
+		return unauthorized("User not found.")
+	}
+
+	// Tokens would outlive the grace period of a replaced key.
+	if user.ByPreviousKey {
+		return forbidden(errReplacedKey)
+	}
+
+	return a.issueTokens(c, user)
+}
+
+func (a *auth) Refresh(c *fiber.Ctx) error {
+	body := &RefreshBody{}
+
+	if err := json.Unmarshal(c.Body(), body); err != nil {
+		return badRequest(err.Error())
+	}
+
+	// Refresh tokens are single use, the client receives a new one with every refresh. Consuming it is atomic,
+	// two refreshes racing with the same token can't both get new tokens.
+	refreshToken, err := a.users.ConsumeRefreshToken(c.Context(), body.RefreshToken)
+	if err != nil {
+		if errors.Is(err, usersRepository.ErrRefreshTokenInvalid) {
+			return unauthorized(err.Error())
+		}
+
+		return err
+	}
+
+	user, err := a.users.GetUserByID(c.Context(), refreshToken.UserID)
+	if err != nil || user.Deactivated {
+		return unauthorized("User not found.")
+	}
+
+	return a.issueTokens(c, user)
+}
+
+func (a *auth) Logout(c *fiber.Ctx) error {
+	body := &RefreshBody{}
+
+	if err := json.Unmarshal(c.Body(), body); err != nil {
+		return badRequest(err.Error())
+	}
+
+	refreshToken, err := a.users.GetRefreshToken(c.Context(), body.RefreshToken)
+	if err != nil {
+		return unauthorized(err.Error())
+	}
+
+	if body.All {
+		err = a.users.RevokeUserRefreshTokens(c.Context(), refreshToken.UserID)
+	} else {
+		err = a.users.RevokeRefreshToken(c.Context(), body.RefreshToken)
+	}
+	if err != nil {
+		return err
+	}
+
+	return c.SendString("Successfully logged out!")
+}
+
+// authenticate resolves the user from a bearer token without touching the database. The legacy Auth-Key
+// header is still accepted so older clients keep working until they switch to /auth/login, X-API-Key is
+// the same key for external consumers of the public API.
+// The only error is a lockout.
+func (a *auth) authenticate(c *fiber.Ctx) (*usersRepository.User, error) {
+	if header := c.Get(fiber.HeaderAuthorization); strings.HasPrefix(header, "Bearer ") {
+		claims, err := a.tokens.Parse(strings.TrimPrefix(header, "Bearer "))
+		if err != nil {
+			return nil, nil
+		}
+
+		id, err := primitive.ObjectIDFromHex(claims.Subject)
+		if err != nil {
+			return nil, nil
+		}
+
+		return &usersRepository.User{
+			ID:        id,
+			Name:      claims.Name,
+			PermLevel: claims.PermLevel,
+			Scopes:    claims.Scopes,
+		}, nil
+	}
+
+	authKey := c.Get("Auth-Key")
+	if authKey == "" {
+		authKey = c.Get("X-API-Key")
+	}
+
+	if authKey != "" {
+		return a.userByKey(c, authKey)
+	}
+
+	return nil, nil
+}
+
+// knownUser is the remembered user of a key hash, nil once its key wasn't checked for knownKeyTTL.
+func (a *auth) knownUser(keyHash uint32) *usersRepository.User {
+	value, ok := a.known.Load(keyHash)
+	if !ok {
+		return nil
+	}
+
+	known := value.(*knownUser)
+	if time.Since(known.checkedAt) > knownKeyTTL {
+		a.known.Delete(keyHash)
+
+		return nil
+	}
+
+	return known.user
+}
+
+// Forget drops the remembered keys of a user, after it was deactivated or its key was rotated.
+func (a *auth) Forget(id primitive.ObjectID) {
+	a.known.Range(func(keyHash, value interface{}) bool {
+		if value.(*knownUser).user.ID == id {
+			a.known.Delete(keyHash)
+		}
+
+		return true
+	})
+}
+
+// userByKey returns the user of an Auth-Key, nil when there is none. Unknown keys are logged and counted
+// against the IP and the key, once either failed too often it gets 429s until the lockout ends. Keys which
+// worked before still get in from a locked out IP, it may be a NAT shared with whoever is guessing. A success
+// only forgets the failures of its own key, the ones of the IP keep counting.
+func (a *auth) userByKey(c *fiber.Ctx, authKey string) (*usersRepository.User, error) {
+	keyHash := util.Hash(authKey)
+	ipKey, hashKey := "ip:"+c.IP(), fmt.Sprintf("key:%d", keyHash)
+
+	locked := func(key string) error {
+		if locked, left := a.lockout.Locked(key); locked {
+			c.Set(fiber.HeaderRetryAfter, strconv.Itoa(int(math.Ceil(left.Seconds()))))
+
+			return tooManyRequests("Too many failed attempts, try again later.")
+		}
+
+		return nil
+	}
+
+	if err := locked(hashKey); err != nil {
+		return nil, err
+	}
+
+	// Only keys known to be valid are looked up while the IP is locked out, otherwise the answer would tell
+	// which guesses are right.
+	if a.knownUser(keyHash) == nil {
+		if err := locked(ipKey); err != nil {
+			return nil, err
+		}
+	}
+
+	user, err := a.users.GetUser(c.Context(), authKey)
+	if err == nil {
+		a.lockout.Reset(hashKey)
+		a.known.Store(keyHash, &knownUser{user: user, checkedAt: time.Now()})
+
+		return user, nil
+	}
+
+	if !errors.Is(err, usersRepository.ErrUserNotFound) {
+		if known := a.knownUser(keyHash); known != nil && a.degradation.Degraded() {
+			return known, nil
+		}
+
+		return nil, err
+	}
+
+	a.known.Delete(keyHash)
+
+	ipLocked := a.lockout.Fail(ipKey)
+	keyLocked := a.lockout.Fail(hashKey)
+
+	if ipLocked || keyLocked {
+		logrus.Warnf("Locked out %s after too many failed Auth-Keys", c.IP())
+	}
+
+	// The request is rejected either way, a failed log write isn't worth a 500.
+	_ = a.users.RecordAuthFailure(c.Context(), &usersRepository.AuthFailure{
+		IP:        c.IP(),
+		KeyHash:   keyHash,
+		Path:      c.Path(),
+		LockedOut: ipLocked || keyLocked,
+	})
+
+	return nil, nil
+}
+
+const errReplacedKey = "This Auth-Key was replaced, use the new one."
+
+// RequireCurrentKey keeps the key replaced by a rotation out of account and admin actions. Whoever stole it
+// could otherwise rotate again and keep a key of their own.
+func (a *auth) RequireCurrentKey(c *fiber.Ctx) error {
+	if user := currentUser(c); user != nil && user.ByPreviousKey {
+		return forbidden(errReplacedKey)
+	}
+
+	return c.Next()
+}
+
+func (a *auth) Identify(c *fiber.Ctx) error {
+	user, err := a.authenticate(c)
+	if err != nil {
+		return err
+	}
+
+	if user != nil {
+		c.Locals("user", user)
+	}
+
+	return c.Next()
+}
+
+// RequireScope reuses the user an earlier middleware already authenticated.
+func (a *auth) RequireScope(scope string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		user := currentUser(c)
+		if user == nil {
+			var err error
+			if user, err = a.authenticate(c); err != nil {
+				return err
+			}
+		}
+
+		if user == nil {
+			return unauthorized("User not found.")
+		}
+
+		if !user.HasScope(scope) {
+			return forbidden("You are not allowed to access here.")
+		}
+
+		c.Locals("user", user)
+
+		return c.Next()
+	}
+}
+
+func (a *auth) RequirePerm(level int) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		user, err := a.authenticate(c)
+		if err != nil {
+			return err
+		}
+
+		if user == nil {
+			return unauthorized("User not found.")
+		}
+
+		if user.PermLevel < level {
+			return forbidden("You are not allowed to access here.")
+		}
+
+		c.Locals("user", user)
+
+		return c.Next()
+	}
+}
+
+// GetAuthFailures pages through the failed Auth-Keys, newest first, optionally of one ip or key_hash.
+func (a *auth) GetAuthFailures(c *fiber.Ctx) error {
+	filter := &usersRepository.AuthFailureFilter{
+		IP:       c.Query("ip"),
+		Page:     c.QueryInt("page", 1),
+		PageSize: c.QueryInt("page_size", defaultPageSize),
+	}
+
+	if filter.Page < 1 {
+		filter.Page = 1
+	}
+
+	if filter.PageSize < 1 || filter.PageSize > maxPageSize {
+		filter.PageSize = defaultPageSize
+	}
+
+	if keyHash := c.Query("key_hash"); keyHash != "" {
+		hash, err := strconv.ParseUint(keyHash, 10, 32)
+		if err != nil {
+			return badRequest("invalid key_hash")
+		}
+
+		h := uint32(hash)
+		filter.KeyHash = &h
+	}
+
+	failures, count, err := a.users.GetAuthFailures(c.Context(), filter)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(&AuthFailuresResponse{
+		Count:    count,
+		Page:     filter.Page,
+		PageSize: filter.PageSize,
+		Failures: failures,
+		Locked:   a.lockout.LockedKeys(),
+	})
+}
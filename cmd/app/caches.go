@@ -0,0 +1,112 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/YusufOzmen01/veri-kontrol-backend/core/sources"
+	"github.com/YusufOzmen01/veri-kontrol-backend/core/tracing"
+	auditRepository "github.com/YusufOzmen01/veri-kontrol-backend/repository/audit"
+	locationsRepository "github.com/YusufOzmen01/veri-kontrol-backend/repository/locations"
+	"github.com/YusufOzmen01/veri-kontrol-backend/tools"
+	"github.com/gofiber/fiber/v2"
+	"github.com/samber/lo"
+)
+
+type Caches interface {
+	Invalidate(c *fiber.Ctx) error
+	Warm(c *fiber.Ctx) error
+}
+
+type caches struct {
+	cache       sources.Cache
+	invalidator *tools.CacheInvalidator
+	audit       auditRepository.Repository
+}
+
+// CacheResponse counts the entries whose cached copies were dropped or fetched.
+type CacheResponse struct {
+	Entries int `json:"entries"`
+}
+
+func NewCaches(cache sources.Cache, invalidator *tools.CacheInvalidator, auditLog auditRepository.Repository) Caches {
+	return &caches{
+		cache:       cache,
+		invalidator: invalidator,
+		audit:       auditLog,
+	}
+}
+
+func cacheEntryID(c *fiber.Ctx) (int, error) {
+	entryID := c.QueryInt("entry_id", 0)
+	if entryID < 0 {
+		return 0, badRequest("invalid entry_id")
+	}
+
+	return entryID, nil
+}
+
+// Invalidate drops the cached feed and texts, or only the text of entry_id. Nothing is fetched until the next
+// request or refresh needs it, warm does that right away. The other replicas drop theirs on their next poll,
+// entries counts the ones dropped here.
+func (ca *caches) Invalidate(c *fiber.Ctx) error {
+	entryID, err := cacheEntryID(c)
+	if err != nil {
+		return err
+	}
+
+	dropped, err := ca.invalidator.Invalidate(c.Context(), entryID)
+	if err != nil {
+		return serviceUnavailable(fmt.Sprintf("dropped the cache of this replica only: %s", err))
+	}
+
+	recordAudit(c, ca.audit, &auditRepository.Event{
+		Action:  auditRepository.ActionCacheInvalidate,
+		EntryID: entryID,
+	})
+
+	return c.JSON(&CacheResponse{Entries: dropped})
+}
+
+// Warm fetches the text of entry_id, or pulls the feed. The texts of the feed are looked up after the response,
+// the same way the refresher does it.
+func (ca *caches) Warm(c *fiber.Ctx) error {
+	entryID, err := cacheEntryID(c)
+	if err != nil {
+		return err
+	}
+
+	if entryID > 0 {
+		locs, err := tools.GetAllLocations(c.Context(), ca.cache)
+		if err != nil {
+			return serviceUnavailable(err.Error())
+		}
+
+		if _, ok := lo.Find(locs, func(loc *locationsRepository.Location) bool { return loc.EntryID == entryID }); !ok {
+			return notFound("entry isn't in the feed")
+		}
+
+		if _, err := tools.GetSingleLocation(c.Context(), entryID, ca.cache); err != nil {
+			return serviceUnavailable(err.Error())
+		}
+
+		recordAudit(c, ca.audit, &auditRepository.Event{
+			Action:  auditRepository.ActionCacheWarm,
+			EntryID: entryID,
+		})
+
+		return c.JSON(&CacheResponse{Entries: 1})
+	}
+
+	locs, err := tools.RefreshLocations(c.Context(), ca.cache)
+	if err != nil {
+		return serviceUnavailable(err.Error())
+	}
+
+	go tools.PrefetchTexts(tracing.Detach(c.Context()), ca.cache, locs)
+
+	recordAudit(c, ca.audit, &auditRepository.Event{
+		Action: auditRepository.ActionCacheWarm,
+	})
+
+	return c.Status(fiber.StatusAccepted).JSON(&CacheResponse{Entries: len(locs)})
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"encoding/json"
+	"strconv"
+
+	auditRepository "github.com/YusufOzmen01/veri-kontrol-backend/repository/audit"
+	citiesRepository "github.com/YusufOzmen01/veri-kontrol-backend/repository/cities"
+	"github.com/gofiber/fiber/v2"
+)
+
+type Cities interface {
+	GetCities(c *fiber.Ctx) error
+	AddCity(c *fiber.Ctx) error
+	UpdateCity(c *fiber.Ctx) error
+	DeleteCity(c *fiber.Ctx) error
+}
+
+type cities struct {
+	cities citiesRepository.Repository
+	audit  auditRepository.Repository
+}
+
+func NewCities(cityRepository citiesRepository.Repository, auditLog auditRepository.Repository) Cities {
+	return &cities{
+		cities: cityRepository,
+		audit:  auditLog,
+	}
+}
+
+func (ci *cities) GetCities(c *fiber.Ctx) error {
+	list, err := ci.cities.GetCities(c.Context())
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(list)
+}
+
+func (ci *cities) AddCity(c *fiber.Ctx) error {
+	city := &citiesRepository.City{}
+
+	if err := json.Unmarshal(c.Body(), city); err != nil {
+		return badRequest(err.Error())
+	}
+
+	if err := city.Validate(); err != nil {
+		return badRequest(err.Error())
+	}
+
+	if err := ci.cities.AddCity(c.Context(), city); err != nil {
+		if err == citiesRepository.ErrCityExists {
+			return conflict(err.Error())
+		}
+
+		return err
+	}
+
+	recordAudit(c, ci.audit, &auditRepository.Event{
+		Action:  auditRepository.ActionCityAdd,
+		Target:  strconv.Itoa(city.ID),
+		Changes: auditRepository.Diff(nil, city),
+	})
+
+	return c.JSON(city)
+}
+
+func (ci *cities) UpdateCity(c *fiber.Ctx) error {
+	cityID, err := c.ParamsInt("city_id")
+	if err != nil {
+		return badRequest(err.Error())
+	}
+
+	city := &citiesRepository.City{}
+
+	if err := json.Unmarshal(c.Body(), city); err != nil {
+		return badRequest(err.Error())
+	}
+
+	city.ID = cityID
+
+	if err := city.Validate(); err != nil {
+		return badRequest(err.Error())
+	}
+
+	before, err := ci.cities.GetCity(c.Context(), cityID)
+	if err != nil {
+		return notFound(err.Error())
+	}
+
+	if err := ci.cities.UpdateCity(c.Context(), city); err != nil {
+		return err
+	}
+
+	recordAudit(c, ci.audit, &auditRepository.Event{
+		Action:  auditRepository.ActionCityUpdate,
+		Target:  strconv.Itoa(cityID),
+		Changes: auditRepository.Diff(before, city),
+	})
+
+	return c.JSON(city)
+}
+
+func (ci *cities) DeleteCity(c *fiber.Ctx) error {
+	cityID, err := c.ParamsInt("city_id")
+	if err != nil {
+		return badRequest(err.Error())
+	}
+
+	before, err := ci.cities.GetCity(c.Context(), cityID)
+	if err != nil {
+		return notFound(err.Error())
+	}
+
+	if err := ci.cities.DeleteCity(c.Context(), cityID); err != nil {
+		return err
+	}
+
+	recordAudit(c, ci.audit, &auditRepository.Event{
+		Action:  auditRepository.ActionCityDelete,
+		Target:  strconv.Itoa(cityID),
+		Changes: auditRepository.Diff(before, nil),
+	})
+
+	return c.SendString("Successfully deleted!")
+}
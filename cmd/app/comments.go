@@ -0,0 +1,99 @@
+package main
+
+import (
+	"encoding/json"
+
+	auditRepository "github.com/YusufOzmen01/veri-kontrol-backend/repository/audit"
+	commentsRepository "github.com/YusufOzmen01/veri-kontrol-backend/repository/comments"
+	"github.com/YusufOzmen01/veri-kontrol-backend/validation"
+	"github.com/gofiber/fiber/v2"
+)
+
+type Comments interface {
+	GetComments(c *fiber.Ctx) error
+	AddComment(c *fiber.Ctx) error
+}
+
+type comments struct {
+	comments commentsRepository.Repository
+	audit    auditRepository.Repository
+	masker   *Masker
+}
+
+type CommentBody struct {
+	Text string `json:"text"`
+}
+
+type CommentsResponse struct {
+	EntryID  int                           `json:"entry_id"`
+	Comments []*commentsRepository.Comment `json:"comments"`
+}
+
+const maxCommentLength = 1000
+
+func NewComments(commentRepository commentsRepository.Repository, auditLog auditRepository.Repository, masker *Masker) Comments {
+	return &comments{
+		comments: commentRepository,
+		audit:    auditLog,
+		masker:   masker,
+	}
+}
+
+func (cm *comments) GetComments(c *fiber.Ctx) error {
+	entryID, err := c.ParamsInt("entry_id")
+	if err != nil {
+		return badRequest(err.Error())
+	}
+
+	list, err := cm.comments.GetComments(c.Context(), entryID)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(&CommentsResponse{
+		EntryID:  entryID,
+		Comments: cm.masker.Comments(c, list),
+	})
+}
+
+func (cm *comments) AddComment(c *fiber.Ctx) error {
+	entryID, err := c.ParamsInt("entry_id")
+	if err != nil || entryID <= 0 {
+		return badRequest("invalid entry_id")
+	}
+
+	body := &CommentBody{}
+
+	if err := json.Unmarshal(c.Body(), body); err != nil {
+		return badRequest(err.Error())
+	}
+
+	body.Text = validation.NormalizeText(body.Text)
+
+	v := validation.New()
+	v.Required("text", body.Text)
+	v.MaxLength("text", body.Text, maxCommentLength)
+
+	if err := v.Err(); err != nil {
+		return validationFailed(err)
+	}
+
+	comment := &commentsRepository.Comment{
+		EntryID: entryID,
+		Author:  currentUser(c),
+		Text:    body.Text,
+	}
+
+	if err := cm.comments.AddComment(c.Context(), comment); err != nil {
+		return err
+	}
+
+	recordAudit(c, cm.audit, &auditRepository.Event{
+		Action:  auditRepository.ActionCommentAdd,
+		EntryID: entryID,
+		Target:  comment.ID.Hex(),
+		Changes: auditRepository.Diff(nil, body),
+	})
+
+	return c.JSON(cm.masker.Comment(c, comment))
+}
@@ -0,0 +1,368 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+
+	"github.com/Netflix/go-env"
+	"github.com/YusufOzmen01/veri-kontrol-backend/core/queue"
+	"github.com/YusufOzmen01/veri-kontrol-backend/core/scheduler"
+	"github.com/YusufOzmen01/veri-kontrol-backend/core/scoring"
+	"github.com/YusufOzmen01/veri-kontrol-backend/core/storage"
+	"github.com/YusufOzmen01/veri-kontrol-backend/core/tracing"
+	"github.com/YusufOzmen01/veri-kontrol-backend/tools"
+	"github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
+)
+
+// configFileEnv points to an optional YAML file, environment variables override the values in it.
+const configFileEnv = "config_file"
+
+type Environment struct {
+	Port               int     `env:"port" yaml:"port"`
+	LogLevel           string  `env:"log_level" yaml:"log_level"`
+	CORSOrigins        string  `env:"cors_origins" yaml:"cors_origins"`
+	CORSMethods        string  `env:"cors_methods" yaml:"cors_methods"`
+	CORSHeaders        string  `env:"cors_headers" yaml:"cors_headers"`
+	TrustedProxies     string  `env:"trusted_proxies" yaml:"trusted_proxies"`
+	ProxyHeader        string  `env:"proxy_header" yaml:"proxy_header"`
+	TLSCertFile        string  `env:"tls_cert_file" yaml:"tls_cert_file"`
+	TLSKeyFile         string  `env:"tls_key_file" yaml:"tls_key_file"`
+	AutocertDomains    string  `env:"autocert_domains" yaml:"autocert_domains"`
+	AutocertEmail      string  `env:"autocert_email" yaml:"autocert_email"`
+	AutocertCacheDir   string  `env:"autocert_cache_dir" yaml:"autocert_cache_dir"`
+	HTTPRedirectPort   int     `env:"http_redirect_port" yaml:"http_redirect_port"`
+	HSTSSeconds        int     `env:"hsts_seconds" yaml:"hsts_seconds"`
+	SecureHeaders      bool    `env:"secure_headers" yaml:"secure_headers"`
+	WALPath            string  `env:"wal_path" yaml:"wal_path"`
+	JournalPath        string  `env:"journal_path" yaml:"journal_path"`
+	JournalDays        int     `env:"journal_retention_days" yaml:"journal_retention_days"`
+	MongoUri           string  `env:"mongo_uri" yaml:"mongo_uri"`
+	JWTSecret          string  `env:"jwt_secret" yaml:"jwt_secret"`
+	ClaimMinutes       int     `env:"claim_minutes" yaml:"claim_minutes"`
+	MaxClaims          int     `env:"max_claims" yaml:"max_claims"`
+	AuthMaxFailures    int     `env:"auth_max_failures" yaml:"auth_max_failures"`
+	AuthLockoutMinutes int     `env:"auth_lockout_minutes" yaml:"auth_lockout_minutes"`
+	KeyGraceMinutes    int     `env:"key_grace_minutes" yaml:"key_grace_minutes"`
+	RedisUri           string  `env:"redis_uri" yaml:"redis_uri"`
+	CacheMaxCost       int64   `env:"cache_max_cost" yaml:"cache_max_cost"`
+	CacheCounters      int64   `env:"cache_counters" yaml:"cache_counters"`
+	UpstreamURL        string  `env:"upstream_url" yaml:"upstream_url"`
+	UpstreamSources    string  `env:"upstream_sources" yaml:"upstream_sources"`
+	SyntheticUpstream  bool    `env:"synthetic_upstream,SYNTHETIC_UPSTREAM" yaml:"synthetic_upstream"`
+	SyntheticEntries   int     `env:"synthetic_entries,SYNTHETIC_ENTRIES" yaml:"synthetic_entries"`
+	FeedCacheSeconds   int     `env:"feed_cache_seconds" yaml:"feed_cache_seconds"`
+	RefreshSeconds     int     `env:"refresh_seconds" yaml:"refresh_seconds"`
+	TwoPersonReview    bool    `env:"two_person_review" yaml:"two_person_review"`
+	StrictSender       bool    `env:"strict_sender" yaml:"strict_sender"`
+	DedupSeconds       int     `env:"dedup_seconds" yaml:"dedup_seconds"`
+	DedupThreshold     float64 `env:"dedup_threshold" yaml:"dedup_threshold"`
+	RateLimitUser      int     `env:"rate_limit_user" yaml:"rate_limit_user"`
+	RateLimitIP        int     `env:"rate_limit_ip" yaml:"rate_limit_ip"`
+	RateLimitBurst     int     `env:"rate_limit_burst" yaml:"rate_limit_burst"`
+	AllowedReasons     string  `env:"allowed_reasons" yaml:"allowed_reasons"`
+	Strategy           string  `env:"selection_strategy" yaml:"selection_strategy"`
+	Geocoder           string  `env:"geocoder" yaml:"geocoder"`
+	GeocoderURL        string  `env:"geocoder_url" yaml:"geocoder_url"`
+	GoogleMapsKey      string  `env:"google_maps_key" yaml:"google_maps_key"`
+	Translator         string  `env:"translator" yaml:"translator"`
+	TranslatorURL      string  `env:"translator_url" yaml:"translator_url"`
+	TranslatorKey      string  `env:"translator_key" yaml:"translator_key"`
+	ShutdownSeconds    int     `env:"shutdown_seconds" yaml:"shutdown_seconds"`
+	TelegramToken      string  `env:"telegram_token" yaml:"telegram_token"`
+	NotifySeconds      int     `env:"notify_seconds" yaml:"notify_seconds"`
+	WebhookSeconds     int     `env:"webhook_seconds" yaml:"webhook_seconds"`
+	OutboxSeconds      int     `env:"outbox_seconds" yaml:"outbox_seconds"`
+	SnapshotSchedule   string  `env:"snapshot_schedule" yaml:"snapshot_schedule"`
+	SnapshotPrefix     string  `env:"snapshot_prefix" yaml:"snapshot_prefix"`
+	StorageEndpoint    string  `env:"storage_endpoint" yaml:"storage_endpoint"`
+	StorageBucket      string  `env:"storage_bucket" yaml:"storage_bucket"`
+	StorageRegion      string  `env:"storage_region" yaml:"storage_region"`
+	StorageAccessKey   string  `env:"storage_access_key" yaml:"storage_access_key"`
+	StorageSecretKey   string  `env:"storage_secret_key" yaml:"storage_secret_key"`
+	SheetsKeyFile      string  `env:"sheets_credentials_file" yaml:"sheets_credentials_file"`
+	SheetsID           string  `env:"sheets_spreadsheet_id" yaml:"sheets_spreadsheet_id"`
+	SheetsTab          string  `env:"sheets_sheet_name" yaml:"sheets_sheet_name"`
+	SheetsSeconds      int     `env:"sheets_seconds" yaml:"sheets_seconds"`
+	UrgencyKeywords    string  `env:"urgency_keywords" yaml:"urgency_keywords"`
+	TextBlocklist      string  `env:"text_blocklist" yaml:"text_blocklist"`
+	TextCacheSeconds   int     `env:"text_cache_seconds" yaml:"text_cache_seconds"`
+	TextFetches        int     `env:"text_fetch_concurrency" yaml:"text_fetch_concurrency"`
+	Prefetches         int     `env:"prefetch_concurrency" yaml:"prefetch_concurrency"`
+	MaxPending         int     `env:"max_pending_registrations" yaml:"max_pending_registrations"`
+	QASamplePercent    float64 `env:"qa_sample_percent" yaml:"qa_sample_percent"`
+	ReapSeconds        int     `env:"reap_seconds" yaml:"reap_seconds"`
+	PendingMaxHours    int     `env:"pending_max_hours" yaml:"pending_max_hours"`
+	OTLPEndpoint       string  `env:"otlp_endpoint" yaml:"otlp_endpoint"`
+	TraceSampleRatio   float64 `env:"trace_sample_ratio" yaml:"trace_sample_ratio"`
+	DevMode            bool    `env:"dev_mode" yaml:"dev_mode"`
+	DevToken           string  `env:"dev_token" yaml:"dev_token"`
+}
+
+// defaultEnvironment only covers the new settings, older ones keep falling back where they are used so a
+// zero value still means the default.
+func defaultEnvironment() *Environment {
+	return &Environment{
+		Port:             80,
+		LogLevel:         "info",
+		CORSOrigins:      "*",
+		CORSMethods:      defaultCORSMethods,
+		WALPath:          defaultWALPath,
+		JournalPath:      defaultJournalPath,
+		JournalDays:      defaultJournalDays,
+		CORSHeaders:      defaultCORSHeaders,
+		ProxyHeader:      "X-Real-IP",
+		CacheMaxCost:     1 << 30,
+		CacheCounters:    1e7,
+		UpstreamURL:      "https://apigo.afetharita.com",
+		SyntheticEntries: 10_000,
+		FeedCacheSeconds: 15 * 60,
+		SnapshotSchedule: DefaultSnapshotSchedule,
+		SnapshotPrefix:   "snapshots/",
+		StorageRegion:    "us-east-1",
+		SheetsTab:        "Sheet1",
+		UrgencyKeywords:  scoring.DefaultWeights,
+		TextCacheSeconds: 24 * 60 * 60,
+		TextFetches:      8,
+		Prefetches:       2,
+		MaxPending:       100,
+		TraceSampleRatio: 1,
+	}
+}
+
+// LoadEnvironment applies the defaults, then the config file, then the environment variables.
+func LoadEnvironment() (*Environment, error) {
+	environment := defaultEnvironment()
+
+	if path := os.Getenv(configFileEnv); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("couldn't read config file: %w", err)
+		}
+
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		decoder.KnownFields(true)
+
+		if err := decoder.Decode(environment); err != nil {
+			return nil, fmt.Errorf("couldn't parse config file: %w", err)
+		}
+	}
+
+	if _, err := env.UnmarshalFromEnviron(environment); err != nil {
+		return nil, err
+	}
+
+	if err := environment.Validate(); err != nil {
+		return nil, err
+	}
+
+	return environment, nil
+}
+
+func (e *Environment) Validate() error {
+	if e.MongoUri == "" {
+		return fmt.Errorf("mongo_uri is required")
+	}
+
+	if e.Port < 1 || e.Port > 65535 {
+		return fmt.Errorf("port must be between 1 and 65535")
+	}
+
+	if _, err := logrus.ParseLevel(e.LogLevel); err != nil {
+		return fmt.Errorf("log_level: %w", err)
+	}
+
+	if _, err := newCORSPolicy(e.CORSOrigins, e.CORSMethods, e.CORSHeaders); err != nil {
+		return fmt.Errorf("cors: %s", err)
+	}
+
+	if len(splitCSV(e.CORSMethods)) == 0 {
+		return fmt.Errorf("cors_methods must not be empty")
+	}
+
+	for _, proxy := range splitCSV(e.TrustedProxies) {
+		if _, _, err := net.ParseCIDR(proxy); err != nil && net.ParseIP(proxy) == nil {
+			return fmt.Errorf("trusted_proxies: %q is neither an IP nor a CIDR range", proxy)
+		}
+	}
+
+	if e.TrustedProxies != "" && e.ProxyHeader == "" {
+		return fmt.Errorf("proxy_header is required with trusted_proxies")
+	}
+
+	if e.DevMode && e.TLSEnabled() {
+		return fmt.Errorf("dev_mode is for local development, it can't be used with TLS")
+	}
+
+	if (e.TLSCertFile == "") != (e.TLSKeyFile == "") {
+		return fmt.Errorf("tls_cert_file and tls_key_file have to be set together")
+	}
+
+	if e.TLSCertFile != "" && e.AutocertDomains != "" {
+		return fmt.Errorf("use either tls_cert_file or autocert_domains")
+	}
+
+	if e.HTTPRedirectPort < 0 || e.HTTPRedirectPort > 65535 || (e.HTTPRedirectPort != 0 && e.HTTPRedirectPort == e.Port) {
+		return fmt.Errorf("http_redirect_port must be between 1 and 65535 and differ from port")
+	}
+
+	if e.HTTPRedirectPort != 0 && !e.TLSEnabled() {
+		return fmt.Errorf("http_redirect_port needs tls_cert_file or autocert_domains")
+	}
+
+	if e.CacheMaxCost <= 0 || e.CacheCounters <= 0 {
+		return fmt.Errorf("cache_max_cost and cache_counters must be positive")
+	}
+
+	if u, err := url.Parse(e.UpstreamURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("upstream_url must be an http or https URL")
+	}
+
+	if _, err := tools.ParseSources(e.UpstreamSources); err != nil {
+		return fmt.Errorf("upstream_sources: %s", err)
+	}
+
+	if e.SyntheticUpstream && e.UpstreamSources != "" {
+		return fmt.Errorf("use either synthetic_upstream or upstream_sources")
+	}
+
+	if e.SyntheticUpstream && (e.SyntheticEntries < 1 || e.SyntheticEntries >= tools.SourceIDSpan) {
+		return fmt.Errorf("synthetic_entries must be between 1 and %d", tools.SourceIDSpan-1)
+	}
+
+	if _, err := scoring.Parse(e.UrgencyKeywords); err != nil {
+		return fmt.Errorf("urgency_keywords: %s", err)
+	}
+
+	if e.FeedCacheSeconds <= 0 {
+		return fmt.Errorf("feed_cache_seconds must be positive")
+	}
+
+	if e.TextCacheSeconds <= 0 {
+		return fmt.Errorf("text_cache_seconds must be positive")
+	}
+
+	if e.TextFetches < 1 {
+		return fmt.Errorf("text_fetch_concurrency must be at least 1")
+	}
+
+	if e.Prefetches < 1 || e.Prefetches > e.TextFetches {
+		return fmt.Errorf("prefetch_concurrency must be between 1 and text_fetch_concurrency")
+	}
+
+	if e.MaxPending < 1 {
+		return fmt.Errorf("max_pending_registrations must be at least 1")
+	}
+
+	if e.JournalDays < 1 {
+		return fmt.Errorf("journal_retention_days must be at least 1")
+	}
+
+	for name, value := range map[string]int{
+		"claim_minutes":        e.ClaimMinutes,
+		"max_claims":           e.MaxClaims,
+		"auth_max_failures":    e.AuthMaxFailures,
+		"auth_lockout_minutes": e.AuthLockoutMinutes,
+		"key_grace_minutes":    e.KeyGraceMinutes,
+		"hsts_seconds":         e.HSTSSeconds,
+		"refresh_seconds":      e.RefreshSeconds,
+		"dedup_seconds":        e.DedupSeconds,
+		"rate_limit_user":      e.RateLimitUser,
+		"rate_limit_ip":        e.RateLimitIP,
+		"rate_limit_burst":     e.RateLimitBurst,
+		"shutdown_seconds":     e.ShutdownSeconds,
+		"notify_seconds":       e.NotifySeconds,
+		"webhook_seconds":      e.WebhookSeconds,
+		"outbox_seconds":       e.OutboxSeconds,
+		"sheets_seconds":       e.SheetsSeconds,
+		"reap_seconds":         e.ReapSeconds,
+	} {
+		if value < 0 {
+			return fmt.Errorf("%s must not be negative", name)
+		}
+	}
+
+	if e.DedupThreshold < 0 || e.DedupThreshold > 1 {
+		return fmt.Errorf("dedup_threshold must be between 0 and 1")
+	}
+
+	if e.PendingMaxHours < 0 {
+		return fmt.Errorf("pending_max_hours must not be negative, 0 keeps pending reviews until approved")
+	}
+
+	if e.QASamplePercent < 0 || e.QASamplePercent > 100 {
+		return fmt.Errorf("qa_sample_percent must be between 0 and 100")
+	}
+
+	if e.Strategy != "" {
+		if _, err := queue.ParseStrategy(e.Strategy); err != nil {
+			return fmt.Errorf("selection_strategy: %w", err)
+		}
+	}
+
+	if e.StorageBucket != "" {
+		if _, err := scheduler.Parse(e.SnapshotSchedule); err != nil {
+			return fmt.Errorf("snapshot_schedule: %w", err)
+		}
+
+		storageConfig := e.StorageConfig()
+		if err := storageConfig.Validate(); err != nil {
+			return fmt.Errorf("storage: %w", err)
+		}
+	}
+
+	if e.SheetsID != "" && (e.SheetsKeyFile == "" || e.SheetsTab == "") {
+		return fmt.Errorf("sheets_credentials_file and sheets_sheet_name are required with sheets_spreadsheet_id")
+	}
+
+	if e.OTLPEndpoint != "" {
+		tracingConfig := e.TracingConfig()
+		if err := tracingConfig.Validate(); err != nil {
+			return fmt.Errorf("tracing: %w", err)
+		}
+	}
+
+	switch e.Geocoder {
+	case "", "nominatim":
+	case "google":
+		if e.GoogleMapsKey == "" {
+			return fmt.Errorf("google_maps_key is required for the google geocoder")
+		}
+	default:
+		return fmt.Errorf("geocoder must be nominatim or google")
+	}
+
+	switch e.Translator {
+	case "":
+	case "deepl", "google":
+		if e.TranslatorKey == "" {
+			return fmt.Errorf("translator_key is required for the %s translator", e.Translator)
+		}
+	default:
+		return fmt.Errorf("translator must be deepl or google")
+	}
+
+	return nil
+}
+
+// TracingConfig is the collector the traces go to, tracing is off while otlp_endpoint is empty.
+func (e *Environment) TracingConfig() tracing.Config {
+	return tracing.Config{
+		Endpoint:    e.OTLPEndpoint,
+		SampleRatio: e.TraceSampleRatio,
+	}
+}
+
+// StorageConfig is the bucket the snapshots go to, snapshots are off while storage_bucket is empty.
+func (e *Environment) StorageConfig() storage.Config {
+	return storage.Config{
+		Endpoint:  e.StorageEndpoint,
+		Bucket:    e.StorageBucket,
+		Region:    e.StorageRegion,
+		AccessKey: e.StorageAccessKey,
+		SecretKey: e.StorageSecretKey,
+	}
+}
@@ -0,0 +1,21 @@
+package main
+
+import "testing"
+
+func TestSyntheticUpstreamEnv(t *testing.T) {
+	for _, name := range []string{"synthetic_upstream", "SYNTHETIC_UPSTREAM"} {
+		t.Run(name, func(t *testing.T) {
+			t.Setenv("mongo_uri", "mongodb://localhost:27017")
+			t.Setenv(name, "true")
+
+			environment, err := LoadEnvironment()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !environment.SyntheticUpstream {
+				t.Fatalf("%s=true didn't turn on synthetic_upstream", name)
+			}
+		})
+	}
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	defaultCORSMethods = "GET,POST,PUT,PATCH,DELETE,HEAD"
+	defaultCORSHeaders = "Accept,Content-Type,Authorization,Auth-Key,X-API-Key,Idempotency-Key,API-Version,If-None-Match,X-Request-ID"
+
+	// corsMaxAge lets browsers skip the preflight of a route for 10 minutes.
+	corsMaxAge = 10 * 60
+)
+
+// corsExposed are the response headers scripts of other origins may read.
+var corsExposed = strings.Join([]string{headerAPIVersion, headerReplayed, fiber.HeaderRetryAfter, fiber.HeaderETag}, ",")
+
+var corsMethods = []string{fiber.MethodGet, fiber.MethodPost, fiber.MethodPut, fiber.MethodPatch, fiber.MethodDelete, fiber.MethodHead}
+
+// corsPolicy decides which other origins may call the API from a browser. Requests from an origin which isn't
+// listed are still handled, they only miss the CORS headers so the browser keeps their response from the
+// script, and so do preflights asking for a method or header which isn't allowed. The API's own origin, where
+// the docs are served, is always allowed.
+type corsPolicy struct {
+	anyOrigin bool
+	origins   map[string]bool
+	methods   map[string]bool
+	headers   map[string]bool
+
+	allowMethods string
+	allowHeaders string
+}
+
+func splitCSV(list string) []string {
+	values := make([]string, 0)
+
+	for _, value := range strings.Split(list, ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+
+	return values
+}
+
+// newCORSPolicy reads comma separated lists. No origins only allows the API's own, * allows every origin.
+func newCORSPolicy(origins, methods, headers string) (*corsPolicy, error) {
+	p := &corsPolicy{
+		origins: make(map[string]bool),
+		methods: make(map[string]bool),
+		headers: make(map[string]bool),
+	}
+
+	for _, origin := range splitCSV(origins) {
+		if origin == "*" {
+			p.anyOrigin = true
+
+			continue
+		}
+
+		u, err := url.Parse(origin)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || (u.Path != "" && u.Path != "/") || u.RawQuery != "" {
+			return nil, fmt.Errorf("origin %q must be a scheme and host like https://example.org", origin)
+		}
+
+		p.origins[u.Scheme+"://"+u.Host] = true
+	}
+
+	if p.anyOrigin && len(p.origins) > 0 {
+		return nil, fmt.Errorf("* can't be combined with other origins")
+	}
+
+	allowedMethods := make([]string, 0)
+	for _, method := range splitCSV(methods) {
+		method = strings.ToUpper(method)
+
+		known := false
+		for _, m := range corsMethods {
+			known = known || m == method
+		}
+
+		if !known {
+			return nil, fmt.Errorf("method %q must be one of %s", method, strings.Join(corsMethods, ", "))
+		}
+
+		p.methods[method] = true
+		allowedMethods = append(allowedMethods, method)
+	}
+
+	allowedHeaders := make([]string, 0)
+	for _, header := range splitCSV(headers) {
+		p.headers[strings.ToLower(header)] = true
+		allowedHeaders = append(allowedHeaders, header)
+	}
+
+	p.allowMethods = strings.Join(allowedMethods, ",")
+	p.allowHeaders = strings.Join(allowedHeaders, ",")
+
+	return p, nil
+}
+
+// sameOrigin compares the hosts only, behind a proxy which terminates TLS the scheme of the Origin is https while
+// the API is called over http.
+func sameOrigin(c *fiber.Ctx, origin string) bool {
+	u, err := url.Parse(origin)
+
+	return err == nil && u.Host != "" && strings.EqualFold(u.Host, c.Hostname())
+}
+
+func (p *corsPolicy) Handler(c *fiber.Ctx) error {
+	origin := c.Get(fiber.HeaderOrigin)
+	if origin == "" {
+		return c.Next()
+	}
+
+	c.Vary(fiber.HeaderOrigin)
+
+	preflight := c.Method() == fiber.MethodOptions && c.Get(fiber.HeaderAccessControlRequestMethod) != ""
+	allowed := p.anyOrigin || p.origins[origin] || sameOrigin(c, origin)
+
+	allowOrigin := origin
+	if p.anyOrigin {
+		allowOrigin = "*"
+	}
+
+	if !preflight {
+		if allowed {
+			c.Set(fiber.HeaderAccessControlAllowOrigin, allowOrigin)
+			c.Set(fiber.HeaderAccessControlExposeHeaders, corsExposed)
+		}
+
+		return c.Next()
+	}
+
+	c.Vary(fiber.HeaderAccessControlRequestMethod, fiber.HeaderAccessControlRequestHeaders)
+
+	if !allowed || !p.allows(c.Get(fiber.HeaderAccessControlRequestMethod), c.Get(fiber.HeaderAccessControlRequestHeaders)) {
+		return c.SendStatus(fiber.StatusNoContent)
+	}
+
+	c.Set(fiber.HeaderAccessControlAllowOrigin, allowOrigin)
+	c.Set(fiber.HeaderAccessControlAllowMethods, p.allowMethods)
+	c.Set(fiber.HeaderAccessControlAllowHeaders, p.allowHeaders)
+	c.Set(fiber.HeaderAccessControlMaxAge, strconv.Itoa(corsMaxAge))
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// allows tells whether a preflight asks for a method and headers which are allowed.
+func (p *corsPolicy) allows(method, headers string) bool {
+	if !p.methods[strings.ToUpper(method)] {
+		return false
+	}
+
+	for _, header := range splitCSV(headers) {
+		if !p.headers[strings.ToLower(header)] {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestCORSPolicy(t *testing.T) {
+	policy, err := newCORSPolicy("https://harita.example.org", defaultCORSMethods, defaultCORSHeaders)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	app := fiber.New(fiber.Config{ErrorHandler: errorHandler})
+	app.Use(policy.Handler)
+	app.Post("/resolve", func(c *fiber.Ctx) error { return c.SendStatus(fiber.StatusOK) })
+
+	tests := []struct {
+		name        string
+		method      string
+		origin      string
+		headers     map[string]string
+		wantStatus  int
+		allowOrigin string
+	}{
+		{"listed origin", "POST", "https://harita.example.org", nil, 200, "https://harita.example.org"},
+		{"other origin", "POST", "https://evil.example.org", nil, 200, ""},
+		// The API itself is behind a proxy which terminates TLS.
+		{"same origin", "POST", "https://api.example.org", nil, 200, "https://api.example.org"},
+		{"preflight", "OPTIONS", "https://harita.example.org", map[string]string{
+			fiber.HeaderAccessControlRequestMethod:  "POST",
+			fiber.HeaderAccessControlRequestHeaders: "Content-Type, Auth-Key",
+		}, 204, "https://harita.example.org"},
+		{"preflight of another origin", "OPTIONS", "https://evil.example.org", map[string]string{
+			fiber.HeaderAccessControlRequestMethod: "POST",
+		}, 204, ""},
+		{"preflight with an unknown header", "OPTIONS", "https://harita.example.org", map[string]string{
+			fiber.HeaderAccessControlRequestMethod:  "POST",
+			fiber.HeaderAccessControlRequestHeaders: "X-Unknown",
+		}, 204, ""},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, "http://api.example.org/resolve", nil)
+		req.Header.Set(fiber.HeaderOrigin, tt.origin)
+		for key, value := range tt.headers {
+			req.Header.Set(key, value)
+		}
+
+		res, err := app.Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if res.StatusCode != tt.wantStatus {
+			t.Errorf("%s: status %d, want %d", tt.name, res.StatusCode, tt.wantStatus)
+		}
+
+		if allowOrigin := res.Header.Get(fiber.HeaderAccessControlAllowOrigin); allowOrigin != tt.allowOrigin {
+			t.Errorf("%s: Access-Control-Allow-Origin %q, want %q", tt.name, allowOrigin, tt.allowOrigin)
+		}
+	}
+}
+
+func TestCORSDefault(t *testing.T) {
+	environment := defaultEnvironment()
+
+	policy, err := newCORSPolicy(environment.CORSOrigins, environment.CORSMethods, environment.CORSHeaders)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	app := fiber.New()
+	app.Use(policy.Handler)
+	app.Get("/", func(c *fiber.Ctx) error { return c.SendStatus(fiber.StatusOK) })
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(fiber.HeaderOrigin, "https://frontend.example.org")
+
+	res, err := app.Test(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if allowOrigin := res.Header.Get(fiber.HeaderAccessControlAllowOrigin); res.StatusCode != 200 || allowOrigin != "*" {
+		t.Fatalf("status %d, Access-Control-Allow-Origin %q, want every origin allowed by default", res.StatusCode, allowOrigin)
+	}
+}
@@ -0,0 +1,308 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/YusufOzmen01/veri-kontrol-backend/core/sources"
+	"github.com/YusufOzmen01/veri-kontrol-backend/core/wal"
+	"github.com/YusufOzmen01/veri-kontrol-backend/tools"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	defaultWALPath        = "data/resolutions.wal"
+	defaultJournalPath    = "data/resolutions.journal"
+	defaultDegradedChecks = 5 * time.Second
+
+	// The journal holds the texts and phone numbers of the reports, it is rotated every day and the rotated
+	// files are only kept for journal_retention_days.
+	defaultJournalDays    = 14
+	journalRotateInterval = 24 * time.Hour
+)
+
+// journalResolutions appends resolutions to the journal before they are written to Mongo or queued, so a crash
+// in between doesn't lose them. journalOutcome follows with what became of them, the adminctl journal replay
+// leaves out the rejected ones and writes the others when the database lost them. A journal which can't be
+// written doesn't fail the resolution.
+func journalResolutions(journal *wal.Journal, resolutions ...*tools.Resolution) {
+	for _, resolution := range resolutions {
+		if err := journal.Append(resolution); err != nil {
+			logrus.Errorf("Couldn't journal the resolution of entry %d: %s", resolution.Entry.EntryID, err)
+		}
+	}
+}
+
+func journalOutcome(journal *wal.Journal, resolution *tools.Resolution, outcome string) {
+	if err := journal.Append(&tools.Outcome{
+		ResolutionID: resolution.Entry.ID,
+		EntryID:      resolution.Entry.EntryID,
+		Outcome:      outcome,
+		At:           time.Now(),
+	}); err != nil {
+		logrus.Errorf("Couldn't journal the outcome of the resolution of entry %d: %s", resolution.Entry.EntryID, err)
+	}
+}
+
+// rotateJournal rotates the journal on startup and then every journalRotateInterval until ctx ends.
+func rotateJournal(ctx context.Context, journal *wal.Journal, retention time.Duration) {
+	ticker := time.NewTicker(journalRotateInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := journal.Rotate(time.Now(), retention); err != nil {
+			logrus.Errorf("Couldn't rotate the resolution journal: %s", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+type degradedClaim struct {
+	owner     string
+	expiresAt time.Time
+}
+
+// Degradation keeps the volunteers working while Mongo is down. Entries are served from the cached feed with
+// claims kept in memory, resolutions are appended to a local write-ahead log and written once Mongo answers
+// again. What the claims, hidden entries, preferences and assignments would filter out is only known from
+// what this replica saw, so the filtering is best effort until Mongo is back.
+type Degradation struct {
+	mongo  sources.MongoClient
+	log    *wal.Log
+	replay func(ctx context.Context, resolution *tools.Resolution) error
+
+	degraded atomic.Bool
+	since    atomic.Pointer[time.Time]
+
+	mu     sync.Mutex
+	queued map[int]bool
+	claims map[int]*degradedClaim
+}
+
+func NewDegradation(mongo sources.MongoClient, path string, replay func(ctx context.Context, resolution *tools.Resolution) error) (*Degradation, error) {
+	if path == "" {
+		path = defaultWALPath
+	}
+
+	log, err := wal.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't open the resolution log: %w", err)
+	}
+
+	return &Degradation{
+		mongo:  mongo,
+		log:    log,
+		replay: replay,
+		queued: make(map[int]bool),
+		claims: make(map[int]*degradedClaim),
+	}, nil
+}
+
+// Degraded is true from the first failed ping until the queued resolutions are written.
+func (d *Degradation) Degraded() bool {
+	return d.degraded.Load()
+}
+
+// Check reports the degradation for /readyz, with the number of resolutions waiting for Mongo.
+func (d *Degradation) Check() *CheckResult {
+	pending := d.log.Len()
+
+	if since := d.since.Load(); d.Degraded() && since != nil {
+		return &CheckResult{
+			Status: "degraded",
+			Error:  fmt.Sprintf("mongo unreachable since %s, %d resolutions queued", since.Format(time.RFC3339), pending),
+		}
+	}
+
+	if pending > 0 {
+		return &CheckResult{Status: "degraded", Error: fmt.Sprintf("%d resolutions waiting to be written", pending)}
+	}
+
+	return &CheckResult{Status: "ok"}
+}
+
+// Queue appends a resolution to the log. The entry isn't served again by this replica from then on.
+func (d *Degradation) Queue(resolution *tools.Resolution) error {
+	if err := d.log.Append(resolution); err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.queued[resolution.Entry.EntryID] = true
+	delete(d.claims, resolution.Entry.EntryID)
+
+	return nil
+}
+
+// Queued tells whether a resolution of the entry is waiting in the log.
+func (d *Degradation) Queued(entryID int) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return d.queued[entryID]
+}
+
+// Available tells whether the entry can be served to owner, it isn't queued or claimed by someone else.
+func (d *Degradation) Available(entryID int, owner string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.queued[entryID] {
+		return false
+	}
+
+	claim, ok := d.claims[entryID]
+
+	return !ok || claim.owner == owner || time.Now().After(claim.expiresAt)
+}
+
+// Claim locks the entry to owner in memory, the same way ClaimLocation does in Mongo.
+func (d *Degradation) Claim(entryID int, owner string, duration time.Duration) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if claim, ok := d.claims[entryID]; ok && claim.owner != owner && time.Now().Before(claim.expiresAt) {
+		return false
+	}
+
+	d.claims[entryID] = &degradedClaim{owner: owner, expiresAt: time.Now().Add(duration)}
+
+	return true
+}
+
+// Release drops the in-memory claim of owner.
+func (d *Degradation) Release(entryID int, owner string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if claim, ok := d.claims[entryID]; ok && claim.owner == owner {
+		delete(d.claims, entryID)
+	}
+}
+
+// check pings Mongo and replays the log once it answers. The replica leaves the degraded mode only when every
+// queued resolution is written, a failing one is retried with the next check.
+func (d *Degradation) check(ctx context.Context) {
+	pingCtx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+	err := d.mongo.Ping(pingCtx)
+	cancel()
+
+	if err != nil {
+		if !d.degraded.Swap(true) {
+			now := time.Now()
+			d.since.Store(&now)
+
+			logrus.Errorf("Mongo is unreachable, serving in degraded mode: %s", err)
+		}
+
+		return
+	}
+
+	if d.log.Len() > 0 {
+		replayed, err := d.log.Replay(func(record json.RawMessage) error {
+			resolution := &tools.Resolution{}
+			if err := json.Unmarshal(record, resolution); err != nil {
+				logrus.Errorf("Dropping unreadable queued resolution: %s", err)
+
+				return nil
+			}
+
+			return d.replay(ctx, resolution)
+		})
+
+		if replayed > 0 {
+			logrus.Infof("Wrote %d queued resolutions", replayed)
+		}
+
+		if err != nil {
+			logrus.Errorf("Couldn't write the queued resolutions: %s", err)
+
+			return
+		}
+	}
+
+	if d.degraded.Swap(false) {
+		logrus.Infoln("Mongo is reachable again, leaving degraded mode")
+
+		d.mu.Lock()
+		d.queued = make(map[int]bool)
+		d.claims = make(map[int]*degradedClaim)
+		d.mu.Unlock()
+	}
+}
+
+// Run checks Mongo every interval until ctx is cancelled. The log is replayed right away, resolutions queued
+// before a restart are written as soon as possible.
+func (d *Degradation) Run(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = defaultDegradedChecks
+	}
+
+	d.check(ctx)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			d.check(ctx)
+		}
+	}
+}
+
+func (d *Degradation) Close() error {
+	return d.log.Close()
+}
+
+// lastKnown keeps the last list read from Mongo, requests fall back to it while Mongo is down.
+type lastKnown[T any] struct {
+	mu    sync.RWMutex
+	value T
+	ok    bool
+}
+
+// get calls fetch and remembers the result. When it fails in degraded mode the remembered one is returned
+// instead, if there is one.
+func (l *lastKnown[T]) get(d *Degradation, fetch func() (T, error)) (T, error) {
+	value, err := fetch()
+	if err == nil {
+		l.mu.Lock()
+		l.value, l.ok = value, true
+		l.mu.Unlock()
+
+		return value, nil
+	}
+
+	if d.Degraded() {
+		l.mu.RLock()
+		defer l.mu.RUnlock()
+
+		if l.ok {
+			return l.value, nil
+		}
+	}
+
+	return value, err
+}
+
+// last returns what was remembered, the zero value when nothing was read yet.
+func (l *lastKnown[T]) last() T {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	return l.value
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+
+	"github.com/YusufOzmen01/veri-kontrol-backend/core/sources"
+	"github.com/YusufOzmen01/veri-kontrol-backend/tools"
+	"github.com/gofiber/fiber/v2"
+)
+
+// Dev are the routes for local development, they are only registered with dev_mode.
+type Dev interface {
+	Seed(c *fiber.Ctx) error
+}
+
+type dev struct {
+	seeder *tools.Seeder
+	cache  sources.Cache
+}
+
+func NewDev(seeder *tools.Seeder, cache sources.Cache) Dev {
+	return &dev{
+		seeder: seeder,
+		cache:  cache,
+	}
+}
+
+// devAccess guards the dev routes. With a dev_token every request needs it in the Dev-Token header, even a local
+// one, behind a reverse proxy on the same machine every request looks local. Without a dev_token only local
+// requests are served.
+func devAccess(token string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if token == "" {
+			if ip := net.ParseIP(c.IP()); ip != nil && ip.IsLoopback() {
+				return c.Next()
+			}
+
+			return forbidden("the dev routes only answer local requests")
+		}
+
+		if subtle.ConstantTimeCompare([]byte(c.Get("Dev-Token")), []byte(token)) != 1 {
+			return unauthorized("a valid Dev-Token is required")
+		}
+
+		return c.Next()
+	}
+}
+
+// Seed generates fake data like cmd/seed, the body is a SeedOptions with 200 entries, 10 users and 50
+// resolutions by default. The feed is dropped from the cache so the entries are served right away.
+func (d *dev) Seed(c *fiber.Ctx) error {
+	opts := &tools.SeedOptions{Locations: 200, Users: 10, Resolutions: 50}
+
+	if len(c.Body()) > 0 {
+		if err := json.Unmarshal(c.Body(), opts); err != nil {
+			return badRequest(err.Error())
+		}
+	}
+
+	for _, count := range []int{opts.Locations, opts.Users, opts.Resolutions} {
+		if count < 0 || count > tools.MaxSeed {
+			return badRequest(fmt.Sprintf("the counts have to be between 0 and %d", tools.MaxSeed))
+		}
+	}
+
+	result, err := d.seeder.Seed(c.Context(), opts)
+	if err != nil {
+		if errors.Is(err, tools.ErrNoFileSource) {
+			return conflict(err.Error())
+		}
+
+		return err
+	}
+
+	tools.InvalidateLocations(c.Context(), d.cache)
+
+	return c.JSON(result)
+}
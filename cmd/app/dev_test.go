@@ -0,0 +1,41 @@
+package main
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestDevAccess(t *testing.T) {
+	tests := []struct {
+		name   string
+		token  string
+		header string
+		want   int
+	}{
+		{"without a dev_token", "", "", fiber.StatusForbidden},
+		{"missing Dev-Token", "secret", "", fiber.StatusUnauthorized},
+		{"wrong Dev-Token", "secret", "guess", fiber.StatusUnauthorized},
+		{"valid Dev-Token", "secret", "secret", fiber.StatusOK},
+	}
+
+	for _, tt := range tests {
+		app := fiber.New(fiber.Config{ErrorHandler: errorHandler})
+		app.Post("/dev/seed", devAccess(tt.token), func(c *fiber.Ctx) error { return c.SendStatus(fiber.StatusOK) })
+
+		req := httptest.NewRequest("POST", "/dev/seed", nil)
+		if tt.header != "" {
+			req.Header.Set("Dev-Token", tt.header)
+		}
+
+		res, err := app.Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if res.StatusCode != tt.want {
+			t.Errorf("%s: status %d, want %d", tt.name, res.StatusCode, tt.want)
+		}
+	}
+}
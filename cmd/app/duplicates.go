@@ -0,0 +1,265 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/YusufOzmen01/veri-kontrol-backend/core/sources"
+	auditRepository "github.com/YusufOzmen01/veri-kontrol-backend/repository/audit"
+	eventsRepository "github.com/YusufOzmen01/veri-kontrol-backend/repository/events"
+	"github.com/YusufOzmen01/veri-kontrol-backend/repository/locations"
+	outboxRepository "github.com/YusufOzmen01/veri-kontrol-backend/repository/outbox"
+	reasonsRepository "github.com/YusufOzmen01/veri-kontrol-backend/repository/reasons"
+	typesRepository "github.com/YusufOzmen01/veri-kontrol-backend/repository/types"
+	"github.com/YusufOzmen01/veri-kontrol-backend/tools"
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type Duplicates interface {
+	GetClusters(c *fiber.Ctx) error
+	ResolveCluster(c *fiber.Ctx) error
+}
+
+type duplicates struct {
+	locations locations.Repository
+	events    eventsRepository.Repository
+	cache     sources.Cache
+	processed *ProcessedIDs
+	reasons   reasonsRepository.Repository
+	types     typesRepository.Repository
+	detector  *tools.DuplicateDetector
+	audit     auditRepository.Repository
+	outbox    outboxRepository.Repository
+	masker    *Masker
+}
+
+type ClustersResponse struct {
+	Count    int              `json:"count"`
+	Clusters []*tools.Cluster `json:"clusters"`
+}
+
+// ResolveClusterBody resolves the canonical entry from the path with Resolution, the members are the entries
+// the moderator reviewed as its duplicates.
+type ResolveClusterBody struct {
+	Resolution *ResolveBody `json:"resolution"`
+	Members    []int        `json:"members"`
+}
+
+const maxClusterMembers = 500
+
+func NewDuplicates(locations locations.Repository, events eventsRepository.Repository, cache sources.Cache, processed *ProcessedIDs, reasons reasonsRepository.Repository, types typesRepository.Repository, detector *tools.DuplicateDetector, auditLog auditRepository.Repository, outboxMessages outboxRepository.Repository, masker *Masker) Duplicates {
+	return &duplicates{
+		locations: locations,
+		events:    events,
+		cache:     cache,
+		processed: processed,
+		reasons:   reasons,
+		types:     types,
+		detector:  detector,
+		audit:     auditLog,
+		outbox:    outboxMessages,
+		masker:    masker,
+	}
+}
+
+// GetClusters groups the unresolved entries by their text. Texts are never fetched here, entries from sources
+// which only list coordinates take part once somebody was served them.
+func (d *duplicates) GetClusters(c *fiber.Ctx) error {
+	eventID, err := eventIDFromQuery(c, d.events)
+	if err != nil {
+		return badRequest(err.Error())
+	}
+
+	raw, err := tools.GetAllLocations(c.Context(), d.cache)
+	if err != nil {
+		return err
+	}
+
+	unresolved := make([]*locations.Location, 0, len(raw))
+	for _, loc := range entriesOfEvent(raw, eventID) {
+		if !d.processed.Contains(loc.EntryID) {
+			unresolved = append(unresolved, loc)
+		}
+	}
+
+	clusters := d.detector.FindClusters(unresolved, func(location *locations.Location) string {
+		if location.OriginalMessage != "" {
+			return location.OriginalMessage
+		}
+
+		if singleData, ok := tools.CachedSingleLocation(d.cache, location.EntryID); ok {
+			return singleData.FullText
+		}
+
+		return ""
+	})
+
+	size := c.QueryInt("limit", defaultPageSize)
+	if size < 1 || size > maxPageSize {
+		size = defaultPageSize
+	}
+
+	response := &ClustersResponse{
+		Count:    len(clusters),
+		Clusters: clusters,
+	}
+
+	if len(clusters) > size {
+		response.Clusters = clusters[:size]
+	}
+
+	response.Clusters = d.masker.Clusters(c, response.Clusters)
+
+	return c.JSON(response)
+}
+
+// ResolveCluster resolves the canonical entry like UpdateEntry does and every member as its duplicate, with
+// the same type and reason. Members which are already resolved or not in the feed are skipped.
+func (d *duplicates) ResolveCluster(c *fiber.Ctx) error {
+	canonicalID, err := c.ParamsInt("entry_id")
+	if err != nil {
+		return badRequest(err.Error())
+	}
+
+	body := &ResolveClusterBody{}
+
+	if err := json.Unmarshal(c.Body(), body); err != nil {
+		return badRequest(err.Error())
+	}
+
+	if body.Resolution == nil {
+		return badRequest("resolution is required")
+	}
+
+	if len(body.Members) == 0 || len(body.Members) > maxClusterMembers {
+		return badRequest(fmt.Sprintf("members must have between 1 and %d entries", maxClusterMembers))
+	}
+
+	reasonList, err := d.reasons.GetReasons(c.Context())
+	if err != nil {
+		return err
+	}
+
+	typeList, err := d.types.GetTypes(c.Context())
+	if err != nil {
+		return err
+	}
+
+	resolution := body.Resolution
+	resolution.ID = canonicalID
+
+	if err := resolution.Validate(reasonList, typeList); err != nil {
+		return validationFailed(err)
+	}
+
+	raw, err := tools.GetAllLocations(c.Context(), d.cache)
+	if err != nil {
+		return err
+	}
+
+	rawLocations := make(map[int]*locations.Location, len(raw))
+	for _, loc := range raw {
+		rawLocations[loc.EntryID] = loc
+	}
+
+	sender := currentUser(c)
+	activeEventID := tools.ActiveEventID(c.Context())
+	resolve := func(entryID, duplicateOf int) *locations.LocationDB {
+		entry := &locations.LocationDB{
+			ID:               primitive.NewObjectIDFromTimestamp(time.Now()),
+			EntryID:          entryID,
+			Sender:           sender,
+			Type:             resolution.LocationType,
+			TypeCode:         resolution.TypeCode(),
+			Location:         make([]float64, 0),
+			Corrected:        resolution.Corrected(),
+			Verified:         true,
+			Status:           locations.StatusResolved,
+			CorrectedAddress: resolution.NewAddress,
+			ParsedAddress:    resolution.ParsedAddress(),
+			Reason:           resolution.Reason,
+			OpenAddress:      resolution.OpenAddress,
+			Apartment:        resolution.Apartment,
+			DuplicateOf:      duplicateOf,
+			EventID:          activeEventID,
+		}
+
+		if loc, ok := rawLocations[entryID]; ok {
+			entry.Location = loc.Loc
+			entry.Epoch = loc.Epoch
+			entry.Source = loc.Source
+			entry.PhoneNumbers = loc.PhoneNumbers
+			if loc.EventID != "" {
+				entry.EventID = loc.EventID
+			}
+			entry.OriginalAddress = fmt.Sprintf("https://www.google.com/maps/?q=%f,%f&ll=%f,%f&z=21", loc.Loc[0], loc.Loc[1], loc.Loc[0], loc.Loc[1])
+		}
+
+		return entry
+	}
+
+	before, _ := d.locations.GetLocation(c.Context(), canonicalID)
+
+	canonical := resolve(canonicalID, 0)
+	if err := d.locations.ReplaceLocation(c.Context(), canonical, sender); err != nil {
+		if errors.Is(err, locations.ErrAlreadyResolved) {
+			return conflict("this location was resolved while it was being updated")
+		}
+
+		return err
+	}
+
+	d.processed.Add(canonicalID)
+
+	recordAudit(c, d.audit, &auditRepository.Event{
+		Action:  auditRepository.ActionUpdate,
+		EntryID: canonicalID,
+		Changes: auditRepository.Diff(before, canonical),
+	})
+	queueResolved(c, d.outbox, canonical)
+
+	response := &BulkResolveResponse{
+		Resolved: make([]int, 0, len(body.Members)),
+		Skipped:  make([]BulkResolveSkip, 0),
+	}
+
+	seen := map[int]bool{canonicalID: true}
+	for _, id := range body.Members {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+
+		if _, ok := rawLocations[id]; !ok {
+			response.Skipped = append(response.Skipped, BulkResolveSkip{ID: id, Reason: "not in the feed"})
+
+			continue
+		}
+
+		entry := resolve(id, canonicalID)
+		if err := d.locations.ResolveLocation(c.Context(), entry); err != nil {
+			if errors.Is(err, locations.ErrAlreadyResolved) {
+				response.Skipped = append(response.Skipped, BulkResolveSkip{ID: id, Reason: "already resolved"})
+
+				continue
+			}
+
+			return err
+		}
+
+		d.processed.Add(id)
+		response.Resolved = append(response.Resolved, id)
+
+		recordAudit(c, d.audit, &auditRepository.Event{
+			Action:  auditRepository.ActionDuplicate,
+			EntryID: id,
+			Changes: auditRepository.Diff(nil, entry),
+		})
+		queueResolved(c, d.outbox, entry)
+	}
+
+	return c.JSON(response)
+}
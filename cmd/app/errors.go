@@ -0,0 +1,123 @@
+package main
+
+import (
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	CodeBadRequest   = "bad_request"
+	CodeUnauthorized = "unauthorized"
+	CodeForbidden    = "forbidden"
+	CodeNotFound     = "not_found"
+	CodeConflict     = "conflict"
+	CodeRateLimited  = "too_many_requests"
+	CodeValidation   = "validation_failed"
+	CodeUnavailable  = "upstream_unavailable"
+	CodeInternal     = "internal_error"
+)
+
+// APIError is returned from handlers and rendered by errorHandler. Any other error is logged and turned into
+// a generic 500 so internal details don't leak to clients.
+type APIError struct {
+	Status  int         `json:"-"`
+	Code    string      `json:"code"`
+	Message string      `json:"message"`
+	Details interface{} `json:"details,omitempty"`
+}
+
+func (e *APIError) Error() string {
+	return e.Message
+}
+
+func newAPIError(status int, code, message string, details ...interface{}) *APIError {
+	apiErr := &APIError{
+		Status:  status,
+		Code:    code,
+		Message: message,
+	}
+
+	if len(details) > 0 {
+		apiErr.Details = details[0]
+	}
+
+	return apiErr
+}
+
+func badRequest(message string, details ...interface{}) *APIError {
+	return newAPIError(fiber.StatusBadRequest, CodeBadRequest, message, details...)
+}
+
+func unauthorized(message string) *APIError {
+	return newAPIError(fiber.StatusUnauthorized, CodeUnauthorized, message)
+}
+
+func forbidden(message string) *APIError {
+	return newAPIError(fiber.StatusForbidden, CodeForbidden, message)
+}
+
+func notFound(message string) *APIError {
+	return newAPIError(fiber.StatusNotFound, CodeNotFound, message)
+}
+
+func conflict(message string, details ...interface{}) *APIError {
+	return newAPIError(fiber.StatusConflict, CodeConflict, message, details...)
+}
+
+func validationFailed(err error) *APIError {
+	return newAPIError(fiber.StatusUnprocessableEntity, CodeValidation, "Some fields are invalid.", err)
+}
+
+func tooManyRequests(message string) *APIError {
+	return newAPIError(fiber.StatusTooManyRequests, CodeRateLimited, message)
+}
+
+func serviceUnavailable(message string) *APIError {
+	return newAPIError(fiber.StatusServiceUnavailable, CodeUnavailable, message)
+}
+
+// codeForStatus is the code of errors which only come with a status, like fiber's own.
+func codeForStatus(status int) string {
+	switch status {
+	case fiber.StatusBadRequest:
+		return CodeBadRequest
+	case fiber.StatusUnauthorized:
+		return CodeUnauthorized
+	case fiber.StatusForbidden:
+		return CodeForbidden
+	case fiber.StatusNotFound:
+		return CodeNotFound
+	case fiber.StatusConflict:
+		return CodeConflict
+	case fiber.StatusUnprocessableEntity:
+		return CodeValidation
+	case fiber.StatusTooManyRequests:
+		return CodeRateLimited
+	}
+
+	return CodeInternal
+}
+
+func errorHandler(c *fiber.Ctx, err error) error {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return c.Status(apiErr.Status).JSON(apiErr)
+	}
+
+	var fiberErr *fiber.Error
+	if errors.As(err, &fiberErr) {
+		return c.Status(fiberErr.Code).JSON(&APIError{
+			Code:    codeForStatus(fiberErr.Code),
+			Message: fiberErr.Message,
+		})
+	}
+
+	logrus.Errorf("%s %s: %s", c.Method(), c.Path(), err)
+
+	return c.Status(fiber.StatusInternalServerError).JSON(&APIError{
+		Code:    CodeInternal,
+		Message: "internal server error",
+	})
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	auditRepository "github.com/YusufOzmen01/veri-kontrol-backend/repository/audit"
+	eventsRepository "github.com/YusufOzmen01/veri-kontrol-backend/repository/events"
+	"github.com/YusufOzmen01/veri-kontrol-backend/repository/locations"
+	"github.com/gofiber/fiber/v2"
+)
+
+type Events interface {
+	GetEvents(c *fiber.Ctx) error
+	AddEvent(c *fiber.Ctx) error
+	UpdateEvent(c *fiber.Ctx) error
+	DeleteEvent(c *fiber.Ctx) error
+	ActivateEvent(c *fiber.Ctx) error
+}
+
+type events struct {
+	events    eventsRepository.Repository
+	locations locations.Repository
+	audit     auditRepository.Repository
+}
+
+func NewEvents(eventRepository eventsRepository.Repository, locations locations.Repository, auditLog auditRepository.Repository) Events {
+	return &events{
+		events:    eventRepository,
+		locations: locations,
+		audit:     auditLog,
+	}
+}
+
+// eventIDFromQuery is the event_id query parameter, or the active event so clients which don't know about
+// events keep seeing the current disaster.
+func eventIDFromQuery(c *fiber.Ctx, eventRepository eventsRepository.Repository) (string, error) {
+	if eventID := c.Query("event_id"); eventID != "" {
+		if _, err := eventRepository.GetEvent(c.Context(), eventID); err != nil {
+			return "", fmt.Errorf("unknown event_id")
+		}
+
+		return eventID, nil
+	}
+
+	event, err := eventRepository.GetActiveEvent(c.Context())
+	if err != nil {
+		// Without an active event nothing is left out.
+		if errors.Is(err, eventsRepository.ErrNoActiveEvent) {
+			return "", nil
+		}
+
+		return "", err
+	}
+
+	return event.ID, nil
+}
+
+// entriesOfEvent keeps the raw entries of the event, all of them when eventID is empty. The feed is tagged with
+// the active event, asking for an earlier one leaves nothing.
+func entriesOfEvent(raw []*locations.Location, eventID string) []*locations.Location {
+	if eventID == "" {
+		return raw
+	}
+
+	entries := make([]*locations.Location, 0, len(raw))
+	for _, loc := range raw {
+		if loc.EventID == eventID {
+			entries = append(entries, loc)
+		}
+	}
+
+	return entries
+}
+
+func (e *events) GetEvents(c *fiber.Ctx) error {
+	list, err := e.events.GetEvents(c.Context())
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(list)
+}
+
+func (e *events) AddEvent(c *fiber.Ctx) error {
+	event := &eventsRepository.Event{}
+
+	if err := json.Unmarshal(c.Body(), event); err != nil {
+		return badRequest(err.Error())
+	}
+
+	if err := event.Validate(); err != nil {
+		return badRequest(err.Error())
+	}
+
+	if err := e.events.AddEvent(c.Context(), event); err != nil {
+		if errors.Is(err, eventsRepository.ErrEventExists) {
+			return conflict(err.Error())
+		}
+
+		return err
+	}
+
+	recordAudit(c, e.audit, &auditRepository.Event{
+		Action:  auditRepository.ActionEventAdd,
+		Target:  event.ID,
+		Changes: auditRepository.Diff(nil, event),
+	})
+
+	return c.JSON(event)
+}
+
+func (e *events) UpdateEvent(c *fiber.Ctx) error {
+	event := &eventsRepository.Event{}
+
+	if err := json.Unmarshal(c.Body(), event); err != nil {
+		return badRequest(err.Error())
+	}
+
+	event.ID = c.Params("event_id")
+
+	if err := event.Validate(); err != nil {
+		return badRequest(err.Error())
+	}
+
+	before, err := e.events.GetEvent(c.Context(), event.ID)
+	if err != nil {
+		return notFound(err.Error())
+	}
+
+	if err := e.events.UpdateEvent(c.Context(), event); err != nil {
+		return err
+	}
+
+	event.Active = before.Active
+	event.CreatedAt = before.CreatedAt
+
+	recordAudit(c, e.audit, &auditRepository.Event{
+		Action:  auditRepository.ActionEventUpdate,
+		Target:  event.ID,
+		Changes: auditRepository.Diff(before, event),
+	})
+
+	return c.JSON(event)
+}
+
+// DeleteEvent only removes events nothing was resolved for, the active one can't be deleted either.
+func (e *events) DeleteEvent(c *fiber.Ctx) error {
+	eventID := c.Params("event_id")
+
+	before, err := e.events.GetEvent(c.Context(), eventID)
+	if err != nil {
+		return notFound(err.Error())
+	}
+
+	if before.Active {
+		return conflict("the active event can't be deleted, activate another one first")
+	}
+
+	count, err := e.locations.CountLocations(c.Context(), &locations.LocationFilter{EventID: eventID})
+	if err != nil {
+		return err
+	}
+
+	if count > 0 {
+		return conflict("the event has resolutions and can't be deleted")
+	}
+
+	if err := e.events.DeleteEvent(c.Context(), eventID); err != nil {
+		return err
+	}
+
+	recordAudit(c, e.audit, &auditRepository.Event{
+		Action:  auditRepository.ActionEventDelete,
+		Target:  eventID,
+		Changes: auditRepository.Diff(before, nil),
+	})
+
+	return c.SendString("Successfully deleted!")
+}
+
+// ActivateEvent switches the feed to the event, entries are tagged with it from the next refresh on.
+func (e *events) ActivateEvent(c *fiber.Ctx) error {
+	eventID := c.Params("event_id")
+
+	before, err := e.events.GetActiveEvent(c.Context())
+	if err != nil && !errors.Is(err, eventsRepository.ErrNoActiveEvent) {
+		return err
+	}
+
+	if err := e.events.Activate(c.Context(), eventID); err != nil {
+		if errors.Is(err, eventsRepository.ErrEventNotFound) {
+			return notFound(err.Error())
+		}
+
+		if errors.Is(err, eventsRepository.ErrActivationConflict) {
+			return conflict(err.Error())
+		}
+
+		return err
+	}
+
+	previous := ""
+	if before != nil {
+		previous = before.ID
+	}
+
+	recordAudit(c, e.audit, &auditRepository.Event{
+		Action: auditRepository.ActionEventActivate,
+		Target: eventID,
+		Changes: []*auditRepository.Change{{
+			Field:  "active_event",
+			Before: previous,
+			After:  eventID,
+		}},
+	})
+
+	return c.SendString("Successfully activated!")
+}
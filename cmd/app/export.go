@@ -0,0 +1,121 @@
+package main
+
+import (
+	"bufio"
+	"context"
+
+	"github.com/YusufOzmen01/veri-kontrol-backend/core/redact"
+	citiesRepository "github.com/YusufOzmen01/veri-kontrol-backend/repository/cities"
+	eventsRepository "github.com/YusufOzmen01/veri-kontrol-backend/repository/events"
+	"github.com/YusufOzmen01/veri-kontrol-backend/repository/locations"
+	usersRepository "github.com/YusufOzmen01/veri-kontrol-backend/repository/users"
+	"github.com/YusufOzmen01/veri-kontrol-backend/tools"
+	"github.com/gofiber/fiber/v2"
+	"github.com/sirupsen/logrus"
+)
+
+type Export interface {
+	GeoJSON(c *fiber.Ctx) error
+	CSV(c *fiber.Ctx) error
+	KML(c *fiber.Ctx) error
+}
+
+type export struct {
+	locations locations.Repository
+	cities    citiesRepository.Repository
+	events    eventsRepository.Repository
+}
+
+// redactorFor leaves personal data in exports only for users with the export:pii scope.
+func redactorFor(c *fiber.Ctx) redact.Redactor {
+	user := currentUser(c)
+
+	return redact.Redactor{ShowPII: user != nil && user.HasScope(usersRepository.ScopeExportPII)}
+}
+
+func NewExport(locations locations.Repository, cities citiesRepository.Repository, events eventsRepository.Repository) Export {
+	return &export{
+		locations: locations,
+		cities:    cities,
+		events:    events,
+	}
+}
+
+func (e *export) GeoJSON(c *fiber.Ctx) error {
+	filter, err := locationFilterFromQuery(c, e.cities, e.events)
+	if err != nil {
+		return badRequest(err.Error())
+	}
+
+	redactor := redactorFor(c)
+
+	c.Set(fiber.HeaderContentType, "application/geo+json")
+	c.Set(fiber.HeaderContentDisposition, `attachment; filename="locations.geojson"`)
+
+	// The writer runs after the handler returns, so it can't rely on the request context.
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		if err := tools.WriteGeoJSON(context.Background(), w, e.locations, filter, redactor); err != nil {
+			logrus.Errorf("GeoJSON export failed: %s", err)
+		}
+
+		_ = w.Flush()
+	})
+
+	return nil
+}
+
+// CSV only exports resolved entries unless another status is asked for.
+func (e *export) CSV(c *fiber.Ctx) error {
+	filter, err := locationFilterFromQuery(c, e.cities, e.events)
+	if err != nil {
+		return badRequest(err.Error())
+	}
+
+	if filter.Status == "" {
+		filter.Status = locations.StatusResolved
+	}
+
+	columns, err := tools.ParseCSVColumns(c.Query("fields"))
+	if err != nil {
+		return badRequest(err.Error())
+	}
+
+	redactor := redactorFor(c)
+
+	c.Set(fiber.HeaderContentType, "text/csv; charset=utf-8")
+	c.Set(fiber.HeaderContentDisposition, `attachment; filename="locations.csv"`)
+
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		if err := tools.WriteCSV(context.Background(), w, e.locations, filter, columns, redactor); err != nil {
+			logrus.Errorf("CSV export failed: %s", err)
+		}
+
+		_ = w.Flush()
+	})
+
+	return nil
+}
+
+// KML only exports resolutions.
+func (e *export) KML(c *fiber.Ctx) error {
+	filter, err := locationFilterFromQuery(c, e.cities, e.events)
+	if err != nil {
+		return badRequest(err.Error())
+	}
+
+	filter.Status = locations.StatusResolved
+	redactor := redactorFor(c)
+
+	c.Set(fiber.HeaderContentType, "application/vnd.google-earth.kml+xml")
+	c.Set(fiber.HeaderContentDisposition, `attachment; filename="locations.kml"`)
+
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		if err := tools.WriteKML(context.Background(), w, e.locations, filter, redactor); err != nil {
+			logrus.Errorf("KML export failed: %s", err)
+		}
+
+		_ = w.Flush()
+	})
+
+	return nil
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+
+	"github.com/YusufOzmen01/veri-kontrol-backend/core/redact"
+	citiesRepository "github.com/YusufOzmen01/veri-kontrol-backend/repository/cities"
+	eventsRepository "github.com/YusufOzmen01/veri-kontrol-backend/repository/events"
+	"github.com/YusufOzmen01/veri-kontrol-backend/repository/locations"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/websocket/v2"
+	"github.com/sirupsen/logrus"
+)
+
+type Feed interface {
+	Upgrade(c *fiber.Ctx) error
+	Resolved(conn *websocket.Conn)
+}
+
+type feed struct {
+	locations locations.Repository
+	cities    citiesRepository.Repository
+	events    eventsRepository.Repository
+}
+
+func NewFeed(locations locations.Repository, cities citiesRepository.Repository, events eventsRepository.Repository) Feed {
+	return &feed{
+		locations: locations,
+		cities:    cities,
+		events:    events,
+	}
+}
+
+// Upgrade resolves the optional city and event filters before switching protocols, so a bad city_id or
+// event_id still gets a proper error.
+func (f *feed) Upgrade(c *fiber.Ctx) error {
+	if !websocket.IsWebSocketUpgrade(c) {
+		return fiber.ErrUpgradeRequired
+	}
+
+	if cityID := c.QueryInt("city_id"); cityID > 0 {
+		city, err := f.cities.GetCity(c.Context(), cityID)
+		if err != nil {
+			return badRequest("unknown city_id")
+		}
+
+		c.Locals("city", city)
+	}
+
+	eventID, err := eventIDFromQuery(c, f.events)
+	if err != nil {
+		return badRequest(err.Error())
+	}
+
+	c.Locals("event_id", eventID)
+	c.Locals("redactor", redactorFor(c))
+
+	return c.Next()
+}
+
+// Resolved pushes every new resolution to the connected client until it disconnects.
+func (f *feed) Resolved(conn *websocket.Conn) {
+	city, _ := conn.Locals("city").(*citiesRepository.City)
+	eventID, _ := conn.Locals("event_id").(string)
+	redactor, _ := conn.Locals("redactor").(redact.Redactor)
+
+	resolutions, unsubscribe := f.locations.Subscribe()
+	defer unsubscribe()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// The client doesn't send anything, reading only notices when it goes away.
+	go func() {
+		defer cancel()
+
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case location, ok := <-resolutions:
+			if !ok {
+				return
+			}
+
+			if location.IsPendingReview() || location.Type == locations.TypeSpam {
+				continue
+			}
+
+			if eventID != "" && location.EventID != eventID {
+				continue
+			}
+
+			if city != nil && (len(location.Location) < 2 || !city.Contains(location.Location[0], location.Location[1])) {
+				continue
+			}
+
+			if err := conn.WriteJSON(toPublicLocation(location, redactor)); err != nil {
+				logrus.Debugln(err)
+
+				return
+			}
+		}
+	}
+}
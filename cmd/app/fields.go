@@ -0,0 +1,94 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+
+	"github.com/YusufOzmen01/veri-kontrol-backend/validation"
+	"github.com/gofiber/fiber/v2"
+)
+
+// jsonFields lists the names v is encoded with, the allowed values of ?fields=. Embedded structs are flattened
+// like encoding/json does.
+func jsonFields(v interface{}) []string {
+	return typeFields(reflect.TypeOf(v))
+}
+
+func typeFields(t reflect.Type) []string {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	names := make([]string, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if field.Anonymous && name == "" {
+			names = append(names, typeFields(field.Type)...)
+
+			continue
+		}
+
+		if name == "" || name == "-" {
+			continue
+		}
+
+		names = append(names, name)
+	}
+
+	return names
+}
+
+// fieldsFromQuery reads the comma separated ?fields= list, nil means every field. Unknown names are rejected,
+// a typo would otherwise quietly return empty objects.
+func fieldsFromQuery(c *fiber.Ctx, v interface{}) ([]string, error) {
+	query := c.Query("fields")
+	if query == "" {
+		return nil, nil
+	}
+
+	allowed := jsonFields(v)
+	fields := make([]string, 0)
+
+	val := validation.New()
+	for _, field := range strings.Split(query, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+
+		if canonical, ok := val.OneOf("fields", field, allowed); ok {
+			fields = append(fields, canonical)
+		}
+	}
+
+	if err := val.Err(); err != nil {
+		return nil, validationFailed(err)
+	}
+
+	return fields, nil
+}
+
+// sparse keeps only the given fields of v's JSON encoding, so mobile clients don't download what they don't show.
+func sparse(v interface{}, fields []string) (map[string]json.RawMessage, error) {
+	encoded, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	all := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(encoded, &all); err != nil {
+		return nil, err
+	}
+
+	picked := make(map[string]json.RawMessage, len(fields))
+	for _, field := range fields {
+		if value, ok := all[field]; ok {
+			picked[field] = value
+		}
+	}
+
+	return picked, nil
+}
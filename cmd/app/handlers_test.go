@@ -0,0 +1,530 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/YusufOzmen01/veri-kontrol-backend/core/queue"
+	"github.com/YusufOzmen01/veri-kontrol-backend/core/ratelimit"
+	"github.com/YusufOzmen01/veri-kontrol-backend/core/sources"
+	"github.com/YusufOzmen01/veri-kontrol-backend/core/textfilter"
+	"github.com/YusufOzmen01/veri-kontrol-backend/core/tokens"
+	"github.com/YusufOzmen01/veri-kontrol-backend/core/wal"
+	auditRepository "github.com/YusufOzmen01/veri-kontrol-backend/repository/audit"
+	citiesRepository "github.com/YusufOzmen01/veri-kontrol-backend/repository/cities"
+	locationsRepository "github.com/YusufOzmen01/veri-kontrol-backend/repository/locations"
+	outboxRepository "github.com/YusufOzmen01/veri-kontrol-backend/repository/outbox"
+	reasonsRepository "github.com/YusufOzmen01/veri-kontrol-backend/repository/reasons"
+	typesRepository "github.com/YusufOzmen01/veri-kontrol-backend/repository/types"
+	usersRepository "github.com/YusufOzmen01/veri-kontrol-backend/repository/users"
+	"github.com/YusufOzmen01/veri-kontrol-backend/tools"
+	"github.com/gofiber/fiber/v2"
+	"github.com/valyala/fasthttp"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// testApp is the part of the API which runs on the locations and users, served from the memory repositories
+// with the routes registered the way main does it.
+type testApp struct {
+	app       *fiber.App
+	locations locationsRepository.Repository
+	users     usersRepository.Repository
+	audit     *recordedAudit
+	outbox    *recordedOutbox
+	activity  *activity
+}
+
+// The repositories without a memory implementation only get what serving and resolving entries uses.
+type (
+	fixedReasons struct{ reasonsRepository.Repository }
+	fixedTypes   struct{ typesRepository.Repository }
+	noCities     struct{ citiesRepository.Repository }
+
+	recordedAudit struct {
+		auditRepository.Repository
+		mu     sync.Mutex
+		events []*auditRepository.Event
+	}
+
+	recordedOutbox struct {
+		outboxRepository.Repository
+		mu       sync.Mutex
+		messages []*outboxRepository.Message
+	}
+
+	// withoutTransactions is a standalone server, the writes of a resolution are done one after the other.
+	withoutTransactions struct{}
+)
+
+func (fixedReasons) GetReasons(ctx context.Context) ([]*reasonsRepository.Reason, error) {
+	return []*reasonsRepository.Reason{
+		{Code: locationsRepository.ReasonNoError, Corrected: true},
+		{Code: "Adres Hatalı"},
+	}, nil
+}
+
+func (fixedTypes) GetTypes(ctx context.Context) ([]*typesRepository.LocationType, error) {
+	return []*typesRepository.LocationType{
+		{ID: locationsRepository.TypeWreckage, Code: "enkaz", Selectable: true},
+	}, nil
+}
+
+func (noCities) GetCities(ctx context.Context) ([]*citiesRepository.City, error) {
+	return nil, nil
+}
+
+func (a *recordedAudit) Record(ctx context.Context, event *auditRepository.Event) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.events = append(a.events, event)
+
+	return nil
+}
+
+func (o *recordedOutbox) Add(ctx context.Context, messages ...*outboxRepository.Message) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.messages = append(o.messages, messages...)
+
+	return nil
+}
+
+func (withoutTransactions) SupportsTransactions(ctx context.Context) bool {
+	return false
+}
+
+func (withoutTransactions) Transaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	return fn(ctx)
+}
+
+// feedSource is the upstream feed, newTestApp reads whatever feed is set when the first request needs it.
+type feedSource struct {
+	locations []*locationsRepository.Location
+}
+
+func (s *feedSource) Name() string {
+	return "test"
+}
+
+func (s *feedSource) Fetch(ctx context.Context) ([]*locationsRepository.Location, error) {
+	locs := make([]*locationsRepository.Location, 0, len(s.locations))
+	for _, loc := range s.locations {
+		copied := *loc
+		locs = append(locs, &copied)
+	}
+
+	return locs, nil
+}
+
+func (s *feedSource) FetchOne(ctx context.Context, entryID int) (*tools.SingleResponse, error) {
+	for _, loc := range s.locations {
+		if loc.EntryID == entryID {
+			return &tools.SingleResponse{FullText: loc.OriginalMessage}, nil
+		}
+	}
+
+	return nil, errors.New("entry not found")
+}
+
+func newTestApp(t *testing.T) *testApp {
+	t.Helper()
+
+	degradation, err := NewDegradation(nil, filepath.Join(t.TempDir(), "resolutions.wal"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	journal, err := wal.OpenJournal(filepath.Join(t.TempDir(), "resolutions.journal"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { journal.Close() })
+
+	cache := sources.NewCache(1<<20, 1e4, 64)
+	t.Cleanup(func() { cache.Close() })
+
+	ta := &testApp{
+		app:       fiber.New(fiber.Config{ErrorHandler: errorHandler}),
+		locations: locationsRepository.NewMemoryRepository(),
+		users:     usersRepository.NewMemoryRepository(),
+		audit:     &recordedAudit{},
+		outbox:    &recordedOutbox{},
+	}
+
+	environment := defaultEnvironment()
+	textFilter := textfilter.New(nil)
+	masker := NewMasker(textFilter)
+	noLimit := func(c *fiber.Ctx) error { return c.Next() }
+
+	auth := NewAuth(ta.users, degradation, tokens.NewManager("test-secret", time.Hour), ratelimit.NewLockout(5, time.Minute))
+	resolutions := NewResolutions(ta.locations, masker)
+	ta.activity = NewActivity(ta.users, ta.locations).(*activity)
+
+	ta.app.Use(ta.activity.Track)
+
+	authG := ta.app.Group("/auth")
+
+	authG.Post("/login", auth.Login)
+	authG.Post("/refresh", auth.Refresh)
+	authG.Post("/logout", auth.Logout)
+
+	meG := ta.app.Group("/me", auth.RequirePerm(usersRepository.PermSubmit))
+
+	meG.Get("/resolutions", resolutions.GetMyResolutions)
+
+	adminG := ta.app.Group("/admin", auth.RequirePerm(usersRepository.PermModerator), auth.RequireCurrentKey)
+	usersG := adminG.Group("/users", auth.RequireScope(usersRepository.ScopeUsersManage))
+
+	usersG.Get("/active", ta.activity.GetActiveUsers)
+
+	registerServing(ta.app, auth, noLimit, nil, &Serving{
+		Environment:   environment,
+		Locations:     ta.locations,
+		Users:         ta.users,
+		Cities:        noCities{},
+		Reasons:       fixedReasons{},
+		Types:         fixedTypes{},
+		Audit:         ta.audit,
+		Outbox:        ta.outbox,
+		Transactions:  withoutTransactions{},
+		Cache:         cache,
+		Degradation:   degradation,
+		Processed:     NewProcessedIDs(make([]int, 0)),
+		Duplicates:    tools.NewDuplicateDetector(ta.locations, environment.DedupThreshold),
+		Masker:        masker,
+		TextFilter:    textFilter,
+		Journal:       journal,
+		Strategy:      queue.StrategyRandom,
+		ClaimDuration: locationsRepository.DefaultClaimDuration,
+	})
+
+	return ta
+}
+
+func (ta *testApp) addUser(t *testing.T, name string, permLevel int) (*usersRepository.User, string) {
+	t.Helper()
+
+	user, authKey, err := ta.users.AddUser(context.Background(), name, "", permLevel, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return user, authKey
+}
+
+// do sends the request and decodes a JSON body into out when it is given.
+func (ta *testApp) do(t *testing.T, method, path string, body interface{}, headers map[string]string, out interface{}) int {
+	t.Helper()
+
+	var reader io.Reader
+	if body != nil {
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		reader = strings.NewReader(string(encoded))
+	}
+
+	req := httptest.NewRequest(method, path, reader)
+	req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := ta.app.Test(req, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if out != nil && resp.StatusCode < 300 {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			t.Fatalf("%s %s: %s", method, path, err)
+		}
+	}
+
+	return resp.StatusCode
+}
+
+func (ta *testApp) login(t *testing.T, authKey string) *TokenResponse {
+	t.Helper()
+
+	tokens := &TokenResponse{}
+	if status := ta.do(t, "POST", "/auth/login", &LoginBody{AuthKey: authKey}, nil, tokens); status != http.StatusOK {
+		t.Fatalf("POST /auth/login = %d, want 200", status)
+	}
+
+	return tokens
+}
+
+func bearer(tokens *TokenResponse) map[string]string {
+	return map[string]string{fiber.HeaderAuthorization: "Bearer " + tokens.AccessToken}
+}
+
+func TestLoginAndRefresh(t *testing.T) {
+	ta := newTestApp(t)
+	_, authKey := ta.addUser(t, "volunteer", usersRepository.PermSubmit)
+
+	if status := ta.do(t, "POST", "/auth/login", &LoginBody{AuthKey: "unknown"}, nil, nil); status != http.StatusUnauthorized {
+		t.Fatalf("login with an unknown key = %d, want 401", status)
+	}
+
+	tokens := ta.login(t, authKey)
+	if tokens.AccessToken == "" || tokens.RefreshToken == "" {
+		t.Fatalf("login returned %+v", tokens)
+	}
+
+	refreshed := &TokenResponse{}
+	if status := ta.do(t, "POST", "/auth/refresh", &RefreshBody{RefreshToken: tokens.RefreshToken}, nil, refreshed); status != http.StatusOK {
+		t.Fatalf("refresh = %d, want 200", status)
+	}
+
+	if refreshed.RefreshToken == tokens.RefreshToken {
+		t.Fatal("refresh returned the same refresh token")
+	}
+
+	// Refresh tokens are single use.
+	if status := ta.do(t, "POST", "/auth/refresh", &RefreshBody{RefreshToken: tokens.RefreshToken}, nil, nil); status != http.StatusUnauthorized {
+		t.Fatalf("refresh with a used token = %d, want 401", status)
+	}
+
+	if status := ta.do(t, "POST", "/auth/logout", &RefreshBody{RefreshToken: refreshed.RefreshToken}, nil, nil); status != http.StatusOK {
+		t.Fatalf("logout = %d, want 200", status)
+	}
+
+	if status := ta.do(t, "POST", "/auth/refresh", &RefreshBody{RefreshToken: refreshed.RefreshToken}, nil, nil); status != http.StatusUnauthorized {
+		t.Fatalf("refresh after logout = %d, want 401", status)
+	}
+}
+
+func TestMyResolutions(t *testing.T) {
+	ta := newTestApp(t)
+	volunteer, authKey := ta.addUser(t, "volunteer", usersRepository.PermSubmit)
+	other, _ := ta.addUser(t, "other", usersRepository.PermSubmit)
+
+	for i, sender := range []*usersRepository.User{volunteer, volunteer, other} {
+		if err := ta.locations.ResolveLocation(context.Background(), &locationsRepository.LocationDB{
+			ID:       primitive.NewObjectID(),
+			EntryID:  i + 1,
+			Sender:   sender,
+			Location: []float64{37.5753, 36.9228},
+			Status:   locationsRepository.StatusResolved,
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	headers := bearer(ta.login(t, authKey))
+
+	response := &MyResolutionsResponse{}
+	if status := ta.do(t, "GET", "/me/resolutions", nil, headers, response); status != http.StatusOK {
+		t.Fatalf("GET /me/resolutions = %d, want 200", status)
+	}
+
+	if response.Count != 2 || len(response.Resolutions) != 2 {
+		t.Fatalf("GET /me/resolutions returned %d of %d resolutions, want 2", len(response.Resolutions), response.Count)
+	}
+
+	for _, resolution := range response.Resolutions {
+		if resolution.Status != locationsRepository.ResolutionApproved || resolution.Location.Sender.ID != volunteer.ID {
+			t.Fatalf("GET /me/resolutions returned entry %d with status %s", resolution.Location.EntryID, resolution.Status)
+		}
+	}
+
+	if status := ta.do(t, "GET", "/me/resolutions?status=bogus", nil, headers, nil); status != http.StatusUnprocessableEntity {
+		t.Fatalf("GET /me/resolutions?status=bogus = %d, want 422", status)
+	}
+
+	if status := ta.do(t, "GET", "/me/resolutions", nil, nil, nil); status != http.StatusUnauthorized {
+		t.Fatalf("GET /me/resolutions without a user = %d, want 401", status)
+	}
+}
+
+func TestPermissions(t *testing.T) {
+	ta := newTestApp(t)
+	_, readOnlyKey := ta.addUser(t, "consumer", usersRepository.PermReadOnly)
+	_, volunteerKey := ta.addUser(t, "volunteer", usersRepository.PermSubmit)
+
+	if status := ta.do(t, "GET", "/me/resolutions", nil, map[string]string{"Auth-Key": readOnlyKey}, nil); status != http.StatusForbidden {
+		t.Fatalf("read only user on /me/resolutions = %d, want 403", status)
+	}
+
+	if status := ta.do(t, "GET", "/admin/users/active", nil, bearer(ta.login(t, volunteerKey)), nil); status != http.StatusForbidden {
+		t.Fatalf("volunteer on /admin/users/active = %d, want 403", status)
+	}
+
+	// Scopes don't let anyone below moderators into /admin.
+	_, scopedKey, err := ta.users.AddUser(context.Background(), "scoped", "", usersRepository.PermSubmit, []string{usersRepository.ScopeUsersManage})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if status := ta.do(t, "GET", "/admin/users/active", nil, bearer(ta.login(t, scopedKey)), nil); status != http.StatusForbidden {
+		t.Fatalf("volunteer with users:manage on /admin/users/active = %d, want 403", status)
+	}
+}
+
+func TestActiveUsers(t *testing.T) {
+	ta := newTestApp(t)
+	volunteer, volunteerKey := ta.addUser(t, "volunteer", usersRepository.PermSubmit)
+	_, adminKey := ta.addUser(t, "admin", usersRepository.PermAdmin)
+
+	if status := ta.do(t, "GET", "/me/resolutions", nil, bearer(ta.login(t, volunteerKey)), nil); status != http.StatusOK {
+		t.Fatalf("GET /me/resolutions = %d, want 200", status)
+	}
+
+	if _, err := ta.locations.ClaimLocation(context.Background(), 42, volunteer.ID.Hex(), time.Minute, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	ta.activity.flush(context.Background())
+
+	response := &ActiveUsersResponse{}
+	if status := ta.do(t, "GET", "/admin/users/active", nil, bearer(ta.login(t, adminKey)), response); status != http.StatusOK {
+		t.Fatalf("GET /admin/users/active = %d, want 200", status)
+	}
+
+	var found *ActiveUser
+	for _, user := range response.Users {
+		if user.ID == volunteer.ID {
+			found = user
+		}
+	}
+
+	if found == nil {
+		t.Fatalf("GET /admin/users/active didn't list the volunteer: %+v", response.Users)
+	}
+
+	if found.Claims != 1 || found.LastSeen.IsZero() {
+		t.Fatalf("volunteer is listed with %d claims, last seen %s", found.Claims, found.LastSeen)
+	}
+}
+
+func TestCheckGrant(t *testing.T) {
+	app := fiber.New()
+
+	c := app.AcquireCtx(&fasthttp.RequestCtx{})
+	defer app.ReleaseCtx(c)
+
+	// A moderator who manages users but can't read PII.
+	c.Locals("user", &usersRepository.User{
+		PermLevel: usersRepository.PermModerator,
+		Scopes:    []string{usersRepository.ScopeEntriesRead, usersRepository.ScopeUsersManage},
+	})
+
+	tests := []struct {
+		name      string
+		permLevel int
+		had       []string
+		scopes    []string
+		allowed   bool
+	}{
+		{"volunteer", usersRepository.PermSubmit, nil, []string{}, true},
+		{"own level", usersRepository.PermModerator, nil, []string{usersRepository.ScopeEntriesRead}, true},
+		{"admin", usersRepository.PermAdmin, nil, []string{}, false},
+		{"scope the caller lacks", usersRepository.PermSubmit, nil, []string{usersRepository.ScopePIIRead}, false},
+		{"scope the account had", usersRepository.PermModerator, []string{usersRepository.ScopePIIRead}, []string{usersRepository.ScopePIIRead}, true},
+	}
+
+	for _, tt := range tests {
+		if err := checkGrant(c, tt.permLevel, tt.had, tt.scopes); (err == nil) != tt.allowed {
+			t.Errorf("%s: checkGrant() = %v, allowed %t", tt.name, err, tt.allowed)
+		}
+	}
+}
+
+func TestAuthLockout(t *testing.T) {
+	ta := newTestApp(t)
+	_, knownKey := ta.addUser(t, "known", usersRepository.PermSubmit)
+	_, newKey := ta.addUser(t, "new", usersRepository.PermSubmit)
+
+	get := func(authKey string) int {
+		return ta.do(t, "GET", "/me/resolutions", nil, map[string]string{"Auth-Key": authKey}, nil)
+	}
+
+	if status := get(knownKey); status != http.StatusOK {
+		t.Fatalf("valid key = %d, want 200", status)
+	}
+
+	// Successes in between don't give the IP more guesses.
+	for i := 0; i < 5; i++ {
+		if status := get("wrong-key-" + strconv.Itoa(i)); status != http.StatusUnauthorized {
+			t.Fatalf("wrong key %d = %d, want 401", i, status)
+		}
+
+		if status := get(knownKey); status != http.StatusOK {
+			t.Fatalf("valid key after %d wrong ones = %d, want 200", i+1, status)
+		}
+	}
+
+	if status := get("wrong-key-5"); status != http.StatusTooManyRequests {
+		t.Fatalf("wrong key after the lockout = %d, want 429", status)
+	}
+
+	if status := get(newKey); status != http.StatusTooManyRequests {
+		t.Fatalf("valid key never used before from the locked out IP = %d, want 429", status)
+	}
+}
+
+func TestReplacedKey(t *testing.T) {
+	ta := newTestApp(t)
+	admin, oldKey := ta.addUser(t, "admin", usersRepository.PermAdmin)
+
+	newKey, _, err := ta.users.RotateAuthKey(context.Background(), admin.ID, time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The old key still works for ordinary requests during the grace period.
+	if status := ta.do(t, "GET", "/me/resolutions", nil, map[string]string{"Auth-Key": oldKey}, nil); status != http.StatusOK {
+		t.Fatalf("GET /me/resolutions with the replaced key = %d, want 200", status)
+	}
+
+	if status := ta.do(t, "GET", "/admin/users/active", nil, map[string]string{"Auth-Key": oldKey}, nil); status != http.StatusForbidden {
+		t.Fatalf("GET /admin/users/active with the replaced key = %d, want 403", status)
+	}
+
+	if status := ta.do(t, "POST", "/auth/login", &LoginBody{AuthKey: oldKey}, nil, nil); status != http.StatusForbidden {
+		t.Fatalf("POST /auth/login with the replaced key = %d, want 403", status)
+	}
+
+	if status := ta.do(t, "GET", "/admin/users/active", nil, map[string]string{"Auth-Key": newKey}, nil); status != http.StatusOK {
+		t.Fatalf("GET /admin/users/active with the new key = %d, want 200", status)
+	}
+}
+
+func TestKnownUsers(t *testing.T) {
+	a := NewAuth(usersRepository.NewMemoryRepository(), nil, tokens.NewManager("test-secret", time.Hour), ratelimit.NewLockout(5, time.Minute)).(*auth)
+
+	alice := &usersRepository.User{ID: primitive.NewObjectID(), Name: "alice"}
+	bob := &usersRepository.User{ID: primitive.NewObjectID(), Name: "bob"}
+
+	a.known.Store(uint32(1), &knownUser{user: alice, checkedAt: time.Now()})
+	a.known.Store(uint32(2), &knownUser{user: alice, checkedAt: time.Now()})
+	a.known.Store(uint32(3), &knownUser{user: bob, checkedAt: time.Now().Add(-knownKeyTTL - time.Minute)})
+
+	if a.knownUser(3) != nil {
+		t.Error("knownUser() of a key which wasn't checked for longer than knownKeyTTL")
+	}
+
+	a.Forget(alice.ID)
+
+	for _, keyHash := range []uint32{1, 2, 3} {
+		if _, ok := a.known.Load(keyHash); ok {
+			t.Errorf("key %d is still remembered", keyHash)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/YusufOzmen01/veri-kontrol-backend/core/sources"
+	"github.com/YusufOzmen01/veri-kontrol-backend/tools"
+	"github.com/gofiber/fiber/v2"
+)
+
+const healthCheckTimeout = 3 * time.Second
+
+type Health interface {
+	Healthz(c *fiber.Ctx) error
+	Readyz(c *fiber.Ctx) error
+	SetReady(ready bool)
+}
+
+type health struct {
+	mongo       sources.MongoClient
+	cache       sources.Cache
+	degradation *Degradation
+	ready       atomic.Bool
+}
+
+type CheckResult struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+type ReadinessResponse struct {
+	Status string                  `json:"status"`
+	Checks map[string]*CheckResult `json:"checks"`
+}
+
+func NewHealth(mongo sources.MongoClient, cache sources.Cache, degradation *Degradation) Health {
+	return &health{
+		mongo:       mongo,
+		cache:       cache,
+		degradation: degradation,
+	}
+}
+
+// SetReady flips once startup finished and back when shutting down, so no new traffic is routed here.
+func (h *health) SetReady(ready bool) {
+	h.ready.Store(ready)
+}
+
+// Healthz only tells the process is serving requests, dependencies are checked by Readyz.
+func (h *health) Healthz(c *fiber.Ctx) error {
+	return c.JSON(&CheckResult{Status: "ok"})
+}
+
+func checkResult(err error) *CheckResult {
+	if err != nil {
+		return &CheckResult{Status: "error", Error: err.Error()}
+	}
+
+	return &CheckResult{Status: "ok"}
+}
+
+func (h *health) Readyz(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), healthCheckTimeout)
+	defer cancel()
+
+	startup := &CheckResult{Status: "ok"}
+	if !h.ready.Load() {
+		startup = &CheckResult{Status: "error", Error: "startup is not complete"}
+	}
+
+	response := &ReadinessResponse{
+		Status: "ok",
+		Checks: map[string]*CheckResult{
+			"startup":  startup,
+			"mongo":    checkResult(h.mongo.Ping(ctx)),
+			"cache":    checkResult(h.cache.Ping(ctx)),
+			"upstream": checkResult(tools.UpstreamStatus()),
+			"degraded": h.degradation.Check(),
+		},
+	}
+
+	// Without Mongo the replica still serves in degraded mode, it stays ready and says so.
+	for name, check := range response.Checks {
+		switch {
+		case check.Status == "ok":
+		case name == "degraded" || name == "mongo" && h.degradation.Degraded():
+			if response.Status == "ok" {
+				response.Status = "degraded"
+			}
+		default:
+			response.Status = "unavailable"
+		}
+	}
+
+	if response.Status != "ok" {
+		c.Status(fiber.StatusServiceUnavailable)
+	}
+
+	return c.JSON(response)
+}
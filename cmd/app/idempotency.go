@@ -0,0 +1,94 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"math"
+	"strconv"
+	"time"
+
+	idempotencyRepository "github.com/YusufOzmen01/veri-kontrol-backend/repository/idempotency"
+	"github.com/gofiber/fiber/v2"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	headerIdempotencyKey = "Idempotency-Key"
+	headerReplayed       = "Idempotency-Replayed"
+
+	maxIdempotencyKey = 255
+)
+
+// idempotent replays the stored response when a client retries a request with the same Idempotency-Key, so
+// flaky connections don't submit twice. It has to run after auth.Identify, keys are kept per user or IP.
+// Requests without the header and dry runs, which don't change anything, are passed through as they are. So
+// is everything while Mongo is down, replaying the queued resolutions drops the ones resolved twice.
+func idempotent(keys idempotencyRepository.Repository, degradation *Degradation) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		key := c.Get(headerIdempotencyKey)
+		if key == "" || c.Query("dry_run") == "true" || degradation.Degraded() {
+			return c.Next()
+		}
+
+		if len(key) > maxIdempotencyKey {
+			return badRequest("Idempotency-Key must be at most 255 characters")
+		}
+
+		sum := sha256.Sum256(c.Body())
+		requestHash := hex.EncodeToString(sum[:])
+
+		record, reserved, err := keys.Reserve(c.Context(), claimOwner(c)+":"+key, requestHash)
+		if err != nil {
+			return err
+		}
+
+		if !reserved {
+			if record.RequestHash != requestHash {
+				return badRequest("Idempotency-Key was already used for a different request")
+			}
+
+			if !record.Done {
+				left := time.Until(record.LeasedUntil)
+				if left < time.Second {
+					left = time.Second
+				}
+
+				c.Set(fiber.HeaderRetryAfter, strconv.Itoa(int(math.Ceil(left.Seconds()))))
+
+				return conflict("a request with this Idempotency-Key is still in progress")
+			}
+
+			c.Set(headerReplayed, "true")
+			c.Set(fiber.HeaderContentType, record.ContentType)
+
+			return c.Status(record.Status).Send(record.Body)
+		}
+
+		// Errors are rendered here, the same as metricsMiddleware does, so the stored body is what the client got.
+		if err := c.Next(); err != nil {
+			if err := c.App().Config().ErrorHandler(c, err); err != nil {
+				_ = c.SendStatus(fiber.StatusInternalServerError)
+			}
+		}
+
+		// Server errors may not happen again, the retry has to run for real.
+		if c.Response().StatusCode() >= fiber.StatusInternalServerError {
+			if err := keys.Release(c.Context(), record); err != nil {
+				logrus.Errorln(err)
+			}
+
+			return nil
+		}
+
+		record.Done = true
+		record.Status = c.Response().StatusCode()
+		record.ContentType = string(c.Response().Header.ContentType())
+		record.Body = append([]byte(nil), c.Response().Body()...)
+
+		if err := keys.Complete(c.Context(), record); err != nil {
+			logrus.Errorln(err)
+		}
+
+		return nil
+	}
+}
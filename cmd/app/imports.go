@@ -0,0 +1,484 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/YusufOzmen01/veri-kontrol-backend/core/address"
+	"github.com/YusufOzmen01/veri-kontrol-backend/core/language"
+	"github.com/YusufOzmen01/veri-kontrol-backend/core/phones"
+	"github.com/YusufOzmen01/veri-kontrol-backend/core/xlsx"
+	auditRepository "github.com/YusufOzmen01/veri-kontrol-backend/repository/audit"
+	"github.com/YusufOzmen01/veri-kontrol-backend/repository/locations"
+	outboxRepository "github.com/YusufOzmen01/veri-kontrol-backend/repository/outbox"
+	reasonsRepository "github.com/YusufOzmen01/veri-kontrol-backend/repository/reasons"
+	typesRepository "github.com/YusufOzmen01/veri-kontrol-backend/repository/types"
+	"github.com/YusufOzmen01/veri-kontrol-backend/tools"
+	"github.com/YusufOzmen01/veri-kontrol-backend/validation"
+	"github.com/gofiber/fiber/v2"
+	"github.com/samber/lo"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type Importer interface {
+	Import(c *fiber.Ctx) error
+}
+
+type importer struct {
+	locations locations.Repository
+	reasons   reasonsRepository.Repository
+	types     typesRepository.Repository
+	processed *ProcessedIDs
+	audit     auditRepository.Repository
+	outbox    outboxRepository.Repository
+}
+
+type ImportRowError struct {
+	Row     int               `json:"row"`
+	EntryID int               `json:"entry_id,omitempty"`
+	Errors  validation.Errors `json:"errors"`
+}
+
+// ImportResponse counts rows from 1 with the header, like the spreadsheet does.
+type ImportResponse struct {
+	Rows           int               `json:"rows"`
+	DryRun         bool              `json:"dry_run"`
+	Imported       []int             `json:"imported"`
+	Skipped        []BulkResolveSkip `json:"skipped"`
+	Invalid        []*ImportRowError `json:"invalid"`
+	IgnoredColumns []string          `json:"ignored_columns"`
+}
+
+const maxImportRows = 10000
+
+// importFields are the columns an import understands, named like the CSV export. Other names can be mapped to
+// them with the mapping form field.
+var importFields = []string{
+	"entry_id", "type", "reason", "corrected", "status", "lat", "lng", "corrected_lat", "corrected_lng",
+	"original_address", "corrected_address", "open_address", "apartment", "tweet_contents", "epoch", "source",
+	"event_id", "resolved_at",
+}
+
+// importTimeLayouts are tried in order for resolved_at, spreadsheets rarely keep RFC 3339.
+var importTimeLayouts = []string{time.RFC3339, "2006-01-02 15:04:05", "2006-01-02 15:04", "02.01.2006 15:04", "2006-01-02", "02.01.2006"}
+
+func NewImporter(locations locations.Repository, reasons reasonsRepository.Repository, types typesRepository.Repository, processed *ProcessedIDs, auditLog auditRepository.Repository, outboxMessages outboxRepository.Repository) Importer {
+	return &importer{
+		locations: locations,
+		reasons:   reasons,
+		types:     types,
+		processed: processed,
+		audit:     auditLog,
+		outbox:    outboxMessages,
+	}
+}
+
+// readImportFile returns the rows of a .csv or .xlsx upload, the first one being the header. Excel saves CSV
+// with semicolons in Turkish locales, so those are accepted too.
+func readImportFile(name string, data []byte) ([][]string, error) {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".csv":
+		data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+
+		reader := csv.NewReader(bytes.NewReader(data))
+		reader.FieldsPerRecord = -1
+
+		header, _, _ := bytes.Cut(data, []byte("\n"))
+		if bytes.Count(header, []byte(";")) > bytes.Count(header, []byte(",")) {
+			reader.Comma = ';'
+		}
+
+		return reader.ReadAll()
+	case ".xlsx":
+		return xlsx.Read(bytes.NewReader(data), int64(len(data)))
+	default:
+		return nil, fmt.Errorf("only .csv and .xlsx files can be imported")
+	}
+}
+
+// importColumns maps the header to import fields, by the mapping first and then by name. The second value lists
+// the columns which are left out.
+func importColumns(header []string, mapping map[string]string) (map[string]int, []string) {
+	columns := make(map[string]int)
+	ignored := make([]string, 0)
+
+	for i, name := range header {
+		name = strings.TrimSpace(name)
+
+		field, ok := mapping[name]
+		if !ok {
+			field = strings.ToLower(name)
+		}
+
+		if _, taken := columns[field]; taken || !lo.Contains(importFields, field) {
+			ignored = append(ignored, name)
+
+			continue
+		}
+
+		columns[field] = i
+	}
+
+	return columns, ignored
+}
+
+// importInt accepts "12" and "12.0", spreadsheets store every number as a float.
+func importInt(value string) (int, error) {
+	if n, err := strconv.Atoi(value); err == nil {
+		return n, nil
+	}
+
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil || f != math.Trunc(f) || math.Abs(f) > math.MaxInt32 {
+		return 0, fmt.Errorf("must be a whole number")
+	}
+
+	return int(f), nil
+}
+
+// maxExcelSerial is 9999-12-31 as an Excel serial date. Bigger numbers are unix times, smaller ones would be
+// unix times in January 1970, which no resolution has.
+const maxExcelSerial = 2958465
+
+// importTime reads a date in one of importTimeLayouts, an Excel serial date or a unix time. Date cells of an
+// xlsx come as serials, days since 1899-12-30 with the time of day as the fraction, in local time like the
+// layouts.
+func importTime(value string) (time.Time, error) {
+	for _, layout := range importTimeLayouts {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t, nil
+		}
+	}
+
+	if serial, err := strconv.ParseFloat(strings.Replace(value, ",", ".", 1), 64); err == nil && serial > 0 && serial <= maxExcelSerial {
+		days := math.Floor(serial)
+		seconds := math.Round((serial - days) * 24 * 60 * 60)
+
+		return time.Date(1899, 12, 30+int(days), 0, 0, int(seconds), 0, time.Local), nil
+	}
+
+	if unix, err := importInt(value); err == nil && unix > 0 {
+		return time.Unix(int64(unix), 0), nil
+	}
+
+	return time.Time{}, fmt.Errorf("must be a date and time")
+}
+
+// importCoordinates reads an optional pair, both or neither of the columns have to be set.
+func importCoordinates(v *validation.Validator, latField, lngField, lat, lng string) (*float64, *float64) {
+	if lat == "" && lng == "" {
+		return nil, nil
+	}
+
+	latValue, latErr := strconv.ParseFloat(strings.Replace(lat, ",", ".", 1), 64)
+	lngValue, lngErr := strconv.ParseFloat(strings.Replace(lng, ",", ".", 1), 64)
+
+	switch {
+	case lat == "":
+		v.Add(latField, "is required together with "+lngField)
+	case lng == "":
+		v.Add(lngField, "is required together with "+latField)
+	case latErr != nil || latValue < -90 || latValue > 90:
+		v.Add(latField, "must be a latitude")
+	case lngErr != nil || lngValue < -180 || lngValue > 180:
+		v.Add(lngField, "must be a longitude")
+	default:
+		return &latValue, &lngValue
+	}
+
+	return nil, nil
+}
+
+// importLocation validates a row the same way /resolve validates a body, except that any known type is accepted
+// since old resolutions can use types which aren't selectable anymore.
+func importLocation(row []string, columns map[string]int, reasons []*reasonsRepository.Reason, types []*typesRepository.LocationType) (*locations.LocationDB, error) {
+	value := func(field string) string {
+		i, ok := columns[field]
+		if !ok || i >= len(row) {
+			return ""
+		}
+
+		return validation.NormalizeText(row[i])
+	}
+
+	v := validation.New()
+	location := &locations.LocationDB{
+		Location:         make([]float64, 0),
+		OriginalAddress:  value("original_address"),
+		CorrectedAddress: value("corrected_address"),
+		OpenAddress:      value("open_address"),
+		Apartment:        value("apartment"),
+		TweetContents:    value("tweet_contents"),
+		Source:           value("source"),
+		EventID:          value("event_id"),
+		Status:           locations.StatusResolved,
+	}
+
+	if v.Required("entry_id", value("entry_id")) {
+		entryID, err := importInt(value("entry_id"))
+		if err != nil {
+			v.Add("entry_id", err.Error())
+		} else {
+			v.Positive("entry_id", entryID)
+		}
+
+		location.EntryID = entryID
+	}
+
+	if v.Required("type", value("type")) {
+		locationType, err := importInt(value("type"))
+		if err != nil {
+			v.Add("type", err.Error())
+		} else if found := typesRepository.Find(types, locationType); found == nil {
+			v.Add("type", "must be a known location type")
+		} else {
+			location.Type = found.ID
+			location.TypeCode = found.Code
+		}
+	}
+
+	if v.Required("reason", value("reason")) {
+		location.Reason, _ = v.OneOf("reason", value("reason"), reasonsRepository.Codes(reasons))
+	}
+
+	if reason := reasonsRepository.Find(reasons, location.Reason); reason != nil {
+		location.Corrected = reason.Corrected
+	}
+
+	if corrected := value("corrected"); corrected != "" {
+		parsed, err := strconv.ParseBool(strings.ToLower(corrected))
+		if err != nil {
+			v.Add("corrected", "must be true or false")
+		}
+
+		location.Corrected = parsed
+	}
+
+	if status := value("status"); status != "" {
+		location.Status, _ = v.OneOf("status", status, []string{locations.StatusResolved, locations.StatusPendingReview})
+	}
+
+	if lat, lng := importCoordinates(v, "lat", "lng", value("lat"), value("lng")); lat != nil {
+		location.Location = []float64{*lat, *lng}
+
+		if location.OriginalAddress == "" {
+			location.OriginalAddress = fmt.Sprintf("https://www.google.com/maps/?q=%f,%f&ll=%f,%f&z=21", *lat, *lng, *lat, *lng)
+		}
+	}
+
+	location.CorrectedLat, location.CorrectedLng = importCoordinates(v, "corrected_lat", "corrected_lng", value("corrected_lat"), value("corrected_lng"))
+
+	if epoch := value("epoch"); epoch != "" {
+		parsed, err := importInt(epoch)
+		if err != nil || parsed < 0 {
+			v.Add("epoch", "must be a unix time")
+		}
+
+		location.Epoch = parsed
+	}
+
+	resolvedAt := time.Now()
+	if value("resolved_at") != "" {
+		parsed, err := importTime(value("resolved_at"))
+		if err != nil {
+			v.Add("resolved_at", err.Error())
+		}
+
+		resolvedAt = parsed
+	}
+
+	location.ID = primitive.NewObjectIDFromTimestamp(resolvedAt)
+
+	v.MaxLength("corrected_address", location.CorrectedAddress, maxAddressLength)
+	v.MaxLength("open_address", location.OpenAddress, maxAddressLength)
+	v.MaxLength("apartment", location.Apartment, maxApartmentLength)
+	v.MaxLength("tweet_contents", location.TweetContents, maxTweetLength)
+
+	if location.CorrectedAddress != "" {
+		if parsed := address.Parse(location.CorrectedAddress); !parsed.IsEmpty() {
+			location.ParsedAddress = parsed
+		}
+	}
+
+	location.PhoneNumbers = phones.Extract(location.TweetContents + "\n" + location.OpenAddress)
+	location.Lang = language.Detect(location.TweetContents)
+
+	return location, v.Err()
+}
+
+// Import adds resolutions from a spreadsheet, for the ones made before this backend existed. Valid rows are
+// imported even when others aren't, entries which already have a resolution are skipped. With dry_run=true
+// nothing is written.
+func (i *importer) Import(c *fiber.Ctx) error {
+	file, err := c.FormFile("file")
+	if err != nil {
+		return badRequest("file is required")
+	}
+
+	mapping := make(map[string]string)
+	if raw := c.FormValue("mapping"); raw != "" {
+		if err := json.Unmarshal([]byte(raw), &mapping); err != nil {
+			return badRequest("mapping must be a JSON object of column names to fields")
+		}
+
+		for column, field := range mapping {
+			if !lo.Contains(importFields, field) {
+				return badRequest(fmt.Sprintf("column %q is mapped to the unknown field %q", column, field))
+			}
+		}
+	}
+
+	f, err := file.Open()
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return err
+	}
+
+	rows, err := readImportFile(file.Filename, data)
+	if err != nil {
+		return badRequest(err.Error())
+	}
+
+	if len(rows) < 2 {
+		return badRequest("the file has no rows below the header")
+	}
+
+	if len(rows)-1 > maxImportRows {
+		return badRequest(fmt.Sprintf("at most %d rows can be imported at once", maxImportRows))
+	}
+
+	columns, ignored := importColumns(rows[0], mapping)
+	if _, ok := columns["entry_id"]; !ok {
+		return badRequest("the file has no entry_id column")
+	}
+
+	reasonList, err := i.reasons.GetReasons(c.Context())
+	if err != nil {
+		return err
+	}
+
+	typeList, err := i.types.GetTypes(c.Context())
+	if err != nil {
+		return err
+	}
+
+	response := &ImportResponse{
+		Rows:           len(rows) - 1,
+		DryRun:         c.Query("dry_run") == "true",
+		Imported:       make([]int, 0),
+		Skipped:        make([]BulkResolveSkip, 0),
+		Invalid:        make([]*ImportRowError, 0),
+		IgnoredColumns: ignored,
+	}
+
+	activeEventID := tools.ActiveEventID(c.Context())
+	sender := currentUser(c)
+	firstRow := make(map[int]int)
+	valid := make([]*locations.LocationDB, 0, len(rows)-1)
+
+	for n, row := range rows[1:] {
+		rowNumber := n + 2
+
+		location, err := importLocation(row, columns, reasonList, typeList)
+		if err != nil {
+			response.Invalid = append(response.Invalid, &ImportRowError{
+				Row:     rowNumber,
+				EntryID: location.EntryID,
+				Errors:  err.(validation.Errors),
+			})
+
+			continue
+		}
+
+		if first, ok := firstRow[location.EntryID]; ok {
+			response.Skipped = append(response.Skipped, BulkResolveSkip{ID: location.EntryID, Reason: fmt.Sprintf("repeats row %d", first)})
+
+			continue
+		}
+		firstRow[location.EntryID] = rowNumber
+
+		location.Sender = sender
+		if location.EventID == "" {
+			location.EventID = activeEventID
+		}
+
+		valid = append(valid, location)
+	}
+
+	entryIDs := make([]int, 0, len(valid))
+	for _, location := range valid {
+		entryIDs = append(entryIDs, location.EntryID)
+	}
+
+	resolvedIDs, err := i.locations.GetResolvedIDs(c.Context(), entryIDs)
+	if err != nil {
+		return err
+	}
+
+	resolved := make(map[int]bool, len(resolvedIDs))
+	for _, id := range resolvedIDs {
+		resolved[id] = true
+	}
+
+	imported := make([]*locations.LocationDB, 0, len(valid))
+	for _, location := range valid {
+		if resolved[location.EntryID] {
+			response.Skipped = append(response.Skipped, BulkResolveSkip{ID: location.EntryID, Reason: "already resolved"})
+
+			continue
+		}
+
+		imported = append(imported, location)
+		response.Imported = append(response.Imported, location.EntryID)
+	}
+
+	if response.DryRun {
+		return c.JSON(response)
+	}
+
+	conflicts, err := i.locations.ResolveLocations(c.Context(), imported)
+	if err != nil {
+		return err
+	}
+
+	// The rows resolved by someone else since the check above are skipped, the others are stored.
+	if len(conflicts) > 0 {
+		conflicted := make(map[int]bool, len(conflicts))
+		for _, id := range conflicts {
+			conflicted[id] = true
+			response.Skipped = append(response.Skipped, BulkResolveSkip{ID: id, Reason: "resolved by someone else in the meantime"})
+		}
+
+		imported = lo.Filter(imported, func(location *locations.LocationDB, _ int) bool { return !conflicted[location.EntryID] })
+		response.Imported = lo.Filter(response.Imported, func(id int, _ int) bool { return !conflicted[id] })
+	}
+
+	for _, location := range imported {
+		if location.Status == locations.StatusResolved {
+			i.processed.Add(location.EntryID)
+		}
+
+		recordAudit(c, i.audit, &auditRepository.Event{
+			Action:  auditRepository.ActionImport,
+			EntryID: location.EntryID,
+			Target:  file.Filename,
+			Changes: auditRepository.Diff(nil, location),
+		})
+		queueResolved(c, i.outbox, location)
+	}
+
+	return c.JSON(response)
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	reasonsRepository "github.com/YusufOzmen01/veri-kontrol-backend/repository/reasons"
+	typesRepository "github.com/YusufOzmen01/veri-kontrol-backend/repository/types"
+)
+
+func TestImportTime(t *testing.T) {
+	tests := []struct {
+		value string
+		want  time.Time
+	}{
+		{"2023-02-06 04:30", time.Date(2023, 2, 6, 4, 30, 0, 0, time.Local)},
+		{"06.02.2023", time.Date(2023, 2, 6, 0, 0, 0, 0, time.Local)},
+		{"44963", time.Date(2023, 2, 6, 0, 0, 0, 0, time.Local)},
+		{"44963.1875", time.Date(2023, 2, 6, 4, 30, 0, 0, time.Local)},
+		{"44963,5", time.Date(2023, 2, 6, 12, 0, 0, 0, time.Local)},
+		{"1675647000", time.Unix(1675647000, 0)},
+	}
+
+	for _, tt := range tests {
+		got, err := importTime(tt.value)
+		if err != nil {
+			t.Errorf("importTime(%q): %s", tt.value, err)
+
+			continue
+		}
+
+		if !got.Equal(tt.want) {
+			t.Errorf("importTime(%q) = %s, want %s", tt.value, got, tt.want)
+		}
+	}
+
+	for _, value := range []string{"", "yesterday", "-1", "0"} {
+		if _, err := importTime(value); err == nil {
+			t.Errorf("importTime(%q) succeeded", value)
+		}
+	}
+}
+
+func TestReadImportFile(t *testing.T) {
+	rows, err := readImportFile("export.CSV", []byte("\xef\xbb\xbfentry_id;tweet_contents\n12;\"Kurtuluş Mah., 5. Sokak\"\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := [][]string{{"entry_id", "tweet_contents"}, {"12", "Kurtuluş Mah., 5. Sokak"}}
+	if !reflect.DeepEqual(rows, want) {
+		t.Fatalf("rows = %q, want %q", rows, want)
+	}
+
+	if _, err := readImportFile("export.ods", []byte("entry_id\n12\n")); err == nil {
+		t.Error("readImportFile() accepted an .ods file")
+	}
+}
+
+func TestImportLocation(t *testing.T) {
+	reasons := []*reasonsRepository.Reason{{Code: "correct"}, {Code: "wrong_address", Corrected: true}}
+	types := []*typesRepository.LocationType{{ID: 1, Code: "rubble"}, {ID: 7, Code: "retired"}}
+
+	columns, ignored := importColumns([]string{"ID", "type", "reason", "lat", "lng", "Çözüldü", "notes"}, map[string]string{"ID": "entry_id", "Çözüldü": "resolved_at"})
+	if !reflect.DeepEqual(ignored, []string{"notes"}) {
+		t.Fatalf("ignored = %q", ignored)
+	}
+
+	location, err := importLocation([]string{"12.0", "7", "wrong_address", "36.2023", "36,1613", "44963.1875"}, columns, reasons, types)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if location.EntryID != 12 || location.TypeCode != "retired" || !location.Corrected {
+		t.Errorf("location = %+v", location)
+	}
+
+	if !reflect.DeepEqual(location.Location, []float64{36.2023, 36.1613}) {
+		t.Errorf("location = %v", location.Location)
+	}
+
+	if want := time.Date(2023, 2, 6, 4, 30, 0, 0, time.Local); !location.ID.Timestamp().Equal(want) {
+		t.Errorf("resolved at %s, want %s", location.ID.Timestamp(), want)
+	}
+
+	if _, err := importLocation([]string{"12", "3", "fixed", "36.2023", "", "tomorrow"}, columns, reasons, types); err == nil {
+		t.Error("importLocation() accepted an invalid row")
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/YusufOzmen01/veri-kontrol-backend/core/sources"
+	"github.com/YusufOzmen01/veri-kontrol-backend/repository/locations"
+	"github.com/YusufOzmen01/veri-kontrol-backend/tools"
+	"github.com/YusufOzmen01/veri-kontrol-backend/validation"
+	"github.com/gofiber/fiber/v2"
+	"github.com/sirupsen/logrus"
+)
+
+type Intake interface {
+	AddEntry(c *fiber.Ctx) error
+}
+
+type intake struct {
+	locations locations.Repository
+	cache     sources.Cache
+}
+
+// IntakeBody is a report from outside the upstream feed, like a call center or a web form. Epoch defaults to
+// the time it was received.
+type IntakeBody struct {
+	Source  string  `json:"source"`
+	Lat     float64 `json:"lat"`
+	Lng     float64 `json:"lng"`
+	Message string  `json:"message"`
+	Address string  `json:"address"`
+	Epoch   int     `json:"epoch"`
+}
+
+type IntakeResponse struct {
+	EntryID int `json:"entry_id"`
+}
+
+func (b *IntakeBody) Validate() error {
+	b.Source = validation.NormalizeText(b.Source)
+	b.Message = validation.NormalizeText(b.Message)
+	b.Address = validation.NormalizeText(b.Address)
+
+	v := validation.New()
+
+	if v.Required("source", b.Source) {
+		b.Source, _ = v.OneOf("source", b.Source, locations.IntakeSources)
+	}
+
+	if b.Lat < -90 || b.Lat > 90 || b.Lat == 0 {
+		v.Add("lat", "must be a latitude")
+	}
+
+	if b.Lng < -180 || b.Lng > 180 || b.Lng == 0 {
+		v.Add("lng", "must be a longitude")
+	}
+
+	v.Required("message", b.Message)
+	v.MaxLength("message", b.Message, maxTweetLength)
+	v.MaxLength("address", b.Address, maxAddressLength)
+
+	if b.Epoch < 0 {
+		v.Add("epoch", "must not be negative")
+	}
+
+	return v.Err()
+}
+
+func NewIntake(locations locations.Repository, cache sources.Cache) Intake {
+	return &intake{
+		locations: locations,
+		cache:     cache,
+	}
+}
+
+func (i *intake) AddEntry(c *fiber.Ctx) error {
+	body := &IntakeBody{}
+
+	if err := json.Unmarshal(c.Body(), body); err != nil {
+		return badRequest(err.Error())
+	}
+
+	if err := body.Validate(); err != nil {
+		return validationFailed(err)
+	}
+
+	if body.Epoch == 0 {
+		body.Epoch = int(time.Now().Unix())
+	}
+
+	entry := &locations.IntakeEntry{
+		Source:      body.Source,
+		Loc:         []float64{body.Lat, body.Lng},
+		Epoch:       body.Epoch,
+		Message:     body.Message,
+		Address:     body.Address,
+		SubmittedBy: currentUser(c),
+	}
+
+	if err := i.locations.AddIntakeEntry(c.Context(), entry); err != nil {
+		return err
+	}
+
+	setEntryID(c, entry.EntryID)
+
+	// The entry is stored, the next refresh picks it up even if it can't be added to the cache now.
+	if err := tools.AddLocation(c.Context(), i.cache, entry.Location()); err != nil {
+		logrus.Errorf("Couldn't add intake entry %d to the pool: %s", entry.EntryID, err)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(&IntakeResponse{
+		EntryID: entry.EntryID,
+	})
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"os"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// requestLog always writes JSON so the lines can be searched by request_id, whatever the app log looks like.
+var requestLog = &logrus.Logger{
+	Out:       os.Stdout,
+	Formatter: &logrus.JSONFormatter{},
+	Hooks:     make(logrus.LevelHooks),
+	Level:     logrus.InfoLevel,
+}
+
+// setEntryID lets handlers which take the entry from the body or pick it themselves show up in the request log.
+func setEntryID(c *fiber.Ctx, entryID int) {
+	c.Locals("entry_id", entryID)
+}
+
+// requestLogger runs after the requestid middleware and before metricsMiddleware, which already rendered
+// errors by the time it returns, so the logged status is the one the client got.
+func requestLogger(c *fiber.Ctx) error {
+	start := time.Now()
+
+	err := c.Next()
+
+	fields := logrus.Fields{
+		"request_id": c.Locals("requestid"),
+		"method":     c.Method(),
+		"path":       c.Path(),
+		"status":     c.Response().StatusCode(),
+		"latency_ms": time.Since(start).Milliseconds(),
+		"ip":         c.IP(),
+	}
+
+	if user := currentUser(c); user != nil {
+		fields["user_id"] = user.ID.Hex()
+	}
+
+	if spanContext := trace.SpanContextFromContext(c.UserContext()); spanContext.IsValid() {
+		fields["trace_id"] = spanContext.TraceID().String()
+	}
+
+	if entryID, ok := c.Locals("entry_id").(int); ok {
+		fields["entry_id"] = entryID
+	} else if entryID, err := c.ParamsInt("entry_id"); err == nil {
+		fields["entry_id"] = entryID
+	}
+
+	entry := requestLog.WithFields(fields)
+	if status := c.Response().StatusCode(); status >= fiber.StatusInternalServerError {
+		entry.Error("request")
+	} else {
+		entry.Info("request")
+	}
+
+	return err
+}
@@ -2,41 +2,125 @@ package main
 
 import (
 	"context"
-	"encoding/json"
+	"crypto/tls"
+	"errors"
 	"fmt"
-	"go.mongodb.org/mongo-driver/bson/primitive"
 	"math/rand"
+	"net"
+	"net/http"
 	"os"
 	"os/signal"
+	"strings"
+	"sync"
 	"syscall"
 	"time"
 
-	"github.com/Netflix/go-env"
+	"github.com/YusufOzmen01/veri-kontrol-backend/core/address"
+	"github.com/YusufOzmen01/veri-kontrol-backend/core/phones"
+	"github.com/YusufOzmen01/veri-kontrol-backend/core/queue"
+	"github.com/YusufOzmen01/veri-kontrol-backend/core/ratelimit"
+	"github.com/YusufOzmen01/veri-kontrol-backend/core/redact"
+	"github.com/YusufOzmen01/veri-kontrol-backend/core/scheduler"
+	"github.com/YusufOzmen01/veri-kontrol-backend/core/scoring"
 	"github.com/YusufOzmen01/veri-kontrol-backend/core/sources"
+	"github.com/YusufOzmen01/veri-kontrol-backend/core/storage"
+	"github.com/YusufOzmen01/veri-kontrol-backend/core/textfilter"
+	"github.com/YusufOzmen01/veri-kontrol-backend/core/tokens"
+	"github.com/YusufOzmen01/veri-kontrol-backend/core/tracing"
+	"github.com/YusufOzmen01/veri-kontrol-backend/core/wal"
+	auditRepository "github.com/YusufOzmen01/veri-kontrol-backend/repository/audit"
+	citiesRepository "github.com/YusufOzmen01/veri-kontrol-backend/repository/cities"
+	commentsRepository "github.com/YusufOzmen01/veri-kontrol-backend/repository/comments"
+	eventsRepository "github.com/YusufOzmen01/veri-kontrol-backend/repository/events"
+	idempotencyRepository "github.com/YusufOzmen01/veri-kontrol-backend/repository/idempotency"
+	invalidationsRepository "github.com/YusufOzmen01/veri-kontrol-backend/repository/invalidations"
 	locationsRepository "github.com/YusufOzmen01/veri-kontrol-backend/repository/locations"
+	notificationsRepository "github.com/YusufOzmen01/veri-kontrol-backend/repository/notifications"
+	outboxRepository "github.com/YusufOzmen01/veri-kontrol-backend/repository/outbox"
+	qualityRepository "github.com/YusufOzmen01/veri-kontrol-backend/repository/quality"
+	reasonsRepository "github.com/YusufOzmen01/veri-kontrol-backend/repository/reasons"
+	typesRepository "github.com/YusufOzmen01/veri-kontrol-backend/repository/types"
 	usersRepository "github.com/YusufOzmen01/veri-kontrol-backend/repository/users"
+	webhooksRepository "github.com/YusufOzmen01/veri-kontrol-backend/repository/webhooks"
 	"github.com/YusufOzmen01/veri-kontrol-backend/tools"
+	"github.com/YusufOzmen01/veri-kontrol-backend/util"
+	"github.com/YusufOzmen01/veri-kontrol-backend/validation"
 	"github.com/gofiber/fiber/v2"
-	"github.com/gofiber/fiber/v2/middleware/cors"
+	"github.com/gofiber/fiber/v2/middleware/compress"
+	"github.com/gofiber/fiber/v2/middleware/etag"
 	"github.com/gofiber/fiber/v2/middleware/monitor"
+	"github.com/gofiber/fiber/v2/middleware/requestid"
+	"github.com/gofiber/websocket/v2"
 	"github.com/sirupsen/logrus"
 )
 
-type Environment struct {
-	MongoUri string `env:"mongo_uri"`
+// claimOwner identifies who an entry is locked to, anonymous volunteers fall back to their IP.
+func claimOwner(c *fiber.Ctx) string {
+	if user := currentUser(c); user != nil {
+		return user.ID.Hex()
+	}
+
+	return c.IP()
+}
+
+// canSeePII tells whether the phone and ID numbers in served texts are left as they are.
+func canSeePII(c *fiber.Ctx) bool {
+	user := currentUser(c)
+
+	return user != nil && user.HasScope(usersRepository.ScopePIIRead)
+}
+
+// GetLocationResponse is Stale while part of the feed is an older copy because upstream is failing, and
+// Degraded while Mongo is down and the entry might have been resolved already.
+type GetLocationResponse struct {
+	Count    int                           `json:"count"`
+	Location *locationsRepository.Location `json:"location"`
+	Stale    bool                          `json:"stale,omitempty"`
+	Degraded bool                          `json:"degraded,omitempty"`
+}
+
+const (
+	defaultBatchSize = 10
+	maxBatchSize     = 20
+)
+
+// The values of the coord_suspect query parameter, without it suspicious entries are served like any other.
+const (
+	coordSuspectExclude = "exclude"
+	coordSuspectFirst   = "first"
+)
+
+// GetLocationsResponse is the batch version of GetLocationResponse, every location in it is claimed.
+type GetLocationsResponse struct {
+	Count     int                             `json:"count"`
+	Locations []*locationsRepository.Location `json:"locations"`
+	Stale     bool                            `json:"stale,omitempty"`
+	Degraded  bool                            `json:"degraded,omitempty"`
+}
+
+// ResolveDryRunResponse is what /resolve?dry_run=true would store, along with what the duplicate checks found.
+// TextDuplicate means an already resolved entry has the same text, DuplicateOf is a resolved entry nearby with
+// a similar one.
+type ResolveDryRunResponse struct {
+	Entry         *locationsRepository.LocationDB `json:"entry"`
+	TextDuplicate bool                            `json:"text_duplicate"`
+	DuplicateOf   int                             `json:"duplicate_of,omitempty"`
+}
+
+type BulkResolveBody struct {
+	IDs          []int  `json:"ids"`
+	LocationType int    `json:"type"`
+	Reason       string `json:"reason"`
 }
 
-var cities = map[int][]float64{
-	1:  {36.852702785393014, 36.87286376953126, 36.535570922786015, 35.88409423828126},
-	2:  {36.2104851748389, 36.81861877441407, 35.84286468375614, 35.82984924316407},
-	3:  {36.495937096205274, 36.649870522206335, 36.064120488812605, 35.4740187605459},
-	4:  {36.50903585150776, 36.402143998719424, 36.47976138594277, 36.31474829364722},
-	5:  {36.64234742932176, 36.3232450328562, 36.53629731173617, 36.029282092441115},
-	6:  {36.116001873480265, 36.06470054394251, 36.0627178139989, 35.91771907373497},
-	7:  {38.53348725642158, 38.78062516773912, 37.32756763881127, 35.45481415037825},
-	8:  {37.35461473302187, 38.0755896764663, 36.85431769725969, 36.67725839531126},
-	9:  {39.065058845523424, 40.013647871307754, 37.86798402826048, 36.687836853946884},
-	10: {38.160827052916495, 39.33362355320935, 37.44250898099215, 37.35608449070936},
+type BulkResolveSkip struct {
+	ID     int    `json:"id"`
+	Reason string `json:"reason"`
+}
+
+type BulkResolveResponse struct {
+	Resolved []int             `json:"resolved"`
+	Skipped  []BulkResolveSkip `json:"skipped"`
 }
 
 type ResolveBody struct {
@@ -47,256 +131,845 @@ type ResolveBody struct {
 	Apartment     string `json:"apartment"`
 	Reason        string `json:"reason"`
 	TweetContents string `json:"tweet_contents"`
+	Spam          bool   `json:"spam"`
+
+	// CorrectedLat and CorrectedLng are where the volunteer found the building on the map, both or neither.
+	CorrectedLat *float64 `json:"corrected_lat,omitempty"`
+	CorrectedLng *float64 `json:"corrected_lng,omitempty"`
+
+	reason       *reasonsRepository.Reason
+	locationType *typesRepository.LocationType
+}
+
+const (
+	maxAddressLength   = 500
+	maxApartmentLength = 200
+	maxTweetLength     = 1000
+)
+
+// Validate normalizes the free text fields in place and reports every invalid field at once. The reason has to
+// be one of the codes in the reasons collection and the type one of the selectable location types.
+func (b *ResolveBody) Validate(reasons []*reasonsRepository.Reason, types []*typesRepository.LocationType) error {
+	b.NewAddress = validation.NormalizeText(b.NewAddress)
+	b.OpenAddress = validation.NormalizeText(b.OpenAddress)
+	b.Apartment = validation.NormalizeText(b.Apartment)
+	b.Reason = validation.NormalizeText(b.Reason)
+
+	v := validation.New()
+
+	v.Positive("id", b.ID)
+
+	// Spam has no real address, the type and reason are fixed so flagged entries are easy to query.
+	if b.Spam {
+		b.LocationType = locationsRepository.TypeSpam
+		b.Reason = locationsRepository.ReasonSpam
+	} else {
+		v.OneOfInt("type", b.LocationType, typesRepository.SelectableIDs(types))
+
+		if v.Required("reason", b.Reason) {
+			b.Reason, _ = v.OneOf("reason", b.Reason, reasonsRepository.Codes(reasons))
+		}
+
+		if reason := reasonsRepository.Find(reasons, b.Reason); reason == nil || !reason.Corrected {
+			v.Required("new_address", b.NewAddress)
+		}
+	}
+
+	b.reason = reasonsRepository.Find(reasons, b.Reason)
+	b.locationType = typesRepository.Find(types, b.LocationType)
+
+	if b.Spam {
+		b.CorrectedLat, b.CorrectedLng = nil, nil
+	}
+
+	switch {
+	case b.CorrectedLat == nil && b.CorrectedLng == nil:
+	case b.CorrectedLat == nil:
+		v.Add("corrected_lat", "is required together with corrected_lng")
+	case b.CorrectedLng == nil:
+		v.Add("corrected_lng", "is required together with corrected_lat")
+	case *b.CorrectedLat < -90 || *b.CorrectedLat > 90:
+		v.Add("corrected_lat", "must be a latitude")
+	case *b.CorrectedLng < -180 || *b.CorrectedLng > 180:
+		v.Add("corrected_lng", "must be a longitude")
+	case !tools.InTurkey(*b.CorrectedLat, *b.CorrectedLng):
+		v.Add("corrected_lat", "must be in Turkey together with corrected_lng")
+	}
+
+	v.MaxLength("new_address", b.NewAddress, maxAddressLength)
+	v.MaxLength("open_address", b.OpenAddress, maxAddressLength)
+	v.MaxLength("apartment", b.Apartment, maxApartmentLength)
+	v.MaxLength("tweet_contents", b.TweetContents, maxTweetLength)
+
+	return v.Err()
+}
+
+// Corrected is true when the reason says the original address was right.
+func (b *ResolveBody) Corrected() bool {
+	return b.reason != nil && b.reason.Corrected
+}
+
+func (b *ResolveBody) TypeCode() string {
+	if b.locationType == nil {
+		return ""
+	}
+
+	return b.locationType.Code
+}
+
+// ParsedAddress is nil when the volunteer didn't write an address or nothing in it could be understood.
+func (b *ResolveBody) ParsedAddress() *address.Parsed {
+	if b.NewAddress == "" {
+		return nil
+	}
+
+	parsed := address.Parse(b.NewAddress)
+	if parsed.IsEmpty() {
+		return nil
+	}
+
+	return parsed
+}
+
+// PhoneNumbers are the mobile numbers in the tweet and the open address, field teams call them first.
+func (b *ResolveBody) PhoneNumbers() []string {
+	return phones.Extract(b.TweetContents + "\n" + b.OpenAddress)
 }
 
 func main() {
-	app := fiber.New()
 	ctx := context.Background()
-	cache := sources.NewCache(1<<30, 1e7, 64)
 
 	rand.Seed(time.Now().UnixMilli())
 
-	var environment Environment
-	if _, err := env.UnmarshalFromEnviron(&environment); err != nil {
+	environment, err := LoadEnvironment()
+	if err != nil {
 		panic(err)
 	}
 
+	// Behind a proxy every request comes from its address, only the trusted proxies may name the client. Rate
+	// limits and lockouts would hit everyone at once otherwise.
+	app := fiber.New(fiber.Config{
+		ErrorHandler:            errorHandler,
+		EnableTrustedProxyCheck: true,
+		TrustedProxies:          splitCSV(environment.TrustedProxies),
+		ProxyHeader:             environment.ProxyHeader,
+		EnableIPValidation:      true,
+	})
+
+	level, _ := logrus.ParseLevel(environment.LogLevel)
+	logrus.SetLevel(level)
+	requestLog.SetLevel(level)
+
+	shutdownTracing := func(ctx context.Context) error { return nil }
+	if environment.OTLPEndpoint != "" {
+		shutdownTracing, err = tracing.Setup(ctx, environment.TracingConfig())
+		if err != nil {
+			panic(err)
+		}
+	} else {
+		logrus.Infoln("otlp_endpoint is not set, tracing is disabled")
+	}
+
+	tools.UpstreamURL = strings.TrimSuffix(environment.UpstreamURL, "/")
+
+	upstreamSources, _ := tools.ParseSources(environment.UpstreamSources)
+	if environment.SyntheticUpstream {
+		// A fixed seed, every replica has to serve the same entries.
+		upstreamSources = []tools.Source{tools.NewSyntheticSource(environment.SyntheticEntries, 1)}
+
+		logrus.Warnf("synthetic_upstream is on, %d generated entries are served instead of the upstream feed", environment.SyntheticEntries)
+	}
+	tools.SetSources(upstreamSources)
+	tools.LocationsCacheTTL = time.Duration(environment.FeedCacheSeconds) * time.Second
+	tools.TextCacheTTL = time.Duration(environment.TextCacheSeconds) * time.Second
+	tools.MaxTextFetches = environment.TextFetches
+	tools.MaxPrefetches = environment.Prefetches
+	tools.KeywordScorer, _ = scoring.Parse(environment.UrgencyKeywords)
+
+	textFilter := textfilter.New(strings.Split(environment.TextBlocklist, ","))
+	masker := NewMasker(textFilter)
+
+	var cache sources.Cache
+	if environment.RedisUri != "" {
+		cache = sources.NewRedisCache(ctx, environment.RedisUri, "veri-kontrol:")
+	} else {
+		cache = sources.NewCache(environment.CacheMaxCost, environment.CacheCounters, 64)
+	}
+
 	mongoClient := sources.NewMongoClient(ctx, environment.MongoUri, "database")
 	locationRepository := locationsRepository.NewRepository(mongoClient)
 	userRepository := usersRepository.NewRepository(mongoClient)
+	cityRepository := citiesRepository.NewRepository(mongoClient)
+	auditLog := auditRepository.NewRepository(mongoClient)
+	notificationRepository := notificationsRepository.NewRepository(mongoClient)
+	webhookRepository := webhooksRepository.NewRepository(mongoClient)
+	reasonRepository := reasonsRepository.NewRepository(mongoClient)
+	typeRepository := typesRepository.NewRepository(mongoClient)
+	eventRepository := eventsRepository.NewRepository(mongoClient)
+	idempotencyKeys := idempotencyRepository.NewRepository(mongoClient)
+	reviewRepository := qualityRepository.NewRepository(mongoClient)
+	commentRepository := commentsRepository.NewRepository(mongoClient)
+	outboxMessages := outboxRepository.NewRepository(mongoClient)
+	cacheInvalidations := invalidationsRepository.NewRepository(mongoClient)
+
+	if err := cityRepository.EnsureDefaults(ctx); err != nil {
+		logrus.Errorf("Couldn't seed cities: %s", err)
+	}
 
-	admin := NewAdmin(locationRepository, cache)
+	if err := eventRepository.EnsureDefault(ctx); err != nil {
+		logrus.Errorf("Couldn't seed events: %s", err)
+	}
+
+	if err := locationRepository.MigrateEvent(ctx, eventsRepository.DefaultEvent.ID); err != nil {
+		logrus.Errorf("Couldn't migrate resolution events: %s", err)
+	}
+
+	if err := userRepository.MigrateScopes(ctx); err != nil {
+		logrus.Errorf("Couldn't migrate user scopes: %s", err)
+	}
+
+	if err := typeRepository.EnsureDefaults(ctx); err != nil {
+		logrus.Errorf("Couldn't seed location types: %s", err)
+	}
+
+	if environment.JWTSecret == "" {
+		logrus.Warnln("jwt_secret is not set, issued tokens will be invalidated on restart")
+
+		environment.JWTSecret = util.SecureRandomString(32)
+	}
+
+	claimDuration := locationsRepository.DefaultClaimDuration
+	if environment.ClaimMinutes > 0 {
+		claimDuration = time.Duration(environment.ClaimMinutes) * time.Minute
+	}
+
+	// allowed_reasons only seeds an empty reasons collection, afterwards the collection is what counts.
+	defaultReasons := reasonsRepository.DefaultCodes
+	if environment.AllowedReasons != "" {
+		defaultReasons = strings.Split(environment.AllowedReasons, ",")
+	}
+
+	if err := reasonRepository.EnsureDefaults(ctx, defaultReasons); err != nil {
+		logrus.Errorf("Couldn't seed reasons: %s", err)
+	}
+
+	defaultStrategy := queue.StrategyRandom
+	if environment.Strategy != "" {
+		strategy, err := queue.ParseStrategy(environment.Strategy)
+		if err != nil {
+			panic(err)
+		}
 
-	processedIDs := make([]int, 0)
+		defaultStrategy = strategy
+	}
+
+	var geocoder tools.Geocoder
+	switch environment.Geocoder {
+	case "nominatim":
+		geocoder = tools.NewCachedGeocoder(tools.NewNominatimGeocoder(environment.GeocoderURL, "veri-kontrol-backend"), cache)
+	case "google":
+		geocoder = tools.NewCachedGeocoder(tools.NewGoogleGeocoder(environment.GoogleMapsKey), cache)
+	case "":
+	default:
+		panic(fmt.Errorf("geocoder must be nominatim or google"))
+	}
+
+	var translator *tools.EntryTranslator
+	switch environment.Translator {
+	case "deepl":
+		translator = tools.NewEntryTranslator(tools.NewDeepLTranslator(environment.TranslatorURL, environment.TranslatorKey), cache)
+	case "google":
+		translator = tools.NewEntryTranslator(tools.NewGoogleTranslator(environment.TranslatorKey), cache)
+	case "":
+	default:
+		panic(fmt.Errorf("translator must be deepl or google"))
+	}
+
+	processedIDs := NewProcessedIDs(make([]int, 0))
 
 	logrus.Infoln("Pulling entries")
-	locs, err := locationRepository.GetLocations(ctx)
+	locs, err := locationRepository.GetLocations(ctx, nil)
 	if err != nil {
 		logrus.Errorf("Couldn't get all locations: %s", err)
 	}
 
 	for _, loc := range locs {
-		processedIDs = append(processedIDs, loc.EntryID)
+		if !loc.IsPendingReview() {
+			processedIDs.Add(loc.EntryID)
+		}
 	}
 
-	logrus.Infoln("Startup complete")
-	app.Use(cors.New())
-
-	adminG := app.Group("/admin", func(c *fiber.Ctx) error {
-		authKey := c.Get("Auth-Key")
+	duplicateDetector := tools.NewDuplicateDetector(locationRepository, environment.DedupThreshold)
+
+	admin := NewAdmin(locationRepository, cache, cityRepository, processedIDs, eventRepository, reasonRepository, typeRepository, auditLog, commentRepository, outboxMessages, masker)
+	export := NewExport(locationRepository, cityRepository, eventRepository)
+	cities := NewCities(cityRepository, auditLog)
+	events := NewEvents(eventRepository, locationRepository, auditLog)
+	preferences := NewPreferences(userRepository, cityRepository, typeRepository)
+	assignments := NewAssignments(userRepository, cityRepository, auditLog)
+	resolutions := NewResolutions(locationRepository, masker)
+	qualityReviews := NewQuality(reviewRepository, auditLog)
+	entryComments := NewComments(commentRepository, auditLog, masker)
+	reasons := NewReasons(reasonRepository)
+	types := NewTypes(typeRepository)
+	stats := NewStats(locationRepository, userRepository, cityRepository, eventRepository, cache, processedIDs)
+	public := NewPublic(locationRepository, cityRepository, eventRepository, userRepository, typeRepository, auditLog)
+	feed := NewFeed(locationRepository, cityRepository, eventRepository)
+	audit := NewAudit(auditLog, masker)
+	// Resolutions queued while Mongo was down are written the way /resolve writes them without a transaction.
+	degradation, err := NewDegradation(mongoClient, environment.WALPath, func(ctx context.Context, resolution *tools.Resolution) error {
+		entry := resolution.Entry
+
+		if err := tools.WriteResolution(ctx, locationRepository, auditLog, outboxMessages, resolution); err != nil {
+			if errors.Is(err, locationsRepository.ErrAlreadyResolved) {
+				logrus.Warnf("Dropping the queued resolution of entry %d, it was resolved in the meantime", entry.EntryID)
+
+				return nil
+			}
 
-		user, err := userRepository.GetUser(ctx, authKey)
-		if err != nil {
-			return c.Status(401).SendString("User not found.")
+			return err
 		}
 
-		if user.PermLevel < usersRepository.PermModerator {
-			return c.Status(401).SendString("You are not allowed to access here.")
+		if entry.Status == locationsRepository.StatusResolved {
+			processedIDs.Add(entry.EntryID)
 		}
 
-		return c.Next()
+		return nil
 	})
+	if err != nil {
+		panic(err)
+	}
 
-	entriesG := adminG.Group("/entries")
+	journal, err := wal.OpenJournal(environment.JournalPath)
+	if err != nil {
+		panic(fmt.Errorf("couldn't open the resolution journal: %w", err))
+	}
 
-	entriesG.Get("", admin.GetLocationEntries)
-	entriesG.Get("/:entry_id", admin.GetSingleEntry)
-	entriesG.Post("/:entry_id", admin.UpdateEntry)
+	health := NewHealth(mongoClient, cache, degradation)
+	keyGrace := defaultKeyGrace
+	if environment.KeyGraceMinutes > 0 {
+		keyGrace = time.Duration(environment.KeyGraceMinutes) * time.Minute
+	}
 
-	app.Get("/monitor", monitor.New())
+	notifications := NewNotifications(notificationRepository, auditLog)
+	webhooks := NewWebhooks(webhookRepository, auditLog)
+	intake := NewIntake(locationRepository, cache)
+	duplicates := NewDuplicates(locationRepository, eventRepository, cache, processedIDs, reasonRepository, typeRepository, duplicateDetector, auditLog, outboxMessages, masker)
+	cacheInvalidator := tools.NewCacheInvalidator(cache, cacheInvalidations)
+	caches := NewCaches(cache, cacheInvalidator, auditLog)
+	importer := NewImporter(locationRepository, reasonRepository, typeRepository, processedIDs, auditLog, outboxMessages)
+	activity := NewActivity(userRepository, locationRepository)
+	if environment.AuthMaxFailures <= 0 {
+		environment.AuthMaxFailures = defaultAuthMaxFailures
+	}
 
-	app.Get("/get-location", func(c *fiber.Ctx) error {
-		locations, err := tools.GetAllLocations(ctx, cache)
-		if err != nil {
-			logrus.Errorln(err)
+	authLockout := defaultAuthLockout
+	if environment.AuthLockoutMinutes > 0 {
+		authLockout = time.Duration(environment.AuthLockoutMinutes) * time.Minute
+	}
 
-			return c.SendString(err.Error())
-		}
+	auth := NewAuth(userRepository, degradation, tokens.NewManager(environment.JWTSecret, tokens.AccessTokenTTL), ratelimit.NewLockout(environment.AuthMaxFailures, authLockout))
+	users := NewUsers(userRepository, auditLog, outboxMessages, auth, environment.TelegramToken != "", keyGrace, environment.MaxPending)
 
-		for _, id := range processedIDs {
-			for i, loc := range locations {
-				if id == loc.EntryID {
-					locations = append(locations[:i], locations[i+1:]...)
+	tools.IntakeSource = locationRepository.GetIntakeEntries
+	tools.ActiveEvent = eventRepository.GetActiveEvent
+	tools.CitySource = cityRepository.GetCities
 
-					continue
-				}
-			}
-		}
+	refreshCtx, stopRefresher := context.WithCancel(ctx)
+	background := &sync.WaitGroup{}
+
+	background.Add(1)
+	go func() {
+		defer background.Done()
 
-		cityID := c.QueryInt("city_id")
-		if cityID > 0 {
-			box := cities[cityID]
+		degradation.Run(refreshCtx, 0)
+	}()
 
-			filteredLocations := make([]*locationsRepository.Location, 0)
+	background.Add(2)
+	go func() {
+		defer background.Done()
 
-			for _, loc := range locations {
-				if box[0] >= loc.Loc[0] && box[1] >= loc.Loc[1] && box[2] <= loc.Loc[0] && box[3] <= loc.Loc[1] {
-					filteredLocations = append(filteredLocations, loc)
-				}
-			}
+		tools.RunLocationRefresher(refreshCtx, cache, time.Duration(environment.RefreshSeconds)*time.Second)
+	}()
+
+	go func() {
+		defer background.Done()
+
+		duplicateDetector.Run(refreshCtx, time.Duration(environment.DedupSeconds)*time.Second)
+	}()
+
+	background.Add(1)
+	go func() {
+		defer background.Done()
+
+		cacheInvalidator.Run(refreshCtx, 0)
+	}()
+
+	background.Add(1)
+	go func() {
+		defer background.Done()
+
+		rotateJournal(refreshCtx, journal, time.Duration(environment.JournalDays)*24*time.Hour)
+	}()
+
+	// Webhook receivers aren't users, what they get is redacted.
+	webhookDispatcher := tools.NewWebhookDispatcher(webhookRepository, func(location *locationsRepository.LocationDB) interface{} {
+		return toPublicLocation(location, redact.Redactor{})
+	})
+
+	background.Add(1)
+	go func() {
+		defer background.Done()
 
-			locations = filteredLocations
+		webhookDispatcher.Run(refreshCtx, time.Duration(environment.WebhookSeconds)*time.Second)
+	}()
+
+	outboxDispatcher := tools.NewOutboxDispatcher(outboxMessages)
+	outboxDispatcher.Handle(tools.TopicLocationResolved, "webhooks", webhookDispatcher.HandleResolved)
+
+	background.Add(1)
+	go func() {
+		defer background.Done()
+
+		activity.Run(refreshCtx, 0)
+	}()
+
+	reaper := tools.NewReaper(locationRepository, time.Duration(environment.PendingMaxHours)*time.Hour)
+
+	background.Add(1)
+	go func() {
+		defer background.Done()
+
+		reaper.Run(refreshCtx, time.Duration(environment.ReapSeconds)*time.Second)
+	}()
+
+	if environment.QASamplePercent > 0 {
+		qualitySampler := tools.NewQualitySampler(reviewRepository, environment.QASamplePercent)
+		outboxDispatcher.Handle(tools.TopicLocationResolved, "quality", qualitySampler.HandleResolved)
+	} else {
+		logrus.Infoln("qa_sample_percent is not set, QA sampling is disabled")
+	}
+
+	if environment.StorageBucket != "" {
+		schedule, err := scheduler.Parse(environment.SnapshotSchedule)
+		if err != nil {
+			panic(err)
 		}
 
-		startingAt := c.QueryInt("starting_at")
-		if startingAt > 0 {
-			filteredLocations := make([]*locationsRepository.Location, 0)
+		snapshots := NewSnapshots(locationRepository, storage.NewS3Bucket(environment.StorageConfig()), environment.SnapshotPrefix)
 
-			for _, loc := range locations {
-				if loc.Epoch >= startingAt {
-					filteredLocations = append(filteredLocations, loc)
-				}
-			}
+		background.Add(1)
+		go func() {
+			defer background.Done()
 
-			locations = filteredLocations
+			snapshots.Run(refreshCtx, schedule)
+		}()
+	} else {
+		logrus.Infoln("storage_bucket is not set, export snapshots are disabled")
+	}
+
+	if environment.SheetsID != "" {
+		credentials, err := os.ReadFile(environment.SheetsKeyFile)
+		if err != nil {
+			panic(fmt.Errorf("couldn't read sheets_credentials_file: %w", err))
 		}
 
-		if len(locations) == 0 {
-			return c.JSON(struct {
-				Count    int                           `json:"count"`
-				Location *locationsRepository.Location `json:"location"`
-			}{
-				Count:    0,
-				Location: nil,
-			})
+		sheet, err := sources.NewSheet(credentials, environment.SheetsID, environment.SheetsTab)
+		if err != nil {
+			panic(err)
 		}
 
-		var selected *locationsRepository.Location
-		fullText := ""
-		processed := make([]int, 0)
-
-		for {
-			randIndex := rand.Intn(len(locations))
-
-			if len(processed) == len(locations) {
-				return c.JSON(struct {
-					Count    int                           `json:"count"`
-					Location *locationsRepository.Location `json:"location"`
-				}{
-					Count:    0,
-					Location: nil,
-				})
-			}
+		sheetSync := tools.NewSheetSync(locationRepository, sheet)
+		outboxDispatcher.Handle(tools.TopicLocationResolved, "sheets", sheetSync.HandleResolved)
 
-			for _, i := range processed {
-				if randIndex == i {
-					continue
-				}
-			}
+		background.Add(1)
+		go func() {
+			defer background.Done()
 
-			processed = append(processed, randIndex)
+			sheetSync.Run(refreshCtx, time.Duration(environment.SheetsSeconds)*time.Second)
+		}()
+	} else {
+		logrus.Infoln("sheets_spreadsheet_id is not set, the coordination sheet sync is disabled")
+	}
 
-			s := locations[randIndex]
+	if environment.TelegramToken != "" {
+		outboxDispatcher.Handle(tools.TopicAlert, "telegram", tools.SendAlert(sources.NewTelegramClient(environment.TelegramToken)))
+		notifier := tools.NewNotifier(cache, notificationRepository, cityRepository, outboxMessages)
 
-			singleData, err := tools.GetSingleLocation(ctx, s.EntryID, cache)
-			if err != nil {
-				logrus.Errorln(err)
+		background.Add(1)
+		go func() {
+			defer background.Done()
 
-				return c.SendString(err.Error())
-			}
+			notifier.Run(refreshCtx, time.Duration(environment.NotifySeconds)*time.Second)
+		}()
+	} else {
+		logrus.Infoln("telegram_token is not set, notifications are disabled")
+	}
 
-			exists, err := locationRepository.IsDuplicate(c.Context(), singleData.FullText)
-			if err != nil {
-				logrus.Errorln(err)
+	background.Add(1)
+	go func() {
+		defer background.Done()
 
-				return c.SendString(err.Error())
-			}
+		outboxDispatcher.Run(refreshCtx, time.Duration(environment.OutboxSeconds)*time.Second)
+	}()
+
+	logrus.Infoln("Startup complete")
+	app.Use(requestid.New())
+	app.Use(requestLogger)
+	app.Use(tracingMiddleware)
+	corsPolicy, err := newCORSPolicy(environment.CORSOrigins, environment.CORSMethods, environment.CORSHeaders)
+	if err != nil {
+		panic(err)
+	}
 
-			if !exists {
-				selected = s
-				fullText = singleData.FullText
+	if corsPolicy.anyOrigin {
+		logrus.Warnln("cors_origins is *, every website can call the API from the browsers of its visitors")
+	}
 
-				break
-			}
-		}
+	app.Use(corsPolicy.Handler)
+	app.Use(secureHeaders(environment.HSTSSeconds, environment.SecureHeaders))
+	app.Use(metricsMiddleware)
+	// gzip or br, whichever the client accepts. Websocket upgrades have no body to compress.
+	app.Use(compress.New(compress.Config{
+		Next: websocket.IsWebSocketUpgrade,
+	}))
+	app.Use(versioning)
+	app.Use(activity.Track)
+
+	if environment.RateLimitUser <= 0 {
+		environment.RateLimitUser = defaultUserRateLimit
+	}
+
+	if environment.RateLimitIP <= 0 {
+		environment.RateLimitIP = defaultIPRateLimit
+	}
+
+	if environment.RateLimitBurst <= 0 {
+		environment.RateLimitBurst = defaultRateBurst
+	}
+
+	limit := rateLimit(
+		ratelimit.New(environment.RateLimitUser, environment.RateLimitBurst),
+		ratelimit.New(environment.RateLimitIP, environment.RateLimitBurst),
+	)
+
+	// Dashboards poll the lists, an unchanged body is answered with 304 and the ETag the client already has. Not
+	// for /get-location(s), a 304 there would hide the entries they just claimed.
+	etags := etag.New()
+
+	authG := app.Group("/auth", limit)
+
+	authG.Post("/login", auth.Login)
+	authG.Post("/refresh", auth.Refresh)
+	authG.Post("/logout", auth.Logout)
+	registerLimiter := ratelimit.New(registerRateLimit, registerRateBurst)
+	authG.Post("/register", rateLimit(registerLimiter, registerLimiter), users.Register)
+
+	// Every route under /admin checks the scope of what it does, the group keeps out everyone below moderators,
+	// API keys and volunteers included, whatever scopes they were given.
+	adminG := app.Group("/admin", auth.RequirePerm(usersRepository.PermModerator), auth.RequireCurrentKey, limit)
+
+	readEntries := auth.RequireScope(usersRepository.ScopeEntriesRead)
+	writeEntries := auth.RequireScope(usersRepository.ScopeEntriesWrite)
+
+	entriesG := adminG.Group("/entries")
+
+	entriesG.Get("", readEntries, etags, admin.GetLocationEntries)
+	entriesG.Get("/near", readEntries, etags, admin.GetNearEntries)
+	entriesG.Get("/clusters", readEntries, etags, admin.GetEntryClusters)
+	entriesG.Get("/:entry_id", readEntries, admin.GetSingleEntry)
+	entriesG.Post("/:entry_id", writeEntries, admin.UpdateEntry)
+	entriesG.Get("/:entry_id/history", readEntries, admin.GetEntryHistory)
+	entriesG.Get("/:entry_id/comments", readEntries, entryComments.GetComments)
+	entriesG.Post("/:entry_id/comments", writeEntries, entryComments.AddComment)
+	entriesG.Post("/:entry_id/rollback/:revision_id", writeEntries, auth.RequirePerm(usersRepository.PermAdmin), admin.RollbackEntry)
+	entriesG.Delete("/:entry_id/resolution", writeEntries, admin.UnresolveEntry)
+	entriesG.Post("/:entry_id/approve", writeEntries, admin.ApproveEntry)
+	entriesG.Delete("/:entry_id", writeEntries, admin.HideEntry)
+	entriesG.Post("/:entry_id/restore", writeEntries, admin.RestoreEntry)
+
+	adminG.Post("/import", writeEntries, auth.RequirePerm(usersRepository.PermAdmin), importer.Import)
+
+	duplicatesG := adminG.Group("/duplicates/clusters")
+
+	duplicatesG.Get("", readEntries, etags, duplicates.GetClusters)
+	duplicatesG.Post("/:entry_id/resolve", writeEntries, duplicates.ResolveCluster)
+
+	exportG := adminG.Group("/export", auth.RequireScope(usersRepository.ScopeExportRun))
+
+	exportG.Get("/geojson", export.GeoJSON)
+	exportG.Get("/csv", export.CSV)
+	exportG.Get("/kml", export.KML)
+
+	statsG := adminG.Group("/stats", auth.RequireScope(usersRepository.ScopeStatsRead))
+
+	statsG.Get("/moderators", stats.GetModeratorStats)
+	statsG.Get("/overview", stats.GetOverview)
+	statsG.Get("/backlog", stats.GetBacklogAge)
+	statsG.Get("/claims", stats.GetClaimStats)
+	statsG.Get("/throughput", stats.GetThroughput)
+	statsG.Get("/quality", qualityReviews.GetQualityStats)
+
+	qaG := adminG.Group("/qa/reviews", auth.RequireScope(usersRepository.ScopeQAReview))
+
+	qaG.Get("", qualityReviews.GetReviews)
+	qaG.Post("/:review_id/grade", qualityReviews.GradeReview)
+
+	citiesG := adminG.Group("/cities")
+
+	// Cities decide which entries volunteers are served, changing them counts as changing entries.
+	citiesG.Get("", readEntries, cities.GetCities)
+	citiesG.Post("", writeEntries, cities.AddCity)
+	citiesG.Put("/:city_id", writeEntries, cities.UpdateCity)
+	citiesG.Delete("/:city_id", writeEntries, cities.DeleteCity)
+
+	app.Get("/cities", limit, cities.GetCities)
+
+	eventsG := adminG.Group("/events", auth.RequirePerm(usersRepository.PermAdmin))
+
+	eventsG.Get("", events.GetEvents)
+	eventsG.Post("", events.AddEvent)
+	eventsG.Put("/:event_id", events.UpdateEvent)
+	eventsG.Delete("/:event_id", events.DeleteEvent)
+	eventsG.Post("/:event_id/activate", events.ActivateEvent)
+
+	app.Get("/events", limit, events.GetEvents)
+
+	meG := app.Group("/me", auth.RequirePerm(usersRepository.PermSubmit), limit)
 
-		selected.OriginalMessage = fullText
-		selected.OriginalLocation = fmt.Sprintf("https://www.google.com/maps/?q=%f,%f&ll=%f,%f&z=21", selected.Loc[0], selected.Loc[1], selected.Loc[0], selected.Loc[1])
+	meG.Get("/preferences", preferences.GetPreferences)
+	meG.Put("/preferences", preferences.SetPreferences)
+	meG.Get("/resolutions", resolutions.GetMyResolutions)
+	meG.Post("/rotate-key", auth.RequireCurrentKey, users.RotateKey)
+	app.Get("/reasons", limit, reasons.GetReasons)
+	app.Get("/location-types", limit, types.GetTypes)
 
-		return c.JSON(struct {
-			Count    int                           `json:"count"`
-			Location *locationsRepository.Location `json:"location"`
-		}{
-			Count:    len(locations),
-			Location: selected,
-		})
+	// The leaderboard names volunteers, only they get to see it.
+	app.Get("/stats/leaderboard", auth.RequirePerm(usersRepository.PermSubmit), limit, stats.GetLeaderboard)
+	app.Put("/stats/leaderboard/opt-out", auth.RequirePerm(usersRepository.PermSubmit), limit, stats.SetLeaderboardOptOut)
+
+	adminG.Post("/api-keys", auth.RequireScope(usersRepository.ScopeUsersManage), public.CreateAPIKey)
+
+	usersG := adminG.Group("/users", auth.RequireScope(usersRepository.ScopeUsersManage))
+
+	usersG.Get("", users.ListUsers)
+	usersG.Get("/active", activity.GetActiveUsers)
+	usersG.Get("/pending", users.ListPendingUsers)
+	usersG.Get("/auth-failures", auth.GetAuthFailures)
+	usersG.Post("/:user_id/approve", users.ApproveUser)
+	usersG.Post("/:user_id/reject", users.RejectUser)
+	usersG.Post("", users.CreateUser)
+	usersG.Patch("/:user_id", users.UpdateUser)
+	usersG.Delete("/:user_id", users.DeactivateUser)
+
+	assignmentsG := adminG.Group("/assignments", auth.RequireScope(usersRepository.ScopeAssignmentsManage))
+
+	assignmentsG.Get("", assignments.GetAssignments)
+	assignmentsG.Put("/:user_id", assignments.SetAssignment)
+	assignmentsG.Delete("/:user_id", assignments.DeleteAssignment)
+
+	notificationsG := adminG.Group("/notifications/rules", auth.RequirePerm(usersRepository.PermAdmin))
+
+	notificationsG.Get("", notifications.GetRules)
+	notificationsG.Post("", notifications.AddRule)
+	notificationsG.Delete("/:rule_id", notifications.DeleteRule)
+
+	webhooksG := adminG.Group("/webhooks", auth.RequirePerm(usersRepository.PermAdmin))
+
+	webhooksG.Get("", webhooks.GetWebhooks)
+	webhooksG.Post("", webhooks.AddWebhook)
+	webhooksG.Delete("/:webhook_id", webhooks.DeleteWebhook)
+	webhooksG.Get("/:webhook_id/deliveries", webhooks.GetDeliveries)
+
+	outbox := NewOutbox(outboxMessages, auditLog)
+	outboxG := adminG.Group("/outbox", auth.RequirePerm(usersRepository.PermAdmin))
+
+	outboxG.Get("", outbox.GetMessages)
+	outboxG.Post("/:message_id/retry", outbox.RetryMessage)
+
+	cacheG := adminG.Group("/cache", auth.RequirePerm(usersRepository.PermAdmin))
+
+	cacheG.Post("/invalidate", caches.Invalidate)
+	cacheG.Post("/warm", caches.Warm)
+
+	adminG.Get("/audit", auth.RequirePerm(usersRepository.PermAdmin), audit.GetEvents)
+
+	app.Post("/intake/entries", auth.RequirePerm(usersRepository.PermSubmit), limit, intake.AddEntry)
+
+	publicG := app.Group("/v1/public", auth.RequirePerm(usersRepository.PermReadOnly), limit)
+
+	publicG.Get("/resolved", public.GetResolved)
+
+	app.Get("/ws/resolved", auth.RequirePerm(usersRepository.PermReadOnly), feed.Upgrade, websocket.New(feed.Resolved))
+
+	app.Get("/healthz", health.Healthz)
+	app.Get("/readyz", health.Readyz)
+
+	app.Get("/monitor", monitor.New())
+	app.Get("/metrics", Metrics)
+
+	apiDoc := buildOpenAPI()
+	serveSpec, serveDocs := OpenAPIHandlers(apiDoc)
+
+	app.Get("/openapi.json", serveSpec)
+	app.Get("/docs", serveDocs)
+
+	// The dev routes don't ask for a user, seeding is how the first local admin is made. They answer the
+	// requests which carry the dev_token, or local ones when there is none.
+	if environment.DevMode {
+		logrus.Warnln("dev_mode is on, /dev/seed fills the database with fake data")
+
+		dev := NewDev(&tools.Seeder{
+			Locations: locationRepository,
+			Users:     userRepository,
+			Cities:    cityRepository,
+			Reasons:   reasonRepository,
+			Types:     typeRepository,
+		}, cache)
+
+		app.Post("/dev/seed", devAccess(environment.DevToken), limit, dev.Seed)
+	}
+
+	registerServing(app, auth, limit, idempotencyKeys, &Serving{
+		Environment:   environment,
+		Locations:     locationRepository,
+		Users:         userRepository,
+		Cities:        cityRepository,
+		Reasons:       reasonRepository,
+		Types:         typeRepository,
+		Audit:         auditLog,
+		Outbox:        outboxMessages,
+		Transactions:  mongoClient,
+		Cache:         cache,
+		Degradation:   degradation,
+		Processed:     processedIDs,
+		Duplicates:    duplicateDetector,
+		Translator:    translator,
+		Geocoder:      geocoder,
+		Masker:        masker,
+		TextFilter:    textFilter,
+		Journal:       journal,
+		Strategy:      defaultStrategy,
+		ClaimDuration: claimDuration,
 	})
 
-	app.Post("/resolve", func(c *fiber.Ctx) error {
-		body := &ResolveBody{}
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, syscall.SIGINT)
+	signal.Notify(c, syscall.SIGTERM)
+
+	shutdownTimeout := defaultShutdownTimeout
+	if environment.ShutdownSeconds > 0 {
+		shutdownTimeout = time.Duration(environment.ShutdownSeconds) * time.Second
+	}
+
+	var (
+		tlsConfig      *tls.Config
+		redirectServer *http.Server
+	)
 
-		if err := json.Unmarshal(c.Body(), body); err != nil {
-			logrus.Errorln(err)
+	if environment.TLSEnabled() {
+		var redirect http.Handler
 
-			return c.SendString(err.Error())
+		if tlsConfig, redirect, err = newTLS(environment); err != nil {
+			panic(err)
 		}
 
-		for _, id := range processedIDs {
-			if body.ID == id {
-				return c.SendString("this location is already checked")
+		if environment.HTTPRedirectPort > 0 {
+			redirectServer = newRedirectServer(environment.HTTPRedirectPort, redirect)
+
+			go func() {
+				if err := redirectServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+					logrus.Errorf("HTTP redirect server failed: %s", err)
+				}
+			}()
+		}
+	}
+
+	shutdownDone := make(chan struct{})
+
+	go func() {
+		_ = <-c
+		fmt.Println("application gracefully shutting down..")
+		defer close(shutdownDone)
+
+		health.SetReady(false)
+		stopRefresher()
+
+		// Listen stops accepting connections right away, in-flight requests get until the timeout to finish.
+		if err := app.ShutdownWithTimeout(shutdownTimeout); err != nil {
+			logrus.Errorf("Couldn't drain connections: %s", err)
+		}
+
+		if redirectServer != nil {
+			redirectCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+			if err := redirectServer.Shutdown(redirectCtx); err != nil {
+				logrus.Errorf("Couldn't stop the HTTP redirect server: %s", err)
 			}
+			cancel()
 		}
 
-		locations, err := tools.GetAllLocations(ctx, cache)
-		if err != nil {
-			logrus.Errorln(err)
+		waitTimeout(background, shutdownTimeout)
 
-			return c.SendString(err.Error())
+		if err := degradation.Close(); err != nil {
+			logrus.Errorf("Couldn't close the resolution log: %s", err)
 		}
 
-		originalLocation := ""
-		location := make([]float64, 0)
+		if err := journal.Close(); err != nil {
+			logrus.Errorf("Couldn't close the resolution journal: %s", err)
+		}
 
-		for _, loc := range locations {
-			if loc.EntryID == body.ID {
-				originalLocation = fmt.Sprintf("https://www.google.com/maps/?q=%f,%f&ll=%f,%f&z=21", loc.Loc[0], loc.Loc[1], loc.Loc[0], loc.Loc[1])
-				location = loc.Loc
-			}
+		cache.Wait()
+		if err := cache.Close(); err != nil {
+			logrus.Errorf("Couldn't close cache: %s", err)
 		}
 
-		var sender *usersRepository.User
+		disconnectCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+
+		if err := mongoClient.Disconnect(disconnectCtx); err != nil {
+			logrus.Errorf("Couldn't disconnect from Mongo: %s", err)
+		}
 
-		authKey := c.Get("Auth-Key")
-		userData, err := userRepository.GetUser(c.Context(), authKey)
-		if err == nil {
-			sender = userData
+		if err := shutdownTracing(disconnectCtx); err != nil {
+			logrus.Errorf("Couldn't flush traces: %s", err)
 		}
+	}()
+
+	checkOpenAPI(app, apiDoc)
+	health.SetReady(true)
 
-		if err := locationRepository.ResolveLocation(ctx, &locationsRepository.LocationDB{
-			ID:               primitive.NewObjectIDFromTimestamp(time.Now()),
-			EntryID:          body.ID,
-			Type:             body.LocationType,
-			Location:         location,
-			Corrected:        body.Reason == "Hata Yok",
-			OriginalAddress:  originalLocation,
-			CorrectedAddress: body.NewAddress,
-			Reason:           body.Reason,
-			Sender:           sender,
-			OpenAddress:      body.OpenAddress,
-			Apartment:        body.Apartment,
-			TweetContents:    body.TweetContents,
-		}); err != nil {
-			logrus.Errorln(err)
-
-			return c.SendString(err.Error())
+	addr := fmt.Sprintf(":%d", environment.Port)
+
+	if tlsConfig == nil {
+		err = app.Listen(addr)
+	} else {
+		var ln net.Listener
+		if ln, err = net.Listen("tcp", addr); err == nil {
+			err = app.Listener(tls.NewListener(ln, tlsConfig))
 		}
+	}
 
-		processedIDs = append(processedIDs, body.ID)
+	if err != nil {
+		panic(fmt.Sprintf("app error: %s", err.Error()))
+	}
 
-		return c.SendString("Successfully added!")
-	})
+	<-shutdownDone
+}
 
-	c := make(chan os.Signal, 1)
-	signal.Notify(c, syscall.SIGINT)
-	signal.Notify(c, syscall.SIGTERM)
+const defaultShutdownTimeout = 30 * time.Second
+
+// waitTimeout gives up on goroutines which don't notice the cancellation in time, the process exits anyway.
+func waitTimeout(wg *sync.WaitGroup, timeout time.Duration) {
+	done := make(chan struct{})
 
 	go func() {
-		_ = <-c
-		fmt.Println("application gracefully shutting down..")
-		_ = app.Shutdown()
+		wg.Wait()
+		close(done)
 	}()
 
-	if err := app.Listen(":80"); err != nil {
-		panic(fmt.Sprintf("app error: %s", err.Error()))
+	select {
+	case <-done:
+	case <-time.After(timeout):
+		logrus.Warnln("Background workers didn't stop in time")
 	}
 }
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"github.com/YusufOzmen01/veri-kontrol-backend/core/textfilter"
+	auditRepository "github.com/YusufOzmen01/veri-kontrol-backend/repository/audit"
+	commentsRepository "github.com/YusufOzmen01/veri-kontrol-backend/repository/comments"
+	locationsRepository "github.com/YusufOzmen01/veri-kontrol-backend/repository/locations"
+	"github.com/YusufOzmen01/veri-kontrol-backend/tools"
+	"github.com/gofiber/fiber/v2"
+)
+
+// Masker filters every text a response shows, the blocked words always and the phone and ID numbers unless the
+// user can see PII. Everything is filtered on a copy, the entries may be shared with the cache.
+type Masker struct {
+	filter *textfilter.Filter
+}
+
+func NewMasker(filter *textfilter.Filter) *Masker {
+	return &Masker{filter: filter}
+}
+
+func (m *Masker) Text(c *fiber.Ctx, text string) string {
+	return m.filter.Apply(text, canSeePII(c))
+}
+
+func (m *Masker) Entry(c *fiber.Ctx, entry *locationsRepository.LocationDB) *locationsRepository.LocationDB {
+	if entry == nil {
+		return nil
+	}
+
+	masked := *entry
+	masked.TweetContents = m.Text(c, entry.TweetContents)
+	if !canSeePII(c) {
+		masked.PhoneNumbers = nil
+	}
+
+	return &masked
+}
+
+func (m *Masker) Entries(c *fiber.Ctx, entries []*locationsRepository.LocationDB) []*locationsRepository.LocationDB {
+	masked := make([]*locationsRepository.LocationDB, 0, len(entries))
+	for _, entry := range entries {
+		masked = append(masked, m.Entry(c, entry))
+	}
+
+	return masked
+}
+
+// Changes masks the diffs of recorded edits, they hold the texts and phone numbers from before and after.
+func (m *Masker) Changes(c *fiber.Ctx, changes []*auditRepository.Change) []*auditRepository.Change {
+	masked := make([]*auditRepository.Change, 0, len(changes))
+	for _, change := range changes {
+		ch := *change
+
+		switch change.Field {
+		case "tweet_contents":
+			if before, ok := change.Before.(string); ok {
+				ch.Before = m.Text(c, before)
+			}
+
+			if after, ok := change.After.(string); ok {
+				ch.After = m.Text(c, after)
+			}
+		case "phone_numbers":
+			if !canSeePII(c) {
+				ch.Before, ch.After = nil, nil
+			}
+		}
+
+		masked = append(masked, &ch)
+	}
+
+	return masked
+}
+
+func (m *Masker) Events(c *fiber.Ctx, events []*auditRepository.Event) []*auditRepository.Event {
+	masked := make([]*auditRepository.Event, 0, len(events))
+	for _, event := range events {
+		e := *event
+		e.Changes = m.Changes(c, event.Changes)
+		masked = append(masked, &e)
+	}
+
+	return masked
+}
+
+func (m *Masker) SenderResolutions(c *fiber.Ctx, resolutions []*locationsRepository.SenderResolution) []*locationsRepository.SenderResolution {
+	masked := make([]*locationsRepository.SenderResolution, 0, len(resolutions))
+	for _, resolution := range resolutions {
+		r := *resolution
+		r.Location = m.Entry(c, resolution.Location)
+		masked = append(masked, &r)
+	}
+
+	return masked
+}
+
+func (m *Masker) Revisions(c *fiber.Ctx, revisions []*locationsRepository.Revision) []*locationsRepository.Revision {
+	masked := make([]*locationsRepository.Revision, 0, len(revisions))
+	for _, revision := range revisions {
+		r := *revision
+		r.Before = m.Entry(c, revision.Before)
+		r.After = m.Entry(c, revision.After)
+		r.Changes = m.Changes(c, revision.Changes)
+		masked = append(masked, &r)
+	}
+
+	return masked
+}
+
+func (m *Masker) Clusters(c *fiber.Ctx, clusters []*tools.Cluster) []*tools.Cluster {
+	masked := make([]*tools.Cluster, 0, len(clusters))
+	for _, cluster := range clusters {
+		cl := *cluster
+		cl.Text = m.Text(c, cluster.Text)
+		masked = append(masked, &cl)
+	}
+
+	return masked
+}
+
+// Comment is filtered too, moderators may have copied a number out of the text.
+func (m *Masker) Comment(c *fiber.Ctx, comment *commentsRepository.Comment) *commentsRepository.Comment {
+	masked := *comment
+	masked.Text = m.Text(c, comment.Text)
+
+	return &masked
+}
+
+func (m *Masker) Comments(c *fiber.Ctx, comments []*commentsRepository.Comment) []*commentsRepository.Comment {
+	masked := make([]*commentsRepository.Comment, 0, len(comments))
+	for _, comment := range comments {
+		masked = append(masked, m.Comment(c, comment))
+	}
+
+	return masked
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/YusufOzmen01/veri-kontrol-backend/core/textfilter"
+	auditRepository "github.com/YusufOzmen01/veri-kontrol-backend/repository/audit"
+	commentsRepository "github.com/YusufOzmen01/veri-kontrol-backend/repository/comments"
+	locationsRepository "github.com/YusufOzmen01/veri-kontrol-backend/repository/locations"
+	usersRepository "github.com/YusufOzmen01/veri-kontrol-backend/repository/users"
+	"github.com/YusufOzmen01/veri-kontrol-backend/tools"
+	"github.com/gofiber/fiber/v2"
+	"github.com/valyala/fasthttp"
+)
+
+func TestMasker(t *testing.T) {
+	app := fiber.New()
+	masker := NewMasker(textfilter.New(nil))
+
+	const text = "Enkaz altındayız, 0532 123 45 67"
+
+	entry := &locationsRepository.LocationDB{EntryID: 1, TweetContents: text, PhoneNumbers: []string{"05321234567"}}
+	revision := &locationsRepository.Revision{
+		EntryID: 1,
+		After:   entry,
+		Changes: []*auditRepository.Change{{Field: "tweet_contents", After: text}, {Field: "phone_numbers", After: entry.PhoneNumbers}},
+	}
+
+	tests := []struct {
+		name    string
+		scopes  []string
+		showPII bool
+	}{
+		{"moderator", []string{usersRepository.ScopeEntriesRead}, false},
+		{"with pii:read", []string{usersRepository.ScopeEntriesRead, usersRepository.ScopePIIRead}, true},
+	}
+
+	for _, tt := range tests {
+		c := app.AcquireCtx(&fasthttp.RequestCtx{})
+		c.Locals("user", &usersRepository.User{PermLevel: usersRepository.PermModerator, Scopes: tt.scopes})
+
+		texts := map[string]string{
+			"entry":    masker.Entry(c, entry).TweetContents,
+			"revision": masker.Revisions(c, []*locationsRepository.Revision{revision})[0].Changes[0].After.(string),
+			"cluster":  masker.Clusters(c, []*tools.Cluster{{Text: text}})[0].Text,
+			"comment":  masker.Comment(c, &commentsRepository.Comment{Text: text}).Text,
+		}
+
+		for kind, masked := range texts {
+			if shown := strings.Contains(masked, "0532 123 45 67"); shown != tt.showPII {
+				t.Errorf("%s: %s text %q", tt.name, kind, masked)
+			}
+		}
+
+		if shown := masker.Entry(c, entry).PhoneNumbers != nil; shown != tt.showPII {
+			t.Errorf("%s: phone numbers shown %t", tt.name, shown)
+		}
+
+		app.ReleaseCtx(c)
+	}
+
+	// The entry may be shared with the cache, it is never changed.
+	if entry.TweetContents != text || len(entry.PhoneNumbers) != 1 || revision.Changes[0].After != text {
+		t.Errorf("the original entry was changed: %+v", entry)
+	}
+}
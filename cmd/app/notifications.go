@@ -0,0 +1,91 @@
+package main
+
+import (
+	"encoding/json"
+
+	auditRepository "github.com/YusufOzmen01/veri-kontrol-backend/repository/audit"
+	"github.com/YusufOzmen01/veri-kontrol-backend/repository/notifications"
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type Notifications interface {
+	GetRules(c *fiber.Ctx) error
+	AddRule(c *fiber.Ctx) error
+	DeleteRule(c *fiber.Ctx) error
+}
+
+type notificationRules struct {
+	rules notifications.Repository
+	audit auditRepository.Repository
+}
+
+func NewNotifications(rules notifications.Repository, auditLog auditRepository.Repository) Notifications {
+	return &notificationRules{
+		rules: rules,
+		audit: auditLog,
+	}
+}
+
+func (n *notificationRules) GetRules(c *fiber.Ctx) error {
+	rules, err := n.rules.GetRules(c.Context())
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(rules)
+}
+
+func (n *notificationRules) AddRule(c *fiber.Ctx) error {
+	rule := &notifications.Rule{}
+
+	if err := json.Unmarshal(c.Body(), rule); err != nil {
+		return badRequest(err.Error())
+	}
+
+	if err := rule.Validate(); err != nil {
+		return badRequest(err.Error())
+	}
+
+	rule.CreatedBy = currentUser(c)
+
+	if err := n.rules.AddRule(c.Context(), rule); err != nil {
+		return err
+	}
+
+	recordAudit(c, n.audit, &auditRepository.Event{
+		Action:  auditRepository.ActionRuleAdd,
+		Target:  rule.ID.Hex(),
+		Changes: auditRepository.Diff(nil, rule),
+	})
+
+	return c.JSON(rule)
+}
+
+func (n *notificationRules) DeleteRule(c *fiber.Ctx) error {
+	ruleID, err := primitive.ObjectIDFromHex(c.Params("rule_id"))
+	if err != nil {
+		return badRequest("invalid rule_id")
+	}
+
+	before, err := n.rules.GetRule(c.Context(), ruleID)
+	if err != nil {
+		if err == notifications.ErrRuleNotFound {
+			return notFound(err.Error())
+		}
+
+		return err
+	}
+
+	if err := n.rules.DeleteRule(c.Context(), ruleID); err != nil {
+		return err
+	}
+
+	recordAudit(c, n.audit, &auditRepository.Event{
+		Action:  auditRepository.ActionRuleDelete,
+		Target:  ruleID.Hex(),
+		Changes: auditRepository.Diff(before, nil),
+	})
+
+	return c.SendString("Successfully deleted!")
+}
@@ -0,0 +1,599 @@
+package main
+
+import (
+	"encoding/json"
+
+	"github.com/YusufOzmen01/veri-kontrol-backend/core/openapi"
+	citiesRepository "github.com/YusufOzmen01/veri-kontrol-backend/repository/cities"
+	commentsRepository "github.com/YusufOzmen01/veri-kontrol-backend/repository/comments"
+	eventsRepository "github.com/YusufOzmen01/veri-kontrol-backend/repository/events"
+	"github.com/YusufOzmen01/veri-kontrol-backend/repository/locations"
+	"github.com/YusufOzmen01/veri-kontrol-backend/repository/notifications"
+	outboxRepository "github.com/YusufOzmen01/veri-kontrol-backend/repository/outbox"
+	reasonsRepository "github.com/YusufOzmen01/veri-kontrol-backend/repository/reasons"
+	typesRepository "github.com/YusufOzmen01/veri-kontrol-backend/repository/types"
+	usersRepository "github.com/YusufOzmen01/veri-kontrol-backend/repository/users"
+	webhooksRepository "github.com/YusufOzmen01/veri-kontrol-backend/repository/webhooks"
+	"github.com/gofiber/fiber/v2"
+	"github.com/sirupsen/logrus"
+)
+
+// Routes which aren't part of the API contract and are left out of the spec on purpose.
+var undocumentedRoutes = map[string]bool{
+	"/monitor":      true,
+	"/metrics":      true,
+	"/openapi.json": true,
+	"/docs":         true,
+	"/dev/seed":     true,
+}
+
+var (
+	authenticated = []map[string][]string{{"bearerAuth": {}}, {"authKey": {}}, {"apiKey": {}}}
+	anonymous     = append([]map[string][]string{{}}, authenticated...)
+)
+
+func queryParam(name, schemaType, description string) *openapi.Parameter {
+	return &openapi.Parameter{
+		Name:        name,
+		In:          "query",
+		Description: description,
+		Schema:      &openapi.Schema{Type: schemaType},
+	}
+}
+
+func pageParams() []*openapi.Parameter {
+	return []*openapi.Parameter{
+		queryParam("page", "integer", "1 based page number"),
+		queryParam("page_size", "integer", "Entries per page, at most 500"),
+	}
+}
+
+func fieldsParam() *openapi.Parameter {
+	return queryParam("fields", "string", "Comma separated fields to return, every field by default")
+}
+
+func entryFilterParams() []*openapi.Parameter {
+	return []*openapi.Parameter{
+		queryParam("event_id", "string", "The active event by default"),
+		queryParam("reason", "string", ""),
+		queryParam("status", "string", "resolved or pending_review"),
+		queryParam("type", "integer", ""),
+		queryParam("corrected", "boolean", ""),
+		queryParam("sender_id", "string", ""),
+		queryParam("city_id", "integer", ""),
+		queryParam("geohash", "string", "Geohash prefix, a shorter one is a bigger area"),
+		queryParam("epoch_from", "integer", "Unix time the tweet was sent"),
+		queryParam("epoch_to", "integer", "Unix time the tweet was sent"),
+		queryParam("resolved_from", "integer", "Unix time of the resolution"),
+		queryParam("resolved_to", "integer", "Unix time of the resolution"),
+	}
+}
+
+func responses(doc *openapi.Document, v interface{}) map[string]*openapi.Response {
+	ok := &openapi.Response{Description: "OK"}
+	if v != nil {
+		ok.Content = doc.JSON(v)
+	}
+
+	return map[string]*openapi.Response{
+		"200":     ok,
+		"default": {Description: "Error", Content: doc.JSON(&APIError{})},
+	}
+}
+
+// conditionalResponses are for the routes which send an ETag, If-None-Match with the same tag gets a 304.
+func conditionalResponses(doc *openapi.Document, v interface{}) map[string]*openapi.Response {
+	r := responses(doc, v)
+	r["304"] = &openapi.Response{Description: "Not Modified"}
+
+	return r
+}
+
+func body(doc *openapi.Document, v interface{}) *openapi.RequestBody {
+	return &openapi.RequestBody{
+		Required: true,
+		Content:  doc.JSON(v),
+	}
+}
+
+// buildOpenAPI describes every route from the same structs the handlers decode and encode.
+func buildOpenAPI() *openapi.Document {
+	doc := openapi.New("Veri Kontrol API", "1.0.0")
+	doc.Info.Description = "Every path is also served under /v1, where the responses are wrapped in " +
+		"{\"data\", \"meta\", \"error\"} envelopes. The schemas below describe data. Sending API-Version: 1 " +
+		"asks for the envelope on the unversioned paths too, /v1/public keeps its own format."
+
+	doc.Components.SecuritySchemes["bearerAuth"] = &openapi.SecurityScheme{Type: "http", Scheme: "bearer"}
+	doc.Components.SecuritySchemes["authKey"] = &openapi.SecurityScheme{Type: "apiKey", In: "header", Name: "Auth-Key"}
+	doc.Components.SecuritySchemes["apiKey"] = &openapi.SecurityScheme{Type: "apiKey", In: "header", Name: "X-API-Key"}
+
+	add := func(method, path, tag, summary string, security []map[string][]string, op *openapi.Operation) {
+		op.Summary = summary
+		op.Tags = []string{tag}
+		op.Security = security
+		doc.Add(method, path, op)
+	}
+
+	add("POST", "/auth/login", "auth", "Exchange an Auth-Key for tokens", nil, &openapi.Operation{
+		RequestBody: body(doc, &LoginBody{}),
+		Responses:   responses(doc, &TokenResponse{}),
+	})
+	add("POST", "/auth/refresh", "auth", "Rotate the refresh token", nil, &openapi.Operation{
+		RequestBody: body(doc, &RefreshBody{}),
+		Responses:   responses(doc, &TokenResponse{}),
+	})
+	add("POST", "/auth/logout", "auth", "Revoke one or all refresh tokens", nil, &openapi.Operation{
+		RequestBody: body(doc, &RefreshBody{}),
+		Responses:   responses(doc, nil),
+	})
+	add("POST", "/auth/register", "auth", "Sign up, the returned Auth-Key works once an admin approves the account", nil, &openapi.Operation{
+		RequestBody: body(doc, &RegisterBody{}),
+		Responses: map[string]*openapi.Response{
+			"201":     {Description: "Created", Content: doc.JSON(&RegisterResponse{})},
+			"429":     {Description: "Too many registrations from this IP", Content: doc.JSON(&APIError{})},
+			"503":     {Description: "Too many registrations are waiting for approval", Content: doc.JSON(&APIError{})},
+			"default": {Description: "Error", Content: doc.JSON(&APIError{})},
+		},
+	})
+
+	add("GET", "/get-location", "volunteer", "Claim the next entry to check, anonymously only while nobody is assigned to cities", anonymous, &openapi.Operation{
+		Parameters: []*openapi.Parameter{
+			queryParam("city_id", "integer", "0 serves entries outside every city"),
+			queryParam("other", "boolean", "Same as city_id=0"),
+			queryParam("starting_at", "integer", "Only entries sent after this unix time"),
+			queryParam("strategy", "string", "random, fifo, priority or score"),
+			queryParam("preferences", "boolean", "false ignores the preferred cities of the user"),
+			queryParam("assignments", "boolean", "false lets admins ignore the cities they are assigned to"),
+			queryParam("lang", "string", "tr, ku, ar or en, without it the languages in the preferences of the user are served"),
+			queryParam("coord_suspect", "string", "exclude leaves out entries with suspicious coordinates, first serves them before the others"),
+		},
+		Responses: responses(doc, &GetLocationResponse{}),
+	})
+	add("GET", "/get-locations", "volunteer", "Claim several entries at once, anonymously only while nobody is assigned to cities", anonymous, &openapi.Operation{
+		Parameters: []*openapi.Parameter{
+			queryParam("count", "integer", "How many entries to claim, 1 to 20, 10 by default"),
+			queryParam("city_id", "integer", "0 serves entries outside every city"),
+			queryParam("other", "boolean", "Same as city_id=0"),
+			queryParam("starting_at", "integer", "Only entries sent after this unix time"),
+			queryParam("strategy", "string", "random, fifo, priority or score"),
+			queryParam("preferences", "boolean", "false ignores the preferred cities of the user"),
+			queryParam("assignments", "boolean", "false lets admins ignore the cities they are assigned to"),
+			queryParam("lang", "string", "tr, ku, ar or en, without it the languages in the preferences of the user are served"),
+			queryParam("coord_suspect", "string", "exclude leaves out entries with suspicious coordinates, first serves them before the others"),
+		},
+		Responses: responses(doc, &GetLocationsResponse{}),
+	})
+	resolveResponses := responses(doc, nil)
+	resolveResponses["202"] = &openapi.Response{Description: "The database is down, the resolution is queued and saved once it is back"}
+	add("POST", "/resolve", "volunteer", "Submit a checked entry", anonymous, &openapi.Operation{
+		Parameters: []*openapi.Parameter{{
+			Name:        headerIdempotencyKey,
+			In:          "header",
+			Description: "Retries with the same key within 24 hours get the original response back, a retry while the first request is still running gets a 409 for up to a minute",
+			Schema:      &openapi.Schema{Type: "string"},
+		}, queryParam("dry_run", "boolean", "Validate and return a ResolveDryRunResponse of what would be stored without writing it")},
+		RequestBody: body(doc, &ResolveBody{}),
+		Responses:   resolveResponses,
+	})
+	add("POST", "/resolve/bulk", "volunteer", "Resolve many entries with the same reason", authenticated, &openapi.Operation{
+		RequestBody: body(doc, &BulkResolveBody{}),
+		Responses:   responses(doc, &BulkResolveResponse{}),
+	})
+	add("POST", "/release/:entry_id", "volunteer", "Give a claimed entry back", anonymous, &openapi.Operation{
+		Responses: responses(doc, nil),
+	})
+	add("GET", "/cities", "volunteer", "List the cities", nil, &openapi.Operation{
+		Responses: responses(doc, []*citiesRepository.City{}),
+	})
+	add("GET", "/me/preferences", "volunteer", "The cities and types the current user prefers", authenticated, &openapi.Operation{
+		Responses: responses(doc, &usersRepository.Preferences{}),
+	})
+	add("PUT", "/me/preferences", "volunteer", "Replace the preferences, /get-location serves the preferred cities first", authenticated, &openapi.Operation{
+		RequestBody: body(doc, &PreferencesBody{}),
+		Responses:   responses(doc, &usersRepository.Preferences{}),
+	})
+	add("GET", "/me/resolutions", "volunteer", "The resolutions of the current user, newest first", authenticated, &openapi.Operation{
+		Parameters: append(pageParams(), queryParam("status", "string", "approved, pending_review or rolled_back")),
+		Responses:  responses(doc, &MyResolutionsResponse{}),
+	})
+	add("POST", "/me/rotate-key", "volunteer", "Replace the Auth-Key, the old one keeps working for a grace period but can't log in, rotate again or use /admin", authenticated, &openapi.Operation{
+		Responses: responses(doc, &RotateKeyResponse{}),
+	})
+	add("GET", "/events", "volunteer", "List the disasters, the active one is served by default", nil, &openapi.Operation{
+		Responses: responses(doc, []*eventsRepository.Event{}),
+	})
+	add("GET", "/reasons", "volunteer", "List the resolution reasons", nil, &openapi.Operation{
+		Responses: responses(doc, []*reasonsRepository.Reason{}),
+	})
+	add("GET", "/location-types", "volunteer", "List the location types", nil, &openapi.Operation{
+		Responses: responses(doc, []*typesRepository.LocationType{}),
+	})
+
+	add("GET", "/stats/leaderboard", "volunteer", "Volunteers ranked by resolutions, spam reports don't count", authenticated, &openapi.Operation{
+		Parameters: []*openapi.Parameter{
+			queryParam("period", "string", "day, week or all, week by default"),
+			queryParam("limit", "integer", "At most 500, volunteers tied with the last one are listed as well"),
+			queryParam("event_id", "string", "The active event by default"),
+		},
+		Responses: responses(doc, &LeaderboardResponse{}),
+	})
+	add("PUT", "/stats/leaderboard/opt-out", "volunteer", "Hide or show yourself on the leaderboard", authenticated, &openapi.Operation{
+		RequestBody: body(doc, &LeaderboardOptOutBody{}),
+		Responses:   responses(doc, nil),
+	})
+
+	add("GET", "/admin/entries", "admin", "List resolutions", authenticated, &openapi.Operation{
+		Parameters: append(append(entryFilterParams(), pageParams()...), fieldsParam()),
+		Responses:  conditionalResponses(doc, &EntriesResponse{}),
+	})
+	add("GET", "/admin/entries/near", "admin", "List resolutions around a point", authenticated, &openapi.Operation{
+		Parameters: append(append([]*openapi.Parameter{
+			queryParam("lat", "number", ""),
+			queryParam("lng", "number", ""),
+			queryParam("radius", "integer", "Metres, 1000 by default"),
+		}, entryFilterParams()...), append(pageParams(), fieldsParam())...),
+		Responses: conditionalResponses(doc, &EntriesResponse{}),
+	})
+	add("GET", "/admin/entries/clusters", "admin", "Unresolved entries grouped into geohash cells for a heatmap", authenticated, &openapi.Operation{
+		Parameters: []*openapi.Parameter{
+			queryParam("zoom", "integer", "Web map zoom level 0 to 20, 8 by default"),
+			queryParam("event_id", "string", "The active event by default"),
+		},
+		Responses: conditionalResponses(doc, &EntryClustersResponse{}),
+	})
+	add("GET", "/admin/entries/:entry_id", "admin", "Get a resolution", authenticated, &openapi.Operation{
+		Parameters: []*openapi.Parameter{fieldsParam()},
+		Responses:  responses(doc, &SingleEntryResponse{}),
+	})
+	add("POST", "/admin/entries/:entry_id", "admin", "Overwrite a resolution", authenticated, &openapi.Operation{
+		RequestBody: body(doc, &ResolveBody{}),
+		Responses:   responses(doc, &UpdateEntryResponse{}),
+	})
+	add("GET", "/admin/entries/:entry_id/history", "admin", "Earlier versions of a resolution, newest first", authenticated, &openapi.Operation{
+		Responses: responses(doc, &EntryHistoryResponse{}),
+	})
+	add("GET", "/admin/entries/:entry_id/comments", "admin", "Internal notes of the moderators on an entry, oldest first", authenticated, &openapi.Operation{
+		Responses: responses(doc, &CommentsResponse{}),
+	})
+	add("POST", "/admin/entries/:entry_id/comments", "admin", "Leave an internal note on an entry", authenticated, &openapi.Operation{
+		RequestBody: body(doc, &CommentBody{}),
+		Responses:   responses(doc, &commentsRepository.Comment{}),
+	})
+	add("POST", "/admin/entries/:entry_id/rollback/:revision_id", "admin", "Undo a revision by restoring the version it replaced", authenticated, &openapi.Operation{
+		Parameters: []*openapi.Parameter{{Name: "revision_id", In: "path", Required: true, Schema: &openapi.Schema{Type: "string"}}},
+		Responses:  responses(doc, &locations.LocationDB{}),
+	})
+	add("DELETE", "/admin/entries/:entry_id/resolution", "admin", "Take a resolution back", authenticated, &openapi.Operation{
+		Responses: responses(doc, nil),
+	})
+	add("POST", "/admin/entries/:entry_id/approve", "admin", "Approve a resolution pending review", authenticated, &openapi.Operation{
+		Responses: responses(doc, nil),
+	})
+	add("DELETE", "/admin/entries/:entry_id", "admin", "Hide a raw entry", authenticated, &openapi.Operation{
+		Responses: responses(doc, nil),
+	})
+	add("POST", "/admin/entries/:entry_id/restore", "admin", "Restore a hidden entry", authenticated, &openapi.Operation{
+		Responses: responses(doc, nil),
+	})
+	add("POST", "/admin/import", "admin", "Import historical resolutions from a CSV or XLSX file, rows with existing entry IDs are skipped", authenticated, &openapi.Operation{
+		Parameters: []*openapi.Parameter{queryParam("dry_run", "boolean", "Validate the rows without writing them")},
+		RequestBody: &openapi.RequestBody{
+			Required: true,
+			Content: map[string]*openapi.MediaType{"multipart/form-data": {Schema: &openapi.Schema{
+				Type: "object",
+				Properties: map[string]*openapi.Schema{
+					"file":    {Type: "string", Format: "binary"},
+					"mapping": {Type: "string", Format: "json"},
+				},
+			}}},
+		},
+		Responses: responses(doc, &ImportResponse{}),
+	})
+	add("GET", "/admin/duplicates/clusters", "admin", "Groups of unresolved entries with near duplicate texts", authenticated, &openapi.Operation{
+		Parameters: []*openapi.Parameter{
+			queryParam("limit", "integer", "Biggest clusters to return, 50 by default"),
+			queryParam("event_id", "string", "The active event by default"),
+		},
+		Responses: conditionalResponses(doc, &ClustersResponse{}),
+	})
+	add("POST", "/admin/duplicates/clusters/:entry_id/resolve", "admin", "Resolve a canonical entry and its duplicates", authenticated, &openapi.Operation{
+		RequestBody: body(doc, &ResolveClusterBody{}),
+		Responses:   responses(doc, &BulkResolveResponse{}),
+	})
+
+	add("GET", "/admin/export/geojson", "admin", "Export resolutions as GeoJSON", authenticated, &openapi.Operation{
+		Parameters: entryFilterParams(),
+		Responses:  responses(doc, nil),
+	})
+	add("GET", "/admin/export/csv", "admin", "Export resolutions as CSV", authenticated, &openapi.Operation{
+		Parameters: append(entryFilterParams(), queryParam("fields", "string", "Comma separated columns")),
+		Responses:  responses(doc, nil),
+	})
+	add("GET", "/admin/export/kml", "admin", "Export resolutions as KML placemarks for Google Earth", authenticated, &openapi.Operation{
+		Parameters: entryFilterParams(),
+		Responses:  responses(doc, nil),
+	})
+	add("GET", "/admin/stats/moderators", "admin", "Resolutions per moderator and day", authenticated, &openapi.Operation{
+		Parameters: []*openapi.Parameter{queryParam("event_id", "string", "The active event by default")},
+		Responses:  responses(doc, &ModeratorStatsResponse{}),
+	})
+	add("GET", "/admin/stats/overview", "admin", "Daily counts and the unresolved backlog per city", authenticated, &openapi.Operation{
+		Parameters: []*openapi.Parameter{
+			queryParam("days", "integer", "How many days back, 7 by default and at most 90"),
+			queryParam("event_id", "string", "The active event by default"),
+		},
+		Responses: responses(doc, &StatsOverviewResponse{}),
+	})
+	add("GET", "/admin/stats/backlog", "admin", "Age of the unresolved entries per city", authenticated, &openapi.Operation{
+		Parameters: []*openapi.Parameter{queryParam("event_id", "string", "The active event by default")},
+		Responses:  responses(doc, &BacklogAgeResponse{}),
+	})
+	add("GET", "/admin/stats/claims", "admin", "Active, resolved, released and expired claims per user", authenticated, &openapi.Operation{
+		Parameters: []*openapi.Parameter{queryParam("days", "integer", "How many days of ended claims, 7 by default and at most 90")},
+		Responses:  responses(doc, &ClaimStatsResponse{}),
+	})
+	add("GET", "/admin/stats/throughput", "admin", "Resolutions per time bucket, city and type", authenticated, &openapi.Operation{
+		Parameters: []*openapi.Parameter{
+			queryParam("bucket", "string", "Bucket size in whole minutes, 15m by default and at most 24h"),
+			queryParam("from", "integer", "Unix time, a day before to by default"),
+			queryParam("to", "integer", "Unix time, now by default"),
+			queryParam("event_id", "string", "The active event by default"),
+		},
+		Responses: responses(doc, &ThroughputResponse{}),
+	})
+	add("GET", "/admin/stats/quality", "admin", "Accuracy of the moderators by the QA grades, least accurate first", authenticated, &openapi.Operation{
+		Responses: responses(doc, &QualityStatsResponse{}),
+	})
+	add("GET", "/admin/qa/reviews", "admin", "Sampled resolutions waiting for a grade, oldest first", authenticated, &openapi.Operation{
+		Parameters: append(pageParams(),
+			queryParam("graded", "boolean", "true lists the graded reviews instead"),
+			queryParam("moderator_id", "string", ""),
+		),
+		Responses: responses(doc, &ReviewsResponse{}),
+	})
+	add("POST", "/admin/qa/reviews/:review_id/grade", "admin", "Grade a sampled resolution as correct, minor_issue or wrong", authenticated, &openapi.Operation{
+		RequestBody: body(doc, &GradeBody{}),
+		Responses:   responses(doc, nil),
+	})
+
+	add("GET", "/admin/cities", "admin", "List the cities", authenticated, &openapi.Operation{
+		Responses: responses(doc, []*citiesRepository.City{}),
+	})
+	add("POST", "/admin/cities", "admin", "Add a city", authenticated, &openapi.Operation{
+		RequestBody: body(doc, &citiesRepository.City{}),
+		Responses:   responses(doc, &citiesRepository.City{}),
+	})
+	add("PUT", "/admin/cities/:city_id", "admin", "Update a city", authenticated, &openapi.Operation{
+		RequestBody: body(doc, &citiesRepository.City{}),
+		Responses:   responses(doc, &citiesRepository.City{}),
+	})
+	add("DELETE", "/admin/cities/:city_id", "admin", "Delete a city", authenticated, &openapi.Operation{
+		Responses: responses(doc, nil),
+	})
+	add("GET", "/admin/events", "admin", "List the events", authenticated, &openapi.Operation{
+		Responses: responses(doc, []*eventsRepository.Event{}),
+	})
+	add("POST", "/admin/events", "admin", "Add an event, it isn't activated", authenticated, &openapi.Operation{
+		RequestBody: body(doc, &eventsRepository.Event{}),
+		Responses:   responses(doc, &eventsRepository.Event{}),
+	})
+	add("PUT", "/admin/events/:event_id", "admin", "Rename an event or change when it started", authenticated, &openapi.Operation{
+		RequestBody: body(doc, &eventsRepository.Event{}),
+		Responses:   responses(doc, &eventsRepository.Event{}),
+	})
+	add("DELETE", "/admin/events/:event_id", "admin", "Delete an inactive event without resolutions", authenticated, &openapi.Operation{
+		Responses: responses(doc, nil),
+	})
+	add("POST", "/admin/events/:event_id/activate", "admin", "Make the event the active one", authenticated, &openapi.Operation{
+		Responses: responses(doc, nil),
+	})
+	add("POST", "/admin/api-keys", "admin", "Create a read only API key", authenticated, &openapi.Operation{
+		RequestBody: body(doc, &APIKeyBody{}),
+		Responses:   responses(doc, &APIKeyResponse{}),
+	})
+
+	userID := []*openapi.Parameter{{Name: "user_id", In: "path", Required: true, Schema: &openapi.Schema{Type: "string"}}}
+
+	add("GET", "/admin/users", "users", "List users", authenticated, &openapi.Operation{
+		Responses: responses(doc, []*usersRepository.User{}),
+	})
+	add("GET", "/admin/users/active", "users", "Users seen in the last 15 minutes with their claims", authenticated, &openapi.Operation{
+		Responses: responses(doc, &ActiveUsersResponse{}),
+	})
+	add("GET", "/admin/users/auth-failures", "users", "Rejected Auth-Keys, newest first, and who is locked out", authenticated, &openapi.Operation{
+		Parameters: append([]*openapi.Parameter{
+			queryParam("ip", "string", ""),
+			queryParam("key_hash", "integer", "The hash users are stored with, to spot leaked keys of deactivated users"),
+		}, pageParams()...),
+		Responses: responses(doc, &AuthFailuresResponse{}),
+	})
+	add("GET", "/admin/users/pending", "users", "Registrations waiting for approval, oldest first", authenticated, &openapi.Operation{
+		Responses: responses(doc, []*usersRepository.User{}),
+	})
+	add("POST", "/admin/users/:user_id/approve", "users", "Activate a registration and tell the user on Telegram", authenticated, &openapi.Operation{
+		Parameters:  userID,
+		RequestBody: body(doc, &ApproveUserBody{}),
+		Responses:   responses(doc, nil),
+	})
+	add("POST", "/admin/users/:user_id/reject", "users", "Remove a registration", authenticated, &openapi.Operation{
+		Parameters: userID,
+		Responses:  responses(doc, nil),
+	})
+	add("POST", "/admin/users", "users", "Create a user", authenticated, &openapi.Operation{
+		RequestBody: body(doc, &CreateUserBody{}),
+		Responses:   responses(doc, &CreateUserResponse{}),
+	})
+	add("PATCH", "/admin/users/:user_id", "users", "Change a user's permission level", authenticated, &openapi.Operation{
+		Parameters:  userID,
+		RequestBody: body(doc, &UpdateUserBody{}),
+		Responses:   responses(doc, nil),
+	})
+	add("DELETE", "/admin/users/:user_id", "users", "Deactivate a user", authenticated, &openapi.Operation{
+		Parameters: userID,
+		Responses:  responses(doc, nil),
+	})
+	add("GET", "/admin/assignments", "users", "The cities volunteers are restricted to", authenticated, &openapi.Operation{
+		Responses: responses(doc, []*usersRepository.Assignment{}),
+	})
+	add("PUT", "/admin/assignments/:user_id", "users", "Restrict a volunteer to cities, an empty list removes the restriction", authenticated, &openapi.Operation{
+		Parameters:  userID,
+		RequestBody: body(doc, &AssignmentBody{}),
+		Responses:   responses(doc, &usersRepository.Assignment{}),
+	})
+	add("DELETE", "/admin/assignments/:user_id", "users", "Let a volunteer work on every city again", authenticated, &openapi.Operation{
+		Parameters: userID,
+		Responses:  responses(doc, nil),
+	})
+	ruleID := []*openapi.Parameter{{Name: "rule_id", In: "path", Required: true, Schema: &openapi.Schema{Type: "string"}}}
+
+	add("GET", "/admin/notifications/rules", "users", "List the alert rules", authenticated, &openapi.Operation{
+		Responses: responses(doc, []*notifications.Rule{}),
+	})
+	add("POST", "/admin/notifications/rules", "users", "Add a Telegram alert rule for new entries", authenticated, &openapi.Operation{
+		RequestBody: body(doc, &notifications.Rule{}),
+		Responses:   responses(doc, &notifications.Rule{}),
+	})
+	add("DELETE", "/admin/notifications/rules/:rule_id", "users", "Delete an alert rule", authenticated, &openapi.Operation{
+		Parameters: ruleID,
+		Responses:  responses(doc, nil),
+	})
+	webhookID := []*openapi.Parameter{{Name: "webhook_id", In: "path", Required: true, Schema: &openapi.Schema{Type: "string"}}}
+
+	add("GET", "/admin/webhooks", "users", "List the webhooks", authenticated, &openapi.Operation{
+		Responses: responses(doc, []*webhooksRepository.Webhook{}),
+	})
+	add("POST", "/admin/webhooks", "users", "Register a URL for signed location.resolved events", authenticated, &openapi.Operation{
+		RequestBody: body(doc, &WebhookBody{}),
+		Responses:   responses(doc, &webhooksRepository.Webhook{}),
+	})
+	add("DELETE", "/admin/webhooks/:webhook_id", "users", "Delete a webhook and its pending deliveries", authenticated, &openapi.Operation{
+		Parameters: webhookID,
+		Responses:  responses(doc, nil),
+	})
+	add("GET", "/admin/webhooks/:webhook_id/deliveries", "users", "Delivery attempts of a webhook, newest first", authenticated, &openapi.Operation{
+		Parameters: append([]*openapi.Parameter{
+			webhookID[0],
+			queryParam("status", "string", "pending, delivered or failed"),
+		}, pageParams()...),
+		Responses: responses(doc, &DeliveriesResponse{}),
+	})
+	add("GET", "/admin/outbox", "users", "Events waiting for or done with delivery, newest first", authenticated, &openapi.Operation{
+		Parameters: append([]*openapi.Parameter{
+			queryParam("status", "string", "pending, done or dead"),
+			queryParam("topic", "string", "location.resolved or alert"),
+		}, pageParams()...),
+		Responses: responses(doc, &OutboxResponse{}),
+	})
+	add("POST", "/admin/cache/invalidate", "admin", "Drop the cached feed and texts, or only the text of one entry", authenticated, &openapi.Operation{
+		Parameters: []*openapi.Parameter{queryParam("entry_id", "integer", "Only this entry")},
+		Responses:  responses(doc, &CacheResponse{}),
+	})
+	warmResponses := responses(doc, &CacheResponse{})
+	warmResponses["202"] = &openapi.Response{Description: "Feed pulled, texts are being looked up", Content: doc.JSON(&CacheResponse{})}
+	add("POST", "/admin/cache/warm", "admin", "Pull the feed and look up its texts in the background, or fetch the text of one entry", authenticated, &openapi.Operation{
+		Parameters: []*openapi.Parameter{queryParam("entry_id", "integer", "Only this entry")},
+		Responses:  warmResponses,
+	})
+	add("POST", "/admin/outbox/:message_id/retry", "users", "Give a dead outbox message a fresh set of attempts", authenticated, &openapi.Operation{
+		Parameters: []*openapi.Parameter{{Name: "message_id", In: "path", Required: true, Schema: &openapi.Schema{Type: "string"}}},
+		Responses:  responses(doc, &outboxRepository.Message{}),
+	})
+	add("GET", "/admin/audit", "users", "Search the audit log", authenticated, &openapi.Operation{
+		Parameters: append([]*openapi.Parameter{
+			queryParam("actor_id", "string", ""),
+			queryParam("action", "string", ""),
+			queryParam("entry_id", "integer", ""),
+			queryParam("from", "integer", "Unix time"),
+			queryParam("to", "integer", "Unix time"),
+		}, pageParams()...),
+		Responses: responses(doc, &AuditResponse{}),
+	})
+
+	add("POST", "/intake/entries", "public", "Submit a report from outside the upstream feed, needs a key with perm_level 1", authenticated, &openapi.Operation{
+		RequestBody: body(doc, &IntakeBody{}),
+		Responses: map[string]*openapi.Response{
+			"201":     {Description: "Created", Content: doc.JSON(&IntakeResponse{})},
+			"default": {Description: "Error", Content: doc.JSON(&APIError{})},
+		},
+	})
+	add("GET", "/v1/public/resolved", "public", "Resolved locations for external consumers", authenticated, &openapi.Operation{
+		Parameters: append([]*openapi.Parameter{
+			queryParam("event_id", "string", "The active event by default"),
+			queryParam("city_id", "integer", ""),
+			queryParam("since", "integer", "Highest resolved_at or updated_at already seen"),
+		}, pageParams()...),
+		Responses: responses(doc, &PublicResolvedResponse{}),
+	})
+	add("GET", "/ws/resolved", "public", "WebSocket feed of new resolutions, one PublicLocation per message", authenticated, &openapi.Operation{
+		Parameters: []*openapi.Parameter{
+			queryParam("event_id", "string", "The active event by default"),
+			queryParam("city_id", "integer", ""),
+		},
+		Responses: map[string]*openapi.Response{
+			"101": {Description: "Switching Protocols", Content: doc.JSON(&PublicLocation{})},
+		},
+	})
+
+	add("GET", "/healthz", "health", "Liveness", nil, &openapi.Operation{
+		Responses: responses(doc, &CheckResult{}),
+	})
+	add("GET", "/readyz", "health", "Readiness with per dependency status", nil, &openapi.Operation{
+		Responses: map[string]*openapi.Response{
+			"200": {Description: "Ready", Content: doc.JSON(&ReadinessResponse{})},
+			"503": {Description: "Not ready", Content: doc.JSON(&ReadinessResponse{})},
+		},
+	})
+
+	return doc
+}
+
+// Middleware registered with Use and Group shows up under this method in the route list.
+const methodUse = "USE"
+
+// checkOpenAPI warns about routes missing from the spec, so a new endpoint isn't silently left out.
+func checkOpenAPI(app *fiber.App, doc *openapi.Document) {
+	for _, route := range app.GetRoutes(true) {
+		if route.Method == fiber.MethodHead || route.Method == methodUse || undocumentedRoutes[route.Path] {
+			continue
+		}
+
+		if !doc.Has(route.Method, route.Path) {
+			logrus.Warnf("%s %s is missing from the OpenAPI spec", route.Method, route.Path)
+		}
+	}
+}
+
+const swaggerUI = `<!DOCTYPE html>
+<html>
+<head>
+	<meta charset="utf-8">
+	<title>Veri Kontrol API</title>
+	<link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@4/swagger-ui.css">
+</head>
+<body>
+	<div id="swagger-ui"></div>
+	<script src="https://unpkg.com/swagger-ui-dist@4/swagger-ui-bundle.js"></script>
+	<script>SwaggerUIBundle({url: "/openapi.json", dom_id: "#swagger-ui"})</script>
+</body>
+</html>`
+
+func OpenAPIHandlers(doc *openapi.Document) (fiber.Handler, fiber.Handler) {
+	spec, err := json.Marshal(doc)
+	if err != nil {
+		panic(err)
+	}
+
+	serveSpec := func(c *fiber.Ctx) error {
+		c.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+
+		return c.Send(spec)
+	}
+
+	serveUI := func(c *fiber.Ctx) error {
+		c.Set(fiber.HeaderContentType, fiber.MIMETextHTMLCharsetUTF8)
+
+		return c.SendString(swaggerUI)
+	}
+
+	return serveSpec, serveUI
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"time"
+
+	auditRepository "github.com/YusufOzmen01/veri-kontrol-backend/repository/audit"
+	"github.com/YusufOzmen01/veri-kontrol-backend/repository/locations"
+	outboxRepository "github.com/YusufOzmen01/veri-kontrol-backend/repository/outbox"
+	"github.com/YusufOzmen01/veri-kontrol-backend/tools"
+	"github.com/YusufOzmen01/veri-kontrol-backend/validation"
+	"github.com/gofiber/fiber/v2"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type Outbox interface {
+	GetMessages(c *fiber.Ctx) error
+	RetryMessage(c *fiber.Ctx) error
+}
+
+type outbox struct {
+	outbox outboxRepository.Repository
+	audit  auditRepository.Repository
+}
+
+type OutboxResponse struct {
+	Count    int64                       `json:"count"`
+	Page     int                         `json:"page"`
+	PageSize int                         `json:"page_size"`
+	Messages []*outboxRepository.Message `json:"messages"`
+}
+
+// queueResolved tells the outbox handlers about a resolution or an edit written outside of a transaction. Like
+// recordAudit, a failure is only logged, the resolution is what counts.
+func queueResolved(c *fiber.Ctx, outboxMessages outboxRepository.Repository, location *locations.LocationDB) {
+	if err := tools.QueueResolved(c.Context(), outboxMessages, location); err != nil {
+		logrus.Errorf("Couldn't queue the resolution of entry %d: %s", location.EntryID, err)
+	}
+}
+
+func NewOutbox(outboxMessages outboxRepository.Repository, auditLog auditRepository.Repository) Outbox {
+	return &outbox{
+		outbox: outboxMessages,
+		audit:  auditLog,
+	}
+}
+
+func (o *outbox) GetMessages(c *fiber.Ctx) error {
+	filter := &outboxRepository.MessageFilter{
+		Status:   c.Query("status"),
+		Topic:    c.Query("topic"),
+		Page:     c.QueryInt("page", 1),
+		PageSize: c.QueryInt("page_size", defaultPageSize),
+	}
+
+	v := validation.New()
+	if filter.Status != "" {
+		filter.Status, _ = v.OneOf("status", filter.Status, outboxRepository.Statuses)
+	}
+
+	if err := v.Err(); err != nil {
+		return validationFailed(err)
+	}
+
+	if filter.Page < 1 {
+		filter.Page = 1
+	}
+
+	if filter.PageSize < 1 || filter.PageSize > maxPageSize {
+		filter.PageSize = defaultPageSize
+	}
+
+	count, err := o.outbox.CountMessages(c.Context(), filter)
+	if err != nil {
+		return err
+	}
+
+	messages, err := o.outbox.GetMessages(c.Context(), filter)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(&OutboxResponse{
+		Count:    count,
+		Page:     filter.Page,
+		PageSize: filter.PageSize,
+		Messages: messages,
+	})
+}
+
+// RetryMessage gives a dead message a fresh set of attempts, for after the cause of the failures was fixed.
+func (o *outbox) RetryMessage(c *fiber.Ctx) error {
+	id, err := primitive.ObjectIDFromHex(c.Params("message_id"))
+	if err != nil {
+		return badRequest("invalid message_id")
+	}
+
+	message, err := o.outbox.GetMessage(c.Context(), id)
+	if err != nil {
+		if err == outboxRepository.ErrMessageNotFound {
+			return notFound(err.Error())
+		}
+
+		return err
+	}
+
+	if message.Status != outboxRepository.StatusDead {
+		return conflict("only dead messages can be retried")
+	}
+
+	message.Status = outboxRepository.StatusPending
+	message.Attempts = 0
+	message.NextAttemptAt = time.Now()
+
+	if err := o.outbox.UpdateMessage(c.Context(), message); err != nil {
+		return err
+	}
+
+	recordAudit(c, o.audit, &auditRepository.Event{
+		Action: auditRepository.ActionOutboxRetry,
+		Target: message.ID.Hex(),
+	})
+
+	return c.JSON(message)
+}
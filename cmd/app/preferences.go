@@ -0,0 +1,115 @@
+package main
+
+import (
+	"encoding/json"
+
+	"github.com/YusufOzmen01/veri-kontrol-backend/core/language"
+	citiesRepository "github.com/YusufOzmen01/veri-kontrol-backend/repository/cities"
+	typesRepository "github.com/YusufOzmen01/veri-kontrol-backend/repository/types"
+	usersRepository "github.com/YusufOzmen01/veri-kontrol-backend/repository/users"
+	"github.com/YusufOzmen01/veri-kontrol-backend/validation"
+	"github.com/gofiber/fiber/v2"
+	"github.com/samber/lo"
+)
+
+type Preferences interface {
+	GetPreferences(c *fiber.Ctx) error
+	SetPreferences(c *fiber.Ctx) error
+}
+
+type preferences struct {
+	users  usersRepository.Repository
+	cities citiesRepository.Repository
+	types  typesRepository.Repository
+}
+
+// PreferencesBody replaces the stored preferences, empty lists mean no preference. Raw entries only get a type
+// once they are resolved, so the types are for the client to preselect rather than a filter. Languages on the
+// other hand are one, volunteers aren't served entries they can't read.
+type PreferencesBody struct {
+	CityIDs   []int    `json:"city_ids"`
+	Types     []int    `json:"types"`
+	Languages []string `json:"languages"`
+}
+
+const maxPreferences = 50
+
+func NewPreferences(users usersRepository.Repository, cities citiesRepository.Repository, types typesRepository.Repository) Preferences {
+	return &preferences{
+		users:  users,
+		cities: cities,
+		types:  types,
+	}
+}
+
+func (p *preferences) GetPreferences(c *fiber.Ctx) error {
+	prefs, err := p.users.GetPreferences(c.Context(), currentUser(c).ID)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(prefs)
+}
+
+func (p *preferences) SetPreferences(c *fiber.Ctx) error {
+	body := &PreferencesBody{}
+
+	if err := json.Unmarshal(c.Body(), body); err != nil {
+		return badRequest(err.Error())
+	}
+
+	cityList, err := p.cities.GetCities(c.Context())
+	if err != nil {
+		return err
+	}
+
+	typeList, err := p.types.GetTypes(c.Context())
+	if err != nil {
+		return err
+	}
+
+	body.CityIDs = lo.Uniq(body.CityIDs)
+	body.Types = lo.Uniq(body.Types)
+
+	v := validation.New()
+
+	if len(body.CityIDs) > maxPreferences || len(body.Types) > maxPreferences {
+		v.Add("city_ids", "at most 50 cities and 50 types can be preferred")
+	}
+
+	cityIDs := lo.Map(cityList, func(city *citiesRepository.City, _ int) int { return city.ID })
+	for _, cityID := range body.CityIDs {
+		v.OneOfInt("city_ids", cityID, cityIDs)
+	}
+
+	for _, locationType := range body.Types {
+		v.OneOfInt("types", locationType, typesRepository.SelectableIDs(typeList))
+	}
+
+	for i, lang := range body.Languages {
+		body.Languages[i], _ = v.OneOf("languages", lang, language.Supported)
+	}
+
+	body.Languages = lo.Uniq(body.Languages)
+
+	if err := v.Err(); err != nil {
+		return validationFailed(err)
+	}
+
+	if body.Languages == nil {
+		body.Languages = make([]string, 0)
+	}
+
+	prefs := &usersRepository.Preferences{
+		UserID:    currentUser(c).ID,
+		CityIDs:   body.CityIDs,
+		Types:     body.Types,
+		Languages: body.Languages,
+	}
+
+	if err := p.users.SetPreferences(c.Context(), prefs); err != nil {
+		return err
+	}
+
+	return c.JSON(prefs)
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"sync"
+
+	locationsRepository "github.com/YusufOzmen01/veri-kontrol-backend/repository/locations"
+)
+
+// ProcessedIDs keeps the entry IDs which are already resolved, shared between the public and admin handlers.
+// It is a set, every request checks the whole feed against it.
+type ProcessedIDs struct {
+	mu  sync.RWMutex
+	ids map[int]struct{}
+}
+
+func NewProcessedIDs(ids []int) *ProcessedIDs {
+	p := &ProcessedIDs{
+		ids: make(map[int]struct{}, len(ids)),
+	}
+
+	p.Add(ids...)
+
+	return p
+}
+
+func (p *ProcessedIDs) Add(ids ...int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, id := range ids {
+		p.ids[id] = struct{}{}
+	}
+}
+
+func (p *ProcessedIDs) Remove(id int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	delete(p.ids, id)
+}
+
+func (p *ProcessedIDs) Contains(id int) bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	_, ok := p.ids[id]
+
+	return ok
+}
+
+// Available returns the entries of locs which aren't processed and which keep accepts. The feed is cached and
+// shared between requests, it is filtered into a new slice instead of in place.
+func (p *ProcessedIDs) Available(locs []*locationsRepository.Location, keep func(loc *locationsRepository.Location) bool) []*locationsRepository.Location {
+	available := make([]*locationsRepository.Location, 0, len(locs))
+
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	for _, loc := range locs {
+		if _, ok := p.ids[loc.EntryID]; !ok && keep(loc) {
+			available = append(available, loc)
+		}
+	}
+
+	return available
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"sync"
+	"testing"
+
+	locationsRepository "github.com/YusufOzmen01/veri-kontrol-backend/repository/locations"
+)
+
+var feedSizes = []int{50_000, 100_000}
+
+// sliceProcessedIDs is how the processed IDs were kept before they became a set, the benchmarks compare the two.
+type sliceProcessedIDs struct {
+	mu  sync.RWMutex
+	ids []int
+}
+
+func (p *sliceProcessedIDs) IDs() []int {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	ids := make([]int, len(p.ids))
+	copy(ids, p.ids)
+
+	return ids
+}
+
+// filterBySlice is the feed filter before the set: every processed ID is looked up in the feed and cut out of it
+// in place, then what is excluded is left out.
+func filterBySlice(locs []*locationsRepository.Location, processed *sliceProcessedIDs, excluded map[int]bool) []*locationsRepository.Location {
+	for _, id := range processed.IDs() {
+		for i, loc := range locs {
+			if id == loc.EntryID {
+				locs = append(locs[:i], locs[i+1:]...)
+
+				break
+			}
+		}
+	}
+
+	available := make([]*locationsRepository.Location, 0, len(locs))
+	for _, loc := range locs {
+		if !excluded[loc.EntryID] {
+			available = append(available, loc)
+		}
+	}
+
+	return available
+}
+
+// generatedFeed returns a feed of size entries, about half of which are processed, and every hundredth entry
+// excluded.
+func generatedFeed(size int) ([]*locationsRepository.Location, []int, map[int]bool) {
+	rng := rand.New(rand.NewSource(1))
+
+	locs := make([]*locationsRepository.Location, 0, size)
+	processed := make([]int, 0, size/2)
+	excluded := make(map[int]bool)
+	for i := 1; i <= size; i++ {
+		locs = append(locs, &locationsRepository.Location{
+			EntryID: i,
+			Loc:     []float64{36 + rng.Float64()*3, 35 + rng.Float64()*5},
+			Epoch:   1675650000 + rng.Intn(72*3600),
+		})
+
+		if rng.Intn(2) == 0 {
+			processed = append(processed, i)
+		}
+
+		if i%100 == 1 {
+			excluded[i] = true
+		}
+	}
+
+	return locs, processed, excluded
+}
+
+func TestProcessedIDsAvailable(t *testing.T) {
+	locs, ids, excluded := generatedFeed(1000)
+	processed := NewProcessedIDs(ids)
+
+	available := processed.Available(locs, func(loc *locationsRepository.Location) bool { return !excluded[loc.EntryID] })
+
+	feed := make([]*locationsRepository.Location, len(locs))
+	copy(feed, locs)
+
+	want := filterBySlice(feed, &sliceProcessedIDs{ids: ids}, excluded)
+
+	if len(available) != len(want) {
+		t.Fatalf("Available() kept %d entries, the slice filter %d", len(available), len(want))
+	}
+
+	for i, loc := range available {
+		if loc.EntryID != want[i].EntryID {
+			t.Fatalf("Available() kept entry %d at %d, the slice filter entry %d", loc.EntryID, i, want[i].EntryID)
+		}
+	}
+
+	for i, loc := range locs {
+		if loc.EntryID != i+1 {
+			t.Fatalf("Available() changed the feed, entry %d is at %d", loc.EntryID, i)
+		}
+	}
+}
+
+func BenchmarkProcessedIDs(b *testing.B) {
+	for _, size := range feedSizes {
+		locs, ids, _ := generatedFeed(size)
+
+		b.Run(fmt.Sprintf("Contains/%d", size), func(b *testing.B) {
+			processed := NewProcessedIDs(ids)
+
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				for _, loc := range locs {
+					processed.Contains(loc.EntryID)
+				}
+			}
+		})
+
+		b.Run(fmt.Sprintf("Add/%d", size), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				NewProcessedIDs(ids)
+			}
+		})
+	}
+}
+
+// BenchmarkFeedFilter runs the filter every feed and claim request applies to the cached feed, the slice
+// filter it replaced next to the set.
+func BenchmarkFeedFilter(b *testing.B) {
+	for _, size := range feedSizes {
+		locs, ids, excluded := generatedFeed(size)
+
+		b.Run(fmt.Sprintf("slice/%d", size), func(b *testing.B) {
+			processed := &sliceProcessedIDs{ids: ids}
+			feed := make([]*locationsRepository.Location, len(locs))
+
+			b.ReportAllocs()
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				// The slice filter cuts the feed it is given, every run starts from a fresh copy.
+				b.StopTimer()
+				copy(feed, locs)
+				b.StartTimer()
+
+				filterBySlice(feed, processed, excluded)
+			}
+		})
+
+		b.Run(fmt.Sprintf("set/%d", size), func(b *testing.B) {
+			processed := NewProcessedIDs(ids)
+
+			b.ReportAllocs()
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				processed.Available(locs, func(loc *locationsRepository.Location) bool { return !excluded[loc.EntryID] })
+			}
+		})
+	}
+}
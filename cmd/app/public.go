@@ -0,0 +1,205 @@
+package main
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/YusufOzmen01/veri-kontrol-backend/core/redact"
+	auditRepository "github.com/YusufOzmen01/veri-kontrol-backend/repository/audit"
+	citiesRepository "github.com/YusufOzmen01/veri-kontrol-backend/repository/cities"
+	eventsRepository "github.com/YusufOzmen01/veri-kontrol-backend/repository/events"
+	"github.com/YusufOzmen01/veri-kontrol-backend/repository/locations"
+	typesRepository "github.com/YusufOzmen01/veri-kontrol-backend/repository/types"
+	usersRepository "github.com/YusufOzmen01/veri-kontrol-backend/repository/users"
+	"github.com/gofiber/fiber/v2"
+)
+
+type Public interface {
+	GetResolved(c *fiber.Ctx) error
+	CreateAPIKey(c *fiber.Ctx) error
+}
+
+type public struct {
+	locations locations.Repository
+	cities    citiesRepository.Repository
+	events    eventsRepository.Repository
+	users     usersRepository.Repository
+	types     typesRepository.Repository
+	audit     auditRepository.Repository
+}
+
+// PublicLocation leaves out who resolved the entry and the raw tweet, consumers only need the verified address.
+type PublicLocation struct {
+	EntryID          int       `json:"entry_id"`
+	Location         []float64 `json:"location"`
+	Type             int       `json:"type"`
+	Reason           string    `json:"reason"`
+	Corrected        bool      `json:"corrected"`
+	CorrectedAddress string    `json:"corrected_address"`
+	CorrectedLat     *float64  `json:"corrected_lat,omitempty"`
+	CorrectedLng     *float64  `json:"corrected_lng,omitempty"`
+	OpenAddress      string    `json:"open_address"`
+	Apartment        string    `json:"apartment"`
+	Epoch            int       `json:"epoch"`
+	ResolvedAt       int64     `json:"resolved_at"`
+	UpdatedAt        int64     `json:"updated_at,omitempty"`
+	Source           string    `json:"source,omitempty"`
+	EventID          string    `json:"event_id,omitempty"`
+}
+
+func toPublicLocation(entry *locations.LocationDB, redactor redact.Redactor) *PublicLocation {
+	return &PublicLocation{
+		EntryID:          entry.EntryID,
+		Location:         entry.Location,
+		Type:             entry.Type,
+		Reason:           entry.Reason,
+		Corrected:        entry.Corrected,
+		CorrectedAddress: redactor.Text(entry.CorrectedAddress),
+		CorrectedLat:     entry.CorrectedLat,
+		CorrectedLng:     entry.CorrectedLng,
+		OpenAddress:      redactor.Text(entry.OpenAddress),
+		Apartment:        redactor.Text(entry.Apartment),
+		Epoch:            entry.Epoch,
+		ResolvedAt:       entry.ID.Timestamp().Unix(),
+		UpdatedAt:        updatedAt(entry),
+		Source:           entry.Source,
+		EventID:          entry.EventID,
+	}
+}
+
+func updatedAt(entry *locations.LocationDB) int64 {
+	if entry.UpdatedAt == nil {
+		return 0
+	}
+
+	return entry.UpdatedAt.Unix()
+}
+
+type PublicResolvedResponse struct {
+	Count    int64             `json:"count"`
+	Page     int               `json:"page"`
+	PageSize int               `json:"page_size"`
+	Entries  []*PublicLocation `json:"entries"`
+}
+
+// APIKeyBody creates a read only key by default, intake integrations need PermSubmit.
+type APIKeyBody struct {
+	Name      string `json:"name"`
+	Discord   string `json:"discord"`
+	PermLevel int    `json:"perm_level"`
+}
+
+type APIKeyResponse struct {
+	Name   string `json:"name"`
+	APIKey string `json:"api_key"`
+}
+
+func NewPublic(locations locations.Repository, cities citiesRepository.Repository, events eventsRepository.Repository, users usersRepository.Repository, types typesRepository.Repository, auditLog auditRepository.Repository) Public {
+	return &public{
+		locations: locations,
+		cities:    cities,
+		events:    events,
+		users:     users,
+		types:     types,
+		audit:     auditLog,
+	}
+}
+
+// GetResolved lists resolutions oldest first. Clients keep the highest resolved_at or updated_at they have seen
+// and pass it back as since to fetch only what was resolved or edited after it.
+func (p *public) GetResolved(c *fiber.Ctx) error {
+	typeList, err := p.types.GetTypes(c.Context())
+	if err != nil {
+		return err
+	}
+
+	eventID, err := eventIDFromQuery(c, p.events)
+	if err != nil {
+		return badRequest(err.Error())
+	}
+
+	filter := &locations.LocationFilter{
+		EventID:  eventID,
+		Status:   locations.StatusResolved,
+		Types:    typesRepository.SelectableIDs(typeList),
+		Page:     c.QueryInt("page", 1),
+		PageSize: c.QueryInt("page_size", defaultPageSize),
+	}
+
+	if filter.Page < 1 {
+		filter.Page = 1
+	}
+
+	if filter.PageSize < 1 || filter.PageSize > maxPageSize {
+		filter.PageSize = defaultPageSize
+	}
+
+	if since := c.QueryInt("since"); since > 0 {
+		filter.ChangedSince = time.Unix(int64(since), 0)
+	}
+
+	if cityID := c.QueryInt("city_id"); cityID > 0 {
+		city, err := p.cities.GetCity(c.Context(), cityID)
+		if err != nil {
+			return badRequest("unknown city_id")
+		}
+
+		filter.Polygon = city.Polygon
+	}
+
+	count, err := p.locations.CountLocations(c.Context(), filter)
+	if err != nil {
+		return err
+	}
+
+	entries, err := p.locations.GetLocations(c.Context(), filter)
+	if err != nil {
+		return err
+	}
+
+	response := &PublicResolvedResponse{
+		Count:    count,
+		Page:     filter.Page,
+		PageSize: filter.PageSize,
+		Entries:  make([]*PublicLocation, 0, len(entries)),
+	}
+
+	redactor := redactorFor(c)
+	for _, entry := range entries {
+		response.Entries = append(response.Entries, toPublicLocation(entry, redactor))
+	}
+
+	return c.JSON(response)
+}
+
+func (p *public) CreateAPIKey(c *fiber.Ctx) error {
+	body := &APIKeyBody{}
+
+	if err := json.Unmarshal(c.Body(), body); err != nil {
+		return badRequest(err.Error())
+	}
+
+	if body.Name == "" {
+		return badRequest("name is required")
+	}
+
+	if body.PermLevel != usersRepository.PermReadOnly && body.PermLevel != usersRepository.PermSubmit {
+		return badRequest("perm_level of an API key must be 0 or 1")
+	}
+
+	user, apiKey, err := p.users.AddUser(c.Context(), body.Name, body.Discord, body.PermLevel, nil)
+	if err != nil {
+		return err
+	}
+
+	recordAudit(c, p.audit, &auditRepository.Event{
+		Action:  auditRepository.ActionAPIKey,
+		Target:  user.ID.Hex(),
+		Changes: auditRepository.Diff(nil, user),
+	})
+
+	return c.JSON(&APIKeyResponse{
+		Name:   body.Name,
+		APIKey: apiKey,
+	})
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+
+	auditRepository "github.com/YusufOzmen01/veri-kontrol-backend/repository/audit"
+	qualityRepository "github.com/YusufOzmen01/veri-kontrol-backend/repository/quality"
+	"github.com/YusufOzmen01/veri-kontrol-backend/validation"
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type Quality interface {
+	GetReviews(c *fiber.Ctx) error
+	GradeReview(c *fiber.Ctx) error
+	GetQualityStats(c *fiber.Ctx) error
+}
+
+type quality struct {
+	reviews qualityRepository.Repository
+	audit   auditRepository.Repository
+}
+
+type ReviewsResponse struct {
+	Count    int64                       `json:"count"`
+	Page     int                         `json:"page"`
+	PageSize int                         `json:"page_size"`
+	Reviews  []*qualityRepository.Review `json:"reviews"`
+}
+
+type GradeBody struct {
+	Grade string `json:"grade"`
+	Note  string `json:"note"`
+}
+
+type QualityStatsResponse struct {
+	Graded     int                                   `json:"graded"`
+	Accuracy   float64                               `json:"accuracy"`
+	Moderators []*qualityRepository.ModeratorQuality `json:"moderators"`
+}
+
+const maxGradeNoteLength = 1000
+
+func NewQuality(reviews qualityRepository.Repository, auditLog auditRepository.Repository) Quality {
+	return &quality{
+		reviews: reviews,
+		audit:   auditLog,
+	}
+}
+
+// GetReviews is the QA queue, oldest first. graded=true lists the graded reviews instead.
+func (q *quality) GetReviews(c *fiber.Ctx) error {
+	filter := &qualityRepository.ReviewFilter{
+		Graded:   c.Query("graded") == "true",
+		Page:     c.QueryInt("page", 1),
+		PageSize: c.QueryInt("page_size", defaultPageSize),
+	}
+
+	if moderatorID := c.Query("moderator_id"); moderatorID != "" {
+		id, err := primitive.ObjectIDFromHex(moderatorID)
+		if err != nil {
+			return badRequest("invalid moderator_id")
+		}
+
+		filter.ModeratorID = id
+	}
+
+	if filter.Page < 1 {
+		filter.Page = 1
+	}
+
+	if filter.PageSize < 1 || filter.PageSize > maxPageSize {
+		filter.PageSize = defaultPageSize
+	}
+
+	count, err := q.reviews.CountReviews(c.Context(), filter)
+	if err != nil {
+		return err
+	}
+
+	reviews, err := q.reviews.GetReviews(c.Context(), filter)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(&ReviewsResponse{
+		Count:    count,
+		Page:     filter.Page,
+		PageSize: filter.PageSize,
+		Reviews:  reviews,
+	})
+}
+
+func (q *quality) GradeReview(c *fiber.Ctx) error {
+	id, err := primitive.ObjectIDFromHex(c.Params("review_id"))
+	if err != nil {
+		return badRequest("invalid review_id")
+	}
+
+	body := &GradeBody{}
+
+	if err := json.Unmarshal(c.Body(), body); err != nil {
+		return badRequest(err.Error())
+	}
+
+	v := validation.New()
+
+	if v.Required("grade", body.Grade) {
+		body.Grade, _ = v.OneOf("grade", body.Grade, qualityRepository.Grades)
+	}
+
+	v.MaxLength("note", body.Note, maxGradeNoteLength)
+
+	if err := v.Err(); err != nil {
+		return validationFailed(err)
+	}
+
+	review, err := q.reviews.GetReview(c.Context(), id)
+	if err != nil {
+		if errors.Is(err, qualityRepository.ErrReviewNotFound) {
+			return notFound(err.Error())
+		}
+
+		return err
+	}
+
+	reviewer := currentUser(c)
+	if review.Moderator != nil && review.Moderator.ID == reviewer.ID {
+		return forbidden("You can't grade your own resolution.")
+	}
+
+	if err := q.reviews.GradeReview(c.Context(), id, body.Grade, body.Note, reviewer); err != nil {
+		if errors.Is(err, qualityRepository.ErrAlreadyGraded) {
+			return conflict(err.Error())
+		}
+
+		return err
+	}
+
+	recordAudit(c, q.audit, &auditRepository.Event{
+		Action:  auditRepository.ActionQAGrade,
+		EntryID: review.EntryID,
+		Target:  review.ID.Hex(),
+		Changes: auditRepository.Diff(nil, body),
+	})
+
+	return c.SendString("Successfully graded!")
+}
+
+// GetQualityStats summarizes the grades per moderator, the least accurate first.
+func (q *quality) GetQualityStats(c *fiber.Ctx) error {
+	moderators, err := q.reviews.GetModeratorQuality(c.Context())
+	if err != nil {
+		return err
+	}
+
+	response := &QualityStatsResponse{
+		Moderators: moderators,
+	}
+
+	correct := 0
+	for _, moderator := range moderators {
+		response.Graded += moderator.Graded
+		correct += moderator.Correct
+	}
+
+	if response.Graded > 0 {
+		response.Accuracy = float64(correct) / float64(response.Graded)
+	}
+
+	return c.JSON(response)
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"math"
+	"strconv"
+
+	"github.com/YusufOzmen01/veri-kontrol-backend/core/ratelimit"
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	defaultUserRateLimit = 120
+	defaultIPRateLimit   = 30
+	defaultRateBurst     = 10
+
+	// Registrations are anonymous and every one of them waits for an admin, an IP gets a few and then one a
+	// minute.
+	registerRateLimit = 1
+	registerRateBurst = 3
+)
+
+// rateLimit has to run after auth.Identify or auth.RequirePerm. Authenticated users get their own bucket,
+// everyone else shares one per IP.
+func rateLimit(users, ips *ratelimit.Limiter) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		limiter, key := ips, "ip:"+c.IP()
+		if user := currentUser(c); user != nil {
+			limiter, key = users, "user:"+user.ID.Hex()
+		}
+
+		allowed, retryAfter := limiter.Allow(key)
+		if !allowed {
+			c.Set(fiber.HeaderRetryAfter, strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+
+			return tooManyRequests("Too many requests, slow down.")
+		}
+
+		return c.Next()
+	}
+}
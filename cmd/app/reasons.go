@@ -0,0 +1,29 @@
+package main
+
+import (
+	reasonsRepository "github.com/YusufOzmen01/veri-kontrol-backend/repository/reasons"
+	"github.com/gofiber/fiber/v2"
+)
+
+type Reasons interface {
+	GetReasons(c *fiber.Ctx) error
+}
+
+type reasons struct {
+	reasons reasonsRepository.Repository
+}
+
+func NewReasons(reasonRepository reasonsRepository.Repository) Reasons {
+	return &reasons{
+		reasons: reasonRepository,
+	}
+}
+
+func (r *reasons) GetReasons(c *fiber.Ctx) error {
+	list, err := r.reasons.GetReasons(c.Context())
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(list)
+}
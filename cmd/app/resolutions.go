@@ -0,0 +1,76 @@
+package main
+
+import (
+	locationsRepository "github.com/YusufOzmen01/veri-kontrol-backend/repository/locations"
+	"github.com/YusufOzmen01/veri-kontrol-backend/validation"
+	"github.com/gofiber/fiber/v2"
+)
+
+type Resolutions interface {
+	GetMyResolutions(c *fiber.Ctx) error
+}
+
+type resolutions struct {
+	locations locationsRepository.Repository
+	masker    *Masker
+}
+
+type MyResolutionsResponse struct {
+	Count       int64                                   `json:"count"`
+	Page        int                                     `json:"page"`
+	PageSize    int                                     `json:"page_size"`
+	Resolutions []*locationsRepository.SenderResolution `json:"resolutions"`
+}
+
+var resolutionStatuses = []string{
+	locationsRepository.ResolutionApproved,
+	locationsRepository.ResolutionPendingReview,
+	locationsRepository.ResolutionRolledBack,
+}
+
+func NewResolutions(locations locationsRepository.Repository, masker *Masker) Resolutions {
+	return &resolutions{
+		locations: locations,
+		masker:    masker,
+	}
+}
+
+// GetMyResolutions lets volunteers look through what they resolved, including what was taken back, so they
+// can see their own mistakes.
+func (r *resolutions) GetMyResolutions(c *fiber.Ctx) error {
+	filter := &locationsRepository.SenderResolutionFilter{
+		SenderID: currentUser(c).ID,
+		Status:   c.Query("status"),
+		Page:     c.QueryInt("page", 1),
+		PageSize: c.QueryInt("page_size", defaultPageSize),
+	}
+
+	if filter.Status != "" {
+		v := validation.New()
+		filter.Status, _ = v.OneOf("status", filter.Status, resolutionStatuses)
+
+		if err := v.Err(); err != nil {
+			return validationFailed(err)
+		}
+	}
+
+	if filter.Page < 1 {
+		filter.Page = 1
+	}
+
+	if filter.PageSize < 1 || filter.PageSize > maxPageSize {
+		filter.PageSize = defaultPageSize
+	}
+
+	list, count, err := r.locations.GetSenderResolutions(c.Context(), filter)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(&MyResolutionsResponse{
+		Count:       count,
+		Page:        filter.Page,
+		PageSize:    filter.PageSize,
+		Resolutions: r.masker.SenderResolutions(c, list),
+	})
+}
@@ -0,0 +1,1075 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/YusufOzmen01/veri-kontrol-backend/core/language"
+	"github.com/YusufOzmen01/veri-kontrol-backend/core/metrics"
+	"github.com/YusufOzmen01/veri-kontrol-backend/core/phones"
+	"github.com/YusufOzmen01/veri-kontrol-backend/core/queue"
+	"github.com/YusufOzmen01/veri-kontrol-backend/core/sources"
+	"github.com/YusufOzmen01/veri-kontrol-backend/core/textfilter"
+	"github.com/YusufOzmen01/veri-kontrol-backend/core/wal"
+	auditRepository "github.com/YusufOzmen01/veri-kontrol-backend/repository/audit"
+	citiesRepository "github.com/YusufOzmen01/veri-kontrol-backend/repository/cities"
+	idempotencyRepository "github.com/YusufOzmen01/veri-kontrol-backend/repository/idempotency"
+	locationsRepository "github.com/YusufOzmen01/veri-kontrol-backend/repository/locations"
+	outboxRepository "github.com/YusufOzmen01/veri-kontrol-backend/repository/outbox"
+	reasonsRepository "github.com/YusufOzmen01/veri-kontrol-backend/repository/reasons"
+	typesRepository "github.com/YusufOzmen01/veri-kontrol-backend/repository/types"
+	usersRepository "github.com/YusufOzmen01/veri-kontrol-backend/repository/users"
+	"github.com/YusufOzmen01/veri-kontrol-backend/tools"
+	"github.com/YusufOzmen01/veri-kontrol-backend/validation"
+	"github.com/gofiber/fiber/v2"
+	"github.com/samber/lo"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// transactions writes a resolution together with its audit event and outbox message, sources.MongoClient is
+// the one main uses.
+type transactions interface {
+	SupportsTransactions(ctx context.Context) bool
+	Transaction(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+// Serving hands the entries out to volunteers and takes their resolutions. Like tools.Seeder it is put
+// together from the repositories, main gives it the Mongo ones and the tests the memory ones.
+type Serving struct {
+	Environment   *Environment
+	Locations     locationsRepository.Repository
+	Users         usersRepository.Repository
+	Cities        citiesRepository.Repository
+	Reasons       reasonsRepository.Repository
+	Types         typesRepository.Repository
+	Audit         auditRepository.Repository
+	Outbox        outboxRepository.Repository
+	Transactions  transactions
+	Cache         sources.Cache
+	Degradation   *Degradation
+	Processed     *ProcessedIDs
+	Duplicates    *tools.DuplicateDetector
+	Translator    *tools.EntryTranslator
+	Geocoder      tools.Geocoder
+	Masker        *Masker
+	TextFilter    *textfilter.Filter
+	Journal       *wal.Journal
+	Strategy      queue.Strategy
+	ClaimDuration time.Duration
+
+	// While Mongo is down the hidden entries and cities are the ones read last.
+	knownHiddenIDs lastKnown[[]int]
+	knownCities    lastKnown[[]*citiesRepository.City]
+	knownReasons   lastKnown[[]*reasonsRepository.Reason]
+	knownTypes     lastKnown[[]*typesRepository.LocationType]
+}
+
+// registerServing adds the routes entries are served, resolved and released through.
+func registerServing(app fiber.Router, auth Auth, limit fiber.Handler, idempotencyKeys idempotencyRepository.Repository, s *Serving) {
+	app.Get("/get-location", auth.Identify, limit, s.GetLocation)
+	app.Get("/get-locations", auth.Identify, limit, s.GetLocations)
+	app.Post("/resolve", auth.Identify, limit, idempotent(idempotencyKeys, s.Degradation), s.Resolve)
+	app.Post("/resolve/bulk", auth.RequirePerm(usersRepository.PermSubmit), limit, s.BulkResolve)
+	app.Post("/release/:entry_id", auth.Identify, limit, s.Release)
+}
+
+func (s *Serving) cities(c *fiber.Ctx) ([]*citiesRepository.City, error) {
+	return s.knownCities.get(s.Degradation, func() ([]*citiesRepository.City, error) { return s.Cities.GetCities(c.Context()) })
+}
+
+// availableLocations is the raw feed without what is processed, claimed by someone else or hidden, narrowed
+// down by the city_id, other, starting_at and coord_suspect query parameters. In degraded mode the claims
+// are the ones in memory, and the preferences and assignments are skipped when they can't be read.
+func (s *Serving) availableLocations(c *fiber.Ctx) ([]*locationsRepository.Location, error) {
+	coordSuspect := c.Query("coord_suspect")
+	if coordSuspect != "" && coordSuspect != coordSuspectExclude && coordSuspect != coordSuspectFirst {
+		return nil, badRequest(fmt.Sprintf("coord_suspect must be %s or %s", coordSuspectExclude, coordSuspectFirst))
+	}
+
+	locations, err := tools.GetAllLocations(c.Context(), s.Cache)
+	if err != nil {
+		logrus.Errorf("Couldn't get locations: %s", err)
+
+		return nil, serviceUnavailable("The locations feed is unavailable, try again later.")
+	}
+
+	owner := claimOwner(c)
+	degraded := s.Degradation.Degraded()
+
+	claimedIDs := make([]int, 0)
+	if !degraded {
+		if claimedIDs, err = s.Locations.GetClaimedIDs(c.Context(), owner); err != nil {
+			return nil, err
+		}
+	}
+
+	hiddenIDs, err := s.knownHiddenIDs.get(s.Degradation, func() ([]int, error) { return s.Locations.GetHiddenIDs(c.Context()) })
+	if err != nil {
+		return nil, err
+	}
+
+	excluded := make(map[int]bool, len(claimedIDs)+len(hiddenIDs))
+	for _, id := range claimedIDs {
+		excluded[id] = true
+	}
+
+	for _, id := range hiddenIDs {
+		excluded[id] = true
+	}
+
+	locations = s.Processed.Available(locations, func(loc *locationsRepository.Location) bool {
+		return !excluded[loc.EntryID] && (!degraded || s.Degradation.Available(loc.EntryID, owner))
+	})
+
+	// lang serves the entries of one language, without it volunteers who listed the languages they read are
+	// only served entries in those. Entries whose text wasn't looked up yet have no language, they are kept
+	// and claimFrom checks their language once it looked the text up.
+	lang := c.Query("lang")
+	if lang != "" && !lo.Contains(language.Supported, lang) {
+		return nil, badRequest(fmt.Sprintf("lang must be one of %s", strings.Join(language.Supported, ", ")))
+	}
+
+	var prefs *usersRepository.Preferences
+	if user := currentUser(c); user != nil {
+		if prefs, err = s.Users.GetPreferences(c.Context(), user.ID); err != nil && !degraded {
+			return nil, err
+		}
+	}
+
+	langs := []string{}
+	if lang != "" {
+		langs = []string{lang}
+	} else if prefs != nil {
+		langs = prefs.Languages
+	}
+
+	c.Locals("langs", langs)
+
+	if len(langs) > 0 {
+		filteredLocations := make([]*locationsRepository.Location, 0)
+
+		for _, loc := range locations {
+			if entryLang := tools.EntryLang(s.Cache, loc); entryLang == "" || lo.Contains(langs, entryLang) {
+				filteredLocations = append(filteredLocations, loc)
+			}
+		}
+
+		locations = filteredLocations
+	}
+
+	// Volunteers a coordinator assigned to cities are only served those, city_id can pick one of them.
+	// Admins see past their own assignment with assignments=false. As long as anybody is assigned, entries
+	// aren't served anonymously, an assigned volunteer could drop the Auth-Key to get around it.
+	if currentUser(c) == nil {
+		assigned, err := s.Users.HasAssignments(c.Context())
+		if err != nil && !degraded {
+			return nil, err
+		}
+
+		if assigned {
+			return nil, unauthorized("Volunteers are assigned to cities, entries are only served with a valid auth key.")
+		}
+	}
+
+	if user := currentUser(c); user != nil {
+		assignment, err := s.Users.GetAssignment(c.Context(), user.ID)
+		if err != nil && !degraded {
+			return nil, err
+		}
+
+		override := c.Query("assignments") == "false"
+		if assignment != nil && override && user.PermLevel < usersRepository.PermAdmin {
+			return nil, forbidden("Only admins can ignore their assignment.")
+		}
+
+		if assignment != nil && !override {
+			if c.Query("city_id") == "0" || c.Query("other") == "true" {
+				return nil, forbidden("You are assigned to cities, entries outside of them aren't served to you.")
+			}
+
+			if cityID := c.QueryInt("city_id"); cityID > 0 && !lo.Contains(assignment.CityIDs, cityID) {
+				return nil, forbidden("You aren't assigned to this city.")
+			}
+
+			cityList, err := s.cities(c)
+			if err != nil {
+				return nil, err
+			}
+
+			assigned := lo.Filter(cityList, func(city *citiesRepository.City, _ int) bool {
+				return lo.Contains(assignment.CityIDs, city.ID)
+			})
+
+			filteredLocations := make([]*locationsRepository.Location, 0)
+
+			for _, loc := range locations {
+				for _, city := range assigned {
+					if city.Contains(loc.Loc[0], loc.Loc[1]) {
+						filteredLocations = append(filteredLocations, loc)
+
+						break
+					}
+				}
+			}
+
+			locations = filteredLocations
+		}
+	}
+
+	// city_id=0 or other=true serves the entries outside every configured region, mostly spam to triage.
+	if c.Query("city_id") == "0" || c.Query("other") == "true" {
+		cityList, err := s.cities(c)
+		if err != nil {
+			return nil, err
+		}
+
+		filteredLocations := make([]*locationsRepository.Location, 0)
+
+		for _, loc := range locations {
+			inCity := false
+
+			for _, city := range cityList {
+				if city.Contains(loc.Loc[0], loc.Loc[1]) {
+					inCity = true
+
+					break
+				}
+			}
+
+			if !inCity {
+				filteredLocations = append(filteredLocations, loc)
+			}
+		}
+
+		locations = filteredLocations
+	}
+
+	cityID := c.QueryInt("city_id")
+	if cityID > 0 {
+		cityList, err := s.cities(c)
+		if err != nil {
+			return nil, err
+		}
+
+		city, ok := lo.Find(cityList, func(city *citiesRepository.City) bool { return city.ID == cityID })
+		if !ok {
+			return nil, notFound("city not found")
+		}
+
+		filteredLocations := make([]*locationsRepository.Location, 0)
+
+		for _, loc := range locations {
+			if city.Contains(loc.Loc[0], loc.Loc[1]) {
+				filteredLocations = append(filteredLocations, loc)
+			}
+		}
+
+		locations = filteredLocations
+	}
+
+	// Without an explicit city the volunteer's preferred cities are served first, the rest only once
+	// those run out. preferences=false ignores them.
+	if prefs != nil && c.Query("city_id") == "" && c.Query("other") == "" && c.Query("preferences") != "false" {
+		if len(prefs.CityIDs) > 0 {
+			cityList, err := s.cities(c)
+			if err != nil {
+				return nil, err
+			}
+
+			preferred := make([]*citiesRepository.City, 0, len(prefs.CityIDs))
+			for _, city := range cityList {
+				if lo.Contains(prefs.CityIDs, city.ID) {
+					preferred = append(preferred, city)
+				}
+			}
+
+			filteredLocations := make([]*locationsRepository.Location, 0)
+
+			for _, loc := range locations {
+				for _, city := range preferred {
+					if city.Contains(loc.Loc[0], loc.Loc[1]) {
+						filteredLocations = append(filteredLocations, loc)
+
+						break
+					}
+				}
+			}
+
+			if len(filteredLocations) > 0 {
+				locations = filteredLocations
+			}
+		}
+	}
+
+	startingAt := c.QueryInt("starting_at")
+	if startingAt > 0 {
+		filteredLocations := make([]*locationsRepository.Location, 0)
+
+		for _, loc := range locations {
+			if loc.Epoch >= startingAt {
+				filteredLocations = append(filteredLocations, loc)
+			}
+		}
+
+		locations = filteredLocations
+	}
+
+	if coordSuspect == coordSuspectExclude {
+		filteredLocations := make([]*locationsRepository.Location, 0)
+
+		for _, loc := range locations {
+			if !loc.CoordSuspect {
+				filteredLocations = append(filteredLocations, loc)
+			}
+		}
+
+		locations = filteredLocations
+	}
+
+	return locations, nil
+}
+
+// claimFrom claims up to count of the locations in the order of the strategy query parameter. The
+// returned locations are copies with their text, map link and address filled in.
+func (s *Serving) claimFrom(c *fiber.Ctx, locations []*locationsRepository.Location, count int) ([]*locationsRepository.Location, error) {
+	strategy := s.Strategy
+	if c.Query("strategy") != "" {
+		var err error
+
+		strategy, err = queue.ParseStrategy(c.Query("strategy"))
+		if err != nil {
+			return nil, badRequest(err.Error())
+		}
+	}
+
+	candidates := make([]queue.Candidate, 0, len(locations))
+	byID := make(map[int]*locationsRepository.Location, len(locations))
+	for _, loc := range locations {
+		candidates = append(candidates, queue.Candidate{
+			ID:    loc.EntryID,
+			Epoch: loc.Epoch,
+			Lat:   loc.Loc[0],
+			Lng:   loc.Loc[1],
+			Score: loc.Score,
+		})
+		byID[loc.EntryID] = loc
+	}
+
+	owner := claimOwner(c)
+	showPII := canSeePII(c)
+	langs, _ := c.Locals("langs").([]string)
+	selected := make([]*locationsRepository.Location, 0, count)
+	candidateQueue := queue.New(strategy, candidates, time.Now())
+
+	// With max_claims set, somebody holding that many entries is only served the ones they already hold
+	// until they resolve or release some.
+	degraded := s.Degradation.Degraded()
+
+	// Without Mongo the held entries aren't known, max_claims isn't enforced until it is back.
+	held := make(map[int]bool)
+	if s.Environment.MaxClaims > 0 && !degraded {
+		ownedIDs, err := s.Locations.GetOwnedClaimIDs(c.Context(), owner)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, id := range ownedIDs {
+			held[id] = true
+		}
+	}
+
+	capped := false
+
+	// Entries whose text was looked up before are cached, so they can still be served while the
+	// lookups fail.
+	var lookupErr error
+
+	for len(selected) < count {
+		id, ok := candidateQueue.Pop()
+		if !ok {
+			break
+		}
+
+		if s.Environment.MaxClaims > 0 && !degraded && len(held) >= s.Environment.MaxClaims && !held[id] {
+			capped = true
+
+			continue
+		}
+
+		loc := byID[id]
+
+		// Some sources only list coordinates, their text has to be looked up. Its language is only known
+		// after that, entries in languages which weren't asked for are skipped.
+		text, lang := loc.OriginalMessage, loc.Lang
+		if text == "" {
+			singleData, err := tools.GetSingleLocation(c.Context(), loc.EntryID, s.Cache)
+			if err != nil {
+				if lookupErr == nil {
+					logrus.Errorf("Couldn't look up entry %d: %s", loc.EntryID, err)
+				}
+
+				lookupErr = err
+
+				continue
+			}
+
+			text, lang = singleData.FullText, singleData.Lang
+			if lang == "" {
+				lang = language.Detect(text)
+			}
+
+			if lang != "" && len(langs) > 0 && !lo.Contains(langs, lang) {
+				continue
+			}
+		}
+
+		if !degraded {
+			exists, err := s.Locations.IsDuplicate(c.Context(), text)
+			if err != nil {
+				return nil, err
+			}
+
+			if exists {
+				continue
+			}
+		}
+
+		if _, isDuplicate := s.Duplicates.IsDuplicate(loc, text); isDuplicate {
+			continue
+		}
+
+		claimed := false
+		if degraded {
+			claimed = s.Degradation.Claim(loc.EntryID, owner, s.ClaimDuration)
+		} else {
+			var err error
+			if claimed, err = s.Locations.ClaimLocation(c.Context(), loc.EntryID, owner, s.ClaimDuration, s.Environment.MaxClaims); err != nil {
+				// Another request of the same owner claimed the last free slot in the meantime.
+				if errors.Is(err, locationsRepository.ErrTooManyClaims) {
+					capped = true
+
+					continue
+				}
+
+				return nil, err
+			}
+		}
+
+		if !claimed {
+			continue
+		}
+
+		held[loc.EntryID] = true
+
+		// The feed is shared between requests, what is served is filled in on a copy.
+		served := *loc
+		served.OriginalMessage = s.Masker.Text(c, text)
+		served.PhoneNumbers = nil
+		if showPII {
+			served.PhoneNumbers = phones.Extract(text)
+		}
+		served.OriginalLocation = fmt.Sprintf("https://www.google.com/maps/?q=%f,%f&ll=%f,%f&z=21", loc.Loc[0], loc.Loc[1], loc.Loc[0], loc.Loc[1])
+		served.Lang = lang
+
+		// The translation is shared by everybody the entry is served to, the provider only ever gets the
+		// text with the phone and ID numbers masked. It is only there once it was made in the background,
+		// like the address it is left out when it fails.
+		if s.Translator != nil {
+			served.Translation = s.Translator.Translate(c.Context(), loc.EntryID, s.TextFilter.Apply(text, false), served.Lang)
+		}
+
+		// The address is a convenience, the entry is still served when the geocoder is down.
+		if s.Geocoder != nil {
+			if address, err := s.Geocoder.ReverseGeocode(c.Context(), loc.Loc[0], loc.Loc[1]); err == nil {
+				served.Address = address
+			}
+		}
+
+		selected = append(selected, &served)
+	}
+
+	if len(selected) == 0 && lookupErr != nil {
+		return nil, serviceUnavailable("The locations feed is unavailable, try again later.")
+	}
+
+	if len(selected) == 0 && capped {
+		return nil, tooManyRequests(fmt.Sprintf("You can hold %d entries at once, resolve or release some first.", s.Environment.MaxClaims))
+	}
+
+	return selected, nil
+}
+
+// claimLocations is claimFrom, with coord_suspect=first the entries with suspicious coordinates are claimed
+// before the others so they get checked and corrected early.
+func (s *Serving) claimLocations(c *fiber.Ctx, locations []*locationsRepository.Location, count int) ([]*locationsRepository.Location, error) {
+	if c.Query("coord_suspect") != coordSuspectFirst {
+		return s.claimFrom(c, locations, count)
+	}
+
+	suspects := make([]*locationsRepository.Location, 0)
+	others := make([]*locationsRepository.Location, 0, len(locations))
+
+	for _, loc := range locations {
+		if loc.CoordSuspect {
+			suspects = append(suspects, loc)
+		} else {
+			others = append(others, loc)
+		}
+	}
+
+	// Hitting max_claims among the suspects can still leave held entries among the others.
+	selected, err := s.claimFrom(c, suspects, count)
+
+	var apiErr *APIError
+	if errors.As(err, &apiErr) && apiErr.Status == fiber.StatusTooManyRequests {
+		err = nil
+	}
+
+	if err != nil || len(selected) == count {
+		return selected, err
+	}
+
+	more, err := s.claimFrom(c, others, count-len(selected))
+	if err != nil {
+		return nil, err
+	}
+
+	return append(selected, more...), nil
+}
+
+func (s *Serving) GetLocation(c *fiber.Ctx) error {
+	locations, err := s.availableLocations(c)
+	if err != nil {
+		return err
+	}
+
+	selected, err := s.claimLocations(c, locations, 1)
+	if err != nil {
+		return err
+	}
+
+	if len(selected) == 0 {
+		return c.JSON(&GetLocationResponse{
+			Count:    0,
+			Location: nil,
+			Stale:    tools.FeedStale(),
+			Degraded: s.Degradation.Degraded(),
+		})
+	}
+
+	setEntryID(c, selected[0].EntryID)
+
+	return c.JSON(&GetLocationResponse{
+		Count:    len(locations),
+		Location: selected[0],
+		Stale:    tools.FeedStale(),
+		Degraded: s.Degradation.Degraded(),
+	})
+}
+
+func (s *Serving) GetLocations(c *fiber.Ctx) error {
+	count := c.QueryInt("count", defaultBatchSize)
+	if count < 1 || count > maxBatchSize {
+		return badRequest(fmt.Sprintf("count must be between 1 and %d", maxBatchSize))
+	}
+
+	locations, err := s.availableLocations(c)
+	if err != nil {
+		return err
+	}
+
+	selected, err := s.claimLocations(c, locations, count)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(&GetLocationsResponse{
+		Count:     len(locations),
+		Locations: selected,
+		Stale:     tools.FeedStale(),
+		Degraded:  s.Degradation.Degraded(),
+	})
+}
+
+// storeResolution writes a resolution of /resolve or /resolve/bulk. On a replica set the resolution, its
+// audit event and its outbox message are written together or not at all. Otherwise the resolution is what
+// counts, the other two are only logged when they fail. The outbox dispatcher turns the message into webhook
+// deliveries. The write isn't tied to the request, a client going away doesn't abort it halfway.
+func (s *Serving) storeResolution(resolution *tools.Resolution) error {
+	ctx := context.Background()
+	entry, event := resolution.Entry, resolution.Event
+	transactional := s.Transactions.SupportsTransactions(ctx)
+
+	if err := s.Transactions.Transaction(ctx, func(txCtx context.Context) error {
+		if err := s.Locations.ResolveLocation(txCtx, entry); err != nil {
+			return err
+		}
+
+		if err := s.Audit.Record(txCtx, event); err != nil {
+			if transactional {
+				return err
+			}
+
+			logrus.Errorf("Couldn't record %s audit event: %s", event.Action, err)
+		}
+
+		if err := tools.QueueResolved(txCtx, s.Outbox, entry); err != nil {
+			if transactional {
+				return err
+			}
+
+			logrus.Errorf("Couldn't queue the resolution of entry %d: %s", entry.EntryID, err)
+		}
+
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	if transactional {
+		s.Locations.Publish(entry)
+	}
+
+	return nil
+}
+
+func (s *Serving) Resolve(c *fiber.Ctx) error {
+	// In strict mode every resolution has to be attributable, anonymous ones are rejected before anything
+	// is claimed.
+	if s.Environment.StrictSender && currentUser(c) == nil {
+		return unauthorized("Resolving requires a valid auth key.")
+	}
+
+	body := &ResolveBody{}
+
+	if err := json.Unmarshal(c.Body(), body); err != nil {
+		return badRequest(err.Error())
+	}
+
+	setEntryID(c, body.ID)
+
+	// While Mongo is down the resolution is checked against what is known and queued, dry runs need the
+	// reads it can't do.
+	degraded := s.Degradation.Degraded()
+	if degraded && c.Query("dry_run") == "true" {
+		return serviceUnavailable("Dry runs aren't available while the database is down.")
+	}
+
+	reasonList, err := s.knownReasons.get(s.Degradation, func() ([]*reasonsRepository.Reason, error) { return s.Reasons.GetReasons(c.Context()) })
+	if err != nil {
+		return err
+	}
+
+	typeList, err := s.knownTypes.get(s.Degradation, func() ([]*typesRepository.LocationType, error) { return s.Types.GetTypes(c.Context()) })
+	if err != nil {
+		return err
+	}
+
+	if err := body.Validate(reasonList, typeList); err != nil {
+		return validationFailed(err)
+	}
+
+	if s.Processed.Contains(body.ID) || degraded && s.Degradation.Queued(body.ID) {
+		return conflict("this location is already checked")
+	}
+
+	owner := claimOwner(c)
+
+	claimedByOther := false
+	if degraded {
+		claimedByOther = !s.Degradation.Available(body.ID, owner)
+	} else if claimedByOther, err = s.Locations.IsClaimed(c.Context(), body.ID, owner); err != nil {
+		return err
+	}
+
+	if claimedByOther {
+		return conflict("this location is claimed by another user")
+	}
+
+	hidden := false
+	if degraded {
+		hidden = lo.Contains(s.knownHiddenIDs.last(), body.ID)
+	} else if hidden, err = s.Locations.IsHidden(c.Context(), body.ID); err != nil {
+		return err
+	}
+
+	if hidden {
+		return conflict("this location was removed by a moderator")
+	}
+
+	locations, err := tools.GetAllLocations(c.Context(), s.Cache)
+	if err != nil {
+		return err
+	}
+
+	originalLocation := ""
+	location := make([]float64, 0)
+	epoch := 0
+	source := ""
+	eventID := ""
+
+	var feedLocation *locationsRepository.Location
+	for _, loc := range locations {
+		if loc.EntryID == body.ID {
+			feedLocation = loc
+			originalLocation = fmt.Sprintf("https://www.google.com/maps/?q=%f,%f&ll=%f,%f&z=21", loc.Loc[0], loc.Loc[1], loc.Loc[0], loc.Loc[1])
+			location = loc.Loc
+			epoch = loc.Epoch
+			source = loc.Source
+			eventID = loc.EventID
+		}
+	}
+
+	if eventID == "" {
+		eventID = tools.ActiveEventID(c.Context())
+	}
+
+	originalText := ""
+	if feedLocation != nil {
+		originalText = feedLocation.OriginalMessage
+	}
+
+	if singleData, ok := tools.CachedSingleLocation(s.Cache, body.ID); ok && originalText == "" {
+		originalText = singleData.FullText
+	}
+
+	// Clients send back the text they were served, which is filtered. The original is stored instead, the
+	// duplicate checks compare the original texts.
+	if originalText != "" && body.TweetContents != originalText && s.Masker.Text(c, originalText) == body.TweetContents {
+		body.TweetContents = originalText
+	}
+
+	sender := currentUser(c)
+
+	// Spam reports are analysed per reporter, so they can't be anonymous.
+	if body.Spam && sender == nil {
+		return unauthorized("spam reports require an auth key")
+	}
+
+	status := locationsRepository.StatusResolved
+	if s.Environment.TwoPersonReview {
+		status = locationsRepository.StatusPendingReview
+	}
+
+	// The language is the one of the text the entry came with when the resolution doesn't carry any.
+	text := body.TweetContents
+	if text == "" {
+		text = originalText
+	}
+
+	entry := &locationsRepository.LocationDB{
+		ID:               primitive.NewObjectIDFromTimestamp(time.Now()),
+		EntryID:          body.ID,
+		Type:             body.LocationType,
+		TypeCode:         body.TypeCode(),
+		Location:         location,
+		Corrected:        body.Corrected(),
+		OriginalAddress:  originalLocation,
+		CorrectedAddress: body.NewAddress,
+		CorrectedLat:     body.CorrectedLat,
+		CorrectedLng:     body.CorrectedLng,
+		ParsedAddress:    body.ParsedAddress(),
+		Reason:           body.Reason,
+		Sender:           sender,
+		OpenAddress:      body.OpenAddress,
+		Apartment:        body.Apartment,
+		TweetContents:    body.TweetContents,
+		Lang:             language.Detect(text),
+		PhoneNumbers:     body.PhoneNumbers(),
+		Epoch:            epoch,
+		Status:           status,
+		Source:           source,
+		EventID:          eventID,
+	}
+
+	// A dry run stops here with what would be stored, the checks which can only fail when writing are done
+	// as reads.
+	if c.Query("dry_run") == "true" {
+		resolved, err := s.Locations.IsResolved(c.Context(), body.ID)
+		if err != nil {
+			return err
+		}
+
+		if resolved {
+			return conflict("this location is already checked")
+		}
+
+		// The entry goes back to the volunteer, its text is filtered like the one they were served.
+		response := &ResolveDryRunResponse{Entry: s.Masker.Entry(c, entry)}
+
+		if text != "" {
+			if response.TextDuplicate, err = s.Locations.IsDuplicate(c.Context(), text); err != nil {
+				return err
+			}
+
+			if feedLocation != nil {
+				response.DuplicateOf, _ = s.Duplicates.IsDuplicate(feedLocation, text)
+			}
+		}
+
+		return c.JSON(response)
+	}
+
+	event := auditEvent(c, &auditRepository.Event{
+		Action:  auditRepository.ActionResolve,
+		EntryID: body.ID,
+		Changes: auditRepository.Diff(nil, entry),
+		At:      time.Now(),
+	})
+	resolution := &tools.Resolution{Entry: entry, Event: event}
+
+	journalResolutions(s.Journal, resolution)
+
+	// The resolution is kept on disk and written once Mongo is back, the replay drops it when the entry was
+	// resolved elsewhere in the meantime.
+	if degraded {
+		if err := s.Degradation.Queue(resolution); err != nil {
+			logrus.Errorf("Couldn't queue the resolution of entry %d: %s", entry.EntryID, err)
+			journalOutcome(s.Journal, resolution, tools.OutcomeRejected)
+
+			return serviceUnavailable("The database is down and the resolution couldn't be queued, try again later.")
+		}
+
+		journalOutcome(s.Journal, resolution, tools.OutcomeQueued)
+
+		metrics.Resolves.WithLabelValues(body.Reason).Inc()
+
+		return c.Status(fiber.StatusAccepted).SendString("Queued, it is saved once the database is back.")
+	}
+
+	if err := s.storeResolution(resolution); err != nil {
+		journalOutcome(s.Journal, resolution, tools.OutcomeRejected)
+
+		if errors.Is(err, locationsRepository.ErrAlreadyResolved) {
+			return conflict("this location is already checked")
+		}
+
+		return err
+	}
+
+	journalOutcome(s.Journal, resolution, tools.OutcomeStored)
+
+	if status == locationsRepository.StatusResolved {
+		s.Processed.Add(body.ID)
+	}
+	metrics.Resolves.WithLabelValues(body.Reason).Inc()
+
+	if err := s.Locations.ReleaseLocation(c.Context(), body.ID, owner, locationsRepository.ClaimResolved); err != nil {
+		logrus.Errorln(err)
+	}
+
+	return c.SendString("Successfully added!")
+}
+
+func (s *Serving) BulkResolve(c *fiber.Ctx) error {
+	body := &BulkResolveBody{}
+
+	if err := json.Unmarshal(c.Body(), body); err != nil {
+		return badRequest(err.Error())
+	}
+
+	if len(body.IDs) == 0 {
+		return badRequest("ids must not be empty")
+	}
+
+	reasonList, err := s.Reasons.GetReasons(c.Context())
+	if err != nil {
+		return err
+	}
+
+	typeList, err := s.Types.GetTypes(c.Context())
+	if err != nil {
+		return err
+	}
+
+	v := validation.New()
+	if v.Required("reason", body.Reason) {
+		body.Reason, _ = v.OneOf("reason", validation.NormalizeText(body.Reason), reasonsRepository.Codes(reasonList))
+	}
+	v.OneOfInt("type", body.LocationType, typesRepository.SelectableIDs(typeList))
+
+	if err := v.Err(); err != nil {
+		return validationFailed(err)
+	}
+
+	corrected := false
+	if reason := reasonsRepository.Find(reasonList, body.Reason); reason != nil {
+		corrected = reason.Corrected
+	}
+
+	typeCode := ""
+	if locationType := typesRepository.Find(typeList, body.LocationType); locationType != nil {
+		typeCode = locationType.Code
+	}
+
+	locations, err := tools.GetAllLocations(c.Context(), s.Cache)
+	if err != nil {
+		return err
+	}
+
+	rawLocations := make(map[int]*locationsRepository.Location, len(locations))
+	for _, loc := range locations {
+		rawLocations[loc.EntryID] = loc
+	}
+
+	sender := currentUser(c)
+	owner := claimOwner(c)
+	activeEventID := tools.ActiveEventID(c.Context())
+	response := &BulkResolveResponse{
+		Resolved: make([]int, 0, len(body.IDs)),
+		Skipped:  make([]BulkResolveSkip, 0),
+	}
+
+	status := locationsRepository.StatusResolved
+	if s.Environment.TwoPersonReview {
+		status = locationsRepository.StatusPendingReview
+	}
+
+	seen := make(map[int]bool, len(body.IDs))
+	resolved := make([]*locationsRepository.LocationDB, 0, len(body.IDs))
+
+	for _, id := range body.IDs {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+
+		isResolved, err := s.Locations.IsResolved(c.Context(), id)
+		if err != nil {
+			return err
+		}
+
+		if isResolved {
+			response.Skipped = append(response.Skipped, BulkResolveSkip{ID: id, Reason: "already resolved"})
+
+			continue
+		}
+
+		claimedByOther, err := s.Locations.IsClaimed(c.Context(), id, owner)
+		if err != nil {
+			return err
+		}
+
+		if claimedByOther {
+			response.Skipped = append(response.Skipped, BulkResolveSkip{ID: id, Reason: "claimed by another user"})
+
+			continue
+		}
+
+		location := &locationsRepository.LocationDB{
+			ID:        primitive.NewObjectIDFromTimestamp(time.Now()),
+			EntryID:   id,
+			Type:      body.LocationType,
+			TypeCode:  typeCode,
+			Location:  make([]float64, 0),
+			Corrected: corrected,
+			Reason:    body.Reason,
+			Sender:    sender,
+			Status:    status,
+			EventID:   activeEventID,
+		}
+
+		if loc, ok := rawLocations[id]; ok {
+			location.Location = loc.Loc
+			location.Epoch = loc.Epoch
+			if loc.EventID != "" {
+				location.EventID = loc.EventID
+			}
+			location.OriginalAddress = fmt.Sprintf("https://www.google.com/maps/?q=%f,%f&ll=%f,%f&z=21", loc.Loc[0], loc.Loc[1], loc.Loc[0], loc.Loc[1])
+		}
+
+		resolved = append(resolved, location)
+	}
+
+	resolutions := make([]*tools.Resolution, 0, len(resolved))
+	for _, entry := range resolved {
+		resolutions = append(resolutions, &tools.Resolution{
+			Entry: entry,
+			Event: auditEvent(c, &auditRepository.Event{
+				Action:  auditRepository.ActionBulkResolve,
+				EntryID: entry.EntryID,
+				Changes: auditRepository.Diff(nil, entry),
+				At:      time.Now(),
+			}),
+		})
+	}
+	// Every resolution is written like the one of /resolve, with its audit event and outbox message. Entries
+	// resolved by someone else since the checks above are skipped. Once a write fails for another reason the
+	// rest isn't tried, the database is most likely gone.
+	var writeErr error
+	for _, resolution := range resolutions {
+		entry := resolution.Entry
+
+		if writeErr != nil {
+			response.Skipped = append(response.Skipped, BulkResolveSkip{ID: entry.EntryID, Reason: "couldn't be stored, try again"})
+
+			continue
+		}
+
+		journalResolutions(s.Journal, resolution)
+
+		if err := s.storeResolution(resolution); err != nil {
+			journalOutcome(s.Journal, resolution, tools.OutcomeRejected)
+
+			if errors.Is(err, locationsRepository.ErrAlreadyResolved) {
+				response.Skipped = append(response.Skipped, BulkResolveSkip{ID: entry.EntryID, Reason: "resolved by someone else in the meantime"})
+
+				continue
+			}
+
+			logrus.Errorf("Couldn't store the resolution of entry %d: %s", entry.EntryID, err)
+
+			if len(response.Resolved) == 0 {
+				return err
+			}
+
+			writeErr = err
+			response.Skipped = append(response.Skipped, BulkResolveSkip{ID: entry.EntryID, Reason: "couldn't be stored, try again"})
+
+			continue
+		}
+
+		response.Resolved = append(response.Resolved, entry.EntryID)
+		journalOutcome(s.Journal, resolution, tools.OutcomeStored)
+
+		if err := s.Locations.ReleaseLocation(c.Context(), entry.EntryID, owner, locationsRepository.ClaimResolved); err != nil {
+			logrus.Errorln(err)
+		}
+	}
+
+	if status == locationsRepository.StatusResolved {
+		s.Processed.Add(response.Resolved...)
+	}
+	metrics.Resolves.WithLabelValues(body.Reason).Add(float64(len(response.Resolved)))
+
+	return c.JSON(response)
+}
+
+func (s *Serving) Release(c *fiber.Ctx) error {
+	entryID, err := c.ParamsInt("entry_id")
+	if err != nil {
+		return badRequest(err.Error())
+	}
+
+	if s.Degradation.Degraded() {
+		s.Degradation.Release(entryID, claimOwner(c))
+
+		return c.SendString("Successfully released!")
+	}
+
+	if err := s.Locations.ReleaseLocation(c.Context(), entryID, claimOwner(c), locationsRepository.ClaimReleased); err != nil {
+		return err
+	}
+
+	recordAudit(c, s.Audit, &auditRepository.Event{
+		Action:  auditRepository.ActionRelease,
+		EntryID: entryID,
+	})
+
+	return c.SendString("Successfully released!")
+}
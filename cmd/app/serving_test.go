@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	locationsRepository "github.com/YusufOzmen01/veri-kontrol-backend/repository/locations"
+	usersRepository "github.com/YusufOzmen01/veri-kontrol-backend/repository/users"
+	"github.com/YusufOzmen01/veri-kontrol-backend/tools"
+)
+
+func TestServeAndResolve(t *testing.T) {
+	tools.SetSources([]tools.Source{&feedSource{locations: []*locationsRepository.Location{
+		{EntryID: 1, Loc: []float64{37.5753, 36.9228}, Epoch: int(time.Now().Unix()), OriginalMessage: "Enkaz altında ses geliyor, acil yardım"},
+	}}})
+	defer tools.SetSources(nil)
+
+	ta := newTestApp(t)
+	volunteer, authKey := ta.addUser(t, "volunteer", usersRepository.PermSubmit)
+	_, otherKey := ta.addUser(t, "other", usersRepository.PermSubmit)
+
+	headers := bearer(ta.login(t, authKey))
+
+	served := &GetLocationResponse{}
+	if status := ta.do(t, "GET", "/get-location", nil, headers, served); status != http.StatusOK {
+		t.Fatalf("GET /get-location = %d, want 200", status)
+	}
+
+	if served.Location == nil || served.Location.EntryID != 1 {
+		t.Fatalf("GET /get-location served %+v, want entry 1", served.Location)
+	}
+
+	// The entry is claimed by the volunteer, nobody else is served it in the meantime.
+	other := &GetLocationResponse{}
+	if status := ta.do(t, "GET", "/get-location", nil, bearer(ta.login(t, otherKey)), other); status != http.StatusOK || other.Location != nil {
+		t.Fatalf("GET /get-location of another volunteer = %d with %+v, want nothing served", status, other.Location)
+	}
+
+	body := &ResolveBody{
+		ID:            served.Location.EntryID,
+		LocationType:  locationsRepository.TypeWreckage,
+		Reason:        locationsRepository.ReasonNoError,
+		TweetContents: served.Location.OriginalMessage,
+	}
+
+	if status := ta.do(t, "POST", "/resolve", body, headers, nil); status != http.StatusOK {
+		t.Fatalf("POST /resolve = %d, want 200", status)
+	}
+
+	resolved, err := ta.locations.GetLocations(context.Background(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(resolved) != 1 || resolved[0].Sender == nil || resolved[0].Sender.ID != volunteer.ID || resolved[0].TweetContents != body.TweetContents {
+		t.Fatalf("stored resolutions %+v, want the one of the volunteer", resolved)
+	}
+
+	// The audit event and the outbox message are written with the resolution.
+	if len(ta.audit.events) != 1 || len(ta.outbox.messages) != 1 {
+		t.Fatalf("%d audit events and %d outbox messages, want 1 of each", len(ta.audit.events), len(ta.outbox.messages))
+	}
+
+	if status := ta.do(t, "POST", "/resolve", body, headers, nil); status != http.StatusConflict {
+		t.Fatalf("POST /resolve of a resolved entry = %d, want 409", status)
+	}
+
+	if status := ta.do(t, "GET", "/get-location", nil, headers, served); status != http.StatusOK || served.Location != nil {
+		t.Fatalf("GET /get-location after resolving = %d with %+v, want nothing served", status, served.Location)
+	}
+}
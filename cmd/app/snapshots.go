@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/YusufOzmen01/veri-kontrol-backend/core/metrics"
+	"github.com/YusufOzmen01/veri-kontrol-backend/core/redact"
+	"github.com/YusufOzmen01/veri-kontrol-backend/core/scheduler"
+	"github.com/YusufOzmen01/veri-kontrol-backend/core/storage"
+	"github.com/YusufOzmen01/veri-kontrol-backend/repository/locations"
+	"github.com/YusufOzmen01/veri-kontrol-backend/tools"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	DefaultSnapshotSchedule = "0 * * * *"
+	snapshotTimeout         = 10 * time.Minute
+)
+
+type snapshotFormat struct {
+	name        string
+	file        string
+	contentType string
+	write       func(ctx context.Context, w io.Writer, locationRepository locations.Repository, filter *locations.LocationFilter) error
+}
+
+var snapshotFormats = []snapshotFormat{
+	{"csv", "locations.csv", "text/csv; charset=utf-8", func(ctx context.Context, w io.Writer, l locations.Repository, f *locations.LocationFilter) error {
+		return tools.WriteCSV(ctx, w, l, f, tools.CSVColumns, redact.Redactor{})
+	}},
+	{"geojson", "locations.geojson", "application/geo+json", func(ctx context.Context, w io.Writer, l locations.Repository, f *locations.LocationFilter) error {
+		return tools.WriteGeoJSON(ctx, w, l, f, redact.Redactor{})
+	}},
+}
+
+// Snapshots uploads every resolution of the active event as CSV and GeoJSON to a bucket on a schedule, so
+// analysts can read the files instead of calling the API. Nobody's scopes are checked on the way to the bucket,
+// so personal data is always redacted.
+type Snapshots struct {
+	locations locations.Repository
+	bucket    storage.Bucket
+	prefix    string
+}
+
+func NewSnapshots(locations locations.Repository, bucket storage.Bucket, prefix string) *Snapshots {
+	return &Snapshots{
+		locations: locations,
+		bucket:    bucket,
+		prefix:    prefix,
+	}
+}
+
+// Upload writes one snapshot per format under prefix/YYYY/MM/DD/HH. A format which fails doesn't stop the
+// others, the returned error is the last failure.
+func (s *Snapshots) Upload(ctx context.Context, at time.Time) error {
+	ctx, cancel := context.WithTimeout(ctx, snapshotTimeout)
+	defer cancel()
+
+	folder := s.prefix + at.UTC().Format("2006/01/02/15")
+
+	var lastErr error
+	for _, format := range snapshotFormats {
+		buf := &bytes.Buffer{}
+
+		err := format.write(ctx, buf, s.locations, &locations.LocationFilter{EventID: tools.ActiveEventID(ctx), Status: locations.StatusResolved})
+		if err == nil {
+			err = s.bucket.Put(ctx, folder+"/"+format.file, format.contentType, buf.Bytes())
+		}
+
+		metrics.ObserveSnapshot(format.name, err)
+
+		if err != nil {
+			lastErr = fmt.Errorf("%s snapshot: %w", format.name, err)
+			logrus.Errorf("Couldn't upload the %s snapshot: %s", format.name, err)
+
+			continue
+		}
+
+		logrus.Infof("Uploaded the %s snapshot to %s/%s (%d bytes)", format.name, folder, format.file, buf.Len())
+	}
+
+	return lastErr
+}
+
+func (s *Snapshots) Run(ctx context.Context, schedule *scheduler.Schedule) {
+	scheduler.Run(ctx, schedule, func(ctx context.Context) {
+		_ = s.Upload(ctx, time.Now())
+	})
+}
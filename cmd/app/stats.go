@@ -0,0 +1,666 @@
+package main
+
+import (
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/YusufOzmen01/veri-kontrol-backend/core/sources"
+	citiesRepository "github.com/YusufOzmen01/veri-kontrol-backend/repository/cities"
+	eventsRepository "github.com/YusufOzmen01/veri-kontrol-backend/repository/events"
+	"github.com/YusufOzmen01/veri-kontrol-backend/repository/locations"
+	usersRepository "github.com/YusufOzmen01/veri-kontrol-backend/repository/users"
+	"github.com/YusufOzmen01/veri-kontrol-backend/tools"
+	"github.com/gofiber/fiber/v2"
+)
+
+// The overview is cached as a snapshot, with Redis the cache needs to know its type.
+func init() {
+	gob.Register(&StatsOverviewResponse{})
+	gob.Register(&BacklogAgeResponse{})
+	gob.Register(&ThroughputResponse{})
+}
+
+type Stats interface {
+	GetModeratorStats(c *fiber.Ctx) error
+	GetLeaderboard(c *fiber.Ctx) error
+	SetLeaderboardOptOut(c *fiber.Ctx) error
+	GetOverview(c *fiber.Ctx) error
+	GetBacklogAge(c *fiber.Ctx) error
+	GetClaimStats(c *fiber.Ctx) error
+	GetThroughput(c *fiber.Ctx) error
+}
+
+type stats struct {
+	locations locations.Repository
+	users     usersRepository.Repository
+	cities    citiesRepository.Repository
+	events    eventsRepository.Repository
+	cache     sources.Cache
+	processed *ProcessedIDs
+}
+
+type LeaderboardResponse struct {
+	Period  string                        `json:"period"`
+	Entries []*locations.LeaderboardEntry `json:"entries"`
+}
+
+type LeaderboardOptOutBody struct {
+	OptOut bool `json:"opt_out"`
+}
+
+// Periods are rolling windows, "all" counts every resolution.
+var leaderboardPeriods = map[string]time.Duration{
+	"day":  24 * time.Hour,
+	"week": 7 * 24 * time.Hour,
+	"all":  0,
+}
+
+const (
+	defaultLeaderboardSize = 50
+	maxLeaderboardSize     = 500
+)
+
+type ModeratorStatsResponse struct {
+	Total      int                         `json:"total"`
+	Moderators []*locations.ModeratorStats `json:"moderators"`
+}
+
+type OverviewDay struct {
+	Day                  string  `json:"day"`
+	NewEntries           int     `json:"new_entries"`
+	Resolved             int     `json:"resolved"`
+	Spam                 int     `json:"spam"`
+	AvgResolutionSeconds float64 `json:"avg_resolution_seconds"`
+}
+
+// CityBacklog counts the entries nobody resolved yet, city_id 0 holds the ones outside every city.
+type CityBacklog struct {
+	CityID     int    `json:"city_id"`
+	Name       string `json:"name"`
+	Unresolved int    `json:"unresolved"`
+}
+
+type StatsOverviewResponse struct {
+	Days        []*OverviewDay `json:"days"`
+	Backlog     []*CityBacklog `json:"backlog"`
+	GeneratedAt time.Time      `json:"generated_at"`
+}
+
+// CityBacklogAge describes how long the unresolved entries of a city have been waiting, in seconds since they
+// were reported. Entries without a report time are counted but have no age.
+type CityBacklogAge struct {
+	CityID     int    `json:"city_id"`
+	Name       string `json:"name"`
+	Unresolved int    `json:"unresolved"`
+	Oldest     int64  `json:"oldest_seconds"`
+	P50        int64  `json:"p50_seconds"`
+	P90        int64  `json:"p90_seconds"`
+	P99        int64  `json:"p99_seconds"`
+}
+
+type BacklogAgeResponse struct {
+	Cities      []*CityBacklogAge `json:"cities"`
+	GeneratedAt time.Time         `json:"generated_at"`
+}
+
+// UserClaimStats describes the claims of one user, or of an IP for anonymous volunteers. Active is what they
+// hold right now, the other counts cover the claims which ended within the requested days.
+type UserClaimStats struct {
+	*locations.ClaimStats
+	Name   string `json:"name,omitempty"`
+	Active int    `json:"active"`
+}
+
+type ClaimStatsResponse struct {
+	Days  int               `json:"days"`
+	Users []*UserClaimStats `json:"users"`
+}
+
+// TypeThroughput counts the resolutions of one type.
+type TypeThroughput struct {
+	Type  int `json:"type"`
+	Count int `json:"count"`
+}
+
+// CityThroughput counts the resolutions of a bucket in a city, city_id 0 holds the ones outside every city.
+type CityThroughput struct {
+	CityID int               `json:"city_id"`
+	Name   string            `json:"name"`
+	Total  int               `json:"total"`
+	Types  []*TypeThroughput `json:"types"`
+}
+
+// ThroughputBucket starts at Start and lasts one bucket. Every bucket of the window is listed, the empty ones
+// have no cities.
+type ThroughputBucket struct {
+	Start  time.Time         `json:"start"`
+	Total  int               `json:"total"`
+	Cities []*CityThroughput `json:"cities"`
+}
+
+type ThroughputResponse struct {
+	BucketSeconds int64               `json:"bucket_seconds"`
+	From          time.Time           `json:"from"`
+	To            time.Time           `json:"to"`
+	Buckets       []*ThroughputBucket `json:"buckets"`
+	GeneratedAt   time.Time           `json:"generated_at"`
+}
+
+const (
+	defaultThroughputBucket = 15 * time.Minute
+	defaultThroughputWindow = 24 * time.Hour
+	minThroughputBucket     = time.Minute
+	maxThroughputBucket     = 24 * time.Hour
+	maxThroughputBuckets    = 2000
+	// throughputCacheTTL is short, the wallboard wants the current bucket to move.
+	throughputCacheTTL = time.Minute
+)
+
+const (
+	defaultOverviewDays = 7
+	maxOverviewDays     = 90
+	overviewCacheTTL    = 5 * time.Minute
+)
+
+func NewStats(locations locations.Repository, userRepository usersRepository.Repository, cities citiesRepository.Repository, events eventsRepository.Repository, cache sources.Cache, processed *ProcessedIDs) Stats {
+	return &stats{
+		locations: locations,
+		users:     userRepository,
+		cities:    cities,
+		events:    events,
+		cache:     cache,
+		processed: processed,
+	}
+}
+
+func (s *stats) GetModeratorStats(c *fiber.Ctx) error {
+	eventID, err := eventIDFromQuery(c, s.events)
+	if err != nil {
+		return badRequest(err.Error())
+	}
+
+	moderators, err := s.locations.GetModeratorStats(c.Context(), eventID)
+	if err != nil {
+		return err
+	}
+
+	total := 0
+	for _, moderator := range moderators {
+		total += moderator.Total
+	}
+
+	return c.JSON(&ModeratorStatsResponse{
+		Total:      total,
+		Moderators: moderators,
+	})
+}
+
+func (s *stats) GetLeaderboard(c *fiber.Ctx) error {
+	period := c.Query("period", "week")
+
+	window, ok := leaderboardPeriods[period]
+	if !ok {
+		return badRequest("period must be day, week or all")
+	}
+
+	size := c.QueryInt("limit", defaultLeaderboardSize)
+	if size < 1 || size > maxLeaderboardSize {
+		size = defaultLeaderboardSize
+	}
+
+	since := time.Time{}
+	if window > 0 {
+		since = time.Now().Add(-window)
+	}
+
+	eventID, err := eventIDFromQuery(c, s.events)
+	if err != nil {
+		return badRequest(err.Error())
+	}
+
+	optedOut, err := s.users.GetLeaderboardOptOutIDs(c.Context())
+	if err != nil {
+		return err
+	}
+
+	entries, err := s.locations.GetLeaderboard(c.Context(), eventID, since, optedOut, size)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(&LeaderboardResponse{
+		Period:  period,
+		Entries: entries,
+	})
+}
+
+// SetLeaderboardOptOut hides or shows the current user on the leaderboard.
+func (s *stats) SetLeaderboardOptOut(c *fiber.Ctx) error {
+	body := &LeaderboardOptOutBody{}
+
+	if err := json.Unmarshal(c.Body(), body); err != nil {
+		return badRequest(err.Error())
+	}
+
+	if err := s.users.SetLeaderboardOptOut(c.Context(), currentUser(c).ID, body.OptOut); err != nil {
+		return err
+	}
+
+	return c.SendString("Successfully updated!")
+}
+
+// GetOverview buckets the last days by UTC day. The aggregations and the walk over the feed are too slow to
+// repeat for every dashboard refresh, so the result is cached for a few minutes.
+func (s *stats) GetOverview(c *fiber.Ctx) error {
+	days := c.QueryInt("days", defaultOverviewDays)
+	if days < 1 || days > maxOverviewDays {
+		days = defaultOverviewDays
+	}
+
+	eventID, err := eventIDFromQuery(c, s.events)
+	if err != nil {
+		return badRequest(err.Error())
+	}
+
+	cacheKey := fmt.Sprintf("stats_overview_%s_%d", eventID, days)
+	if data, exists := s.cache.Get(cacheKey); exists {
+		return c.JSON(data.(*StatsOverviewResponse))
+	}
+
+	now := time.Now().UTC()
+	since := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC).AddDate(0, 0, -(days - 1))
+
+	response := &StatsOverviewResponse{
+		Days:        make([]*OverviewDay, 0, days),
+		Backlog:     make([]*CityBacklog, 0),
+		GeneratedAt: now,
+	}
+
+	byDay := make(map[string]*OverviewDay, days)
+	for day := since; !day.After(now); day = day.AddDate(0, 0, 1) {
+		overviewDay := &OverviewDay{Day: day.Format("2006-01-02")}
+
+		response.Days = append(response.Days, overviewDay)
+		byDay[overviewDay.Day] = overviewDay
+	}
+
+	daily, err := s.locations.GetDailyStats(c.Context(), eventID, since)
+	if err != nil {
+		return err
+	}
+
+	for _, stat := range daily {
+		if overviewDay, ok := byDay[stat.Day]; ok {
+			overviewDay.Resolved = stat.Resolved
+			overviewDay.Spam = stat.Spam
+			overviewDay.AvgResolutionSeconds = stat.AvgResolutionSeconds
+		}
+	}
+
+	raw, err := tools.GetAllLocations(c.Context(), s.cache)
+	if err != nil {
+		return err
+	}
+
+	raw = entriesOfEvent(raw, eventID)
+
+	cityList, err := s.cities.GetCities(c.Context())
+	if err != nil {
+		return err
+	}
+
+	for _, loc := range raw {
+		if overviewDay, ok := byDay[time.Unix(int64(loc.Epoch), 0).UTC().Format("2006-01-02")]; ok {
+			overviewDay.NewEntries++
+		}
+	}
+
+	for i, unresolved := range s.groupUnresolved(raw, cityList) {
+		backlog := &CityBacklog{Unresolved: len(unresolved)}
+		if i < len(cityList) {
+			backlog.CityID = cityList[i].ID
+			backlog.Name = cityList[i].Name
+		}
+
+		response.Backlog = append(response.Backlog, backlog)
+	}
+
+	s.cache.SetWithTTL(cacheKey, response, 1, overviewCacheTTL)
+
+	return c.JSON(response)
+}
+
+// groupUnresolved splits the raw entries nobody resolved yet by the city they are in. The result has one more
+// group than there are cities, the last one holds the entries outside every city.
+func (s *stats) groupUnresolved(raw []*locations.Location, cityList []*citiesRepository.City) [][]*locations.Location {
+	groups := make([][]*locations.Location, len(cityList)+1)
+
+	for _, loc := range raw {
+		if s.processed.Contains(loc.EntryID) || len(loc.Loc) != 2 {
+			continue
+		}
+
+		group := len(cityList)
+		for i, city := range cityList {
+			if city.Contains(loc.Loc[0], loc.Loc[1]) {
+				group = i
+
+				break
+			}
+		}
+
+		groups[group] = append(groups[group], loc)
+	}
+
+	return groups
+}
+
+// percentile uses the nearest rank on ages sorted in ascending order.
+func percentile(sorted []int64, p int) int64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+
+	rank := (p*len(sorted) + 99) / 100
+	if rank < 1 {
+		rank = 1
+	}
+
+	return sorted[rank-1]
+}
+
+// GetBacklogAge lists the cities with the longest waiting entries first, going by the 90th percentile.
+func (s *stats) GetBacklogAge(c *fiber.Ctx) error {
+	eventID, err := eventIDFromQuery(c, s.events)
+	if err != nil {
+		return badRequest(err.Error())
+	}
+
+	cacheKey := "stats_backlog_age_" + eventID
+	if data, exists := s.cache.Get(cacheKey); exists {
+		return c.JSON(data.(*BacklogAgeResponse))
+	}
+
+	raw, err := tools.GetAllLocations(c.Context(), s.cache)
+	if err != nil {
+		return err
+	}
+
+	raw = entriesOfEvent(raw, eventID)
+
+	cityList, err := s.cities.GetCities(c.Context())
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	response := &BacklogAgeResponse{
+		Cities:      make([]*CityBacklogAge, 0, len(cityList)+1),
+		GeneratedAt: now.UTC(),
+	}
+
+	for i, unresolved := range s.groupUnresolved(raw, cityList) {
+		backlog := &CityBacklogAge{Unresolved: len(unresolved)}
+		if i < len(cityList) {
+			backlog.CityID = cityList[i].ID
+			backlog.Name = cityList[i].Name
+		}
+
+		ages := make([]int64, 0, len(unresolved))
+		for _, loc := range unresolved {
+			if loc.Epoch > 0 {
+				ages = append(ages, now.Unix()-int64(loc.Epoch))
+			}
+		}
+
+		sort.Slice(ages, func(a, b int) bool { return ages[a] < ages[b] })
+
+		if len(ages) > 0 {
+			backlog.Oldest = ages[len(ages)-1]
+		}
+
+		backlog.P50 = percentile(ages, 50)
+		backlog.P90 = percentile(ages, 90)
+		backlog.P99 = percentile(ages, 99)
+
+		response.Cities = append(response.Cities, backlog)
+	}
+
+	sort.SliceStable(response.Cities, func(a, b int) bool {
+		return response.Cities[a].P90 > response.Cities[b].P90
+	})
+
+	s.cache.SetWithTTL(cacheKey, response, 1, overviewCacheTTL)
+
+	return c.JSON(response)
+}
+
+// GetClaimStats lists everybody who holds entries or held some within the last days, the most active holders
+// first. Claims are only logged since this was deployed, older ones don't show up.
+func (s *stats) GetClaimStats(c *fiber.Ctx) error {
+	days := c.QueryInt("days", defaultOverviewDays)
+	if days < 1 || days > maxOverviewDays {
+		days = defaultOverviewDays
+	}
+
+	ended, err := s.locations.GetClaimStats(c.Context(), time.Now().AddDate(0, 0, -days))
+	if err != nil {
+		return err
+	}
+
+	active, err := s.locations.CountActiveClaims(c.Context())
+	if err != nil {
+		return err
+	}
+
+	userList, err := s.users.GetUsers(c.Context())
+	if err != nil {
+		return err
+	}
+
+	names := make(map[string]string, len(userList))
+	for _, user := range userList {
+		names[user.ID.Hex()] = user.Name
+	}
+
+	byOwner := make(map[string]*UserClaimStats, len(ended)+len(active))
+	for _, stat := range ended {
+		byOwner[stat.Owner] = &UserClaimStats{ClaimStats: stat}
+	}
+
+	for owner, count := range active {
+		stat, ok := byOwner[owner]
+		if !ok {
+			stat = &UserClaimStats{ClaimStats: &locations.ClaimStats{Owner: owner}}
+			byOwner[owner] = stat
+		}
+
+		stat.Active = count
+	}
+
+	response := &ClaimStatsResponse{
+		Days:  days,
+		Users: make([]*UserClaimStats, 0, len(byOwner)),
+	}
+
+	for owner, stat := range byOwner {
+		stat.Name = names[owner]
+		response.Users = append(response.Users, stat)
+	}
+
+	sort.Slice(response.Users, func(i, j int) bool {
+		a, b := response.Users[i], response.Users[j]
+		if a.Active != b.Active {
+			return a.Active > b.Active
+		}
+
+		if a.Claims != b.Claims {
+			return a.Claims > b.Claims
+		}
+
+		return a.Owner < b.Owner
+	})
+
+	return c.JSON(response)
+}
+
+// alignToBucket rounds down to the start of the bucket, aligned to the Unix epoch like GetThroughput does.
+// time.Truncate goes by the zero time, which only agrees for buckets which divide a day.
+func alignToBucket(t time.Time, bucket time.Duration) time.Time {
+	ms := t.UnixMilli()
+
+	return time.UnixMilli(ms - ms%bucket.Milliseconds()).UTC()
+}
+
+// throughputWindow reads the bucket size and the from and to Unix times. The window is widened to whole
+// buckets, which also keeps the cache key the same while the current bucket lasts.
+func throughputWindow(c *fiber.Ctx) (time.Duration, time.Time, time.Time, error) {
+	bucket := defaultThroughputBucket
+	if query := c.Query("bucket"); query != "" {
+		parsed, err := time.ParseDuration(query)
+		if err != nil || parsed < minThroughputBucket || parsed > maxThroughputBucket || parsed%time.Minute != 0 {
+			return 0, time.Time{}, time.Time{}, badRequest("bucket must be whole minutes between 1m and 24h, like 15m or 1h")
+		}
+
+		bucket = parsed
+	}
+
+	to := time.Now()
+	if query := c.QueryInt("to"); query > 0 {
+		to = time.Unix(int64(query), 0)
+	}
+
+	from := to.Add(-defaultThroughputWindow)
+	if query := c.QueryInt("from"); query > 0 {
+		from = time.Unix(int64(query), 0)
+	}
+
+	if !from.Before(to) {
+		return 0, time.Time{}, time.Time{}, badRequest("from must be before to")
+	}
+
+	from = alignToBucket(from, bucket)
+	if aligned := alignToBucket(to, bucket); aligned.Before(to) {
+		to = aligned.Add(bucket)
+	}
+
+	if to.Sub(from)/bucket > maxThroughputBuckets {
+		return 0, time.Time{}, time.Time{}, badRequest(fmt.Sprintf("the window can have at most %d buckets, use a bigger bucket", maxThroughputBuckets))
+	}
+
+	return bucket, from, to, nil
+}
+
+// GetThroughput counts the resolutions per bucket, city and type, for the coordination room wallboard. A single
+// aggregation assigns every resolution to one city, overlapping cities don't count it twice.
+func (s *stats) GetThroughput(c *fiber.Ctx) error {
+	bucket, from, to, err := throughputWindow(c)
+	if err != nil {
+		return err
+	}
+
+	eventID, err := eventIDFromQuery(c, s.events)
+	if err != nil {
+		return badRequest(err.Error())
+	}
+
+	cacheKey := fmt.Sprintf("stats_throughput_%s_%d_%d_%d", eventID, bucket/time.Minute, from.Unix(), to.Unix())
+	if data, exists := s.cache.Get(cacheKey); exists {
+		return c.JSON(data.(*ThroughputResponse))
+	}
+
+	cityList, err := s.cities.GetCities(c.Context())
+	if err != nil {
+		return err
+	}
+
+	response := &ThroughputResponse{
+		BucketSeconds: int64(bucket / time.Second),
+		From:          from,
+		To:            to,
+		Buckets:       make([]*ThroughputBucket, 0, int(to.Sub(from)/bucket)),
+		GeneratedAt:   time.Now().UTC(),
+	}
+
+	byStart := make(map[int64]*ThroughputBucket)
+	for start := from; start.Before(to); start = start.Add(bucket) {
+		throughputBucket := &ThroughputBucket{Start: start, Cities: make([]*CityThroughput, 0)}
+
+		response.Buckets = append(response.Buckets, throughputBucket)
+		byStart[start.Unix()] = throughputBucket
+	}
+
+	// A resolution is counted in the first city it lies in, the cities keep their order in every bucket and the
+	// resolutions outside of all of them come last.
+	areas := make([]*locations.ThroughputArea, 0, len(cityList))
+	names := make(map[int]string, len(cityList))
+	order := make(map[int]int, len(cityList))
+
+	for i, city := range cityList {
+		areas = append(areas, &locations.ThroughputArea{ID: city.ID, Polygon: city.Polygon})
+		names[city.ID] = city.Name
+		order[city.ID] = i
+	}
+
+	// ResolvedUntil is inclusive, the last second belongs to the next bucket.
+	counts, err := s.locations.GetThroughput(c.Context(), &locations.LocationFilter{
+		EventID:       eventID,
+		ResolvedSince: from,
+		ResolvedUntil: to.Add(-time.Second),
+	}, bucket, areas)
+	if err != nil {
+		return err
+	}
+
+	type cityKey struct {
+		start int64
+		city  int
+	}
+
+	byCity := make(map[cityKey]*CityThroughput)
+
+	for _, count := range counts {
+		throughputBucket, ok := byStart[count.Bucket.Unix()]
+		if !ok {
+			continue
+		}
+
+		key := cityKey{start: count.Bucket.Unix(), city: count.Area}
+
+		cityThroughput, ok := byCity[key]
+		if !ok {
+			cityThroughput = &CityThroughput{CityID: count.Area, Name: names[count.Area], Types: make([]*TypeThroughput, 0)}
+			throughputBucket.Cities = append(throughputBucket.Cities, cityThroughput)
+			byCity[key] = cityThroughput
+		}
+
+		throughputBucket.Total += count.Count
+		cityThroughput.Total += count.Count
+		cityThroughput.Types = append(cityThroughput.Types, &TypeThroughput{Type: count.Type, Count: count.Count})
+	}
+
+	position := func(cityID int) int {
+		if i, ok := order[cityID]; ok {
+			return i
+		}
+
+		return len(cityList)
+	}
+
+	for _, throughputBucket := range response.Buckets {
+		sort.SliceStable(throughputBucket.Cities, func(i, j int) bool {
+			return position(throughputBucket.Cities[i].CityID) < position(throughputBucket.Cities[j].CityID)
+		})
+
+		for _, city := range throughputBucket.Cities {
+			sort.Slice(city.Types, func(i, j int) bool { return city.Types[i].Type < city.Types[j].Type })
+		}
+	}
+
+	s.cache.SetWithTTL(cacheKey, response, 1, throughputCacheTTL)
+
+	return c.JSON(response)
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+const defaultAutocertCacheDir = "autocert-cache"
+
+// TLSEnabled is true when the app terminates TLS itself instead of sitting behind a proxy which does.
+func (e *Environment) TLSEnabled() bool {
+	return e.TLSCertFile != "" || e.AutocertDomains != ""
+}
+
+// newTLS builds the TLS config of the listener, from the certificate files or with certificates Let's Encrypt
+// issues for the autocert domains. The handler is for the plain HTTP port, it answers the ACME challenges and
+// redirects everything else to HTTPS.
+func newTLS(e *Environment) (*tls.Config, http.Handler, error) {
+	redirect := httpsRedirect(e.Port)
+
+	if e.TLSCertFile != "" {
+		certificate, err := tls.LoadX509KeyPair(e.TLSCertFile, e.TLSKeyFile)
+		if err != nil {
+			return nil, nil, fmt.Errorf("couldn't load the TLS certificate: %w", err)
+		}
+
+		return &tls.Config{
+			Certificates: []tls.Certificate{certificate},
+			MinVersion:   tls.VersionTLS12,
+		}, redirect, nil
+	}
+
+	cacheDir := e.AutocertCacheDir
+	if cacheDir == "" {
+		cacheDir = defaultAutocertCacheDir
+	}
+
+	manager := &autocert.Manager{
+		Prompt:     autocert.AcceptTOS,
+		HostPolicy: autocert.HostWhitelist(splitCSV(e.AutocertDomains)...),
+		Cache:      autocert.DirCache(cacheDir),
+		Email:      e.AutocertEmail,
+	}
+
+	// The TLS config also answers the tls-alpn-01 challenge, the HTTP port is only needed for http-01. It
+	// offers h2 as well, which fasthttp can't serve, so only HTTP/1.1 and the challenge are negotiated.
+	tlsConfig := manager.TLSConfig()
+	tlsConfig.MinVersion = tls.VersionTLS12
+	tlsConfig.NextProtos = []string{"http/1.1", acme.ALPNProto}
+
+	return tlsConfig, manager.HTTPHandler(redirect), nil
+}
+
+// httpsRedirect sends plain HTTP requests to the same URL on the TLS port, keeping the method.
+func httpsRedirect(port int) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host, _, err := net.SplitHostPort(r.Host)
+		if err != nil {
+			host = r.Host
+		}
+
+		if port != 443 {
+			host = net.JoinHostPort(host, strconv.Itoa(port))
+		}
+
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusPermanentRedirect)
+	})
+}
+
+// newRedirectServer serves the handler of newTLS on the plain HTTP port.
+func newRedirectServer(port int, handler http.Handler) *http.Server {
+	return &http.Server{
+		Addr:              fmt.Sprintf(":%d", port),
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+}
+
+// secureHeaders sets the headers browsers use to protect the API's own pages, like the docs. HSTS is only sent
+// over HTTPS, browsers ignore it otherwise. With a proxy terminating TLS that is the X-Forwarded-Proto it sets.
+func secureHeaders(hstsSeconds int, headers bool) fiber.Handler {
+	hsts := fmt.Sprintf("max-age=%d", hstsSeconds)
+
+	return func(c *fiber.Ctx) error {
+		if headers {
+			c.Set(fiber.HeaderXContentTypeOptions, "nosniff")
+			c.Set(fiber.HeaderXFrameOptions, "DENY")
+			c.Set(fiber.HeaderReferrerPolicy, "no-referrer")
+		}
+
+		if hstsSeconds > 0 && c.Protocol() == "https" {
+			c.Set(fiber.HeaderStrictTransportSecurity, hsts)
+		}
+
+		return c.Next()
+	}
+}
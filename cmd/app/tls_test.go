@@ -0,0 +1,24 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/samber/lo"
+	"golang.org/x/crypto/acme"
+)
+
+func TestAutocertProtocols(t *testing.T) {
+	environment := defaultEnvironment()
+	environment.AutocertDomains = "veri.example.org"
+	environment.AutocertCacheDir = t.TempDir()
+
+	tlsConfig, _, err := newTLS(environment)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// fasthttp only speaks HTTP/1.1, browsers offered h2 would use it.
+	if lo.Contains(tlsConfig.NextProtos, "h2") || !lo.Contains(tlsConfig.NextProtos, acme.ALPNProto) {
+		t.Fatalf("NextProtos = %v", tlsConfig.NextProtos)
+	}
+}
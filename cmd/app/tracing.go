@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/YusufOzmen01/veri-kontrol-backend/core/tracing"
+	"github.com/gofiber/fiber/v2"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracingMiddleware starts the server span of a request, continuing the trace of the caller when it sent a
+// traceparent header. Handlers pass c.Context() on, so the span is injected there for the Mongo, cache and
+// upstream spans to find. It runs outside metricsMiddleware, which renders errors, so the status is final.
+func tracingMiddleware(c *fiber.Ctx) error {
+	carrier := propagation.HeaderCarrier{}
+	for _, key := range otel.GetTextMapPropagator().Fields() {
+		if value := c.Get(key); value != "" {
+			carrier.Set(key, value)
+		}
+	}
+
+	ctx := otel.GetTextMapPropagator().Extract(c.UserContext(), carrier)
+	ctx, span := tracing.Start(ctx, c.Method(),
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(
+			semconv.HTTPMethod(c.Method()),
+			semconv.HTTPTarget(c.Path()),
+			attribute.String("request_id", fmt.Sprint(c.Locals("requestid"))),
+		),
+	)
+	defer span.End()
+
+	tracing.Inject(c.Context(), span)
+	c.SetUserContext(ctx)
+
+	err := c.Next()
+
+	route := c.Route().Path
+	status := c.Response().StatusCode()
+
+	span.SetName(c.Method() + " " + route)
+	span.SetAttributes(semconv.HTTPRoute(route), semconv.HTTPStatusCode(status))
+
+	if err != nil {
+		span.RecordError(err)
+	}
+
+	if status >= fiber.StatusInternalServerError {
+		span.SetStatus(codes.Error, fmt.Sprintf("status %d", status))
+	}
+
+	return err
+}
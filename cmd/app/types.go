@@ -0,0 +1,29 @@
+package main
+
+import (
+	typesRepository "github.com/YusufOzmen01/veri-kontrol-backend/repository/types"
+	"github.com/gofiber/fiber/v2"
+)
+
+type Types interface {
+	GetTypes(c *fiber.Ctx) error
+}
+
+type types struct {
+	types typesRepository.Repository
+}
+
+func NewTypes(typeRepository typesRepository.Repository) Types {
+	return &types{
+		types: typeRepository,
+	}
+}
+
+func (t *types) GetTypes(c *fiber.Ctx) error {
+	list, err := t.types.GetTypes(c.Context())
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(list)
+}
@@ -0,0 +1,490 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	auditRepository "github.com/YusufOzmen01/veri-kontrol-backend/repository/audit"
+	outboxRepository "github.com/YusufOzmen01/veri-kontrol-backend/repository/outbox"
+	usersRepository "github.com/YusufOzmen01/veri-kontrol-backend/repository/users"
+	"github.com/YusufOzmen01/veri-kontrol-backend/tools"
+	"github.com/YusufOzmen01/veri-kontrol-backend/util"
+	"github.com/YusufOzmen01/veri-kontrol-backend/validation"
+	"github.com/gofiber/fiber/v2"
+	"github.com/samber/lo"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type Users interface {
+	ListUsers(c *fiber.Ctx) error
+	CreateUser(c *fiber.Ctx) error
+	UpdateUser(c *fiber.Ctx) error
+	DeactivateUser(c *fiber.Ctx) error
+	Register(c *fiber.Ctx) error
+	ListPendingUsers(c *fiber.Ctx) error
+	ApproveUser(c *fiber.Ctx) error
+	RejectUser(c *fiber.Ctx) error
+	RotateKey(c *fiber.Ctx) error
+}
+
+// users sends the approval of a registration to its Telegram chat through the outbox when notify is set,
+// without a Telegram token nobody would deliver it. keyGrace is how long a rotated Auth-Key keeps working,
+// maxPending how many registrations may wait for approval at once.
+type users struct {
+	users      usersRepository.Repository
+	audit      auditRepository.Repository
+	outbox     outboxRepository.Repository
+	auth       Auth
+	notify     bool
+	keyGrace   time.Duration
+	maxPending int
+}
+
+const defaultKeyGrace = time.Hour
+
+const maxNameLength = 100
+
+// RegisterBody is a volunteer signing up. The Telegram chat is where the approval is announced once the admin
+// approving it checked the chat belongs to the volunteer, the bot only reaches chats which talked to it first.
+type RegisterBody struct {
+	Name           string `json:"name"`
+	Discord        string `json:"discord"`
+	TelegramChatID string `json:"telegram_chat_id"`
+}
+
+// RegisterResponse is the only place the Auth-Key of a registration is shown, it works once an admin approved
+// the account.
+type RegisterResponse struct {
+	ID      primitive.ObjectID `json:"_id"`
+	AuthKey string             `json:"auth_key"`
+}
+
+// ApproveUserBody gives submit rights by default, scopes default to the ones of the perm level. Anybody can
+// register with any Telegram chat, TelegramVerified tells the admin made sure it is the volunteer's before the
+// approval is announced there.
+type ApproveUserBody struct {
+	PermLevel        *int     `json:"perm_level"`
+	Scopes           []string `json:"scopes"`
+	TelegramVerified bool     `json:"telegram_verified"`
+}
+
+// RotateKeyResponse is the only place the new Auth-Key is shown, the old one works until PreviousKeyExpiry.
+type RotateKeyResponse struct {
+	AuthKey           string    `json:"auth_key"`
+	PreviousKeyExpiry time.Time `json:"previous_key_expiry"`
+}
+
+// Scopes default to the ones of the perm level when they are left out.
+type CreateUserBody struct {
+	Name      string   `json:"name"`
+	Discord   string   `json:"discord"`
+	PermLevel int      `json:"perm_level"`
+	Scopes    []string `json:"scopes"`
+}
+
+// UpdateUserBody leaves out what isn't given. Scopes which aren't given are kept, unless the perm level changes
+// and they become the ones of the new level.
+type UpdateUserBody struct {
+	PermLevel *int     `json:"perm_level"`
+	Scopes    []string `json:"scopes"`
+}
+
+// validateScopes replaces the scopes with their canonical spelling in place.
+func validateScopes(v *validation.Validator, scopes []string) {
+	for i, scope := range scopes {
+		scopes[i], _ = v.OneOf("scopes", scope, usersRepository.Scopes)
+	}
+}
+
+// CreateUserResponse is the only place the Auth-Key is ever shown, only its hash is stored.
+type CreateUserResponse struct {
+	User    *usersRepository.User `json:"user"`
+	AuthKey string                `json:"auth_key"`
+}
+
+func NewUsers(userRepository usersRepository.Repository, auditLog auditRepository.Repository, outbox outboxRepository.Repository, auth Auth, notify bool, keyGrace time.Duration, maxPending int) Users {
+	return &users{
+		users:      userRepository,
+		audit:      auditLog,
+		outbox:     outbox,
+		auth:       auth,
+		notify:     notify,
+		keyGrace:   keyGrace,
+		maxPending: maxPending,
+	}
+}
+
+// targetUser loads the user from the :user_id param, admins can't change their own account through these
+// endpoints, nor the account of somebody with a higher perm level.
+func (u *users) targetUser(c *fiber.Ctx) (*usersRepository.User, error) {
+	id, err := primitive.ObjectIDFromHex(c.Params("user_id"))
+	if err != nil {
+		return nil, badRequest("invalid user_id")
+	}
+
+	if id == currentUser(c).ID {
+		return nil, forbidden("You can't change your own account.")
+	}
+
+	user, err := u.users.GetUserByID(c.Context(), id)
+	if err != nil {
+		return nil, notFound(err.Error())
+	}
+
+	if user.PermLevel > currentUser(c).PermLevel {
+		return nil, forbidden("You can't change an account with a higher perm level than yours.")
+	}
+
+	return user, nil
+}
+
+// checkGrant keeps whoever manages users from handing out more than they have. The perm level can't be above
+// their own and the scopes the account didn't have before have to be theirs as well.
+func checkGrant(c *fiber.Ctx, permLevel int, had, scopes []string) error {
+	caller := currentUser(c)
+
+	if permLevel > caller.PermLevel {
+		return forbidden("You can't grant a higher perm level than yours.")
+	}
+
+	for _, scope := range scopes {
+		if !lo.Contains(had, scope) && !caller.HasScope(scope) {
+			return forbidden(fmt.Sprintf("You can't grant the %s scope, you don't have it.", scope))
+		}
+	}
+
+	return nil
+}
+
+func (u *users) ListUsers(c *fiber.Ctx) error {
+	list, err := u.users.GetUsers(c.Context())
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(list)
+}
+
+func (u *users) CreateUser(c *fiber.Ctx) error {
+	body := &CreateUserBody{}
+
+	if err := json.Unmarshal(c.Body(), body); err != nil {
+		return badRequest(err.Error())
+	}
+
+	body.Name = validation.NormalizeText(body.Name)
+	body.Discord = validation.NormalizeText(body.Discord)
+
+	v := validation.New()
+	v.Required("name", body.Name)
+	v.OneOfInt("perm_level", body.PermLevel, usersRepository.PermLevels)
+	validateScopes(v, body.Scopes)
+
+	if err := v.Err(); err != nil {
+		return validationFailed(err)
+	}
+
+	scopes := body.Scopes
+	if scopes == nil {
+		scopes = usersRepository.ScopesForPermLevel(body.PermLevel)
+	}
+
+	if err := checkGrant(c, body.PermLevel, nil, scopes); err != nil {
+		return err
+	}
+
+	user, authKey, err := u.users.AddUser(c.Context(), body.Name, body.Discord, body.PermLevel, scopes)
+	if err != nil {
+		return err
+	}
+
+	recordAudit(c, u.audit, &auditRepository.Event{
+		Action:  auditRepository.ActionUserCreate,
+		Target:  user.ID.Hex(),
+		Changes: auditRepository.Diff(nil, user),
+	})
+
+	return c.JSON(&CreateUserResponse{
+		User:    user,
+		AuthKey: authKey,
+	})
+}
+
+func (u *users) UpdateUser(c *fiber.Ctx) error {
+	user, err := u.targetUser(c)
+	if err != nil {
+		return err
+	}
+
+	body := &UpdateUserBody{}
+
+	if err := json.Unmarshal(c.Body(), body); err != nil {
+		return badRequest(err.Error())
+	}
+
+	permLevel := user.PermLevel
+	if body.PermLevel != nil {
+		permLevel = *body.PermLevel
+	}
+
+	v := validation.New()
+	v.OneOfInt("perm_level", permLevel, usersRepository.PermLevels)
+	validateScopes(v, body.Scopes)
+
+	if err := v.Err(); err != nil {
+		return validationFailed(err)
+	}
+
+	scopes := body.Scopes
+	if scopes == nil {
+		scopes = user.Scopes
+
+		if permLevel != user.PermLevel || scopes == nil {
+			scopes = usersRepository.ScopesForPermLevel(permLevel)
+		}
+	}
+
+	if err := checkGrant(c, permLevel, user.Scopes, scopes); err != nil {
+		return err
+	}
+
+	if err := u.users.SetPermLevel(c.Context(), user.ID, permLevel); err != nil {
+		return err
+	}
+
+	if err := u.users.SetScopes(c.Context(), user.ID, scopes); err != nil {
+		return err
+	}
+
+	// Issued tokens carry the old level and scopes, make the user log in again to pick up the new ones.
+	if err := u.users.RevokeUserRefreshTokens(c.Context(), user.ID); err != nil {
+		return err
+	}
+
+	updated := *user
+	updated.PermLevel = permLevel
+	updated.Scopes = scopes
+
+	recordAudit(c, u.audit, &auditRepository.Event{
+		Action:  auditRepository.ActionUserUpdate,
+		Target:  user.ID.Hex(),
+		Changes: auditRepository.Diff(user, &updated),
+	})
+
+	return c.SendString("Successfully updated!")
+}
+
+func (u *users) DeactivateUser(c *fiber.Ctx) error {
+	user, err := u.targetUser(c)
+	if err != nil {
+		return err
+	}
+
+	if err := u.users.DeactivateUser(c.Context(), user.ID); err != nil {
+		return err
+	}
+
+	// Other replicas forget the key once knownKeyTTL passed.
+	u.auth.Forget(user.ID)
+
+	if err := u.users.RevokeUserRefreshTokens(c.Context(), user.ID); err != nil {
+		return err
+	}
+
+	deactivated := *user
+	deactivated.Deactivated = true
+
+	recordAudit(c, u.audit, &auditRepository.Event{
+		Action:  auditRepository.ActionUserDisable,
+		Target:  user.ID.Hex(),
+		Changes: auditRepository.Diff(user, &deactivated),
+	})
+
+	return c.SendString("Successfully deactivated!")
+}
+
+func (u *users) Register(c *fiber.Ctx) error {
+	body := &RegisterBody{}
+
+	if err := json.Unmarshal(c.Body(), body); err != nil {
+		return badRequest(err.Error())
+	}
+
+	body.Name = validation.NormalizeText(body.Name)
+	body.Discord = validation.NormalizeText(body.Discord)
+	body.TelegramChatID = validation.NormalizeText(body.TelegramChatID)
+
+	v := validation.New()
+	if v.Required("name", body.Name) {
+		v.MaxLength("name", body.Name, maxNameLength)
+	}
+	v.MaxLength("discord", body.Discord, maxNameLength)
+	v.MaxLength("telegram_chat_id", body.TelegramChatID, maxNameLength)
+
+	if err := v.Err(); err != nil {
+		return validationFailed(err)
+	}
+
+	user, authKey, err := u.users.RegisterUser(c.Context(), body.Name, body.Discord, body.TelegramChatID, u.maxPending)
+	if err != nil {
+		if errors.Is(err, usersRepository.ErrTooManyPending) {
+			return serviceUnavailable("Registrations are paused until the waiting ones are reviewed, try again later.")
+		}
+
+		return err
+	}
+
+	recordAudit(c, u.audit, &auditRepository.Event{
+		Action:  auditRepository.ActionUserRegister,
+		Target:  user.ID.Hex(),
+		Changes: auditRepository.Diff(nil, user),
+	})
+
+	return c.Status(fiber.StatusCreated).JSON(&RegisterResponse{
+		ID:      user.ID,
+		AuthKey: authKey,
+	})
+}
+
+func (u *users) ListPendingUsers(c *fiber.Ctx) error {
+	list, err := u.users.GetPendingUsers(c.Context())
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(list)
+}
+
+func (u *users) ApproveUser(c *fiber.Ctx) error {
+	user, err := u.targetUser(c)
+	if err != nil {
+		return err
+	}
+
+	body := &ApproveUserBody{}
+
+	if err := json.Unmarshal(c.Body(), body); err != nil {
+		return badRequest(err.Error())
+	}
+
+	permLevel := usersRepository.PermSubmit
+	if body.PermLevel != nil {
+		permLevel = *body.PermLevel
+	}
+
+	v := validation.New()
+	v.OneOfInt("perm_level", permLevel, usersRepository.PermLevels)
+	validateScopes(v, body.Scopes)
+
+	if err := v.Err(); err != nil {
+		return validationFailed(err)
+	}
+
+	if body.Scopes == nil {
+		body.Scopes = usersRepository.ScopesForPermLevel(permLevel)
+	}
+
+	if err := checkGrant(c, permLevel, nil, body.Scopes); err != nil {
+		return err
+	}
+
+	if err := u.users.ApproveUser(c.Context(), user.ID, permLevel, body.Scopes); err != nil {
+		if errors.Is(err, usersRepository.ErrNotPending) {
+			return conflict(err.Error())
+		}
+
+		return err
+	}
+
+	approved := *user
+	approved.Pending = false
+	approved.PermLevel = permLevel
+	approved.Scopes = body.Scopes
+
+	recordAudit(c, u.audit, &auditRepository.Event{
+		Action:  auditRepository.ActionUserApprove,
+		Target:  user.ID.Hex(),
+		Changes: auditRepository.Diff(user, &approved),
+	})
+
+	if u.notify && user.TelegramChatID != "" && body.TelegramVerified {
+		u.announce(c, user.TelegramChatID, fmt.Sprintf("Merhaba %s, hesabın onaylandı. Kayıt olurken aldığın anahtarla giriş yapabilirsin.", user.Name))
+	}
+
+	return c.SendString("Successfully approved!")
+}
+
+func (u *users) RejectUser(c *fiber.Ctx) error {
+	user, err := u.targetUser(c)
+	if err != nil {
+		return err
+	}
+
+	if err := u.users.RejectUser(c.Context(), user.ID); err != nil {
+		if errors.Is(err, usersRepository.ErrNotPending) {
+			return conflict(err.Error())
+		}
+
+		return err
+	}
+
+	recordAudit(c, u.audit, &auditRepository.Event{
+		Action:  auditRepository.ActionUserReject,
+		Target:  user.ID.Hex(),
+		Changes: auditRepository.Diff(user, nil),
+	})
+
+	return c.SendString("Successfully rejected!")
+}
+
+// RotateKey replaces the Auth-Key of the current user. The old key keeps working for the grace period so
+// running clients can be switched over, refresh tokens are revoked since whoever had the key could have
+// logged in with it.
+func (u *users) RotateKey(c *fiber.Ctx) error {
+	expiry := time.Now().Add(u.keyGrace)
+
+	authKey, user, err := u.users.RotateAuthKey(c.Context(), currentUser(c).ID, expiry)
+	if err != nil {
+		return err
+	}
+
+	u.auth.Forget(user.ID)
+
+	if err := u.users.RevokeUserRefreshTokens(c.Context(), user.ID); err != nil {
+		return err
+	}
+
+	rotated := *user
+	rotated.PreviousKeyHash = user.AuthKeyHash
+	rotated.PreviousKeyExpiry = &expiry
+	rotated.AuthKeyHash = util.Hash(authKey)
+
+	recordAudit(c, u.audit, &auditRepository.Event{
+		Action:  auditRepository.ActionUserRotateKey,
+		Target:  user.ID.Hex(),
+		Changes: auditRepository.Diff(user, &rotated),
+	})
+
+	return c.JSON(&RotateKeyResponse{
+		AuthKey:           authKey,
+		PreviousKeyExpiry: expiry,
+	})
+}
+
+// announce queues a Telegram message, the approval already happened so a failure is only logged.
+func (u *users) announce(c *fiber.Ctx, chatID, text string) {
+	message, err := outboxRepository.NewMessage(tools.TopicAlert, &tools.Alert{
+		ChatID: chatID,
+		Text:   text,
+	})
+	if err == nil {
+		err = u.outbox.Add(c.Context(), message)
+	}
+
+	if err != nil {
+		logrus.Errorf("Couldn't queue the approval message of chat %s: %s", chatID, err)
+	}
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+)
+
+const (
+	headerAPIVersion = "API-Version"
+
+	// apiVersion is the only revision so far. A breaking change gets a new one, clients opt in with the
+	// API-Version header and the old one keeps working until it is retired.
+	apiVersion       = "1"
+	apiVersionPrefix = "/v1"
+)
+
+// Envelope is the body of every /v1 response. Data is what the unversioned route returns, null for errors,
+// and Error is only set for them.
+type Envelope struct {
+	Data  json.RawMessage `json:"data"`
+	Meta  *EnvelopeMeta   `json:"meta"`
+	Error *APIError       `json:"error,omitempty"`
+}
+
+type EnvelopeMeta struct {
+	Version   string `json:"version"`
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// nativeV1 are groups registered under /v1 before the envelope existed, they keep their own format.
+var nativeV1 = []string{"/v1/public"}
+
+// versioning serves the API under /v1 in envelopes. The routes are registered once, without the prefix, so a
+// /v1 request is routed as the unversioned path and its response wrapped afterwards. Sending the API-Version
+// header asks for the envelope on the unversioned paths as well. It runs inside compress, the body has to be
+// wrapped before it is compressed.
+func versioning(c *fiber.Ctx) error {
+	// Path points into the request buffer, which the rewrite below overwrites.
+	path := utils.CopyString(c.Path())
+	versioned := path == apiVersionPrefix || strings.HasPrefix(path, apiVersionPrefix+"/")
+
+	for _, prefix := range nativeV1 {
+		if path == prefix || strings.HasPrefix(path, prefix+"/") {
+			return c.Next()
+		}
+	}
+
+	unversioned := strings.TrimPrefix(path, apiVersionPrefix)
+	if unversioned == "" {
+		unversioned = "/"
+	}
+
+	// Metrics, the monitor and the docs aren't part of the API, they aren't found under /v1 and ignore the header.
+	if undocumentedRoutes[unversioned] {
+		return c.Next()
+	}
+
+	requested := c.Get(headerAPIVersion)
+	if !versioned && requested == "" {
+		return c.Next()
+	}
+
+	if versioned {
+		c.Path(unversioned)
+	}
+
+	var err error
+	if requested != "" && requested != apiVersion {
+		err = badRequest(fmt.Sprintf("API-Version %s isn't supported, use %s", requested, apiVersion))
+	} else {
+		err = c.Next()
+	}
+
+	if err != nil {
+		if err := c.App().Config().ErrorHandler(c, err); err != nil {
+			return err
+		}
+	}
+
+	c.Set(headerAPIVersion, apiVersion)
+
+	return wrapResponse(c)
+}
+
+// wrapResponse puts the rendered response into an Envelope. Bodies which aren't JSON or plain text, like the
+// exports, streams and websocket upgrades, are left alone.
+func wrapResponse(c *fiber.Ctx) error {
+	response := c.Response()
+	status := response.StatusCode()
+
+	if c.Method() == fiber.MethodHead || status < fiber.StatusOK || status == fiber.StatusNoContent ||
+		status == fiber.StatusNotModified || response.IsBodyStream() {
+		return nil
+	}
+
+	contentType := string(response.Header.ContentType())
+	isJSON := strings.HasPrefix(contentType, fiber.MIMEApplicationJSON)
+	if !isJSON && !strings.HasPrefix(contentType, fiber.MIMETextPlain) {
+		return nil
+	}
+
+	body := response.Body()
+	envelope := &Envelope{
+		Data: json.RawMessage("null"),
+		Meta: &EnvelopeMeta{Version: apiVersion},
+	}
+
+	if requestID, ok := c.Locals("requestid").(string); ok {
+		envelope.Meta.RequestID = requestID
+	}
+
+	switch {
+	case status >= fiber.StatusBadRequest:
+		apiErr := &APIError{}
+		if !isJSON || json.Unmarshal(body, apiErr) != nil || apiErr.Code == "" {
+			apiErr = &APIError{Code: codeForStatus(status), Message: string(body)}
+		}
+
+		envelope.Error = apiErr
+	case isJSON && len(body) > 0:
+		envelope.Data = append(json.RawMessage(nil), body...)
+	case len(body) > 0:
+		data, err := json.Marshal(string(body))
+		if err != nil {
+			return err
+		}
+
+		envelope.Data = data
+	}
+
+	return c.Status(status).JSON(envelope)
+}
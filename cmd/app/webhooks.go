@@ -0,0 +1,161 @@
+package main
+
+import (
+	"encoding/json"
+
+	coreWebhooks "github.com/YusufOzmen01/veri-kontrol-backend/core/webhooks"
+	auditRepository "github.com/YusufOzmen01/veri-kontrol-backend/repository/audit"
+	webhooksRepository "github.com/YusufOzmen01/veri-kontrol-backend/repository/webhooks"
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type Webhooks interface {
+	GetWebhooks(c *fiber.Ctx) error
+	AddWebhook(c *fiber.Ctx) error
+	DeleteWebhook(c *fiber.Ctx) error
+	GetDeliveries(c *fiber.Ctx) error
+}
+
+type webhooks struct {
+	webhooks webhooksRepository.Repository
+	audit    auditRepository.Repository
+}
+
+type WebhookBody struct {
+	URL    string `json:"url"`
+	Secret string `json:"secret"`
+}
+
+type DeliveriesResponse struct {
+	Count      int64                          `json:"count"`
+	Page       int                            `json:"page"`
+	PageSize   int                            `json:"page_size"`
+	Deliveries []*webhooksRepository.Delivery `json:"deliveries"`
+}
+
+func NewWebhooks(webhookRepository webhooksRepository.Repository, auditLog auditRepository.Repository) Webhooks {
+	return &webhooks{
+		webhooks: webhookRepository,
+		audit:    auditLog,
+	}
+}
+
+func (w *webhooks) targetWebhook(c *fiber.Ctx) (*webhooksRepository.Webhook, error) {
+	id, err := primitive.ObjectIDFromHex(c.Params("webhook_id"))
+	if err != nil {
+		return nil, badRequest("invalid webhook_id")
+	}
+
+	webhook, err := w.webhooks.GetWebhook(c.Context(), id)
+	if err != nil {
+		if err == webhooksRepository.ErrWebhookNotFound {
+			return nil, notFound(err.Error())
+		}
+
+		return nil, err
+	}
+
+	return webhook, nil
+}
+
+func (w *webhooks) GetWebhooks(c *fiber.Ctx) error {
+	list, err := w.webhooks.GetWebhooks(c.Context())
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(list)
+}
+
+func (w *webhooks) AddWebhook(c *fiber.Ctx) error {
+	body := &WebhookBody{}
+
+	if err := json.Unmarshal(c.Body(), body); err != nil {
+		return badRequest(err.Error())
+	}
+
+	webhook := &webhooksRepository.Webhook{
+		URL:       body.URL,
+		Secret:    body.Secret,
+		CreatedBy: currentUser(c),
+	}
+
+	if err := webhook.Validate(); err != nil {
+		return badRequest(err.Error())
+	}
+
+	if err := coreWebhooks.CheckURL(c.Context(), webhook.URL); err != nil {
+		return badRequest(err.Error())
+	}
+
+	if err := w.webhooks.AddWebhook(c.Context(), webhook); err != nil {
+		return err
+	}
+
+	recordAudit(c, w.audit, &auditRepository.Event{
+		Action:  auditRepository.ActionWebhookAdd,
+		Target:  webhook.ID.Hex(),
+		Changes: auditRepository.Diff(nil, &WebhookBody{URL: webhook.URL}),
+	})
+
+	return c.JSON(webhook)
+}
+
+func (w *webhooks) DeleteWebhook(c *fiber.Ctx) error {
+	webhook, err := w.targetWebhook(c)
+	if err != nil {
+		return err
+	}
+
+	if err := w.webhooks.DeleteWebhook(c.Context(), webhook.ID); err != nil {
+		return err
+	}
+
+	recordAudit(c, w.audit, &auditRepository.Event{
+		Action:  auditRepository.ActionWebhookDelete,
+		Target:  webhook.ID.Hex(),
+		Changes: auditRepository.Diff(&WebhookBody{URL: webhook.URL}, nil),
+	})
+
+	return c.SendString("Successfully deleted!")
+}
+
+func (w *webhooks) GetDeliveries(c *fiber.Ctx) error {
+	webhook, err := w.targetWebhook(c)
+	if err != nil {
+		return err
+	}
+
+	filter := &webhooksRepository.DeliveryFilter{
+		WebhookID: webhook.ID,
+		Status:    c.Query("status"),
+		Page:      c.QueryInt("page", 1),
+		PageSize:  c.QueryInt("page_size", defaultPageSize),
+	}
+
+	if filter.Page < 1 {
+		filter.Page = 1
+	}
+
+	if filter.PageSize < 1 || filter.PageSize > maxPageSize {
+		filter.PageSize = defaultPageSize
+	}
+
+	count, err := w.webhooks.CountDeliveries(c.Context(), filter)
+	if err != nil {
+		return err
+	}
+
+	deliveries, err := w.webhooks.GetDeliveries(c.Context(), filter)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(&DeliveriesResponse{
+		Count:      count,
+		Page:       filter.Page,
+		PageSize:   filter.PageSize,
+		Deliveries: deliveries,
+	})
+}
@@ -62,10 +62,12 @@ func main() {
 			id, _ := strconv.ParseInt(rec[0], 10, 32)
 
 			location := make([]float64, 0)
+			epoch := 0
 
 			for _, loc := range locs {
 				if loc.EntryID == int(id) {
 					location = loc.Loc
+					epoch = loc.Epoch
 				}
 			}
 
@@ -76,6 +78,7 @@ func main() {
 				OriginalAddress:  rec[1],
 				CorrectedAddress: rec[2],
 				Reason:           rec[3],
+				Epoch:            epoch,
 			}
 
 			if len(rec) > 4 {
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"os"
+
+	"github.com/Netflix/go-env"
+	"github.com/YusufOzmen01/veri-kontrol-backend/core/sources"
+	citiesRepository "github.com/YusufOzmen01/veri-kontrol-backend/repository/cities"
+	locationsRepository "github.com/YusufOzmen01/veri-kontrol-backend/repository/locations"
+	reasonsRepository "github.com/YusufOzmen01/veri-kontrol-backend/repository/reasons"
+	typesRepository "github.com/YusufOzmen01/veri-kontrol-backend/repository/types"
+	usersRepository "github.com/YusufOzmen01/veri-kontrol-backend/repository/users"
+	"github.com/YusufOzmen01/veri-kontrol-backend/tools"
+	"github.com/sirupsen/logrus"
+)
+
+type Environment struct {
+	MongoUri        string `env:"mongo_uri"`
+	UpstreamSources string `env:"upstream_sources,default=seed:file:data/seed.json"`
+}
+
+// Fills a local database with fake entries, users and resolutions, so the app can be run without the
+// production data. Start the app with the same upstream_sources to serve the entries, the Auth-Keys of the
+// users are printed once.
+func main() {
+	ctx := context.Background()
+	var environment Environment
+
+	if _, err := env.UnmarshalFromEnviron(&environment); err != nil {
+		panic(err)
+	}
+
+	opts := &tools.SeedOptions{}
+	flag.IntVar(&opts.Locations, "locations", 200, "entries to add to the file source")
+	flag.IntVar(&opts.Users, "users", 10, "users to create, the first one is an admin")
+	flag.IntVar(&opts.Resolutions, "resolutions", 50, "new entries to resolve")
+	flag.Int64Var(&opts.RandomSeed, "seed", 0, "random seed, the same one generates the same data")
+	flag.Parse()
+
+	upstreamSources, err := tools.ParseSources(environment.UpstreamSources)
+	if err != nil {
+		panic(err)
+	}
+
+	tools.SetSources(upstreamSources)
+
+	mongoClient := sources.NewMongoClient(ctx, environment.MongoUri, "database")
+
+	cityRepository := citiesRepository.NewRepository(mongoClient)
+	typeRepository := typesRepository.NewRepository(mongoClient)
+	reasonRepository := reasonsRepository.NewRepository(mongoClient)
+
+	// The defaults the app creates on startup, so seeding works on an empty database.
+	if err := cityRepository.EnsureDefaults(ctx); err != nil {
+		panic(err)
+	}
+
+	if err := typeRepository.EnsureDefaults(ctx); err != nil {
+		panic(err)
+	}
+
+	if err := reasonRepository.EnsureDefaults(ctx, reasonsRepository.DefaultCodes); err != nil {
+		panic(err)
+	}
+
+	seeder := &tools.Seeder{
+		Locations: locationsRepository.NewRepository(mongoClient),
+		Users:     usersRepository.NewRepository(mongoClient),
+		Cities:    cityRepository,
+		Reasons:   reasonRepository,
+		Types:     typeRepository,
+	}
+
+	result, err := seeder.Seed(ctx, opts)
+	if err != nil {
+		panic(err)
+	}
+
+	logrus.Infof("Added %d entries to %s, %d users and %d resolutions", result.Locations, result.FeedPath, len(result.Users), result.Resolutions)
+	logrus.Infof("Run the app with upstream_sources=%s to serve them", environment.UpstreamSources)
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+
+	if err := encoder.Encode(result.Users); err != nil {
+		panic(err)
+	}
+}
@@ -3,6 +3,7 @@ package main
 import (
 	"context"
 	"github.com/Netflix/go-env"
+	"github.com/YusufOzmen01/veri-kontrol-backend/core/language"
 	"github.com/YusufOzmen01/veri-kontrol-backend/core/sources"
 	locationsRepository "github.com/YusufOzmen01/veri-kontrol-backend/repository/locations"
 	"github.com/YusufOzmen01/veri-kontrol-backend/tools"
@@ -12,7 +13,8 @@ import (
 )
 
 type Environment struct {
-	MongoUri string `env:"mongo_uri"`
+	MongoUri        string `env:"mongo_uri"`
+	UpstreamSources string `env:"upstream_sources"`
 }
 
 func main() {
@@ -23,6 +25,13 @@ func main() {
 		panic(err)
 	}
 
+	upstreamSources, err := tools.ParseSources(environment.UpstreamSources)
+	if err != nil {
+		panic(err)
+	}
+
+	tools.SetSources(upstreamSources)
+
 	cache := sources.NewCache(1<<30, 1e7, 64)
 
 	mongoClient := sources.NewMongoClient(ctx, environment.MongoUri, "database")
@@ -52,8 +61,9 @@ func main() {
 				}
 
 				loc.TweetContents = resp.FullText
+				loc.Lang = language.Detect(resp.FullText)
 
-				if err := locationRepository.ResolveLocation(ctx, loc); err != nil {
+				if err := locationRepository.ReplaceLocation(ctx, loc, nil); err != nil {
 					panic(err)
 				}
 			}(l)
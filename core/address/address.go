@@ -0,0 +1,171 @@
+package address
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// Parsed holds what could be understood from a free text Turkish address, fields which weren't found stay empty.
+type Parsed struct {
+	Province     string `json:"province,omitempty" bson:"province,omitempty"`
+	District     string `json:"district,omitempty" bson:"district,omitempty"`
+	Neighborhood string `json:"neighborhood,omitempty" bson:"neighborhood,omitempty"`
+	Street       string `json:"street,omitempty" bson:"street,omitempty"`
+	Building     string `json:"building,omitempty" bson:"building,omitempty"`
+	Floor        string `json:"floor,omitempty" bson:"floor,omitempty"`
+	Apartment    string `json:"apartment,omitempty" bson:"apartment,omitempty"`
+}
+
+func (p *Parsed) IsEmpty() bool {
+	return *p == Parsed{}
+}
+
+var provinces = []string{
+	"adana", "adıyaman", "afyonkarahisar", "ağrı", "aksaray", "amasya", "ankara", "antalya", "ardahan", "artvin",
+	"aydın", "balıkesir", "bartın", "batman", "bayburt", "bilecik", "bingöl", "bitlis", "bolu", "burdur", "bursa",
+	"çanakkale", "çankırı", "çorum", "denizli", "diyarbakır", "düzce", "edirne", "elazığ", "erzincan", "erzurum",
+	"eskişehir", "gaziantep", "giresun", "gümüşhane", "hakkari", "hatay", "ığdır", "ısparta", "istanbul", "izmir",
+	"kahramanmaraş", "karabük", "karaman", "kars", "kastamonu", "kayseri", "kilis", "kırıkkale", "kırklareli",
+	"kırşehir", "kocaeli", "konya", "kütahya", "malatya", "manisa", "mardin", "mersin", "muğla", "muş", "nevşehir",
+	"niğde", "ordu", "osmaniye", "rize", "sakarya", "samsun", "şanlıurfa", "siirt", "sinop", "şırnak", "sivas",
+	"tekirdağ", "tokat", "trabzon", "tunceli", "uşak", "van", "yalova", "yozgat", "zonguldak",
+}
+
+var (
+	buildingNoPattern   = regexp.MustCompile(`(?:^|\s|,)(?:no|numara)\s*[:.]?\s*(\d+[\p{L}]?)(?:\s*/\s*(\d+))?`)
+	floorPattern        = regexp.MustCompile(`(?:kat\s*[:.]?\s*(\d+|zemin|giriş)|(\d+)\s*\.?\s*kat)`)
+	apartmentPattern    = regexp.MustCompile(`(?:^|\s|,)(?:daire|d)\s*[:.]?\s*(\d+)`)
+	districtPattern     = regexp.MustCompile(`([\p{L}]+)\s*/\s*([\p{L}]+)\s*$`)
+	districtWordPattern = regexp.MustCompile(`([\p{L}]+)\s+(?:ilçesi|ilçe)(?:\s|,|$)`)
+
+	// Names stop at numbers like "No:4" or "12/5" and at the district keyword.
+	stopPattern = regexp.MustCompile(`^(?:,|ilçesi|ilçe|no|numara|kat|daire)$|[:/]`)
+)
+
+var neighborhoodSuffixes = map[string]string{
+	"mahallesi": "", "mahalle": "", "mah": "", "mah.": "", "mh": "", "mh.": "",
+}
+
+var streetSuffixes = map[string]string{
+	"sokağı": "Sokak", "sokak": "Sokak", "sok": "Sokak", "sok.": "Sokak", "sk": "Sokak", "sk.": "Sokak",
+	"caddesi": "Caddesi", "cadde": "Caddesi", "cad": "Caddesi", "cad.": "Caddesi", "cd": "Caddesi", "cd.": "Caddesi",
+	"bulvarı": "Bulvarı", "bulvar": "Bulvarı", "blv": "Bulvarı", "blv.": "Bulvarı",
+}
+
+var buildingSuffixes = map[string]string{
+	"apartmanı": "Apartmanı", "apartman": "Apartmanı", "apt": "Apartmanı", "apt.": "Apartmanı",
+	"sitesi": "Sitesi", "site": "Sitesi", "blok": "Blok",
+}
+
+// title capitalizes every word with the Turkish rules, so "istanbul" becomes "İstanbul".
+func title(s string) string {
+	words := strings.Fields(s)
+	for i, word := range words {
+		runes := []rune(word)
+		runes[0] = unicode.TurkishCase.ToUpper(runes[0])
+		words[i] = string(runes)
+	}
+
+	return strings.Join(words, " ")
+}
+
+func isProvince(s string) bool {
+	for _, province := range provinces {
+		if province == s {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Parse extracts il/ilçe/mahalle/sokak/bina/kat/daire from an address. It is a best effort, the original
+// text is always kept next to the result.
+func Parse(text string) *Parsed {
+	text = strings.ToLowerSpecial(unicode.TurkishCase, strings.Join(strings.Fields(text), " "))
+	parsed := &Parsed{}
+
+	tokens := strings.Fields(strings.ReplaceAll(text, ",", " , "))
+	boundary := 0
+
+	// A name is the words right before its keyword, at most three and never crossing a comma or an earlier keyword.
+	for i, token := range tokens {
+		suffixes, field := neighborhoodSuffixes, &parsed.Neighborhood
+		if _, ok := streetSuffixes[token]; ok {
+			suffixes, field = streetSuffixes, &parsed.Street
+		} else if _, ok := buildingSuffixes[token]; ok {
+			suffixes, field = buildingSuffixes, &parsed.Building
+		} else if _, ok := neighborhoodSuffixes[token]; !ok {
+			if stopPattern.MatchString(token) {
+				boundary = i + 1
+			}
+
+			continue
+		}
+
+		from := i
+		for from > boundary && i-from < 3 {
+			from--
+		}
+		boundary = i + 1
+
+		if from == i || *field != "" {
+			continue
+		}
+
+		*field = strings.TrimSpace(title(strings.Join(tokens[from:i], " ")) + " " + suffixes[token])
+	}
+
+	if m := buildingNoPattern.FindStringSubmatch(text); m != nil {
+		number := "No: " + strings.ToUpper(m[1])
+		if parsed.Building != "" {
+			parsed.Building += " " + number
+		} else {
+			parsed.Building = number
+		}
+
+		// "No: 12/5" is the common short form of the building and apartment number.
+		if m[2] != "" {
+			parsed.Apartment = m[2]
+		}
+	}
+
+	if m := floorPattern.FindStringSubmatch(text); m != nil {
+		parsed.Floor = title(m[1] + m[2])
+	}
+
+	if m := apartmentPattern.FindStringSubmatch(text); m != nil {
+		parsed.Apartment = m[1]
+	}
+
+	if m := districtPattern.FindStringSubmatch(text); m != nil && isProvince(m[2]) {
+		parsed.District = title(m[1])
+		parsed.Province = title(m[2])
+	} else {
+		words := strings.FieldsFunc(text, func(r rune) bool {
+			return !unicode.IsLetter(r)
+		})
+
+		for i, word := range words {
+			if !isProvince(word) {
+				continue
+			}
+
+			parsed.Province = title(word)
+
+			// "Antakya Hatay" at the end of an address is the district followed by the province.
+			if i == len(words)-1 && i > 0 && strings.HasSuffix(text, words[i-1]+" "+word) {
+				parsed.District = title(words[i-1])
+			}
+		}
+	}
+
+	if parsed.District == "" {
+		if m := districtWordPattern.FindStringSubmatch(text); m != nil {
+			parsed.District = title(m[1])
+		}
+	}
+
+	return parsed
+}
@@ -0,0 +1,82 @@
+package breaker
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+var ErrOpen = errors.New("circuit breaker is open")
+
+// Breaker stops calls to a failing dependency. After threshold failures in a row it opens for the cooldown,
+// then lets a single call through and closes again when that one succeeds.
+type Breaker struct {
+	threshold int
+	cooldown  time.Duration
+
+	mu        sync.Mutex
+	failures  int
+	openUntil time.Time
+	probing   bool
+}
+
+func New(threshold int, cooldown time.Duration) *Breaker {
+	return &Breaker{
+		threshold: threshold,
+		cooldown:  cooldown,
+	}
+}
+
+func (b *Breaker) allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.failures < b.threshold {
+		return nil
+	}
+
+	if time.Now().Before(b.openUntil) || b.probing {
+		return ErrOpen
+	}
+
+	b.probing = true
+
+	return nil
+}
+
+func (b *Breaker) record(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probing = false
+
+	if err == nil {
+		b.failures = 0
+
+		return
+	}
+
+	b.failures++
+	if b.failures >= b.threshold {
+		b.openUntil = time.Now().Add(b.cooldown)
+	}
+}
+
+// Do calls fn unless the breaker is open, in which case it fails with ErrOpen right away.
+func (b *Breaker) Do(fn func() error) error {
+	if err := b.allow(); err != nil {
+		return err
+	}
+
+	err := fn()
+	b.record(err)
+
+	return err
+}
+
+func (b *Breaker) Open() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.failures >= b.threshold && time.Now().Before(b.openUntil)
+}
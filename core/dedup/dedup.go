@@ -0,0 +1,262 @@
+package dedup
+
+import (
+	"encoding/binary"
+	"hash/fnv"
+	"math/rand"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+const (
+	DefaultThreshold = 0.8
+
+	shingleSize = 4
+	numHashes   = 64
+	numBands    = 16
+	bandRows    = numHashes / numBands
+)
+
+var (
+	retweetPrefix = regexp.MustCompile(`^rt @\S+:?`)
+	urls          = regexp.MustCompile(`https?://\S+`)
+	mentions      = regexp.MustCompile(`@\S+`)
+
+	// Fixed seeds keep signatures comparable between runs and replicas.
+	hashSeeds = func() []uint64 {
+		r := rand.New(rand.NewSource(1))
+
+		seeds := make([]uint64, numHashes)
+		for i := range seeds {
+			seeds[i] = r.Uint64()
+		}
+
+		return seeds
+	}()
+)
+
+// Normalize strips what differs between copies of the same tweet: case, retweet prefixes, links, mentions and punctuation.
+func Normalize(text string) string {
+	text = strings.ToLowerSpecial(unicode.TurkishCase, text)
+	text = retweetPrefix.ReplaceAllString(strings.TrimSpace(text), "")
+	text = urls.ReplaceAllString(text, " ")
+	text = mentions.ReplaceAllString(text, " ")
+
+	text = strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return r
+		}
+
+		return ' '
+	}, text)
+
+	return strings.Join(strings.Fields(text), " ")
+}
+
+type Signature []uint64
+
+func mix(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+
+	return x
+}
+
+// MinHash expects normalized text and builds its signature from character shingles.
+func MinHash(text string) Signature {
+	signature := make(Signature, numHashes)
+	for i := range signature {
+		signature[i] = ^uint64(0)
+	}
+
+	runes := []rune(text)
+	if len(runes) < shingleSize {
+		runes = append(runes, make([]rune, shingleSize-len(runes))...)
+	}
+
+	for i := 0; i+shingleSize <= len(runes); i++ {
+		h := fnv.New64a()
+		_, _ = h.Write([]byte(string(runes[i : i+shingleSize])))
+		shingle := h.Sum64()
+
+		for j, seed := range hashSeeds {
+			if v := mix(shingle ^ seed); v < signature[j] {
+				signature[j] = v
+			}
+		}
+	}
+
+	return signature
+}
+
+// Similarity estimates the Jaccard similarity of the shingle sets behind both signatures.
+func (s Signature) Similarity(other Signature) float64 {
+	same := 0
+	for i := range s {
+		if s[i] == other[i] {
+			same++
+		}
+	}
+
+	return float64(same) / float64(len(s))
+}
+
+func (s Signature) bands() []uint64 {
+	bands := make([]uint64, numBands)
+	buf := make([]byte, 8)
+
+	for b := 0; b < numBands; b++ {
+		h := fnv.New64a()
+		binary.LittleEndian.PutUint64(buf, uint64(b))
+		_, _ = h.Write(buf)
+
+		for _, v := range s[b*bandRows : (b+1)*bandRows] {
+			binary.LittleEndian.PutUint64(buf, v)
+			_, _ = h.Write(buf)
+		}
+
+		bands[b] = h.Sum64()
+	}
+
+	return bands
+}
+
+func Levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+
+	previous := make([]int, len(rb)+1)
+	current := make([]int, len(rb)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		current[0] = i
+
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+
+			current[j] = minInt(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+
+		previous, current = current, previous
+	}
+
+	return previous[len(rb)]
+}
+
+func minInt(values ...int) int {
+	m := values[0]
+	for _, v := range values[1:] {
+		if v < m {
+			m = v
+		}
+	}
+
+	return m
+}
+
+// LevenshteinRatio is 1 for equal strings and 0 for completely different ones.
+func LevenshteinRatio(a, b string) float64 {
+	longest := len([]rune(a))
+	if l := len([]rune(b)); l > longest {
+		longest = l
+	}
+
+	if longest == 0 {
+		return 1
+	}
+
+	return 1 - float64(Levenshtein(a, b))/float64(longest)
+}
+
+type indexEntry struct {
+	id        int
+	text      string
+	signature Signature
+}
+
+// Index finds near duplicates with locality sensitive hashing over MinHash bands, candidates are confirmed
+// with the edit distance. It is not safe for concurrent use.
+type Index struct {
+	threshold float64
+	entries   []*indexEntry
+	buckets   map[uint64][]int
+}
+
+func NewIndex(threshold float64) *Index {
+	if threshold <= 0 {
+		threshold = DefaultThreshold
+	}
+
+	return &Index{
+		threshold: threshold,
+		entries:   make([]*indexEntry, 0),
+		buckets:   make(map[uint64][]int),
+	}
+}
+
+func (i *Index) Len() int {
+	return len(i.entries)
+}
+
+func (i *Index) Add(id int, text string) {
+	normalized := Normalize(text)
+	signature := MinHash(normalized)
+
+	position := len(i.entries)
+	i.entries = append(i.entries, &indexEntry{
+		id:        id,
+		text:      normalized,
+		signature: signature,
+	})
+
+	for _, band := range signature.bands() {
+		i.buckets[band] = append(i.buckets[band], position)
+	}
+}
+
+// Match returns the ID of the earliest indexed entry which is a near duplicate of text.
+func (i *Index) Match(text string) (int, bool) {
+	normalized := Normalize(text)
+	signature := MinHash(normalized)
+	checked := make(map[int]bool)
+	best := -1
+
+	for _, band := range signature.bands() {
+		for _, position := range i.buckets[band] {
+			if checked[position] {
+				continue
+			}
+			checked[position] = true
+
+			if best != -1 && position > best {
+				continue
+			}
+
+			entry := i.entries[position]
+
+			// Shingle overlap drops faster than the edit distance, so it only serves as a loose pre-filter.
+			if signature.Similarity(entry.signature) < i.threshold*0.75 {
+				continue
+			}
+
+			if LevenshteinRatio(normalized, entry.text) >= i.threshold {
+				best = position
+			}
+		}
+	}
+
+	if best == -1 {
+		return 0, false
+	}
+
+	return i.entries[best].id, true
+}
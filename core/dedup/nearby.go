@@ -0,0 +1,131 @@
+package dedup
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/YusufOzmen01/veri-kontrol-backend/core/geohash"
+)
+
+const (
+	// NearbyRadius in metres, two reports closer than this are about the same place.
+	NearbyRadius = 50
+
+	// NearbyThreshold is a bit lower than DefaultThreshold, a second report from the same place may say the same
+	// thing in other words. Within 50m the texts of neighbouring buildings differ only in a house number or a
+	// count, so the numbers in both texts have to be the same as well.
+	NearbyThreshold = 0.75
+)
+
+// Point is a [lat, lng] coordinate with its geohash, an empty or too short geohash is computed.
+type Point struct {
+	Lat     float64
+	Lng     float64
+	Geohash string
+}
+
+func (p Point) cell() string {
+	if len(p.Geohash) >= geohash.NearbyPrecision {
+		return p.Geohash[:geohash.NearbyPrecision]
+	}
+
+	return geohash.Encode(p.Lat, p.Lng, geohash.NearbyPrecision)
+}
+
+type nearbyEntry struct {
+	id      int
+	order   int
+	point   Point
+	text    string
+	numbers string
+}
+
+// numbers are the words of a normalized text with a digit in them, like 12 or 5a, sorted so the same report
+// with the words in another order still matches.
+func numbers(normalized string) string {
+	found := make([]string, 0)
+	for _, field := range strings.Fields(normalized) {
+		if strings.IndexFunc(field, unicode.IsDigit) >= 0 {
+			found = append(found, field)
+		}
+	}
+
+	sort.Strings(found)
+
+	return strings.Join(found, " ")
+}
+
+// NearbyIndex finds reports of the same place, within radius metres and with a similar text. Entries are kept
+// per geohash cell so a lookup only compares the nine cells around the point. It is not safe for concurrent use.
+type NearbyIndex struct {
+	threshold float64
+	radius    float64
+	cells     map[string][]*nearbyEntry
+	count     int
+}
+
+func NewNearbyIndex(threshold, radius float64) *NearbyIndex {
+	if threshold <= 0 {
+		threshold = NearbyThreshold
+	}
+
+	if radius <= 0 {
+		radius = NearbyRadius
+	}
+
+	return &NearbyIndex{
+		threshold: threshold,
+		radius:    radius,
+		cells:     make(map[string][]*nearbyEntry),
+	}
+}
+
+func (i *NearbyIndex) Len() int {
+	return i.count
+}
+
+func (i *NearbyIndex) Add(id int, point Point, text string) {
+	cell := point.cell()
+
+	normalized := Normalize(text)
+
+	i.cells[cell] = append(i.cells[cell], &nearbyEntry{
+		id:      id,
+		order:   i.count,
+		point:   point,
+		text:    normalized,
+		numbers: numbers(normalized),
+	})
+	i.count++
+}
+
+// Match returns the ID of the earliest indexed entry near the point with a text similar to text and the same
+// numbers in it.
+func (i *NearbyIndex) Match(point Point, text string) (int, bool) {
+	normalized := Normalize(text)
+	numbers := numbers(normalized)
+	var best *nearbyEntry
+
+	for _, cell := range geohash.Neighbours(point.cell()) {
+		for _, entry := range i.cells[cell] {
+			if best != nil && entry.order > best.order {
+				continue
+			}
+
+			if entry.numbers != numbers || geohash.Distance(point.Lat, point.Lng, entry.point.Lat, entry.point.Lng) > i.radius {
+				continue
+			}
+
+			if LevenshteinRatio(normalized, entry.text) >= i.threshold {
+				best = entry
+			}
+		}
+	}
+
+	if best == nil {
+		return 0, false
+	}
+
+	return best.id, true
+}
@@ -0,0 +1,27 @@
+package dedup
+
+import "testing"
+
+func TestNearbyMatch(t *testing.T) {
+	index := NewNearbyIndex(0, 0)
+	index.Add(1, Point{Lat: 36.2023, Lng: 36.1613}, "Kurtuluş Mah. 12. Sokak No 5 enkaz altında 3 kişi var")
+
+	tests := []struct {
+		name  string
+		point Point
+		text  string
+		want  bool
+	}{
+		{"retweet", Point{Lat: 36.2023, Lng: 36.1613}, "RT @x: Kurtuluş Mah. 12. Sokak No 5 enkaz altında 3 kişi var!!", true},
+		{"reworded", Point{Lat: 36.2024, Lng: 36.1614}, "Kurtuluş mahallesi 12. sokak no 5 enkazın altında 3 kişi var", true},
+		{"next building", Point{Lat: 36.2024, Lng: 36.1614}, "Kurtuluş Mah. 12. Sokak No 7 enkaz altında 2 kişi var", false},
+		{"too far", Point{Lat: 36.2043, Lng: 36.1613}, "Kurtuluş Mah. 12. Sokak No 5 enkaz altında 3 kişi var", false},
+		{"different text", Point{Lat: 36.2023, Lng: 36.1613}, "Çadır ve battaniye lazım, 12. sokakta 5 aile var", false},
+	}
+
+	for _, tt := range tests {
+		if _, got := index.Match(tt.point, tt.text); got != tt.want {
+			t.Errorf("%s: matched %t, want %t", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package geohash
+
+import (
+	"math"
+	"strings"
+)
+
+const (
+	// Precision is what is stored, 9 characters are a cell of about 5 metres.
+	Precision = 9
+
+	// NearbyPrecision cells are about 150 by 150 metres, together with their neighbours they cover everything
+	// within 50 metres of a point in the middle one.
+	NearbyPrecision = 7
+
+	base32 = "0123456789bcdefghjkmnpqrstuvwxyz"
+
+	earthRadius = 6371000
+)
+
+// Encode returns the geohash of the point with precision characters.
+func Encode(lat, lng float64, precision int) string {
+	latRange := [2]float64{-90, 90}
+	lngRange := [2]float64{-180, 180}
+
+	var hash strings.Builder
+	bit, ch, even := 0, 0, true
+
+	for hash.Len() < precision {
+		r, v := &latRange, lat
+		if even {
+			r, v = &lngRange, lng
+		}
+
+		mid := (r[0] + r[1]) / 2
+		if v >= mid {
+			ch |= 1 << (4 - bit)
+			r[0] = mid
+		} else {
+			r[1] = mid
+		}
+
+		even = !even
+
+		if bit++; bit == 5 {
+			hash.WriteByte(base32[ch])
+			bit, ch = 0, 0
+		}
+	}
+
+	return hash.String()
+}
+
+// Valid reports whether hash is a geohash of at most Precision characters.
+func Valid(hash string) bool {
+	if hash == "" || len(hash) > Precision {
+		return false
+	}
+
+	for _, c := range hash {
+		if !strings.ContainsRune(base32, c) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Bounds returns the south west and north east corners of the cell.
+func Bounds(hash string) (minLat, minLng, maxLat, maxLng float64) {
+	minLat, maxLat = -90, 90
+	minLng, maxLng = -180, 180
+	even := true
+
+	for _, c := range hash {
+		idx := strings.IndexRune(base32, c)
+		if idx < 0 {
+			break
+		}
+
+		for bit := 4; bit >= 0; bit-- {
+			on := idx&(1<<bit) != 0
+
+			if even {
+				mid := (minLng + maxLng) / 2
+				if on {
+					minLng = mid
+				} else {
+					maxLng = mid
+				}
+			} else {
+				mid := (minLat + maxLat) / 2
+				if on {
+					minLat = mid
+				} else {
+					maxLat = mid
+				}
+			}
+
+			even = !even
+		}
+	}
+
+	return minLat, minLng, maxLat, maxLng
+}
+
+// Neighbours returns the cell and the eight around it, cells past the poles are left out and the ones across
+// the antimeridian wrap around.
+func Neighbours(hash string) []string {
+	minLat, minLng, maxLat, maxLng := Bounds(hash)
+	height, width := maxLat-minLat, maxLng-minLng
+	lat, lng := (minLat+maxLat)/2, (minLng+maxLng)/2
+
+	cells := make([]string, 0, 9)
+	for dLat := -1; dLat <= 1; dLat++ {
+		for dLng := -1; dLng <= 1; dLng++ {
+			nLat := lat + float64(dLat)*height
+			if nLat < -90 || nLat > 90 {
+				continue
+			}
+
+			nLng := lng + float64(dLng)*width
+			if nLng < -180 {
+				nLng += 360
+			} else if nLng > 180 {
+				nLng -= 360
+			}
+
+			cells = append(cells, Encode(nLat, nLng, len(hash)))
+		}
+	}
+
+	return cells
+}
+
+// Distance is the great circle distance between two points in metres.
+func Distance(lat1, lng1, lat2, lng2 float64) float64 {
+	toRad := math.Pi / 180
+
+	dLat := (lat2 - lat1) * toRad
+	dLng := (lng2 - lng1) * toRad
+
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1*toRad)*math.Cos(lat2*toRad)*math.Sin(dLng/2)*math.Sin(dLng/2)
+
+	return 2 * earthRadius * math.Asin(math.Sqrt(a))
+}
@@ -0,0 +1,92 @@
+package heatmap
+
+import (
+	"sort"
+
+	"github.com/YusufOzmen01/veri-kontrol-backend/core/geohash"
+)
+
+const (
+	MinZoom = 0
+	MaxZoom = 20
+
+	// cellsPerTile is how many cells a 256 pixel map tile is split into at least, a cell is then at most 64
+	// pixels wide at every zoom level.
+	cellsPerTile = 4
+)
+
+// Cluster is the points of one geohash cell, Lat and Lng are their centroid rather than the cell centre so the
+// marker sits where the reports are.
+type Cluster struct {
+	Geohash string  `json:"geohash"`
+	Lat     float64 `json:"lat"`
+	Lng     float64 `json:"lng"`
+	Count   int     `json:"count"`
+}
+
+// Point is a [lat, lng] coordinate with its stored geohash, a missing or too short geohash is computed.
+type Point struct {
+	Lat     float64
+	Lng     float64
+	Geohash string
+}
+
+// Precision is the geohash length whose cells fit cellsPerTile times into a map tile at the given web map
+// zoom level. A tile spans 360 / 2^zoom degrees of longitude, a geohash of p characters ceil(5p / 2) bits of it.
+func Precision(zoom int) int {
+	for p := 1; p < geohash.Precision; p++ {
+		if (5*p+1)/2 >= zoom+2 {
+			return p
+		}
+	}
+
+	return geohash.Precision
+}
+
+type sum struct {
+	lat, lng float64
+	count    int
+}
+
+// Group puts the points into geohash cells of the given precision, the biggest clusters come first.
+func Group(points []Point, precision int) []*Cluster {
+	cells := make(map[string]*sum)
+
+	for _, p := range points {
+		key := p.Geohash
+		if len(key) < precision {
+			key = geohash.Encode(p.Lat, p.Lng, precision)
+		}
+		key = key[:precision]
+
+		s, ok := cells[key]
+		if !ok {
+			s = &sum{}
+			cells[key] = s
+		}
+
+		s.lat += p.Lat
+		s.lng += p.Lng
+		s.count++
+	}
+
+	clusters := make([]*Cluster, 0, len(cells))
+	for key, s := range cells {
+		clusters = append(clusters, &Cluster{
+			Geohash: key,
+			Lat:     s.lat / float64(s.count),
+			Lng:     s.lng / float64(s.count),
+			Count:   s.count,
+		})
+	}
+
+	sort.Slice(clusters, func(i, j int) bool {
+		if clusters[i].Count != clusters[j].Count {
+			return clusters[i].Count > clusters[j].Count
+		}
+
+		return clusters[i].Geohash < clusters[j].Geohash
+	})
+
+	return clusters
+}
@@ -0,0 +1,98 @@
+package language
+
+import (
+	"strings"
+	"unicode"
+)
+
+// The languages entries are tagged with. Kurdish covers both Kurmanji, written in Latin letters, and Sorani,
+// written in Arabic letters.
+const (
+	Turkish = "tr"
+	Kurdish = "ku"
+	Arabic  = "ar"
+	English = "en"
+)
+
+var Supported = []string{Turkish, Kurdish, Arabic, English}
+
+// soraniLetters are Arabic script letters Arabic itself doesn't use.
+const soraniLetters = "ڕڵۆێەڤگچپژ"
+
+// markers are words which are common in reports of one language and rare in the others. Kurmanji shares ç and ş
+// with Turkish, so its letters only count with ê, which Turkish doesn't have.
+var markers = map[string][]string{
+	Turkish: {"ve", "bir", "bu", "için", "ile", "var", "yok", "acil", "yardım", "enkaz", "altında", "lütfen", "mahallesi", "mah", "sokak", "sk", "cad", "apartmanı", "apt", "kat", "ses", "geliyor", "bina", "kişi", "yaralı"},
+	Kurdish: {"û", "jî", "ji", "di", "li", "bi", "ez", "hûn", "em", "wan", "çi", "heye", "hene", "alîkarî", "birîndar", "zarok", "xanî", "malê", "kolanê", "bajarê"},
+	English: {"the", "and", "is", "are", "we", "need", "help", "please", "under", "there", "people", "for", "of", "with", "rubble", "trapped", "building", "street"},
+}
+
+var markerLanguage = func() map[string]string {
+	m := make(map[string]string)
+	for lang, words := range markers {
+		for _, word := range words {
+			m[word] = lang
+		}
+	}
+
+	return m
+}()
+
+// Detect guesses the language of a report from its script and a few common words. Latin text without any
+// hints is taken for Turkish, which most of the feed is. Empty text has no language.
+func Detect(text string) string {
+	arabic, latin := 0, 0
+	sorani := false
+
+	for _, r := range text {
+		switch {
+		case unicode.Is(unicode.Arabic, r):
+			arabic++
+			if strings.ContainsRune(soraniLetters, r) {
+				sorani = true
+			}
+		case unicode.Is(unicode.Latin, r):
+			latin++
+		}
+	}
+
+	if arabic == 0 && latin == 0 {
+		return ""
+	}
+
+	if arabic > latin {
+		if sorani {
+			return Kurdish
+		}
+
+		return Arabic
+	}
+
+	scores := make(map[string]int, len(markers))
+
+	words := strings.FieldsFunc(strings.ToLowerSpecial(unicode.TurkishCase, text), func(r rune) bool {
+		return !unicode.IsLetter(r)
+	})
+
+	for _, word := range words {
+		if lang, ok := markerLanguage[word]; ok {
+			scores[lang] += 2
+		}
+
+		switch {
+		case strings.ContainsAny(word, "ığöü"):
+			scores[Turkish]++
+		case strings.ContainsRune(word, 'ê'):
+			scores[Kurdish]++
+		}
+	}
+
+	detected, best := Turkish, scores[Turkish]
+	for _, lang := range []string{Kurdish, English} {
+		if scores[lang] > best {
+			detected, best = lang, scores[lang]
+		}
+	}
+
+	return detected
+}
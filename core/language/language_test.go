@@ -0,0 +1,30 @@
+package language
+
+import "testing"
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{"empty", "", ""},
+		{"only numbers", "0532 123 45 67", ""},
+		{"turkish", "Enkaz altında ses geliyor, acil yardım lütfen", Turkish},
+		{"turkish letters only", "Gökçe ağabeyim İskenderun'da", Turkish},
+		{"latin without hints", "Antakya Cebrail", Turkish},
+		{"english", "We need help, people are trapped under the building", English},
+		{"kurmanji", "Em li bajarê Semsûrê ne, alîkarî hene", Kurdish},
+		{"kurmanji with turkish letters", "Zarokên me birîndar in, çi bikin", Kurdish},
+		{"arabic", "نحن تحت الأنقاض في أنطاكيا نحتاج المساعدة", Arabic},
+		{"sorani", "ئێمە لە ژێر داروپەردووین یارمەتیمان بدەن", Kurdish},
+		{"arabic with a latin address", "نحتاج المساعدة Antakya", Arabic},
+		{"upper case", "ENKAZ ALTINDA YARDIM", Turkish},
+	}
+
+	for _, tt := range tests {
+		if got := Detect(tt.text); got != tt.want {
+			t.Errorf("%s: Detect(%q) = %q, want %q", tt.name, tt.text, got, tt.want)
+		}
+	}
+}
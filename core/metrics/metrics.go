@@ -0,0 +1,81 @@
+package metrics
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	RequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_request_duration_seconds",
+		Help:    "Latency of HTTP requests per route.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	Resolves = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "resolves_total",
+		Help: "Resolved entries by reason.",
+	}, []string{"reason"})
+
+	CacheRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "cache_requests_total",
+		Help: "Cache lookups by result, hit or miss.",
+	}, []string{"result"})
+
+	MongoDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "mongo_operation_duration_seconds",
+		Help:    "Duration of MongoDB operations.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"operation", "collection"})
+
+	Snapshots = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "export_snapshots_total",
+		Help: "Scheduled export snapshots by format and result, success or failure.",
+	}, []string{"format", "result"})
+
+	SnapshotLastSuccess = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "export_snapshot_last_success_timestamp_seconds",
+		Help: "Unix time of the last snapshot which was uploaded, per format.",
+	}, []string{"format"})
+
+	UpstreamCalls = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "upstream_calls_total",
+		Help: "Feed and text lookups by kind and whether they shared an upstream call already in flight.",
+	}, []string{"kind", "shared"})
+)
+
+func ObserveRequest(method, route string, status int, start time.Time) {
+	RequestDuration.WithLabelValues(method, route, strconv.Itoa(status)).Observe(time.Since(start).Seconds())
+}
+
+func ObserveMongo(operation, collection string, start time.Time) {
+	MongoDuration.WithLabelValues(operation, collection).Observe(time.Since(start).Seconds())
+}
+
+func ObserveCache(hit bool) {
+	if hit {
+		CacheRequests.WithLabelValues("hit").Inc()
+
+		return
+	}
+
+	CacheRequests.WithLabelValues("miss").Inc()
+}
+
+func ObserveUpstreamCall(kind string, shared bool) {
+	UpstreamCalls.WithLabelValues(kind, strconv.FormatBool(shared)).Inc()
+}
+
+func ObserveSnapshot(format string, err error) {
+	if err != nil {
+		Snapshots.WithLabelValues(format, "failure").Inc()
+
+		return
+	}
+
+	Snapshots.WithLabelValues(format, "success").Inc()
+	SnapshotLastSuccess.WithLabelValues(format).SetToCurrentTime()
+}
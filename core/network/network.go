@@ -6,20 +6,26 @@ import (
 	"io"
 	"net/http"
 	"time"
+
+	"github.com/YusufOzmen01/veri-kontrol-backend/core/tracing"
 )
 
 //goland:noinspection GoUnusedGlobalVariable,GoUnusedGlobalVariable,GoUnusedGlobalVariable
 var (
-	defaultHTTPClient = &http.Client{}
-	HC10              = &http.Client{Timeout: time.Second * 10}
-	HC15              = &http.Client{Timeout: time.Second * 15}
-	HC30              = &http.Client{Timeout: time.Second * 30}
+	defaultHTTPClient = &http.Client{Transport: tracing.Transport(http.DefaultTransport)}
+	HC10              = &http.Client{Timeout: time.Second * 10, Transport: tracing.Transport(http.DefaultTransport)}
+	HC15              = &http.Client{Timeout: time.Second * 15, Transport: tracing.Transport(http.DefaultTransport)}
+	HC30              = &http.Client{Timeout: time.Second * 30, Transport: tracing.Transport(http.DefaultTransport)}
 )
 
 func ProcessGet(ctx context.Context, url string, headers map[string]string) ([]byte, int, error) {
 	return unsafeHTTPCall(ctx, defaultHTTPClient, "GET", url, nil, headers)
 }
 
+func ProcessPost(ctx context.Context, url string, body []byte, headers map[string]string) ([]byte, int, error) {
+	return unsafeHTTPCall(ctx, HC15, "POST", url, body, headers)
+}
+
 func unsafeHTTPCall(ctx context.Context, client *http.Client, method string, url string, body []byte, headers map[string]string) ([]byte, int, error) {
 	req, err := http.NewRequest(method, url, bytes.NewBuffer(body))
 	if err != nil {
@@ -35,6 +41,7 @@ func unsafeHTTPCall(ctx context.Context, client *http.Client, method string, url
 	if err != nil {
 		return nil, 0, err
 	}
+	defer resp.Body.Close()
 
 	body, err = io.ReadAll(resp.Body)
 	if err != nil {
@@ -0,0 +1,56 @@
+package network
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+// StatusError is an unexpected response status.
+type StatusError struct {
+	Status int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("unexpected status %d", e.Status)
+}
+
+// Retryable is true for network errors, 5xx and 429. Other statuses won't change by asking again.
+func Retryable(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.Status >= 500 || statusErr.Status == 429
+	}
+
+	return true
+}
+
+// Retry calls fn up to attempts times. The waits double from base and are fully jittered, so clients which
+// failed together don't come back together.
+func Retry(ctx context.Context, attempts int, base time.Duration, fn func() error) error {
+	var err error
+
+	for i := 0; i < attempts; i++ {
+		if i > 0 {
+			wait := time.Duration(rand.Int63n(int64(base<<(i-1)) + 1))
+
+			select {
+			case <-ctx.Done():
+				return err
+			case <-time.After(wait):
+			}
+		}
+
+		if err = fn(); err == nil || !Retryable(err) {
+			return err
+		}
+	}
+
+	return err
+}
@@ -0,0 +1,252 @@
+package openapi
+
+import (
+	"encoding"
+	"encoding/json"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+)
+
+type Document struct {
+	OpenAPI    string                           `json:"openapi"`
+	Info       Info                             `json:"info"`
+	Paths      map[string]map[string]*Operation `json:"paths"`
+	Components Components                       `json:"components"`
+}
+
+type Info struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+type Components struct {
+	Schemas         map[string]*Schema         `json:"schemas"`
+	SecuritySchemes map[string]*SecurityScheme `json:"securitySchemes,omitempty"`
+}
+
+type SecurityScheme struct {
+	Type   string `json:"type"`
+	Scheme string `json:"scheme,omitempty"`
+	In     string `json:"in,omitempty"`
+	Name   string `json:"name,omitempty"`
+}
+
+type Operation struct {
+	Summary     string                `json:"summary,omitempty"`
+	Tags        []string              `json:"tags,omitempty"`
+	Parameters  []*Parameter          `json:"parameters,omitempty"`
+	RequestBody *RequestBody          `json:"requestBody,omitempty"`
+	Responses   map[string]*Response  `json:"responses"`
+	Security    []map[string][]string `json:"security,omitempty"`
+}
+
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+type RequestBody struct {
+	Required bool                  `json:"required"`
+	Content  map[string]*MediaType `json:"content"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+type Response struct {
+	Description string                `json:"description"`
+	Content     map[string]*MediaType `json:"content,omitempty"`
+}
+
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	Enum                 []interface{}      `json:"enum,omitempty"`
+}
+
+func New(title, version string) *Document {
+	return &Document{
+		OpenAPI: "3.0.3",
+		Info: Info{
+			Title:   title,
+			Version: version,
+		},
+		Paths: make(map[string]map[string]*Operation),
+		Components: Components{
+			Schemas:         make(map[string]*Schema),
+			SecuritySchemes: make(map[string]*SecurityScheme),
+		},
+	}
+}
+
+var pathParam = regexp.MustCompile(`:(\w+)`)
+
+// Add registers an operation under a fiber style path, ":entry_id" segments become integer path parameters
+// unless the operation already describes them.
+func (d *Document) Add(method, path string, op *Operation) {
+	for _, match := range pathParam.FindAllStringSubmatch(path, -1) {
+		found := false
+		for _, parameter := range op.Parameters {
+			if parameter.Name == match[1] && parameter.In == "path" {
+				found = true
+			}
+		}
+
+		if !found {
+			op.Parameters = append(op.Parameters, &Parameter{
+				Name:     match[1],
+				In:       "path",
+				Required: true,
+				Schema:   &Schema{Type: "integer"},
+			})
+		}
+	}
+
+	if op.Responses == nil {
+		op.Responses = map[string]*Response{"200": {Description: "OK"}}
+	}
+
+	path = pathParam.ReplaceAllString(path, "{$1}")
+	if d.Paths[path] == nil {
+		d.Paths[path] = make(map[string]*Operation)
+	}
+
+	d.Paths[path][strings.ToLower(method)] = op
+}
+
+// Has tells whether a fiber style path is documented for the method.
+func (d *Document) Has(method, path string) bool {
+	_, ok := d.Paths[pathParam.ReplaceAllString(path, "{$1}")][strings.ToLower(method)]
+
+	return ok
+}
+
+// JSON is a shortcut for a JSON request body or response of the given value's type.
+func (d *Document) JSON(v interface{}) map[string]*MediaType {
+	return map[string]*MediaType{
+		"application/json": {Schema: d.SchemaOf(v)},
+	}
+}
+
+// SchemaOf describes the value's type the way encoding/json would serialize it. Named structs are added to
+// the components and referenced, so the spec follows the structs the handlers actually use.
+func (d *Document) SchemaOf(v interface{}) *Schema {
+	return d.schema(reflect.TypeOf(v))
+}
+
+var (
+	timeType          = reflect.TypeOf(time.Time{})
+	jsonMarshaler     = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshaler     = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	emptyInterfaceTyp = reflect.TypeOf((*interface{})(nil)).Elem()
+)
+
+func (d *Document) schema(t reflect.Type) *Schema {
+	if t == nil || t == emptyInterfaceTyp {
+		return &Schema{}
+	}
+
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	if t == timeType {
+		return &Schema{Type: "string", Format: "date-time"}
+	}
+
+	// Types with their own encoding, like ObjectIDs, serialize to strings.
+	if t.Implements(jsonMarshaler) || reflect.PtrTo(t).Implements(jsonMarshaler) || t.Implements(textMarshaler) || reflect.PtrTo(t).Implements(textMarshaler) {
+		return &Schema{Type: "string"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Int64, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+
+		return &Schema{Type: "array", Items: d.schema(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: d.schema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return d.structSchema(t)
+		}
+
+		name := t.Name()
+		if _, ok := d.Components.Schemas[name]; !ok {
+			// Reserve the name first, self referencing structs would recurse forever otherwise.
+			d.Components.Schemas[name] = &Schema{}
+			*d.Components.Schemas[name] = *d.structSchema(t)
+		}
+
+		return &Schema{Ref: "#/components/schemas/" + name}
+	}
+
+	return &Schema{}
+}
+
+func (d *Document) structSchema(t reflect.Type) *Schema {
+	schema := &Schema{
+		Type:       "object",
+		Properties: make(map[string]*Schema),
+	}
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		name := field.Name
+		if tag := field.Tag.Get("json"); tag != "" {
+			if tag == "-" {
+				continue
+			}
+
+			if n := strings.Split(tag, ",")[0]; n != "" {
+				name = n
+			}
+		}
+
+		if field.Anonymous && field.Tag.Get("json") == "" {
+			embedded := field.Type
+			for embedded.Kind() == reflect.Ptr {
+				embedded = embedded.Elem()
+			}
+
+			if embedded.Kind() == reflect.Struct {
+				for k, v := range d.structSchema(embedded).Properties {
+					schema.Properties[k] = v
+				}
+
+				continue
+			}
+		}
+
+		schema.Properties[name] = d.schema(field.Type)
+	}
+
+	return schema
+}
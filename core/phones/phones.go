@@ -0,0 +1,98 @@
+package phones
+
+import (
+	"regexp"
+	"strings"
+)
+
+// mobilePattern matches Turkish mobile numbers the way people type them: 05321234567, 0532 123 45 67,
+// (532) 123-45-67, +90 532 123 4567 and so on. Landlines aren't matched, nobody under rubble answers one.
+var mobilePattern = regexp.MustCompile(`(?:\+?\s?90[\s.-]?)?\(?0?\s?\(?5\d{2}\)?[\s.-]?\d{3}[\s.-]?\d{2}[\s.-]?\d{2}`)
+
+func isDigit(b byte) bool {
+	return b >= '0' && b <= '9'
+}
+
+// normalize returns the number as +905XXXXXXXXX, empty when the digits aren't a mobile number.
+func normalize(match string) string {
+	digits := strings.Map(func(r rune) rune {
+		if r >= '0' && r <= '9' {
+			return r
+		}
+
+		return -1
+	}, match)
+
+	switch {
+	case len(digits) == 12 && strings.HasPrefix(digits, "90"):
+		digits = digits[2:]
+	case len(digits) == 11 && strings.HasPrefix(digits, "0"):
+		digits = digits[1:]
+	}
+
+	if len(digits) != 10 || digits[0] != '5' {
+		return ""
+	}
+
+	return "+90" + digits
+}
+
+// find returns the bounds of the mobile numbers in the text. Digits running on before or after a match mean it
+// is part of something longer, like an ID number, and it is skipped.
+func find(text string) [][]int {
+	var found [][]int
+
+	for _, bounds := range mobilePattern.FindAllStringIndex(text, -1) {
+		start, end := bounds[0], bounds[1]
+		if (start > 0 && isDigit(text[start-1])) || (end < len(text) && isDigit(text[end])) {
+			continue
+		}
+
+		if normalize(text[start:end]) == "" {
+			continue
+		}
+
+		found = append(found, bounds)
+	}
+
+	return found
+}
+
+// Extract returns the mobile numbers in the text in the order they appear, without repeats.
+func Extract(text string) []string {
+	var numbers []string
+
+	seen := make(map[string]bool)
+
+	for _, bounds := range find(text) {
+		number := normalize(text[bounds[0]:bounds[1]])
+		if seen[number] {
+			continue
+		}
+
+		seen[number] = true
+		numbers = append(numbers, number)
+	}
+
+	return numbers
+}
+
+// Mask replaces the digits of the mobile numbers in the text with asterisks, the rest of the text stays as it
+// is.
+func Mask(text string) string {
+	found := find(text)
+	if len(found) == 0 {
+		return text
+	}
+
+	masked := []byte(text)
+	for _, bounds := range found {
+		for i := bounds[0]; i < bounds[1]; i++ {
+			if isDigit(masked[i]) {
+				masked[i] = '*'
+			}
+		}
+	}
+
+	return string(masked)
+}
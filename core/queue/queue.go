@@ -0,0 +1,174 @@
+package queue
+
+import (
+	"container/heap"
+	"fmt"
+	"math"
+	"math/rand"
+	"time"
+)
+
+type Strategy string
+
+const (
+	StrategyRandom   Strategy = "random"
+	StrategyFIFO     Strategy = "fifo"
+	StrategyPriority Strategy = "priority"
+	StrategyScore    Strategy = "score"
+)
+
+// clusterCellSize is roughly a kilometre in degrees, reports landing in the same cell count as one cluster.
+const clusterCellSize = 0.01
+
+func ParseStrategy(s string) (Strategy, error) {
+	switch Strategy(s) {
+	case StrategyRandom, StrategyFIFO, StrategyPriority, StrategyScore:
+		return Strategy(s), nil
+	}
+
+	return "", fmt.Errorf("strategy must be one of random, fifo, priority, score")
+}
+
+// Candidate is an entry waiting for a volunteer, Epoch is when it was reported and Score how urgent its text
+// sounds.
+type Candidate struct {
+	ID    int
+	Epoch int
+	Lat   float64
+	Lng   float64
+	Score float64
+}
+
+type item struct {
+	id       int
+	score    float64
+	tiebreak int64
+}
+
+type items []*item
+
+func (h items) Len() int { return len(h) }
+
+func (h items) Less(i, j int) bool {
+	if h[i].score != h[j].score {
+		return h[i].score > h[j].score
+	}
+
+	return h[i].tiebreak < h[j].tiebreak
+}
+
+func (h items) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+
+func (h *items) Push(x interface{}) { *h = append(*h, x.(*item)) }
+
+func (h *items) Pop() interface{} {
+	old := *h
+	last := old[len(old)-1]
+	*h = old[:len(old)-1]
+
+	return last
+}
+
+// Queue hands out candidates highest score first, equal scores come out in random order. The random strategy
+// doesn't need a heap, it draws with a Fisher–Yates shuffle which is only carried out as far as it is popped,
+// so every candidate is tested at most once. It is not safe for concurrent use.
+type Queue struct {
+	items items
+	ids   []int
+}
+
+func New(strategy Strategy, candidates []Candidate, now time.Time) *Queue {
+	if strategy == StrategyRandom {
+		q := &Queue{
+			ids: make([]int, 0, len(candidates)),
+		}
+
+		for _, c := range candidates {
+			q.ids = append(q.ids, c.ID)
+		}
+
+		return q
+	}
+
+	var clusters map[[2]int]int
+	if strategy == StrategyPriority {
+		clusters = make(map[[2]int]int)
+		for _, c := range candidates {
+			clusters[cell(c)]++
+		}
+	}
+
+	q := &Queue{
+		items: make(items, 0, len(candidates)),
+	}
+
+	for _, c := range candidates {
+		it := &item{
+			id:       c.ID,
+			tiebreak: rand.Int63(),
+		}
+
+		switch strategy {
+		case StrategyFIFO:
+			it.score = -float64(c.Epoch)
+		case StrategyPriority:
+			it.score = urgency(c, clusters[cell(c)], now)
+		case StrategyScore:
+			it.score = c.Score
+		}
+
+		q.items = append(q.items, it)
+	}
+
+	heap.Init(&q.items)
+
+	return q
+}
+
+func cell(c Candidate) [2]int {
+	return [2]int{int(math.Floor(c.Lat / clusterCellSize)), int(math.Floor(c.Lng / clusterCellSize))}
+}
+
+// urgency grows with the time an entry has been waiting, and faster when many reports come from the same
+// area since that usually means a collapsed building with several people asking for help.
+func urgency(c Candidate, clusterSize int, now time.Time) float64 {
+	waiting := now.Sub(time.Unix(int64(c.Epoch), 0)).Minutes()
+	if waiting < 1 {
+		waiting = 1
+	}
+
+	return waiting * math.Log2(1+float64(clusterSize))
+}
+
+func (q *Queue) Len() int {
+	return len(q.items) + len(q.ids)
+}
+
+// Pop returns the next candidate ID, false once the queue is exhausted.
+func (q *Queue) Pop() (int, bool) {
+	if q.ids != nil {
+		return q.draw()
+	}
+
+	if len(q.items) == 0 {
+		return 0, false
+	}
+
+	return heap.Pop(&q.items).(*item).id, true
+}
+
+// draw is one step of Fisher–Yates: a random remaining ID is swapped to the end and cut off.
+func (q *Queue) draw() (int, bool) {
+	n := len(q.ids)
+	if n == 0 {
+		return 0, false
+	}
+
+	i := rand.Intn(n)
+	q.ids[i], q.ids[n-1] = q.ids[n-1], q.ids[i]
+
+	id := q.ids[n-1]
+	q.ids = q.ids[:n-1]
+
+	return id, true
+}
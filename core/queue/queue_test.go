@@ -0,0 +1,175 @@
+package queue
+
+import (
+	"testing"
+	"time"
+)
+
+func candidatesOf(ids ...int) []Candidate {
+	candidates := make([]Candidate, 0, len(ids))
+	for _, id := range ids {
+		candidates = append(candidates, Candidate{ID: id})
+	}
+
+	return candidates
+}
+
+func drain(q *Queue) []int {
+	ids := make([]int, 0)
+	for {
+		id, ok := q.Pop()
+		if !ok {
+			return ids
+		}
+
+		ids = append(ids, id)
+	}
+}
+
+func TestRandomEmptyPool(t *testing.T) {
+	for _, candidates := range [][]Candidate{nil, {}} {
+		q := New(StrategyRandom, candidates, time.Now())
+
+		if q.Len() != 0 {
+			t.Fatalf("Len() = %d, want 0", q.Len())
+		}
+
+		if id, ok := q.Pop(); ok {
+			t.Fatalf("Pop() = %d, true on an empty pool", id)
+		}
+	}
+}
+
+func TestRandomDrainsPool(t *testing.T) {
+	ids := make([]int, 0, 1000)
+	for i := 1; i <= 1000; i++ {
+		ids = append(ids, i)
+	}
+
+	q := New(StrategyRandom, candidatesOf(ids...), time.Now())
+
+	seen := make(map[int]bool, len(ids))
+	for i := range ids {
+		if q.Len() != len(ids)-i {
+			t.Fatalf("Len() = %d after %d draws, want %d", q.Len(), i, len(ids)-i)
+		}
+
+		id, ok := q.Pop()
+		if !ok {
+			t.Fatalf("Pop() ran out after %d of %d draws", i, len(ids))
+		}
+
+		if id < 1 || id > len(ids) {
+			t.Fatalf("Pop() = %d, not in the pool", id)
+		}
+
+		if seen[id] {
+			t.Fatalf("Pop() returned %d twice", id)
+		}
+
+		seen[id] = true
+	}
+
+	if id, ok := q.Pop(); ok {
+		t.Fatalf("Pop() = %d, true on a drained pool", id)
+	}
+
+	if q.Len() != 0 {
+		t.Fatalf("Len() = %d on a drained pool, want 0", q.Len())
+	}
+}
+
+// Every candidate is drawn once, an ID listed more than once comes out as often as it is listed.
+func TestRandomDuplicateIDs(t *testing.T) {
+	ids := []int{7, 7, 7, 7, 7, 7, 7, 7, 3, 7, 9, 3}
+
+	want := make(map[int]int)
+	for _, id := range ids {
+		want[id]++
+	}
+
+	got := make(map[int]int)
+	for _, id := range drain(New(StrategyRandom, candidatesOf(ids...), time.Now())) {
+		got[id]++
+	}
+
+	if len(got) != len(want) {
+		t.Fatalf("drew %v, want %v", got, want)
+	}
+
+	for id, n := range want {
+		if got[id] != n {
+			t.Fatalf("drew %d %d times, want %d", id, got[id], n)
+		}
+	}
+}
+
+// The claim handler skips the processed entries it pops, the few left have to be found without testing any
+// candidate twice.
+func TestRandomMostlyProcessed(t *testing.T) {
+	const size = 5000
+
+	ids := make([]int, 0, size)
+	processed := make(map[int]bool, size)
+	for i := 1; i <= size; i++ {
+		ids = append(ids, i)
+		if i%500 != 0 {
+			processed[i] = true
+		}
+	}
+
+	q := New(StrategyRandom, candidatesOf(ids...), time.Now())
+
+	pops := 0
+	found := make(map[int]bool)
+	for {
+		id, ok := q.Pop()
+		if !ok {
+			break
+		}
+
+		pops++
+		if processed[id] {
+			continue
+		}
+
+		if found[id] {
+			t.Fatalf("Pop() returned unprocessed %d twice", id)
+		}
+
+		found[id] = true
+	}
+
+	if pops != size {
+		t.Fatalf("popped %d candidates, want each of the %d once", pops, size)
+	}
+
+	if len(found) != size/500 {
+		t.Fatalf("found %d unprocessed entries, want %d", len(found), size/500)
+	}
+}
+
+func TestRandomNoRepeats(t *testing.T) {
+	for _, size := range []int{1, 2, 3, 10, 257} {
+		ids := make([]int, 0, size)
+		for i := 0; i < size; i++ {
+			ids = append(ids, i*3+1)
+		}
+
+		for round := 0; round < 50; round++ {
+			drawn := drain(New(StrategyRandom, candidatesOf(ids...), time.Now()))
+			if len(drawn) != size {
+				t.Fatalf("size %d: drew %d IDs", size, len(drawn))
+			}
+
+			seen := make(map[int]bool, size)
+			for _, id := range drawn {
+				if seen[id] {
+					t.Fatalf("size %d: %d drawn twice", size, id)
+				}
+
+				seen[id] = true
+			}
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package ratelimit
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+type lockoutEntry struct {
+	failures    int
+	first       time.Time
+	lockedUntil time.Time
+}
+
+// Lockout locks a key out for duration once it failed max times within duration. A max below 1 never locks.
+type Lockout struct {
+	max      int
+	duration time.Duration
+
+	mu        sync.Mutex
+	entries   map[string]*lockoutEntry
+	lastSweep time.Time
+}
+
+func NewLockout(max int, duration time.Duration) *Lockout {
+	return &Lockout{
+		max:       max,
+		duration:  duration,
+		entries:   make(map[string]*lockoutEntry),
+		lastSweep: time.Now(),
+	}
+}
+
+// Locked tells whether key is locked out and for how much longer.
+func (l *Lockout) Locked(key string) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	e, ok := l.entries[key]
+	if !ok {
+		return false, 0
+	}
+
+	if left := time.Until(e.lockedUntil); left > 0 {
+		return true, left
+	}
+
+	return false, 0
+}
+
+// Fail counts a failure of key and returns true when it locked the key out.
+func (l *Lockout) Fail(key string) bool {
+	if l.max < 1 {
+		return false
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	l.sweep(now)
+
+	e, ok := l.entries[key]
+	if !ok || now.Sub(e.first) > l.duration {
+		e = &lockoutEntry{first: now}
+		l.entries[key] = e
+	}
+
+	e.failures++
+	if e.failures < l.max {
+		return false
+	}
+
+	e.failures = 0
+	e.first = now
+	e.lockedUntil = now.Add(l.duration)
+
+	return true
+}
+
+// Reset forgets the failures of key, a lockout in place stays.
+func (l *Lockout) Reset(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if e, ok := l.entries[key]; ok && !time.Now().Before(e.lockedUntil) {
+		delete(l.entries, key)
+	}
+}
+
+// LockedKeys returns the keys which are locked out right now, sorted.
+func (l *Lockout) LockedKeys() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	keys := make([]string, 0)
+
+	for key, e := range l.entries {
+		if now.Before(e.lockedUntil) {
+			keys = append(keys, key)
+		}
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
+
+// sweep forgets the keys whose failures and lockout are over, they behave exactly like new ones.
+func (l *Lockout) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < sweepInterval {
+		return
+	}
+
+	l.lastSweep = now
+
+	for key, e := range l.entries {
+		if now.Sub(e.first) > l.duration && !now.Before(e.lockedUntil) {
+			delete(l.entries, key)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package ratelimit
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestLockout(t *testing.T) {
+	l := NewLockout(3, time.Hour)
+
+	for i := 0; i < 2; i++ {
+		if l.Fail("ip:1.2.3.4") {
+			t.Fatalf("Fail() locked out after %d failures", i+1)
+		}
+	}
+
+	if locked, _ := l.Locked("ip:1.2.3.4"); locked {
+		t.Fatal("Locked() before the third failure")
+	}
+
+	if !l.Fail("ip:1.2.3.4") {
+		t.Fatal("Fail() didn't lock out after the third failure")
+	}
+
+	locked, left := l.Locked("ip:1.2.3.4")
+	if !locked || left <= 59*time.Minute || left > time.Hour {
+		t.Fatalf("Locked() = %t, %s", locked, left)
+	}
+
+	// A lockout in place outlives a reset, other keys aren't affected.
+	l.Reset("ip:1.2.3.4")
+	if locked, _ := l.Locked("ip:1.2.3.4"); !locked {
+		t.Error("Reset() lifted the lockout")
+	}
+
+	if locked, _ := l.Locked("ip:5.6.7.8"); locked {
+		t.Error("Locked() of another key")
+	}
+
+	if keys := l.LockedKeys(); !reflect.DeepEqual(keys, []string{"ip:1.2.3.4"}) {
+		t.Errorf("LockedKeys() = %v", keys)
+	}
+}
+
+func TestLockoutReset(t *testing.T) {
+	l := NewLockout(2, time.Hour)
+
+	l.Fail("key:1")
+	l.Reset("key:1")
+
+	if l.Fail("key:1") {
+		t.Error("Fail() counted a failure from before the reset")
+	}
+}
+
+func TestLockoutExpiry(t *testing.T) {
+	l := NewLockout(1, 20*time.Millisecond)
+
+	if !l.Fail("key:1") {
+		t.Fatal("Fail() didn't lock out")
+	}
+
+	time.Sleep(30 * time.Millisecond)
+
+	if locked, _ := l.Locked("key:1"); locked {
+		t.Error("Locked() after the lockout ran out")
+	}
+
+	if len(l.LockedKeys()) != 0 {
+		t.Errorf("LockedKeys() = %v after the lockout ran out", l.LockedKeys())
+	}
+}
+
+func TestLockoutDisabled(t *testing.T) {
+	l := NewLockout(0, time.Hour)
+
+	for i := 0; i < 10; i++ {
+		if l.Fail("key:1") {
+			t.Fatal("Fail() locked out with max 0")
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package redact
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/YusufOzmen01/veri-kontrol-backend/core/textfilter"
+)
+
+// Redacted stands in for a name which was left out.
+const Redacted = "[redacted]"
+
+// handlePattern matches Twitter handles, they name the person as much as their real name does. The
+// character before the @ is checked separately, emails aren't handles.
+var handlePattern = regexp.MustCompile(`@[A-Za-z0-9_]{1,15}`)
+
+// Redactor masks the personal data of exported fields. Free text keeps its shape with the phone numbers, ID
+// numbers and handles starred out, names are replaced as a whole. With ShowPII everything is left as it is.
+type Redactor struct {
+	ShowPII bool
+}
+
+// Text masks the personal data in addresses and tweets.
+func (r Redactor) Text(text string) string {
+	if r.ShowPII || text == "" {
+		return text
+	}
+
+	text = textfilter.MaskPII(text)
+
+	found := handlePattern.FindAllStringIndex(text, -1)
+	if len(found) == 0 {
+		return text
+	}
+
+	var b strings.Builder
+
+	last := 0
+	for _, bounds := range found {
+		start, end := bounds[0], bounds[1]
+		if start > 0 && isHandleChar(text[start-1]) {
+			continue
+		}
+
+		b.WriteString(text[last : start+1])
+		b.WriteString(strings.Repeat("*", end-start-1))
+		last = end
+	}
+
+	b.WriteString(text[last:])
+
+	return b.String()
+}
+
+// Name replaces a person's name, an empty name stays empty so it still reads as missing.
+func (r Redactor) Name(name string) string {
+	if r.ShowPII || name == "" {
+		return name
+	}
+
+	return Redacted
+}
+
+func isHandleChar(b byte) bool {
+	return b == '_' || (b >= '0' && b <= '9') || (b >= 'a' && b <= 'z') || (b >= 'A' && b <= 'Z')
+}
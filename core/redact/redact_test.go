@@ -0,0 +1,65 @@
+package redact
+
+import "testing"
+
+func TestRedactorText(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{"empty", "", ""},
+		{"mobile", "Ulaşın 05321234567", "Ulaşın ***********"},
+		{"mobile with spaces", "tel: 0532 123 45 67 acil", "tel: **** *** ** ** acil"},
+		{"mobile with country code", "+90 532 123 4567", "+** *** *** ****"},
+		{"mobile with parentheses", "(532) 123-45-67", "(***) ***-**-**"},
+		{"id number", "TC 10000000146 enkaz altında", "TC *********** enkaz altında"},
+		{"handle", "@ahbap_org yardım", "@********* yardım"},
+		{"handle in text", "bilgi için @afad_1 ve @kizilay", "bilgi için @****** ve @*******"},
+		{"all of them", "@yardim 05551112233 TC 10000000146", "@****** *********** TC ***********"},
+
+		{"coordinates", "konum 37.5753, 36.9228", "konum 37.5753, 36.9228"},
+		{"coordinates without space", "36.202100,36.160000", "36.202100,36.160000"},
+		{"entry id", "entry 104522 çözüldü", "entry 104522 çözüldü"},
+		{"date", "06.02.2023 04:17 tarihinde", "06.02.2023 04:17 tarihinde"},
+		{"iso date", "2023-02-06T04:17:00Z", "2023-02-06T04:17:00Z"},
+		{"eleven digits failing the checksum", "ref 12345678901", "ref 12345678901"},
+		{"landline", "0342 123 45 67", "0342 123 45 67"},
+		{"email", "ali@example.com", "ali@example.com"},
+		{"address", "Kurtuluş Mah. 12. Sokak No: 5 Antakya", "Kurtuluş Mah. 12. Sokak No: 5 Antakya"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := (Redactor{}).Text(tt.text); got != tt.want {
+				t.Errorf("Text(%q) = %q, want %q", tt.text, got, tt.want)
+			}
+
+			if got := (Redactor{ShowPII: true}).Text(tt.text); got != tt.text {
+				t.Errorf("Text(%q) with ShowPII = %q, want it unchanged", tt.text, got)
+			}
+		})
+	}
+}
+
+func TestRedactorName(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		showPII bool
+		want    string
+	}{
+		{"name", "Ayşe Yılmaz", false, Redacted},
+		{"single name", "Mehmet", false, Redacted},
+		{"empty", "", false, ""},
+		{"shown", "Ayşe Yılmaz", true, "Ayşe Yılmaz"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := (Redactor{ShowPII: tt.showPII}).Name(tt.input); got != tt.want {
+				t.Errorf("Name(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,178 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule is a cron expression with the usual five fields: minute, hour, day of month, month and day of week.
+// Every field takes *, numbers, ranges like 1-5, steps like */15 or 0-30/10 and comma separated lists of them.
+type Schedule struct {
+	minutes  []bool
+	hours    []bool
+	days     []bool
+	months   []bool
+	weekdays []bool
+
+	// Like cron, when both are restricted a day matches if either the day of month or the day of week does.
+	anyDay     bool
+	anyWeekday bool
+}
+
+type field struct {
+	name     string
+	min, max int
+}
+
+var fields = []field{
+	{"minute", 0, 59},
+	{"hour", 0, 23},
+	{"day of month", 1, 31},
+	{"month", 1, 12},
+	{"day of week", 0, 6},
+}
+
+func parseField(f field, spec string) ([]bool, error) {
+	set := make([]bool, f.max+1)
+
+	for _, part := range strings.Split(spec, ",") {
+		step := 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			n, err := strconv.Atoi(part[i+1:])
+			if err != nil || n <= 0 {
+				return nil, fmt.Errorf("%s has an invalid step in %q", f.name, part)
+			}
+
+			step = n
+			part = part[:i]
+		}
+
+		from, to := f.min, f.max
+
+		switch {
+		case part == "*":
+		case strings.Contains(part, "-"):
+			bounds := strings.SplitN(part, "-", 2)
+
+			var err error
+			if from, err = strconv.Atoi(bounds[0]); err != nil {
+				return nil, fmt.Errorf("%s has an invalid range %q", f.name, part)
+			}
+
+			if to, err = strconv.Atoi(bounds[1]); err != nil {
+				return nil, fmt.Errorf("%s has an invalid range %q", f.name, part)
+			}
+		default:
+			n, err := strconv.Atoi(part)
+			if err != nil {
+				return nil, fmt.Errorf("%s has an invalid value %q", f.name, part)
+			}
+
+			from, to = n, n
+			if step > 1 {
+				to = f.max
+			}
+		}
+
+		if from < f.min || to > f.max || from > to {
+			return nil, fmt.Errorf("%s must be between %d and %d", f.name, f.min, f.max)
+		}
+
+		for i := from; i <= to; i += step {
+			set[i] = true
+		}
+	}
+
+	return set, nil
+}
+
+// Parse reads a five field cron expression, for example "0 * * * *" for every hour.
+func Parse(spec string) (*Schedule, error) {
+	parts := strings.Fields(spec)
+	if len(parts) != len(fields) {
+		return nil, fmt.Errorf("schedule %q must have %d fields", spec, len(fields))
+	}
+
+	sets := make([][]bool, len(fields))
+	for i, f := range fields {
+		set, err := parseField(f, parts[i])
+		if err != nil {
+			return nil, err
+		}
+
+		sets[i] = set
+	}
+
+	return &Schedule{
+		minutes:    sets[0],
+		hours:      sets[1],
+		days:       sets[2],
+		months:     sets[3],
+		weekdays:   sets[4],
+		anyDay:     parts[2] == "*",
+		anyWeekday: parts[4] == "*",
+	}, nil
+}
+
+func (s *Schedule) dayMatches(t time.Time) bool {
+	day, weekday := s.days[t.Day()], s.weekdays[int(t.Weekday())]
+
+	switch {
+	case s.anyDay && s.anyWeekday:
+		return true
+	case s.anyDay:
+		return weekday
+	case s.anyWeekday:
+		return day
+	default:
+		return day || weekday
+	}
+}
+
+// maxSearch bounds Next, a schedule like "0 0 31 2 *" never matches and would otherwise search forever.
+const maxSearch = 5 * 366 * 24 * time.Hour
+
+// Next is the first matching minute after t, or the zero time when the schedule never matches.
+func (s *Schedule) Next(t time.Time) time.Time {
+	next := t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.Add(maxSearch)
+
+	for next.Before(limit) {
+		switch {
+		case !s.months[int(next.Month())]:
+			next = time.Date(next.Year(), next.Month()+1, 1, 0, 0, 0, 0, next.Location())
+		case !s.dayMatches(next):
+			next = time.Date(next.Year(), next.Month(), next.Day()+1, 0, 0, 0, 0, next.Location())
+		case !s.hours[next.Hour()]:
+			next = time.Date(next.Year(), next.Month(), next.Day(), next.Hour()+1, 0, 0, 0, next.Location())
+		case !s.minutes[next.Minute()]:
+			next = next.Add(time.Minute)
+		default:
+			return next
+		}
+	}
+
+	return time.Time{}
+}
+
+// Run calls job at every matching minute until ctx is cancelled. Runs don't overlap, a job which takes longer
+// than the gap skips the times it missed.
+func Run(ctx context.Context, schedule *Schedule, job func(ctx context.Context)) {
+	for {
+		next := schedule.Next(time.Now())
+		if next.IsZero() {
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(time.Until(next)):
+		}
+
+		job(ctx)
+	}
+}
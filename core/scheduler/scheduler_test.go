@@ -0,0 +1,67 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseErrors(t *testing.T) {
+	for _, spec := range []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 7",
+		"*/0 * * * *",
+		"*/x * * * *",
+		"5-1 * * * *",
+		"a-5 * * * *",
+		"1,,2 * * * *",
+		"one * * * *",
+	} {
+		if _, err := Parse(spec); err == nil {
+			t.Errorf("Parse(%q) succeeded", spec)
+		}
+	}
+}
+
+func TestNext(t *testing.T) {
+	// A Wednesday.
+	from := time.Date(2023, 2, 15, 10, 17, 30, 0, time.UTC)
+
+	tests := []struct {
+		spec string
+		want time.Time
+	}{
+		{"* * * * *", time.Date(2023, 2, 15, 10, 18, 0, 0, time.UTC)},
+		{"0 * * * *", time.Date(2023, 2, 15, 11, 0, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2023, 2, 15, 10, 30, 0, 0, time.UTC)},
+		{"5/20 * * * *", time.Date(2023, 2, 15, 10, 25, 0, 0, time.UTC)},
+		{"0-30/10 11 * * *", time.Date(2023, 2, 15, 11, 0, 0, 0, time.UTC)},
+		{"17 10 * * *", time.Date(2023, 2, 16, 10, 17, 0, 0, time.UTC)},
+		{"0 3,9 * * *", time.Date(2023, 2, 16, 3, 0, 0, 0, time.UTC)},
+		{"0 0 1 * *", time.Date(2023, 3, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 * 6 *", time.Date(2023, 6, 1, 0, 0, 0, 0, time.UTC)},
+		// Sunday.
+		{"0 8 * * 0", time.Date(2023, 2, 19, 8, 0, 0, 0, time.UTC)},
+		{"0 8 * * 1-5", time.Date(2023, 2, 16, 8, 0, 0, 0, time.UTC)},
+		// Like cron, either the day of month or the day of week: the 20th comes after Friday the 17th.
+		{"0 0 20 * 5", time.Date(2023, 2, 17, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{"0 0 31 2 *", time.Time{}},
+	}
+
+	for _, tt := range tests {
+		schedule, err := Parse(tt.spec)
+		if err != nil {
+			t.Fatalf("Parse(%q): %s", tt.spec, err)
+		}
+
+		if got := schedule.Next(from); !got.Equal(tt.want) {
+			t.Errorf("%q: Next() = %s, want %s", tt.spec, got, tt.want)
+		}
+	}
+}
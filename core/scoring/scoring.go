@@ -0,0 +1,83 @@
+package scoring
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// DefaultWeights are phrases which mean someone is still trapped, they are worth serving before the rest.
+const DefaultWeights = "ses geliyor:10,enkaz altında:8,göçük altında:8,yardım bekliyor:5,yaralı:4,acil:3"
+
+type keyword struct {
+	phrase string
+	weight float64
+}
+
+// Scorer rates how urgent a report sounds from the phrases in its text.
+type Scorer struct {
+	keywords []keyword
+}
+
+// fold lowercases with the Turkish rules and drops the Turkish letters, reports are often typed on keyboards
+// without them, so "enkaz altinda" has to match "enkaz altında".
+var fold = strings.NewReplacer("ı", "i", "ş", "s", "ğ", "g", "ü", "u", "ö", "o", "ç", "c", "â", "a", "î", "i", "û", "u")
+
+func normalize(text string) string {
+	words := strings.FieldsFunc(strings.ToLowerSpecial(unicode.TurkishCase, text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+
+	return " " + fold.Replace(strings.Join(words, " ")) + " "
+}
+
+// Parse reads "phrase:weight" pairs separated by commas, for example "ses geliyor:10,acil:3".
+func Parse(spec string) (*Scorer, error) {
+	s := &Scorer{}
+
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		i := strings.LastIndex(entry, ":")
+		if i < 0 {
+			return nil, fmt.Errorf("keyword %q must be phrase:weight", entry)
+		}
+
+		weight, err := strconv.ParseFloat(strings.TrimSpace(entry[i+1:]), 64)
+		if err != nil || weight <= 0 {
+			return nil, fmt.Errorf("keyword %q must have a positive weight", entry)
+		}
+
+		phrase := normalize(entry[:i])
+		if strings.TrimSpace(phrase) == "" {
+			return nil, fmt.Errorf("keyword %q has no phrase", entry)
+		}
+
+		s.keywords = append(s.keywords, keyword{phrase: phrase, weight: weight})
+	}
+
+	return s, nil
+}
+
+// Score is the sum of the weights of the phrases the text contains, each counted once and only as whole
+// words. A report repeating "acil" ten times isn't ten times as urgent.
+func (s *Scorer) Score(text string) float64 {
+	if s == nil || text == "" {
+		return 0
+	}
+
+	normalized := normalize(text)
+
+	score := 0.0
+	for _, k := range s.keywords {
+		if strings.Contains(normalized, k.phrase) {
+			score += k.weight
+		}
+	}
+
+	return score
+}
@@ -0,0 +1,63 @@
+package scoring
+
+import "testing"
+
+func TestParse(t *testing.T) {
+	for _, spec := range []string{
+		"acil",
+		"acil:0",
+		"acil:-1",
+		"acil:x",
+		":5",
+		"!!:5",
+	} {
+		if _, err := Parse(spec); err == nil {
+			t.Errorf("Parse(%q) succeeded", spec)
+		}
+	}
+
+	scorer, err := Parse(" ses geliyor:10, ,acil:2.5 ")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(scorer.keywords) != 2 {
+		t.Fatalf("Parse() read %d keywords, want 2", len(scorer.keywords))
+	}
+}
+
+func TestScore(t *testing.T) {
+	scorer, err := Parse(DefaultWeights)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		text string
+		want float64
+	}{
+		{"", 0},
+		{"Su ve battaniye lazım", 0},
+		{"ENKAZ ALTINDA ses geliyor!!", 18},
+		// Keyboards without the Turkish letters.
+		{"enkaz altinda kalanlar var", 8},
+		{"Göçük altında, yaralı var", 12},
+		// Each phrase counts once.
+		{"acil acil ACİL", 3},
+		// Only whole words.
+		{"acilen gelin", 0},
+		{"sesgeliyor", 0},
+		{"enkaz\naltında", 8},
+	}
+
+	for _, tt := range tests {
+		if got := scorer.Score(tt.text); got != tt.want {
+			t.Errorf("Score(%q) = %v, want %v", tt.text, got, tt.want)
+		}
+	}
+
+	var none *Scorer
+	if got := none.Score("acil"); got != 0 {
+		t.Errorf("nil Scorer scored %v", got)
+	}
+}
@@ -1,8 +1,11 @@
 package sources
 
 import (
+	"context"
+	"fmt"
 	"time"
 
+	"github.com/YusufOzmen01/veri-kontrol-backend/core/metrics"
 	"github.com/dgraph-io/ristretto"
 )
 
@@ -14,6 +17,8 @@ type (
 		Del(key interface{})
 		Clear()
 		Wait()
+		Ping(ctx context.Context) error
+		Close() error
 	}
 
 	cache struct {
@@ -35,7 +40,10 @@ func NewCache(maxCost, numCounters, bufferItems int64) Cache {
 }
 
 func (c *cache) Get(key interface{}) (interface{}, bool) {
-	return c.c.Get(key)
+	value, exists := c.c.Get(key)
+	metrics.ObserveCache(exists)
+
+	return value, exists
 }
 
 func (c *cache) Set(key, value interface{}, cost int64) bool {
@@ -61,3 +69,17 @@ func (c *cache) Clear() {
 func (c *cache) Wait() {
 	c.c.Wait()
 }
+
+func (c *cache) Ping(ctx context.Context) error {
+	if c.c == nil {
+		return fmt.Errorf("cache is not initialized")
+	}
+
+	return nil
+}
+
+func (c *cache) Close() error {
+	c.c.Close()
+
+	return nil
+}
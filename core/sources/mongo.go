@@ -2,7 +2,13 @@ package sources
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"sync"
+	"time"
+
+	"github.com/YusufOzmen01/veri-kontrol-backend/core/metrics"
+	"github.com/YusufOzmen01/veri-kontrol-backend/core/tracing"
 
 	log "github.com/sirupsen/logrus"
 	"go.mongodb.org/mongo-driver/bson"
@@ -10,6 +16,12 @@ import (
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
+// Mongo error codes of indexes and collections which don't exist.
+const (
+	namespaceNotFound = 26
+	indexNotFound     = 27
+)
+
 type (
 	MongoClient interface {
 		Aggregate(ctx context.Context, table string, pipeline interface{}, opts ...*options.AggregateOptions) (*mongo.Cursor, error)
@@ -19,21 +31,39 @@ type (
 		InsertMany(ctx context.Context, table string, documents []interface{}, opts ...*options.InsertManyOptions) error
 		Find(ctx context.Context, table string, filter interface{}, opts ...*options.FindOptions) (cur *mongo.Cursor, err error)
 		FindOne(ctx context.Context, table string, filter interface{}, opts ...*options.FindOneOptions) *mongo.SingleResult
+		FindOneAndUpdate(ctx context.Context, table string, filter interface{}, update interface{}, opts ...*options.FindOneAndUpdateOptions) *mongo.SingleResult
+		FindOneAndDelete(ctx context.Context, table string, filter interface{}, opts ...*options.FindOneAndDeleteOptions) *mongo.SingleResult
 		DeleteOne(ctx context.Context, table string, filter interface{}, opts ...*options.DeleteOptions) error
 		DeleteMany(ctx context.Context, table string, filter interface{}, opts ...*options.DeleteOptions) error
 		UpdateOne(ctx context.Context, table string, filter interface{}, update interface{}, opts ...*options.UpdateOptions) error
+		ReplaceOne(ctx context.Context, table string, filter interface{}, replacement interface{}, opts ...*options.ReplaceOptions) error
+		UpdateMany(ctx context.Context, table string, filter interface{}, update interface{}, opts ...*options.UpdateOptions) error
 		DoesExist(ctx context.Context, table string, filter bson.D, opts ...*options.FindOneOptions) (bool, error)
 		CreateIndex(ctx context.Context, table string, keys ...bson.E) (string, error)
+		CreateIndexWithOptions(ctx context.Context, table string, opts *options.IndexOptions, keys ...bson.E) (string, error)
+		DropIndex(ctx context.Context, table string, name string) error
+		DropIndexes(ctx context.Context, table string) error
+		CollectionNames(ctx context.Context) ([]string, error)
 		Count(ctx context.Context, table string, filter interface{}, opts ...*options.CountOptions) (int64, error)
 		Disconnect(ctx context.Context) error
+		Ping(ctx context.Context) error
 		WithSession() (MongoClient, error)
 		WithTransaction(ctx context.Context, callback func(sessCtx mongo.SessionContext) (interface{}, error)) (interface{}, error)
+		SupportsTransactions(ctx context.Context) bool
+		Transaction(ctx context.Context, fn func(ctx context.Context) error) error
 	}
 
 	mongoClient struct {
 		cl      *mongo.Client
 		db      *mongo.Database
 		session mongo.Session
+		tx      *transactionSupport
+	}
+
+	// transactionSupport is looked up once and shared by the session copies of a client.
+	transactionSupport struct {
+		once      sync.Once
+		supported bool
 	}
 )
 
@@ -41,16 +71,15 @@ func NewMongoClient(ctx context.Context, uri, dbName string) MongoClient {
 	opts := options.Client()
 	opts.ApplyURI(uri)
 	opts.SetMaxPoolSize(5)
+	opts.SetMonitor(tracing.MongoMonitor())
 
 	client, err := mongo.Connect(ctx, opts)
 	if err != nil {
-		log.Fatal("Error while connecting to MongoClient", err)
-		panic(err)
+		log.Fatalf("Error while connecting to MongoClient: %s", err)
 	}
 
 	if err := client.Ping(ctx, nil); err != nil {
-		log.Fatal("Error while pinging MongoClient", err)
-		panic(err)
+		log.Fatalf("Error while pinging MongoClient: %s", err)
 	}
 
 	db := client.Database(dbName)
@@ -58,6 +87,7 @@ func NewMongoClient(ctx context.Context, uri, dbName string) MongoClient {
 	return &mongoClient{
 		db: db,
 		cl: client,
+		tx: &transactionSupport{},
 	}
 }
 
@@ -71,6 +101,7 @@ func (mc *mongoClient) WithSession() (MongoClient, error) {
 		db:      mc.db,
 		cl:      mc.cl,
 		session: session,
+		tx:      mc.tx,
 	}, nil
 }
 
@@ -85,23 +116,74 @@ func (mc *mongoClient) WithTransaction(
 	return mc.session.WithTransaction(ctx, callback)
 }
 
+// SupportsTransactions is true on replica set members and mongos, a standalone server rejects transactions.
+// A failed lookup counts as unsupported and isn't retried.
+func (mc *mongoClient) SupportsTransactions(ctx context.Context) bool {
+	mc.tx.once.Do(func() {
+		var hello struct {
+			SetName string `bson:"setName"`
+			Msg     string `bson:"msg"`
+		}
+
+		if err := mc.db.RunCommand(ctx, bson.D{{Key: "hello", Value: 1}}).Decode(&hello); err != nil {
+			log.Errorf("Couldn't check for transaction support, writes won't be transactional: %s", err)
+
+			return
+		}
+
+		mc.tx.supported = hello.SetName != "" || hello.Msg == "isdbgrid"
+		if !mc.tx.supported {
+			log.Infoln("MongoDB isn't a replica set, writes won't be transactional")
+		}
+	})
+
+	return mc.tx.supported
+}
+
+// Transaction runs fn in a transaction, every operation has to use the ctx fn gets to take part in it. Without
+// transaction support fn runs on its own and whatever it wrote before failing stays written. fn may be called
+// more than once when the transaction hits a transient error.
+func (mc *mongoClient) Transaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	if !mc.SupportsTransactions(ctx) {
+		return fn(ctx)
+	}
+
+	session, err := mc.cl.StartSession()
+	if err != nil {
+		return err
+	}
+	defer session.EndSession(ctx)
+
+	_, err = session.WithTransaction(ctx, func(sessCtx mongo.SessionContext) (interface{}, error) {
+		return nil, fn(sessCtx)
+	})
+
+	return err
+}
+
 func (mc *mongoClient) getCollection(table string) *mongo.Collection {
 	return mc.db.Collection(table)
 }
 
 func (mc *mongoClient) Aggregate(ctx context.Context, table string, pipeline interface{}, opts ...*options.AggregateOptions) (*mongo.Cursor, error) {
+	defer metrics.ObserveMongo("aggregate", table, time.Now())
+
 	coll := mc.getCollection(table)
 
 	return coll.Aggregate(ctx, pipeline, opts...)
 }
 
 func (mc *mongoClient) Count(ctx context.Context, table string, filter interface{}, opts ...*options.CountOptions) (int64, error) {
+	defer metrics.ObserveMongo("count", table, time.Now())
+
 	coll := mc.getCollection(table)
 
 	return coll.CountDocuments(ctx, filter, opts...)
 }
 
 func (mc *mongoClient) UpsertOne(ctx context.Context, table string, filter interface{}, update interface{}) error {
+	defer metrics.ObserveMongo("upsert_one", table, time.Now())
+
 	coll := mc.getCollection(table)
 
 	_, err := coll.UpdateOne(ctx, filter, update, options.Update().SetUpsert(true))
@@ -113,6 +195,8 @@ func (mc *mongoClient) UpsertOne(ctx context.Context, table string, filter inter
 }
 
 func (mc *mongoClient) UpsertMany(ctx context.Context, table string, filter interface{}, update interface{}) error {
+	defer metrics.ObserveMongo("upsert_many", table, time.Now())
+
 	coll := mc.getCollection(table)
 
 	_, err := coll.UpdateMany(ctx, filter, update, options.Update().SetUpsert(true))
@@ -127,7 +211,44 @@ func (mc *mongoClient) Disconnect(ctx context.Context) error {
 	return mc.cl.Disconnect(ctx)
 }
 
+func (mc *mongoClient) Ping(ctx context.Context) error {
+	return mc.cl.Ping(ctx, nil)
+}
+
+// FindOneAndUpdate updates a document and returns it in a single step, by default as it was before the update.
+func (mc *mongoClient) FindOneAndUpdate(ctx context.Context, table string, filter interface{}, update interface{}, opts ...*options.FindOneAndUpdateOptions) *mongo.SingleResult {
+	defer metrics.ObserveMongo("find_one_and_update", table, time.Now())
+
+	coll := mc.getCollection(table)
+
+	return coll.FindOneAndUpdate(ctx, filter, update, opts...)
+}
+
+// FindOneAndDelete deletes a document and returns it in a single step.
+func (mc *mongoClient) FindOneAndDelete(ctx context.Context, table string, filter interface{}, opts ...*options.FindOneAndDeleteOptions) *mongo.SingleResult {
+	defer metrics.ObserveMongo("find_one_and_delete", table, time.Now())
+
+	coll := mc.getCollection(table)
+
+	return coll.FindOneAndDelete(ctx, filter, opts...)
+}
+
+func (mc *mongoClient) ReplaceOne(ctx context.Context, table string, filter interface{}, replacement interface{}, opts ...*options.ReplaceOptions) error {
+	defer metrics.ObserveMongo("replace_one", table, time.Now())
+
+	coll := mc.getCollection(table)
+
+	_, err := coll.ReplaceOne(ctx, filter, replacement, opts...)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
 func (mc *mongoClient) UpdateOne(ctx context.Context, table string, filter interface{}, update interface{}, opts ...*options.UpdateOptions) error {
+	defer metrics.ObserveMongo("update_one", table, time.Now())
+
 	coll := mc.getCollection(table)
 
 	_, err := coll.UpdateOne(ctx, filter, update, opts...)
@@ -135,7 +256,19 @@ func (mc *mongoClient) UpdateOne(ctx context.Context, table string, filter inter
 	return err
 }
 
+func (mc *mongoClient) UpdateMany(ctx context.Context, table string, filter interface{}, update interface{}, opts ...*options.UpdateOptions) error {
+	defer metrics.ObserveMongo("update_many", table, time.Now())
+
+	coll := mc.getCollection(table)
+
+	_, err := coll.UpdateMany(ctx, filter, update, opts...)
+
+	return err
+}
+
 func (mc *mongoClient) InsertOne(ctx context.Context, table string, document interface{}, opts ...*options.InsertOneOptions) error {
+	defer metrics.ObserveMongo("insert_one", table, time.Now())
+
 	coll := mc.getCollection(table)
 
 	_, err := coll.InsertOne(ctx, document, opts...)
@@ -144,6 +277,8 @@ func (mc *mongoClient) InsertOne(ctx context.Context, table string, document int
 }
 
 func (mc *mongoClient) InsertMany(ctx context.Context, table string, documents []interface{}, opts ...*options.InsertManyOptions) error {
+	defer metrics.ObserveMongo("insert_many", table, time.Now())
+
 	coll := mc.getCollection(table)
 
 	_, err := coll.InsertMany(ctx, documents, opts...)
@@ -152,18 +287,24 @@ func (mc *mongoClient) InsertMany(ctx context.Context, table string, documents [
 }
 
 func (mc *mongoClient) Find(ctx context.Context, table string, filter interface{}, opts ...*options.FindOptions) (cur *mongo.Cursor, err error) {
+	defer metrics.ObserveMongo("find", table, time.Now())
+
 	coll := mc.db.Collection(table)
 
 	return coll.Find(ctx, filter, opts...)
 }
 
 func (mc *mongoClient) FindOne(ctx context.Context, table string, filter interface{}, opts ...*options.FindOneOptions) *mongo.SingleResult {
+	defer metrics.ObserveMongo("find_one", table, time.Now())
+
 	coll := mc.db.Collection(table)
 
 	return coll.FindOne(ctx, filter, opts...)
 }
 
 func (mc *mongoClient) DoesExist(ctx context.Context, table string, filter bson.D, opts ...*options.FindOneOptions) (bool, error) {
+	defer metrics.ObserveMongo("does_exist", table, time.Now())
+
 	result := make(bson.M)
 
 	coll := mc.db.Collection(table)
@@ -181,20 +322,55 @@ func (mc *mongoClient) DoesExist(ctx context.Context, table string, filter bson.
 }
 
 func (mc *mongoClient) CreateIndex(ctx context.Context, table string, keys ...bson.E) (string, error) {
+	return mc.CreateIndexWithOptions(ctx, table, nil, keys...)
+}
+
+func (mc *mongoClient) CreateIndexWithOptions(ctx context.Context, table string, opts *options.IndexOptions, keys ...bson.E) (string, error) {
+	defer metrics.ObserveMongo("create_index", table, time.Now())
+
 	coll := mc.db.Collection(table)
 	indexKeys := make(bson.D, 0)
 	for _, key := range keys {
 		indexKeys = append(indexKeys, key)
 	}
 
-	model := mongo.IndexModel{Keys: indexKeys}
+	model := mongo.IndexModel{Keys: indexKeys, Options: opts}
 
 	index, err := coll.Indexes().CreateOne(ctx, model)
 
 	return index, err
 }
 
+// DropIndex drops the index with the given name, one which doesn't exist isn't an error.
+func (mc *mongoClient) DropIndex(ctx context.Context, table string, name string) error {
+	defer metrics.ObserveMongo("drop_index", table, time.Now())
+
+	_, err := mc.db.Collection(table).Indexes().DropOne(ctx, name)
+
+	var commandErr mongo.CommandError
+	if errors.As(err, &commandErr) && (commandErr.Code == indexNotFound || commandErr.Code == namespaceNotFound) {
+		return nil
+	}
+
+	return err
+}
+
+// DropIndexes drops every index of the collection except the one on _id.
+func (mc *mongoClient) DropIndexes(ctx context.Context, table string) error {
+	defer metrics.ObserveMongo("drop_indexes", table, time.Now())
+
+	_, err := mc.db.Collection(table).Indexes().DropAll(ctx)
+
+	return err
+}
+
+func (mc *mongoClient) CollectionNames(ctx context.Context) ([]string, error) {
+	return mc.db.ListCollectionNames(ctx, bson.D{})
+}
+
 func (mc *mongoClient) DeleteOne(ctx context.Context, table string, filter interface{}, opts ...*options.DeleteOptions) error {
+	defer metrics.ObserveMongo("delete_one", table, time.Now())
+
 	coll := mc.db.Collection(table)
 
 	_, err := coll.DeleteOne(ctx, filter, opts...)
@@ -206,6 +382,8 @@ func (mc *mongoClient) DeleteOne(ctx context.Context, table string, filter inter
 }
 
 func (mc *mongoClient) DeleteMany(ctx context.Context, table string, filter interface{}, opts ...*options.DeleteOptions) error {
+	defer metrics.ObserveMongo("delete_many", table, time.Now())
+
 	coll := mc.db.Collection(table)
 
 	_, err := coll.DeleteMany(ctx, filter, opts...)
@@ -0,0 +1,146 @@
+package sources
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"fmt"
+	"time"
+
+	"github.com/YusufOzmen01/veri-kontrol-backend/core/metrics"
+	"github.com/redis/go-redis/v9"
+	log "github.com/sirupsen/logrus"
+)
+
+const redisTimeout = 5 * time.Second
+
+type redisCache struct {
+	cl     *redis.Client
+	prefix string
+}
+
+// redisEntry wraps the cached value so gob keeps its concrete type. Packages storing values in the cache
+// must gob.Register those types.
+type redisEntry struct {
+	Value interface{}
+}
+
+// NewRedisCache returns a Cache shared between every replica using the same Redis, costs are ignored.
+func NewRedisCache(ctx context.Context, uri, prefix string) Cache {
+	opts, err := redis.ParseURL(uri)
+	if err != nil {
+		log.Fatalf("Error while parsing Redis URL: %s", err)
+	}
+
+	client := redis.NewClient(opts)
+
+	if err := client.Ping(ctx).Err(); err != nil {
+		log.Fatalf("Error while pinging Redis: %s", err)
+	}
+
+	return &redisCache{
+		cl:     client,
+		prefix: prefix,
+	}
+}
+
+func (c *redisCache) key(key interface{}) string {
+	return fmt.Sprintf("%s%v", c.prefix, key)
+}
+
+func (c *redisCache) Get(key interface{}) (interface{}, bool) {
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+
+	data, err := c.cl.Get(ctx, c.key(key)).Bytes()
+	if err != nil {
+		if err != redis.Nil {
+			log.Errorln(err)
+		}
+
+		metrics.ObserveCache(false)
+
+		return nil, false
+	}
+
+	entry := &redisEntry{}
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(entry); err != nil {
+		log.Errorln(err)
+
+		metrics.ObserveCache(false)
+
+		return nil, false
+	}
+
+	metrics.ObserveCache(true)
+
+	return entry.Value, true
+}
+
+func (c *redisCache) Set(key, value interface{}, cost int64) bool {
+	return c.set(key, value, 0)
+}
+
+func (c *redisCache) SetWithTTL(key, value interface{}, cost int64, ttl time.Duration) bool {
+	if ttl == 0 {
+		ttl = 24 * time.Hour
+	}
+
+	return c.set(key, value, ttl)
+}
+
+func (c *redisCache) set(key, value interface{}, ttl time.Duration) bool {
+	buf := &bytes.Buffer{}
+	if err := gob.NewEncoder(buf).Encode(&redisEntry{Value: value}); err != nil {
+		log.Errorln(err)
+
+		return false
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+
+	if err := c.cl.Set(ctx, c.key(key), buf.Bytes(), ttl).Err(); err != nil {
+		log.Errorln(err)
+
+		return false
+	}
+
+	return true
+}
+
+func (c *redisCache) Del(key interface{}) {
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+
+	if err := c.cl.Del(ctx, c.key(key)).Err(); err != nil {
+		log.Errorln(err)
+	}
+}
+
+// Clear only removes the keys under our prefix, the Redis instance may be shared with other applications.
+func (c *redisCache) Clear() {
+	ctx := context.Background()
+
+	iter := c.cl.Scan(ctx, 0, c.prefix+"*", 100).Iterator()
+	for iter.Next(ctx) {
+		if err := c.cl.Del(ctx, iter.Val()).Err(); err != nil {
+			log.Errorln(err)
+		}
+	}
+
+	if err := iter.Err(); err != nil {
+		log.Errorln(err)
+	}
+}
+
+// Wait is a no-op, Redis writes are visible as soon as Set returns.
+func (c *redisCache) Wait() {}
+
+func (c *redisCache) Ping(ctx context.Context) error {
+	return c.cl.Ping(ctx).Err()
+}
+
+func (c *redisCache) Close() error {
+	return c.cl.Close()
+}
@@ -0,0 +1,213 @@
+package sources
+
+import (
+	"context"
+	"crypto/rsa"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/YusufOzmen01/veri-kontrol-backend/core/network"
+	"github.com/golang-jwt/jwt/v4"
+)
+
+const (
+	sheetsAPI   = "https://sheets.googleapis.com/v4/spreadsheets"
+	sheetsScope = "https://www.googleapis.com/auth/spreadsheets"
+)
+
+type (
+	// Sheet is a single tab of a Google spreadsheet.
+	Sheet interface {
+		AppendRows(ctx context.Context, rows [][]string) error
+		GetRows(ctx context.Context) ([][]string, error)
+	}
+
+	sheet struct {
+		email       string
+		key         *rsa.PrivateKey
+		tokenURI    string
+		spreadsheet string
+		name        string
+
+		mu          sync.Mutex
+		token       string
+		tokenExpiry time.Time
+	}
+)
+
+// NewSheet authenticates with the JSON key of a service account, the spreadsheet has to be shared with the
+// account's e-mail address.
+func NewSheet(credentials []byte, spreadsheetID, sheetName string) (Sheet, error) {
+	var c struct {
+		ClientEmail string `json:"client_email"`
+		PrivateKey  string `json:"private_key"`
+		TokenURI    string `json:"token_uri"`
+	}
+
+	if err := json.Unmarshal(credentials, &c); err != nil {
+		return nil, fmt.Errorf("couldn't parse service account credentials: %w", err)
+	}
+
+	if c.ClientEmail == "" || c.PrivateKey == "" {
+		return nil, fmt.Errorf("service account credentials need client_email and private_key")
+	}
+
+	key, err := jwt.ParseRSAPrivateKeyFromPEM([]byte(c.PrivateKey))
+	if err != nil {
+		return nil, fmt.Errorf("couldn't parse service account private key: %w", err)
+	}
+
+	if c.TokenURI == "" {
+		c.TokenURI = "https://oauth2.googleapis.com/token"
+	}
+
+	return &sheet{
+		email:       c.ClientEmail,
+		key:         key,
+		tokenURI:    c.TokenURI,
+		spreadsheet: spreadsheetID,
+		name:        sheetName,
+	}, nil
+}
+
+// accessToken trades a signed assertion for an OAuth token and keeps it until shortly before it expires.
+func (s *sheet) accessToken(ctx context.Context) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.token != "" && time.Now().Before(s.tokenExpiry) {
+		return s.token, nil
+	}
+
+	now := time.Now()
+	assertion, err := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"iss":   s.email,
+		"scope": sheetsScope,
+		"aud":   s.tokenURI,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	}).SignedString(s.key)
+	if err != nil {
+		return "", err
+	}
+
+	form := url.Values{
+		"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"},
+		"assertion":  {assertion},
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.tokenURI, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := network.HC15.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+
+	if resp.StatusCode != 200 {
+		return "", fmt.Errorf("token endpoint returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	var d struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+
+	if err := json.Unmarshal(body, &d); err != nil {
+		return "", err
+	}
+
+	s.token = d.AccessToken
+	s.tokenExpiry = now.Add(time.Duration(d.ExpiresIn)*time.Second - time.Minute)
+
+	return s.token, nil
+}
+
+func (s *sheet) valuesURL(suffix string) string {
+	return fmt.Sprintf("%s/%s/values/%s%s", sheetsAPI, url.PathEscape(s.spreadsheet), url.PathEscape(s.name), suffix)
+}
+
+func sheetsError(status int, res []byte) error {
+	var d struct {
+		Error struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+
+	_ = json.Unmarshal(res, &d)
+
+	return fmt.Errorf("sheets returned status %d: %s", status, d.Error.Message)
+}
+
+// AppendRows adds the rows below the last filled row. The values are written as they are, a leading = or +
+// isn't turned into a formula.
+func (s *sheet) AppendRows(ctx context.Context, rows [][]string) error {
+	token, err := s.accessToken(ctx)
+	if err != nil {
+		return err
+	}
+
+	body, err := json.Marshal(map[string]interface{}{
+		"values": rows,
+	})
+	if err != nil {
+		return err
+	}
+
+	res, status, err := network.ProcessPost(ctx, s.valuesURL(":append?valueInputOption=RAW&insertDataOption=INSERT_ROWS"), body, map[string]string{
+		"Authorization": "Bearer " + token,
+	})
+	if err != nil {
+		return err
+	}
+
+	if status != 200 {
+		return sheetsError(status, res)
+	}
+
+	return nil
+}
+
+// GetRows returns every filled row including the header, trailing empty cells are left out by the API.
+func (s *sheet) GetRows(ctx context.Context) ([][]string, error) {
+	token, err := s.accessToken(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	res, status, err := network.ProcessGet(ctx, s.valuesURL("?valueRenderOption=FORMATTED_VALUE"), map[string]string{
+		"Authorization": "Bearer " + token,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if status != 200 {
+		return nil, sheetsError(status, res)
+	}
+
+	var d struct {
+		Values [][]string `json:"values"`
+	}
+
+	if err := json.Unmarshal(res, &d); err != nil {
+		return nil, err
+	}
+
+	return d.Values, nil
+}
@@ -0,0 +1,54 @@
+package sources
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/YusufOzmen01/veri-kontrol-backend/core/network"
+)
+
+const telegramAPI = "https://api.telegram.org"
+
+type (
+	Telegram interface {
+		SendMessage(ctx context.Context, chatID, text string) error
+	}
+
+	telegram struct {
+		token string
+	}
+)
+
+// NewTelegramClient talks to the Bot API, chatID can be a numeric chat ID or a public @channel name.
+func NewTelegramClient(token string) Telegram {
+	return &telegram{token: token}
+}
+
+func (t *telegram) SendMessage(ctx context.Context, chatID, text string) error {
+	body, err := json.Marshal(map[string]interface{}{
+		"chat_id":                  chatID,
+		"text":                     text,
+		"disable_web_page_preview": true,
+	})
+	if err != nil {
+		return err
+	}
+
+	res, status, err := network.ProcessPost(ctx, fmt.Sprintf("%s/bot%s/sendMessage", telegramAPI, t.token), body, nil)
+	if err != nil {
+		return err
+	}
+
+	if status != 200 {
+		var d struct {
+			Description string `json:"description"`
+		}
+
+		_ = json.Unmarshal(res, &d)
+
+		return fmt.Errorf("telegram returned status %d: %s", status, d.Description)
+	}
+
+	return nil
+}
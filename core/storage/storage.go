@@ -0,0 +1,203 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/YusufOzmen01/veri-kontrol-backend/core/tracing"
+)
+
+// Bucket stores whole objects under a key.
+type Bucket interface {
+	Put(ctx context.Context, key, contentType string, body []byte) error
+}
+
+// Config describes an S3 compatible bucket. Google Cloud Storage works the same way through its
+// interoperability API, with https://storage.googleapis.com as the endpoint, an HMAC key and the region "auto".
+type Config struct {
+	Endpoint  string
+	Bucket    string
+	Region    string
+	AccessKey string
+	SecretKey string
+}
+
+func (c *Config) Validate() error {
+	if u, err := url.Parse(c.Endpoint); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("endpoint must be an http or https URL")
+	}
+
+	if c.Bucket == "" || c.Region == "" || c.AccessKey == "" || c.SecretKey == "" {
+		return fmt.Errorf("bucket, region, access key and secret key are required")
+	}
+
+	return nil
+}
+
+type s3Bucket struct {
+	config Config
+	client *http.Client
+}
+
+// NewS3Bucket signs its requests with AWS signature version 4 and addresses the bucket by path, which every
+// S3 compatible service supports.
+func NewS3Bucket(config Config) Bucket {
+	config.Endpoint = strings.TrimSuffix(config.Endpoint, "/")
+
+	return &s3Bucket{
+		config: config,
+		client: &http.Client{Timeout: 5 * time.Minute, Transport: tracing.Transport(http.DefaultTransport)},
+	}
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+
+	return mac.Sum(nil)
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+
+	return hex.EncodeToString(sum[:])
+}
+
+// uriEncode encodes everything except the unreserved characters, as signature version 4 expects it. url's own
+// escaping leaves characters like $ alone which S3 encodes before it checks the signature.
+func uriEncode(s string) string {
+	var b strings.Builder
+
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' || c == '-' || c == '.' || c == '_' || c == '~' {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+
+	return b.String()
+}
+
+// escapePath encodes every segment the way signature version 4 expects, slashes are kept.
+func escapePath(path string) string {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		segments[i] = uriEncode(segment)
+	}
+
+	return strings.Join(segments, "/")
+}
+
+// canonicalQuery sorts the parameters by name and value and encodes them the way signature version 4 expects.
+func canonicalQuery(u *url.URL) string {
+	query := u.Query()
+
+	names := make([]string, 0, len(query))
+	for name := range query {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	params := make([]string, 0, len(query))
+	for _, name := range names {
+		values := query[name]
+		sort.Strings(values)
+
+		for _, value := range values {
+			params = append(params, uriEncode(name)+"="+uriEncode(value))
+		}
+	}
+
+	return strings.Join(params, "&")
+}
+
+// sign adds the signature version 4 Authorization header, every header which is set on req at this point is
+// signed along with the host.
+func (b *s3Bucket) sign(req *http.Request, payloadHash string, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	values := map[string]string{"host": req.URL.Host}
+	for name, value := range req.Header {
+		if name != "Authorization" {
+			values[strings.ToLower(name)] = strings.TrimSpace(strings.Join(value, ","))
+		}
+	}
+
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	canonicalHeaders := ""
+	for _, name := range names {
+		canonicalHeaders += name + ":" + values[name] + "\n"
+	}
+
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		escapePath(req.URL.Path),
+		canonicalQuery(req.URL),
+		canonicalHeaders,
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := fmt.Sprintf("%s/%s/s3/aws4_request", date, b.config.Region)
+	stringToSign := strings.Join([]string{"AWS4-HMAC-SHA256", amzDate, scope, sha256Hex([]byte(canonicalRequest))}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+b.config.SecretKey), date)
+	key = hmacSHA256(key, b.config.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		b.config.AccessKey, scope, signedHeaders, hex.EncodeToString(hmacSHA256(key, stringToSign))))
+}
+
+func (b *s3Bucket) Put(ctx context.Context, key, contentType string, body []byte) error {
+	u, err := url.Parse(b.config.Endpoint + escapePath("/"+b.config.Bucket+"/"+strings.TrimPrefix(key, "/")))
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", contentType)
+	b.sign(req, sha256Hex(body), time.Now())
+
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+
+		return fmt.Errorf("storage returned %d: %s", resp.StatusCode, strings.TrimSpace(string(message)))
+	}
+
+	return nil
+}
@@ -0,0 +1,140 @@
+package textfilter
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/YusufOzmen01/veri-kontrol-backend/core/phones"
+)
+
+var (
+	wordPattern = regexp.MustCompile(`[\p{L}\p{N}]+`)
+
+	// idPattern finds candidates for Turkish ID numbers, isIDNumber checks them.
+	idPattern = regexp.MustCompile(`[1-9][0-9]{10}`)
+)
+
+// Filter masks the blocked words of texts before volunteers see them. Words are compared in lower case with the
+// Turkish rules, so "I" matches "ı". An entry ending in * blocks every word starting with it, which catches
+// the suffixed forms.
+type Filter struct {
+	words    map[string]bool
+	prefixes []string
+}
+
+func lower(s string) string {
+	return strings.ToLowerSpecial(unicode.TurkishCase, s)
+}
+
+// New builds a filter of the blocklist, empty entries are skipped.
+func New(blocklist []string) *Filter {
+	f := &Filter{words: make(map[string]bool, len(blocklist))}
+
+	for _, word := range blocklist {
+		word = lower(strings.TrimSpace(word))
+
+		if prefix := strings.TrimSuffix(word, "*"); prefix != word {
+			if prefix != "" {
+				f.prefixes = append(f.prefixes, prefix)
+			}
+
+			continue
+		}
+
+		if word != "" {
+			f.words[word] = true
+		}
+	}
+
+	return f
+}
+
+func (f *Filter) blocked(word string) bool {
+	word = lower(word)
+	if f.words[word] {
+		return true
+	}
+
+	for _, prefix := range f.prefixes {
+		if strings.HasPrefix(word, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Apply masks the blocked words, and the phone and ID numbers unless showPII is set.
+func (f *Filter) Apply(text string, showPII bool) string {
+	if len(f.words) > 0 || len(f.prefixes) > 0 {
+		text = wordPattern.ReplaceAllStringFunc(text, func(word string) string {
+			if !f.blocked(word) {
+				return word
+			}
+
+			return strings.Repeat("*", len([]rune(word)))
+		})
+	}
+
+	if !showPII {
+		text = MaskPII(text)
+	}
+
+	return text
+}
+
+// MaskPII replaces the digits of mobile and Turkish ID numbers with asterisks.
+func MaskPII(text string) string {
+	text = phones.Mask(text)
+
+	found := idPattern.FindAllStringIndex(text, -1)
+	if len(found) == 0 {
+		return text
+	}
+
+	masked := []byte(text)
+	for _, bounds := range found {
+		start, end := bounds[0], bounds[1]
+		if (start > 0 && isDigit(text[start-1])) || (end < len(text) && isDigit(text[end])) {
+			continue
+		}
+
+		if !isIDNumber(text[start:end]) {
+			continue
+		}
+
+		for i := start; i < end; i++ {
+			masked[i] = '*'
+		}
+	}
+
+	return string(masked)
+}
+
+func isDigit(b byte) bool {
+	return b >= '0' && b <= '9'
+}
+
+// isIDNumber checks the two check digits of an 11 digit Turkish ID number, most other 11 digit numbers fail
+// them.
+func isIDNumber(number string) bool {
+	digits := make([]int, len(number))
+	for i := range number {
+		digits[i] = int(number[i] - '0')
+	}
+
+	odd := digits[0] + digits[2] + digits[4] + digits[6] + digits[8]
+	even := digits[1] + digits[3] + digits[5] + digits[7]
+
+	if ((odd*7-even)%10+10)%10 != digits[9] {
+		return false
+	}
+
+	sum := 0
+	for _, digit := range digits[:10] {
+		sum += digit
+	}
+
+	return sum%10 == digits[10]
+}
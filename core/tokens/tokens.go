@@ -0,0 +1,88 @@
+package tokens
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+const (
+	AccessTokenTTL  = 15 * time.Minute
+	RefreshTokenTTL = 7 * 24 * time.Hour
+)
+
+type (
+	Manager interface {
+		Issue(userID, name string, permLevel int, scopes []string) (string, time.Time, error)
+		Parse(token string) (*Claims, error)
+	}
+
+	// Claims from tokens issued before there were scopes have nil Scopes.
+	Claims struct {
+		Name      string   `json:"name"`
+		PermLevel int      `json:"perm_level"`
+		Scopes    []string `json:"scopes"`
+		jwt.RegisteredClaims
+	}
+
+	manager struct {
+		secret []byte
+		ttl    time.Duration
+	}
+)
+
+func NewManager(secret string, ttl time.Duration) Manager {
+	if ttl == 0 {
+		ttl = AccessTokenTTL
+	}
+
+	return &manager{
+		secret: []byte(secret),
+		ttl:    ttl,
+	}
+}
+
+func (m *manager) Issue(userID, name string, permLevel int, scopes []string) (string, time.Time, error) {
+	now := time.Now()
+	expiresAt := now.Add(m.ttl)
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, &Claims{
+		Name:      name,
+		PermLevel: permLevel,
+		Scopes:    scopes,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   userID,
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+		},
+	})
+
+	signed, err := token.SignedString(m.secret)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	return signed, expiresAt, nil
+}
+
+func (m *manager) Parse(token string) (*Claims, error) {
+	claims := &Claims{}
+
+	parsed, err := jwt.ParseWithClaims(token, claims, func(t *jwt.Token) (interface{}, error) {
+		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method %v", t.Header["alg"])
+		}
+
+		return m.secret, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if !parsed.Valid {
+		return nil, fmt.Errorf("invalid token")
+	}
+
+	return claims, nil
+}
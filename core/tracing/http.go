@@ -0,0 +1,52 @@
+package tracing
+
+import (
+	"fmt"
+	"net/http"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type transport struct {
+	base http.RoundTripper
+}
+
+// Transport wraps base so every outgoing request gets a client span and carries the trace to the server. Only the
+// host is recorded, paths and queries hold tokens like the Telegram one.
+func Transport(base http.RoundTripper) http.RoundTripper {
+	return &transport{base: base}
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx, span := Start(req.Context(), "HTTP "+req.Method,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.HTTPMethod(req.Method),
+			semconv.HTTPScheme(req.URL.Scheme),
+			semconv.NetPeerName(req.URL.Hostname()),
+		),
+	)
+
+	req = req.Clone(ctx)
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+
+	res, err := t.base.RoundTrip(req)
+	if err != nil {
+		End(span, err)
+
+		return nil, err
+	}
+
+	span.SetAttributes(semconv.HTTPStatusCode(res.StatusCode))
+
+	if res.StatusCode >= 500 {
+		err = fmt.Errorf("status %d", res.StatusCode)
+	}
+
+	End(span, err)
+
+	return res, nil
+}
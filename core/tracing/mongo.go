@@ -0,0 +1,50 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/event"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type commandKey struct {
+	connectionID string
+	requestID    int64
+}
+
+// MongoMonitor turns every command sent to Mongo into a client span under the span of the context it was
+// sent with.
+func MongoMonitor() *event.CommandMonitor {
+	spans := &sync.Map{}
+
+	return &event.CommandMonitor{
+		Started: func(ctx context.Context, evt *event.CommandStartedEvent) {
+			collection, _ := evt.Command.Lookup(evt.CommandName).StringValueOK()
+
+			_, span := Start(ctx, "mongo."+evt.CommandName,
+				trace.WithSpanKind(trace.SpanKindClient),
+				trace.WithAttributes(
+					semconv.DBSystemMongoDB,
+					semconv.DBName(evt.DatabaseName),
+					semconv.DBOperation(evt.CommandName),
+					semconv.DBMongoDBCollection(collection),
+				),
+			)
+
+			spans.Store(commandKey{evt.ConnectionID, evt.RequestID}, span)
+		},
+		Succeeded: func(ctx context.Context, evt *event.CommandSucceededEvent) {
+			if span, ok := spans.LoadAndDelete(commandKey{evt.ConnectionID, evt.RequestID}); ok {
+				span.(trace.Span).End()
+			}
+		},
+		Failed: func(ctx context.Context, evt *event.CommandFailedEvent) {
+			if span, ok := spans.LoadAndDelete(commandKey{evt.ConnectionID, evt.RequestID}); ok {
+				End(span.(trace.Span), errors.New(evt.Failure))
+			}
+		},
+	}
+}
@@ -0,0 +1,121 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const serviceName = "veri-kontrol-backend"
+
+// Config describes where the traces go, tracing is off while Endpoint is empty.
+type Config struct {
+	// Endpoint is the base URL of an OTLP/HTTP collector like http://localhost:4318.
+	Endpoint string
+	// SampleRatio is the share of new traces which are recorded, requests continuing a sampled trace always are.
+	SampleRatio float64
+}
+
+func (c *Config) Validate() error {
+	if u, err := url.Parse(c.Endpoint); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("endpoint must be an http or https URL")
+	}
+
+	if c.SampleRatio < 0 || c.SampleRatio > 1 {
+		return fmt.Errorf("sample ratio must be between 0 and 1")
+	}
+
+	return nil
+}
+
+// Setup exports the spans of the whole process over OTLP/HTTP. The returned function flushes what is left, it
+// belongs to the shutdown.
+func Setup(ctx context.Context, config Config) (func(ctx context.Context) error, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	u, _ := url.Parse(config.Endpoint)
+
+	opts := []otlptracehttp.Option{otlptracehttp.WithEndpoint(u.Host)}
+	if u.Scheme == "http" {
+		opts = append(opts, otlptracehttp.WithInsecure())
+	}
+
+	if u.Path != "" && u.Path != "/" {
+		opts = append(opts, otlptracehttp.WithURLPath(u.Path))
+	}
+
+	exporter, err := otlptracehttp.New(ctx, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(config.SampleRatio))),
+		sdktrace.WithResource(resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceName(serviceName))),
+	)
+
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	return provider.Shutdown, nil
+}
+
+// spanKey is where Inject leaves the span of a request.
+type spanKey struct{}
+
+// UserValueSetter is fasthttp's request context, which is what handlers pass on as their context. It can't be
+// wrapped like a regular context, so the request span is stored in it as a user value.
+type UserValueSetter interface {
+	SetUserValue(key interface{}, value interface{})
+}
+
+// Inject makes span the parent of the spans started from ctx.
+func Inject(ctx UserValueSetter, span trace.Span) {
+	ctx.SetUserValue(spanKey{}, span)
+}
+
+// spanFrom returns the span in ctx, either put there by OpenTelemetry or by Inject.
+func spanFrom(ctx context.Context) trace.Span {
+	if span := trace.SpanFromContext(ctx); span.SpanContext().IsValid() {
+		return span
+	}
+
+	if span, ok := ctx.Value(spanKey{}).(trace.Span); ok {
+		return span
+	}
+
+	return trace.SpanFromContext(ctx)
+}
+
+// Start begins a span under the one in ctx. Without tracing set up the span does nothing.
+func Start(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	return otel.Tracer(serviceName).Start(trace.ContextWithSpan(ctx, spanFrom(ctx)), name, opts...)
+}
+
+// Detach returns a context which is never cancelled but still belongs to the trace of ctx, for work which can
+// outlive the request that started it.
+func Detach(ctx context.Context) context.Context {
+	return trace.ContextWithSpan(context.Background(), spanFrom(ctx))
+}
+
+// End finishes the span and marks it failed when err isn't nil.
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+
+	span.End()
+}
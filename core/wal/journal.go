@@ -0,0 +1,142 @@
+package wal
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// rotatedLayout is the suffix of rotated journal files, they sort in the order they were rotated.
+const rotatedLayout = "20060102T150405"
+
+// Journal is an append-only file of JSON records like Log, but nothing is removed from it until it is rotated
+// away. It is the record of what was accepted, kept to replay from after an outage or a crash lost the writes
+// elsewhere.
+type Journal struct {
+	path string
+
+	mu   sync.Mutex
+	file *os.File
+}
+
+// OpenJournal creates the file if needed, new records are appended to the ones already in it.
+func OpenJournal(path string) (*Journal, error) {
+	file, err := openAppend(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Journal{path: path, file: file}, nil
+}
+
+// Rotate moves the records so far into a file of their own and removes the rotated files older than retention.
+// An empty journal isn't rotated.
+func (j *Journal) Rotate(now time.Time, retention time.Duration) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	info, err := j.file.Stat()
+	if err != nil {
+		return err
+	}
+
+	if info.Size() > 0 {
+		if err := j.file.Close(); err != nil {
+			return err
+		}
+
+		if err := os.Rename(j.path, j.path+"."+now.UTC().Format(rotatedLayout)); err != nil {
+			return err
+		}
+
+		if j.file, err = openAppend(j.path); err != nil {
+			return err
+		}
+	}
+
+	rotated, err := rotatedJournals(j.path)
+	if err != nil {
+		return err
+	}
+
+	for _, file := range rotated {
+		rotatedAt, _ := time.Parse(rotatedLayout, strings.TrimPrefix(file, j.path+"."))
+		if now.Sub(rotatedAt) <= retention {
+			continue
+		}
+
+		if err := os.Remove(file); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Append writes record to the end of the journal, it is on disk when Append returns.
+func (j *Journal) Append(record interface{}) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	return write(j.file, record)
+}
+
+func (j *Journal) Close() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	return j.file.Close()
+}
+
+// rotatedJournals returns the rotated files of the journal at path, the oldest first.
+func rotatedJournals(path string) ([]string, error) {
+	matches, err := filepath.Glob(path + ".*")
+	if err != nil {
+		return nil, err
+	}
+
+	rotated := make([]string, 0, len(matches))
+	for _, match := range matches {
+		if _, err := time.Parse(rotatedLayout, strings.TrimPrefix(match, path+".")); err == nil {
+			rotated = append(rotated, match)
+		}
+	}
+
+	sort.Strings(rotated)
+
+	return rotated, nil
+}
+
+// ReadJournal calls fn with the records of the journal at path and of the files rotated away from it, in the
+// order they were appended. It stops at the first error.
+func ReadJournal(path string, fn func(record json.RawMessage) error) error {
+	files, err := rotatedJournals(path)
+	if err != nil {
+		return err
+	}
+
+	for _, name := range append(files, path) {
+		file, err := os.Open(name)
+		if err != nil {
+			// Everything may have been rotated away since.
+			if os.IsNotExist(err) && name == path && len(files) > 0 {
+				return nil
+			}
+
+			return err
+		}
+
+		err = scan(file, fn)
+		_ = file.Close()
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
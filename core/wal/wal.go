@@ -0,0 +1,277 @@
+package wal
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Log is an append-only file of JSON records, one per line, which survives restarts. Every append is synced
+// to disk before it returns, so a record the caller was told about isn't lost in a crash.
+type Log struct {
+	path string
+
+	mu    sync.Mutex
+	file  *os.File
+	count int
+}
+
+// Open creates the file if needed and counts the records left over from the last run.
+func Open(path string) (*Log, error) {
+	file, err := openAppend(path)
+	if err != nil {
+		return nil, err
+	}
+
+	l := &Log{path: path, file: file}
+
+	records, err := l.read()
+	if err != nil {
+		_ = file.Close()
+
+		return nil, err
+	}
+
+	l.count = len(records)
+
+	return l, nil
+}
+
+// openAppend opens path for appending. A crash in the middle of an append leaves a partial last line, it is cut
+// off so the next record isn't glued onto it and lost with it.
+func openAppend(path string) (*os.File, error) {
+	if dir := filepath.Dir(path); dir != "" {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, err
+		}
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := truncatePartial(file); err != nil {
+		_ = file.Close()
+
+		return nil, err
+	}
+
+	return file, nil
+}
+
+// truncatePartial cuts the file back to the end of its last complete line.
+func truncatePartial(file *os.File) error {
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+
+	size := info.Size()
+	keep := int64(0)
+	buf := make([]byte, 4096)
+
+	for end := size; end > 0; {
+		start := end - int64(len(buf))
+		if start < 0 {
+			start = 0
+		}
+
+		chunk := buf[:end-start]
+		if _, err := file.ReadAt(chunk, start); err != nil {
+			return err
+		}
+
+		if i := bytes.LastIndexByte(chunk, '\n'); i >= 0 {
+			keep = start + int64(i) + 1
+
+			break
+		}
+
+		end = start
+	}
+
+	if keep == size {
+		return nil
+	}
+
+	if err := file.Truncate(keep); err != nil {
+		return err
+	}
+
+	return file.Sync()
+}
+
+func (l *Log) read() ([]json.RawMessage, error) {
+	file, err := os.Open(l.path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	records := make([]json.RawMessage, 0)
+
+	err = scan(file, func(record json.RawMessage) error {
+		records = append(records, record)
+
+		return nil
+	})
+
+	return records, err
+}
+
+// scan calls fn with every complete record of r.
+func scan(r io.Reader, fn func(record json.RawMessage) error) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		// A crash in the middle of an append leaves a partial last line, it was never acknowledged.
+		if !json.Valid(line) {
+			continue
+		}
+
+		if err := fn(append(json.RawMessage(nil), line...)); err != nil {
+			return err
+		}
+	}
+
+	return scanner.Err()
+}
+
+// write appends record to file as a line and syncs it. A write which fails halfway is cut off again.
+func write(file *os.File, record interface{}) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		_ = file.Truncate(info.Size())
+
+		return err
+	}
+
+	return file.Sync()
+}
+
+// Append writes record to the end of the log.
+func (l *Log) Append(record interface{}) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if err := write(l.file, record); err != nil {
+		return err
+	}
+
+	l.count++
+
+	return nil
+}
+
+// Len is the number of records waiting to be replayed.
+func (l *Log) Len() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.count
+}
+
+// Replay calls fn with the records in the order they were appended. It stops at the first error and keeps that
+// record and the ones after it for the next replay, the others are removed from the file. Appends wait until
+// the replay is done.
+func (l *Log) Replay(fn func(record json.RawMessage) error) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	records, err := l.read()
+	if err != nil {
+		return 0, err
+	}
+
+	done := 0
+	var replayErr error
+
+	for _, record := range records {
+		if replayErr = fn(record); replayErr != nil {
+			break
+		}
+
+		done++
+	}
+
+	if done == 0 {
+		return 0, replayErr
+	}
+
+	if err := l.rewrite(records[done:]); err != nil {
+		return done, fmt.Errorf("couldn't remove replayed records: %w", err)
+	}
+
+	return done, replayErr
+}
+
+// rewrite replaces the file with the remaining records through a rename, a crash leaves either the old or the
+// new file.
+func (l *Log) rewrite(records []json.RawMessage) error {
+	tmp := l.path + ".tmp"
+
+	file, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+
+	for _, record := range records {
+		if _, err := file.Write(append(record, '\n')); err != nil {
+			_ = file.Close()
+
+			return err
+		}
+	}
+
+	if err := file.Sync(); err != nil {
+		_ = file.Close()
+
+		return err
+	}
+
+	if err := file.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmp, l.path); err != nil {
+		return err
+	}
+
+	_ = l.file.Close()
+
+	if l.file, err = os.OpenFile(l.path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0o600); err != nil {
+		return err
+	}
+
+	l.count = len(records)
+
+	return nil
+}
+
+func (l *Log) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.file.Close()
+}
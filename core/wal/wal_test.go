@@ -0,0 +1,224 @@
+package wal
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type record struct {
+	ID int `json:"id"`
+}
+
+func replayIDs(t *testing.T, l *Log, failAt int) ([]int, error) {
+	t.Helper()
+
+	ids := make([]int, 0)
+
+	_, err := l.Replay(func(raw json.RawMessage) error {
+		r := &record{}
+		if err := json.Unmarshal(raw, r); err != nil {
+			t.Fatal(err)
+		}
+
+		if r.ID == failAt {
+			return errors.New("mongo is down")
+		}
+
+		ids = append(ids, r.ID)
+
+		return nil
+	})
+
+	return ids, err
+}
+
+func TestLogReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "wal", "resolutions.wal")
+
+	l, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for id := 1; id <= 3; id++ {
+		if err := l.Append(&record{ID: id}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if l, err = Open(path); err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	if l.Len() != 3 {
+		t.Fatalf("Len() = %d after reopening, want 3", l.Len())
+	}
+
+	// A failed record and the ones after it are kept for the next replay.
+	ids, err := replayIDs(t, l, 2)
+	if err == nil || !reflect.DeepEqual(ids, []int{1}) || l.Len() != 2 {
+		t.Fatalf("Replay() = %v, %v with %d left", ids, err, l.Len())
+	}
+
+	if ids, err = replayIDs(t, l, 0); err != nil || !reflect.DeepEqual(ids, []int{2, 3}) || l.Len() != 0 {
+		t.Fatalf("Replay() = %v, %v with %d left", ids, err, l.Len())
+	}
+}
+
+func TestLogPartialLine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "resolutions.wal")
+
+	// The process crashed while appending the third record.
+	if err := os.WriteFile(path, []byte("{\"id\":1}\n{\"id\":2}\n{\"id\":"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	l, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	if l.Len() != 2 {
+		t.Fatalf("Len() = %d, want the 2 complete records", l.Len())
+	}
+
+	// The record appended after the crash isn't glued onto the partial one.
+	if err := l.Append(&record{ID: 4}); err != nil {
+		t.Fatal(err)
+	}
+
+	if ids, err := replayIDs(t, l, 0); err != nil || !reflect.DeepEqual(ids, []int{1, 2, 4}) {
+		t.Fatalf("Replay() = %v, %v", ids, err)
+	}
+}
+
+func TestLogOnlyPartialLine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "resolutions.wal")
+
+	if err := os.WriteFile(path, []byte("{\"id\":1"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	l, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	if err := l.Append(&record{ID: 2}); err != nil {
+		t.Fatal(err)
+	}
+
+	if ids, err := replayIDs(t, l, 0); err != nil || !reflect.DeepEqual(ids, []int{2}) {
+		t.Fatalf("Replay() = %v, %v", ids, err)
+	}
+}
+
+func TestJournalPartialLine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "resolutions.journal")
+
+	if err := os.WriteFile(path, []byte("{\"id\":1}\n{\"id\""), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	j, err := OpenJournal(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := j.Append(&record{ID: 2}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := j.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	ids := make([]int, 0)
+	if err := ReadJournal(path, func(raw json.RawMessage) error {
+		r := &record{}
+		if err := json.Unmarshal(raw, r); err != nil {
+			return err
+		}
+
+		ids = append(ids, r.ID)
+
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(ids, []int{1, 2}) {
+		t.Fatalf("ReadJournal() = %v", ids)
+	}
+}
+
+func TestJournalRotate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "resolutions.journal")
+
+	j, err := OpenJournal(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer j.Close()
+
+	start := time.Date(2023, 2, 10, 12, 0, 0, 0, time.UTC)
+	retention := 48 * time.Hour
+
+	for day := 0; day < 4; day++ {
+		if err := j.Append(&record{ID: day}); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := j.Rotate(start.Add(time.Duration(day)*24*time.Hour), retention); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Nothing was appended since the last rotation, there is no empty file for it.
+	if err := j.Rotate(start.Add(4*24*time.Hour), retention); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := j.Append(&record{ID: 4}); err != nil {
+		t.Fatal(err)
+	}
+
+	rotated, err := rotatedJournals(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(rotated) != 2 {
+		t.Fatalf("rotated files %v, want the 2 within the retention", rotated)
+	}
+
+	ids := make([]int, 0)
+	if err := ReadJournal(path, func(raw json.RawMessage) error {
+		r := &record{}
+		if err := json.Unmarshal(raw, r); err != nil {
+			return err
+		}
+
+		ids = append(ids, r.ID)
+
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(ids, []int{2, 3, 4}) {
+		t.Fatalf("ReadJournal() = %v", ids)
+	}
+}
@@ -0,0 +1,81 @@
+package webhooks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"syscall"
+	"time"
+
+	"github.com/YusufOzmen01/veri-kontrol-backend/core/tracing"
+)
+
+// ErrForbiddenAddress is returned for receivers on loopback, private, link-local and other internal addresses.
+// Webhook URLs come from admins of the API, not of the network it runs in, they mustn't reach the cloud
+// metadata endpoint or the services next to the API.
+var ErrForbiddenAddress = errors.New("webhook receivers must be on a public address")
+
+// sharedAddressSpace is the carrier-grade NAT range, Go doesn't count it as private.
+var sharedAddressSpace = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+func publicIP(ip net.IP) bool {
+	return !(ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() || ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || sharedAddressSpace.Contains(ip))
+}
+
+// CheckURL resolves the host of a receiver and fails with ErrForbiddenAddress when any of its addresses isn't
+// public. It is only a check at registration, the name can point elsewhere later, Send checks again at every
+// connection.
+func CheckURL(ctx context.Context, rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+
+	addresses, err := net.DefaultResolver.LookupIPAddr(ctx, u.Hostname())
+	if err != nil {
+		return fmt.Errorf("couldn't resolve %s: %w", u.Hostname(), err)
+	}
+
+	for _, address := range addresses {
+		if !publicIP(address.IP) {
+			return fmt.Errorf("%w, %s resolves to %s", ErrForbiddenAddress, u.Hostname(), address.IP)
+		}
+	}
+
+	return nil
+}
+
+// checkDial runs before every connection with the address the name resolved to, so a receiver whose name
+// is changed to an internal address after the registration is refused as well.
+func checkDial(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+
+	if ip := net.ParseIP(host); ip == nil || !publicIP(ip) {
+		return fmt.Errorf("%w, not connecting to %s", ErrForbiddenAddress, host)
+	}
+
+	return nil
+}
+
+// client connects to receivers directly, a proxy from the environment would make checkDial see the proxy's
+// address instead of the receiver's.
+var client = &http.Client{
+	Timeout: 15 * time.Second,
+	Transport: tracing.Transport(&http.Transport{
+		DialContext: (&net.Dialer{
+			Timeout: 10 * time.Second,
+			Control: checkDial,
+		}).DialContext,
+		ForceAttemptHTTP2:   true,
+		MaxIdleConns:        100,
+		IdleConnTimeout:     90 * time.Second,
+		TLSHandshakeTimeout: 10 * time.Second,
+	}),
+}
@@ -0,0 +1,75 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+const (
+	SignatureHeader = "X-Webhook-Signature"
+	DeliveryHeader  = "X-Webhook-Delivery"
+
+	MaxAttempts = 8
+
+	minBackoff = 30 * time.Second
+	maxBackoff = time.Hour
+)
+
+// Sign returns the hex HMAC-SHA256 of the body, receivers compute the same with their secret to verify it.
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Backoff is the wait before the next attempt after the given number of failed ones: 30s, 1m, 2m and so on
+// up to an hour.
+func Backoff(attempts int) time.Duration {
+	backoff := minBackoff
+	for i := 1; i < attempts && backoff < maxBackoff; i++ {
+		backoff *= 2
+	}
+
+	if backoff > maxBackoff {
+		backoff = maxBackoff
+	}
+
+	return backoff
+}
+
+// Send posts a signed body, anything but a 2xx status counts as a failure. Receivers on internal addresses are
+// refused with ErrForbiddenAddress.
+func Send(ctx context.Context, url, secret, deliveryID string, body []byte) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(SignatureHeader, Sign(secret, body))
+	req.Header.Set(DeliveryHeader, deliveryID)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	status := resp.StatusCode
+
+	if status < 200 || status > 299 {
+		return status, fmt.Errorf("receiver returned status %d", status)
+	}
+
+	return status, nil
+}
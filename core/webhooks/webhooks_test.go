@@ -0,0 +1,107 @@
+package webhooks
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestPublicIP(t *testing.T) {
+	tests := []struct {
+		ip     string
+		public bool
+	}{
+		{"93.184.216.34", true},
+		{"2606:2800:220:1:248:1893:25c8:1946", true},
+		{"127.0.0.1", false},
+		{"::1", false},
+		{"10.1.2.3", false},
+		{"172.16.0.1", false},
+		{"192.168.1.1", false},
+		{"169.254.169.254", false},
+		{"100.64.0.1", false},
+		{"0.0.0.0", false},
+		{"::", false},
+		{"fd00::1", false},
+		{"fe80::1", false},
+		{"::ffff:127.0.0.1", false},
+		{"224.0.0.1", false},
+	}
+
+	for _, tt := range tests {
+		if got := publicIP(net.ParseIP(tt.ip)); got != tt.public {
+			t.Errorf("publicIP(%s) = %t, want %t", tt.ip, got, tt.public)
+		}
+	}
+}
+
+func TestCheckURL(t *testing.T) {
+	ctx := context.Background()
+
+	for _, rawURL := range []string{
+		"http://127.0.0.1:8080/hook",
+		"http://[::1]/hook",
+		"http://169.254.169.254/latest/meta-data/",
+		"https://10.0.0.5/hook",
+		"http://localhost/hook",
+	} {
+		if err := CheckURL(ctx, rawURL); !errors.Is(err, ErrForbiddenAddress) {
+			t.Errorf("CheckURL(%s) = %v, want ErrForbiddenAddress", rawURL, err)
+		}
+	}
+
+	if err := CheckURL(ctx, "https://93.184.216.34/hook"); err != nil {
+		t.Errorf("CheckURL() of a public address = %v", err)
+	}
+}
+
+func TestSendRefusesInternalReceivers(t *testing.T) {
+	received := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = true
+	}))
+	defer server.Close()
+
+	if _, err := Send(context.Background(), server.URL, "0123456789abcdef", "1", []byte("{}")); !errors.Is(err, ErrForbiddenAddress) {
+		t.Fatalf("Send() = %v, want ErrForbiddenAddress", err)
+	}
+
+	if received {
+		t.Fatal("the receiver on the loopback address got the request")
+	}
+}
+
+func TestSign(t *testing.T) {
+	// echo -n '{"event":"location.resolved"}' | openssl dgst -sha256 -hmac secret
+	want := "sha256=175333a1e18a559bf5d1700bc1b8dbe04433a713597be9c33b4777db4cf7605e"
+	if got := Sign("secret", []byte(`{"event":"location.resolved"}`)); got != want {
+		t.Fatalf("Sign() = %s, want %s", got, want)
+	}
+
+	if Sign("secret", []byte("a")) == Sign("other", []byte("a")) {
+		t.Fatal("Sign() doesn't depend on the secret")
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{1, 30 * time.Second},
+		{2, time.Minute},
+		{3, 2 * time.Minute},
+		{8, time.Hour},
+		{100, time.Hour},
+	}
+
+	for _, tt := range tests {
+		if got := Backoff(tt.attempts); got != tt.want {
+			t.Errorf("Backoff(%d) = %s, want %s", tt.attempts, got, tt.want)
+		}
+	}
+}
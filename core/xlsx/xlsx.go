@@ -0,0 +1,222 @@
+package xlsx
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// ErrNoSheet is returned for workbooks without a worksheet, which usually means the file isn't an xlsx at all.
+var ErrNoSheet = errors.New("the workbook has no worksheet")
+
+type workbook struct {
+	Sheets []struct {
+		ID string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+	} `xml:"sheets>sheet"`
+}
+
+type relationships struct {
+	Relationships []struct {
+		ID     string `xml:"Id,attr"`
+		Target string `xml:"Target,attr"`
+	} `xml:"Relationship"`
+}
+
+// richText is both a shared string and an inline string, either a plain <t> or runs of <r><t>.
+type richText struct {
+	Text string `xml:"t"`
+	Runs []struct {
+		Text string `xml:"t"`
+	} `xml:"r"`
+}
+
+func (t *richText) String() string {
+	if len(t.Runs) == 0 {
+		return t.Text
+	}
+
+	var b strings.Builder
+	for _, run := range t.Runs {
+		b.WriteString(run.Text)
+	}
+
+	return b.String()
+}
+
+type sharedStrings struct {
+	Items []richText `xml:"si"`
+}
+
+type worksheet struct {
+	Rows []struct {
+		Cells []struct {
+			Ref    string    `xml:"r,attr"`
+			Type   string    `xml:"t,attr"`
+			Value  string    `xml:"v"`
+			Inline *richText `xml:"is"`
+		} `xml:"c"`
+	} `xml:"sheetData>row"`
+}
+
+// Read returns the cells of the first worksheet as text, row by row. Numbers and dates come back the way they
+// are stored, dates as serial day numbers. Empty rows are skipped and every row is as long as its last cell.
+func Read(r io.ReaderAt, size int64) ([][]string, error) {
+	archive, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, err
+	}
+
+	files := make(map[string]*zip.File, len(archive.File))
+	for _, file := range archive.File {
+		files[file.Name] = file
+	}
+
+	sheetPath, err := firstSheet(files)
+	if err != nil {
+		return nil, err
+	}
+
+	strs := &sharedStrings{}
+	if file, ok := files["xl/sharedStrings.xml"]; ok {
+		if err := decode(file, strs); err != nil {
+			return nil, err
+		}
+	}
+
+	sheet := &worksheet{}
+	if err := decode(files[sheetPath], sheet); err != nil {
+		return nil, err
+	}
+
+	rows := make([][]string, 0, len(sheet.Rows))
+	for _, row := range sheet.Rows {
+		values := make([]string, 0, len(row.Cells))
+
+		for i, cell := range row.Cells {
+			column := i
+			if cell.Ref != "" {
+				if column, err = columnIndex(cell.Ref); err != nil {
+					return nil, err
+				}
+			}
+
+			value := cell.Value
+			switch cell.Type {
+			case "s":
+				index, err := strconv.Atoi(value)
+				if err != nil || index < 0 || index >= len(strs.Items) {
+					return nil, fmt.Errorf("cell %s refers to a missing shared string", cell.Ref)
+				}
+
+				value = strs.Items[index].String()
+			case "inlineStr":
+				if cell.Inline != nil {
+					value = cell.Inline.String()
+				}
+			}
+
+			for len(values) < column {
+				values = append(values, "")
+			}
+
+			if column < len(values) {
+				values[column] = value
+			} else {
+				values = append(values, value)
+			}
+		}
+
+		if len(values) > 0 {
+			rows = append(rows, values)
+		}
+	}
+
+	return rows, nil
+}
+
+// firstSheet follows the workbook to the part of its first sheet, the sheet file names don't have to match the
+// order of the tabs.
+func firstSheet(files map[string]*zip.File) (string, error) {
+	book := &workbook{}
+	rels := &relationships{}
+
+	bookFile, ok := files["xl/workbook.xml"]
+	relsFile, hasRels := files["xl/_rels/workbook.xml.rels"]
+	if !ok || !hasRels {
+		return "", ErrNoSheet
+	}
+
+	if err := decode(bookFile, book); err != nil {
+		return "", err
+	}
+
+	if err := decode(relsFile, rels); err != nil {
+		return "", err
+	}
+
+	if len(book.Sheets) == 0 {
+		return "", ErrNoSheet
+	}
+
+	for _, rel := range rels.Relationships {
+		if rel.ID != book.Sheets[0].ID {
+			continue
+		}
+
+		target := strings.TrimPrefix(rel.Target, "/")
+		if !strings.HasPrefix(target, "xl/") {
+			target = path.Join("xl", target)
+		}
+
+		if _, ok := files[target]; !ok {
+			return "", ErrNoSheet
+		}
+
+		return target, nil
+	}
+
+	return "", ErrNoSheet
+}
+
+func decode(file *zip.File, v interface{}) error {
+	rc, err := file.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	return xml.NewDecoder(rc).Decode(v)
+}
+
+// maxColumns is Excel's limit, XFD.
+const maxColumns = 16384
+
+// columnIndex turns the letters of a cell reference like "AB12" into a zero based column.
+func columnIndex(ref string) (int, error) {
+	column := 0
+	letters := 0
+
+	for _, r := range ref {
+		if r < 'A' || r > 'Z' {
+			break
+		}
+
+		column = column*26 + int(r-'A'+1)
+		letters++
+
+		if column > maxColumns {
+			break
+		}
+	}
+
+	if letters == 0 || column > maxColumns {
+		return 0, fmt.Errorf("invalid cell reference %q", ref)
+	}
+
+	return column - 1, nil
+}
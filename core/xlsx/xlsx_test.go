@@ -0,0 +1,116 @@
+package xlsx
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// workbookFiles is a workbook whose only tab is stored as sheet2.xml, like Excel does after tabs are deleted.
+var workbookFiles = map[string]string{
+	"xl/workbook.xml": `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">
+<sheets><sheet name="Resolutions" sheetId="1" r:id="rId3"/></sheets></workbook>`,
+	"xl/_rels/workbook.xml.rels": `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/>
+<Relationship Id="rId3" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet2.xml"/>
+</Relationships>`,
+	"xl/sharedStrings.xml": `<sst xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">
+<si><t>entry_id</t></si><si><t>resolved_at</t></si><si><r><t>Kurtuluş </t></r><r><t>Mah.</t></r></si></sst>`,
+	"xl/worksheets/sheet2.xml": `<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>
+<row r="1"><c r="A1" t="s"><v>0</v></c><c r="B1" t="s"><v>1</v></c><c r="C1" t="inlineStr"><is><t>address</t></is></c></row>
+<row r="2"><c r="A2"><v>12</v></c><c r="B2" s="1"><v>44963.1875</v></c><c r="C2" t="s"><v>2</v></c></row>
+<row r="3"></row>
+<row r="4"><c r="A4"><v>13</v></c><c r="C4" t="inlineStr"><is><t>Antakya</t></is></c></row>
+</sheetData></worksheet>`,
+}
+
+func zipped(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+
+	buf := &bytes.Buffer{}
+	w := zip.NewWriter(buf)
+
+	for name, content := range files {
+		f, err := w.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := f.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.Bytes()
+}
+
+func TestRead(t *testing.T) {
+	data := zipped(t, workbookFiles)
+
+	rows, err := Read(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := [][]string{
+		{"entry_id", "resolved_at", "address"},
+		{"12", "44963.1875", "Kurtuluş Mah."},
+		{"13", "", "Antakya"},
+	}
+
+	if !reflect.DeepEqual(rows, want) {
+		t.Fatalf("rows = %q, want %q", rows, want)
+	}
+}
+
+func TestReadErrors(t *testing.T) {
+	if _, err := Read(bytes.NewReader([]byte("entry_id,type\n1,2\n")), 16); err == nil {
+		t.Error("Read() accepted a CSV file")
+	}
+
+	noSheet := zipped(t, map[string]string{"docProps/app.xml": "<Properties/>"})
+	if _, err := Read(bytes.NewReader(noSheet), int64(len(noSheet))); !errors.Is(err, ErrNoSheet) {
+		t.Errorf("Read() without a workbook = %v, want ErrNoSheet", err)
+	}
+
+	files := make(map[string]string, len(workbookFiles))
+	for name, content := range workbookFiles {
+		files[name] = content
+	}
+	files["xl/sharedStrings.xml"] = `<sst xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><si><t>entry_id</t></si></sst>`
+
+	missing := zipped(t, files)
+	if _, err := Read(bytes.NewReader(missing), int64(len(missing))); err == nil {
+		t.Error("Read() accepted a missing shared string")
+	}
+}
+
+func TestColumnIndex(t *testing.T) {
+	tests := []struct {
+		ref     string
+		want    int
+		wantErr bool
+	}{
+		{"A1", 0, false},
+		{"Z9", 25, false},
+		{"AA10", 26, false},
+		{"AB12", 27, false},
+		{"XFD1", 16383, false},
+		{"XFE1", 0, true},
+		{"12", 0, true},
+		{"", 0, true},
+	}
+
+	for _, tt := range tests {
+		got, err := columnIndex(tt.ref)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("columnIndex(%q) = %d, %v, want %d", tt.ref, got, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,276 @@
+//go:build integration
+
+package integration
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/YusufOzmen01/veri-kontrol-backend/core/sources"
+	locationsRepository "github.com/YusufOzmen01/veri-kontrol-backend/repository/locations"
+	usersRepository "github.com/YusufOzmen01/veri-kontrol-backend/repository/users"
+	"github.com/YusufOzmen01/veri-kontrol-backend/tools"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// appDatabase is the database the app works on, the name is fixed in cmd/app.
+const appDatabase = "database"
+
+type app struct {
+	url string
+}
+
+func freePort(t *testing.T) int {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	return listener.Addr().(*net.TCPAddr).Port
+}
+
+// writeFeed writes a feed of count entries around Kahramanmaraş in the format of the file source.
+func writeFeed(t *testing.T, path string, count int) {
+	t.Helper()
+
+	locs := make([]*locationsRepository.Location, 0, count)
+	for i := 1; i <= count; i++ {
+		locs = append(locs, &locationsRepository.Location{
+			EntryID:          i,
+			Loc:              []float64{37.5 + float64(i)/1000, 36.9 + float64(i)/1000},
+			Epoch:            1675650000 + i*60,
+			OriginalMessage:  fmt.Sprintf("Enkaz altında %d kişi var", i%5+1),
+			OriginalLocation: "Dulkadiroğlu, Kahramanmaraş",
+		})
+	}
+
+	data, err := json.Marshal(map[string]interface{}{"results": locs})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// startApp runs the built app on a feed of 50 entries read from a file until the test is done.
+func startApp(t *testing.T) *app {
+	t.Helper()
+
+	dir := t.TempDir()
+	port := freePort(t)
+
+	feed := filepath.Join(dir, "feed.json")
+	writeFeed(t, feed, 50)
+
+	cmd := exec.Command(appBinary)
+	cmd.Dir = dir
+	cmd.Stdout, cmd.Stderr = os.Stderr, os.Stderr
+	cmd.Env = append(os.Environ(),
+		"mongo_uri="+mongoURI,
+		fmt.Sprintf("port=%d", port),
+		"log_level=warn",
+		"jwt_secret=integration",
+		"upstream_sources=test:file:"+feed,
+		"wal_path="+filepath.Join(dir, "resolutions.wal"),
+		"journal_path="+filepath.Join(dir, "resolutions.journal"),
+	)
+
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		_ = cmd.Process.Signal(os.Interrupt)
+		_ = cmd.Wait()
+
+		if err := client.Database(appDatabase).Drop(context.Background()); err != nil {
+			t.Logf("Couldn't drop %s: %s", appDatabase, err)
+		}
+	})
+
+	a := &app{url: fmt.Sprintf("http://127.0.0.1:%d", port)}
+
+	deadline := time.Now().Add(time.Minute)
+	for {
+		resp, err := http.Get(a.url + "/healthz")
+		if err == nil {
+			resp.Body.Close()
+
+			if resp.StatusCode == http.StatusOK {
+				return a
+			}
+		}
+
+		if time.Now().After(deadline) {
+			t.Fatalf("the app didn't come up: %v", err)
+		}
+
+		time.Sleep(200 * time.Millisecond)
+	}
+}
+
+// do sends the request with the Auth-Key and decodes a JSON body into out when it is given.
+func (a *app) do(t *testing.T, method, path, authKey string, body interface{}, out interface{}) int {
+	t.Helper()
+
+	var reader io.Reader
+	if body != nil {
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		reader = bytes.NewReader(encoded)
+	}
+
+	req, err := http.NewRequest(method, a.url+path, reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	if authKey != "" {
+		req.Header.Set("Auth-Key", authKey)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if out != nil && resp.StatusCode < 300 {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			t.Fatalf("%s %s: %s", method, path, err)
+		}
+	}
+
+	return resp.StatusCode
+}
+
+type getLocationResponse struct {
+	Count    int                           `json:"count"`
+	Location *locationsRepository.Location `json:"location"`
+}
+
+type getLocationsResponse struct {
+	Locations []*locationsRepository.Location `json:"locations"`
+}
+
+type resolveBody struct {
+	ID     int    `json:"id"`
+	Type   int    `json:"type"`
+	Reason string `json:"reason"`
+}
+
+type featureCollection struct {
+	Features []*tools.GeoJSONFeature `json:"features"`
+}
+
+// TestClaimServeResolveExport walks an entry the way volunteers and coordinators see it: it is claimed and
+// served to one volunteer, resolved by them, left out of what is served afterwards and exported.
+func TestClaimServeResolveExport(t *testing.T) {
+	ctx := context.Background()
+	users := usersRepository.NewRepository(sources.NewMongoClient(ctx, mongoURI, appDatabase))
+
+	_, volunteerKey, err := users.AddUser(ctx, "volunteer", "", usersRepository.PermSubmit, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, otherKey, err := users.AddUser(ctx, "other", "", usersRepository.PermSubmit, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, adminKey, err := users.AddUser(ctx, "admin", "", usersRepository.PermAdmin, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a := startApp(t)
+
+	served := &getLocationResponse{}
+	if status := a.do(t, "GET", "/get-location", volunteerKey, nil, served); status != http.StatusOK {
+		t.Fatalf("GET /get-location = %d, want 200", status)
+	}
+
+	if served.Location == nil {
+		t.Fatal("GET /get-location served nothing from the feed")
+	}
+
+	entryID := served.Location.EntryID
+	body := &resolveBody{ID: entryID, Type: locationsRepository.TypeWreckage, Reason: locationsRepository.ReasonNoError}
+
+	// The entry is claimed by the volunteer it was served to.
+	if status := a.do(t, "POST", "/resolve", otherKey, body, nil); status != http.StatusConflict {
+		t.Fatalf("resolving a claimed entry as someone else = %d, want 409", status)
+	}
+
+	if status := a.do(t, "POST", "/resolve", volunteerKey, body, nil); status != http.StatusOK {
+		t.Fatalf("POST /resolve = %d, want 200", status)
+	}
+
+	if status := a.do(t, "POST", "/resolve", volunteerKey, body, nil); status != http.StatusConflict {
+		t.Fatalf("resolving entry %d twice = %d, want 409", entryID, status)
+	}
+
+	batch := &getLocationsResponse{}
+	if status := a.do(t, "GET", "/get-locations?count=50", otherKey, nil, batch); status != http.StatusOK {
+		t.Fatalf("GET /get-locations = %d, want 200", status)
+	}
+
+	for _, loc := range batch.Locations {
+		if loc.EntryID == entryID {
+			t.Fatalf("resolved entry %d is still served", entryID)
+		}
+	}
+
+	exported := &featureCollection{}
+	if status := a.do(t, "GET", "/admin/export/geojson", adminKey, nil, exported); status != http.StatusOK {
+		t.Fatalf("GET /admin/export/geojson = %d, want 200", status)
+	}
+
+	var feature *tools.GeoJSONFeature
+	for _, f := range exported.Features {
+		if f.Properties.EntryID == entryID {
+			feature = f
+		}
+	}
+
+	if feature == nil {
+		t.Fatalf("entry %d isn't in the export", entryID)
+	}
+
+	if feature.Properties.Reason != locationsRepository.ReasonNoError || feature.Properties.Type != locationsRepository.TypeWreckage {
+		t.Fatalf("entry %d is exported as %+v", entryID, feature.Properties)
+	}
+
+	// The resolution was written with its audit event and outbox message.
+	db := client.Database(appDatabase)
+
+	events, err := db.Collection("audit").CountDocuments(ctx, bson.D{{Key: "entry_id", Value: entryID}})
+	if err != nil || events == 0 {
+		t.Errorf("resolving entry %d recorded %d audit events: %v", entryID, events, err)
+	}
+
+	messages, err := db.Collection("outbox").CountDocuments(ctx, bson.D{{Key: "topic", Value: tools.TopicLocationResolved}})
+	if err != nil || messages == 0 {
+		t.Errorf("resolving entry %d queued %d outbox messages: %v", entryID, messages, err)
+	}
+}
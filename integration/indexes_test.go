@@ -0,0 +1,110 @@
+//go:build integration
+
+package integration
+
+import (
+	"context"
+	"testing"
+
+	auditRepository "github.com/YusufOzmen01/veri-kontrol-backend/repository/audit"
+	locationsRepository "github.com/YusufOzmen01/veri-kontrol-backend/repository/locations"
+	outboxRepository "github.com/YusufOzmen01/veri-kontrol-backend/repository/outbox"
+	usersRepository "github.com/YusufOzmen01/veri-kontrol-backend/repository/users"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type index struct {
+	Name               string `bson:"name"`
+	Unique             bool   `bson:"unique"`
+	ExpireAfterSeconds *int32 `bson:"expireAfterSeconds"`
+}
+
+func indexes(t *testing.T, db *mongo.Database, collection string) map[string]index {
+	t.Helper()
+
+	ctx := context.Background()
+
+	cursor, err := db.Collection(collection).Indexes().List(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	list := make([]index, 0)
+	if err := cursor.All(ctx, &list); err != nil {
+		t.Fatal(err)
+	}
+
+	byName := make(map[string]index, len(list))
+	for _, i := range list {
+		byName[i.Name] = i
+	}
+
+	return byName
+}
+
+func TestIndexes(t *testing.T) {
+	mongoClient, db := database(t)
+
+	locationsRepository.NewRepository(mongoClient)
+	usersRepository.NewRepository(mongoClient)
+	auditRepository.NewRepository(mongoClient)
+	outboxRepository.NewRepository(mongoClient)
+
+	tests := []struct {
+		collection string
+		name       string
+		unique     bool
+		ttl        bool
+	}{
+		{"locations", "entry_id_1", true, false},
+		{"locations", "tweet_contents_hashed", false, false},
+		{"locations", "sender._id_1", false, false},
+		{"locations", "point_2dsphere", false, false},
+		{"locations", "geohash_1", false, false},
+		{"claims", "entry_id_1", true, false},
+		{"claims", "expires_at_1", false, true},
+		{"users", "auth_key_hash_1", false, false},
+		{"refresh_tokens", "token_hash_1", true, false},
+		{"refresh_tokens", "expires_at_1", false, true},
+		{"outbox", "status_1_next_attempt_at_1", false, false},
+	}
+
+	found := make(map[string]map[string]index)
+
+	for _, tt := range tests {
+		if found[tt.collection] == nil {
+			found[tt.collection] = indexes(t, db, tt.collection)
+		}
+
+		i, ok := found[tt.collection][tt.name]
+		if !ok {
+			t.Errorf("%s has no %s index", tt.collection, tt.name)
+
+			continue
+		}
+
+		if i.Unique != tt.unique {
+			t.Errorf("%s index %s is unique: %t, want %t", tt.collection, tt.name, i.Unique, tt.unique)
+		}
+
+		if (i.ExpireAfterSeconds != nil) != tt.ttl {
+			t.Errorf("%s index %s expires documents: %t, want %t", tt.collection, tt.name, i.ExpireAfterSeconds != nil, tt.ttl)
+		}
+	}
+
+	// Building the repositories again leaves the indexes as they are.
+	locationsRepository.NewRepository(mongoClient)
+
+	if again := indexes(t, db, "locations"); len(again) != len(found["locations"]) {
+		t.Fatalf("locations has %d indexes after a restart, had %d", len(again), len(found["locations"]))
+	}
+
+	// The unique entry_id index is what turns a second resolution into ErrAlreadyResolved.
+	if _, err := db.Collection("locations").InsertMany(context.Background(), []interface{}{
+		bson.D{{Key: "entry_id", Value: 1}},
+		bson.D{{Key: "entry_id", Value: 1}},
+	}); !mongo.IsDuplicateKeyError(err) {
+		t.Fatalf("inserting entry 1 twice = %v, want a duplicate key error", err)
+	}
+}
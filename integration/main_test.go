@@ -0,0 +1,157 @@
+//go:build integration
+
+// Package integration runs the repositories and the app against a real Mongo, started in Docker for the run:
+//
+//	go test -tags integration ./integration/
+//
+// Mongo runs as a single node replica set, so the transactions of /resolve are exercised as well.
+package integration
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/YusufOzmen01/veri-kontrol-backend/core/sources"
+	"github.com/ory/dockertest/v3"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	// mongoURI reaches the container, client is for looking at what the code under test wrote.
+	mongoURI string
+	client   *mongo.Client
+
+	// appBinary is cmd/app built once for the API tests.
+	appBinary string
+)
+
+func TestMain(m *testing.M) {
+	os.Exit(run(m))
+}
+
+func run(m *testing.M) int {
+	pool, err := dockertest.NewPool("")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Couldn't reach Docker: %s\n", err)
+
+		return 1
+	}
+
+	pool.MaxWait = 2 * time.Minute
+
+	resource, err := pool.RunWithOptions(&dockertest.RunOptions{
+		Repository: "mongo",
+		Tag:        "6.0",
+		Cmd:        []string{"--replSet", "rs0", "--bind_ip_all"},
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Couldn't start Mongo: %s\n", err)
+
+		return 1
+	}
+
+	defer func() {
+		if err := pool.Purge(resource); err != nil {
+			fmt.Fprintf(os.Stderr, "Couldn't remove the Mongo container: %s\n", err)
+		}
+	}()
+
+	// The replica set knows its member by the address inside the container, the tests connect directly.
+	mongoURI = fmt.Sprintf("mongodb://localhost:%s/?directConnection=true", resource.GetPort("27017/tcp"))
+
+	ctx := context.Background()
+
+	if err := pool.Retry(func() error {
+		var err error
+		if client, err = mongo.Connect(ctx, options.Client().ApplyURI(mongoURI)); err != nil {
+			return err
+		}
+
+		return client.Ping(ctx, nil)
+	}); err != nil {
+		fmt.Fprintf(os.Stderr, "Mongo didn't come up: %s\n", err)
+
+		return 1
+	}
+
+	defer client.Disconnect(ctx)
+
+	if err := client.Database("admin").RunCommand(ctx, bson.D{{Key: "replSetInitiate", Value: bson.D{
+		{Key: "_id", Value: "rs0"},
+		{Key: "members", Value: bson.A{bson.D{{Key: "_id", Value: 0}, {Key: "host", Value: "localhost:27017"}}}},
+	}}}).Err(); err != nil {
+		fmt.Fprintf(os.Stderr, "Couldn't initiate the replica set: %s\n", err)
+
+		return 1
+	}
+
+	if err := pool.Retry(func() error {
+		hello := bson.M{}
+		if err := client.Database("admin").RunCommand(ctx, bson.D{{Key: "hello", Value: 1}}).Decode(&hello); err != nil {
+			return err
+		}
+
+		if primary, _ := hello["isWritablePrimary"].(bool); !primary {
+			return fmt.Errorf("no primary yet")
+		}
+
+		return nil
+	}); err != nil {
+		fmt.Fprintf(os.Stderr, "The replica set has no primary: %s\n", err)
+
+		return 1
+	}
+
+	dir, err := os.MkdirTemp("", "integration")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+
+		return 1
+	}
+
+	defer os.RemoveAll(dir)
+
+	appBinary = filepath.Join(dir, "app")
+
+	build := exec.Command("go", "build", "-o", appBinary, "../cmd/app")
+	build.Stdout, build.Stderr = os.Stderr, os.Stderr
+	if err := build.Run(); err != nil {
+		fmt.Fprintf(os.Stderr, "Couldn't build the app: %s\n", err)
+
+		return 1
+	}
+
+	return m.Run()
+}
+
+var unsafeDatabaseChars = regexp.MustCompile(`[^A-Za-z0-9_]`)
+
+// database returns a client for a database of the test alone, it is dropped once the test is done.
+func database(t *testing.T) (sources.MongoClient, *mongo.Database) {
+	t.Helper()
+
+	name := unsafeDatabaseChars.ReplaceAllString(strings.ToLower(t.Name()), "_")
+	if len(name) > 60 {
+		name = name[:60]
+	}
+
+	ctx := context.Background()
+	db := client.Database(name)
+
+	t.Cleanup(func() {
+		if err := db.Drop(ctx); err != nil {
+			t.Logf("Couldn't drop %s: %s", name, err)
+		}
+	})
+
+	return sources.NewMongoClient(ctx, mongoURI, name), db
+}
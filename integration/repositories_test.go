@@ -0,0 +1,398 @@
+//go:build integration
+
+package integration
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	locationsRepository "github.com/YusufOzmen01/veri-kontrol-backend/repository/locations"
+	usersRepository "github.com/YusufOzmen01/veri-kontrol-backend/repository/users"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func resolution(entryID int, sender *usersRepository.User) *locationsRepository.LocationDB {
+	return &locationsRepository.LocationDB{
+		ID:       primitive.NewObjectID(),
+		EntryID:  entryID,
+		Sender:   sender,
+		Location: []float64{37.5753, 36.9228},
+		Reason:   locationsRepository.ReasonNoError,
+		Type:     locationsRepository.TypeWreckage,
+		Status:   locationsRepository.StatusResolved,
+	}
+}
+
+func TestLocationsResolve(t *testing.T) {
+	mongoClient, _ := database(t)
+	locations := locationsRepository.NewRepository(mongoClient)
+	ctx := context.Background()
+
+	if err := locations.ResolveLocation(ctx, resolution(1, nil)); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := locations.ResolveLocation(ctx, resolution(1, nil)); !errors.Is(err, locationsRepository.ErrAlreadyResolved) {
+		t.Fatalf("resolving entry 1 twice = %v, want ErrAlreadyResolved", err)
+	}
+
+	resolved, err := locations.IsResolved(ctx, 1)
+	if err != nil || !resolved {
+		t.Fatalf("IsResolved(1) = %t, %v", resolved, err)
+	}
+
+	stored, err := locations.GetLocation(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if stored.Point == nil || stored.Geohash == "" {
+		t.Fatalf("entry 1 was stored without its point or geohash: %+v", stored)
+	}
+
+	if _, err := locations.GetLocation(ctx, 2); !errors.Is(err, locationsRepository.ErrLocationNotFound) {
+		t.Fatalf("GetLocation(2) = %v, want ErrLocationNotFound", err)
+	}
+}
+
+// Only one of many volunteers resolving the same entry at once gets through.
+func TestLocationsResolveConcurrently(t *testing.T) {
+	mongoClient, _ := database(t)
+	locations := locationsRepository.NewRepository(mongoClient)
+
+	var (
+		wg        sync.WaitGroup
+		mu        sync.Mutex
+		succeeded int
+	)
+
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			err := locations.ResolveLocation(context.Background(), resolution(7, nil))
+			if err != nil && !errors.Is(err, locationsRepository.ErrAlreadyResolved) {
+				t.Error(err)
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			if err == nil {
+				succeeded++
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	if succeeded != 1 {
+		t.Fatalf("%d resolutions of entry 7 went through, want 1", succeeded)
+	}
+}
+
+func TestLocationsResolveMany(t *testing.T) {
+	mongoClient, _ := database(t)
+	locations := locationsRepository.NewRepository(mongoClient)
+	ctx := context.Background()
+
+	if err := locations.ResolveLocation(ctx, resolution(2, nil)); err != nil {
+		t.Fatal(err)
+	}
+
+	conflicts, err := locations.ResolveLocations(ctx, []*locationsRepository.LocationDB{
+		resolution(1, nil), resolution(2, nil), resolution(3, nil),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(conflicts) != 1 || conflicts[0] != 2 {
+		t.Fatalf("ResolveLocations reported conflicts %v, want [2]", conflicts)
+	}
+
+	ids, err := locations.GetResolvedIDs(ctx, []int{1, 2, 3, 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sort.Ints(ids)
+	if len(ids) != 3 || ids[0] != 1 || ids[1] != 2 || ids[2] != 3 {
+		t.Fatalf("resolved IDs are %v, want [1 2 3]", ids)
+	}
+}
+
+func TestLocationsUnresolve(t *testing.T) {
+	mongoClient, db := database(t)
+	locations := locationsRepository.NewRepository(mongoClient)
+	ctx := context.Background()
+
+	if err := locations.ResolveLocation(ctx, resolution(4, nil)); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := locations.UnresolveLocation(ctx, 4, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := locations.UnresolveLocation(ctx, 4, nil); !errors.Is(err, locationsRepository.ErrLocationNotFound) {
+		t.Fatalf("unresolving entry 4 twice = %v, want ErrLocationNotFound", err)
+	}
+
+	if resolved, err := locations.IsResolved(ctx, 4); err != nil || resolved {
+		t.Fatalf("IsResolved(4) = %t, %v after the unresolve", resolved, err)
+	}
+
+	history, err := db.Collection("location_history").CountDocuments(ctx, bson.D{{Key: "entry_id", Value: 4}})
+	if err != nil || history != 1 {
+		t.Fatalf("entry 4 has %d history entries, want 1: %v", history, err)
+	}
+}
+
+func TestLocationsReplace(t *testing.T) {
+	mongoClient, _ := database(t)
+	locations := locationsRepository.NewRepository(mongoClient)
+	ctx := context.Background()
+
+	volunteer := &usersRepository.User{ID: primitive.NewObjectID(), Name: "volunteer"}
+	moderator := &usersRepository.User{ID: primitive.NewObjectID(), Name: "moderator"}
+
+	original := resolution(5, volunteer)
+	if err := locations.ResolveLocation(ctx, original); err != nil {
+		t.Fatal(err)
+	}
+
+	edited := resolution(5, moderator)
+	edited.CorrectedAddress = "Kurtuluş Mah. Antakya"
+
+	if err := locations.ReplaceLocation(ctx, edited, moderator); err != nil {
+		t.Fatal(err)
+	}
+
+	stored, err := locations.GetLocation(ctx, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if stored.ID != original.ID || stored.CorrectedAddress != edited.CorrectedAddress {
+		t.Fatalf("entry 5 is %+v after the edit", stored)
+	}
+
+	if stored.Revision != 1 || stored.UpdatedAt == nil {
+		t.Fatalf("entry 5 is at revision %d, updated at %v after the edit", stored.Revision, stored.UpdatedAt)
+	}
+
+	// The edit is picked up by a since cursor later than the resolution.
+	changed, err := locations.GetLocations(ctx, &locationsRepository.LocationFilter{ChangedSince: stored.UpdatedAt.Add(-time.Millisecond)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(changed) != 1 || changed[0].EntryID != 5 {
+		t.Fatalf("GetLocations(ChangedSince) = %d locations, want entry 5", len(changed))
+	}
+
+	revisions, err := locations.GetRevisions(ctx, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(revisions) != 1 {
+		t.Fatalf("entry 5 has %d revisions, want 1", len(revisions))
+	}
+
+	// Replacing an entry without a resolution creates it.
+	if err := locations.ReplaceLocation(ctx, resolution(6, moderator), moderator); err != nil {
+		t.Fatal(err)
+	}
+
+	if resolved, err := locations.IsResolved(ctx, 6); err != nil || !resolved {
+		t.Fatalf("IsResolved(6) = %t, %v after the replace", resolved, err)
+	}
+}
+
+func TestLocationsClaims(t *testing.T) {
+	mongoClient, _ := database(t)
+	locations := locationsRepository.NewRepository(mongoClient)
+	ctx := context.Background()
+
+	claimed, err := locations.ClaimLocation(ctx, 3, "alice", time.Minute, 0)
+	if err != nil || !claimed {
+		t.Fatalf("ClaimLocation(3, alice) = %t, %v", claimed, err)
+	}
+
+	if claimed, err := locations.ClaimLocation(ctx, 3, "bob", time.Minute, 0); err != nil || claimed {
+		t.Fatalf("ClaimLocation(3, bob) = %t, %v while alice holds it", claimed, err)
+	}
+
+	if other, err := locations.IsClaimed(ctx, 3, "bob"); err != nil || !other {
+		t.Fatalf("IsClaimed(3, bob) = %t, %v", other, err)
+	}
+
+	if other, err := locations.IsClaimed(ctx, 3, "alice"); err != nil || other {
+		t.Fatalf("IsClaimed(3, alice) = %t, %v", other, err)
+	}
+
+	if err := locations.ReleaseLocation(ctx, 3, "alice", locationsRepository.ClaimReleased); err != nil {
+		t.Fatal(err)
+	}
+
+	if claimed, err := locations.ClaimLocation(ctx, 3, "bob", time.Minute, 0); err != nil || !claimed {
+		t.Fatalf("ClaimLocation(3, bob) = %t, %v after alice released it", claimed, err)
+	}
+
+	if claimed, err := locations.ClaimLocation(ctx, 4, "bob", time.Minute, 1); !errors.Is(err, locationsRepository.ErrTooManyClaims) || claimed {
+		t.Fatalf("ClaimLocation(4, bob) = %t, %v over max_claims", claimed, err)
+	}
+
+	if other, err := locations.IsClaimed(ctx, 4, "alice"); err != nil || other {
+		t.Fatalf("IsClaimed(4, alice) = %t, %v after the claim over max_claims was given up", other, err)
+	}
+
+	if _, err := locations.ClaimLocation(ctx, 5, "alice", -time.Second, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	expired, err := locations.ExpireClaims(ctx, time.Now())
+	if err != nil || len(expired) != 1 || expired[0].EntryID != 5 {
+		t.Fatalf("ExpireClaims() = %v, %v", expired, err)
+	}
+}
+
+func TestUsersAndRefreshTokens(t *testing.T) {
+	mongoClient, _ := database(t)
+	users := usersRepository.NewRepository(mongoClient)
+	ctx := context.Background()
+
+	user, authKey, err := users.AddUser(ctx, "volunteer", "", usersRepository.PermSubmit, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	found, err := users.GetUser(ctx, authKey)
+	if err != nil || found.ID != user.ID {
+		t.Fatalf("GetUser = %+v, %v", found, err)
+	}
+
+	if _, err := users.GetUser(ctx, "unknown"); !errors.Is(err, usersRepository.ErrUserNotFound) {
+		t.Fatalf("GetUser(unknown) = %v, want ErrUserNotFound", err)
+	}
+
+	token, err := users.CreateRefreshToken(ctx, user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Two refreshes racing with the same token, only one may consume it.
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		consumed int
+	)
+
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			refreshToken, err := users.ConsumeRefreshToken(context.Background(), token)
+			if err != nil && !errors.Is(err, usersRepository.ErrRefreshTokenInvalid) {
+				t.Error(err)
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			if err == nil {
+				if refreshToken.UserID != user.ID {
+					t.Errorf("refresh token belongs to %s, want %s", refreshToken.UserID.Hex(), user.ID.Hex())
+				}
+
+				consumed++
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	if consumed != 1 {
+		t.Fatalf("the refresh token was consumed %d times, want 1", consumed)
+	}
+}
+
+func TestLocationsThroughput(t *testing.T) {
+	mongoClient, _ := database(t)
+	locations := locationsRepository.NewRepository(mongoClient)
+	ctx := context.Background()
+
+	square := func(lat, lng float64) [][]float64 {
+		return [][]float64{{lat, lng}, {lat + 1, lng}, {lat + 1, lng + 1}, {lat, lng + 1}}
+	}
+
+	// Area 2 overlaps area 1, what lies in both counts for area 1.
+	areas := []*locationsRepository.ThroughputArea{
+		{ID: 1, Polygon: square(36, 36)},
+		{ID: 2, Polygon: square(36.5, 36.5)},
+	}
+
+	points := []struct {
+		entryID  int
+		location []float64
+		typ      int
+	}{
+		{1, []float64{36.2, 36.2}, locationsRepository.TypeWreckage},
+		{2, []float64{36.7, 36.7}, locationsRepository.TypeSupplyHelp},
+		{3, []float64{37.2, 37.2}, locationsRepository.TypeWreckage},
+		{4, []float64{39, 39}, locationsRepository.TypeWreckage},
+		{5, []float64{}, locationsRepository.TypeWreckage},
+	}
+
+	for _, p := range points {
+		location := resolution(p.entryID, nil)
+		location.Location = p.location
+		location.Type = p.typ
+
+		if err := locations.ResolveLocation(ctx, location); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	counts, err := locations.GetThroughput(ctx, &locationsRepository.LocationFilter{}, 24*time.Hour, areas)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type key struct{ area, typ int }
+
+	got := make(map[key]int)
+	total := 0
+	for _, count := range counts {
+		got[key{count.Area, count.Type}] += count.Count
+		total += count.Count
+	}
+
+	want := map[key]int{
+		{1, locationsRepository.TypeWreckage}:   1,
+		{1, locationsRepository.TypeSupplyHelp}: 1,
+		{2, locationsRepository.TypeWreckage}:   1,
+		{0, locationsRepository.TypeWreckage}:   2,
+	}
+
+	if total != len(points) || len(got) != len(want) {
+		t.Fatalf("GetThroughput = %v, want %v", got, want)
+	}
+
+	for k, n := range want {
+		if got[k] != n {
+			t.Fatalf("area %d type %d has %d resolutions, want %d", k.area, k.typ, got[k], n)
+		}
+	}
+}
@@ -0,0 +1,267 @@
+package audit
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"time"
+
+	"github.com/YusufOzmen01/veri-kontrol-backend/core/sources"
+	"github.com/YusufOzmen01/veri-kontrol-backend/repository/users"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type Repository interface {
+	Record(ctx context.Context, event *Event) error
+	GetEvents(ctx context.Context, filter *EventFilter) ([]*Event, error)
+	CountEvents(ctx context.Context, filter *EventFilter) (int64, error)
+}
+
+type repository struct {
+	mongo sources.MongoClient
+}
+
+func NewRepository(mongo sources.MongoClient) Repository {
+	r := &repository{
+		mongo: mongo,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	r.ensureIndexes(ctx)
+
+	return r
+}
+
+func (r *repository) ensureIndexes(ctx context.Context) {
+	if _, err := r.mongo.CreateIndex(ctx, "audit", bson.E{Key: "actor._id", Value: 1}); err != nil {
+		logrus.Errorf("Couldn't create audit actor._id index: %s", err)
+	}
+
+	if _, err := r.mongo.CreateIndex(ctx, "audit", bson.E{Key: "entry_id", Value: 1}); err != nil {
+		logrus.Errorf("Couldn't create audit entry_id index: %s", err)
+	}
+
+	if _, err := r.mongo.CreateIndex(ctx, "audit", bson.E{Key: "at", Value: -1}); err != nil {
+		logrus.Errorf("Couldn't create audit at index: %s", err)
+	}
+}
+
+const (
+	ActionResolve          = "resolve"
+	ActionBulkResolve      = "bulk_resolve"
+	ActionDuplicate        = "duplicate_resolve"
+	ActionRelease          = "release"
+	ActionUpdate           = "update"
+	ActionRollback         = "rollback"
+	ActionUnresolve        = "unresolve"
+	ActionApprove          = "approve"
+	ActionHide             = "hide"
+	ActionRestore          = "restore"
+	ActionCityAdd          = "city_add"
+	ActionCityUpdate       = "city_update"
+	ActionCityDelete       = "city_delete"
+	ActionUserCreate       = "user_create"
+	ActionUserUpdate       = "user_update"
+	ActionUserDisable      = "user_deactivate"
+	ActionUserRegister     = "user_register"
+	ActionUserApprove      = "user_approve"
+	ActionUserReject       = "user_reject"
+	ActionUserRotateKey    = "user_rotate_key"
+	ActionAssignmentSet    = "assignment_set"
+	ActionAssignmentDelete = "assignment_delete"
+	ActionAPIKey           = "api_key_create"
+	ActionRuleAdd          = "notification_rule_add"
+	ActionRuleDelete       = "notification_rule_delete"
+	ActionWebhookAdd       = "webhook_add"
+	ActionWebhookDelete    = "webhook_delete"
+	ActionEventAdd         = "event_add"
+	ActionEventUpdate      = "event_update"
+	ActionEventDelete      = "event_delete"
+	ActionEventActivate    = "event_activate"
+	ActionQAGrade          = "qa_grade"
+	ActionCommentAdd       = "comment_add"
+	ActionOutboxRetry      = "outbox_retry"
+	ActionImport           = "import"
+	ActionCacheInvalidate  = "cache_invalidate"
+	ActionCacheWarm        = "cache_warm"
+)
+
+// Change is a single field which differs between the document before and after an action.
+type Change struct {
+	Field  string      `json:"field" bson:"field"`
+	Before interface{} `json:"before" bson:"before"`
+	After  interface{} `json:"after" bson:"after"`
+}
+
+// Event is one mutating request. Actor is nil for anonymous volunteers, IP tells them apart. Target names
+// what was changed when it isn't an entry, like a city or user ID.
+type Event struct {
+	ID      primitive.ObjectID `json:"_id" bson:"_id"`
+	Actor   *users.User        `json:"actor" bson:"actor"`
+	IP      string             `json:"ip" bson:"ip"`
+	Action  string             `json:"action" bson:"action"`
+	EntryID int                `json:"entry_id,omitempty" bson:"entry_id,omitempty"`
+	Target  string             `json:"target,omitempty" bson:"target,omitempty"`
+	Changes []*Change          `json:"changes" bson:"changes"`
+	At      time.Time          `json:"at" bson:"at"`
+}
+
+// Diff compares the BSON form of two documents field by field, either side can be nil for creations and
+// deletions. The _id is left out since every resolution gets a new one.
+func Diff(before, after interface{}) []*Change {
+	b, a := toMap(before), toMap(after)
+
+	fields := make([]string, 0, len(b)+len(a))
+	for field := range b {
+		fields = append(fields, field)
+	}
+
+	for field := range a {
+		if _, ok := b[field]; !ok {
+			fields = append(fields, field)
+		}
+	}
+
+	sort.Strings(fields)
+
+	changes := make([]*Change, 0)
+	for _, field := range fields {
+		if field == "_id" || reflect.DeepEqual(b[field], a[field]) {
+			continue
+		}
+
+		changes = append(changes, &Change{
+			Field:  field,
+			Before: b[field],
+			After:  a[field],
+		})
+	}
+
+	return changes
+}
+
+func toMap(document interface{}) bson.M {
+	m := bson.M{}
+	if document == nil || reflect.ValueOf(document).Kind() == reflect.Ptr && reflect.ValueOf(document).IsNil() {
+		return m
+	}
+
+	data, err := bson.Marshal(document)
+	if err != nil {
+		logrus.Errorln(err)
+
+		return m
+	}
+
+	if err := bson.Unmarshal(data, &m); err != nil {
+		logrus.Errorln(err)
+	}
+
+	return m
+}
+
+// EventFilter narrows down the audit log, From and To are inclusive.
+type EventFilter struct {
+	ActorID  *primitive.ObjectID
+	Action   string
+	EntryID  int
+	From     time.Time
+	To       time.Time
+	Page     int
+	PageSize int
+}
+
+func (f *EventFilter) query() bson.D {
+	query := bson.D{}
+	if f == nil {
+		return query
+	}
+
+	if f.ActorID != nil {
+		query = append(query, bson.E{Key: "actor._id", Value: *f.ActorID})
+	}
+
+	if f.Action != "" {
+		query = append(query, bson.E{Key: "action", Value: f.Action})
+	}
+
+	if f.EntryID > 0 {
+		query = append(query, bson.E{Key: "entry_id", Value: f.EntryID})
+	}
+
+	if !f.From.IsZero() || !f.To.IsZero() {
+		at := bson.D{}
+
+		if !f.From.IsZero() {
+			at = append(at, bson.E{Key: "$gte", Value: f.From})
+		}
+
+		if !f.To.IsZero() {
+			at = append(at, bson.E{Key: "$lte", Value: f.To})
+		}
+
+		query = append(query, bson.E{Key: "at", Value: at})
+	}
+
+	return query
+}
+
+func (f *EventFilter) findOptions() *options.FindOptions {
+	opts := options.Find().SetSort(bson.D{{Key: "at", Value: -1}})
+	if f == nil || f.PageSize <= 0 {
+		return opts
+	}
+
+	page := f.Page
+	if page < 1 {
+		page = 1
+	}
+
+	return opts.SetSkip(int64((page - 1) * f.PageSize)).SetLimit(int64(f.PageSize))
+}
+
+func (r *repository) Record(ctx context.Context, event *Event) error {
+	if event.ID.IsZero() {
+		event.ID = primitive.NewObjectIDFromTimestamp(time.Now())
+	}
+
+	if event.At.IsZero() {
+		event.At = time.Now()
+	}
+
+	if event.Changes == nil {
+		event.Changes = make([]*Change, 0)
+	}
+
+	if err := r.mongo.InsertOne(ctx, "audit", event); err != nil {
+		logrus.Errorln(err)
+
+		return err
+	}
+
+	return nil
+}
+
+func (r *repository) GetEvents(ctx context.Context, filter *EventFilter) ([]*Event, error) {
+	cur, err := r.mongo.Find(ctx, "audit", filter.query(), filter.findOptions())
+	if err != nil {
+		return nil, err
+	}
+
+	events := make([]*Event, 0)
+	if err := cur.All(ctx, &events); err != nil {
+		logrus.Errorln(err)
+		return nil, err
+	}
+
+	return events, nil
+}
+
+func (r *repository) CountEvents(ctx context.Context, filter *EventFilter) (int64, error) {
+	return r.mongo.Count(ctx, "audit", filter.query())
+}
@@ -0,0 +1,189 @@
+package cities
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/YusufOzmen01/veri-kontrol-backend/core/sources"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type Repository interface {
+	GetCities(ctx context.Context) ([]*City, error)
+	GetCity(ctx context.Context, id int) (*City, error)
+	AddCity(ctx context.Context, city *City) error
+	UpdateCity(ctx context.Context, city *City) error
+	DeleteCity(ctx context.Context, id int) error
+	EnsureDefaults(ctx context.Context) error
+}
+
+var ErrCityExists = errors.New("city already exists")
+
+type repository struct {
+	mongo sources.MongoClient
+}
+
+func NewRepository(mongo sources.MongoClient) Repository {
+	return &repository{
+		mongo: mongo,
+	}
+}
+
+// City is an affected region, Polygon holds its vertices as [lat, lng] pairs.
+type City struct {
+	ID      int         `json:"id" bson:"_id"`
+	Name    string      `json:"name" bson:"name"`
+	Polygon [][]float64 `json:"polygon" bson:"polygon"`
+}
+
+// defaultBoxes are the regions the app shipped with, stored as max lat, max lng, min lat, min lng.
+var defaultBoxes = map[int][]float64{
+	1:  {36.852702785393014, 36.87286376953126, 36.535570922786015, 35.88409423828126},
+	2:  {36.2104851748389, 36.81861877441407, 35.84286468375614, 35.82984924316407},
+	3:  {36.495937096205274, 36.649870522206335, 36.064120488812605, 35.4740187605459},
+	4:  {36.50903585150776, 36.402143998719424, 36.47976138594277, 36.31474829364722},
+	5:  {36.64234742932176, 36.3232450328562, 36.53629731173617, 36.029282092441115},
+	6:  {36.116001873480265, 36.06470054394251, 36.0627178139989, 35.91771907373497},
+	7:  {38.53348725642158, 38.78062516773912, 37.32756763881127, 35.45481415037825},
+	8:  {37.35461473302187, 38.0755896764663, 36.85431769725969, 36.67725839531126},
+	9:  {39.065058845523424, 40.013647871307754, 37.86798402826048, 36.687836853946884},
+	10: {38.160827052916495, 39.33362355320935, 37.44250898099215, 37.35608449070936},
+}
+
+func BoxPolygon(box []float64) [][]float64 {
+	return [][]float64{
+		{box[2], box[3]},
+		{box[0], box[3]},
+		{box[0], box[1]},
+		{box[2], box[1]},
+	}
+}
+
+func (c *City) Validate() error {
+	if c.ID <= 0 {
+		return fmt.Errorf("city id must be positive")
+	}
+
+	if c.Name == "" {
+		return fmt.Errorf("city name is required")
+	}
+
+	if len(c.Polygon) < 3 {
+		return fmt.Errorf("city polygon needs at least 3 points")
+	}
+
+	for _, point := range c.Polygon {
+		if len(point) != 2 {
+			return fmt.Errorf("city polygon points must be [lat, lng] pairs")
+		}
+	}
+
+	return nil
+}
+
+// Contains checks whether the point is inside the polygon with ray casting.
+func (c *City) Contains(lat, lng float64) bool {
+	inside := false
+
+	for i, j := 0, len(c.Polygon)-1; i < len(c.Polygon); j, i = i, i+1 {
+		a, b := c.Polygon[i], c.Polygon[j]
+
+		if (a[1] > lng) != (b[1] > lng) && lat < (b[0]-a[0])*(lng-a[1])/(b[1]-a[1])+a[0] {
+			inside = !inside
+		}
+	}
+
+	return inside
+}
+
+func (r *repository) GetCities(ctx context.Context) ([]*City, error) {
+	cur, err := r.mongo.Find(ctx, "cities", bson.D{}, options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+
+	cities := make([]*City, 0)
+	if err := cur.All(ctx, &cities); err != nil {
+		logrus.Errorln(err)
+		return nil, err
+	}
+
+	return cities, nil
+}
+
+func (r *repository) GetCity(ctx context.Context, id int) (*City, error) {
+	city := &City{}
+	if err := r.mongo.FindOne(ctx, "cities", bson.D{{
+		Key:   "_id",
+		Value: id,
+	}}).Decode(city); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, fmt.Errorf("city not found")
+		}
+
+		return nil, err
+	}
+
+	return city, nil
+}
+
+func (r *repository) AddCity(ctx context.Context, city *City) error {
+	if err := r.mongo.InsertOne(ctx, "cities", city); err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return ErrCityExists
+		}
+
+		logrus.Errorln(err)
+
+		return err
+	}
+
+	return nil
+}
+
+func (r *repository) UpdateCity(ctx context.Context, city *City) error {
+	return r.mongo.UpdateOne(ctx, "cities", bson.D{{
+		Key:   "_id",
+		Value: city.ID,
+	}}, bson.D{{
+		Key: "$set",
+		Value: bson.D{
+			{Key: "name", Value: city.Name},
+			{Key: "polygon", Value: city.Polygon},
+		},
+	}})
+}
+
+func (r *repository) DeleteCity(ctx context.Context, id int) error {
+	return r.mongo.DeleteOne(ctx, "cities", bson.D{{
+		Key:   "_id",
+		Value: id,
+	}})
+}
+
+// EnsureDefaults seeds the collection with the original regions on the first startup.
+func (r *repository) EnsureDefaults(ctx context.Context) error {
+	count, err := r.mongo.Count(ctx, "cities", bson.D{})
+	if err != nil {
+		return err
+	}
+
+	if count > 0 {
+		return nil
+	}
+
+	cities := make([]interface{}, 0, len(defaultBoxes))
+	for id, box := range defaultBoxes {
+		cities = append(cities, &City{
+			ID:      id,
+			Name:    fmt.Sprintf("Bölge %d", id),
+			Polygon: BoxPolygon(box),
+		})
+	}
+
+	return r.mongo.InsertMany(ctx, "cities", cities)
+}
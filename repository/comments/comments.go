@@ -0,0 +1,83 @@
+package comments
+
+import (
+	"context"
+	"time"
+
+	"github.com/YusufOzmen01/veri-kontrol-backend/core/sources"
+	"github.com/YusufOzmen01/veri-kontrol-backend/repository/users"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type Repository interface {
+	AddComment(ctx context.Context, comment *Comment) error
+	GetComments(ctx context.Context, entryID int) ([]*Comment, error)
+}
+
+type repository struct {
+	mongo sources.MongoClient
+}
+
+func NewRepository(mongo sources.MongoClient) Repository {
+	r := &repository{
+		mongo: mongo,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	r.ensureIndexes(ctx)
+
+	return r
+}
+
+func (r *repository) ensureIndexes(ctx context.Context) {
+	if _, err := r.mongo.CreateIndex(ctx, "entry_comments", bson.E{Key: "entry_id", Value: 1}, bson.E{Key: "_id", Value: 1}); err != nil {
+		logrus.Errorf("Couldn't create entry_comments entry_id index: %s", err)
+	}
+}
+
+// Comment is an internal note of a moderator on an entry, like a call that went unanswered. The entry doesn't
+// have to be resolved yet.
+type Comment struct {
+	ID        primitive.ObjectID `json:"_id" bson:"_id"`
+	EntryID   int                `json:"entry_id" bson:"entry_id"`
+	Author    *users.User        `json:"author" bson:"author"`
+	Text      string             `json:"text" bson:"text"`
+	CreatedAt time.Time          `json:"created_at" bson:"created_at"`
+}
+
+func (r *repository) AddComment(ctx context.Context, comment *Comment) error {
+	comment.ID = primitive.NewObjectIDFromTimestamp(time.Now())
+	comment.CreatedAt = time.Now()
+
+	if err := r.mongo.InsertOne(ctx, "entry_comments", comment); err != nil {
+		logrus.Errorln(err)
+
+		return err
+	}
+
+	return nil
+}
+
+// GetComments returns the comments of an entry oldest first, so they read like a conversation.
+func (r *repository) GetComments(ctx context.Context, entryID int) ([]*Comment, error) {
+	cur, err := r.mongo.Find(ctx, "entry_comments", bson.D{{
+		Key:   "entry_id",
+		Value: entryID,
+	}}, options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+
+	comments := make([]*Comment, 0)
+	if err := cur.All(ctx, &comments); err != nil {
+		logrus.Errorln(err)
+		return nil, err
+	}
+
+	return comments, nil
+}
@@ -0,0 +1,222 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/YusufOzmen01/veri-kontrol-backend/core/sources"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type Repository interface {
+	GetEvents(ctx context.Context) ([]*Event, error)
+	GetEvent(ctx context.Context, id string) (*Event, error)
+	GetActiveEvent(ctx context.Context) (*Event, error)
+	AddEvent(ctx context.Context, event *Event) error
+	UpdateEvent(ctx context.Context, event *Event) error
+	DeleteEvent(ctx context.Context, id string) error
+	Activate(ctx context.Context, id string) error
+	EnsureDefault(ctx context.Context) error
+}
+
+var (
+	ErrEventExists   = errors.New("event already exists")
+	ErrEventNotFound = errors.New("event not found")
+	ErrNoActiveEvent = errors.New("no event is active")
+	// ErrActivationConflict is returned when another event was activated at the same time and won.
+	ErrActivationConflict = errors.New("another event was activated at the same time")
+)
+
+type repository struct {
+	mongo sources.MongoClient
+}
+
+func NewRepository(mongo sources.MongoClient) Repository {
+	r := &repository{
+		mongo: mongo,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	r.ensureIndexes(ctx)
+
+	return r
+}
+
+// ensureIndexes makes sure at most one event is active, it fails while a database already has several.
+func (r *repository) ensureIndexes(ctx context.Context) {
+	if _, err := r.mongo.CreateIndexWithOptions(ctx, "events", options.Index().
+		SetName("active_unique").
+		SetUnique(true).
+		SetPartialFilterExpression(bson.D{{Key: "active", Value: true}}), bson.E{Key: "active", Value: 1}); err != nil {
+		logrus.Errorf("Couldn't create events active index: %s", err)
+	}
+}
+
+// Event is a disaster the entries belong to. Only one is active at a time, it is what the feed is tagged with
+// and what the endpoints show when no event_id is asked for.
+type Event struct {
+	ID        string    `json:"id" bson:"_id"`
+	Name      string    `json:"name" bson:"name"`
+	StartedAt time.Time `json:"started_at" bson:"started_at"`
+	Active    bool      `json:"active" bson:"active"`
+	CreatedAt time.Time `json:"created_at" bson:"created_at"`
+}
+
+// DefaultEvent is the disaster the backend was built for, everything stored before events existed belongs to it.
+var DefaultEvent = &Event{
+	ID:        "2023-kahramanmaras",
+	Name:      "6 Şubat 2023 Kahramanmaraş depremleri",
+	StartedAt: time.Date(2023, time.February, 6, 1, 17, 0, 0, time.FixedZone("TRT", 3*60*60)),
+}
+
+var idPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,63}$`)
+
+func (e *Event) Validate() error {
+	if !idPattern.MatchString(e.ID) {
+		return fmt.Errorf("event id must be lowercase letters, digits and dashes, at most 64 characters")
+	}
+
+	if e.Name == "" {
+		return fmt.Errorf("event name is required")
+	}
+
+	return nil
+}
+
+func (r *repository) GetEvents(ctx context.Context) ([]*Event, error) {
+	cur, err := r.mongo.Find(ctx, "events", bson.D{}, options.Find().SetSort(bson.D{{Key: "started_at", Value: -1}}))
+	if err != nil {
+		return nil, err
+	}
+
+	events := make([]*Event, 0)
+	if err := cur.All(ctx, &events); err != nil {
+		logrus.Errorln(err)
+		return nil, err
+	}
+
+	return events, nil
+}
+
+func (r *repository) findOne(ctx context.Context, filter bson.D, notFound error) (*Event, error) {
+	event := &Event{}
+	if err := r.mongo.FindOne(ctx, "events", filter).Decode(event); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, notFound
+		}
+
+		return nil, err
+	}
+
+	return event, nil
+}
+
+func (r *repository) GetEvent(ctx context.Context, id string) (*Event, error) {
+	return r.findOne(ctx, bson.D{{Key: "_id", Value: id}}, ErrEventNotFound)
+}
+
+func (r *repository) GetActiveEvent(ctx context.Context) (*Event, error) {
+	return r.findOne(ctx, bson.D{{Key: "active", Value: true}}, ErrNoActiveEvent)
+}
+
+// AddEvent never activates the event, that is a separate step so the feed doesn't switch by accident.
+func (r *repository) AddEvent(ctx context.Context, event *Event) error {
+	event.Active = false
+	event.CreatedAt = time.Now()
+
+	if err := r.mongo.InsertOne(ctx, "events", event); err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return ErrEventExists
+		}
+
+		logrus.Errorln(err)
+
+		return err
+	}
+
+	return nil
+}
+
+func (r *repository) UpdateEvent(ctx context.Context, event *Event) error {
+	return r.mongo.UpdateOne(ctx, "events", bson.D{{
+		Key:   "_id",
+		Value: event.ID,
+	}}, bson.D{{
+		Key: "$set",
+		Value: bson.D{
+			{Key: "name", Value: event.Name},
+			{Key: "started_at", Value: event.StartedAt},
+		},
+	}})
+}
+
+func (r *repository) DeleteEvent(ctx context.Context, id string) error {
+	return r.mongo.DeleteOne(ctx, "events", bson.D{{
+		Key:   "_id",
+		Value: id,
+	}})
+}
+
+// Activate makes the event the active one and deactivates the previous, in one transaction on a replica set.
+// On a standalone server there is a moment without an active event in between, and of two activations which
+// run at once the later one fails with ErrActivationConflict, the unique index on active only lets one win.
+func (r *repository) Activate(ctx context.Context, id string) error {
+	if _, err := r.GetEvent(ctx, id); err != nil {
+		return err
+	}
+
+	err := r.mongo.Transaction(ctx, func(ctx context.Context) error {
+		if err := r.mongo.UpdateMany(ctx, "events", bson.D{
+			{Key: "active", Value: true},
+			{Key: "_id", Value: bson.D{{Key: "$ne", Value: id}}},
+		}, bson.D{{
+			Key:   "$set",
+			Value: bson.D{{Key: "active", Value: false}},
+		}}); err != nil {
+			return err
+		}
+
+		return r.mongo.UpdateOne(ctx, "events", bson.D{{
+			Key:   "_id",
+			Value: id,
+		}}, bson.D{{
+			Key:   "$set",
+			Value: bson.D{{Key: "active", Value: true}},
+		}})
+	})
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrActivationConflict
+	}
+
+	if err != nil {
+		logrus.Errorln(err)
+	}
+
+	return err
+}
+
+// EnsureDefault stores DefaultEvent as the active event on the first startup.
+func (r *repository) EnsureDefault(ctx context.Context) error {
+	count, err := r.mongo.Count(ctx, "events", bson.D{})
+	if err != nil {
+		return err
+	}
+
+	if count > 0 {
+		return nil
+	}
+
+	event := *DefaultEvent
+	event.Active = true
+	event.CreatedAt = time.Now()
+
+	return r.mongo.InsertOne(ctx, "events", &event)
+}
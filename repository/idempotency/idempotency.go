@@ -0,0 +1,150 @@
+package idempotency
+
+import (
+	"context"
+	"time"
+
+	"github.com/YusufOzmen01/veri-kontrol-backend/core/sources"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	// KeyTTL is how long a result is replayed, clients don't retry for longer than that.
+	KeyTTL = 24 * time.Hour
+	// LeaseTTL is how long a request holds its key before a retry may take it over, a replica which died
+	// halfway through would otherwise leave the key in progress for a whole day.
+	LeaseTTL = time.Minute
+)
+
+type Repository interface {
+	Reserve(ctx context.Context, key, requestHash string) (*Record, bool, error)
+	Complete(ctx context.Context, record *Record) error
+	Release(ctx context.Context, record *Record) error
+}
+
+type repository struct {
+	mongo sources.MongoClient
+}
+
+func NewRepository(mongo sources.MongoClient) Repository {
+	r := &repository{
+		mongo: mongo,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	r.ensureIndexes(ctx)
+
+	return r
+}
+
+func (r *repository) ensureIndexes(ctx context.Context) {
+	if _, err := r.mongo.CreateIndexWithOptions(ctx, "idempotency_keys", options.Index().SetExpireAfterSeconds(0), bson.E{Key: "expires_at", Value: 1}); err != nil {
+		logrus.Errorf("Couldn't create idempotency_keys expires_at index: %s", err)
+	}
+}
+
+// Record is the result of a request, stored under its key. Done is false while the first request is still
+// running, the hash tells a retry apart from a different request reusing the key. LeaseID names the request
+// holding the key, only that one may complete or release it.
+type Record struct {
+	Key         string    `bson:"_id"`
+	RequestHash string    `bson:"request_hash"`
+	Done        bool      `bson:"done"`
+	LeaseID     string    `bson:"lease_id"`
+	LeasedUntil time.Time `bson:"leased_until"`
+	Status      int       `bson:"status,omitempty"`
+	ContentType string    `bson:"content_type,omitempty"`
+	Body        []byte    `bson:"body,omitempty"`
+	ExpiresAt   time.Time `bson:"expires_at"`
+}
+
+// Reserve claims the key for a new request. A retry of a request whose lease ran out without completing takes
+// the key over. When the key is otherwise taken, the stored record is returned instead and reserved is false.
+func (r *repository) Reserve(ctx context.Context, key, requestHash string) (*Record, bool, error) {
+	now := time.Now()
+	record := &Record{
+		Key:         key,
+		RequestHash: requestHash,
+		LeaseID:     primitive.NewObjectID().Hex(),
+		LeasedUntil: now.Add(LeaseTTL),
+		ExpiresAt:   now.Add(KeyTTL),
+	}
+
+	err := r.mongo.InsertOne(ctx, "idempotency_keys", record)
+	if err == nil {
+		return record, true, nil
+	}
+
+	if !mongo.IsDuplicateKeyError(err) {
+		logrus.Errorln(err)
+
+		return nil, false, err
+	}
+
+	err = r.mongo.FindOneAndUpdate(ctx, "idempotency_keys", bson.D{
+		{Key: "_id", Value: key},
+		{Key: "request_hash", Value: requestHash},
+		{Key: "done", Value: false},
+		{Key: "leased_until", Value: bson.D{{Key: "$lt", Value: now}}},
+	}, bson.D{{
+		Key: "$set",
+		Value: bson.D{
+			{Key: "lease_id", Value: record.LeaseID},
+			{Key: "leased_until", Value: record.LeasedUntil},
+			{Key: "expires_at", Value: record.ExpiresAt},
+		},
+	}}).Err()
+	if err == nil {
+		return record, true, nil
+	}
+
+	if err != mongo.ErrNoDocuments {
+		return nil, false, err
+	}
+
+	existing := &Record{}
+	if err := r.mongo.FindOne(ctx, "idempotency_keys", bson.D{{
+		Key:   "_id",
+		Value: key,
+	}}).Decode(existing); err != nil {
+		// The TTL monitor removed it in between, the caller can simply retry.
+		if err == mongo.ErrNoDocuments {
+			return r.Reserve(ctx, key, requestHash)
+		}
+
+		return nil, false, err
+	}
+
+	return existing, false, nil
+}
+
+// Complete stores the result, unless a retry took the key over in the meantime.
+func (r *repository) Complete(ctx context.Context, record *Record) error {
+	return r.mongo.UpdateOne(ctx, "idempotency_keys", bson.D{
+		{Key: "_id", Value: record.Key},
+		{Key: "lease_id", Value: record.LeaseID},
+	}, bson.D{{
+		Key: "$set",
+		Value: bson.D{
+			{Key: "done", Value: true},
+			{Key: "status", Value: record.Status},
+			{Key: "content_type", Value: record.ContentType},
+			{Key: "body", Value: record.Body},
+		},
+	}})
+}
+
+// Release frees a key whose request failed on our side, so the retry gets another chance. A key a retry took
+// over in the meantime stays with the retry.
+func (r *repository) Release(ctx context.Context, record *Record) error {
+	return r.mongo.DeleteOne(ctx, "idempotency_keys", bson.D{
+		{Key: "_id", Value: record.Key},
+		{Key: "lease_id", Value: record.LeaseID},
+	})
+}
@@ -0,0 +1,112 @@
+package invalidations
+
+import (
+	"context"
+	"time"
+
+	"github.com/YusufOzmen01/veri-kontrol-backend/core/sources"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// KeepFor is how long an invalidation is kept, longer than any text stays cached.
+const KeepFor = 7 * 24 * time.Hour
+
+type Repository interface {
+	Add(ctx context.Context, entryID int) (*Invalidation, error)
+	GetSince(ctx context.Context, since time.Time) ([]*Invalidation, error)
+	GetLastFeed(ctx context.Context) (*Invalidation, error)
+}
+
+type repository struct {
+	mongo sources.MongoClient
+}
+
+func NewRepository(mongo sources.MongoClient) Repository {
+	r := &repository{
+		mongo: mongo,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	r.ensureIndexes(ctx)
+
+	return r
+}
+
+func (r *repository) ensureIndexes(ctx context.Context) {
+	if _, err := r.mongo.CreateIndex(ctx, "cache_invalidations", bson.E{Key: "at", Value: 1}); err != nil {
+		logrus.Errorf("Couldn't create cache_invalidations at index: %s", err)
+	}
+
+	if _, err := r.mongo.CreateIndexWithOptions(ctx, "cache_invalidations", options.Index().SetExpireAfterSeconds(0), bson.E{Key: "expires_at", Value: 1}); err != nil {
+		logrus.Errorf("Couldn't create cache_invalidations expires_at index: %s", err)
+	}
+}
+
+// Invalidation is a cache invalidation made on one replica, the others poll for it and drop the same entries
+// from their own cache. EntryID 0 stands for the feed and every text.
+type Invalidation struct {
+	ID        primitive.ObjectID `bson:"_id"`
+	EntryID   int                `bson:"entry_id"`
+	At        time.Time          `bson:"at"`
+	ExpiresAt time.Time          `bson:"expires_at"`
+}
+
+func (r *repository) Add(ctx context.Context, entryID int) (*Invalidation, error) {
+	now := time.Now()
+	invalidation := &Invalidation{
+		ID:        primitive.NewObjectIDFromTimestamp(now),
+		EntryID:   entryID,
+		At:        now,
+		ExpiresAt: now.Add(KeepFor),
+	}
+
+	if err := r.mongo.InsertOne(ctx, "cache_invalidations", invalidation); err != nil {
+		logrus.Errorln(err)
+
+		return nil, err
+	}
+
+	return invalidation, nil
+}
+
+// GetSince returns the invalidations made at or after since, oldest first.
+func (r *repository) GetSince(ctx context.Context, since time.Time) ([]*Invalidation, error) {
+	cur, err := r.mongo.Find(ctx, "cache_invalidations", bson.D{{
+		Key:   "at",
+		Value: bson.D{{Key: "$gte", Value: since}},
+	}}, options.Find().SetSort(bson.D{{Key: "at", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+
+	invalidations := make([]*Invalidation, 0)
+	if err := cur.All(ctx, &invalidations); err != nil {
+		logrus.Errorln(err)
+		return nil, err
+	}
+
+	return invalidations, nil
+}
+
+// GetLastFeed returns the latest invalidation of the whole feed, nil when there is none.
+func (r *repository) GetLastFeed(ctx context.Context) (*Invalidation, error) {
+	invalidation := &Invalidation{}
+	if err := r.mongo.FindOne(ctx, "cache_invalidations", bson.D{{
+		Key:   "entry_id",
+		Value: 0,
+	}}, options.FindOne().SetSort(bson.D{{Key: "at", Value: -1}})).Decode(invalidation); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	return invalidation, nil
+}
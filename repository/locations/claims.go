@@ -0,0 +1,318 @@
+package locations
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const DefaultClaimDuration = 10 * time.Minute
+
+// ErrTooManyClaims is returned when a new claim would take the owner over the limit, the claim isn't kept.
+var ErrTooManyClaims = errors.New("owner holds too many claims")
+
+// How a claim ended, claims which are taken over by someone else after running out count as expired.
+const (
+	ClaimResolved = "resolved"
+	ClaimReleased = "released"
+	ClaimExpired  = "expired"
+)
+
+// claimLogTTL is how long ended claims are kept for the statistics.
+const claimLogTTL = 90 * 24 * time.Hour
+
+type Claim struct {
+	EntryID   int       `json:"entry_id" bson:"entry_id"`
+	Owner     string    `json:"owner" bson:"owner"`
+	ClaimedAt time.Time `json:"claimed_at" bson:"claimed_at"`
+	ExpiresAt time.Time `json:"expires_at" bson:"expires_at"`
+}
+
+// ClaimLog is a claim which ended. Seconds is how long it was held, it is missing for claims taken before
+// claimed_at was stored.
+type ClaimLog struct {
+	EntryID int       `bson:"entry_id"`
+	Owner   string    `bson:"owner"`
+	Outcome string    `bson:"outcome"`
+	EndedAt time.Time `bson:"ended_at"`
+	Seconds *float64  `bson:"seconds,omitempty"`
+}
+
+// ClaimStats sums up the claims of an owner which ended, AvgResolveSeconds only counts the resolved ones.
+type ClaimStats struct {
+	Owner             string  `json:"owner" bson:"_id"`
+	Claims            int     `json:"claims" bson:"claims"`
+	Resolved          int     `json:"resolved" bson:"resolved"`
+	Released          int     `json:"released" bson:"released"`
+	Expired           int     `json:"expired" bson:"expired"`
+	AvgResolveSeconds float64 `json:"avg_resolve_seconds" bson:"avg_resolve_seconds"`
+}
+
+func (r *repository) ensureClaimIndexes(ctx context.Context) {
+	if _, err := r.mongo.CreateIndexWithOptions(ctx, "claims", options.Index().SetUnique(true), bson.E{Key: "entry_id", Value: 1}); err != nil {
+		logrus.Errorf("Couldn't create claims entry_id index: %s", err)
+	}
+
+	// The TTL index removed expired claims before the reaper could log them, the reaper removes them now.
+	if err := r.mongo.DropIndex(ctx, "claims", "expires_at_1"); err != nil {
+		logrus.Errorf("Couldn't drop claims expires_at_1 index: %s", err)
+	}
+
+	if _, err := r.mongo.CreateIndex(ctx, "claims", bson.E{Key: "expires_at", Value: 1}, bson.E{Key: "owner", Value: 1}); err != nil {
+		logrus.Errorf("Couldn't create claims expires_at index: %s", err)
+	}
+
+	if _, err := r.mongo.CreateIndexWithOptions(ctx, "claim_log", options.Index().SetExpireAfterSeconds(int32(claimLogTTL.Seconds())), bson.E{Key: "ended_at", Value: 1}); err != nil {
+		logrus.Errorf("Couldn't create claim_log ended_at index: %s", err)
+	}
+}
+
+// logClaims records how the claims ended. The statistics aren't worth failing a request over, errors are only
+// logged.
+func (r *repository) logClaims(ctx context.Context, outcome string, now time.Time, claims ...*Claim) {
+	if len(claims) == 0 {
+		return
+	}
+
+	documents := make([]interface{}, 0, len(claims))
+	for _, claim := range claims {
+		entry := &ClaimLog{
+			EntryID: claim.EntryID,
+			Owner:   claim.Owner,
+			Outcome: outcome,
+			EndedAt: now,
+		}
+
+		if !claim.ClaimedAt.IsZero() {
+			seconds := now.Sub(claim.ClaimedAt).Seconds()
+			entry.Seconds = &seconds
+		}
+
+		documents = append(documents, entry)
+	}
+
+	if err := r.mongo.InsertMany(ctx, "claim_log", documents); err != nil {
+		logrus.Errorf("Couldn't log %d %s claims: %s", len(claims), outcome, err)
+	}
+}
+
+// ClaimLocation claims the entry for owner, or extends the claim owner already holds. A positive max limits
+// how many claims owner may hold, a new claim over it is given up again and ErrTooManyClaims returned.
+func (r *repository) ClaimLocation(ctx context.Context, entryID int, owner string, duration time.Duration, max int) (bool, error) {
+	now := time.Now()
+
+	// The filter only matches when the entry is free or already ours, otherwise the upsert collides with the unique index.
+	// Extending our own claim keeps the time it was first taken, the update is a pipeline to compare with the old owner.
+	previous := &Claim{}
+	err := r.mongo.FindOneAndUpdate(ctx, "claims", bson.D{
+		{Key: "entry_id", Value: entryID},
+		{Key: "$or", Value: bson.A{
+			bson.D{{Key: "owner", Value: owner}},
+			bson.D{{Key: "expires_at", Value: bson.D{{Key: "$lte", Value: now}}}},
+		}},
+	}, bson.A{bson.D{{
+		Key: "$set",
+		Value: bson.D{
+			{Key: "claimed_at", Value: bson.D{{Key: "$cond", Value: bson.A{
+				bson.D{{Key: "$eq", Value: bson.A{"$owner", owner}}},
+				bson.D{{Key: "$ifNull", Value: bson.A{"$claimed_at", now}}},
+				now,
+			}}}},
+			{Key: "owner", Value: owner},
+			{Key: "expires_at", Value: now.Add(duration)},
+		},
+	}}}, options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.Before)).Decode(previous)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		previous = nil
+	} else if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return false, nil
+		}
+
+		logrus.Errorln(err)
+
+		return false, err
+	}
+
+	// Taking over a claim which ran out ends it, the reaper won't see it anymore.
+	if previous != nil && previous.Owner != owner {
+		r.logClaims(ctx, ClaimExpired, previous.ExpiresAt, previous)
+	}
+
+	extended := previous != nil && previous.Owner == owner && previous.ExpiresAt.After(now)
+	if max <= 0 || extended {
+		return true, nil
+	}
+
+	held, err := r.mongo.Count(ctx, "claims", bson.D{
+		{Key: "owner", Value: owner},
+		{Key: "expires_at", Value: bson.D{{Key: "$gt", Value: now}}},
+	})
+	if err != nil {
+		logrus.Errorln(err)
+
+		return false, err
+	}
+
+	if held <= int64(max) {
+		return true, nil
+	}
+
+	// Requests of the same owner running at once may all claim before they count, each gives its claim up.
+	if err := r.mongo.DeleteOne(ctx, "claims", bson.D{
+		{Key: "entry_id", Value: entryID},
+		{Key: "owner", Value: owner},
+	}); err != nil {
+		logrus.Errorln(err)
+
+		return false, err
+	}
+
+	return false, ErrTooManyClaims
+}
+
+// ReleaseLocation ends the claim of owner on the entry, if there is one, and logs it with the given outcome.
+func (r *repository) ReleaseLocation(ctx context.Context, entryID int, owner string, outcome string) error {
+	claim := &Claim{}
+	if err := r.mongo.FindOneAndDelete(ctx, "claims", bson.D{
+		{Key: "entry_id", Value: entryID},
+		{Key: "owner", Value: owner},
+	}).Decode(claim); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil
+		}
+
+		logrus.Errorln(err)
+
+		return err
+	}
+
+	r.logClaims(ctx, outcome, time.Now(), claim)
+
+	return nil
+}
+
+func (r *repository) IsClaimed(ctx context.Context, entryID int, owner string) (bool, error) {
+	return r.mongo.DoesExist(ctx, "claims", bson.D{
+		{Key: "entry_id", Value: entryID},
+		{Key: "owner", Value: bson.D{{Key: "$ne", Value: owner}}},
+		{Key: "expires_at", Value: bson.D{{Key: "$gt", Value: time.Now()}}},
+	})
+}
+
+func (r *repository) GetClaimedIDs(ctx context.Context, owner string) ([]int, error) {
+	cur, err := r.mongo.Find(ctx, "claims", bson.D{
+		{Key: "owner", Value: bson.D{{Key: "$ne", Value: owner}}},
+		{Key: "expires_at", Value: bson.D{{Key: "$gt", Value: time.Now()}}},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	claims := make([]*Claim, 0)
+	if err := cur.All(ctx, &claims); err != nil {
+		logrus.Errorln(err)
+		return nil, err
+	}
+
+	ids := make([]int, 0, len(claims))
+	for _, claim := range claims {
+		ids = append(ids, claim.EntryID)
+	}
+
+	return ids, nil
+}
+
+// GetOwnedClaimIDs returns the entries owner holds right now.
+func (r *repository) GetOwnedClaimIDs(ctx context.Context, owner string) ([]int, error) {
+	cur, err := r.mongo.Find(ctx, "claims", bson.D{
+		{Key: "owner", Value: owner},
+		{Key: "expires_at", Value: bson.D{{Key: "$gt", Value: time.Now()}}},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	claims := make([]*Claim, 0)
+	if err := cur.All(ctx, &claims); err != nil {
+		logrus.Errorln(err)
+		return nil, err
+	}
+
+	ids := make([]int, 0, len(claims))
+	for _, claim := range claims {
+		ids = append(ids, claim.EntryID)
+	}
+
+	return ids, nil
+}
+
+// GetClaimStats sums up the claims which ended since the given time by owner.
+func (r *repository) GetClaimStats(ctx context.Context, since time.Time) ([]*ClaimStats, error) {
+	outcome := func(name string) bson.D {
+		return bson.D{{Key: "$sum", Value: bson.D{{Key: "$cond", Value: bson.A{
+			bson.D{{Key: "$eq", Value: bson.A{"$outcome", name}}}, 1, 0,
+		}}}}}
+	}
+
+	cur, err := r.mongo.Aggregate(ctx, "claim_log", bson.A{
+		bson.D{{Key: "$match", Value: bson.D{{Key: "ended_at", Value: bson.D{{Key: "$gte", Value: since}}}}}},
+		bson.D{{Key: "$group", Value: bson.D{
+			{Key: "_id", Value: "$owner"},
+			{Key: "claims", Value: bson.D{{Key: "$sum", Value: 1}}},
+			{Key: "resolved", Value: outcome(ClaimResolved)},
+			{Key: "released", Value: outcome(ClaimReleased)},
+			{Key: "expired", Value: outcome(ClaimExpired)},
+			// $avg skips the nulls of the other outcomes.
+			{Key: "avg_resolve_seconds", Value: bson.D{{Key: "$avg", Value: bson.D{{Key: "$cond", Value: bson.A{
+				bson.D{{Key: "$eq", Value: bson.A{"$outcome", ClaimResolved}}}, "$seconds", nil,
+			}}}}}},
+		}}},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	stats := make([]*ClaimStats, 0)
+	if err := cur.All(ctx, &stats); err != nil {
+		logrus.Errorln(err)
+		return nil, err
+	}
+
+	return stats, nil
+}
+
+// CountActiveClaims returns how many entries every owner holds right now.
+func (r *repository) CountActiveClaims(ctx context.Context) (map[string]int, error) {
+	cur, err := r.mongo.Aggregate(ctx, "claims", bson.A{
+		bson.D{{Key: "$match", Value: bson.D{{Key: "expires_at", Value: bson.D{{Key: "$gt", Value: time.Now()}}}}}},
+		bson.D{{Key: "$group", Value: bson.D{
+			{Key: "_id", Value: "$owner"},
+			{Key: "count", Value: bson.D{{Key: "$sum", Value: 1}}},
+		}}},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var counts []struct {
+		Owner string `bson:"_id"`
+		Count int    `bson:"count"`
+	}
+	if err := cur.All(ctx, &counts); err != nil {
+		logrus.Errorln(err)
+		return nil, err
+	}
+
+	byOwner := make(map[string]int, len(counts))
+	for _, count := range counts {
+		byOwner[count.Owner] = count.Count
+	}
+
+	return byOwner, nil
+}
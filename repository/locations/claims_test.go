@@ -0,0 +1,72 @@
+package locations
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestClaimLimit(t *testing.T) {
+	ctx := context.Background()
+	repository := NewMemoryRepository()
+
+	for _, entryID := range []int{1, 2} {
+		if claimed, err := repository.ClaimLocation(ctx, entryID, "alice", time.Minute, 2); err != nil || !claimed {
+			t.Fatalf("ClaimLocation(%d) = %t, %v", entryID, claimed, err)
+		}
+	}
+
+	if claimed, err := repository.ClaimLocation(ctx, 3, "alice", time.Minute, 2); !errors.Is(err, ErrTooManyClaims) || claimed {
+		t.Fatalf("ClaimLocation(3) = %t, %v over the limit", claimed, err)
+	}
+
+	// Extending a claim doesn't take another slot.
+	if claimed, err := repository.ClaimLocation(ctx, 2, "alice", time.Minute, 2); err != nil || !claimed {
+		t.Fatalf("ClaimLocation(2) = %t, %v extending", claimed, err)
+	}
+
+	ids, err := repository.GetOwnedClaimIDs(ctx, "alice")
+	if err != nil || len(ids) != 2 {
+		t.Fatalf("GetOwnedClaimIDs() = %v, %v", ids, err)
+	}
+}
+
+func TestClaimTakeoverLogged(t *testing.T) {
+	ctx := context.Background()
+	repository := NewMemoryRepository()
+	since := time.Now().Add(-time.Hour)
+
+	if _, err := repository.ClaimLocation(ctx, 1, "alice", -time.Second, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	if claimed, err := repository.ClaimLocation(ctx, 1, "bob", time.Minute, 0); err != nil || !claimed {
+		t.Fatalf("ClaimLocation() = %t, %v after alice's claim ran out", claimed, err)
+	}
+
+	// Releasing twice only ends the claim once.
+	for i := 0; i < 2; i++ {
+		if err := repository.ReleaseLocation(ctx, 1, "bob", ClaimReleased); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	stats, err := repository.GetClaimStats(ctx, since)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	byOwner := make(map[string]*ClaimStats)
+	for _, owner := range stats {
+		byOwner[owner.Owner] = owner
+	}
+
+	if alice := byOwner["alice"]; alice == nil || alice.Expired != 1 || alice.Claims != 1 {
+		t.Errorf("alice = %+v, want one expired claim", alice)
+	}
+
+	if bob := byOwner["bob"]; bob == nil || bob.Released != 1 || bob.Claims != 1 {
+		t.Errorf("bob = %+v, want one released claim", bob)
+	}
+}
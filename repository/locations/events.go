@@ -0,0 +1,32 @@
+package locations
+
+import (
+	"context"
+
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func (r *repository) ensureEventIndexes(ctx context.Context) {
+	if _, err := r.mongo.CreateIndex(ctx, "locations", bson.E{Key: "event_id", Value: 1}, bson.E{Key: "_id", Value: 1}); err != nil {
+		logrus.Errorf("Couldn't create locations event_id index: %s", err)
+	}
+}
+
+// MigrateEvent tags the resolutions stored before events existed with the given event. Tagged ones are left
+// alone, so it is safe to run on every startup.
+func (r *repository) MigrateEvent(ctx context.Context, eventID string) error {
+	if err := r.mongo.UpdateMany(ctx, "locations", bson.D{{
+		Key:   "event_id",
+		Value: bson.D{{Key: "$exists", Value: false}},
+	}}, bson.D{{
+		Key:   "$set",
+		Value: bson.D{{Key: "event_id", Value: eventID}},
+	}}); err != nil {
+		logrus.Errorln(err)
+
+		return err
+	}
+
+	return nil
+}
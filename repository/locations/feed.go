@@ -0,0 +1,60 @@
+package locations
+
+import "sync"
+
+const subscriberBuffer = 64
+
+// feed fans out freshly written resolutions to subscribers. Slow subscribers miss events instead of
+// blocking the write path.
+type feed struct {
+	mu          sync.RWMutex
+	subscribers map[chan *LocationDB]struct{}
+}
+
+func newFeed() *feed {
+	return &feed{
+		subscribers: make(map[chan *LocationDB]struct{}),
+	}
+}
+
+func (f *feed) subscribe() (<-chan *LocationDB, func()) {
+	ch := make(chan *LocationDB, subscriberBuffer)
+
+	f.mu.Lock()
+	f.subscribers[ch] = struct{}{}
+	f.mu.Unlock()
+
+	var once sync.Once
+
+	return ch, func() {
+		once.Do(func() {
+			f.mu.Lock()
+			delete(f.subscribers, ch)
+			f.mu.Unlock()
+
+			close(ch)
+		})
+	}
+}
+
+func (f *feed) publish(location *LocationDB) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	for ch := range f.subscribers {
+		select {
+		case ch <- location:
+		default:
+		}
+	}
+}
+
+// Publish tells the subscribers about a resolution written in a transaction, after it was committed.
+func (r *repository) Publish(location *LocationDB) {
+	r.feed.publish(location)
+}
+
+// Subscribe returns new resolutions as they are written, the returned function must be called to unsubscribe.
+func (r *repository) Subscribe() (<-chan *LocationDB, func()) {
+	return r.feed.subscribe()
+}
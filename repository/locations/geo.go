@@ -0,0 +1,142 @@
+package locations
+
+import (
+	"context"
+	"time"
+
+	"github.com/YusufOzmen01/veri-kontrol-backend/core/geohash"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	// earthRadius in metres, $centerSphere takes its radius in radians.
+	earthRadius = 6378100
+
+	backfillTimeout = time.Hour
+)
+
+// GeoPoint is the GeoJSON form of Location for the 2dsphere index, note the [lng, lat] order.
+type GeoPoint struct {
+	Type        string    `json:"type" bson:"type"`
+	Coordinates []float64 `json:"coordinates" bson:"coordinates"`
+}
+
+// NewGeoPoint takes a [lat, lng] pair like the stored location, nil when the location is missing.
+func NewGeoPoint(location []float64) *GeoPoint {
+	if len(location) < 2 {
+		return nil
+	}
+
+	return &GeoPoint{
+		Type:        "Point",
+		Coordinates: []float64{location[1], location[0]},
+	}
+}
+
+// newGeohash takes a [lat, lng] pair like NewGeoPoint, empty when the location is missing.
+func newGeohash(location []float64) string {
+	if len(location) < 2 {
+		return ""
+	}
+
+	return geohash.Encode(location[0], location[1], geohash.Precision)
+}
+
+// Near matches the locations within RadiusMeters of the [lat, lng] point.
+type Near struct {
+	Lat          float64
+	Lng          float64
+	RadiusMeters float64
+}
+
+// geoPolygon turns [lat, lng] vertices into a closed GeoJSON polygon.
+func geoPolygon(points [][]float64) bson.D {
+	ring := make(bson.A, 0, len(points)+1)
+	for _, point := range points {
+		ring = append(ring, bson.A{point[1], point[0]})
+	}
+
+	if first, last := points[0], points[len(points)-1]; first[0] != last[0] || first[1] != last[1] {
+		ring = append(ring, bson.A{first[1], first[0]})
+	}
+
+	return bson.D{
+		{Key: "type", Value: "Polygon"},
+		{Key: "coordinates", Value: bson.A{ring}},
+	}
+}
+
+func (r *repository) ensureGeoIndexes(ctx context.Context) {
+	if _, err := r.mongo.CreateIndex(ctx, "locations", bson.E{Key: "point", Value: "2dsphere"}); err != nil {
+		logrus.Errorf("Couldn't create locations point index: %s", err)
+	}
+
+	if _, err := r.mongo.CreateIndex(ctx, "locations", bson.E{Key: "geohash", Value: 1}); err != nil {
+		logrus.Errorf("Couldn't create locations geohash index: %s", err)
+	}
+}
+
+// backfill runs in the background, it goes through every resolution stored before the fields existed and the
+// server shouldn't wait for that to start. Resolutions without them are only left out of the geo queries until
+// it is done.
+func (r *repository) backfill() {
+	ctx, cancel := context.WithTimeout(context.Background(), backfillTimeout)
+	defer cancel()
+
+	r.backfillPoints(ctx)
+	r.backfillGeohashes(ctx)
+}
+
+// backfillPoints adds the GeoJSON point to resolutions stored before it existed, later writes set it themselves.
+func (r *repository) backfillPoints(ctx context.Context) {
+	if err := r.mongo.UpdateMany(ctx, "locations", bson.D{
+		{Key: "point", Value: bson.D{{Key: "$exists", Value: false}}},
+		{Key: "location.1", Value: bson.D{{Key: "$exists", Value: true}}},
+	}, bson.A{bson.D{{Key: "$set", Value: bson.D{{Key: "point", Value: bson.D{
+		{Key: "type", Value: "Point"},
+		{Key: "coordinates", Value: bson.A{
+			bson.D{{Key: "$arrayElemAt", Value: bson.A{"$location", 1}}},
+			bson.D{{Key: "$arrayElemAt", Value: bson.A{"$location", 0}}},
+		}},
+	}}}}}}); err != nil {
+		logrus.Errorf("Couldn't backfill location points: %s", err)
+	}
+}
+
+// backfillGeohashes does the same for the geohash, Mongo can't compute one so every document is updated on its own.
+func (r *repository) backfillGeohashes(ctx context.Context) {
+	cur, err := r.mongo.Find(ctx, "locations", bson.D{
+		{Key: "geohash", Value: bson.D{{Key: "$exists", Value: false}}},
+		{Key: "location.1", Value: bson.D{{Key: "$exists", Value: true}}},
+	}, options.Find().SetProjection(bson.D{{Key: "entry_id", Value: 1}, {Key: "location", Value: 1}}))
+	if err != nil {
+		logrus.Errorf("Couldn't backfill location geohashes: %s", err)
+		return
+	}
+	defer cur.Close(ctx)
+
+	filled := 0
+	for cur.Next(ctx) {
+		location := new(LocationDB)
+		if err := cur.Decode(location); err != nil {
+			logrus.Errorln(err)
+			continue
+		}
+
+		if err := r.mongo.UpdateOne(ctx, "locations", bson.D{{Key: "entry_id", Value: location.EntryID}}, bson.D{{
+			Key:   "$set",
+			Value: bson.D{{Key: "geohash", Value: newGeohash(location.Location)}},
+		}}); err != nil {
+			logrus.Errorf("Couldn't backfill the geohash of entry %d: %s", location.EntryID, err)
+			continue
+		}
+
+		filled++
+	}
+
+	if filled > 0 {
+		logrus.Infof("Backfilled the geohash of %d locations", filled)
+	}
+}
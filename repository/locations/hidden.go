@@ -0,0 +1,86 @@
+package locations
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/YusufOzmen01/veri-kontrol-backend/repository/users"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var ErrEntryNotHidden = errors.New("entry is not hidden")
+
+// HiddenEntry takes a raw entry out of circulation without resolving it, restoring deletes the document.
+type HiddenEntry struct {
+	EntryID  int         `json:"entry_id" bson:"entry_id"`
+	HiddenBy *users.User `json:"hidden_by" bson:"hidden_by"`
+	HiddenAt time.Time   `json:"hidden_at" bson:"hidden_at"`
+}
+
+func (r *repository) ensureHiddenIndexes(ctx context.Context) {
+	if _, err := r.mongo.CreateIndexWithOptions(ctx, "hidden_entries", options.Index().SetUnique(true), bson.E{Key: "entry_id", Value: 1}); err != nil {
+		logrus.Errorf("Couldn't create hidden_entries entry_id index: %s", err)
+	}
+}
+
+// HideEntry is idempotent, hiding an entry twice keeps whoever hid it last.
+func (r *repository) HideEntry(ctx context.Context, entryID int, actor *users.User) error {
+	if err := r.mongo.UpsertOne(ctx, "hidden_entries", bson.D{{
+		Key:   "entry_id",
+		Value: entryID,
+	}}, bson.D{{
+		Key: "$set",
+		Value: bson.D{
+			{Key: "hidden_by", Value: actor},
+			{Key: "hidden_at", Value: time.Now()},
+		},
+	}}); err != nil {
+		logrus.Errorln(err)
+
+		return err
+	}
+
+	return nil
+}
+
+func (r *repository) RestoreEntry(ctx context.Context, entryID int) error {
+	filter := bson.D{{Key: "entry_id", Value: entryID}}
+
+	hidden, err := r.mongo.DoesExist(ctx, "hidden_entries", filter)
+	if err != nil {
+		return err
+	}
+
+	if !hidden {
+		return ErrEntryNotHidden
+	}
+
+	return r.mongo.DeleteOne(ctx, "hidden_entries", filter)
+}
+
+func (r *repository) IsHidden(ctx context.Context, entryID int) (bool, error) {
+	return r.mongo.DoesExist(ctx, "hidden_entries", bson.D{{Key: "entry_id", Value: entryID}})
+}
+
+func (r *repository) GetHiddenIDs(ctx context.Context) ([]int, error) {
+	cur, err := r.mongo.Find(ctx, "hidden_entries", bson.D{})
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]*HiddenEntry, 0)
+	if err := cur.All(ctx, &entries); err != nil {
+		logrus.Errorln(err)
+		return nil, err
+	}
+
+	ids := make([]int, 0, len(entries))
+	for _, entry := range entries {
+		ids = append(ids, entry.EntryID)
+	}
+
+	return ids, nil
+}
@@ -0,0 +1,124 @@
+package locations
+
+import (
+	"context"
+	"time"
+
+	"github.com/YusufOzmen01/veri-kontrol-backend/repository/users"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// SourceAfetHarita marks the entries of the upstream feed, the others come in through the intake endpoint.
+const (
+	SourceAfetHarita = "afetharita"
+	SourceCallCenter = "call_center"
+	SourceWhatsApp   = "whatsapp"
+	SourceWebForm    = "web_form"
+	SourceOther      = "other"
+)
+
+var IntakeSources = []string{SourceCallCenter, SourceWhatsApp, SourceWebForm, SourceOther}
+
+// IntakeIDOffset keeps intake entry IDs clear of the upstream ones.
+const IntakeIDOffset = 1_000_000_000
+
+const maxIntakeIDRetries = 5
+
+// IntakeEntry is a report which didn't come from the upstream feed, it is served like any other Location.
+type IntakeEntry struct {
+	EntryID     int         `json:"entry_id" bson:"entry_id"`
+	Source      string      `json:"source" bson:"source"`
+	Loc         []float64   `json:"loc" bson:"loc"`
+	Epoch       int         `json:"epoch" bson:"epoch"`
+	Message     string      `json:"message" bson:"message"`
+	Address     string      `json:"address" bson:"address"`
+	SubmittedBy *users.User `json:"submitted_by" bson:"submitted_by"`
+	CreatedAt   time.Time   `json:"created_at" bson:"created_at"`
+}
+
+func (e *IntakeEntry) Location() *Location {
+	message := e.Message
+	if e.Address != "" {
+		message += "\n" + e.Address
+	}
+
+	return &Location{
+		EntryID:         e.EntryID,
+		Loc:             e.Loc,
+		Epoch:           e.Epoch,
+		OriginalMessage: message,
+		Source:          e.Source,
+	}
+}
+
+func (r *repository) ensureIntakeIndexes(ctx context.Context) {
+	if _, err := r.mongo.CreateIndexWithOptions(ctx, "intake_entries", options.Index().SetUnique(true), bson.E{Key: "entry_id", Value: 1}); err != nil {
+		logrus.Errorf("Couldn't create intake_entries entry_id index: %s", err)
+	}
+}
+
+func (r *repository) lastIntakeID(ctx context.Context) (int, error) {
+	last := &IntakeEntry{}
+	if err := r.mongo.FindOne(ctx, "intake_entries", bson.D{}, options.FindOne().SetSort(bson.D{{Key: "entry_id", Value: -1}})).Decode(last); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return IntakeIDOffset, nil
+		}
+
+		return 0, err
+	}
+
+	return last.EntryID, nil
+}
+
+// AddIntakeEntry assigns the next free entry ID. Two intakes at the same time pick the same ID, the unique
+// index rejects one of them and it tries again with the following ID.
+func (r *repository) AddIntakeEntry(ctx context.Context, entry *IntakeEntry) error {
+	entry.CreatedAt = time.Now()
+
+	var err error
+	for i := 0; i < maxIntakeIDRetries; i++ {
+		var last int
+		last, err = r.lastIntakeID(ctx)
+		if err != nil {
+			return err
+		}
+
+		entry.EntryID = last + 1
+
+		err = r.mongo.InsertOne(ctx, "intake_entries", entry)
+		if err == nil || !mongo.IsDuplicateKeyError(err) {
+			break
+		}
+	}
+
+	if err != nil {
+		logrus.Errorln(err)
+
+		return err
+	}
+
+	return nil
+}
+
+func (r *repository) GetIntakeEntries(ctx context.Context) ([]*Location, error) {
+	cur, err := r.mongo.Find(ctx, "intake_entries", bson.D{})
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]*IntakeEntry, 0)
+	if err := cur.All(ctx, &entries); err != nil {
+		logrus.Errorln(err)
+		return nil, err
+	}
+
+	locs := make([]*Location, 0, len(entries))
+	for _, entry := range entries {
+		locs = append(locs, entry.Location())
+	}
+
+	return locs, nil
+}
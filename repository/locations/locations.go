@@ -2,28 +2,121 @@ package locations
 
 import (
 	"context"
+	"errors"
+	"regexp"
+	"time"
+
+	"github.com/YusufOzmen01/veri-kontrol-backend/core/address"
 	"github.com/YusufOzmen01/veri-kontrol-backend/core/sources"
 	"github.com/YusufOzmen01/veri-kontrol-backend/repository/users"
 	"github.com/sirupsen/logrus"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 type Repository interface {
-	GetLocations(ctx context.Context) ([]*LocationDB, error)
+	GetLocations(ctx context.Context, filter *LocationFilter) ([]*LocationDB, error)
+	CountLocations(ctx context.Context, filter *LocationFilter) (int64, error)
+	StreamLocations(ctx context.Context, filter *LocationFilter, fn func(location *LocationDB) error) error
+	GetLocation(ctx context.Context, entryID int) (*LocationDB, error)
 	ResolveLocation(ctx context.Context, location *LocationDB) error
+	ReplaceLocation(ctx context.Context, location *LocationDB, editor *users.User) error
+	GetRevisions(ctx context.Context, entryID int) ([]*Revision, error)
+	RollbackLocation(ctx context.Context, entryID int, revisionID primitive.ObjectID, editor *users.User) (*LocationDB, error)
+	UnresolveLocation(ctx context.Context, entryID int, actor *users.User) error
+	ApproveLocation(ctx context.Context, entryID int, approver *users.User) error
+	SetDuplicateOf(ctx context.Context, entryID int, duplicateOf int) error
+	SetFieldVerified(ctx context.Context, entryID int, verified bool) error
+	Subscribe() (<-chan *LocationDB, func())
+	Publish(location *LocationDB)
+	ResolveLocations(ctx context.Context, locations []*LocationDB) ([]int, error)
 	IsResolved(ctx context.Context, locationID int) (bool, error)
+	GetResolvedIDs(ctx context.Context, entryIDs []int) ([]int, error)
+	GetModeratorStats(ctx context.Context, eventID string) ([]*ModeratorStats, error)
+	GetLeaderboard(ctx context.Context, eventID string, since time.Time, excluded []primitive.ObjectID, limit int) ([]*LeaderboardEntry, error)
+	GetDailyStats(ctx context.Context, eventID string, since time.Time) ([]*DailyStats, error)
+	GetThroughput(ctx context.Context, filter *LocationFilter, bucket time.Duration, areas []*ThroughputArea) ([]*ThroughputCount, error)
 	IsDuplicate(ctx context.Context, tweetContents string) (bool, error)
 	GetDocumentsWithNoTweetContents(ctx context.Context) ([]*LocationDB, error)
+	ClaimLocation(ctx context.Context, entryID int, owner string, duration time.Duration, max int) (bool, error)
+	ReleaseLocation(ctx context.Context, entryID int, owner string, outcome string) error
+	IsClaimed(ctx context.Context, entryID int, owner string) (bool, error)
+	GetClaimedIDs(ctx context.Context, owner string) ([]int, error)
+	GetOwnedClaimIDs(ctx context.Context, owner string) ([]int, error)
+	CountActiveClaims(ctx context.Context) (map[string]int, error)
+	GetClaimStats(ctx context.Context, since time.Time) ([]*ClaimStats, error)
+	HideEntry(ctx context.Context, entryID int, actor *users.User) error
+	RestoreEntry(ctx context.Context, entryID int) error
+	IsHidden(ctx context.Context, entryID int) (bool, error)
+	GetHiddenIDs(ctx context.Context) ([]int, error)
+	AddIntakeEntry(ctx context.Context, entry *IntakeEntry) error
+	GetIntakeEntries(ctx context.Context) ([]*Location, error)
+	MigrateEvent(ctx context.Context, eventID string) error
+	GetSenderResolutions(ctx context.Context, filter *SenderResolutionFilter) ([]*SenderResolution, int64, error)
+	ExpireClaims(ctx context.Context, now time.Time) ([]*Claim, error)
+	GetStalePending(ctx context.Context, before time.Time) ([]*LocationDB, error)
+	ExpirePending(ctx context.Context, entryID int) error
 }
 
+var (
+	ErrLocationNotFound = errors.New("location not found")
+	ErrAlreadyResolved  = errors.New("location already resolved")
+)
+
 type repository struct {
 	mongo sources.MongoClient
+	feed  *feed
 }
 
 func NewRepository(mongo sources.MongoClient) Repository {
-	return &repository{
+	r := &repository{
 		mongo: mongo,
+		feed:  newFeed(),
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	r.ensureIndexes(ctx)
+	r.ensureClaimIndexes(ctx)
+	r.ensureHiddenIndexes(ctx)
+	r.ensureRevisionIndexes(ctx)
+	r.ensureIntakeIndexes(ctx)
+	r.ensureEventIndexes(ctx)
+	r.ensureGeoIndexes(ctx)
+
+	go r.backfill()
+
+	return r
+}
+
+func (r *repository) ensureIndexes(ctx context.Context) {
+	if _, err := r.mongo.CreateIndexWithOptions(ctx, "locations", options.Index().SetUnique(true), bson.E{Key: "entry_id", Value: 1}); err != nil {
+		logrus.Errorf("Couldn't create locations entry_id index: %s", err)
+	}
+
+	// Tweets can be longer than the index key limit, a hashed index still serves the exact match in IsDuplicate.
+	if _, err := r.mongo.CreateIndex(ctx, "locations", bson.E{Key: "tweet_contents", Value: "hashed"}); err != nil {
+		logrus.Errorf("Couldn't create locations tweet_contents index: %s", err)
+	}
+
+	if _, err := r.mongo.CreateIndex(ctx, "locations", bson.E{Key: "sender._id", Value: 1}); err != nil {
+		logrus.Errorf("Couldn't create locations sender._id index: %s", err)
+	}
+
+	if _, err := r.mongo.CreateIndex(ctx, "locations", bson.E{Key: "epoch", Value: 1}); err != nil {
+		logrus.Errorf("Couldn't create locations epoch index: %s", err)
+	}
+
+	// Only edited resolutions have updated_at, the sparse index leaves out the rest.
+	if _, err := r.mongo.CreateIndexWithOptions(ctx, "locations", options.Index().SetSparse(true), bson.E{Key: "updated_at", Value: 1}); err != nil {
+		logrus.Errorf("Couldn't create locations updated_at index: %s", err)
+	}
+
+	if _, err := r.mongo.CreateIndex(ctx, "location_history", bson.E{Key: "entry_id", Value: 1}); err != nil {
+		logrus.Errorf("Couldn't create location_history entry_id index: %s", err)
 	}
 }
 
@@ -33,11 +126,45 @@ type Location struct {
 	Epoch            int       `json:"epoch"`
 	OriginalMessage  string    `json:"original_message"`
 	OriginalLocation string    `json:"original_location"`
+	Address          *Address  `json:"address,omitempty"`
+	Source           string    `json:"source,omitempty"`
+	Score            float64   `json:"score,omitempty"`
+	PhoneNumbers     []string  `json:"phone_numbers,omitempty"`
+	EventID          string    `json:"event_id,omitempty"`
+	Geohash          string    `json:"geohash,omitempty"`
+	CoordSuspect     bool      `json:"coord_suspect,omitempty"`
+	CoordIssue       string    `json:"coord_issue,omitempty"`
+	SuggestedLoc     []float64 `json:"suggested_loc,omitempty"`
+	Lang             string    `json:"lang,omitempty"`
+	Translation      string    `json:"translation,omitempty"`
+}
+
+// Address is the administrative area a coordinate falls in, filled by reverse geocoding.
+type Address struct {
+	Province     string `json:"province"`
+	District     string `json:"district"`
+	Neighborhood string `json:"neighborhood"`
 }
 
+// The types are described in the location_types collection, these are the ones the code relies on.
 const (
 	TypeWreckage   = 1
 	TypeSupplyHelp = 2
+	TypeSpam       = 3
+	TypeShelter    = 4
+	TypeOther      = 5
+)
+
+// ReasonNoError means the volunteer confirmed the original address, every other reason describes what was wrong.
+const (
+	ReasonNoError = "Hata Yok"
+	ReasonSpam    = "Spam"
+)
+
+// Documents written before review mode existed have no status and count as resolved.
+const (
+	StatusResolved      = "resolved"
+	StatusPendingReview = "pending_review"
 )
 
 type LocationDB struct {
@@ -45,19 +172,196 @@ type LocationDB struct {
 	EntryID          int                `json:"entry_id" bson:"entry_id"`
 	Sender           *users.User        `json:"sender" bson:"sender"`
 	Location         []float64          `json:"location" bson:"location"`
+	Point            *GeoPoint          `json:"-" bson:"point,omitempty"`
+	Geohash          string             `json:"geohash,omitempty" bson:"geohash,omitempty"`
 	Corrected        bool               `json:"corrected" bson:"corrected"`
 	Verified         bool               `json:"verified" bson:"verified"`
 	OriginalAddress  string             `json:"original_address" bson:"original_address"`
 	CorrectedAddress string             `json:"corrected_address" bson:"corrected_address"`
+	CorrectedLat     *float64           `json:"corrected_lat,omitempty" bson:"corrected_lat,omitempty"`
+	CorrectedLng     *float64           `json:"corrected_lng,omitempty" bson:"corrected_lng,omitempty"`
+	ParsedAddress    *address.Parsed    `json:"parsed_address,omitempty" bson:"parsed_address,omitempty"`
 	OpenAddress      string             `json:"open_address" bson:"open_address"`
 	Apartment        string             `json:"apartment" bson:"apartment"`
 	Type             int                `json:"type" bson:"type"`
+	TypeCode         string             `json:"type_code,omitempty" bson:"type_code,omitempty"`
 	Reason           string             `json:"reason" bson:"reason"`
 	TweetContents    string             `json:"tweet_contents" bson:"tweet_contents"`
+	Lang             string             `json:"lang,omitempty" bson:"lang,omitempty"`
+	Epoch            int                `json:"epoch" bson:"epoch"`
+	Status           string             `json:"status" bson:"status"`
+	ApprovedBy       *users.User        `json:"approved_by" bson:"approved_by"`
+	DuplicateOf      int                `json:"duplicate_of,omitempty" bson:"duplicate_of,omitempty"`
+	Source           string             `json:"source,omitempty" bson:"source,omitempty"`
+	FieldVerified    bool               `json:"field_verified" bson:"field_verified,omitempty"`
+	PhoneNumbers     []string           `json:"phone_numbers,omitempty" bson:"phone_numbers,omitempty"`
+	EventID          string             `json:"event_id" bson:"event_id"`
+	// Revision counts the edits of the resolution, UpdatedAt is when the last one was made. The _id stays the
+	// one of the first resolution.
+	Revision  int        `json:"revision,omitempty" bson:"revision,omitempty"`
+	UpdatedAt *time.Time `json:"updated_at,omitempty" bson:"updated_at,omitempty"`
+}
+
+func (l *LocationDB) IsPendingReview() bool {
+	return l.Status == StatusPendingReview
+}
+
+const HistoryActionUnresolve = "unresolve"
+
+// LocationHistory keeps resolutions which were taken back, so nothing a volunteer submitted is lost.
+type LocationHistory struct {
+	ID       primitive.ObjectID `json:"_id" bson:"_id"`
+	EntryID  int                `json:"entry_id" bson:"entry_id"`
+	Action   string             `json:"action" bson:"action"`
+	Actor    *users.User        `json:"actor" bson:"actor"`
+	At       time.Time          `json:"at" bson:"at"`
+	Location *LocationDB        `json:"location" bson:"location"`
 }
 
-func (r *repository) GetLocations(ctx context.Context) ([]*LocationDB, error) {
-	cur, err := r.mongo.Find(ctx, "locations", bson.D{})
+// LocationFilter narrows down resolved locations, a nil filter matches everything.
+type LocationFilter struct {
+	EventID       string
+	Reason        string
+	Status        string
+	Types         []int
+	Corrected     *bool
+	SenderID      *primitive.ObjectID
+	Polygon       [][]float64 // [lat, lng] vertices, same layout as the stored location
+	Near          *Near
+	Geohash       string // Prefix of the geohash, a shorter one is a bigger area
+	EpochFrom     int
+	EpochTo       int
+	ResolvedSince time.Time // Compared with the ObjectID timestamp, which is when the entry got resolved
+	ResolvedUntil time.Time
+	ChangedSince  time.Time // Resolved or edited since then
+	Page          int
+	PageSize      int
+}
+
+func (f *LocationFilter) query() bson.D {
+	query := bson.D{}
+	if f == nil {
+		return query
+	}
+
+	if f.EventID != "" {
+		query = append(query, bson.E{Key: "event_id", Value: f.EventID})
+	}
+
+	if f.Reason != "" {
+		query = append(query, bson.E{Key: "reason", Value: f.Reason})
+	}
+
+	switch f.Status {
+	case StatusPendingReview:
+		query = append(query, bson.E{Key: "status", Value: StatusPendingReview})
+	case StatusResolved:
+		query = append(query, bson.E{Key: "status", Value: bson.D{{Key: "$ne", Value: StatusPendingReview}}})
+	}
+
+	if len(f.Types) > 0 {
+		query = append(query, bson.E{Key: "type", Value: bson.D{{Key: "$in", Value: f.Types}}})
+	}
+
+	if f.Corrected != nil {
+		query = append(query, bson.E{Key: "corrected", Value: *f.Corrected})
+	}
+
+	if f.SenderID != nil {
+		query = append(query, bson.E{Key: "sender._id", Value: *f.SenderID})
+	}
+
+	// Polygon and Near both constrain the point, they are combined so one doesn't overwrite the other.
+	geo := bson.A{}
+
+	if len(f.Polygon) >= 3 {
+		geo = append(geo, bson.D{{Key: "point", Value: bson.D{{
+			Key:   "$geoWithin",
+			Value: bson.D{{Key: "$geometry", Value: geoPolygon(f.Polygon)}},
+		}}}})
+	}
+
+	// $centerSphere instead of $nearSphere, the latter can't be counted and doesn't work with skip.
+	if f.Near != nil {
+		geo = append(geo, bson.D{{Key: "point", Value: bson.D{{
+			Key: "$geoWithin",
+			Value: bson.D{{Key: "$centerSphere", Value: bson.A{
+				bson.A{f.Near.Lng, f.Near.Lat},
+				f.Near.RadiusMeters / earthRadius,
+			}}},
+		}}}})
+	}
+
+	// Anchored, case sensitive prefixes are answered from the geohash index.
+	if f.Geohash != "" {
+		query = append(query, bson.E{Key: "geohash", Value: primitive.Regex{Pattern: "^" + regexp.QuoteMeta(f.Geohash)}})
+	}
+
+	switch len(geo) {
+	case 1:
+		query = append(query, geo[0].(bson.D)...)
+	case 2:
+		query = append(query, bson.E{Key: "$and", Value: geo})
+	}
+
+	if f.EpochFrom > 0 || f.EpochTo > 0 {
+		epoch := bson.D{}
+
+		if f.EpochFrom > 0 {
+			epoch = append(epoch, bson.E{Key: "$gte", Value: f.EpochFrom})
+		}
+
+		if f.EpochTo > 0 {
+			epoch = append(epoch, bson.E{Key: "$lte", Value: f.EpochTo})
+		}
+
+		query = append(query, bson.E{Key: "epoch", Value: epoch})
+	}
+
+	if !f.ResolvedSince.IsZero() || !f.ResolvedUntil.IsZero() {
+		resolved := bson.D{}
+
+		if !f.ResolvedSince.IsZero() {
+			resolved = append(resolved, bson.E{Key: "$gte", Value: primitive.NewObjectIDFromTimestamp(f.ResolvedSince)})
+		}
+
+		// ObjectIDs only keep whole seconds, everything created during the last second still matches.
+		if !f.ResolvedUntil.IsZero() {
+			resolved = append(resolved, bson.E{Key: "$lt", Value: primitive.NewObjectIDFromTimestamp(f.ResolvedUntil.Add(time.Second))})
+		}
+
+		query = append(query, bson.E{Key: "_id", Value: resolved})
+	}
+
+	if !f.ChangedSince.IsZero() {
+		query = append(query, bson.E{Key: "$or", Value: bson.A{
+			bson.D{{Key: "_id", Value: bson.D{{Key: "$gte", Value: primitive.NewObjectIDFromTimestamp(f.ChangedSince)}}}},
+			bson.D{{Key: "updated_at", Value: bson.D{{Key: "$gte", Value: f.ChangedSince}}}},
+		}})
+	}
+
+	return query
+}
+
+func (f *LocationFilter) findOptions() *options.FindOptions {
+	opts := options.Find()
+	if f == nil || f.PageSize <= 0 {
+		return opts
+	}
+
+	page := f.Page
+	if page < 1 {
+		page = 1
+	}
+
+	return opts.
+		SetSort(bson.D{{Key: "_id", Value: 1}}).
+		SetSkip(int64((page - 1) * f.PageSize)).
+		SetLimit(int64(f.PageSize))
+}
+
+func (r *repository) GetLocations(ctx context.Context, filter *LocationFilter) ([]*LocationDB, error) {
+	cur, err := r.mongo.Find(ctx, "locations", filter.query(), filter.findOptions())
 	if err != nil {
 		return nil, err
 	}
@@ -71,25 +375,249 @@ func (r *repository) GetLocations(ctx context.Context) ([]*LocationDB, error) {
 	return locs, nil
 }
 
+// StreamLocations decodes the matching locations one by one so large result sets never sit in memory at once.
+func (r *repository) StreamLocations(ctx context.Context, filter *LocationFilter, fn func(location *LocationDB) error) error {
+	cur, err := r.mongo.Find(ctx, "locations", filter.query(), filter.findOptions())
+	if err != nil {
+		return err
+	}
+	defer cur.Close(ctx)
+
+	for cur.Next(ctx) {
+		location := &LocationDB{}
+		if err := cur.Decode(location); err != nil {
+			logrus.Errorln(err)
+			return err
+		}
+
+		if err := fn(location); err != nil {
+			return err
+		}
+	}
+
+	return cur.Err()
+}
+
+func (r *repository) GetLocation(ctx context.Context, entryID int) (*LocationDB, error) {
+	location := &LocationDB{}
+	if err := r.mongo.FindOne(ctx, "locations", bson.D{{
+		Key:   "entry_id",
+		Value: entryID,
+	}}).Decode(location); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, ErrLocationNotFound
+		}
+
+		return nil, err
+	}
+
+	return location, nil
+}
+
+func (r *repository) CountLocations(ctx context.Context, filter *LocationFilter) (int64, error) {
+	return r.mongo.Count(ctx, "locations", filter.query())
+}
+
+// ResolveLocation stores a new resolution and fails with ErrAlreadyResolved if the entry already has one. In a
+// transaction subscribers aren't told, the caller has to Publish the resolution once it is committed.
 func (r *repository) ResolveLocation(ctx context.Context, location *LocationDB) error {
-	if err := r.mongo.DeleteOne(ctx, "locations", bson.D{{
+	location.Point = NewGeoPoint(location.Location)
+	location.Geohash = newGeohash(location.Location)
+
+	// No document lacks an _id, so the filter never matches and an existing resolution makes the upsert collide
+	// with the unique entry_id index. Checking and inserting is a single operation that way.
+	err := r.mongo.UpsertOne(ctx, "locations", bson.D{
+		{Key: "entry_id", Value: location.EntryID},
+		{Key: "_id", Value: bson.D{{Key: "$exists", Value: false}}},
+	}, bson.D{{
+		Key:   "$setOnInsert",
+		Value: location,
+	}})
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return ErrAlreadyResolved
+		}
+
+		logrus.Errorln(err)
+
+		return err
+	}
+
+	if mongo.SessionFromContext(ctx) == nil {
+		r.feed.publish(location)
+	}
+
+	return nil
+}
+
+// ReplaceLocation overwrites the resolution of an entry, or creates it when there is none. The previous
+// version is kept as a revision.
+func (r *repository) ReplaceLocation(ctx context.Context, location *LocationDB, editor *users.User) error {
+	return r.replaceLocation(ctx, location, editor, nil)
+}
+
+func (r *repository) replaceLocation(ctx context.Context, location *LocationDB, editor *users.User, rollbackOf *primitive.ObjectID) error {
+	location.Point = NewGeoPoint(location.Location)
+	location.Geohash = newGeohash(location.Location)
+
+	before, err := r.GetLocation(ctx, location.EntryID)
+	if err != nil && err != ErrLocationNotFound {
+		return err
+	}
+
+	// The _id of a document can't change, the replacement keeps the one of the stored location. The revision
+	// and updated_at tell the edit apart from the resolution it replaces.
+	if before != nil {
+		now := time.Now()
+
+		location.ID = before.ID
+		location.Revision = before.Revision + 1
+		location.UpdatedAt = &now
+	}
+
+	// A single replace, a failed write can't leave the entry without its location. A volunteer can still
+	// resolve the entry between the read above and the upsert.
+	if err := r.mongo.ReplaceOne(ctx, "locations", bson.D{{
 		Key:   "entry_id",
 		Value: location.EntryID,
-	}}); err != nil {
+	}}, location, options.Replace().SetUpsert(true)); err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return ErrAlreadyResolved
+		}
+
 		logrus.Errorln(err)
 
 		return err
 	}
 
-	if err := r.mongo.InsertOne(ctx, "locations", location); err != nil {
+	// The edit itself went through, a missing revision only loses history.
+	if err := r.addRevision(ctx, editor, before, location, rollbackOf); err != nil {
+		logrus.Errorf("Couldn't record revision of entry %d: %s", location.EntryID, err)
+	}
+
+	r.feed.publish(location)
+
+	return nil
+}
+
+// ResolveLocations inserts already validated, unresolved locations with a single bulk write. The write is
+// unordered, a location resolved by someone else in the meantime doesn't stop the others. Their entry IDs are
+// returned, every other location was stored.
+func (r *repository) ResolveLocations(ctx context.Context, locations []*LocationDB) ([]int, error) {
+	conflicts := make([]int, 0)
+	if len(locations) == 0 {
+		return conflicts, nil
+	}
+
+	documents := make([]interface{}, 0, len(locations))
+	for _, location := range locations {
+		location.Point = NewGeoPoint(location.Location)
+		location.Geohash = newGeohash(location.Location)
+		documents = append(documents, location)
+	}
+
+	failed := make(map[int]bool)
+
+	if err := r.mongo.InsertMany(ctx, "locations", documents, options.InsertMany().SetOrdered(false)); err != nil {
+		var bulkErr mongo.BulkWriteException
+		if !errors.As(err, &bulkErr) || bulkErr.WriteConcernError != nil {
+			logrus.Errorln(err)
+
+			return nil, err
+		}
+
+		for _, writeErr := range bulkErr.WriteErrors {
+			if !mongo.IsDuplicateKeyError(writeErr.WriteError) || writeErr.Index < 0 || writeErr.Index >= len(locations) {
+				logrus.Errorln(err)
+
+				return nil, err
+			}
+
+			failed[writeErr.Index] = true
+			conflicts = append(conflicts, locations[writeErr.Index].EntryID)
+		}
+	}
+
+	for i, location := range locations {
+		if !failed[i] {
+			r.feed.publish(location)
+		}
+	}
+
+	return conflicts, nil
+}
+
+// UnresolveLocation deletes the resolution and moves what it deleted into the history. Finding and deleting it
+// is one step, a resolution made again in the meantime can't be deleted in place of the one that was read.
+func (r *repository) UnresolveLocation(ctx context.Context, entryID int, actor *users.User) error {
+	location := &LocationDB{}
+	if err := r.mongo.FindOneAndDelete(ctx, "locations", bson.D{{
+		Key:   "entry_id",
+		Value: entryID,
+	}}).Decode(location); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return ErrLocationNotFound
+		}
+
 		logrus.Errorln(err)
 
 		return err
 	}
 
+	// The resolution is gone either way, a missing history entry only loses what it was.
+	if err := r.mongo.InsertOne(ctx, "location_history", &LocationHistory{
+		ID:       primitive.NewObjectIDFromTimestamp(time.Now()),
+		EntryID:  entryID,
+		Action:   HistoryActionUnresolve,
+		Actor:    actor,
+		At:       time.Now(),
+		Location: location,
+	}); err != nil {
+		logrus.Errorf("Couldn't record the unresolve of entry %d: %s", entryID, err)
+	}
+
 	return nil
 }
 
+func (r *repository) ApproveLocation(ctx context.Context, entryID int, approver *users.User) error {
+	return r.mongo.UpdateOne(ctx, "locations", bson.D{
+		{Key: "entry_id", Value: entryID},
+		{Key: "status", Value: StatusPendingReview},
+	}, bson.D{{
+		Key: "$set",
+		Value: bson.D{
+			{Key: "status", Value: StatusResolved},
+			{Key: "approved_by", Value: approver},
+		},
+	}})
+}
+
+// SetDuplicateOf links a resolution to the entry it is a near duplicate of, 0 removes the link.
+func (r *repository) SetDuplicateOf(ctx context.Context, entryID int, duplicateOf int) error {
+	update := bson.D{{Key: "$set", Value: bson.D{{Key: "duplicate_of", Value: duplicateOf}}}}
+	if duplicateOf == 0 {
+		update = bson.D{{Key: "$unset", Value: bson.D{{Key: "duplicate_of", Value: ""}}}}
+	}
+
+	return r.mongo.UpdateOne(ctx, "locations", bson.D{{
+		Key:   "entry_id",
+		Value: entryID,
+	}}, update)
+}
+
+// SetFieldVerified records whether a team on the ground confirmed the resolution.
+func (r *repository) SetFieldVerified(ctx context.Context, entryID int, verified bool) error {
+	update := bson.D{{Key: "$set", Value: bson.D{{Key: "field_verified", Value: true}}}}
+	if !verified {
+		update = bson.D{{Key: "$unset", Value: bson.D{{Key: "field_verified", Value: ""}}}}
+	}
+
+	return r.mongo.UpdateOne(ctx, "locations", bson.D{{
+		Key:   "entry_id",
+		Value: entryID,
+	}}, update)
+}
+
 func (r *repository) IsResolved(ctx context.Context, locationID int) (bool, error) {
 	exists, err := r.mongo.DoesExist(ctx, "locations", bson.D{{
 		Key:   "entry_id",
@@ -102,6 +630,34 @@ func (r *repository) IsResolved(ctx context.Context, locationID int) (bool, erro
 	return exists, nil
 }
 
+// GetResolvedIDs returns which of the given entries already have a resolution.
+func (r *repository) GetResolvedIDs(ctx context.Context, entryIDs []int) ([]int, error) {
+	ids := make([]int, 0)
+	if len(entryIDs) == 0 {
+		return ids, nil
+	}
+
+	cur, err := r.mongo.Find(ctx, "locations", bson.D{{
+		Key:   "entry_id",
+		Value: bson.D{{Key: "$in", Value: entryIDs}},
+	}}, options.Find().SetProjection(bson.D{{Key: "entry_id", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+
+	locations := make([]*LocationDB, 0)
+	if err := cur.All(ctx, &locations); err != nil {
+		logrus.Errorln(err)
+		return nil, err
+	}
+
+	for _, location := range locations {
+		ids = append(ids, location.EntryID)
+	}
+
+	return ids, nil
+}
+
 func (r *repository) IsDuplicate(ctx context.Context, tweetContents string) (bool, error) {
 	exists, err := r.mongo.DoesExist(ctx, "locations", bson.D{{
 		Key:   "tweet_contents",
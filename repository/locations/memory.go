@@ -0,0 +1,1134 @@
+package locations
+
+import (
+	"bytes"
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/YusufOzmen01/veri-kontrol-backend/core/geohash"
+	"github.com/YusufOzmen01/veri-kontrol-backend/repository/audit"
+	"github.com/YusufOzmen01/veri-kontrol-backend/repository/users"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// memoryRepository keeps every collection in maps and slices. It answers like the Mongo repository does, so
+// the handlers can be run against it without a database, but nothing survives a restart.
+type memoryRepository struct {
+	mu        sync.RWMutex
+	locations map[int]*LocationDB
+	history   []*LocationHistory
+	revisions []*Revision
+	claims    map[int]*Claim
+	claimLog  []*ClaimLog
+	hidden    map[int]*HiddenEntry
+	intake    []*IntakeEntry
+	feed      *feed
+}
+
+// NewMemoryRepository returns an empty Repository which lives in memory, for tests and local runs.
+func NewMemoryRepository() Repository {
+	return &memoryRepository{
+		locations: make(map[int]*LocationDB),
+		claims:    make(map[int]*Claim),
+		hidden:    make(map[int]*HiddenEntry),
+		feed:      newFeed(),
+	}
+}
+
+// copyLocation keeps callers from changing the stored document, a decoded Mongo document is a copy as well.
+func copyLocation(location *LocationDB) *LocationDB {
+	if location == nil {
+		return nil
+	}
+
+	c := *location
+
+	return &c
+}
+
+// resolvedDayOf is resolvedDay for a document in memory.
+func resolvedDayOf(location *LocationDB) string {
+	return location.ID.Timestamp().UTC().Format("2006-01-02")
+}
+
+func senderIs(user *users.User, id primitive.ObjectID) bool {
+	return user != nil && user.ID == id
+}
+
+// containsPoint checks whether the [lat, lng] point is inside the polygon with ray casting.
+func containsPoint(polygon [][]float64, lat, lng float64) bool {
+	inside := false
+
+	for i, j := 0, len(polygon)-1; i < len(polygon); j, i = i, i+1 {
+		a, b := polygon[i], polygon[j]
+
+		if (a[1] > lng) != (b[1] > lng) && lat < (b[0]-a[0])*(lng-a[1])/(b[1]-a[1])+a[0] {
+			inside = !inside
+		}
+	}
+
+	return inside
+}
+
+// matches is query for a document in memory.
+func (f *LocationFilter) matches(location *LocationDB) bool {
+	if f == nil {
+		return true
+	}
+
+	if f.EventID != "" && location.EventID != f.EventID {
+		return false
+	}
+
+	if f.Reason != "" && location.Reason != f.Reason {
+		return false
+	}
+
+	switch f.Status {
+	case StatusPendingReview:
+		if !location.IsPendingReview() {
+			return false
+		}
+	case StatusResolved:
+		if location.IsPendingReview() {
+			return false
+		}
+	}
+
+	if len(f.Types) > 0 && !containsInt(f.Types, location.Type) {
+		return false
+	}
+
+	if f.Corrected != nil && location.Corrected != *f.Corrected {
+		return false
+	}
+
+	if f.SenderID != nil && !senderIs(location.Sender, *f.SenderID) {
+		return false
+	}
+
+	hasPoint := len(location.Location) >= 2
+
+	if len(f.Polygon) >= 3 && (!hasPoint || !containsPoint(f.Polygon, location.Location[0], location.Location[1])) {
+		return false
+	}
+
+	if f.Near != nil && (!hasPoint || geohash.Distance(f.Near.Lat, f.Near.Lng, location.Location[0], location.Location[1]) > f.Near.RadiusMeters) {
+		return false
+	}
+
+	if f.Geohash != "" && !strings.HasPrefix(location.Geohash, f.Geohash) {
+		return false
+	}
+
+	if f.EpochFrom > 0 && location.Epoch < f.EpochFrom {
+		return false
+	}
+
+	if f.EpochTo > 0 && location.Epoch > f.EpochTo {
+		return false
+	}
+
+	if !f.ResolvedSince.IsZero() {
+		since := primitive.NewObjectIDFromTimestamp(f.ResolvedSince)
+		if bytes.Compare(location.ID[:], since[:]) < 0 {
+			return false
+		}
+	}
+
+	if !f.ResolvedUntil.IsZero() {
+		until := primitive.NewObjectIDFromTimestamp(f.ResolvedUntil.Add(time.Second))
+		if bytes.Compare(location.ID[:], until[:]) >= 0 {
+			return false
+		}
+	}
+
+	if !f.ChangedSince.IsZero() {
+		since := primitive.NewObjectIDFromTimestamp(f.ChangedSince)
+		edited := location.UpdatedAt != nil && !location.UpdatedAt.Before(f.ChangedSince)
+
+		if bytes.Compare(location.ID[:], since[:]) < 0 && !edited {
+			return false
+		}
+	}
+
+	return true
+}
+
+func containsInt(list []int, value int) bool {
+	for _, v := range list {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+// sortedLocations returns copies of the stored documents in _id order, the caller has to hold the lock.
+func (r *memoryRepository) sortedLocations(filter *LocationFilter) []*LocationDB {
+	locs := make([]*LocationDB, 0)
+	for _, location := range r.locations {
+		if filter.matches(location) {
+			locs = append(locs, copyLocation(location))
+		}
+	}
+
+	sort.Slice(locs, func(i, j int) bool {
+		return bytes.Compare(locs[i].ID[:], locs[j].ID[:]) < 0
+	})
+
+	return locs
+}
+
+func (r *memoryRepository) GetLocations(ctx context.Context, filter *LocationFilter) ([]*LocationDB, error) {
+	r.mu.RLock()
+	locs := r.sortedLocations(filter)
+	r.mu.RUnlock()
+
+	if filter == nil || filter.PageSize <= 0 {
+		return locs, nil
+	}
+
+	page := filter.Page
+	if page < 1 {
+		page = 1
+	}
+
+	start := (page - 1) * filter.PageSize
+	if start >= len(locs) {
+		return make([]*LocationDB, 0), nil
+	}
+
+	end := start + filter.PageSize
+	if end > len(locs) {
+		end = len(locs)
+	}
+
+	return locs[start:end], nil
+}
+
+func (r *memoryRepository) CountLocations(ctx context.Context, filter *LocationFilter) (int64, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var count int64
+	for _, location := range r.locations {
+		if filter.matches(location) {
+			count++
+		}
+	}
+
+	return count, nil
+}
+
+func (r *memoryRepository) StreamLocations(ctx context.Context, filter *LocationFilter, fn func(location *LocationDB) error) error {
+	locs, err := r.GetLocations(ctx, filter)
+	if err != nil {
+		return err
+	}
+
+	for _, location := range locs {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		if err := fn(location); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r *memoryRepository) GetLocation(ctx context.Context, entryID int) (*LocationDB, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	location, ok := r.locations[entryID]
+	if !ok {
+		return nil, ErrLocationNotFound
+	}
+
+	return copyLocation(location), nil
+}
+
+func (r *memoryRepository) ResolveLocation(ctx context.Context, location *LocationDB) error {
+	location.Point = NewGeoPoint(location.Location)
+	location.Geohash = newGeohash(location.Location)
+
+	r.mu.Lock()
+	if _, ok := r.locations[location.EntryID]; ok {
+		r.mu.Unlock()
+
+		return ErrAlreadyResolved
+	}
+
+	r.locations[location.EntryID] = copyLocation(location)
+	r.mu.Unlock()
+
+	if mongo.SessionFromContext(ctx) == nil {
+		r.feed.publish(location)
+	}
+
+	return nil
+}
+
+func (r *memoryRepository) ReplaceLocation(ctx context.Context, location *LocationDB, editor *users.User) error {
+	r.replaceLocation(location, editor, nil)
+
+	return nil
+}
+
+func (r *memoryRepository) replaceLocation(location *LocationDB, editor *users.User, rollbackOf *primitive.ObjectID) {
+	location.Point = NewGeoPoint(location.Location)
+	location.Geohash = newGeohash(location.Location)
+
+	r.mu.Lock()
+	before := copyLocation(r.locations[location.EntryID])
+	if before != nil {
+		now := time.Now()
+
+		location.ID = before.ID
+		location.Revision = before.Revision + 1
+		location.UpdatedAt = &now
+	}
+
+	r.locations[location.EntryID] = copyLocation(location)
+
+	r.revisions = append(r.revisions, &Revision{
+		ID:         primitive.NewObjectIDFromTimestamp(time.Now()),
+		EntryID:    location.EntryID,
+		Editor:     editor,
+		At:         time.Now(),
+		Changes:    audit.Diff(before, location),
+		Before:     before,
+		After:      copyLocation(location),
+		RollbackOf: rollbackOf,
+	})
+	r.mu.Unlock()
+
+	r.feed.publish(location)
+}
+
+func (r *memoryRepository) GetRevisions(ctx context.Context, entryID int) ([]*Revision, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	revisions := make([]*Revision, 0)
+	for i := len(r.revisions) - 1; i >= 0; i-- {
+		if r.revisions[i].EntryID == entryID {
+			revisions = append(revisions, r.revisions[i])
+		}
+	}
+
+	return revisions, nil
+}
+
+func (r *memoryRepository) RollbackLocation(ctx context.Context, entryID int, revisionID primitive.ObjectID, editor *users.User) (*LocationDB, error) {
+	r.mu.RLock()
+	var revision *Revision
+	for _, rev := range r.revisions {
+		if rev.ID == revisionID && rev.EntryID == entryID {
+			revision = rev
+			break
+		}
+	}
+	r.mu.RUnlock()
+
+	if revision == nil {
+		return nil, ErrRevisionNotFound
+	}
+
+	if revision.Before == nil {
+		return nil, ErrNothingToRestore
+	}
+
+	restored := *revision.Before
+	restored.ID = primitive.NewObjectIDFromTimestamp(time.Now())
+
+	r.replaceLocation(&restored, editor, &revision.ID)
+
+	return &restored, nil
+}
+
+// takeBack moves a resolution into the history, the caller has to hold the lock.
+func (r *memoryRepository) takeBack(location *LocationDB, action string, actor *users.User) {
+	r.history = append(r.history, &LocationHistory{
+		ID:       primitive.NewObjectIDFromTimestamp(time.Now()),
+		EntryID:  location.EntryID,
+		Action:   action,
+		Actor:    actor,
+		At:       time.Now(),
+		Location: copyLocation(location),
+	})
+
+	delete(r.locations, location.EntryID)
+}
+
+func (r *memoryRepository) UnresolveLocation(ctx context.Context, entryID int, actor *users.User) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	location, ok := r.locations[entryID]
+	if !ok {
+		return ErrLocationNotFound
+	}
+
+	r.takeBack(location, HistoryActionUnresolve, actor)
+
+	return nil
+}
+
+func (r *memoryRepository) ApproveLocation(ctx context.Context, entryID int, approver *users.User) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if location, ok := r.locations[entryID]; ok && location.IsPendingReview() {
+		location.Status = StatusResolved
+		location.ApprovedBy = approver
+	}
+
+	return nil
+}
+
+func (r *memoryRepository) SetDuplicateOf(ctx context.Context, entryID int, duplicateOf int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if location, ok := r.locations[entryID]; ok {
+		location.DuplicateOf = duplicateOf
+	}
+
+	return nil
+}
+
+func (r *memoryRepository) SetFieldVerified(ctx context.Context, entryID int, verified bool) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if location, ok := r.locations[entryID]; ok {
+		location.FieldVerified = verified
+	}
+
+	return nil
+}
+
+func (r *memoryRepository) Subscribe() (<-chan *LocationDB, func()) {
+	return r.feed.subscribe()
+}
+
+func (r *memoryRepository) Publish(location *LocationDB) {
+	r.feed.publish(location)
+}
+
+func (r *memoryRepository) ResolveLocations(ctx context.Context, locations []*LocationDB) ([]int, error) {
+	conflicts := make([]int, 0)
+	stored := make([]*LocationDB, 0, len(locations))
+
+	r.mu.Lock()
+	for _, location := range locations {
+		if _, ok := r.locations[location.EntryID]; ok {
+			conflicts = append(conflicts, location.EntryID)
+
+			continue
+		}
+
+		location.Point = NewGeoPoint(location.Location)
+		location.Geohash = newGeohash(location.Location)
+		r.locations[location.EntryID] = copyLocation(location)
+		stored = append(stored, location)
+	}
+	r.mu.Unlock()
+
+	for _, location := range stored {
+		r.feed.publish(location)
+	}
+
+	return conflicts, nil
+}
+
+func (r *memoryRepository) IsResolved(ctx context.Context, locationID int) (bool, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	_, ok := r.locations[locationID]
+
+	return ok, nil
+}
+
+func (r *memoryRepository) GetResolvedIDs(ctx context.Context, entryIDs []int) ([]int, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	ids := make([]int, 0)
+	for _, id := range entryIDs {
+		if _, ok := r.locations[id]; ok {
+			ids = append(ids, id)
+		}
+	}
+
+	return ids, nil
+}
+
+func (r *memoryRepository) GetModeratorStats(ctx context.Context, eventID string) ([]*ModeratorStats, error) {
+	type senderKey struct {
+		id   primitive.ObjectID
+		name string
+	}
+
+	r.mu.RLock()
+	bySender := make(map[senderKey]map[string]map[string]int)
+	for _, location := range r.locations {
+		if location.Sender == nil || (eventID != "" && location.EventID != eventID) {
+			continue
+		}
+
+		key := senderKey{id: location.Sender.ID, name: location.Sender.Name}
+		if bySender[key] == nil {
+			bySender[key] = make(map[string]map[string]int)
+		}
+
+		day := resolvedDayOf(location)
+		if bySender[key][day] == nil {
+			bySender[key][day] = make(map[string]int)
+		}
+
+		bySender[key][day][location.Reason]++
+	}
+	r.mu.RUnlock()
+
+	stats := make([]*ModeratorStats, 0, len(bySender))
+	for key, days := range bySender {
+		sender := &ModeratorStats{SenderID: key.id, Name: key.name, Days: make([]*ModeratorDailyStats, 0, len(days))}
+
+		for day, reasons := range days {
+			daily := &ModeratorDailyStats{Day: day, Reasons: make([]*ReasonCount, 0, len(reasons))}
+
+			for reason, count := range reasons {
+				daily.Count += count
+				daily.Reasons = append(daily.Reasons, &ReasonCount{Reason: reason, Count: count})
+			}
+
+			sort.Slice(daily.Reasons, func(i, j int) bool { return daily.Reasons[i].Reason < daily.Reasons[j].Reason })
+
+			sender.Total += daily.Count
+			sender.Days = append(sender.Days, daily)
+		}
+
+		sort.Slice(sender.Days, func(i, j int) bool { return sender.Days[i].Day < sender.Days[j].Day })
+
+		stats = append(stats, sender)
+	}
+
+	sort.SliceStable(stats, func(i, j int) bool {
+		if stats[i].Total != stats[j].Total {
+			return stats[i].Total > stats[j].Total
+		}
+
+		return stats[i].SenderID.Hex() < stats[j].SenderID.Hex()
+	})
+
+	return stats, nil
+}
+
+func (r *memoryRepository) GetLeaderboard(ctx context.Context, eventID string, since time.Time, excluded []primitive.ObjectID, limit int) ([]*LeaderboardEntry, error) {
+	filter := &LocationFilter{EventID: eventID, ResolvedSince: since}
+
+	r.mu.RLock()
+	locs := r.sortedLocations(filter)
+	r.mu.RUnlock()
+
+	bySender := make(map[primitive.ObjectID]*LeaderboardEntry)
+	entries := make([]*LeaderboardEntry, 0)
+
+	for _, location := range locs {
+		if location.Sender == nil || location.Type == TypeSpam {
+			continue
+		}
+
+		skip := false
+		for _, id := range excluded {
+			if location.Sender.ID == id {
+				skip = true
+				break
+			}
+		}
+
+		if skip {
+			continue
+		}
+
+		entry, ok := bySender[location.Sender.ID]
+		if !ok {
+			entry = &LeaderboardEntry{SenderID: location.Sender.ID}
+			bySender[location.Sender.ID] = entry
+			entries = append(entries, entry)
+		}
+
+		// The name of the latest resolution, like $last.
+		entry.Name = location.Sender.Name
+		entry.Count++
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Count != entries[j].Count {
+			return entries[i].Count > entries[j].Count
+		}
+
+		return bytes.Compare(entries[i].SenderID[:], entries[j].SenderID[:]) < 0
+	})
+
+	if limit > 0 && len(entries) > limit {
+		// Like the Mongo repository, the senders tied with the last one stay.
+		end := limit
+		for end < len(entries) && entries[end].Count == entries[limit-1].Count {
+			end++
+		}
+
+		entries = entries[:end]
+	}
+
+	rankLeaderboard(entries)
+
+	return entries, nil
+}
+
+func (r *memoryRepository) GetDailyStats(ctx context.Context, eventID string, since time.Time) ([]*DailyStats, error) {
+	type latency struct {
+		sum   float64
+		count int
+	}
+
+	r.mu.RLock()
+	locs := r.sortedLocations(&LocationFilter{EventID: eventID, ResolvedSince: since})
+	r.mu.RUnlock()
+
+	byDay := make(map[string]*DailyStats)
+	latencies := make(map[string]*latency)
+	stats := make([]*DailyStats, 0)
+
+	for _, location := range locs {
+		day := resolvedDayOf(location)
+
+		daily, ok := byDay[day]
+		if !ok {
+			daily = &DailyStats{Day: day}
+			byDay[day] = daily
+			latencies[day] = &latency{}
+			stats = append(stats, daily)
+		}
+
+		daily.Resolved++
+		if location.Type == TypeSpam {
+			daily.Spam++
+		}
+
+		if location.Epoch > 0 {
+			latencies[day].sum += float64(location.ID.Timestamp().Unix() - int64(location.Epoch))
+			latencies[day].count++
+		}
+	}
+
+	for _, daily := range stats {
+		if l := latencies[daily.Day]; l.count > 0 {
+			daily.AvgResolutionSeconds = l.sum / float64(l.count)
+		}
+	}
+
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Day < stats[j].Day })
+
+	return stats, nil
+}
+
+func (r *memoryRepository) GetThroughput(ctx context.Context, filter *LocationFilter, bucket time.Duration, areas []*ThroughputArea) ([]*ThroughputCount, error) {
+	type countKey struct {
+		bucket int64
+		area   int
+		typ    int
+	}
+
+	r.mu.RLock()
+	locs := r.sortedLocations(filter)
+	r.mu.RUnlock()
+
+	byKey := make(map[countKey]*ThroughputCount)
+	counts := make([]*ThroughputCount, 0)
+	size := bucket.Milliseconds()
+
+	for _, location := range locs {
+		area := 0
+		if len(location.Location) >= 2 {
+			for _, a := range areas {
+				if len(a.Polygon) >= 3 && containsPoint(a.Polygon, location.Location[0], location.Location[1]) {
+					area = a.ID
+
+					break
+				}
+			}
+		}
+
+		resolvedAt := location.ID.Timestamp().UnixMilli()
+		key := countKey{bucket: resolvedAt - resolvedAt%size, area: area, typ: location.Type}
+
+		count, ok := byKey[key]
+		if !ok {
+			count = &ThroughputCount{Bucket: time.UnixMilli(key.bucket).UTC(), Area: area, Type: location.Type}
+			byKey[key] = count
+			counts = append(counts, count)
+		}
+
+		count.Count++
+	}
+
+	sort.Slice(counts, func(i, j int) bool {
+		if !counts[i].Bucket.Equal(counts[j].Bucket) {
+			return counts[i].Bucket.Before(counts[j].Bucket)
+		}
+
+		if counts[i].Area != counts[j].Area {
+			return counts[i].Area < counts[j].Area
+		}
+
+		return counts[i].Type < counts[j].Type
+	})
+
+	return counts, nil
+}
+
+func (r *memoryRepository) IsDuplicate(ctx context.Context, tweetContents string) (bool, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, location := range r.locations {
+		if location.TweetContents == tweetContents {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func (r *memoryRepository) GetDocumentsWithNoTweetContents(ctx context.Context) ([]*LocationDB, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	locs := make([]*LocationDB, 0)
+	for _, location := range r.sortedLocations(nil) {
+		if location.TweetContents == "" {
+			locs = append(locs, location)
+		}
+	}
+
+	return locs, nil
+}
+
+// logClaims is logClaims of the Mongo repository, the caller has to hold the lock.
+func (r *memoryRepository) logClaims(outcome string, now time.Time, claims ...*Claim) {
+	for _, claim := range claims {
+		entry := &ClaimLog{
+			EntryID: claim.EntryID,
+			Owner:   claim.Owner,
+			Outcome: outcome,
+			EndedAt: now,
+		}
+
+		if !claim.ClaimedAt.IsZero() {
+			seconds := now.Sub(claim.ClaimedAt).Seconds()
+			entry.Seconds = &seconds
+		}
+
+		r.claimLog = append(r.claimLog, entry)
+	}
+}
+
+func (r *memoryRepository) ClaimLocation(ctx context.Context, entryID int, owner string, duration time.Duration, max int) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	claimedAt := now
+	extended := false
+
+	if claim, ok := r.claims[entryID]; ok {
+		if claim.Owner != owner && claim.ExpiresAt.After(now) {
+			return false, nil
+		}
+
+		if claim.Owner == owner && !claim.ClaimedAt.IsZero() {
+			claimedAt = claim.ClaimedAt
+		}
+
+		if claim.Owner != owner {
+			r.logClaims(ClaimExpired, claim.ExpiresAt, claim)
+		}
+
+		extended = claim.Owner == owner && claim.ExpiresAt.After(now)
+	}
+
+	if max > 0 && !extended {
+		held := 0
+		for _, claim := range r.claims {
+			if claim.Owner == owner && claim.ExpiresAt.After(now) {
+				held++
+			}
+		}
+
+		if held >= max {
+			delete(r.claims, entryID)
+
+			return false, ErrTooManyClaims
+		}
+	}
+
+	r.claims[entryID] = &Claim{
+		EntryID:   entryID,
+		Owner:     owner,
+		ClaimedAt: claimedAt,
+		ExpiresAt: now.Add(duration),
+	}
+
+	return true, nil
+}
+
+func (r *memoryRepository) ReleaseLocation(ctx context.Context, entryID int, owner string, outcome string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	claim, ok := r.claims[entryID]
+	if !ok || claim.Owner != owner {
+		return nil
+	}
+
+	delete(r.claims, entryID)
+	r.logClaims(outcome, time.Now(), claim)
+
+	return nil
+}
+
+func (r *memoryRepository) IsClaimed(ctx context.Context, entryID int, owner string) (bool, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	claim, ok := r.claims[entryID]
+
+	return ok && claim.Owner != owner && claim.ExpiresAt.After(time.Now()), nil
+}
+
+// activeClaimIDs returns the entries of the active claims owner either holds or doesn't, sorted.
+func (r *memoryRepository) activeClaimIDs(owner string, owned bool) []int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	now := time.Now()
+	ids := make([]int, 0)
+
+	for _, claim := range r.claims {
+		if (claim.Owner == owner) == owned && claim.ExpiresAt.After(now) {
+			ids = append(ids, claim.EntryID)
+		}
+	}
+
+	sort.Ints(ids)
+
+	return ids
+}
+
+func (r *memoryRepository) GetClaimedIDs(ctx context.Context, owner string) ([]int, error) {
+	return r.activeClaimIDs(owner, false), nil
+}
+
+func (r *memoryRepository) GetOwnedClaimIDs(ctx context.Context, owner string) ([]int, error) {
+	return r.activeClaimIDs(owner, true), nil
+}
+
+func (r *memoryRepository) CountActiveClaims(ctx context.Context) (map[string]int, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	now := time.Now()
+	byOwner := make(map[string]int)
+
+	for _, claim := range r.claims {
+		if claim.ExpiresAt.After(now) {
+			byOwner[claim.Owner]++
+		}
+	}
+
+	return byOwner, nil
+}
+
+func (r *memoryRepository) GetClaimStats(ctx context.Context, since time.Time) ([]*ClaimStats, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	byOwner := make(map[string]*ClaimStats)
+	resolvedSeconds := make(map[string][]float64)
+	stats := make([]*ClaimStats, 0)
+
+	for _, entry := range r.claimLog {
+		if entry.EndedAt.Before(since) {
+			continue
+		}
+
+		owner, ok := byOwner[entry.Owner]
+		if !ok {
+			owner = &ClaimStats{Owner: entry.Owner}
+			byOwner[entry.Owner] = owner
+			stats = append(stats, owner)
+		}
+
+		owner.Claims++
+
+		switch entry.Outcome {
+		case ClaimResolved:
+			owner.Resolved++
+
+			if entry.Seconds != nil {
+				resolvedSeconds[entry.Owner] = append(resolvedSeconds[entry.Owner], *entry.Seconds)
+			}
+		case ClaimReleased:
+			owner.Released++
+		case ClaimExpired:
+			owner.Expired++
+		}
+	}
+
+	for _, owner := range stats {
+		seconds := resolvedSeconds[owner.Owner]
+		if len(seconds) == 0 {
+			continue
+		}
+
+		sum := 0.0
+		for _, s := range seconds {
+			sum += s
+		}
+
+		owner.AvgResolveSeconds = sum / float64(len(seconds))
+	}
+
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Owner < stats[j].Owner })
+
+	return stats, nil
+}
+
+func (r *memoryRepository) HideEntry(ctx context.Context, entryID int, actor *users.User) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.hidden[entryID] = &HiddenEntry{EntryID: entryID, HiddenBy: actor, HiddenAt: time.Now()}
+
+	return nil
+}
+
+func (r *memoryRepository) RestoreEntry(ctx context.Context, entryID int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.hidden[entryID]; !ok {
+		return ErrEntryNotHidden
+	}
+
+	delete(r.hidden, entryID)
+
+	return nil
+}
+
+func (r *memoryRepository) IsHidden(ctx context.Context, entryID int) (bool, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	_, ok := r.hidden[entryID]
+
+	return ok, nil
+}
+
+func (r *memoryRepository) GetHiddenIDs(ctx context.Context) ([]int, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	ids := make([]int, 0, len(r.hidden))
+	for id := range r.hidden {
+		ids = append(ids, id)
+	}
+
+	sort.Ints(ids)
+
+	return ids, nil
+}
+
+func (r *memoryRepository) AddIntakeEntry(ctx context.Context, entry *IntakeEntry) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	last := IntakeIDOffset
+	for _, e := range r.intake {
+		if e.EntryID > last {
+			last = e.EntryID
+		}
+	}
+
+	entry.CreatedAt = time.Now()
+	entry.EntryID = last + 1
+
+	stored := *entry
+	r.intake = append(r.intake, &stored)
+
+	return nil
+}
+
+func (r *memoryRepository) GetIntakeEntries(ctx context.Context) ([]*Location, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	locs := make([]*Location, 0, len(r.intake))
+	for _, entry := range r.intake {
+		locs = append(locs, entry.Location())
+	}
+
+	return locs, nil
+}
+
+// MigrateEvent tags the resolutions without an event, an empty event ID stands for a missing field here.
+func (r *memoryRepository) MigrateEvent(ctx context.Context, eventID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, location := range r.locations {
+		if location.EventID == "" {
+			location.EventID = eventID
+		}
+	}
+
+	return nil
+}
+
+func (r *memoryRepository) GetSenderResolutions(ctx context.Context, filter *SenderResolutionFilter) ([]*SenderResolution, int64, error) {
+	r.mu.RLock()
+	resolutions := make([]*SenderResolution, 0)
+
+	for _, location := range r.locations {
+		if !senderIs(location.Sender, filter.SenderID) {
+			continue
+		}
+
+		status := ResolutionApproved
+		if location.IsPendingReview() {
+			status = ResolutionPendingReview
+		}
+
+		resolutions = append(resolutions, &SenderResolution{Status: status, Location: copyLocation(location)})
+	}
+
+	for _, entry := range r.history {
+		if (entry.Action != HistoryActionUnresolve && entry.Action != HistoryActionExpire) || entry.Location == nil || !senderIs(entry.Location.Sender, filter.SenderID) {
+			continue
+		}
+
+		at := entry.At
+		resolutions = append(resolutions, &SenderResolution{Status: ResolutionRolledBack, RolledBackAt: &at, Location: copyLocation(entry.Location)})
+	}
+
+	// An edit by the volunteer themselves isn't a rollback, only a replacement by someone else is.
+	for _, revision := range r.revisions {
+		if revision.Before == nil || !senderIs(revision.Before.Sender, filter.SenderID) || (revision.After != nil && senderIs(revision.After.Sender, filter.SenderID)) {
+			continue
+		}
+
+		at := revision.At
+		resolutions = append(resolutions, &SenderResolution{Status: ResolutionRolledBack, RolledBackAt: &at, Location: copyLocation(revision.Before)})
+	}
+	r.mu.RUnlock()
+
+	if filter.Status != "" {
+		filtered := make([]*SenderResolution, 0, len(resolutions))
+		for _, resolution := range resolutions {
+			if resolution.Status == filter.Status {
+				filtered = append(filtered, resolution)
+			}
+		}
+
+		resolutions = filtered
+	}
+
+	sort.SliceStable(resolutions, func(i, j int) bool {
+		return bytes.Compare(resolutions[i].Location.ID[:], resolutions[j].Location.ID[:]) > 0
+	})
+
+	count := int64(len(resolutions))
+
+	page := filter.Page
+	if page < 1 {
+		page = 1
+	}
+
+	start := (page - 1) * filter.PageSize
+	if start > len(resolutions) {
+		start = len(resolutions)
+	}
+
+	end := len(resolutions)
+	if filter.PageSize > 0 && start+filter.PageSize < end {
+		end = start + filter.PageSize
+	}
+
+	return resolutions[start:end], count, nil
+}
+
+func (r *memoryRepository) ExpireClaims(ctx context.Context, now time.Time) ([]*Claim, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	claims := make([]*Claim, 0)
+	for entryID, claim := range r.claims {
+		if !claim.ExpiresAt.After(now) {
+			claims = append(claims, claim)
+			delete(r.claims, entryID)
+		}
+	}
+
+	sort.Slice(claims, func(i, j int) bool { return claims[i].EntryID < claims[j].EntryID })
+
+	r.logClaims(ClaimExpired, now, claims...)
+
+	return claims, nil
+}
+
+func (r *memoryRepository) GetStalePending(ctx context.Context, before time.Time) ([]*LocationDB, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	cutoff := primitive.NewObjectIDFromTimestamp(before)
+	locs := make([]*LocationDB, 0)
+
+	for _, location := range r.sortedLocations(&LocationFilter{Status: StatusPendingReview}) {
+		if bytes.Compare(location.ID[:], cutoff[:]) < 0 {
+			locs = append(locs, location)
+		}
+	}
+
+	return locs, nil
+}
+
+func (r *memoryRepository) ExpirePending(ctx context.Context, entryID int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	location, ok := r.locations[entryID]
+	if !ok {
+		return ErrLocationNotFound
+	}
+
+	if !location.IsPendingReview() {
+		return ErrNotPending
+	}
+
+	r.takeBack(location, HistoryActionExpire, nil)
+
+	return nil
+}
@@ -0,0 +1,99 @@
+package locations
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// HistoryActionExpire marks pending reviews nobody approved in time, they were taken back like an unresolve.
+const HistoryActionExpire = "expire"
+
+var ErrNotPending = errors.New("location isn't pending review")
+
+// ExpireClaims removes the claims which ran out before now, logs and returns them. Each one is removed on its
+// own, a claim taken again in between expires later and doesn't match anymore.
+func (r *repository) ExpireClaims(ctx context.Context, now time.Time) ([]*Claim, error) {
+	claims := make([]*Claim, 0)
+	defer func() {
+		r.logClaims(ctx, ClaimExpired, now, claims...)
+	}()
+
+	for {
+		claim := &Claim{}
+		if err := r.mongo.FindOneAndDelete(ctx, "claims", bson.D{{
+			Key:   "expires_at",
+			Value: bson.D{{Key: "$lte", Value: now}},
+		}}).Decode(claim); err != nil {
+			if errors.Is(err, mongo.ErrNoDocuments) {
+				return claims, nil
+			}
+
+			logrus.Errorln(err)
+
+			return nil, err
+		}
+
+		claims = append(claims, claim)
+	}
+}
+
+// GetStalePending returns the pending reviews resolved before the given time.
+func (r *repository) GetStalePending(ctx context.Context, before time.Time) ([]*LocationDB, error) {
+	cur, err := r.mongo.Find(ctx, "locations", bson.D{
+		{Key: "status", Value: StatusPendingReview},
+		{Key: "_id", Value: bson.D{{Key: "$lt", Value: primitive.NewObjectIDFromTimestamp(before)}}},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	locations := make([]*LocationDB, 0)
+	if err := cur.All(ctx, &locations); err != nil {
+		logrus.Errorln(err)
+		return nil, err
+	}
+
+	return locations, nil
+}
+
+// ExpirePending takes back a pending review so the entry is served again. Approved entries are left alone,
+// the approval may have come in after the entry was picked.
+func (r *repository) ExpirePending(ctx context.Context, entryID int) error {
+	location, err := r.GetLocation(ctx, entryID)
+	if err != nil {
+		return err
+	}
+
+	if !location.IsPendingReview() {
+		return ErrNotPending
+	}
+
+	if err := r.mongo.InsertOne(ctx, "location_history", &LocationHistory{
+		ID:       primitive.NewObjectIDFromTimestamp(time.Now()),
+		EntryID:  entryID,
+		Action:   HistoryActionExpire,
+		At:       time.Now(),
+		Location: location,
+	}); err != nil {
+		logrus.Errorln(err)
+
+		return err
+	}
+
+	if err := r.mongo.DeleteOne(ctx, "locations", bson.D{
+		{Key: "entry_id", Value: entryID},
+		{Key: "status", Value: StatusPendingReview},
+	}); err != nil {
+		logrus.Errorln(err)
+
+		return err
+	}
+
+	return nil
+}
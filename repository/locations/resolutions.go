@@ -0,0 +1,133 @@
+package locations
+
+import (
+	"context"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	ResolutionApproved      = "approved"
+	ResolutionPendingReview = "pending_review"
+	ResolutionRolledBack    = "rolled_back"
+)
+
+// SenderResolution is one resolution of a volunteer. A rolled back resolution was either taken back, expired
+// without an approval or replaced by someone else's edit, RolledBackAt is when that happened.
+type SenderResolution struct {
+	Status       string      `json:"status" bson:"status"`
+	RolledBackAt *time.Time  `json:"rolled_back_at,omitempty" bson:"rolled_back_at,omitempty"`
+	Location     *LocationDB `json:"location" bson:"location"`
+}
+
+type SenderResolutionFilter struct {
+	SenderID primitive.ObjectID
+	Status   string
+	Page     int
+	PageSize int
+}
+
+// GetSenderResolutions lists the resolutions sent by a volunteer, newest first. The current ones come from
+// locations, the rolled back ones from location_history and entry_revisions.
+func (r *repository) GetSenderResolutions(ctx context.Context, filter *SenderResolutionFilter) ([]*SenderResolution, int64, error) {
+	rolledBack := bson.D{{Key: "$literal", Value: ResolutionRolledBack}}
+
+	pipeline := bson.A{
+		bson.D{{Key: "$match", Value: bson.D{{Key: "sender._id", Value: filter.SenderID}}}},
+		bson.D{{Key: "$project", Value: bson.D{
+			{Key: "_id", Value: 0},
+			{Key: "status", Value: bson.D{{Key: "$cond", Value: bson.A{
+				bson.D{{Key: "$eq", Value: bson.A{"$status", StatusPendingReview}}},
+				ResolutionPendingReview,
+				ResolutionApproved,
+			}}}},
+			{Key: "location", Value: "$$ROOT"},
+		}}},
+		bson.D{{Key: "$unionWith", Value: bson.D{
+			{Key: "coll", Value: "location_history"},
+			{Key: "pipeline", Value: bson.A{
+				bson.D{{Key: "$match", Value: bson.D{
+					{Key: "action", Value: bson.D{{Key: "$in", Value: bson.A{HistoryActionUnresolve, HistoryActionExpire}}}},
+					{Key: "location.sender._id", Value: filter.SenderID},
+				}}},
+				bson.D{{Key: "$project", Value: bson.D{
+					{Key: "_id", Value: 0},
+					{Key: "status", Value: rolledBack},
+					{Key: "rolled_back_at", Value: "$at"},
+					{Key: "location", Value: 1},
+				}}},
+			}},
+		}}},
+		// An edit by the volunteer themselves isn't a rollback, only a replacement by someone else is.
+		bson.D{{Key: "$unionWith", Value: bson.D{
+			{Key: "coll", Value: "entry_revisions"},
+			{Key: "pipeline", Value: bson.A{
+				bson.D{{Key: "$match", Value: bson.D{
+					{Key: "before.sender._id", Value: filter.SenderID},
+					{Key: "after.sender._id", Value: bson.D{{Key: "$ne", Value: filter.SenderID}}},
+				}}},
+				bson.D{{Key: "$project", Value: bson.D{
+					{Key: "_id", Value: 0},
+					{Key: "status", Value: rolledBack},
+					{Key: "rolled_back_at", Value: "$at"},
+					{Key: "location", Value: "$before"},
+				}}},
+			}},
+		}}},
+	}
+
+	if filter.Status != "" {
+		pipeline = append(pipeline, bson.D{{Key: "$match", Value: bson.D{{Key: "status", Value: filter.Status}}}})
+	}
+
+	page := filter.Page
+	if page < 1 {
+		page = 1
+	}
+
+	pipeline = append(pipeline,
+		bson.D{{Key: "$sort", Value: bson.D{{Key: "location._id", Value: -1}}}},
+		bson.D{{Key: "$facet", Value: bson.D{
+			{Key: "count", Value: bson.A{bson.D{{Key: "$count", Value: "n"}}}},
+			{Key: "resolutions", Value: bson.A{
+				bson.D{{Key: "$skip", Value: (page - 1) * filter.PageSize}},
+				bson.D{{Key: "$limit", Value: filter.PageSize}},
+			}},
+		}}},
+	)
+
+	cur, err := r.mongo.Aggregate(ctx, "locations", pipeline)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	results := make([]struct {
+		Count []struct {
+			N int64 `bson:"n"`
+		} `bson:"count"`
+		Resolutions []*SenderResolution `bson:"resolutions"`
+	}, 0)
+	if err := cur.All(ctx, &results); err != nil {
+		logrus.Errorln(err)
+		return nil, 0, err
+	}
+
+	resolutions := make([]*SenderResolution, 0)
+	if len(results) == 0 {
+		return resolutions, 0, nil
+	}
+
+	if results[0].Resolutions != nil {
+		resolutions = results[0].Resolutions
+	}
+
+	var count int64
+	if len(results[0].Count) > 0 {
+		count = results[0].Count[0].N
+	}
+
+	return resolutions, count, nil
+}
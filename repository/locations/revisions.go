@@ -0,0 +1,106 @@
+package locations
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/YusufOzmen01/veri-kontrol-backend/repository/audit"
+	"github.com/YusufOzmen01/veri-kontrol-backend/repository/users"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	ErrRevisionNotFound = errors.New("revision not found")
+	ErrNothingToRestore = errors.New("the entry had no resolution before this revision")
+)
+
+// Revision is one edit of a resolution. Before is nil when the entry had no resolution yet, rolling back means
+// writing Before again. RollbackOf is set on revisions made by a rollback.
+type Revision struct {
+	ID         primitive.ObjectID  `json:"_id" bson:"_id"`
+	EntryID    int                 `json:"entry_id" bson:"entry_id"`
+	Editor     *users.User         `json:"editor" bson:"editor"`
+	At         time.Time           `json:"at" bson:"at"`
+	Changes    []*audit.Change     `json:"changes" bson:"changes"`
+	Before     *LocationDB         `json:"before" bson:"before"`
+	After      *LocationDB         `json:"after" bson:"after"`
+	RollbackOf *primitive.ObjectID `json:"rollback_of,omitempty" bson:"rollback_of,omitempty"`
+}
+
+func (r *repository) ensureRevisionIndexes(ctx context.Context) {
+	if _, err := r.mongo.CreateIndex(ctx, "entry_revisions", bson.E{Key: "entry_id", Value: 1}, bson.E{Key: "at", Value: -1}); err != nil {
+		logrus.Errorf("Couldn't create entry_revisions entry_id index: %s", err)
+	}
+}
+
+func (r *repository) addRevision(ctx context.Context, editor *users.User, before, after *LocationDB, rollbackOf *primitive.ObjectID) error {
+	if err := r.mongo.InsertOne(ctx, "entry_revisions", &Revision{
+		ID:         primitive.NewObjectIDFromTimestamp(time.Now()),
+		EntryID:    after.EntryID,
+		Editor:     editor,
+		At:         time.Now(),
+		Changes:    audit.Diff(before, after),
+		Before:     before,
+		After:      after,
+		RollbackOf: rollbackOf,
+	}); err != nil {
+		logrus.Errorln(err)
+
+		return err
+	}
+
+	return nil
+}
+
+// GetRevisions returns the edits of an entry, newest first.
+func (r *repository) GetRevisions(ctx context.Context, entryID int) ([]*Revision, error) {
+	cur, err := r.mongo.Find(ctx, "entry_revisions", bson.D{{
+		Key:   "entry_id",
+		Value: entryID,
+	}}, options.Find().SetSort(bson.D{{Key: "at", Value: -1}}))
+	if err != nil {
+		return nil, err
+	}
+
+	revisions := make([]*Revision, 0)
+	if err := cur.All(ctx, &revisions); err != nil {
+		logrus.Errorln(err)
+		return nil, err
+	}
+
+	return revisions, nil
+}
+
+// RollbackLocation undoes a revision by writing back the resolution it replaced. The rollback is a revision
+// of its own, so it can be undone the same way.
+func (r *repository) RollbackLocation(ctx context.Context, entryID int, revisionID primitive.ObjectID, editor *users.User) (*LocationDB, error) {
+	revision := &Revision{}
+	if err := r.mongo.FindOne(ctx, "entry_revisions", bson.D{
+		{Key: "_id", Value: revisionID},
+		{Key: "entry_id", Value: entryID},
+	}).Decode(revision); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, ErrRevisionNotFound
+		}
+
+		return nil, err
+	}
+
+	if revision.Before == nil {
+		return nil, ErrNothingToRestore
+	}
+
+	restored := *revision.Before
+	restored.ID = primitive.NewObjectIDFromTimestamp(time.Now())
+
+	if err := r.replaceLocation(ctx, &restored, editor, &revision.ID); err != nil {
+		return nil, err
+	}
+
+	return &restored, nil
+}
@@ -0,0 +1,342 @@
+package locations
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type ReasonCount struct {
+	Reason string `json:"reason" bson:"reason"`
+	Count  int    `json:"count" bson:"count"`
+}
+
+type ModeratorDailyStats struct {
+	Day     string         `json:"day" bson:"day"`
+	Count   int            `json:"count" bson:"count"`
+	Reasons []*ReasonCount `json:"reasons" bson:"reasons"`
+}
+
+type ModeratorStats struct {
+	SenderID primitive.ObjectID     `json:"sender_id" bson:"sender_id"`
+	Name     string                 `json:"name" bson:"name"`
+	Total    int                    `json:"total" bson:"total"`
+	Days     []*ModeratorDailyStats `json:"days" bson:"days"`
+}
+
+// resolvedDay turns the resolution time, which is encoded in the document's ObjectID, into a YYYY-MM-DD string.
+var resolvedDay = bson.D{{Key: "$dateToString", Value: bson.D{
+	{Key: "format", Value: "%Y-%m-%d"},
+	{Key: "date", Value: bson.D{{Key: "$toDate", Value: "$_id"}}},
+}}}
+
+// eventMatch narrows a $match down to the resolutions of an event, an empty eventID keeps all of them.
+func eventMatch(match bson.D, eventID string) bson.D {
+	if eventID == "" {
+		return match
+	}
+
+	return append(match, bson.E{Key: "event_id", Value: eventID})
+}
+
+// GetModeratorStats counts the resolutions of the event per sender, day and reason, every event when eventID
+// is empty.
+func (r *repository) GetModeratorStats(ctx context.Context, eventID string) ([]*ModeratorStats, error) {
+	pipeline := bson.A{
+		bson.D{{Key: "$match", Value: eventMatch(bson.D{{Key: "sender", Value: bson.D{{Key: "$ne", Value: nil}}}}, eventID)}},
+		bson.D{{Key: "$group", Value: bson.D{
+			{Key: "_id", Value: bson.D{
+				{Key: "sender_id", Value: "$sender._id"},
+				{Key: "name", Value: "$sender.name"},
+				{Key: "day", Value: resolvedDay},
+				{Key: "reason", Value: "$reason"},
+			}},
+			{Key: "count", Value: bson.D{{Key: "$sum", Value: 1}}},
+		}}},
+		bson.D{{Key: "$group", Value: bson.D{
+			{Key: "_id", Value: bson.D{
+				{Key: "sender_id", Value: "$_id.sender_id"},
+				{Key: "name", Value: "$_id.name"},
+				{Key: "day", Value: "$_id.day"},
+			}},
+			{Key: "count", Value: bson.D{{Key: "$sum", Value: "$count"}}},
+			{Key: "reasons", Value: bson.D{{Key: "$push", Value: bson.D{
+				{Key: "reason", Value: "$_id.reason"},
+				{Key: "count", Value: "$count"},
+			}}}},
+		}}},
+		bson.D{{Key: "$sort", Value: bson.D{{Key: "_id.day", Value: 1}}}},
+		bson.D{{Key: "$group", Value: bson.D{
+			{Key: "_id", Value: bson.D{
+				{Key: "sender_id", Value: "$_id.sender_id"},
+				{Key: "name", Value: "$_id.name"},
+			}},
+			{Key: "total", Value: bson.D{{Key: "$sum", Value: "$count"}}},
+			{Key: "days", Value: bson.D{{Key: "$push", Value: bson.D{
+				{Key: "day", Value: "$_id.day"},
+				{Key: "count", Value: "$count"},
+				{Key: "reasons", Value: "$reasons"},
+			}}}},
+		}}},
+		bson.D{{Key: "$project", Value: bson.D{
+			{Key: "_id", Value: 0},
+			{Key: "sender_id", Value: "$_id.sender_id"},
+			{Key: "name", Value: "$_id.name"},
+			{Key: "total", Value: 1},
+			{Key: "days", Value: 1},
+		}}},
+		bson.D{{Key: "$sort", Value: bson.D{{Key: "total", Value: -1}}}},
+	}
+
+	cur, err := r.mongo.Aggregate(ctx, "locations", pipeline)
+	if err != nil {
+		return nil, err
+	}
+
+	stats := make([]*ModeratorStats, 0)
+	if err := cur.All(ctx, &stats); err != nil {
+		logrus.Errorln(err)
+		return nil, err
+	}
+
+	return stats, nil
+}
+
+type LeaderboardEntry struct {
+	Rank     int                `json:"rank" bson:"-"`
+	SenderID primitive.ObjectID `json:"-" bson:"sender_id"`
+	Name     string             `json:"name" bson:"name"`
+	Count    int                `json:"count" bson:"count"`
+}
+
+// GetLeaderboard counts resolutions per sender since the given time, spam reports don't count. Senders who
+// share a count share the rank, the next rank skips the places they took. The limit doesn't cut through a tie,
+// the senders tied with the last one are returned as well. An empty eventID counts every event.
+func (r *repository) GetLeaderboard(ctx context.Context, eventID string, since time.Time, excluded []primitive.ObjectID, limit int) ([]*LeaderboardEntry, error) {
+	match := bson.D{
+		{Key: "sender", Value: bson.D{{Key: "$ne", Value: nil}}},
+		{Key: "type", Value: bson.D{{Key: "$ne", Value: TypeSpam}}},
+	}
+
+	if len(excluded) > 0 {
+		match = append(match, bson.E{Key: "sender._id", Value: bson.D{{Key: "$nin", Value: excluded}}})
+	}
+
+	if !since.IsZero() {
+		match = append(match, bson.E{Key: "_id", Value: bson.D{{Key: "$gte", Value: primitive.NewObjectIDFromTimestamp(since)}}})
+	}
+
+	pipeline := bson.A{
+		bson.D{{Key: "$match", Value: eventMatch(match, eventID)}},
+		// Oldest first, so $last picks the name of the latest resolution.
+		bson.D{{Key: "$sort", Value: bson.D{{Key: "_id", Value: 1}}}},
+		bson.D{{Key: "$group", Value: bson.D{
+			{Key: "_id", Value: "$sender._id"},
+			{Key: "name", Value: bson.D{{Key: "$last", Value: "$sender.name"}}},
+			{Key: "count", Value: bson.D{{Key: "$sum", Value: 1}}},
+		}}},
+		bson.D{{Key: "$sort", Value: bson.D{{Key: "count", Value: -1}, {Key: "_id", Value: 1}}}},
+		bson.D{{Key: "$project", Value: bson.D{
+			{Key: "_id", Value: 0},
+			{Key: "sender_id", Value: "$_id"},
+			{Key: "name", Value: 1},
+			{Key: "count", Value: 1},
+		}}},
+	}
+
+	cur, err := r.mongo.Aggregate(ctx, "locations", pipeline)
+	if err != nil {
+		return nil, err
+	}
+
+	defer cur.Close(ctx)
+
+	entries := make([]*LeaderboardEntry, 0)
+	for cur.Next(ctx) {
+		entry := &LeaderboardEntry{}
+		if err := cur.Decode(entry); err != nil {
+			logrus.Errorln(err)
+			return nil, err
+		}
+
+		if len(entries) >= limit && entries[len(entries)-1].Count != entry.Count {
+			break
+		}
+
+		entries = append(entries, entry)
+	}
+
+	if err := cur.Err(); err != nil {
+		logrus.Errorln(err)
+		return nil, err
+	}
+
+	rankLeaderboard(entries)
+
+	return entries, nil
+}
+
+// rankLeaderboard ranks entries sorted by count, ties share the rank of the first of them: 1, 2, 2, 4.
+func rankLeaderboard(entries []*LeaderboardEntry) {
+	for i, entry := range entries {
+		entry.Rank = i + 1
+		if i > 0 && entries[i-1].Count == entry.Count {
+			entry.Rank = entries[i-1].Rank
+		}
+	}
+}
+
+type DailyStats struct {
+	Day                  string  `json:"day" bson:"_id"`
+	Resolved             int     `json:"resolved" bson:"resolved"`
+	Spam                 int     `json:"spam" bson:"spam"`
+	AvgResolutionSeconds float64 `json:"avg_resolution_seconds" bson:"avg_resolution_seconds"`
+}
+
+// GetDailyStats counts the resolutions per day since the given time. The resolution latency is the time from
+// the entry being reported to it being resolved, entries without a report time are left out of the average.
+// An empty eventID counts every event.
+func (r *repository) GetDailyStats(ctx context.Context, eventID string, since time.Time) ([]*DailyStats, error) {
+	resolvedAt := bson.D{{Key: "$toLong", Value: bson.D{{Key: "$toDate", Value: "$_id"}}}}
+
+	pipeline := bson.A{
+		bson.D{{Key: "$match", Value: eventMatch(bson.D{{Key: "_id", Value: bson.D{{Key: "$gte", Value: primitive.NewObjectIDFromTimestamp(since)}}}}, eventID)}},
+		bson.D{{Key: "$group", Value: bson.D{
+			{Key: "_id", Value: resolvedDay},
+			{Key: "resolved", Value: bson.D{{Key: "$sum", Value: 1}}},
+			{Key: "spam", Value: bson.D{{Key: "$sum", Value: bson.D{{Key: "$cond", Value: bson.A{
+				bson.D{{Key: "$eq", Value: bson.A{"$type", TypeSpam}}}, 1, 0,
+			}}}}}},
+			{Key: "avg_resolution_seconds", Value: bson.D{{Key: "$avg", Value: bson.D{{Key: "$cond", Value: bson.A{
+				bson.D{{Key: "$gt", Value: bson.A{"$epoch", 0}}},
+				bson.D{{Key: "$divide", Value: bson.A{
+					bson.D{{Key: "$subtract", Value: bson.A{resolvedAt, bson.D{{Key: "$multiply", Value: bson.A{"$epoch", 1000}}}}}},
+					1000,
+				}}},
+				nil,
+			}}}}}},
+		}}},
+		bson.D{{Key: "$sort", Value: bson.D{{Key: "_id", Value: 1}}}},
+	}
+
+	cur, err := r.mongo.Aggregate(ctx, "locations", pipeline)
+	if err != nil {
+		return nil, err
+	}
+
+	stats := make([]*DailyStats, 0)
+	if err := cur.All(ctx, &stats); err != nil {
+		logrus.Errorln(err)
+		return nil, err
+	}
+
+	return stats, nil
+}
+
+// ThroughputArea is an area the throughput is split by, like a city. ID 0 is left for the resolutions outside
+// of every area.
+type ThroughputArea struct {
+	ID      int
+	Polygon [][]float64 // [lat, lng] vertices, same layout as the stored location
+}
+
+// ThroughputCount is how many resolutions of a type in an area fall into the bucket which starts at Bucket.
+type ThroughputCount struct {
+	Bucket time.Time `json:"bucket" bson:"bucket"`
+	Area   int       `json:"area" bson:"area"`
+	Type   int       `json:"type" bson:"type"`
+	Count  int       `json:"count" bson:"count"`
+}
+
+// GetThroughput counts the matching resolutions per bucket, area and type with a single aggregation. Buckets are
+// aligned to the Unix epoch, so the same bucket size always splits the time the same way. A resolution is
+// counted in the first area it lies in, those in none of them and those without a location in area 0.
+func (r *repository) GetThroughput(ctx context.Context, filter *LocationFilter, bucket time.Duration, areas []*ThroughputArea) ([]*ThroughputCount, error) {
+	resolvedAt := bson.D{{Key: "$toLong", Value: bson.D{{Key: "$toDate", Value: "$_id"}}}}
+
+	count := func(area int, match bson.D) bson.A {
+		stages := bson.A{}
+		if len(match) > 0 {
+			stages = append(stages, bson.D{{Key: "$match", Value: match}})
+		}
+
+		return append(stages,
+			bson.D{{Key: "$group", Value: bson.D{
+				{Key: "_id", Value: bson.D{
+					{Key: "bucket", Value: bson.D{{Key: "$toDate", Value: bson.D{{Key: "$subtract", Value: bson.A{
+						resolvedAt,
+						bson.D{{Key: "$mod", Value: bson.A{resolvedAt, bucket.Milliseconds()}}},
+					}}}}}},
+					{Key: "type", Value: "$type"},
+				}},
+				{Key: "count", Value: bson.D{{Key: "$sum", Value: 1}}},
+			}}},
+			bson.D{{Key: "$project", Value: bson.D{
+				{Key: "_id", Value: 0},
+				{Key: "bucket", Value: "$_id.bucket"},
+				{Key: "area", Value: bson.D{{Key: "$literal", Value: area}}},
+				{Key: "type", Value: "$_id.type"},
+				{Key: "count", Value: 1},
+			}}},
+		)
+	}
+
+	within := func(polygon [][]float64) bson.D {
+		return bson.D{{Key: "point", Value: bson.D{{Key: "$geoWithin", Value: bson.D{{Key: "$geometry", Value: geoPolygon(polygon)}}}}}}
+	}
+
+	// Every facet leaves out what an earlier area has, so overlapping areas don't count a resolution twice.
+	facets := bson.D{}
+	branches := bson.A{}
+	earlier := bson.A{}
+
+	for i, area := range areas {
+		if len(area.Polygon) < 3 {
+			continue
+		}
+
+		match := within(area.Polygon)
+		if len(earlier) > 0 {
+			match = append(match, bson.E{Key: "$nor", Value: append(bson.A{}, earlier...)})
+		}
+
+		name := fmt.Sprintf("area_%d", i)
+		facets = append(facets, bson.E{Key: name, Value: count(area.ID, match)})
+		branches = append(branches, "$"+name)
+		earlier = append(earlier, within(area.Polygon))
+	}
+
+	outside := bson.D{}
+	if len(earlier) > 0 {
+		outside = bson.D{{Key: "$nor", Value: earlier}}
+	}
+
+	facets = append(facets, bson.E{Key: "outside", Value: count(0, outside)})
+	branches = append(branches, "$outside")
+
+	pipeline := bson.A{
+		bson.D{{Key: "$match", Value: filter.query()}},
+		bson.D{{Key: "$facet", Value: facets}},
+		bson.D{{Key: "$project", Value: bson.D{{Key: "counts", Value: bson.D{{Key: "$concatArrays", Value: branches}}}}}},
+		bson.D{{Key: "$unwind", Value: "$counts"}},
+		bson.D{{Key: "$replaceRoot", Value: bson.D{{Key: "newRoot", Value: "$counts"}}}},
+		bson.D{{Key: "$sort", Value: bson.D{{Key: "bucket", Value: 1}, {Key: "area", Value: 1}, {Key: "type", Value: 1}}}},
+	}
+
+	cur, err := r.mongo.Aggregate(ctx, "locations", pipeline)
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make([]*ThroughputCount, 0)
+	if err := cur.All(ctx, &counts); err != nil {
+		logrus.Errorln(err)
+		return nil, err
+	}
+
+	return counts, nil
+}
@@ -0,0 +1,113 @@
+package locations
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/YusufOzmen01/veri-kontrol-backend/repository/users"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestLeaderboardTies(t *testing.T) {
+	ctx := context.Background()
+	repository := NewMemoryRepository()
+
+	senders := make([]*users.User, 5)
+	for i := range senders {
+		senders[i] = &users.User{ID: primitive.NewObjectID(), Name: string(rune('a' + i))}
+	}
+
+	// a: 3, b: 2, c: 2, d: 1, e: 1
+	entryID := 0
+	for i, count := range []int{3, 2, 2, 1, 1} {
+		for j := 0; j < count; j++ {
+			entryID++
+
+			if err := repository.ResolveLocation(ctx, &LocationDB{
+				ID:       primitive.NewObjectID(),
+				EntryID:  entryID,
+				Location: []float64{36.2, 36.16},
+				Sender:   senders[i],
+			}); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	tests := []struct {
+		limit int
+		ranks []int
+	}{
+		{10, []int{1, 2, 2, 4, 4}},
+		{2, []int{1, 2, 2}},
+		{4, []int{1, 2, 2, 4, 4}},
+		{1, []int{1}},
+	}
+
+	for _, tt := range tests {
+		entries, err := repository.GetLeaderboard(ctx, "", time.Time{}, nil, tt.limit)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ranks := make([]int, len(entries))
+		for i, entry := range entries {
+			ranks[i] = entry.Rank
+		}
+
+		if len(ranks) != len(tt.ranks) {
+			t.Fatalf("limit %d: ranks %v, want %v", tt.limit, ranks, tt.ranks)
+		}
+
+		for i := range ranks {
+			if ranks[i] != tt.ranks[i] {
+				t.Fatalf("limit %d: ranks %v, want %v", tt.limit, ranks, tt.ranks)
+			}
+		}
+	}
+}
+
+func TestLeaderboardEvent(t *testing.T) {
+	ctx := context.Background()
+	repository := NewMemoryRepository()
+
+	sender := &users.User{ID: primitive.NewObjectID(), Name: "a"}
+	for i, eventID := range []string{"earthquake", "earthquake", "flood"} {
+		if err := repository.ResolveLocation(ctx, &LocationDB{
+			ID:       primitive.NewObjectID(),
+			EntryID:  i + 1,
+			Location: []float64{36.2, 36.16},
+			Sender:   sender,
+			EventID:  eventID,
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		eventID string
+		count   int
+	}{
+		{"", 3},
+		{"earthquake", 2},
+		{"flood", 1},
+		{"fire", 0},
+	}
+
+	for _, tt := range tests {
+		entries, err := repository.GetLeaderboard(ctx, tt.eventID, time.Time{}, nil, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		count := 0
+		if len(entries) > 0 {
+			count = entries[0].Count
+		}
+
+		if count != tt.count {
+			t.Errorf("event %q: count %d, want %d", tt.eventID, count, tt.count)
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package notifications
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/YusufOzmen01/veri-kontrol-backend/core/sources"
+	"github.com/YusufOzmen01/veri-kontrol-backend/repository/users"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type Repository interface {
+	GetRules(ctx context.Context) ([]*Rule, error)
+	GetRule(ctx context.Context, id primitive.ObjectID) (*Rule, error)
+	AddRule(ctx context.Context, rule *Rule) error
+	DeleteRule(ctx context.Context, id primitive.ObjectID) error
+}
+
+var ErrRuleNotFound = errors.New("rule not found")
+
+type repository struct {
+	mongo sources.MongoClient
+}
+
+func NewRepository(mongo sources.MongoClient) Repository {
+	return &repository{
+		mongo: mongo,
+	}
+}
+
+// Rule sends an alert to ChatID for new entries containing any of the keywords, inside any of the cities.
+// An empty list doesn't restrict, but a rule needs at least one of them.
+type Rule struct {
+	ID        primitive.ObjectID `json:"_id" bson:"_id"`
+	Name      string             `json:"name" bson:"name"`
+	Keywords  []string           `json:"keywords" bson:"keywords"`
+	CityIDs   []int              `json:"city_ids" bson:"city_ids"`
+	ChatID    string             `json:"chat_id" bson:"chat_id"`
+	CreatedBy *users.User        `json:"created_by" bson:"created_by"`
+	CreatedAt time.Time          `json:"created_at" bson:"created_at"`
+}
+
+func (r *Rule) Validate() error {
+	if r.Name == "" {
+		return fmt.Errorf("rule name is required")
+	}
+
+	if r.ChatID == "" {
+		return fmt.Errorf("chat_id is required")
+	}
+
+	if len(r.Keywords) == 0 && len(r.CityIDs) == 0 {
+		return fmt.Errorf("a rule needs keywords or cities")
+	}
+
+	for _, keyword := range r.Keywords {
+		if strings.TrimSpace(keyword) == "" {
+			return fmt.Errorf("keywords must not be empty")
+		}
+	}
+
+	return nil
+}
+
+// MatchesText looks for the keywords case insensitively with the Turkish rules, so "ENKAZ ALTINDA" matches
+// "enkaz altında".
+func (r *Rule) MatchesText(text string) bool {
+	if len(r.Keywords) == 0 {
+		return true
+	}
+
+	text = strings.ToLowerSpecial(unicode.TurkishCase, text)
+	for _, keyword := range r.Keywords {
+		if strings.Contains(text, strings.ToLowerSpecial(unicode.TurkishCase, strings.TrimSpace(keyword))) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (r *repository) GetRules(ctx context.Context) ([]*Rule, error) {
+	cur, err := r.mongo.Find(ctx, "notification_rules", bson.D{}, options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+
+	rules := make([]*Rule, 0)
+	if err := cur.All(ctx, &rules); err != nil {
+		logrus.Errorln(err)
+		return nil, err
+	}
+
+	return rules, nil
+}
+
+func (r *repository) GetRule(ctx context.Context, id primitive.ObjectID) (*Rule, error) {
+	rule := &Rule{}
+	if err := r.mongo.FindOne(ctx, "notification_rules", bson.D{{
+		Key:   "_id",
+		Value: id,
+	}}).Decode(rule); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, ErrRuleNotFound
+		}
+
+		return nil, err
+	}
+
+	return rule, nil
+}
+
+func (r *repository) AddRule(ctx context.Context, rule *Rule) error {
+	rule.ID = primitive.NewObjectIDFromTimestamp(time.Now())
+	rule.CreatedAt = time.Now()
+
+	if rule.Keywords == nil {
+		rule.Keywords = make([]string, 0)
+	}
+
+	if rule.CityIDs == nil {
+		rule.CityIDs = make([]int, 0)
+	}
+
+	if err := r.mongo.InsertOne(ctx, "notification_rules", rule); err != nil {
+		logrus.Errorln(err)
+
+		return err
+	}
+
+	return nil
+}
+
+func (r *repository) DeleteRule(ctx context.Context, id primitive.ObjectID) error {
+	return r.mongo.DeleteOne(ctx, "notification_rules", bson.D{{
+		Key:   "_id",
+		Value: id,
+	}})
+}
@@ -0,0 +1,239 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/YusufOzmen01/veri-kontrol-backend/core/sources"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type Repository interface {
+	Add(ctx context.Context, messages ...*Message) error
+	GetMessage(ctx context.Context, id primitive.ObjectID) (*Message, error)
+	ClaimDue(ctx context.Context, now time.Time, lease time.Duration) (*Message, error)
+	UpdateMessage(ctx context.Context, message *Message) error
+	GetMessages(ctx context.Context, filter *MessageFilter) ([]*Message, error)
+	CountMessages(ctx context.Context, filter *MessageFilter) (int64, error)
+}
+
+var (
+	ErrMessageNotFound = errors.New("outbox message not found")
+	ErrNothingDue      = errors.New("no outbox message is due")
+)
+
+// A message is pending until it was handled. Dead messages ran out of attempts and stay until somebody
+// retries them, handled ones are removed after DoneTTL.
+const (
+	StatusPending = "pending"
+	StatusDone    = "done"
+	StatusDead    = "dead"
+
+	DoneTTL = 7 * 24 * time.Hour
+)
+
+var Statuses = []string{StatusPending, StatusDone, StatusDead}
+
+type repository struct {
+	mongo sources.MongoClient
+}
+
+func NewRepository(mongo sources.MongoClient) Repository {
+	r := &repository{
+		mongo: mongo,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	r.ensureIndexes(ctx)
+
+	return r
+}
+
+func (r *repository) ensureIndexes(ctx context.Context) {
+	if _, err := r.mongo.CreateIndex(ctx, "outbox", bson.E{Key: "status", Value: 1}, bson.E{Key: "next_attempt_at", Value: 1}); err != nil {
+		logrus.Errorf("Couldn't create outbox status index: %s", err)
+	}
+
+	if _, err := r.mongo.CreateIndexWithOptions(ctx, "outbox", options.Index().SetExpireAfterSeconds(0), bson.E{Key: "expires_at", Value: 1}); err != nil {
+		logrus.Errorf("Couldn't create outbox expires_at index: %s", err)
+	}
+}
+
+// Message is an event written together with the change it is about, so it is published even when the process
+// dies right after the change. Payload is the JSON the handlers of the topic get, Handled names the handlers
+// which are done with it, a retry only runs the others. LeaseID names the claim of the replica handling it.
+type Message struct {
+	ID            primitive.ObjectID `json:"_id" bson:"_id"`
+	Topic         string             `json:"topic" bson:"topic"`
+	Payload       string             `json:"payload" bson:"payload"`
+	Status        string             `json:"status" bson:"status"`
+	Attempts      int                `json:"attempts" bson:"attempts"`
+	LastError     string             `json:"last_error,omitempty" bson:"last_error,omitempty"`
+	Handled       []string           `json:"handled,omitempty" bson:"handled,omitempty"`
+	LeaseID       string             `json:"-" bson:"lease_id,omitempty"`
+	NextAttemptAt time.Time          `json:"next_attempt_at" bson:"next_attempt_at"`
+	CreatedAt     time.Time          `json:"created_at" bson:"created_at"`
+	DoneAt        *time.Time         `json:"done_at,omitempty" bson:"done_at,omitempty"`
+	ExpiresAt     *time.Time         `json:"-" bson:"expires_at,omitempty"`
+}
+
+// NewMessage encodes v as the payload of a pending message which is due right away.
+func NewMessage(topic string, v interface{}) (*Message, error) {
+	payload, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Message{
+		Topic:         topic,
+		Payload:       string(payload),
+		Status:        StatusPending,
+		NextAttemptAt: time.Now(),
+	}, nil
+}
+
+type MessageFilter struct {
+	Status   string
+	Topic    string
+	Page     int
+	PageSize int
+}
+
+func (f *MessageFilter) query() bson.D {
+	query := bson.D{}
+
+	if f.Status != "" {
+		query = append(query, bson.E{Key: "status", Value: f.Status})
+	}
+
+	if f.Topic != "" {
+		query = append(query, bson.E{Key: "topic", Value: f.Topic})
+	}
+
+	return query
+}
+
+func (r *repository) Add(ctx context.Context, messages ...*Message) error {
+	if len(messages) == 0 {
+		return nil
+	}
+
+	documents := make([]interface{}, 0, len(messages))
+	for _, message := range messages {
+		message.ID = primitive.NewObjectIDFromTimestamp(time.Now())
+		message.CreatedAt = time.Now()
+
+		documents = append(documents, message)
+	}
+
+	if err := r.mongo.InsertMany(ctx, "outbox", documents); err != nil {
+		logrus.Errorln(err)
+
+		return err
+	}
+
+	return nil
+}
+
+func (r *repository) GetMessage(ctx context.Context, id primitive.ObjectID) (*Message, error) {
+	message := &Message{}
+	if err := r.mongo.FindOne(ctx, "outbox", bson.D{{
+		Key:   "_id",
+		Value: id,
+	}}).Decode(message); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, ErrMessageNotFound
+		}
+
+		return nil, err
+	}
+
+	return message, nil
+}
+
+// ClaimDue takes the pending message which waited the longest for its next attempt, ErrNothingDue when there is
+// none. Finding and claiming it is one step, the next attempt is pushed back by the lease so no other replica
+// takes it meanwhile. A replica which dies while handling it leaves it to the others once the lease is over.
+func (r *repository) ClaimDue(ctx context.Context, now time.Time, lease time.Duration) (*Message, error) {
+	leaseID := primitive.NewObjectID().Hex()
+
+	message := &Message{}
+	if err := r.mongo.FindOneAndUpdate(ctx, "outbox", bson.D{
+		{Key: "status", Value: StatusPending},
+		{Key: "next_attempt_at", Value: bson.D{{Key: "$lte", Value: now}}},
+	}, bson.D{{
+		Key: "$set",
+		Value: bson.D{
+			{Key: "next_attempt_at", Value: now.Add(lease)},
+			{Key: "lease_id", Value: leaseID},
+		},
+	}}, options.FindOneAndUpdate().SetSort(bson.D{{Key: "next_attempt_at", Value: 1}}).SetReturnDocument(options.After)).Decode(message); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, ErrNothingDue
+		}
+
+		return nil, err
+	}
+
+	return message, nil
+}
+
+// UpdateMessage stores the outcome of an attempt. A message claimed by another replica since, because the lease
+// ran out, is left to that one.
+func (r *repository) UpdateMessage(ctx context.Context, message *Message) error {
+	filter := bson.D{{Key: "_id", Value: message.ID}}
+	if message.LeaseID != "" {
+		filter = append(filter, bson.E{Key: "lease_id", Value: message.LeaseID})
+	}
+
+	return r.mongo.UpdateOne(ctx, "outbox", filter, bson.D{{
+		Key: "$set",
+		Value: bson.D{
+			{Key: "status", Value: message.Status},
+			{Key: "attempts", Value: message.Attempts},
+			{Key: "last_error", Value: message.LastError},
+			{Key: "handled", Value: message.Handled},
+			{Key: "next_attempt_at", Value: message.NextAttemptAt},
+			{Key: "done_at", Value: message.DoneAt},
+			{Key: "expires_at", Value: message.ExpiresAt},
+		},
+	}})
+}
+
+// GetMessages lists the newest messages first.
+func (r *repository) GetMessages(ctx context.Context, filter *MessageFilter) ([]*Message, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "_id", Value: -1}})
+	if filter.PageSize > 0 {
+		page := filter.Page
+		if page < 1 {
+			page = 1
+		}
+
+		opts = opts.SetSkip(int64((page - 1) * filter.PageSize)).SetLimit(int64(filter.PageSize))
+	}
+
+	cur, err := r.mongo.Find(ctx, "outbox", filter.query(), opts)
+	if err != nil {
+		return nil, err
+	}
+
+	messages := make([]*Message, 0)
+	if err := cur.All(ctx, &messages); err != nil {
+		logrus.Errorln(err)
+		return nil, err
+	}
+
+	return messages, nil
+}
+
+func (r *repository) CountMessages(ctx context.Context, filter *MessageFilter) (int64, error) {
+	return r.mongo.Count(ctx, "outbox", filter.query())
+}
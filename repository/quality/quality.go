@@ -0,0 +1,246 @@
+package quality
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/YusufOzmen01/veri-kontrol-backend/core/sources"
+	"github.com/YusufOzmen01/veri-kontrol-backend/repository/locations"
+	"github.com/YusufOzmen01/veri-kontrol-backend/repository/users"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type Repository interface {
+	AddReview(ctx context.Context, review *Review) error
+	GetReview(ctx context.Context, id primitive.ObjectID) (*Review, error)
+	GetReviews(ctx context.Context, filter *ReviewFilter) ([]*Review, error)
+	CountReviews(ctx context.Context, filter *ReviewFilter) (int64, error)
+	GradeReview(ctx context.Context, id primitive.ObjectID, grade, note string, reviewer *users.User) error
+	GetModeratorQuality(ctx context.Context) ([]*ModeratorQuality, error)
+}
+
+var (
+	ErrReviewNotFound = errors.New("review not found")
+	ErrAlreadyGraded  = errors.New("review was already graded")
+)
+
+const (
+	GradeCorrect    = "correct"
+	GradeMinorIssue = "minor_issue"
+	GradeWrong      = "wrong"
+)
+
+var Grades = []string{GradeCorrect, GradeMinorIssue, GradeWrong}
+
+type repository struct {
+	mongo sources.MongoClient
+}
+
+func NewRepository(mongo sources.MongoClient) Repository {
+	r := &repository{
+		mongo: mongo,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	r.ensureIndexes(ctx)
+
+	return r
+}
+
+func (r *repository) ensureIndexes(ctx context.Context) {
+	if _, err := r.mongo.CreateIndex(ctx, "qa_reviews", bson.E{Key: "grade", Value: 1}, bson.E{Key: "_id", Value: 1}); err != nil {
+		logrus.Errorf("Couldn't create qa_reviews grade index: %s", err)
+	}
+
+	if _, err := r.mongo.CreateIndex(ctx, "qa_reviews", bson.E{Key: "moderator._id", Value: 1}); err != nil {
+		logrus.Errorf("Couldn't create qa_reviews moderator._id index: %s", err)
+	}
+}
+
+// Review is a sampled resolution waiting for, or graded by, a reviewer. Location is the resolution as it was
+// sampled, later edits don't change what is graded.
+type Review struct {
+	ID        primitive.ObjectID    `json:"_id" bson:"_id"`
+	EntryID   int                   `json:"entry_id" bson:"entry_id"`
+	Moderator *users.User           `json:"moderator" bson:"moderator"`
+	Location  *locations.LocationDB `json:"location" bson:"location"`
+	Grade     string                `json:"grade,omitempty" bson:"grade"`
+	Note      string                `json:"note,omitempty" bson:"note,omitempty"`
+	Reviewer  *users.User           `json:"reviewer,omitempty" bson:"reviewer,omitempty"`
+	CreatedAt time.Time             `json:"created_at" bson:"created_at"`
+	GradedAt  *time.Time            `json:"graded_at,omitempty" bson:"graded_at,omitempty"`
+}
+
+// ReviewFilter lists the ungraded reviews unless Graded is set.
+type ReviewFilter struct {
+	Graded      bool
+	ModeratorID primitive.ObjectID
+	Page        int
+	PageSize    int
+}
+
+func (f *ReviewFilter) query() bson.D {
+	query := bson.D{{Key: "grade", Value: ""}}
+	if f.Graded {
+		query = bson.D{{Key: "grade", Value: bson.D{{Key: "$ne", Value: ""}}}}
+	}
+
+	if !f.ModeratorID.IsZero() {
+		query = append(query, bson.E{Key: "moderator._id", Value: f.ModeratorID})
+	}
+
+	return query
+}
+
+func (r *repository) AddReview(ctx context.Context, review *Review) error {
+	review.ID = primitive.NewObjectIDFromTimestamp(time.Now())
+	review.CreatedAt = time.Now()
+
+	if err := r.mongo.InsertOne(ctx, "qa_reviews", review); err != nil {
+		logrus.Errorln(err)
+
+		return err
+	}
+
+	return nil
+}
+
+func (r *repository) GetReview(ctx context.Context, id primitive.ObjectID) (*Review, error) {
+	review := &Review{}
+	if err := r.mongo.FindOne(ctx, "qa_reviews", bson.D{{
+		Key:   "_id",
+		Value: id,
+	}}).Decode(review); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, ErrReviewNotFound
+		}
+
+		return nil, err
+	}
+
+	return review, nil
+}
+
+// GetReviews returns the oldest reviews first, so the queue is worked through in order.
+func (r *repository) GetReviews(ctx context.Context, filter *ReviewFilter) ([]*Review, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "_id", Value: 1}})
+	if filter.PageSize > 0 {
+		page := filter.Page
+		if page < 1 {
+			page = 1
+		}
+
+		opts = opts.SetSkip(int64((page - 1) * filter.PageSize)).SetLimit(int64(filter.PageSize))
+	}
+
+	cur, err := r.mongo.Find(ctx, "qa_reviews", filter.query(), opts)
+	if err != nil {
+		return nil, err
+	}
+
+	reviews := make([]*Review, 0)
+	if err := cur.All(ctx, &reviews); err != nil {
+		logrus.Errorln(err)
+		return nil, err
+	}
+
+	return reviews, nil
+}
+
+func (r *repository) CountReviews(ctx context.Context, filter *ReviewFilter) (int64, error) {
+	return r.mongo.Count(ctx, "qa_reviews", filter.query())
+}
+
+// GradeReview only grades a review once, two reviewers picking the same one can't both count.
+func (r *repository) GradeReview(ctx context.Context, id primitive.ObjectID, grade, note string, reviewer *users.User) error {
+	review, err := r.GetReview(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if review.Grade != "" {
+		return ErrAlreadyGraded
+	}
+
+	now := time.Now()
+
+	// Another reviewer may have graded it since, only the update itself can tell.
+	if err := r.mongo.FindOneAndUpdate(ctx, "qa_reviews", bson.D{
+		{Key: "_id", Value: id},
+		{Key: "grade", Value: ""},
+	}, bson.D{{
+		Key: "$set",
+		Value: bson.D{
+			{Key: "grade", Value: grade},
+			{Key: "note", Value: note},
+			{Key: "reviewer", Value: reviewer},
+			{Key: "graded_at", Value: now},
+		},
+	}}).Err(); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return ErrAlreadyGraded
+		}
+
+		logrus.Errorln(err)
+
+		return err
+	}
+
+	return nil
+}
+
+// ModeratorQuality is how the sampled resolutions of a moderator were graded. Accuracy is the share graded
+// correct, minor issues don't count as correct.
+type ModeratorQuality struct {
+	ModeratorID primitive.ObjectID `json:"moderator_id" bson:"_id"`
+	Name        string             `json:"name" bson:"name"`
+	Graded      int                `json:"graded" bson:"graded"`
+	Correct     int                `json:"correct" bson:"correct"`
+	MinorIssue  int                `json:"minor_issue" bson:"minor_issue"`
+	Wrong       int                `json:"wrong" bson:"wrong"`
+	Accuracy    float64            `json:"accuracy" bson:"accuracy"`
+}
+
+func countGrade(grade string) bson.D {
+	return bson.D{{Key: "$sum", Value: bson.D{{Key: "$cond", Value: bson.A{
+		bson.D{{Key: "$eq", Value: bson.A{"$grade", grade}}}, 1, 0,
+	}}}}}
+}
+
+func (r *repository) GetModeratorQuality(ctx context.Context) ([]*ModeratorQuality, error) {
+	pipeline := bson.A{
+		bson.D{{Key: "$match", Value: bson.D{{Key: "grade", Value: bson.D{{Key: "$ne", Value: ""}}}}}},
+		bson.D{{Key: "$group", Value: bson.D{
+			{Key: "_id", Value: "$moderator._id"},
+			{Key: "name", Value: bson.D{{Key: "$last", Value: "$moderator.name"}}},
+			{Key: "graded", Value: bson.D{{Key: "$sum", Value: 1}}},
+			{Key: "correct", Value: countGrade(GradeCorrect)},
+			{Key: "minor_issue", Value: countGrade(GradeMinorIssue)},
+			{Key: "wrong", Value: countGrade(GradeWrong)},
+		}}},
+		bson.D{{Key: "$addFields", Value: bson.D{
+			{Key: "accuracy", Value: bson.D{{Key: "$divide", Value: bson.A{"$correct", "$graded"}}}},
+		}}},
+		bson.D{{Key: "$sort", Value: bson.D{{Key: "accuracy", Value: 1}, {Key: "graded", Value: -1}}}},
+	}
+
+	cur, err := r.mongo.Aggregate(ctx, "qa_reviews", pipeline)
+	if err != nil {
+		return nil, err
+	}
+
+	stats := make([]*ModeratorQuality, 0)
+	if err := cur.All(ctx, &stats); err != nil {
+		logrus.Errorln(err)
+		return nil, err
+	}
+
+	return stats, nil
+}
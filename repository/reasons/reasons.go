@@ -0,0 +1,95 @@
+package reasons
+
+import (
+	"context"
+
+	"github.com/YusufOzmen01/veri-kontrol-backend/core/sources"
+	"github.com/YusufOzmen01/veri-kontrol-backend/repository/locations"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type Repository interface {
+	GetReasons(ctx context.Context) ([]*Reason, error)
+	EnsureDefaults(ctx context.Context, codes []string) error
+}
+
+type repository struct {
+	mongo sources.MongoClient
+}
+
+func NewRepository(mongo sources.MongoClient) Repository {
+	return &repository{
+		mongo: mongo,
+	}
+}
+
+// Reason is what a volunteer picks when resolving. The code is stored on the resolution, so the reasons the app
+// shipped with keep their original strings as codes. Corrected means the original address was right.
+type Reason struct {
+	Code      string `json:"code" bson:"_id"`
+	Label     string `json:"label" bson:"label"`
+	Corrected bool   `json:"corrected" bson:"corrected"`
+	Order     int    `json:"order" bson:"order"`
+}
+
+var DefaultCodes = []string{locations.ReasonNoError, "Adres Hatalı", "Konum Hatalı", "Eksik Bilgi", locations.ReasonSpam, "Diğer"}
+
+func Codes(reasons []*Reason) []string {
+	codes := make([]string, 0, len(reasons))
+	for _, reason := range reasons {
+		codes = append(codes, reason.Code)
+	}
+
+	return codes
+}
+
+func Find(reasons []*Reason, code string) *Reason {
+	for _, reason := range reasons {
+		if reason.Code == code {
+			return reason
+		}
+	}
+
+	return nil
+}
+
+func (r *repository) GetReasons(ctx context.Context) ([]*Reason, error) {
+	cur, err := r.mongo.Find(ctx, "reasons", bson.D{}, options.Find().SetSort(bson.D{{Key: "order", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+
+	reasons := make([]*Reason, 0)
+	if err := cur.All(ctx, &reasons); err != nil {
+		logrus.Errorln(err)
+		return nil, err
+	}
+
+	return reasons, nil
+}
+
+// EnsureDefaults seeds the collection with the given codes on the first startup, labelled with the code itself.
+func (r *repository) EnsureDefaults(ctx context.Context, codes []string) error {
+	count, err := r.mongo.Count(ctx, "reasons", bson.D{})
+	if err != nil {
+		return err
+	}
+
+	if count > 0 {
+		return nil
+	}
+
+	reasons := make([]interface{}, 0, len(codes))
+	for i, code := range codes {
+		reasons = append(reasons, &Reason{
+			Code:      code,
+			Label:     code,
+			Corrected: code == locations.ReasonNoError,
+			Order:     i,
+		})
+	}
+
+	return r.mongo.InsertMany(ctx, "reasons", reasons)
+}
@@ -0,0 +1,100 @@
+package types
+
+import (
+	"context"
+
+	"github.com/YusufOzmen01/veri-kontrol-backend/core/sources"
+	"github.com/YusufOzmen01/veri-kontrol-backend/repository/locations"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type Repository interface {
+	GetTypes(ctx context.Context) ([]*LocationType, error)
+	EnsureDefaults(ctx context.Context) error
+}
+
+type repository struct {
+	mongo sources.MongoClient
+}
+
+func NewRepository(mongo sources.MongoClient) Repository {
+	return &repository{
+		mongo: mongo,
+	}
+}
+
+// LocationType gives the type numbers on resolutions a meaning. Only selectable types can be picked by a
+// volunteer, spam is set through the spam flag.
+type LocationType struct {
+	ID         int    `json:"id" bson:"_id"`
+	Code       string `json:"code" bson:"code"`
+	Label      string `json:"label" bson:"label"`
+	Selectable bool   `json:"selectable" bson:"selectable"`
+	Order      int    `json:"order" bson:"order"`
+}
+
+var defaultTypes = []*LocationType{
+	{ID: locations.TypeWreckage, Code: "enkaz", Label: "Enkaz", Selectable: true},
+	{ID: locations.TypeSupplyHelp, Code: "yardim", Label: "Yardım", Selectable: true},
+	{ID: locations.TypeShelter, Code: "barinma", Label: "Barınma", Selectable: true},
+	{ID: locations.TypeOther, Code: "diger", Label: "Diğer", Selectable: true},
+	{ID: locations.TypeSpam, Code: "spam", Label: "Spam"},
+}
+
+func SelectableIDs(types []*LocationType) []int {
+	ids := make([]int, 0, len(types))
+	for _, t := range types {
+		if t.Selectable {
+			ids = append(ids, t.ID)
+		}
+	}
+
+	return ids
+}
+
+func Find(types []*LocationType, id int) *LocationType {
+	for _, t := range types {
+		if t.ID == id {
+			return t
+		}
+	}
+
+	return nil
+}
+
+func (r *repository) GetTypes(ctx context.Context) ([]*LocationType, error) {
+	cur, err := r.mongo.Find(ctx, "location_types", bson.D{}, options.Find().SetSort(bson.D{{Key: "order", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+
+	types := make([]*LocationType, 0)
+	if err := cur.All(ctx, &types); err != nil {
+		logrus.Errorln(err)
+		return nil, err
+	}
+
+	return types, nil
+}
+
+// EnsureDefaults seeds the collection with the types the app knows about on the first startup.
+func (r *repository) EnsureDefaults(ctx context.Context) error {
+	count, err := r.mongo.Count(ctx, "location_types", bson.D{})
+	if err != nil {
+		return err
+	}
+
+	if count > 0 {
+		return nil
+	}
+
+	types := make([]interface{}, 0, len(defaultTypes))
+	for i, t := range defaultTypes {
+		t.Order = i
+		types = append(types, t)
+	}
+
+	return r.mongo.InsertMany(ctx, "location_types", types)
+}
@@ -0,0 +1,48 @@
+package users
+
+import (
+	"context"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// SetLastSeen only ever moves last_seen forward, flushes from several instances can arrive in any order.
+func (r *repository) SetLastSeen(ctx context.Context, seen map[primitive.ObjectID]time.Time) error {
+	for id, at := range seen {
+		if err := r.mongo.UpdateOne(ctx, "users", bson.D{{
+			Key:   "_id",
+			Value: id,
+		}}, bson.D{{
+			Key:   "$max",
+			Value: bson.D{{Key: "last_seen", Value: at}},
+		}}); err != nil {
+			logrus.Errorln(err)
+
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r *repository) GetActiveUsers(ctx context.Context, since time.Time) ([]*User, error) {
+	cur, err := r.mongo.Find(ctx, "users", bson.D{
+		{Key: "last_seen", Value: bson.D{{Key: "$gte", Value: since}}},
+		{Key: "deactivated", Value: bson.D{{Key: "$ne", Value: true}}},
+	}, options.Find().SetSort(bson.D{{Key: "last_seen", Value: -1}}))
+	if err != nil {
+		return nil, err
+	}
+
+	users := make([]*User, 0)
+	if err := cur.All(ctx, &users); err != nil {
+		logrus.Errorln(err)
+		return nil, err
+	}
+
+	return users, nil
+}
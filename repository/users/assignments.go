@@ -0,0 +1,91 @@
+package users
+
+import (
+	"context"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Assignment restricts a volunteer to the cities a coordinator gave them. Unlike the preferences, which the
+// volunteer picks and which only come first, nothing outside the assigned cities is served.
+type Assignment struct {
+	UserID     primitive.ObjectID `json:"user_id" bson:"_id"`
+	CityIDs    []int              `json:"city_ids" bson:"city_ids"`
+	AssignedBy string             `json:"assigned_by" bson:"assigned_by"`
+	UpdatedAt  time.Time          `json:"updated_at" bson:"updated_at"`
+}
+
+// GetAssignment returns nil for users which aren't assigned to any city.
+func (r *repository) GetAssignment(ctx context.Context, id primitive.ObjectID) (*Assignment, error) {
+	assignment := &Assignment{}
+	if err := r.mongo.FindOne(ctx, "user_assignments", bson.D{{
+		Key:   "_id",
+		Value: id,
+	}}).Decode(assignment); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	return assignment, nil
+}
+
+func (r *repository) GetAssignments(ctx context.Context) ([]*Assignment, error) {
+	cur, err := r.mongo.Find(ctx, "user_assignments", bson.D{}, options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+
+	assignments := make([]*Assignment, 0)
+	if err := cur.All(ctx, &assignments); err != nil {
+		logrus.Errorln(err)
+		return nil, err
+	}
+
+	return assignments, nil
+}
+
+// HasAssignments tells whether any volunteer is assigned to cities.
+func (r *repository) HasAssignments(ctx context.Context) (bool, error) {
+	count, err := r.mongo.Count(ctx, "user_assignments", bson.D{}, options.Count().SetLimit(1))
+	if err != nil {
+		return false, err
+	}
+
+	return count > 0, nil
+}
+
+func (r *repository) SetAssignment(ctx context.Context, assignment *Assignment) error {
+	if err := r.mongo.UpsertOne(ctx, "user_assignments", bson.D{{
+		Key:   "_id",
+		Value: assignment.UserID,
+	}}, bson.D{{
+		Key: "$set",
+		Value: bson.D{
+			{Key: "city_ids", Value: assignment.CityIDs},
+			{Key: "assigned_by", Value: assignment.AssignedBy},
+			{Key: "updated_at", Value: assignment.UpdatedAt},
+		},
+	}}); err != nil {
+		logrus.Errorln(err)
+
+		return err
+	}
+
+	return nil
+}
+
+// DeleteAssignment lets the user work on every city again.
+func (r *repository) DeleteAssignment(ctx context.Context, id primitive.ObjectID) error {
+	return r.mongo.DeleteOne(ctx, "user_assignments", bson.D{{
+		Key:   "_id",
+		Value: id,
+	}})
+}
@@ -0,0 +1,100 @@
+package users
+
+import (
+	"context"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// authFailureTTL is how long failed attempts are kept.
+const authFailureTTL = 7 * 24 * time.Hour
+
+// AuthFailure is a rejected Auth-Key. Only the hash of the key is stored, the same one users have, so a
+// leaked key of a deactivated user can be matched to them.
+type AuthFailure struct {
+	ID      primitive.ObjectID `json:"_id" bson:"_id"`
+	IP      string             `json:"ip" bson:"ip"`
+	KeyHash uint32             `json:"key_hash" bson:"key_hash"`
+	Path    string             `json:"path" bson:"path"`
+	At      time.Time          `json:"at" bson:"at"`
+	// LockedOut is set on the failure which locked the IP or key out.
+	LockedOut bool `json:"locked_out,omitempty" bson:"locked_out,omitempty"`
+}
+
+// AuthFailureFilter narrows the failures down to an IP or a key hash.
+type AuthFailureFilter struct {
+	IP       string
+	KeyHash  *uint32
+	Page     int
+	PageSize int
+}
+
+func (r *repository) ensureAuthFailureIndexes(ctx context.Context) {
+	if _, err := r.mongo.CreateIndexWithOptions(ctx, "auth_failures", options.Index().SetExpireAfterSeconds(int32(authFailureTTL.Seconds())), bson.E{Key: "at", Value: 1}); err != nil {
+		logrus.Errorf("Couldn't create auth_failures at index: %s", err)
+	}
+
+	if _, err := r.mongo.CreateIndex(ctx, "auth_failures", bson.E{Key: "ip", Value: 1}, bson.E{Key: "at", Value: -1}); err != nil {
+		logrus.Errorf("Couldn't create auth_failures ip index: %s", err)
+	}
+}
+
+func (r *repository) RecordAuthFailure(ctx context.Context, failure *AuthFailure) error {
+	if failure.ID.IsZero() {
+		failure.ID = primitive.NewObjectIDFromTimestamp(time.Now())
+	}
+
+	if failure.At.IsZero() {
+		failure.At = time.Now()
+	}
+
+	if err := r.mongo.InsertOne(ctx, "auth_failures", failure); err != nil {
+		logrus.Errorln(err)
+
+		return err
+	}
+
+	return nil
+}
+
+// GetAuthFailures returns a page of the failures, newest first, and how many match in total.
+func (r *repository) GetAuthFailures(ctx context.Context, filter *AuthFailureFilter) ([]*AuthFailure, int64, error) {
+	query := bson.D{}
+	if filter.IP != "" {
+		query = append(query, bson.E{Key: "ip", Value: filter.IP})
+	}
+
+	if filter.KeyHash != nil {
+		query = append(query, bson.E{Key: "key_hash", Value: *filter.KeyHash})
+	}
+
+	total, err := r.mongo.Count(ctx, "auth_failures", query)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	page := filter.Page
+	if page < 1 {
+		page = 1
+	}
+
+	cur, err := r.mongo.Find(ctx, "auth_failures", query, options.Find().
+		SetSort(bson.D{{Key: "at", Value: -1}}).
+		SetSkip(int64((page-1)*filter.PageSize)).
+		SetLimit(int64(filter.PageSize)))
+	if err != nil {
+		return nil, 0, err
+	}
+
+	failures := make([]*AuthFailure, 0)
+	if err := cur.All(ctx, &failures); err != nil {
+		logrus.Errorln(err)
+		return nil, 0, err
+	}
+
+	return failures, total, nil
+}
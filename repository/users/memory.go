@@ -0,0 +1,534 @@
+package users
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/YusufOzmen01/veri-kontrol-backend/core/tokens"
+	"github.com/YusufOzmen01/veri-kontrol-backend/util"
+	"github.com/samber/lo"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// memoryRepository keeps the users and everything stored along with them in maps. It answers like the Mongo
+// repository does, so the handlers can be run against it without a database, but nothing survives a restart.
+type memoryRepository struct {
+	mu            sync.RWMutex
+	users         map[primitive.ObjectID]*User
+	preferences   map[primitive.ObjectID]*Preferences
+	assignments   map[primitive.ObjectID]*Assignment
+	refreshTokens map[string]*RefreshToken
+	authFailures  []*AuthFailure
+}
+
+// NewMemoryRepository returns an empty Repository which lives in memory, for tests and local runs.
+func NewMemoryRepository() Repository {
+	return &memoryRepository{
+		users:         make(map[primitive.ObjectID]*User),
+		preferences:   make(map[primitive.ObjectID]*Preferences),
+		assignments:   make(map[primitive.ObjectID]*Assignment),
+		refreshTokens: make(map[string]*RefreshToken),
+	}
+}
+
+// copyUser keeps callers from changing the stored document, a decoded Mongo document is a copy as well.
+func copyUser(user *User) *User {
+	c := *user
+
+	return &c
+}
+
+// sortedUsers returns copies of the users match accepts in _id order, the caller has to hold the lock.
+func (r *memoryRepository) sortedUsers(match func(user *User) bool) []*User {
+	users := make([]*User, 0)
+	for _, user := range r.users {
+		if match(user) {
+			users = append(users, copyUser(user))
+		}
+	}
+
+	sort.Slice(users, func(i, j int) bool {
+		return bytes.Compare(users[i].ID[:], users[j].ID[:]) < 0
+	})
+
+	return users
+}
+
+// update calls fn with the stored user, an unknown ID is ignored like an update which matched nothing.
+func (r *memoryRepository) update(id primitive.ObjectID, fn func(user *User)) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if user, ok := r.users[id]; ok {
+		fn(user)
+	}
+
+	return nil
+}
+
+func (r *memoryRepository) GetUser(ctx context.Context, authKey string) (*User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	hash := util.Hash(authKey)
+	now := time.Now()
+
+	for _, u := range r.sortedUsers(func(*User) bool { return true }) {
+		if u.Deactivated || u.Pending {
+			continue
+		}
+
+		if u.AuthKeyHash == hash {
+			return u, nil
+		}
+
+		if u.PreviousKeyHash == hash && u.PreviousKeyExpiry != nil && now.Before(*u.PreviousKeyExpiry) {
+			u.ByPreviousKey = true
+
+			return u, nil
+		}
+	}
+
+	return nil, ErrUserNotFound
+}
+
+func (r *memoryRepository) GetUserByID(ctx context.Context, id primitive.ObjectID) (*User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	user, ok := r.users[id]
+	if !ok {
+		return nil, ErrUserNotFound
+	}
+
+	return copyUser(user), nil
+}
+
+func (r *memoryRepository) insert(user *User) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.users[user.ID] = copyUser(user)
+}
+
+func (r *memoryRepository) AddUser(ctx context.Context, name, discord string, permLevel int, scopes []string) (*User, string, error) {
+	authKey := util.RandomString(32)
+
+	if scopes == nil {
+		scopes = ScopesForPermLevel(permLevel)
+	}
+
+	user := &User{
+		ID:            primitive.NewObjectIDFromTimestamp(time.Now()),
+		Name:          name,
+		Discord:       discord,
+		AuthKeyHash:   util.Hash(authKey),
+		PermLevel:     permLevel,
+		Scopes:        scopes,
+		DefaultScopes: ScopesForPermLevel(permLevel),
+	}
+
+	r.insert(user)
+
+	return user, authKey, nil
+}
+
+func (r *memoryRepository) RegisterUser(ctx context.Context, name, discord, telegramChatID string, maxPending int) (*User, string, error) {
+	if pending, _ := r.GetPendingUsers(ctx); len(pending) >= maxPending {
+		return nil, "", ErrTooManyPending
+	}
+
+	authKey := util.RandomString(32)
+	now := time.Now()
+
+	user := &User{
+		ID:             primitive.NewObjectIDFromTimestamp(now),
+		Name:           name,
+		Discord:        discord,
+		AuthKeyHash:    util.Hash(authKey),
+		PermLevel:      PermReadOnly,
+		Scopes:         []string{},
+		Pending:        true,
+		TelegramChatID: telegramChatID,
+		RegisteredAt:   &now,
+	}
+
+	r.insert(user)
+
+	return user, authKey, nil
+}
+
+func (r *memoryRepository) GetPendingUsers(ctx context.Context) ([]*User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.sortedUsers(func(user *User) bool { return user.Pending }), nil
+}
+
+func (r *memoryRepository) ApproveUser(ctx context.Context, id primitive.ObjectID, permLevel int, scopes []string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	user, ok := r.users[id]
+	if !ok || !user.Pending {
+		return ErrNotPending
+	}
+
+	user.PermLevel = permLevel
+	user.Scopes = scopes
+	user.DefaultScopes = ScopesForPermLevel(permLevel)
+	user.Pending = false
+
+	return nil
+}
+
+func (r *memoryRepository) RejectUser(ctx context.Context, id primitive.ObjectID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	user, ok := r.users[id]
+	if !ok || !user.Pending {
+		return ErrNotPending
+	}
+
+	delete(r.users, id)
+
+	return nil
+}
+
+func (r *memoryRepository) RotateAuthKey(ctx context.Context, id primitive.ObjectID, expiresAt time.Time) (string, *User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	user, ok := r.users[id]
+	if !ok || user.Deactivated {
+		return "", nil, ErrUserNotFound
+	}
+
+	before := copyUser(user)
+	authKey := util.RandomString(32)
+
+	user.PreviousKeyHash = user.AuthKeyHash
+	user.PreviousKeyExpiry = &expiresAt
+	user.AuthKeyHash = util.Hash(authKey)
+
+	return authKey, before, nil
+}
+
+func (r *memoryRepository) GetUsers(ctx context.Context) ([]*User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.sortedUsers(func(*User) bool { return true }), nil
+}
+
+func (r *memoryRepository) SetPermLevel(ctx context.Context, id primitive.ObjectID, permLevel int) error {
+	return r.update(id, func(user *User) {
+		user.PermLevel = permLevel
+		user.DefaultScopes = ScopesForPermLevel(permLevel)
+	})
+}
+
+func (r *memoryRepository) SetScopes(ctx context.Context, id primitive.ObjectID, scopes []string) error {
+	return r.update(id, func(user *User) { user.Scopes = scopes })
+}
+
+func (r *memoryRepository) MigrateScopes(ctx context.Context) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, user := range r.users {
+		defaults := ScopesForPermLevel(user.PermLevel)
+
+		switch {
+		case user.Scopes == nil:
+			user.Scopes = defaults
+			user.DefaultScopes = append([]string(nil), defaults...)
+		case user.DefaultScopes == nil:
+			user.DefaultScopes = lo.Intersect(defaults, scopesBeforeDefaults)
+		}
+
+		for _, scope := range defaults {
+			if !lo.Contains(user.DefaultScopes, scope) {
+				user.Scopes = append(user.Scopes, scope)
+				user.DefaultScopes = append(user.DefaultScopes, scope)
+			}
+		}
+	}
+
+	return nil
+}
+
+func (r *memoryRepository) SetLastSeen(ctx context.Context, seen map[primitive.ObjectID]time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for id, at := range seen {
+		user, ok := r.users[id]
+		if !ok {
+			continue
+		}
+
+		if user.LastSeen == nil || at.After(*user.LastSeen) {
+			at := at
+			user.LastSeen = &at
+		}
+	}
+
+	return nil
+}
+
+func (r *memoryRepository) GetActiveUsers(ctx context.Context, since time.Time) ([]*User, error) {
+	r.mu.RLock()
+	users := r.sortedUsers(func(user *User) bool {
+		return user.LastSeen != nil && !user.LastSeen.Before(since) && !user.Deactivated
+	})
+	r.mu.RUnlock()
+
+	sort.SliceStable(users, func(i, j int) bool { return users[i].LastSeen.After(*users[j].LastSeen) })
+
+	return users, nil
+}
+
+func (r *memoryRepository) GetPreferences(ctx context.Context, id primitive.ObjectID) (*Preferences, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	preferences, ok := r.preferences[id]
+	if !ok {
+		return &Preferences{UserID: id, CityIDs: make([]int, 0), Types: make([]int, 0), Languages: make([]string, 0)}, nil
+	}
+
+	c := *preferences
+	if c.Languages == nil {
+		c.Languages = make([]string, 0)
+	}
+
+	return &c, nil
+}
+
+func (r *memoryRepository) SetPreferences(ctx context.Context, preferences *Preferences) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	c := *preferences
+	r.preferences[preferences.UserID] = &c
+
+	return nil
+}
+
+func (r *memoryRepository) GetAssignment(ctx context.Context, id primitive.ObjectID) (*Assignment, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	assignment, ok := r.assignments[id]
+	if !ok {
+		return nil, nil
+	}
+
+	c := *assignment
+
+	return &c, nil
+}
+
+func (r *memoryRepository) GetAssignments(ctx context.Context) ([]*Assignment, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	assignments := make([]*Assignment, 0, len(r.assignments))
+	for _, assignment := range r.assignments {
+		c := *assignment
+		assignments = append(assignments, &c)
+	}
+
+	sort.Slice(assignments, func(i, j int) bool {
+		return bytes.Compare(assignments[i].UserID[:], assignments[j].UserID[:]) < 0
+	})
+
+	return assignments, nil
+}
+
+func (r *memoryRepository) HasAssignments(ctx context.Context) (bool, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return len(r.assignments) > 0, nil
+}
+
+func (r *memoryRepository) SetAssignment(ctx context.Context, assignment *Assignment) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	c := *assignment
+	r.assignments[assignment.UserID] = &c
+
+	return nil
+}
+
+func (r *memoryRepository) DeleteAssignment(ctx context.Context, id primitive.ObjectID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.assignments, id)
+
+	return nil
+}
+
+func (r *memoryRepository) DeactivateUser(ctx context.Context, id primitive.ObjectID) error {
+	return r.update(id, func(user *User) { user.Deactivated = true })
+}
+
+func (r *memoryRepository) SetLeaderboardOptOut(ctx context.Context, id primitive.ObjectID, optOut bool) error {
+	return r.update(id, func(user *User) { user.LeaderboardOptOut = optOut })
+}
+
+func (r *memoryRepository) GetLeaderboardOptOutIDs(ctx context.Context) ([]primitive.ObjectID, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	ids := make([]primitive.ObjectID, 0)
+	for _, user := range r.sortedUsers(func(user *User) bool { return user.LeaderboardOptOut }) {
+		ids = append(ids, user.ID)
+	}
+
+	return ids, nil
+}
+
+func (r *memoryRepository) CreateRefreshToken(ctx context.Context, userID primitive.ObjectID) (string, error) {
+	token := util.SecureRandomString(32)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	hash := util.SHA256(token)
+	r.refreshTokens[hash] = &RefreshToken{
+		ID:        primitive.NewObjectIDFromTimestamp(time.Now()),
+		UserID:    userID,
+		TokenHash: hash,
+		ExpiresAt: time.Now().Add(tokens.RefreshTokenTTL),
+	}
+
+	return token, nil
+}
+
+func (r *memoryRepository) GetRefreshToken(ctx context.Context, token string) (*RefreshToken, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	refreshToken, ok := r.refreshTokens[util.SHA256(token)]
+	if !ok {
+		return nil, fmt.Errorf("refresh token not found")
+	}
+
+	if refreshToken.Revoked {
+		return nil, fmt.Errorf("refresh token is revoked")
+	}
+
+	if time.Now().After(refreshToken.ExpiresAt) {
+		return nil, fmt.Errorf("refresh token is expired")
+	}
+
+	c := *refreshToken
+
+	return &c, nil
+}
+
+func (r *memoryRepository) ConsumeRefreshToken(ctx context.Context, token string) (*RefreshToken, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	refreshToken, ok := r.refreshTokens[util.SHA256(token)]
+	if !ok || refreshToken.Revoked || !time.Now().Before(refreshToken.ExpiresAt) {
+		return nil, ErrRefreshTokenInvalid
+	}
+
+	c := *refreshToken
+	refreshToken.Revoked = true
+
+	return &c, nil
+}
+
+func (r *memoryRepository) RevokeRefreshToken(ctx context.Context, token string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if refreshToken, ok := r.refreshTokens[util.SHA256(token)]; ok {
+		refreshToken.Revoked = true
+	}
+
+	return nil
+}
+
+func (r *memoryRepository) RevokeUserRefreshTokens(ctx context.Context, userID primitive.ObjectID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, refreshToken := range r.refreshTokens {
+		if refreshToken.UserID == userID {
+			refreshToken.Revoked = true
+		}
+	}
+
+	return nil
+}
+
+func (r *memoryRepository) RecordAuthFailure(ctx context.Context, failure *AuthFailure) error {
+	if failure.ID.IsZero() {
+		failure.ID = primitive.NewObjectIDFromTimestamp(time.Now())
+	}
+
+	if failure.At.IsZero() {
+		failure.At = time.Now()
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	c := *failure
+	r.authFailures = append(r.authFailures, &c)
+
+	return nil
+}
+
+func (r *memoryRepository) GetAuthFailures(ctx context.Context, filter *AuthFailureFilter) ([]*AuthFailure, int64, error) {
+	r.mu.RLock()
+	failures := make([]*AuthFailure, 0)
+	for _, failure := range r.authFailures {
+		if filter.IP != "" && failure.IP != filter.IP {
+			continue
+		}
+
+		if filter.KeyHash != nil && failure.KeyHash != *filter.KeyHash {
+			continue
+		}
+
+		c := *failure
+		failures = append(failures, &c)
+	}
+	r.mu.RUnlock()
+
+	sort.SliceStable(failures, func(i, j int) bool { return failures[i].At.After(failures[j].At) })
+
+	total := int64(len(failures))
+
+	page := filter.Page
+	if page < 1 {
+		page = 1
+	}
+
+	start := (page - 1) * filter.PageSize
+	if start > len(failures) {
+		start = len(failures)
+	}
+
+	end := len(failures)
+	if filter.PageSize > 0 && start+filter.PageSize < end {
+		end = start + filter.PageSize
+	}
+
+	return failures[start:end], total, nil
+}
@@ -0,0 +1,60 @@
+package users
+
+import (
+	"context"
+
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Preferences narrow down what a volunteer is served. They are kept apart from the user document, resolutions
+// store a copy of their sender and shouldn't carry them along. Languages are the ones the volunteer reads.
+type Preferences struct {
+	UserID    primitive.ObjectID `json:"-" bson:"_id"`
+	CityIDs   []int              `json:"city_ids" bson:"city_ids"`
+	Types     []int              `json:"types" bson:"types"`
+	Languages []string           `json:"languages" bson:"languages"`
+}
+
+// GetPreferences returns empty preferences for users which never set any.
+func (r *repository) GetPreferences(ctx context.Context, id primitive.ObjectID) (*Preferences, error) {
+	preferences := &Preferences{}
+	if err := r.mongo.FindOne(ctx, "user_preferences", bson.D{{
+		Key:   "_id",
+		Value: id,
+	}}).Decode(preferences); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return &Preferences{UserID: id, CityIDs: make([]int, 0), Types: make([]int, 0), Languages: make([]string, 0)}, nil
+		}
+
+		return nil, err
+	}
+
+	if preferences.Languages == nil {
+		preferences.Languages = make([]string, 0)
+	}
+
+	return preferences, nil
+}
+
+func (r *repository) SetPreferences(ctx context.Context, preferences *Preferences) error {
+	if err := r.mongo.UpsertOne(ctx, "user_preferences", bson.D{{
+		Key:   "_id",
+		Value: preferences.UserID,
+	}}, bson.D{{
+		Key: "$set",
+		Value: bson.D{
+			{Key: "city_ids", Value: preferences.CityIDs},
+			{Key: "types", Value: preferences.Types},
+			{Key: "languages", Value: preferences.Languages},
+		},
+	}}); err != nil {
+		logrus.Errorln(err)
+
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,38 @@
+package users
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestRegistration(t *testing.T) {
+	ctx := context.Background()
+	repository := NewMemoryRepository()
+
+	first, _, err := repository.RegisterUser(ctx, "first", "", "", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err := repository.RegisterUser(ctx, "second", "", "", 2); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err := repository.RegisterUser(ctx, "third", "", "", 2); !errors.Is(err, ErrTooManyPending) {
+		t.Fatalf("RegisterUser() over the cap = %v, want ErrTooManyPending", err)
+	}
+
+	if err := repository.ApproveUser(ctx, first.ID, PermSubmit, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := repository.ApproveUser(ctx, first.ID, PermAdmin, nil); !errors.Is(err, ErrNotPending) {
+		t.Fatalf("ApproveUser() twice = %v, want ErrNotPending", err)
+	}
+
+	// The approval freed a place in the queue.
+	if _, _, err := repository.RegisterUser(ctx, "third", "", "", 2); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,130 @@
+package users
+
+import (
+	"context"
+
+	"github.com/samber/lo"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Scopes split what used to be a single moderator level. Routes check the scope of what they do, the perm
+// level only decides the scopes a user starts with. Reviewers are moderators or admins with ScopeQAReview,
+// the perm levels are baked into issued tokens and can't get a level in between.
+const (
+	ScopeEntriesRead  = "entries:read"
+	ScopeEntriesWrite = "entries:write"
+	ScopeUsersManage  = "users:manage"
+	ScopeExportRun    = "export:run"
+	ScopeStatsRead    = "stats:read"
+	ScopeQAReview     = "qa:review"
+	// ScopePIIRead shows the phone and ID numbers in the texts served to volunteers, everybody else gets them
+	// masked.
+	ScopePIIRead = "pii:read"
+	// ScopeExportPII leaves the phone numbers and names in exports and the public API, everybody else gets them
+	// redacted.
+	ScopeExportPII = "export:pii"
+	// ScopeAssignmentsManage makes a coordinator, who assigns volunteers to the cities they work on.
+	ScopeAssignmentsManage = "assignments:manage"
+)
+
+var Scopes = []string{ScopeEntriesRead, ScopeEntriesWrite, ScopeUsersManage, ScopeExportRun, ScopeStatsRead, ScopeQAReview, ScopePIIRead, ScopeExportPII, ScopeAssignmentsManage}
+
+// ScopesForPermLevel is what every moderator and admin could do before there were scopes.
+func ScopesForPermLevel(permLevel int) []string {
+	switch {
+	case permLevel >= PermAdmin:
+		return []string{ScopeEntriesRead, ScopeEntriesWrite, ScopeUsersManage, ScopeExportRun, ScopeStatsRead, ScopeQAReview, ScopePIIRead, ScopeExportPII, ScopeAssignmentsManage}
+	case permLevel >= PermModerator:
+		return []string{ScopeEntriesRead, ScopeEntriesWrite, ScopeExportRun, ScopeStatsRead}
+	}
+
+	return []string{}
+}
+
+// HasScope falls back to the perm level for users without scopes, which are accounts and tokens from before
+// the migration. An empty list means no scopes.
+func (u *User) HasScope(scope string) bool {
+	scopes := u.Scopes
+	if scopes == nil {
+		scopes = ScopesForPermLevel(u.PermLevel)
+	}
+
+	for _, s := range scopes {
+		if s == scope {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (r *repository) SetScopes(ctx context.Context, id primitive.ObjectID, scopes []string) error {
+	return r.mongo.UpdateOne(ctx, "users", bson.D{{
+		Key:   "_id",
+		Value: id,
+	}}, bson.D{{
+		Key:   "$set",
+		Value: bson.D{{Key: "scopes", Value: scopes}},
+	}})
+}
+
+// scopesBeforeDefaults are the scopes there were before DefaultScopes was kept. Users from back then were given
+// those of their perm level, the ones added since are still missing.
+var scopesBeforeDefaults = []string{ScopeEntriesRead, ScopeEntriesWrite, ScopeUsersManage, ScopeExportRun, ScopeStatsRead}
+
+// MigrateScopes gives every user without scopes the ones their perm level stands for, and every other user the
+// defaults of its perm level which were added since it got its scopes. Defaults a user was already given aren't
+// given again, a default an admin took away stays away. It is safe to run on every startup.
+func (r *repository) MigrateScopes(ctx context.Context) error {
+	for _, permLevel := range PermLevels {
+		defaults := ScopesForPermLevel(permLevel)
+
+		if err := r.mongo.UpdateMany(ctx, "users", bson.D{
+			{Key: "perm_level", Value: permLevel},
+			{Key: "scopes", Value: bson.D{{Key: "$exists", Value: false}}},
+		}, bson.D{{
+			Key: "$set",
+			Value: bson.D{
+				{Key: "scopes", Value: defaults},
+				{Key: "default_scopes", Value: defaults},
+			},
+		}}); err != nil {
+			logrus.Errorln(err)
+
+			return err
+		}
+
+		if err := r.mongo.UpdateMany(ctx, "users", bson.D{
+			{Key: "perm_level", Value: permLevel},
+			{Key: "default_scopes", Value: bson.D{{Key: "$exists", Value: false}}},
+		}, bson.D{{
+			Key:   "$set",
+			Value: bson.D{{Key: "default_scopes", Value: lo.Intersect(defaults, scopesBeforeDefaults)}},
+		}}); err != nil {
+			logrus.Errorln(err)
+
+			return err
+		}
+
+		for _, scope := range defaults {
+			if err := r.mongo.UpdateMany(ctx, "users", bson.D{
+				{Key: "perm_level", Value: permLevel},
+				{Key: "default_scopes", Value: bson.D{{Key: "$ne", Value: scope}}},
+			}, bson.D{{
+				Key: "$addToSet",
+				Value: bson.D{
+					{Key: "scopes", Value: scope},
+					{Key: "default_scopes", Value: scope},
+				},
+			}}); err != nil {
+				logrus.Errorln(err)
+
+				return err
+			}
+		}
+	}
+
+	return nil
+}
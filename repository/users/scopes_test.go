@@ -0,0 +1,67 @@
+package users
+
+import (
+	"context"
+	"sort"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestMigrateScopes(t *testing.T) {
+	ctx := context.Background()
+	repository := NewMemoryRepository().(*memoryRepository)
+
+	// An admin from before the defaults were kept, an admin who had export:pii taken away since and a moderator
+	// from before there were scopes.
+	legacy := &User{ID: primitive.NewObjectID(), PermLevel: PermAdmin, Scopes: []string{ScopeEntriesRead, ScopeUsersManage}}
+	trimmed := &User{
+		ID:            primitive.NewObjectID(),
+		PermLevel:     PermAdmin,
+		Scopes:        []string{ScopeEntriesRead},
+		DefaultScopes: []string{ScopeEntriesRead, ScopeEntriesWrite, ScopeUsersManage, ScopeExportRun, ScopeStatsRead, ScopeQAReview, ScopePIIRead, ScopeExportPII},
+	}
+	unscoped := &User{ID: primitive.NewObjectID(), PermLevel: PermModerator}
+
+	for _, user := range []*User{legacy, trimmed, unscoped} {
+		repository.insert(user)
+	}
+
+	// The second run must not change anything.
+	for i := 0; i < 2; i++ {
+		if err := repository.MigrateScopes(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		user *User
+		want []string
+	}{
+		{legacy, []string{ScopeEntriesRead, ScopeUsersManage, ScopeQAReview, ScopePIIRead, ScopeExportPII, ScopeAssignmentsManage}},
+		{trimmed, []string{ScopeEntriesRead, ScopeAssignmentsManage}},
+		{unscoped, ScopesForPermLevel(PermModerator)},
+	}
+
+	for _, tt := range tests {
+		user, err := repository.GetUserByID(ctx, tt.user.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		got := append([]string(nil), user.Scopes...)
+		want := append([]string(nil), tt.want...)
+		sort.Strings(got)
+		sort.Strings(want)
+
+		if len(got) != len(want) {
+			t.Fatalf("user %s: scopes %v, want %v", user.ID.Hex(), got, want)
+		}
+
+		for i := range got {
+			if got[i] != want[i] {
+				t.Fatalf("user %s: scopes %v, want %v", user.ID.Hex(), got, want)
+			}
+		}
+	}
+}
@@ -2,40 +2,137 @@ package users
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"time"
+
 	"github.com/YusufOzmen01/veri-kontrol-backend/core/sources"
+	"github.com/YusufOzmen01/veri-kontrol-backend/core/tokens"
 	"github.com/YusufOzmen01/veri-kontrol-backend/util"
 	"github.com/sirupsen/logrus"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 type Repository interface {
 	GetUser(ctx context.Context, authKey string) (*User, error)
-	AddUser(ctx context.Context, name, discord string, permLevel int) (string, error)
+	GetUserByID(ctx context.Context, id primitive.ObjectID) (*User, error)
+	AddUser(ctx context.Context, name, discord string, permLevel int, scopes []string) (*User, string, error)
+	RegisterUser(ctx context.Context, name, discord, telegramChatID string, maxPending int) (*User, string, error)
+	GetPendingUsers(ctx context.Context) ([]*User, error)
+	ApproveUser(ctx context.Context, id primitive.ObjectID, permLevel int, scopes []string) error
+	RejectUser(ctx context.Context, id primitive.ObjectID) error
+	RotateAuthKey(ctx context.Context, id primitive.ObjectID, expiresAt time.Time) (string, *User, error)
+	GetUsers(ctx context.Context) ([]*User, error)
+	SetPermLevel(ctx context.Context, id primitive.ObjectID, permLevel int) error
+	SetScopes(ctx context.Context, id primitive.ObjectID, scopes []string) error
+	MigrateScopes(ctx context.Context) error
+	SetLastSeen(ctx context.Context, seen map[primitive.ObjectID]time.Time) error
+	GetActiveUsers(ctx context.Context, since time.Time) ([]*User, error)
+	GetPreferences(ctx context.Context, id primitive.ObjectID) (*Preferences, error)
+	SetPreferences(ctx context.Context, preferences *Preferences) error
+	GetAssignment(ctx context.Context, id primitive.ObjectID) (*Assignment, error)
+	GetAssignments(ctx context.Context) ([]*Assignment, error)
+	HasAssignments(ctx context.Context) (bool, error)
+	SetAssignment(ctx context.Context, assignment *Assignment) error
+	DeleteAssignment(ctx context.Context, id primitive.ObjectID) error
+	DeactivateUser(ctx context.Context, id primitive.ObjectID) error
+	SetLeaderboardOptOut(ctx context.Context, id primitive.ObjectID, optOut bool) error
+	GetLeaderboardOptOutIDs(ctx context.Context) ([]primitive.ObjectID, error)
+	CreateRefreshToken(ctx context.Context, userID primitive.ObjectID) (string, error)
+	GetRefreshToken(ctx context.Context, token string) (*RefreshToken, error)
+	ConsumeRefreshToken(ctx context.Context, token string) (*RefreshToken, error)
+	RevokeRefreshToken(ctx context.Context, token string) error
+	RevokeUserRefreshTokens(ctx context.Context, userID primitive.ObjectID) error
+	RecordAuthFailure(ctx context.Context, failure *AuthFailure) error
+	GetAuthFailures(ctx context.Context, filter *AuthFailureFilter) ([]*AuthFailure, int64, error)
 }
 
+var (
+	ErrUserNotFound   = errors.New("user not found")
+	ErrNotPending     = errors.New("user isn't waiting for approval")
+	ErrTooManyPending = errors.New("too many registrations are waiting for approval")
+	// ErrRefreshTokenInvalid covers unknown, revoked and expired refresh tokens alike.
+	ErrRefreshTokenInvalid = errors.New("refresh token is invalid, revoked or expired")
+)
+
 type repository struct {
 	mongo sources.MongoClient
 }
 
 func NewRepository(mongo sources.MongoClient) Repository {
-	return &repository{
+	r := &repository{
 		mongo: mongo,
 	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	r.ensureIndexes(ctx)
+	r.ensureAuthFailureIndexes(ctx)
+
+	return r
+}
+
+func (r *repository) ensureIndexes(ctx context.Context) {
+	if _, err := r.mongo.CreateIndex(ctx, "users", bson.E{Key: "auth_key_hash", Value: 1}); err != nil {
+		logrus.Errorf("Couldn't create users auth_key_hash index: %s", err)
+	}
+
+	if _, err := r.mongo.CreateIndexWithOptions(ctx, "refresh_tokens", options.Index().SetUnique(true), bson.E{Key: "token_hash", Value: 1}); err != nil {
+		logrus.Errorf("Couldn't create refresh_tokens token_hash index: %s", err)
+	}
+
+	if _, err := r.mongo.CreateIndex(ctx, "refresh_tokens", bson.E{Key: "user_id", Value: 1}); err != nil {
+		logrus.Errorf("Couldn't create refresh_tokens user_id index: %s", err)
+	}
+
+	// Expired refresh tokens are useless, let Mongo clean them up.
+	if _, err := r.mongo.CreateIndexWithOptions(ctx, "refresh_tokens", options.Index().SetExpireAfterSeconds(0), bson.E{Key: "expires_at", Value: 1}); err != nil {
+		logrus.Errorf("Couldn't create refresh_tokens expires_at index: %s", err)
+	}
 }
 
+// PermReadOnly users are API keys of external consumers, they can't submit anything. PermAdmin manages users.
 const (
+	PermReadOnly  = 0
 	PermSubmit    = 1
 	PermModerator = 2
+	PermAdmin     = 3
 )
 
+var PermLevels = []int{PermReadOnly, PermSubmit, PermModerator, PermAdmin}
+
 type User struct {
-	ID          primitive.ObjectID `json:"_id" bson:"_id"`
-	Name        string             `json:"name" bson:"name"`
-	Discord     string             `json:"discord" bson:"discord"`
-	AuthKeyHash uint32             `json:"auth_key_hash" bson:"auth_key_hash"`
-	PermLevel   int                `json:"perm_level" bson:"perm_level"`
+	ID                primitive.ObjectID `json:"_id" bson:"_id"`
+	Name              string             `json:"name" bson:"name"`
+	Discord           string             `json:"discord" bson:"discord"`
+	AuthKeyHash       uint32             `json:"-" bson:"auth_key_hash"`
+	PreviousKeyHash   uint32             `json:"-" bson:"previous_key_hash,omitempty"`
+	PreviousKeyExpiry *time.Time         `json:"previous_key_expiry,omitempty" bson:"previous_key_expiry,omitempty"`
+	PermLevel         int                `json:"perm_level" bson:"perm_level"`
+	Deactivated       bool               `json:"deactivated" bson:"deactivated"`
+	Pending           bool               `json:"pending,omitempty" bson:"pending,omitempty"`
+	TelegramChatID    string             `json:"telegram_chat_id,omitempty" bson:"telegram_chat_id,omitempty"`
+	RegisteredAt      *time.Time         `json:"registered_at,omitempty" bson:"registered_at,omitempty"`
+	LeaderboardOptOut bool               `json:"leaderboard_opt_out" bson:"leaderboard_opt_out"`
+	Scopes            []string           `json:"scopes" bson:"scopes"`
+	DefaultScopes     []string           `json:"-" bson:"default_scopes,omitempty"`
+	LastSeen          *time.Time         `json:"last_seen,omitempty" bson:"last_seen,omitempty"`
+
+	// ByPreviousKey is set by GetUser when the key replaced by a rotation was used, it is only good for the
+	// ordinary requests of the grace period.
+	ByPreviousKey bool `json:"-" bson:"-"`
+}
+
+type RefreshToken struct {
+	ID        primitive.ObjectID `json:"_id" bson:"_id"`
+	UserID    primitive.ObjectID `json:"user_id" bson:"user_id"`
+	TokenHash string             `json:"token_hash" bson:"token_hash"`
+	ExpiresAt time.Time          `json:"expires_at" bson:"expires_at"`
+	Revoked   bool               `json:"revoked" bson:"revoked"`
 }
 
 func (r *repository) GetUser(ctx context.Context, authKey string) (*User, error) {
@@ -52,28 +149,343 @@ func (r *repository) GetUser(ctx context.Context, authKey string) (*User, error)
 		return nil, err
 	}
 
+	hash := util.Hash(authKey)
+	now := time.Now()
+
 	for _, u := range user {
-		if u.AuthKeyHash == util.Hash(authKey) {
+		if u.Deactivated || u.Pending {
+			continue
+		}
+
+		if u.AuthKeyHash == hash {
 			return u, nil
 		}
+
+		// The key replaced by a rotation keeps working until its expiry.
+		if u.PreviousKeyHash == hash && u.PreviousKeyExpiry != nil && now.Before(*u.PreviousKeyExpiry) {
+			u.ByPreviousKey = true
+
+			return u, nil
+		}
+	}
+
+	return nil, ErrUserNotFound
+}
+
+// AddUser gives the user the scopes of its perm level when scopes is nil.
+func (r *repository) AddUser(ctx context.Context, name, discord string, permLevel int, scopes []string) (*User, string, error) {
+	authKey := util.RandomString(32)
+
+	if scopes == nil {
+		scopes = ScopesForPermLevel(permLevel)
+	}
+
+	user := &User{
+		ID:            primitive.NewObjectIDFromTimestamp(time.Now()),
+		Name:          name,
+		Discord:       discord,
+		AuthKeyHash:   util.Hash(authKey),
+		PermLevel:     permLevel,
+		Scopes:        scopes,
+		DefaultScopes: ScopesForPermLevel(permLevel),
+	}
+
+	if err := r.mongo.InsertOne(ctx, "users", user); err != nil {
+		logrus.Errorln(err)
+
+		return nil, "", err
 	}
 
-	return nil, fmt.Errorf("user not found")
+	return user, authKey, nil
 }
 
-func (r *repository) AddUser(ctx context.Context, name, discord string, permLevel int) (string, error) {
+// RegisterUser adds a user which can't do anything until an admin approves it. Its Auth-Key is returned right
+// away and starts working with the approval, so it never has to be sent around. Once maxPending registrations
+// are waiting, new ones get ErrTooManyPending.
+func (r *repository) RegisterUser(ctx context.Context, name, discord, telegramChatID string, maxPending int) (*User, string, error) {
+	// Registrations running at once can both pass, the cap only has to keep the queue from growing unbounded.
+	pending, err := r.mongo.Count(ctx, "users", bson.D{{Key: "pending", Value: true}})
+	if err != nil {
+		logrus.Errorln(err)
+
+		return nil, "", err
+	}
+
+	if pending >= int64(maxPending) {
+		return nil, "", ErrTooManyPending
+	}
+
 	authKey := util.RandomString(32)
+	now := time.Now()
+
+	user := &User{
+		ID:             primitive.NewObjectIDFromTimestamp(now),
+		Name:           name,
+		Discord:        discord,
+		AuthKeyHash:    util.Hash(authKey),
+		PermLevel:      PermReadOnly,
+		Scopes:         []string{},
+		Pending:        true,
+		TelegramChatID: telegramChatID,
+		RegisteredAt:   &now,
+	}
+
+	if err := r.mongo.InsertOne(ctx, "users", user); err != nil {
+		logrus.Errorln(err)
+
+		return nil, "", err
+	}
+
+	return user, authKey, nil
+}
 
-	if err := r.mongo.InsertOne(ctx, "users", &User{
-		Name:        name,
-		Discord:     discord,
-		AuthKeyHash: util.Hash(authKey),
-		PermLevel:   permLevel,
+// GetPendingUsers returns the registrations waiting for approval, oldest first.
+func (r *repository) GetPendingUsers(ctx context.Context) ([]*User, error) {
+	cur, err := r.mongo.Find(ctx, "users", bson.D{{Key: "pending", Value: true}}, options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+
+	users := make([]*User, 0)
+	if err := cur.All(ctx, &users); err != nil {
+		logrus.Errorln(err)
+		return nil, err
+	}
+
+	return users, nil
+}
+
+// ApproveUser activates a registration with the given perm level and scopes.
+func (r *repository) ApproveUser(ctx context.Context, id primitive.ObjectID, permLevel int, scopes []string) error {
+	err := r.mongo.FindOneAndUpdate(ctx, "users", bson.D{
+		{Key: "_id", Value: id},
+		{Key: "pending", Value: true},
+	}, bson.D{
+		{Key: "$set", Value: bson.D{
+			{Key: "perm_level", Value: permLevel},
+			{Key: "scopes", Value: scopes},
+			{Key: "default_scopes", Value: ScopesForPermLevel(permLevel)},
+		}},
+		{Key: "$unset", Value: bson.D{{Key: "pending", Value: ""}}},
+	}).Err()
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return ErrNotPending
+	}
+
+	return err
+}
+
+// RejectUser removes a registration, it never did anything worth keeping.
+func (r *repository) RejectUser(ctx context.Context, id primitive.ObjectID) error {
+	err := r.mongo.FindOneAndDelete(ctx, "users", bson.D{
+		{Key: "_id", Value: id},
+		{Key: "pending", Value: true},
+	}).Err()
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return ErrNotPending
+	}
+
+	return err
+}
+
+// RotateAuthKey gives the user a new Auth-Key, the current one keeps working until expiresAt. A key left over
+// from an earlier rotation stops working right away. The user is returned as it was before.
+func (r *repository) RotateAuthKey(ctx context.Context, id primitive.ObjectID, expiresAt time.Time) (string, *User, error) {
+	authKey := util.RandomString(32)
+
+	// The current hash is moved in the same update, rotations racing each other can't lose a key in between.
+	user := &User{}
+	if err := r.mongo.FindOneAndUpdate(ctx, "users", bson.D{
+		{Key: "_id", Value: id},
+		{Key: "deactivated", Value: bson.D{{Key: "$ne", Value: true}}},
+	}, bson.A{bson.D{{
+		Key: "$set",
+		Value: bson.D{
+			{Key: "previous_key_hash", Value: "$auth_key_hash"},
+			{Key: "auth_key_hash", Value: util.Hash(authKey)},
+			{Key: "previous_key_expiry", Value: expiresAt},
+		},
+	}}}).Decode(user); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return "", nil, ErrUserNotFound
+		}
+
+		logrus.Errorln(err)
+
+		return "", nil, err
+	}
+
+	return authKey, user, nil
+}
+
+func (r *repository) GetUsers(ctx context.Context) ([]*User, error) {
+	cur, err := r.mongo.Find(ctx, "users", bson.D{}, options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+
+	users := make([]*User, 0)
+	if err := cur.All(ctx, &users); err != nil {
+		logrus.Errorln(err)
+		return nil, err
+	}
+
+	return users, nil
+}
+
+func (r *repository) SetPermLevel(ctx context.Context, id primitive.ObjectID, permLevel int) error {
+	return r.mongo.UpdateOne(ctx, "users", bson.D{{
+		Key:   "_id",
+		Value: id,
+	}}, bson.D{{
+		Key: "$set",
+		Value: bson.D{
+			{Key: "perm_level", Value: permLevel},
+			{Key: "default_scopes", Value: ScopesForPermLevel(permLevel)},
+		},
+	}})
+}
+
+// DeactivateUser keeps the document so resolutions still point to a known sender, the Auth-Key stops working.
+func (r *repository) DeactivateUser(ctx context.Context, id primitive.ObjectID) error {
+	return r.mongo.UpdateOne(ctx, "users", bson.D{{
+		Key:   "_id",
+		Value: id,
+	}}, bson.D{{
+		Key:   "$set",
+		Value: bson.D{{Key: "deactivated", Value: true}},
+	}})
+}
+
+func (r *repository) SetLeaderboardOptOut(ctx context.Context, id primitive.ObjectID, optOut bool) error {
+	return r.mongo.UpdateOne(ctx, "users", bson.D{{
+		Key:   "_id",
+		Value: id,
+	}}, bson.D{{
+		Key:   "$set",
+		Value: bson.D{{Key: "leaderboard_opt_out", Value: optOut}},
+	}})
+}
+
+// GetLeaderboardOptOutIDs is looked up separately since resolutions keep a copy of the sender from back then.
+func (r *repository) GetLeaderboardOptOutIDs(ctx context.Context) ([]primitive.ObjectID, error) {
+	cur, err := r.mongo.Find(ctx, "users", bson.D{{
+		Key:   "leaderboard_opt_out",
+		Value: true,
+	}}, options.Find().SetProjection(bson.D{{Key: "_id", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+
+	users := make([]*User, 0)
+	if err := cur.All(ctx, &users); err != nil {
+		logrus.Errorln(err)
+		return nil, err
+	}
+
+	ids := make([]primitive.ObjectID, 0, len(users))
+	for _, user := range users {
+		ids = append(ids, user.ID)
+	}
+
+	return ids, nil
+}
+
+func (r *repository) GetUserByID(ctx context.Context, id primitive.ObjectID) (*User, error) {
+	user := &User{}
+	if err := r.mongo.FindOne(ctx, "users", bson.D{{
+		Key:   "_id",
+		Value: id,
+	}}).Decode(user); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, ErrUserNotFound
+		}
+
+		return nil, err
+	}
+
+	return user, nil
+}
+
+func (r *repository) CreateRefreshToken(ctx context.Context, userID primitive.ObjectID) (string, error) {
+	token := util.SecureRandomString(32)
+
+	if err := r.mongo.InsertOne(ctx, "refresh_tokens", &RefreshToken{
+		ID:        primitive.NewObjectIDFromTimestamp(time.Now()),
+		UserID:    userID,
+		TokenHash: util.SHA256(token),
+		ExpiresAt: time.Now().Add(tokens.RefreshTokenTTL),
 	}); err != nil {
 		logrus.Errorln(err)
 
 		return "", err
 	}
 
-	return authKey, nil
+	return token, nil
+}
+
+func (r *repository) GetRefreshToken(ctx context.Context, token string) (*RefreshToken, error) {
+	refreshToken := &RefreshToken{}
+	if err := r.mongo.FindOne(ctx, "refresh_tokens", bson.D{{
+		Key:   "token_hash",
+		Value: util.SHA256(token),
+	}}).Decode(refreshToken); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, fmt.Errorf("refresh token not found")
+		}
+
+		return nil, err
+	}
+
+	if refreshToken.Revoked {
+		return nil, fmt.Errorf("refresh token is revoked")
+	}
+
+	if time.Now().After(refreshToken.ExpiresAt) {
+		return nil, fmt.Errorf("refresh token is expired")
+	}
+
+	return refreshToken, nil
+}
+
+// ConsumeRefreshToken revokes a valid refresh token and returns it. The lookup and the revocation are one
+// operation, of two refreshes with the same token only one gets it.
+func (r *repository) ConsumeRefreshToken(ctx context.Context, token string) (*RefreshToken, error) {
+	refreshToken := &RefreshToken{}
+	if err := r.mongo.FindOneAndUpdate(ctx, "refresh_tokens", bson.D{
+		{Key: "token_hash", Value: util.SHA256(token)},
+		{Key: "revoked", Value: false},
+		{Key: "expires_at", Value: bson.D{{Key: "$gt", Value: time.Now()}}},
+	}, bson.D{{
+		Key:   "$set",
+		Value: bson.D{{Key: "revoked", Value: true}},
+	}}).Decode(refreshToken); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, ErrRefreshTokenInvalid
+		}
+
+		return nil, err
+	}
+
+	return refreshToken, nil
+}
+
+func (r *repository) RevokeRefreshToken(ctx context.Context, token string) error {
+	return r.mongo.UpdateOne(ctx, "refresh_tokens", bson.D{{
+		Key:   "token_hash",
+		Value: util.SHA256(token),
+	}}, bson.D{{
+		Key:   "$set",
+		Value: bson.D{{Key: "revoked", Value: true}},
+	}})
+}
+
+func (r *repository) RevokeUserRefreshTokens(ctx context.Context, userID primitive.ObjectID) error {
+	return r.mongo.UpdateMany(ctx, "refresh_tokens", bson.D{{
+		Key:   "user_id",
+		Value: userID,
+	}}, bson.D{{
+		Key:   "$set",
+		Value: bson.D{{Key: "revoked", Value: true}},
+	}})
 }
@@ -0,0 +1,297 @@
+package webhooks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/YusufOzmen01/veri-kontrol-backend/core/sources"
+	"github.com/YusufOzmen01/veri-kontrol-backend/repository/users"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type Repository interface {
+	GetWebhooks(ctx context.Context) ([]*Webhook, error)
+	GetWebhook(ctx context.Context, id primitive.ObjectID) (*Webhook, error)
+	AddWebhook(ctx context.Context, webhook *Webhook) error
+	DeleteWebhook(ctx context.Context, id primitive.ObjectID) error
+	AddDeliveries(ctx context.Context, deliveries []*Delivery) error
+	ClaimDueDelivery(ctx context.Context, now time.Time, lease time.Duration) (*Delivery, error)
+	UpdateDelivery(ctx context.Context, delivery *Delivery) error
+	GetDeliveries(ctx context.Context, filter *DeliveryFilter) ([]*Delivery, error)
+	CountDeliveries(ctx context.Context, filter *DeliveryFilter) (int64, error)
+}
+
+var (
+	ErrWebhookNotFound = errors.New("webhook not found")
+	ErrNothingDue      = errors.New("no webhook delivery is due")
+)
+
+type repository struct {
+	mongo sources.MongoClient
+}
+
+func NewRepository(mongo sources.MongoClient) Repository {
+	r := &repository{
+		mongo: mongo,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	r.ensureIndexes(ctx)
+
+	return r
+}
+
+func (r *repository) ensureIndexes(ctx context.Context) {
+	if _, err := r.mongo.CreateIndex(ctx, "webhook_deliveries", bson.E{Key: "status", Value: 1}, bson.E{Key: "next_attempt_at", Value: 1}); err != nil {
+		logrus.Errorf("Couldn't create webhook_deliveries status index: %s", err)
+	}
+
+	if _, err := r.mongo.CreateIndex(ctx, "webhook_deliveries", bson.E{Key: "webhook_id", Value: 1}, bson.E{Key: "_id", Value: -1}); err != nil {
+		logrus.Errorf("Couldn't create webhook_deliveries webhook_id index: %s", err)
+	}
+
+	// The index without the revision kept edits of a resolution from being delivered.
+	if err := r.mongo.DropIndex(ctx, "webhook_deliveries", "webhook_id_1_resolution_id_1"); err != nil {
+		logrus.Errorf("Couldn't drop webhook_deliveries webhook_id_1_resolution_id_1 index: %s", err)
+	}
+
+	// A revision of a resolution can be queued more than once, only the first counts.
+	if _, err := r.mongo.CreateIndexWithOptions(ctx, "webhook_deliveries", options.Index().SetUnique(true).SetPartialFilterExpression(bson.D{{
+		Key:   "resolution_id",
+		Value: bson.D{{Key: "$exists", Value: true}},
+	}}), bson.E{Key: "webhook_id", Value: 1}, bson.E{Key: "resolution_id", Value: 1}, bson.E{Key: "revision", Value: 1}); err != nil {
+		logrus.Errorf("Couldn't create webhook_deliveries resolution_id index: %s", err)
+	}
+}
+
+// Webhook receives every new resolution. The secret signs the requests and is never shown again.
+type Webhook struct {
+	ID        primitive.ObjectID `json:"_id" bson:"_id"`
+	URL       string             `json:"url" bson:"url"`
+	Secret    string             `json:"-" bson:"secret"`
+	CreatedBy *users.User        `json:"created_by" bson:"created_by"`
+	CreatedAt time.Time          `json:"created_at" bson:"created_at"`
+}
+
+func (w *Webhook) Validate() error {
+	if u, err := url.Parse(w.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("url must be an http or https URL")
+	}
+
+	if len(w.Secret) < 16 {
+		return fmt.Errorf("secret must be at least 16 characters")
+	}
+
+	return nil
+}
+
+const (
+	DeliveryPending   = "pending"
+	DeliveryDelivered = "delivered"
+	DeliveryFailed    = "failed"
+)
+
+// Delivery is one event for one webhook. The payload is stored as sent, so retries are byte for byte the same
+// and keep the same signature.
+type Delivery struct {
+	ID            primitive.ObjectID `json:"_id" bson:"_id"`
+	WebhookID     primitive.ObjectID `json:"webhook_id" bson:"webhook_id"`
+	Event         string             `json:"event" bson:"event"`
+	EntryID       int                `json:"entry_id" bson:"entry_id"`
+	ResolutionID  primitive.ObjectID `json:"resolution_id,omitempty" bson:"resolution_id,omitempty"`
+	Revision      int                `json:"revision" bson:"revision"`
+	Payload       string             `json:"payload" bson:"payload"`
+	Status        string             `json:"status" bson:"status"`
+	Attempts      int                `json:"attempts" bson:"attempts"`
+	LastStatus    int                `json:"last_status,omitempty" bson:"last_status,omitempty"`
+	LastError     string             `json:"last_error,omitempty" bson:"last_error,omitempty"`
+	NextAttemptAt time.Time          `json:"next_attempt_at" bson:"next_attempt_at"`
+	DeliveredAt   *time.Time         `json:"delivered_at,omitempty" bson:"delivered_at,omitempty"`
+	LeaseID       string             `json:"-" bson:"lease_id,omitempty"`
+}
+
+type DeliveryFilter struct {
+	WebhookID primitive.ObjectID
+	Status    string
+	Page      int
+	PageSize  int
+}
+
+func (f *DeliveryFilter) query() bson.D {
+	query := bson.D{{Key: "webhook_id", Value: f.WebhookID}}
+
+	if f.Status != "" {
+		query = append(query, bson.E{Key: "status", Value: f.Status})
+	}
+
+	return query
+}
+
+func (r *repository) GetWebhooks(ctx context.Context) ([]*Webhook, error) {
+	cur, err := r.mongo.Find(ctx, "webhooks", bson.D{}, options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+
+	webhooks := make([]*Webhook, 0)
+	if err := cur.All(ctx, &webhooks); err != nil {
+		logrus.Errorln(err)
+		return nil, err
+	}
+
+	return webhooks, nil
+}
+
+func (r *repository) GetWebhook(ctx context.Context, id primitive.ObjectID) (*Webhook, error) {
+	webhook := &Webhook{}
+	if err := r.mongo.FindOne(ctx, "webhooks", bson.D{{
+		Key:   "_id",
+		Value: id,
+	}}).Decode(webhook); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, ErrWebhookNotFound
+		}
+
+		return nil, err
+	}
+
+	return webhook, nil
+}
+
+func (r *repository) AddWebhook(ctx context.Context, webhook *Webhook) error {
+	webhook.ID = primitive.NewObjectIDFromTimestamp(time.Now())
+	webhook.CreatedAt = time.Now()
+
+	if err := r.mongo.InsertOne(ctx, "webhooks", webhook); err != nil {
+		logrus.Errorln(err)
+
+		return err
+	}
+
+	return nil
+}
+
+// DeleteWebhook also drops the deliveries which are still waiting, nobody is listening for them anymore.
+func (r *repository) DeleteWebhook(ctx context.Context, id primitive.ObjectID) error {
+	if err := r.mongo.DeleteOne(ctx, "webhooks", bson.D{{
+		Key:   "_id",
+		Value: id,
+	}}); err != nil {
+		logrus.Errorln(err)
+
+		return err
+	}
+
+	return r.mongo.DeleteMany(ctx, "webhook_deliveries", bson.D{
+		{Key: "webhook_id", Value: id},
+		{Key: "status", Value: DeliveryPending},
+	})
+}
+
+func (r *repository) AddDeliveries(ctx context.Context, deliveries []*Delivery) error {
+	if len(deliveries) == 0 {
+		return nil
+	}
+
+	documents := make([]interface{}, 0, len(deliveries))
+	for _, delivery := range deliveries {
+		documents = append(documents, delivery)
+	}
+
+	// Unordered, so deliveries which were already queued don't keep the others from being stored.
+	if err := r.mongo.InsertMany(ctx, "webhook_deliveries", documents, options.InsertMany().SetOrdered(false)); err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return nil
+		}
+
+		logrus.Errorln(err)
+
+		return err
+	}
+
+	return nil
+}
+
+// ClaimDueDelivery takes the pending delivery which waited the longest, ErrNothingDue when there is none. Like
+// the outbox, the next attempt is pushed back by the lease so replicas don't send it twice.
+func (r *repository) ClaimDueDelivery(ctx context.Context, now time.Time, lease time.Duration) (*Delivery, error) {
+	leaseID := primitive.NewObjectID().Hex()
+
+	delivery := &Delivery{}
+	if err := r.mongo.FindOneAndUpdate(ctx, "webhook_deliveries", bson.D{
+		{Key: "status", Value: DeliveryPending},
+		{Key: "next_attempt_at", Value: bson.D{{Key: "$lte", Value: now}}},
+	}, bson.D{{
+		Key: "$set",
+		Value: bson.D{
+			{Key: "next_attempt_at", Value: now.Add(lease)},
+			{Key: "lease_id", Value: leaseID},
+		},
+	}}, options.FindOneAndUpdate().SetSort(bson.D{{Key: "next_attempt_at", Value: 1}}).SetReturnDocument(options.After)).Decode(delivery); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, ErrNothingDue
+		}
+
+		return nil, err
+	}
+
+	return delivery, nil
+}
+
+// UpdateDelivery stores the outcome of an attempt, unless another replica claimed the delivery since.
+func (r *repository) UpdateDelivery(ctx context.Context, delivery *Delivery) error {
+	filter := bson.D{{Key: "_id", Value: delivery.ID}}
+	if delivery.LeaseID != "" {
+		filter = append(filter, bson.E{Key: "lease_id", Value: delivery.LeaseID})
+	}
+
+	return r.mongo.UpdateOne(ctx, "webhook_deliveries", filter, bson.D{{
+		Key: "$set",
+		Value: bson.D{
+			{Key: "status", Value: delivery.Status},
+			{Key: "attempts", Value: delivery.Attempts},
+			{Key: "last_status", Value: delivery.LastStatus},
+			{Key: "last_error", Value: delivery.LastError},
+			{Key: "next_attempt_at", Value: delivery.NextAttemptAt},
+			{Key: "delivered_at", Value: delivery.DeliveredAt},
+		},
+	}})
+}
+
+func (r *repository) GetDeliveries(ctx context.Context, filter *DeliveryFilter) ([]*Delivery, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "_id", Value: -1}})
+	if filter.PageSize > 0 {
+		page := filter.Page
+		if page < 1 {
+			page = 1
+		}
+
+		opts = opts.SetSkip(int64((page - 1) * filter.PageSize)).SetLimit(int64(filter.PageSize))
+	}
+
+	cur, err := r.mongo.Find(ctx, "webhook_deliveries", filter.query(), opts)
+	if err != nil {
+		return nil, err
+	}
+
+	deliveries := make([]*Delivery, 0)
+	if err := cur.All(ctx, &deliveries); err != nil {
+		logrus.Errorln(err)
+		return nil, err
+	}
+
+	return deliveries, nil
+}
+
+func (r *repository) CountDeliveries(ctx context.Context, filter *DeliveryFilter) (int64, error) {
+	return r.mongo.Count(ctx, "webhook_deliveries", filter.query())
+}
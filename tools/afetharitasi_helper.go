@@ -2,67 +2,460 @@ package tools
 
 import (
 	"context"
+	"encoding/gob"
 	"encoding/json"
 	"fmt"
+	"github.com/YusufOzmen01/veri-kontrol-backend/core/geohash"
+	"github.com/YusufOzmen01/veri-kontrol-backend/core/language"
 	"github.com/YusufOzmen01/veri-kontrol-backend/core/network"
+	"github.com/YusufOzmen01/veri-kontrol-backend/core/phones"
+	"github.com/YusufOzmen01/veri-kontrol-backend/core/scoring"
 	"github.com/YusufOzmen01/veri-kontrol-backend/core/sources"
+	"github.com/YusufOzmen01/veri-kontrol-backend/core/tracing"
+	"github.com/YusufOzmen01/veri-kontrol-backend/repository/cities"
 	"github.com/YusufOzmen01/veri-kontrol-backend/repository/locations"
 	log "github.com/sirupsen/logrus"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 )
 
+// SingleResponse is the looked up text of an entry. Lang is detected when it is fetched, so the language of
+// entries which only list coordinates is known before the next refresh.
 type SingleResponse struct {
-	FullText         string `json:"full_text"`
-	FormattedAddress string `json:"formatted_address"`
+	FullText         string    `json:"full_text"`
+	FormattedAddress string    `json:"formatted_address"`
+	FetchedAt        time.Time `json:"-"`
+	Lang             string    `json:"-"`
 }
 
-func GetAllLocations(ctx context.Context, cache sources.Cache) ([]*locations.Location, error) {
-	var d struct {
-		Locations []*locations.Location `json:"results"`
+// The cache may be backed by Redis which needs to know the concrete types it stores.
+func init() {
+	gob.Register([]*locations.Location{})
+	gob.Register(&SingleResponse{})
+}
+
+const locationsCacheKey = "locations"
+
+// UpstreamURL and LocationsCacheTTL are configurable, they have to be set before the first fetch. UpstreamURL
+// is the afetharita source used when no sources are configured.
+var (
+	UpstreamURL       = "https://apigo.afetharita.com"
+	LocationsCacheTTL = 15 * time.Minute
+)
+
+// KeywordScorer rates the entries on every refresh, nil leaves every score at zero.
+var KeywordScorer *scoring.Scorer
+
+// enrichLocations tags the entries with the event and the language, scores them, checks their coordinates and
+// pulls the phone numbers out of their text.
+// Sources which only list coordinates use the looked up text, entries whose text wasn't looked up yet are left
+// as they are until a refresh after the lookup.
+func enrichLocations(cache sources.Cache, eventID string, cityList []*cities.City, locs []*locations.Location) {
+	for _, loc := range locs {
+		loc.EventID = eventID
+		if len(loc.Loc) >= 2 {
+			loc.Geohash = geohash.Encode(loc.Loc[0], loc.Loc[1], geohash.Precision)
+		}
+
+		checkCoordinates(loc, cityList)
+		analyzeText(loc, entryTextOf(cache, loc))
+	}
+}
+
+// entryTextOf is the text of the feed, or the looked up one of the sources which only send coordinates. It is
+// empty while the lookup didn't happen yet.
+func entryTextOf(cache sources.Cache, loc *locations.Location) string {
+	if loc.OriginalMessage != "" {
+		return loc.OriginalMessage
+	}
+
+	if singleData, ok := CachedSingleLocation(cache, loc.EntryID); ok {
+		return singleData.FullText
+	}
+
+	return ""
+}
+
+// EntryLang is the language of an entry, the one of its looked up text when the feed didn't have it yet. It is
+// empty while the text wasn't looked up.
+func EntryLang(cache sources.Cache, loc *locations.Location) string {
+	if loc.Lang != "" || loc.OriginalMessage != "" {
+		return loc.Lang
+	}
+
+	if singleData, ok := CachedSingleLocation(cache, loc.EntryID); ok {
+		return singleData.Lang
 	}
 
-	data, exists := cache.Get("locations")
+	return ""
+}
+
+func analyzeText(loc *locations.Location, text string) {
+	loc.Score = KeywordScorer.Score(text)
+	loc.PhoneNumbers = phones.Extract(text)
+	loc.Lang = language.Detect(text)
+}
+
+// rescoreLocations analyzes the entries again whose text was looked up after the refresh which scored them,
+// without it they are served as if they had no urgent phrases until the next refresh. The cached slice is
+// shared with running requests, so the changed entries are copies in a new slice. It returns how many
+// changed.
+func rescoreLocations(ctx context.Context, cache sources.Cache) int {
+	cacheMu.Lock()
+	defer cacheMu.Unlock()
+
+	data, exists := cacheGet(ctx, cache, locationsCacheKey)
+	if !exists {
+		return 0
+	}
+
+	locs := data.([]*locations.Location)
+	updated := make([]*locations.Location, len(locs))
+	changed := 0
+
+	for i, loc := range locs {
+		updated[i] = loc
+
+		if loc.OriginalMessage != "" {
+			continue
+		}
+
+		text := entryTextOf(cache, loc)
+		if text == "" {
+			continue
+		}
+
+		analyzed := *loc
+		analyzeText(&analyzed, text)
+
+		if analyzed.Score != loc.Score || analyzed.Lang != loc.Lang || len(analyzed.PhoneNumbers) != len(loc.PhoneNumbers) {
+			updated[i] = &analyzed
+			changed++
+		}
+	}
+
+	if changed > 0 {
+		cacheSet(ctx, cache, locationsCacheKey, updated, LocationsCacheTTL)
+	}
+
+	return changed
+}
+
+func GetAllLocations(ctx context.Context, cache sources.Cache) ([]*locations.Location, error) {
+	data, exists := cacheGet(ctx, cache, locationsCacheKey)
 	if exists {
 		return data.([]*locations.Location), nil
 	}
 
-	res, _, err := network.ProcessGet(ctx, "https://apigo.afetharita.com/feeds/areas?ne_lat=39.91618777305531&ne_lng=47.85149904303703&sw_lat=36.07272886939253&sw_lng=23.872389299415502", map[string]string{
-		"User-Agent": "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/108.0.0.0 Safari/537.36",
+	return RefreshLocations(ctx, cache)
+}
+
+var upstream struct {
+	mu          sync.RWMutex
+	lastSuccess time.Time
+	lastErr     error
+	stale       bool
+}
+
+// UpstreamStatus fails when there is no usable copy of the feed. A failed refresh is tolerated for as long
+// as the previous copy is still cached.
+func UpstreamStatus() error {
+	upstream.mu.RLock()
+	defer upstream.mu.RUnlock()
+
+	if upstream.lastSuccess.IsZero() {
+		if upstream.lastErr != nil {
+			return upstream.lastErr
+		}
+
+		return fmt.Errorf("locations feed wasn't fetched yet")
+	}
+
+	if upstream.lastErr != nil && time.Since(upstream.lastSuccess) > LocationsCacheTTL {
+		return upstream.lastErr
+	}
+
+	return nil
+}
+
+// FeedStale is true while part of the served feed is an older copy, because a source couldn't be fetched or
+// its breaker is open.
+func FeedStale() bool {
+	upstream.mu.RLock()
+	defer upstream.mu.RUnlock()
+
+	return upstream.stale
+}
+
+func recordUpstream(stale, err error) {
+	upstream.mu.Lock()
+	defer upstream.mu.Unlock()
+
+	upstream.stale = err == nil && stale != nil
+
+	// A stale copy still counts as a failure for the health check, it just isn't one for the requests.
+	if err == nil {
+		err = stale
+	}
+
+	upstream.lastErr = err
+	if err == nil {
+		upstream.lastSuccess = time.Now()
+	}
+}
+
+// IntakeSource returns the entries submitted through the intake endpoint, they are merged into the feed on
+// every refresh. Nil leaves them out.
+var IntakeSource func(ctx context.Context) ([]*locations.Location, error)
+
+var cacheMu sync.Mutex
+
+// RefreshLocations pulls every source and replaces the cached copy regardless of its age. It only fails when
+// none of the sources could be fetched and there is no earlier copy of them either. Refreshes which overlap,
+// like the requests missing the cache after it expired, share one pull.
+func RefreshLocations(ctx context.Context, cache sources.Cache) ([]*locations.Location, error) {
+	locs, err := shared(ctx, "feed", locationsCacheKey, func(ctx context.Context) (interface{}, error) {
+		return refreshLocations(ctx, cache)
 	})
 	if err != nil {
 		return nil, err
 	}
 
-	if err := json.Unmarshal(res, &d); err != nil {
+	return locs.([]*locations.Location), nil
+}
+
+func refreshLocations(ctx context.Context, cache sources.Cache) (locs []*locations.Location, err error) {
+	ctx, span := tracing.Start(ctx, "feed.refresh")
+	defer func() { tracing.End(span, err) }()
+
+	locs, stale, err := fetchAllSources(ctx)
+	recordUpstream(stale, err)
+
+	if err != nil {
 		return nil, err
 	}
 
-	cache.SetWithTTL("locations", d.Locations, int64(time.Minute*15), 0)
+	// Intake entries are an addition, the upstream feed is still served when they can't be loaded.
+	if IntakeSource != nil {
+		intake, err := IntakeSource(ctx)
+		if err != nil {
+			log.Errorf("Couldn't load intake entries: %s", err)
+		} else {
+			locs = append(locs, intake...)
+		}
+	}
 
-	return d.Locations, nil
+	enrichLocations(cache, ActiveEventID(ctx), loadCities(ctx), locs)
+
+	cacheMu.Lock()
+	defer cacheMu.Unlock()
+
+	cacheSet(ctx, cache, locationsCacheKey, locs, LocationsCacheTTL)
+
+	return locs, nil
 }
 
-func GetSingleLocation(ctx context.Context, locationID int, cache sources.Cache) (*SingleResponse, error) {
-	data, exists := cache.Get(fmt.Sprintf("single_location_%d", locationID))
-	if exists {
-		return data.(*SingleResponse), nil
+type afetHaritaSource struct {
+	name    string
+	baseURL string
+}
+
+// NewAfetHaritaSource reads the afetharita.com feed, the list only has coordinates and the text is fetched
+// per entry.
+func NewAfetHaritaSource(name, baseURL string) Source {
+	return &afetHaritaSource{
+		name:    name,
+		baseURL: strings.TrimSuffix(baseURL, "/"),
 	}
+}
+
+func (s *afetHaritaSource) Name() string {
+	return s.name
+}
 
-	resp, _, err := network.ProcessGet(ctx, fmt.Sprintf("https://apigo.afetharita.com/feeds/%d", locationID), map[string]string{
+func (s *afetHaritaSource) Fetch(ctx context.Context) ([]*locations.Location, error) {
+	var d struct {
+		Locations []*locations.Location `json:"results"`
+	}
+
+	res, status, err := network.ProcessGet(ctx, s.baseURL+"/feeds/areas?ne_lat=39.91618777305531&ne_lng=47.85149904303703&sw_lat=36.07272886939253&sw_lng=23.872389299415502", map[string]string{
 		"User-Agent": "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/108.0.0.0 Safari/537.36",
 	})
 	if err != nil {
 		return nil, err
 	}
 
+	if status != 200 {
+		return nil, &network.StatusError{Status: status}
+	}
+
+	if err := json.Unmarshal(res, &d); err != nil {
+		return nil, err
+	}
+
+	return d.Locations, nil
+}
+
+func (s *afetHaritaSource) FetchOne(ctx context.Context, entryID int) (*SingleResponse, error) {
+	resp, status, err := network.ProcessGet(ctx, fmt.Sprintf("%s/feeds/%d", s.baseURL, entryID), map[string]string{
+		"User-Agent": "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/108.0.0.0 Safari/537.36",
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if status != 200 {
+		return nil, &network.StatusError{Status: status}
+	}
+
 	singleData := &SingleResponse{}
 	if err := json.Unmarshal(resp, singleData); err != nil {
 		log.Errorln(string(resp))
 
-		panic(err)
+		return nil, err
+	}
+
+	return singleData, nil
+}
+
+// AddLocation makes a new intake entry available right away instead of after the next refresh. The cached
+// slice is shared with running requests, so it is copied instead of appended to.
+func AddLocation(ctx context.Context, cache sources.Cache, location *locations.Location) error {
+	locs, err := GetAllLocations(ctx, cache)
+	if err != nil {
+		return err
+	}
+
+	enrichLocations(cache, ActiveEventID(ctx), loadCities(ctx), []*locations.Location{location})
+
+	cacheMu.Lock()
+	defer cacheMu.Unlock()
+
+	if data, exists := cacheGet(ctx, cache, locationsCacheKey); exists {
+		locs = data.([]*locations.Location)
+	}
+
+	updated := make([]*locations.Location, 0, len(locs)+1)
+	updated = append(updated, locs...)
+	updated = append(updated, location)
+
+	cacheSet(ctx, cache, locationsCacheKey, updated, LocationsCacheTTL)
+
+	return nil
+}
+
+func textCacheKey(entryID int) string {
+	return fmt.Sprintf("single_location_%d", entryID)
+}
+
+// InvalidateText drops the cached text of an entry and its translation, the next lookup asks its source again.
+func InvalidateText(ctx context.Context, cache sources.Cache, entryID int) {
+	cacheDel(ctx, cache, textCacheKey(entryID))
+	cacheDel(ctx, cache, translationCacheKey(entryID))
+}
+
+// textsValidAfter is when the feed was last invalidated, texts fetched before it count as missing even when they
+// are still cached. That covers the texts of entries which already left the cached feed.
+var (
+	textsMu         sync.Mutex
+	textsValidAfter time.Time
+)
+
+func dropTextsBefore(at time.Time) {
+	textsMu.Lock()
+	defer textsMu.Unlock()
+
+	if at.After(textsValidAfter) {
+		textsValidAfter = at
+	}
+}
+
+func textValid(text *SingleResponse) bool {
+	textsMu.Lock()
+	defer textsMu.Unlock()
+
+	return !text.FetchedAt.Before(textsValidAfter)
+}
+
+// InvalidateLocations drops the cached feed and the texts and translations of its entries, for after
+// corrections upstream. The geocoding results stay, they only depend on the coordinates. It returns how many
+// entries were dropped.
+func InvalidateLocations(ctx context.Context, cache sources.Cache) int {
+	cacheMu.Lock()
+	defer cacheMu.Unlock()
+
+	dropTextsBefore(time.Now())
+
+	data, exists := cacheGet(ctx, cache, locationsCacheKey)
+	if !exists {
+		return 0
+	}
+
+	locs := data.([]*locations.Location)
+	for _, loc := range locs {
+		cache.Del(textCacheKey(loc.EntryID))
+		cache.Del(translationCacheKey(loc.EntryID))
+	}
+
+	cache.Del(locationsCacheKey)
+
+	return len(locs)
+}
+
+// CachedSingleLocation only returns texts which were already looked up, it never asks upstream.
+func CachedSingleLocation(cache sources.Cache, locationID int) (*SingleResponse, bool) {
+	data, exists := cache.Get(textCacheKey(locationID))
+	if !exists || !textValid(data.(*SingleResponse)) {
+		return nil, false
+	}
+
+	return data.(*SingleResponse), true
+}
+
+// GetSingleLocation asks the source the entry came from for its full text. Lookups of the same entry which
+// overlap share one call.
+func GetSingleLocation(ctx context.Context, locationID int, cache sources.Cache) (*SingleResponse, error) {
+	if data, exists := cacheGet(ctx, cache, textCacheKey(locationID)); exists && textValid(data.(*SingleResponse)) {
+		return data.(*SingleResponse), nil
+	}
+
+	singleData, err := shared(ctx, "text", strconv.Itoa(locationID), func(ctx context.Context) (interface{}, error) {
+		return fetchSingleLocation(ctx, locationID, cache)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return singleData.(*SingleResponse), nil
+}
+
+// fetchSingleLocation waits for one of the MaxTextFetches slots, so the lookups never flood a source.
+func fetchSingleLocation(ctx context.Context, locationID int, cache sources.Cache) (*SingleResponse, error) {
+	source, offset, ok := sourceOf(locationID)
+	if !ok {
+		return nil, fmt.Errorf("entry %d doesn't belong to any source", locationID)
+	}
+
+	release, err := acquireTextFetch(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	var singleData *SingleResponse
+	if err := callSource(ctx, source, func() (err error) {
+		singleData, err = source.FetchOne(ctx, locationID-offset)
+
+		return err
+	}); err != nil {
+		return nil, err
 	}
 
-	cache.Set(fmt.Sprintf("single_location_%d", locationID), singleData, 0)
+	singleData.FetchedAt = time.Now()
+	singleData.Lang = language.Detect(singleData.FullText)
+	cacheSet(ctx, cache, textCacheKey(locationID), singleData, TextCacheTTL)
 
 	return singleData, nil
 }
@@ -0,0 +1,100 @@
+package tools
+
+import (
+	"context"
+
+	"github.com/YusufOzmen01/veri-kontrol-backend/repository/cities"
+	"github.com/YusufOzmen01/veri-kontrol-backend/repository/locations"
+	log "github.com/sirupsen/logrus"
+)
+
+// What was found wrong with the coordinates of an entry.
+const (
+	CoordIssueSwapped       = "swapped"
+	CoordIssueOutsideTurkey = "outside_turkey"
+	CoordIssueOutsideCities = "outside_cities"
+)
+
+// Turkey's bounding box with a little margin for the coast.
+const (
+	turkeyMinLat = 35.8
+	turkeyMaxLat = 42.2
+	turkeyMinLng = 25.6
+	turkeyMaxLng = 44.9
+)
+
+// CitySource returns the configured cities, entries outside all of them are flagged on every refresh. Nil or
+// a failing source only leaves the bounding box check.
+var CitySource func(ctx context.Context) ([]*cities.City, error)
+
+// InTurkey checks the point against Turkey's bounding box.
+func InTurkey(lat, lng float64) bool {
+	return lat >= turkeyMinLat && lat <= turkeyMaxLat && lng >= turkeyMinLng && lng <= turkeyMaxLng
+}
+
+// checkCoordinates flags entries whose coordinates are likely wrong. Swapped ones, which only make sense the
+// other way around, get the corrected pair as a suggestion. Around the affected region latitude and longitude
+// are close enough for a swapped pair to stay in Turkey, so the cities decide that too. The city check is
+// skipped without cities, a coordinate in the sea usually falls outside of all of them.
+func checkCoordinates(loc *locations.Location, cityList []*cities.City) {
+	loc.CoordSuspect, loc.CoordIssue, loc.SuggestedLoc = false, "", nil
+
+	if len(loc.Loc) < 2 {
+		return
+	}
+
+	lat, lng := loc.Loc[0], loc.Loc[1]
+
+	if !InTurkey(lat, lng) {
+		loc.CoordSuspect = true
+		loc.CoordIssue = CoordIssueOutsideTurkey
+
+		if InTurkey(lng, lat) {
+			loc.CoordIssue = CoordIssueSwapped
+			loc.SuggestedLoc = []float64{lng, lat}
+		}
+
+		return
+	}
+
+	if len(cityList) == 0 {
+		return
+	}
+
+	if inCities(cityList, lat, lng) {
+		return
+	}
+
+	loc.CoordSuspect = true
+	loc.CoordIssue = CoordIssueOutsideCities
+
+	if inCities(cityList, lng, lat) {
+		loc.CoordIssue = CoordIssueSwapped
+		loc.SuggestedLoc = []float64{lng, lat}
+	}
+}
+
+func inCities(cityList []*cities.City, lat, lng float64) bool {
+	for _, city := range cityList {
+		if city.Contains(lat, lng) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func loadCities(ctx context.Context) []*cities.City {
+	if CitySource == nil {
+		return nil
+	}
+
+	cityList, err := CitySource(ctx)
+	if err != nil {
+		log.Errorf("Couldn't load cities for the coordinate check: %s", err)
+
+		return nil
+	}
+
+	return cityList
+}
@@ -0,0 +1,192 @@
+package tools
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/YusufOzmen01/veri-kontrol-backend/core/dedup"
+	"github.com/YusufOzmen01/veri-kontrol-backend/repository/locations"
+	log "github.com/sirupsen/logrus"
+)
+
+const DefaultDuplicateInterval = 5 * time.Minute
+
+// DuplicateDetector periodically groups near duplicate resolutions and keeps an index of them for
+// /get-location, so retweets of already handled entries are not served again. Reports from within
+// dedup.NearbyRadius of a resolution with a similar text count as duplicates too.
+type DuplicateDetector struct {
+	locations locations.Repository
+	threshold float64
+
+	mu     sync.RWMutex
+	index  *dedup.Index
+	nearby *dedup.NearbyIndex
+}
+
+func NewDuplicateDetector(locations locations.Repository, threshold float64) *DuplicateDetector {
+	return &DuplicateDetector{
+		locations: locations,
+		threshold: threshold,
+		index:     dedup.NewIndex(threshold),
+		nearby:    dedup.NewNearbyIndex(dedup.NearbyThreshold, dedup.NearbyRadius),
+	}
+}
+
+// dedupPoint is nil for locations without coordinates, they are only compared by text.
+func dedupPoint(location []float64, hash string) *dedup.Point {
+	if len(location) < 2 {
+		return nil
+	}
+
+	return &dedup.Point{Lat: location[0], Lng: location[1], Geohash: hash}
+}
+
+// matchDuplicate looks for the text anywhere first and then for a similar one close to p.
+func matchDuplicate(index *dedup.Index, nearby *dedup.NearbyIndex, p *dedup.Point, text string) (int, bool) {
+	if id, ok := index.Match(text); ok {
+		return id, true
+	}
+
+	if p == nil {
+		return 0, false
+	}
+
+	return nearby.Match(*p, text)
+}
+
+// IsDuplicate returns the entry ID the location's text is a near duplicate of.
+func (d *DuplicateDetector) IsDuplicate(location *locations.Location, text string) (int, bool) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	return matchDuplicate(d.index, d.nearby, dedupPoint(location.Loc, location.Geohash), text)
+}
+
+// Detect rebuilds the index from the resolved locations, the oldest entry of every group becomes the one
+// the others are marked as duplicate_of.
+func (d *DuplicateDetector) Detect(ctx context.Context) error {
+	index := dedup.NewIndex(d.threshold)
+	nearby := dedup.NewNearbyIndex(dedup.NearbyThreshold, dedup.NearbyRadius)
+	marked := 0
+
+	if err := d.locations.StreamLocations(ctx, nil, func(location *locations.LocationDB) error {
+		if location.TweetContents == "" {
+			return nil
+		}
+
+		p := dedupPoint(location.Location, location.Geohash)
+
+		duplicateOf, ok := matchDuplicate(index, nearby, p, location.TweetContents)
+		if !ok {
+			index.Add(location.EntryID, location.TweetContents)
+			if p != nil {
+				nearby.Add(location.EntryID, *p, location.TweetContents)
+			}
+
+			duplicateOf = 0
+		}
+
+		if duplicateOf == location.DuplicateOf || duplicateOf == location.EntryID {
+			return nil
+		}
+
+		marked++
+
+		return d.locations.SetDuplicateOf(ctx, location.EntryID, duplicateOf)
+	}); err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	d.index = index
+	d.nearby = nearby
+	d.mu.Unlock()
+
+	log.Infof("Duplicate detection indexed %d entries, updated %d", index.Len(), marked)
+
+	return nil
+}
+
+func (d *DuplicateDetector) Run(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = DefaultDuplicateInterval
+	}
+
+	for {
+		if err := d.Detect(ctx); err != nil {
+			log.Errorf("Couldn't detect duplicates: %s", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+		}
+	}
+}
+
+// Cluster is a group of unresolved entries with near duplicate texts, the oldest one is the canonical entry
+// the others get resolved as duplicates of.
+type Cluster struct {
+	CanonicalID int    `json:"canonical_id"`
+	EntryIDs    []int  `json:"entry_ids"`
+	Text        string `json:"text"`
+}
+
+// FindClusters groups the entries by near duplicate text, or similar text within dedup.NearbyRadius, with the
+// same thresholds as the resolutions. Entries text returns nothing for are left out, and only groups of two or
+// more are returned, biggest first.
+func (d *DuplicateDetector) FindClusters(locs []*locations.Location, text func(location *locations.Location) string) []*Cluster {
+	sorted := make([]*locations.Location, len(locs))
+	copy(sorted, locs)
+
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Epoch < sorted[j].Epoch })
+
+	index := dedup.NewIndex(d.threshold)
+	nearby := dedup.NewNearbyIndex(dedup.NearbyThreshold, dedup.NearbyRadius)
+	clusters := make([]*Cluster, 0)
+	byCanonical := make(map[int]*Cluster)
+
+	for _, location := range sorted {
+		t := text(location)
+		if t == "" {
+			continue
+		}
+
+		p := dedupPoint(location.Loc, location.Geohash)
+
+		if canonicalID, ok := matchDuplicate(index, nearby, p, t); ok {
+			cluster := byCanonical[canonicalID]
+			cluster.EntryIDs = append(cluster.EntryIDs, location.EntryID)
+
+			continue
+		}
+
+		index.Add(location.EntryID, t)
+		if p != nil {
+			nearby.Add(location.EntryID, *p, t)
+		}
+
+		cluster := &Cluster{
+			CanonicalID: location.EntryID,
+			EntryIDs:    []int{location.EntryID},
+			Text:        t,
+		}
+
+		clusters = append(clusters, cluster)
+		byCanonical[location.EntryID] = cluster
+	}
+
+	found := make([]*Cluster, 0)
+	for _, cluster := range clusters {
+		if len(cluster.EntryIDs) > 1 {
+			found = append(found, cluster)
+		}
+	}
+
+	sort.SliceStable(found, func(i, j int) bool { return len(found[i].EntryIDs) > len(found[j].EntryIDs) })
+
+	return found
+}
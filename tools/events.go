@@ -0,0 +1,28 @@
+package tools
+
+import (
+	"context"
+
+	"github.com/YusufOzmen01/veri-kontrol-backend/repository/events"
+	log "github.com/sirupsen/logrus"
+)
+
+// ActiveEvent looks up the event the feed currently belongs to. Nil, or a failed lookup, falls back to the
+// default event.
+var ActiveEvent func(ctx context.Context) (*events.Event, error)
+
+// ActiveEventID is what raw entries and resolutions without an entry in the feed are tagged with.
+func ActiveEventID(ctx context.Context) string {
+	if ActiveEvent == nil {
+		return events.DefaultEvent.ID
+	}
+
+	event, err := ActiveEvent(ctx)
+	if err != nil {
+		log.Errorf("Couldn't look up the active event: %s", err)
+
+		return events.DefaultEvent.ID
+	}
+
+	return event.ID
+}
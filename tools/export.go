@@ -0,0 +1,326 @@
+package tools
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/YusufOzmen01/veri-kontrol-backend/core/redact"
+	"github.com/YusufOzmen01/veri-kontrol-backend/repository/locations"
+)
+
+type GeoJSONFeature struct {
+	Type       string            `json:"type"`
+	Geometry   GeoJSONGeometry   `json:"geometry"`
+	Properties GeoJSONProperties `json:"properties"`
+}
+
+type GeoJSONGeometry struct {
+	Type        string    `json:"type"`
+	Coordinates []float64 `json:"coordinates"`
+}
+
+type GeoJSONProperties struct {
+	EntryID          int      `json:"entry_id"`
+	Type             int      `json:"type"`
+	Reason           string   `json:"reason"`
+	CorrectedAddress string   `json:"corrected_address"`
+	CorrectedLat     *float64 `json:"corrected_lat,omitempty"`
+	CorrectedLng     *float64 `json:"corrected_lng,omitempty"`
+	Epoch            int      `json:"epoch"`
+	Sender           string   `json:"sender"`
+	Source           string   `json:"source,omitempty"`
+}
+
+// CSVColumn is a column of the CSV export, Name is its header.
+type CSVColumn struct {
+	Name  string
+	value func(location *locations.LocationDB) string
+}
+
+func coordinate(location *locations.LocationDB, i int) string {
+	if len(location.Location) < 2 {
+		return ""
+	}
+
+	return strconv.FormatFloat(location.Location[i], 'f', -1, 64)
+}
+
+func optionalCoordinate(value *float64) string {
+	if value == nil {
+		return ""
+	}
+
+	return strconv.FormatFloat(*value, 'f', -1, 64)
+}
+
+// CSVColumns are in their default order, ParseCSVColumns picks and reorders them.
+var CSVColumns = []CSVColumn{
+	{"entry_id", func(l *locations.LocationDB) string { return strconv.Itoa(l.EntryID) }},
+	{"type", func(l *locations.LocationDB) string { return strconv.Itoa(l.Type) }},
+	{"reason", func(l *locations.LocationDB) string { return l.Reason }},
+	{"corrected", func(l *locations.LocationDB) string { return strconv.FormatBool(l.Corrected) }},
+	{"status", func(l *locations.LocationDB) string { return l.Status }},
+	{"lat", func(l *locations.LocationDB) string { return coordinate(l, 0) }},
+	{"lng", func(l *locations.LocationDB) string { return coordinate(l, 1) }},
+	{"corrected_lat", func(l *locations.LocationDB) string { return optionalCoordinate(l.CorrectedLat) }},
+	{"corrected_lng", func(l *locations.LocationDB) string { return optionalCoordinate(l.CorrectedLng) }},
+	{"original_address", func(l *locations.LocationDB) string { return l.OriginalAddress }},
+	{"corrected_address", func(l *locations.LocationDB) string { return l.CorrectedAddress }},
+	{"open_address", func(l *locations.LocationDB) string { return l.OpenAddress }},
+	{"apartment", func(l *locations.LocationDB) string { return l.Apartment }},
+	{"tweet_contents", func(l *locations.LocationDB) string { return l.TweetContents }},
+	{"epoch", func(l *locations.LocationDB) string { return strconv.Itoa(l.Epoch) }},
+	{"source", func(l *locations.LocationDB) string { return l.Source }},
+	{"resolved_at", func(l *locations.LocationDB) string { return l.ID.Timestamp().UTC().Format(time.RFC3339) }},
+	{"sender", func(l *locations.LocationDB) string {
+		if l.Sender == nil {
+			return ""
+		}
+
+		return l.Sender.Name
+	}},
+}
+
+// piiColumns are the columns which are redacted for keys without the export:pii scope.
+var piiColumns = map[string]func(r redact.Redactor, value string) string{
+	"corrected_address": redact.Redactor.Text,
+	"open_address":      redact.Redactor.Text,
+	"apartment":         redact.Redactor.Text,
+	"tweet_contents":    redact.Redactor.Text,
+	"sender":            redact.Redactor.Name,
+}
+
+// ParseCSVColumns picks the columns named in the comma separated fields, all of them when it is empty.
+func ParseCSVColumns(fields string) ([]CSVColumn, error) {
+	if fields == "" {
+		return CSVColumns, nil
+	}
+
+	columns := make([]CSVColumn, 0)
+
+	for _, field := range strings.Split(fields, ",") {
+		field = strings.TrimSpace(field)
+		found := false
+
+		for _, column := range CSVColumns {
+			if column.Name == field {
+				columns = append(columns, column)
+				found = true
+
+				break
+			}
+		}
+
+		if !found {
+			return nil, fmt.Errorf("unknown field %q", field)
+		}
+	}
+
+	return columns, nil
+}
+
+// WriteGeoJSON writes the entries of filter which have a location as a FeatureCollection.
+func WriteGeoJSON(ctx context.Context, w io.Writer, locationRepository locations.Repository, filter *locations.LocationFilter, redactor redact.Redactor) error {
+	if _, err := io.WriteString(w, `{"type":"FeatureCollection","features":[`); err != nil {
+		return err
+	}
+
+	first := true
+	if err := locationRepository.StreamLocations(ctx, filter, func(location *locations.LocationDB) error {
+		if len(location.Location) < 2 {
+			return nil
+		}
+
+		sender := ""
+		if location.Sender != nil {
+			sender = location.Sender.Name
+		}
+
+		feature, err := json.Marshal(&GeoJSONFeature{
+			Type: "Feature",
+			Geometry: GeoJSONGeometry{
+				Type:        "Point",
+				Coordinates: []float64{location.Location[1], location.Location[0]},
+			},
+			Properties: GeoJSONProperties{
+				EntryID:          location.EntryID,
+				Type:             location.Type,
+				Reason:           location.Reason,
+				CorrectedAddress: redactor.Text(location.CorrectedAddress),
+				CorrectedLat:     location.CorrectedLat,
+				CorrectedLng:     location.CorrectedLng,
+				Epoch:            location.Epoch,
+				Sender:           redactor.Name(sender),
+				Source:           location.Source,
+			},
+		})
+		if err != nil {
+			return err
+		}
+
+		if !first {
+			if _, err := w.Write([]byte{','}); err != nil {
+				return err
+			}
+		}
+		first = false
+
+		_, err = w.Write(feature)
+
+		return err
+	}); err != nil {
+		_, _ = io.WriteString(w, "]}")
+
+		return err
+	}
+
+	_, err := io.WriteString(w, "]}")
+
+	return err
+}
+
+// WriteCSV writes the entries of filter with the given columns. It starts with a UTF-8 BOM, without it Excel
+// opens the file in the local code page and breaks the Turkish characters.
+func WriteCSV(ctx context.Context, w io.Writer, locationRepository locations.Repository, filter *locations.LocationFilter, columns []CSVColumn, redactor redact.Redactor) error {
+	if _, err := io.WriteString(w, "\xEF\xBB\xBF"); err != nil {
+		return err
+	}
+
+	writer := csv.NewWriter(w)
+	defer writer.Flush()
+
+	header := make([]string, len(columns))
+	for i, column := range columns {
+		header[i] = column.Name
+	}
+
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+
+	return locationRepository.StreamLocations(ctx, filter, func(location *locations.LocationDB) error {
+		record := make([]string, len(columns))
+		for i, column := range columns {
+			record[i] = column.value(location)
+			if redactValue, ok := piiColumns[column.Name]; ok {
+				record[i] = redactValue(redactor, record[i])
+			}
+
+			record[i] = csvCell(record[i])
+		}
+
+		return writer.Write(record)
+	})
+}
+
+// csvCell keeps spreadsheets from running a cell as a formula. Texts come from the public, a cell starting with
+// = + - @ or a tab or carriage return gets a leading ' so it stays text. Numbers, the negative ones included,
+// are left alone.
+func csvCell(value string) string {
+	if value == "" || !strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return value
+	}
+
+	if _, err := strconv.ParseFloat(value, 64); err == nil {
+		return value
+	}
+
+	return "'" + value
+}
+
+type kmlPlacemark struct {
+	XMLName     xml.Name `xml:"Placemark"`
+	Name        string   `xml:"name"`
+	Description string   `xml:"description"`
+	StyleURL    string   `xml:"styleUrl"`
+	Coordinates string   `xml:"Point>coordinates"`
+}
+
+// kmlStyles are the pin colours per type, in the aabbggrr order KML uses. Types without one get the default pin.
+var kmlStyles = []struct {
+	locationType int
+	color        string
+}{
+	{locations.TypeWreckage, "ff0000ff"},
+	{locations.TypeSupplyHelp, "ff00a5ff"},
+	{locations.TypeShelter, "ff00ff00"},
+	{locations.TypeOther, "ffff0000"},
+}
+
+const kmlPin = "http://maps.google.com/mapfiles/kml/pushpin/wht-pushpin.png"
+
+func kmlStyleID(locationType int) string {
+	for _, style := range kmlStyles {
+		if style.locationType == locationType {
+			return fmt.Sprintf("type-%d", locationType)
+		}
+	}
+
+	return "type-default"
+}
+
+// WriteKML writes the resolutions of filter as placemarks for the field teams using Google Earth. Spam is left
+// out, the balloon shows the corrected address the volunteers agreed on.
+func WriteKML(ctx context.Context, w io.Writer, locationRepository locations.Repository, filter *locations.LocationFilter, redactor redact.Redactor) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+
+	if _, err := io.WriteString(w, `<kml xmlns="http://www.opengis.net/kml/2.2"><Document><name>locations</name>`); err != nil {
+		return err
+	}
+
+	if _, err := fmt.Fprintf(w, `<Style id="type-default"><IconStyle><Icon><href>%s</href></Icon></IconStyle></Style>`, kmlPin); err != nil {
+		return err
+	}
+
+	for _, style := range kmlStyles {
+		if _, err := fmt.Fprintf(w, `<Style id="%s"><IconStyle><color>%s</color><Icon><href>%s</href></Icon></IconStyle></Style>`, kmlStyleID(style.locationType), style.color, kmlPin); err != nil {
+			return err
+		}
+	}
+
+	encoder := xml.NewEncoder(w)
+
+	err := locationRepository.StreamLocations(ctx, filter, func(location *locations.LocationDB) error {
+		if len(location.Location) < 2 || location.Type == locations.TypeSpam {
+			return nil
+		}
+
+		description := location.CorrectedAddress
+		if location.Apartment != "" {
+			description += "\n" + location.Apartment
+		}
+
+		// Field teams navigate by the pin, the building the volunteer marked beats the reported coordinate.
+		coordinates := coordinate(location, 1) + "," + coordinate(location, 0)
+		if location.CorrectedLat != nil && location.CorrectedLng != nil {
+			coordinates = optionalCoordinate(location.CorrectedLng) + "," + optionalCoordinate(location.CorrectedLat)
+		}
+
+		return encoder.Encode(&kmlPlacemark{
+			Name:        fmt.Sprintf("#%d", location.EntryID),
+			Description: redactor.Text(description),
+			StyleURL:    "#" + kmlStyleID(location.Type),
+			Coordinates: coordinates,
+		})
+	})
+
+	// The document is closed even after a failure, the placemarks written so far stay readable.
+	if flushErr := encoder.Flush(); err == nil {
+		err = flushErr
+	}
+
+	if _, closeErr := io.WriteString(w, "</Document></kml>"); err == nil {
+		err = closeErr
+	}
+
+	return err
+}
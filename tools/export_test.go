@@ -0,0 +1,29 @@
+package tools
+
+import "testing"
+
+func TestCSVCell(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"", ""},
+		{"Kurtuluş Mah. Antakya", "Kurtuluş Mah. Antakya"},
+		{"=HYPERLINK(\"http://example.com\")", "'=HYPERLINK(\"http://example.com\")"},
+		{"+cmd|' /C calc'!A0", "'+cmd|' /C calc'!A0"},
+		{"-2+3+cmd|' /C calc'!A0", "'-2+3+cmd|' /C calc'!A0"},
+		{"@SUM(1+1)", "'@SUM(1+1)"},
+		{"\t=1+1", "'\t=1+1"},
+		{"\r=1+1", "'\r=1+1"},
+		{"36.2023", "36.2023"},
+		{"-36.2023", "-36.2023"},
+		{"+905551234567", "+905551234567"},
+		{"a=1", "a=1"},
+	}
+
+	for _, tt := range tests {
+		if got := csvCell(tt.value); got != tt.want {
+			t.Errorf("csvCell(%q) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,220 @@
+package tools
+
+import (
+	"context"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/YusufOzmen01/veri-kontrol-backend/core/network"
+	"github.com/YusufOzmen01/veri-kontrol-backend/core/sources"
+	"github.com/YusufOzmen01/veri-kontrol-backend/repository/locations"
+	log "github.com/sirupsen/logrus"
+)
+
+func init() {
+	gob.Register(&locations.Address{})
+}
+
+const (
+	DefaultNominatimURL = "https://nominatim.openstreetmap.org"
+
+	geocodeCacheTTL = 7 * 24 * time.Hour
+	geocodeTimeout  = 5 * time.Second
+
+	// nominatimInterval follows the public instance's usage policy of one request a second.
+	nominatimInterval = time.Second
+)
+
+type Geocoder interface {
+	ReverseGeocode(ctx context.Context, lat, lng float64) (*locations.Address, error)
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+
+	return ""
+}
+
+type nominatimGeocoder struct {
+	baseURL   string
+	userAgent string
+
+	mu   sync.Mutex
+	last time.Time
+}
+
+func NewNominatimGeocoder(baseURL, userAgent string) Geocoder {
+	if baseURL == "" {
+		baseURL = DefaultNominatimURL
+	}
+
+	return &nominatimGeocoder{
+		baseURL:   baseURL,
+		userAgent: userAgent,
+	}
+}
+
+func (g *nominatimGeocoder) wait(ctx context.Context) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if wait := time.Until(g.last.Add(nominatimInterval)); wait > 0 {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+
+	g.last = time.Now()
+
+	return nil
+}
+
+func (g *nominatimGeocoder) ReverseGeocode(ctx context.Context, lat, lng float64) (*locations.Address, error) {
+	if err := g.wait(ctx); err != nil {
+		return nil, err
+	}
+
+	query := url.Values{}
+	query.Set("format", "jsonv2")
+	query.Set("lat", fmt.Sprintf("%f", lat))
+	query.Set("lon", fmt.Sprintf("%f", lng))
+	query.Set("zoom", "16")
+	query.Set("accept-language", "tr")
+
+	res, status, err := network.ProcessGet(ctx, g.baseURL+"/reverse?"+query.Encode(), map[string]string{
+		"User-Agent": g.userAgent,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if status != 200 {
+		return nil, fmt.Errorf("nominatim returned status %d", status)
+	}
+
+	var d struct {
+		Address struct {
+			Province      string `json:"province"`
+			State         string `json:"state"`
+			City          string `json:"city"`
+			Town          string `json:"town"`
+			County        string `json:"county"`
+			CityDistrict  string `json:"city_district"`
+			Neighbourhood string `json:"neighbourhood"`
+			Suburb        string `json:"suburb"`
+			Quarter       string `json:"quarter"`
+			Village       string `json:"village"`
+		} `json:"address"`
+	}
+
+	if err := json.Unmarshal(res, &d); err != nil {
+		return nil, err
+	}
+
+	return &locations.Address{
+		Province:     firstNonEmpty(d.Address.Province, d.Address.State, d.Address.City),
+		District:     firstNonEmpty(d.Address.Town, d.Address.County, d.Address.CityDistrict),
+		Neighborhood: firstNonEmpty(d.Address.Neighbourhood, d.Address.Suburb, d.Address.Quarter, d.Address.Village),
+	}, nil
+}
+
+type googleGeocoder struct {
+	apiKey string
+}
+
+func NewGoogleGeocoder(apiKey string) Geocoder {
+	return &googleGeocoder{
+		apiKey: apiKey,
+	}
+}
+
+func (g *googleGeocoder) ReverseGeocode(ctx context.Context, lat, lng float64) (*locations.Address, error) {
+	query := url.Values{}
+	query.Set("latlng", fmt.Sprintf("%f,%f", lat, lng))
+	query.Set("language", "tr")
+	query.Set("key", g.apiKey)
+
+	res, _, err := network.ProcessGet(ctx, "https://maps.googleapis.com/maps/api/geocode/json?"+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var d struct {
+		Status  string `json:"status"`
+		Results []struct {
+			AddressComponents []struct {
+				LongName string   `json:"long_name"`
+				Types    []string `json:"types"`
+			} `json:"address_components"`
+		} `json:"results"`
+	}
+
+	if err := json.Unmarshal(res, &d); err != nil {
+		return nil, err
+	}
+
+	if d.Status != "OK" || len(d.Results) == 0 {
+		return nil, fmt.Errorf("google geocoding returned %s", d.Status)
+	}
+
+	components := make(map[string]string)
+	for _, component := range d.Results[0].AddressComponents {
+		for _, t := range component.Types {
+			if _, ok := components[t]; !ok {
+				components[t] = component.LongName
+			}
+		}
+	}
+
+	return &locations.Address{
+		Province:     components["administrative_area_level_1"],
+		District:     components["administrative_area_level_2"],
+		Neighborhood: firstNonEmpty(components["administrative_area_level_4"], components["neighborhood"], components["sublocality"]),
+	}, nil
+}
+
+type cachedGeocoder struct {
+	geocoder Geocoder
+	cache    sources.Cache
+}
+
+// NewCachedGeocoder remembers results per coordinate rounded to about a metre, entries are served over and
+// over and both providers limit how often they can be asked.
+func NewCachedGeocoder(geocoder Geocoder, cache sources.Cache) Geocoder {
+	return &cachedGeocoder{
+		geocoder: geocoder,
+		cache:    cache,
+	}
+}
+
+func (g *cachedGeocoder) ReverseGeocode(ctx context.Context, lat, lng float64) (*locations.Address, error) {
+	key := fmt.Sprintf("geocode_%.5f_%.5f", lat, lng)
+
+	if data, exists := cacheGet(ctx, g.cache, key); exists {
+		return data.(*locations.Address), nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, geocodeTimeout)
+	defer cancel()
+
+	address, err := g.geocoder.ReverseGeocode(ctx, lat, lng)
+	if err != nil {
+		log.Errorf("Reverse geocoding %f,%f failed: %s", lat, lng, err)
+
+		return nil, err
+	}
+
+	cacheSet(ctx, g.cache, key, address, geocodeCacheTTL)
+
+	return address, nil
+}
@@ -0,0 +1,131 @@
+package tools
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/YusufOzmen01/veri-kontrol-backend/core/sources"
+	"github.com/YusufOzmen01/veri-kontrol-backend/repository/invalidations"
+	log "github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	DefaultInvalidationInterval = 10 * time.Second
+
+	// invalidationOverlap is how far back every poll looks again, invalidations written by replicas whose clocks
+	// are a bit behind still show up.
+	invalidationOverlap = time.Minute
+)
+
+// CacheInvalidator spreads cache invalidations between the replicas. Each replica keeps its own cache unless
+// Redis is configured, so an invalidation is recorded and every other replica applies it on its next poll.
+type CacheInvalidator struct {
+	cache         sources.Cache
+	invalidations invalidations.Repository
+
+	mu    sync.Mutex
+	since time.Time
+	seen  map[primitive.ObjectID]time.Time
+}
+
+func NewCacheInvalidator(cache sources.Cache, invalidations invalidations.Repository) *CacheInvalidator {
+	return &CacheInvalidator{
+		cache:         cache,
+		invalidations: invalidations,
+		since:         time.Now(),
+		seen:          make(map[primitive.ObjectID]time.Time),
+	}
+}
+
+func (ci *CacheInvalidator) apply(ctx context.Context, entryID int) int {
+	if entryID > 0 {
+		InvalidateText(ctx, ci.cache, entryID)
+
+		return 1
+	}
+
+	return InvalidateLocations(ctx, ci.cache)
+}
+
+// Invalidate drops the text of entryID, or the feed and every text when it is 0, here and then on the other
+// replicas. It returns how many entries were dropped here.
+func (ci *CacheInvalidator) Invalidate(ctx context.Context, entryID int) (int, error) {
+	dropped := ci.apply(ctx, entryID)
+
+	invalidation, err := ci.invalidations.Add(ctx, entryID)
+	if err != nil {
+		return dropped, err
+	}
+
+	ci.mu.Lock()
+	ci.seen[invalidation.ID] = invalidation.At
+	ci.mu.Unlock()
+
+	return dropped, nil
+}
+
+// Poll applies the invalidations the other replicas recorded since the last poll.
+func (ci *CacheInvalidator) Poll(ctx context.Context) error {
+	ci.mu.Lock()
+	since := ci.since
+	ci.mu.Unlock()
+
+	recorded, err := ci.invalidations.GetSince(ctx, since.Add(-invalidationOverlap))
+	if err != nil {
+		return err
+	}
+
+	ci.mu.Lock()
+	defer ci.mu.Unlock()
+
+	for _, invalidation := range recorded {
+		if invalidation.At.After(ci.since) {
+			ci.since = invalidation.At
+		}
+
+		if _, ok := ci.seen[invalidation.ID]; ok {
+			continue
+		}
+
+		ci.seen[invalidation.ID] = invalidation.At
+		dropped := ci.apply(ctx, invalidation.EntryID)
+
+		log.Debugf("Applied the cache invalidation of entry %d from another replica, dropped %d entries", invalidation.EntryID, dropped)
+	}
+
+	for id, at := range ci.seen {
+		if at.Before(ci.since.Add(-2 * invalidationOverlap)) {
+			delete(ci.seen, id)
+		}
+	}
+
+	return nil
+}
+
+// Run drops the texts fetched before the last invalidation of the feed first, a shared cache may still hold them
+// when this replica starts.
+func (ci *CacheInvalidator) Run(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = DefaultInvalidationInterval
+	}
+
+	if last, err := ci.invalidations.GetLastFeed(ctx); err != nil {
+		log.Errorf("Couldn't get the last cache invalidation: %s", err)
+	} else if last != nil {
+		dropTextsBefore(last.At)
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+		}
+
+		if err := ci.Poll(ctx); err != nil {
+			log.Errorf("Couldn't poll the cache invalidations: %s", err)
+		}
+	}
+}
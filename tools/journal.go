@@ -0,0 +1,58 @@
+package tools
+
+import (
+	"context"
+	"time"
+
+	"github.com/YusufOzmen01/veri-kontrol-backend/repository/audit"
+	"github.com/YusufOzmen01/veri-kontrol-backend/repository/locations"
+	"github.com/YusufOzmen01/veri-kontrol-backend/repository/outbox"
+	log "github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Resolution is a resolution as the local logs keep it, the journal and the queue of the degraded mode, with
+// its audit event.
+type Resolution struct {
+	Entry *locations.LocationDB `json:"entry"`
+	Event *audit.Event          `json:"event"`
+}
+
+// The outcomes the journal records after a resolution. One without an outcome was accepted when the process
+// stopped, it may or may not have been written.
+const (
+	OutcomeStored   = "stored"
+	OutcomeQueued   = "queued"
+	OutcomeRejected = "rejected"
+)
+
+// Outcome is what became of a resolution in the journal, ResolutionID is the _id of its entry.
+type Outcome struct {
+	ResolutionID primitive.ObjectID `json:"resolution_id"`
+	EntryID      int                `json:"entry_id"`
+	Outcome      string             `json:"outcome"`
+	At           time.Time          `json:"at"`
+}
+
+// WriteResolution stores a resolution read back from a local log the way /resolve does it without a
+// transaction. It fails with locations.ErrAlreadyResolved when the entry has a resolution already, a failed
+// audit event or outbox message is only logged.
+func WriteResolution(ctx context.Context, locationRepository locations.Repository, auditLog audit.Repository, outboxMessages outbox.Repository, resolution *Resolution) error {
+	entry := resolution.Entry
+
+	if err := locationRepository.ResolveLocation(ctx, entry); err != nil {
+		return err
+	}
+
+	if resolution.Event != nil {
+		if err := auditLog.Record(ctx, resolution.Event); err != nil {
+			log.Errorf("Couldn't record %s audit event: %s", resolution.Event.Action, err)
+		}
+	}
+
+	if err := QueueResolved(ctx, outboxMessages, entry); err != nil {
+		log.Errorf("Couldn't queue the resolution of entry %d: %s", entry.EntryID, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,232 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/YusufOzmen01/veri-kontrol-backend/core/sources"
+	"github.com/YusufOzmen01/veri-kontrol-backend/repository/cities"
+	"github.com/YusufOzmen01/veri-kontrol-backend/repository/locations"
+	"github.com/YusufOzmen01/veri-kontrol-backend/repository/notifications"
+	"github.com/YusufOzmen01/veri-kontrol-backend/repository/outbox"
+	log "github.com/sirupsen/logrus"
+)
+
+const DefaultNotifyInterval = time.Minute
+
+// Alert is the payload of TopicAlert messages.
+type Alert struct {
+	ChatID string `json:"chat_id"`
+	Text   string `json:"text"`
+}
+
+// SendAlert is the outbox handler of TopicAlert.
+func SendAlert(telegram sources.Telegram) OutboxHandler {
+	return func(ctx context.Context, payload []byte) error {
+		alert := &Alert{}
+		if err := json.Unmarshal(payload, alert); err != nil {
+			return err
+		}
+
+		return telegram.SendMessage(ctx, alert.ChatID, alert.Text)
+	}
+}
+
+// Notifier watches the raw locations for new entries and queues alerts to the chats of the matching rules in
+// the outbox. Entry IDs only grow, so everything above the highest ID seen so far is new.
+type Notifier struct {
+	cache  sources.Cache
+	rules  notifications.Repository
+	cities cities.Repository
+	outbox outbox.Repository
+
+	// Every source and the intake entries have their own ID range, each range is followed on its own.
+	started   bool
+	lastEntry map[int]int
+}
+
+func NewNotifier(cache sources.Cache, rules notifications.Repository, cities cities.Repository, outbox outbox.Repository) *Notifier {
+	return &Notifier{
+		cache:  cache,
+		rules:  rules,
+		cities: cities,
+		outbox: outbox,
+	}
+}
+
+// entryText is what the keywords are matched against. Most sources only send coordinates with the feed, their
+// text is the one looked up per entry, it is taken from the cache when the prefetch already got it.
+func entryText(ctx context.Context, cache sources.Cache, location *locations.Location) string {
+	if location.OriginalMessage != "" {
+		return location.OriginalMessage + " " + location.OriginalLocation
+	}
+
+	single, err := GetSingleLocation(ctx, location.EntryID, cache)
+	if err != nil {
+		log.Warnf("Couldn't look up the text of entry %d for alerts: %s", location.EntryID, err)
+
+		return location.OriginalLocation
+	}
+
+	return single.FullText + " " + single.FormattedAddress
+}
+
+func matches(rule *notifications.Rule, location *locations.Location, text string, cityByID map[int]*cities.City) bool {
+	if !rule.MatchesText(text) {
+		return false
+	}
+
+	if len(rule.CityIDs) == 0 {
+		return true
+	}
+
+	if len(location.Loc) != 2 {
+		return false
+	}
+
+	for _, cityID := range rule.CityIDs {
+		if city, ok := cityByID[cityID]; ok && city.Contains(location.Loc[0], location.Loc[1]) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func idRange(entryID int) int {
+	if entryID > locations.IntakeIDOffset {
+		return locations.IntakeIDOffset / SourceIDSpan
+	}
+
+	return entryID / SourceIDSpan
+}
+
+func alertText(rule *notifications.Rule, location *locations.Location, entryText string) string {
+	text := fmt.Sprintf("[%s] Yeni kayıt #%d\n%s", rule.Name, location.EntryID, strings.TrimSpace(entryText))
+
+	if len(location.Loc) == 2 {
+		text += fmt.Sprintf("\nhttps://www.google.com/maps/?q=%f,%f", location.Loc[0], location.Loc[1])
+	}
+
+	return text
+}
+
+// Check queues alerts about entries which arrived since the last call. When they can't be queued the same
+// entries are tried again on the next call. The first call only remembers where the
+// entries end, otherwise every restart would send the whole backlog.
+func (n *Notifier) Check(ctx context.Context) error {
+	locs, err := GetAllLocations(ctx, n.cache)
+	if err != nil {
+		return err
+	}
+
+	last := func(r int) int {
+		if id, ok := n.lastEntry[r]; ok {
+			return id
+		}
+
+		return r * SourceIDSpan
+	}
+
+	highest := make(map[int]int, len(n.lastEntry))
+	for r, id := range n.lastEntry {
+		highest[r] = id
+	}
+
+	for _, location := range locs {
+		if r := idRange(location.EntryID); location.EntryID > last(r) && location.EntryID > highest[r] {
+			highest[r] = location.EntryID
+		}
+	}
+
+	if !n.started {
+		n.started = true
+		n.lastEntry = highest
+
+		return nil
+	}
+
+	rules, err := n.rules.GetRules(ctx)
+	if err != nil {
+		return err
+	}
+
+	cityList, err := n.cities.GetCities(ctx)
+	if err != nil {
+		return err
+	}
+
+	cityByID := make(map[int]*cities.City, len(cityList))
+	for _, city := range cityList {
+		cityByID[city.ID] = city
+	}
+
+	// Texts are only looked up when a rule has keywords to match.
+	needsText := false
+	for _, rule := range rules {
+		if len(rule.Keywords) > 0 {
+			needsText = true
+		}
+	}
+
+	messages := make([]*outbox.Message, 0)
+	for _, location := range locs {
+		if location.EntryID <= last(idRange(location.EntryID)) {
+			continue
+		}
+
+		text := location.OriginalMessage + " " + location.OriginalLocation
+		if needsText {
+			text = entryText(ctx, n.cache, location)
+		}
+
+		for _, rule := range rules {
+			if !matches(rule, location, text, cityByID) {
+				continue
+			}
+
+			message, err := outbox.NewMessage(TopicAlert, &Alert{
+				ChatID: rule.ChatID,
+				Text:   alertText(rule, location, text),
+			})
+			if err != nil {
+				return err
+			}
+
+			messages = append(messages, message)
+		}
+	}
+
+	if err := n.outbox.Add(ctx, messages...); err != nil {
+		return err
+	}
+
+	n.lastEntry = highest
+
+	if len(messages) > 0 {
+		log.Infof("Queued %d alerts", len(messages))
+	}
+
+	return nil
+}
+
+func (n *Notifier) Run(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = DefaultNotifyInterval
+	}
+
+	for {
+		if err := n.Check(ctx); err != nil {
+			log.Errorf("Couldn't check for alerts: %s", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+		}
+	}
+}
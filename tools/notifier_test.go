@@ -0,0 +1,47 @@
+package tools
+
+import (
+	"context"
+	"testing"
+
+	"github.com/YusufOzmen01/veri-kontrol-backend/core/sources"
+	"github.com/YusufOzmen01/veri-kontrol-backend/repository/locations"
+	"github.com/YusufOzmen01/veri-kontrol-backend/repository/notifications"
+)
+
+func TestNotifierMatchesCachedText(t *testing.T) {
+	ctx := context.Background()
+
+	cache := sources.NewCache(1<<20, 1e4, 64)
+	defer cache.Close()
+
+	// The feed only has the coordinates, the text was looked up by the prefetch.
+	cache.Set(textCacheKey(7), &SingleResponse{FullText: "Enkaz altındayız, yardım edin", FormattedAddress: "Kurtuluş Mah. Antakya"}, 1)
+	cache.Wait()
+
+	location := &locations.Location{EntryID: 7, Loc: []float64{36.2, 36.16}}
+	text := entryText(ctx, cache, location)
+
+	tests := []struct {
+		keywords []string
+		want     bool
+	}{
+		{[]string{"enkaz"}, true},
+		{[]string{"ANTAKYA"}, true},
+		{[]string{"su", "gıda"}, false},
+		{nil, true},
+	}
+
+	for _, tt := range tests {
+		rule := &notifications.Rule{Name: "test", ChatID: "1", Keywords: tt.keywords}
+		if got := matches(rule, location, text, nil); got != tt.want {
+			t.Errorf("matches(%v) = %t, want %t", tt.keywords, got, tt.want)
+		}
+	}
+
+	// Entries which came with their text don't need a lookup.
+	withText := &locations.Location{EntryID: 8, OriginalMessage: "Gıda lazım", OriginalLocation: "Nurdağı"}
+	if got := entryText(ctx, cache, withText); got != "Gıda lazım Nurdağı" {
+		t.Errorf("entryText() = %q", got)
+	}
+}
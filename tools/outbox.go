@@ -0,0 +1,173 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/YusufOzmen01/veri-kontrol-backend/repository/locations"
+	"github.com/YusufOzmen01/veri-kontrol-backend/repository/outbox"
+	"github.com/samber/lo"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	TopicLocationResolved = "location.resolved"
+	TopicAlert            = "alert"
+
+	DefaultOutboxInterval = 5 * time.Second
+	MaxOutboxAttempts     = 10
+
+	outboxBatchSize  = 100
+	outboxLease      = 2 * time.Minute
+	outboxMinBackoff = 10 * time.Second
+	outboxMaxBackoff = time.Hour
+)
+
+// OutboxHandler gets the payload of a message, an error has the message tried again later.
+type OutboxHandler func(ctx context.Context, payload []byte) error
+
+type namedHandler struct {
+	name    string
+	handler OutboxHandler
+}
+
+// OutboxDispatcher hands the messages of the outbox to the handlers of their topic until each of them
+// succeeded. Replicas claim the messages they handle for outboxLease. A message can still be handled more than
+// once, when the process dies before marking it or the handlers outlast the lease, so handlers have to tolerate
+// repeats. Messages which keep failing, or have no handler, end up dead.
+type OutboxDispatcher struct {
+	outbox   outbox.Repository
+	handlers map[string][]namedHandler
+}
+
+func NewOutboxDispatcher(outbox outbox.Repository) *OutboxDispatcher {
+	return &OutboxDispatcher{
+		outbox:   outbox,
+		handlers: make(map[string][]namedHandler),
+	}
+}
+
+// QueueResolved adds a TopicLocationResolved message of the resolution, edits included. In a transaction it is
+// written together with the resolution.
+func QueueResolved(ctx context.Context, outboxMessages outbox.Repository, location *locations.LocationDB) error {
+	message, err := outbox.NewMessage(TopicLocationResolved, location)
+	if err != nil {
+		return err
+	}
+
+	return outboxMessages.Add(ctx, message)
+}
+
+// Handle registers a handler of a topic, it has to happen before Run. A topic can have several handlers, the
+// name tells them apart in the stored message and must not change between releases.
+func (d *OutboxDispatcher) Handle(topic, name string, handler OutboxHandler) {
+	d.handlers[topic] = append(d.handlers[topic], namedHandler{name: name, handler: handler})
+}
+
+// outboxBackoff is 10s, 20s, 40s and so on up to an hour after the given number of failed attempts.
+func outboxBackoff(attempts int) time.Duration {
+	backoff := outboxMinBackoff
+	for i := 1; i < attempts && backoff < outboxMaxBackoff; i++ {
+		backoff *= 2
+	}
+
+	if backoff > outboxMaxBackoff {
+		backoff = outboxMaxBackoff
+	}
+
+	return backoff
+}
+
+// handle runs the handlers which didn't handle the message yet and adds the ones which succeed to
+// message.Handled. The first error is returned, the other handlers still get their turn.
+func (d *OutboxDispatcher) handle(ctx context.Context, message *outbox.Message) error {
+	handlers, ok := d.handlers[message.Topic]
+	if !ok {
+		return fmt.Errorf("no handler for topic %s", message.Topic)
+	}
+
+	var failed error
+	for _, h := range handlers {
+		if lo.Contains(message.Handled, h.name) {
+			continue
+		}
+
+		if err := h.handler(ctx, []byte(message.Payload)); err != nil {
+			if failed == nil {
+				failed = fmt.Errorf("%s: %w", h.name, err)
+			}
+
+			continue
+		}
+
+		message.Handled = append(message.Handled, h.name)
+	}
+
+	return failed
+}
+
+func (d *OutboxDispatcher) attempt(ctx context.Context, message *outbox.Message) {
+	message.Attempts++
+
+	err := d.handle(ctx, message)
+
+	switch {
+	case err == nil:
+		now := time.Now()
+		expires := now.Add(outbox.DoneTTL)
+
+		message.Status = outbox.StatusDone
+		message.DoneAt = &now
+		message.ExpiresAt = &expires
+		message.LastError = ""
+	case message.Attempts >= MaxOutboxAttempts || len(d.handlers[message.Topic]) == 0:
+		message.Status = outbox.StatusDead
+		message.LastError = err.Error()
+
+		log.Errorf("Outbox message %s (%s) is dead after %d attempts: %s", message.ID.Hex(), message.Topic, message.Attempts, err)
+	default:
+		message.NextAttemptAt = time.Now().Add(outboxBackoff(message.Attempts))
+		message.LastError = err.Error()
+	}
+
+	if err := d.outbox.UpdateMessage(ctx, message); err != nil {
+		log.Errorf("Couldn't update outbox message %s: %s", message.ID.Hex(), err)
+	}
+}
+
+// Dispatch claims and handles up to outboxBatchSize messages which are due.
+func (d *OutboxDispatcher) Dispatch(ctx context.Context) error {
+	for i := 0; i < outboxBatchSize; i++ {
+		message, err := d.outbox.ClaimDue(ctx, time.Now(), outboxLease)
+		if err == outbox.ErrNothingDue {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		d.attempt(ctx, message)
+	}
+
+	return nil
+}
+
+func (d *OutboxDispatcher) Run(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = DefaultOutboxInterval
+	}
+
+	for {
+		if err := d.Dispatch(ctx); err != nil {
+			log.Errorf("Couldn't dispatch the outbox: %s", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/YusufOzmen01/veri-kontrol-backend/repository/outbox"
+)
+
+func TestOutboxHandlersRetryIndependently(t *testing.T) {
+	ctx := context.Background()
+	dispatcher := NewOutboxDispatcher(nil)
+
+	calls := map[string]int{}
+	failing := true
+
+	dispatcher.Handle(TopicLocationResolved, "webhooks", func(ctx context.Context, payload []byte) error {
+		calls["webhooks"]++
+
+		return nil
+	})
+	dispatcher.Handle(TopicLocationResolved, "sheets", func(ctx context.Context, payload []byte) error {
+		calls["sheets"]++
+		if failing {
+			return errors.New("quota exceeded")
+		}
+
+		return nil
+	})
+
+	message, err := outbox.NewMessage(TopicLocationResolved, struct{}{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := dispatcher.handle(ctx, message); err == nil {
+		t.Fatal("handle() succeeded with a failing handler")
+	}
+
+	failing = false
+	if err := dispatcher.handle(ctx, message); err != nil {
+		t.Fatal(err)
+	}
+
+	// The retry only ran the handler which failed.
+	if calls["webhooks"] != 1 || calls["sheets"] != 2 {
+		t.Fatalf("calls = %v", calls)
+	}
+
+	if len(message.Handled) != 2 {
+		t.Fatalf("handled = %v", message.Handled)
+	}
+
+	if err := dispatcher.handle(ctx, &outbox.Message{Topic: "unknown"}); err == nil {
+		t.Fatal("handle() succeeded without a handler")
+	}
+}
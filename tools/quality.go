@@ -0,0 +1,48 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"math/rand"
+
+	"github.com/YusufOzmen01/veri-kontrol-backend/repository/locations"
+	"github.com/YusufOzmen01/veri-kontrol-backend/repository/quality"
+)
+
+// QualitySampler routes a share of the new resolutions to the QA review queue. Anonymous resolutions aren't
+// sampled, there is no moderator to grade.
+type QualitySampler struct {
+	reviews quality.Repository
+	percent float64
+}
+
+func NewQualitySampler(reviews quality.Repository, percent float64) *QualitySampler {
+	return &QualitySampler{
+		reviews: reviews,
+		percent: percent,
+	}
+}
+
+func (s *QualitySampler) Sample(ctx context.Context, location *locations.LocationDB) error {
+	if location.Sender == nil || rand.Float64()*100 >= s.percent {
+		return nil
+	}
+
+	return s.reviews.AddReview(ctx, &quality.Review{
+		EntryID:   location.EntryID,
+		Moderator: location.Sender,
+		Location:  location,
+	})
+}
+
+// HandleResolved is the outbox handler of TopicLocationResolved, the payload is the resolution. The outbox
+// retries it when the review can't be stored, unlike the feed of Subscribe which drops resolutions when the
+// sampler falls behind.
+func (s *QualitySampler) HandleResolved(ctx context.Context, payload []byte) error {
+	location := &locations.LocationDB{}
+	if err := json.Unmarshal(payload, location); err != nil {
+		return err
+	}
+
+	return s.Sample(ctx, location)
+}
@@ -0,0 +1,82 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/YusufOzmen01/veri-kontrol-backend/repository/locations"
+	log "github.com/sirupsen/logrus"
+)
+
+const DefaultReapInterval = time.Minute
+
+// Reaper puts entries back into circulation which somebody took and never finished: claims which ran out and
+// pending reviews nobody approved within pendingMaxAge. A zero pendingMaxAge keeps pending reviews forever.
+type Reaper struct {
+	locations     locations.Repository
+	pendingMaxAge time.Duration
+}
+
+func NewReaper(locations locations.Repository, pendingMaxAge time.Duration) *Reaper {
+	return &Reaper{
+		locations:     locations,
+		pendingMaxAge: pendingMaxAge,
+	}
+}
+
+func (r *Reaper) Reap(ctx context.Context) error {
+	now := time.Now()
+
+	claims, err := r.locations.ExpireClaims(ctx, now)
+	if err != nil {
+		return err
+	}
+
+	for _, claim := range claims {
+		log.Infof("Claim of %s on entry %d expired at %s", claim.Owner, claim.EntryID, claim.ExpiresAt.Format(time.RFC3339))
+	}
+
+	if r.pendingMaxAge <= 0 {
+		return nil
+	}
+
+	stale, err := r.locations.GetStalePending(ctx, now.Add(-r.pendingMaxAge))
+	if err != nil {
+		return err
+	}
+
+	for _, location := range stale {
+		if err := r.locations.ExpirePending(ctx, location.EntryID); err != nil {
+			if errors.Is(err, locations.ErrNotPending) || errors.Is(err, locations.ErrLocationNotFound) {
+				continue
+			}
+
+			log.Errorf("Couldn't expire the pending review of entry %d: %s", location.EntryID, err)
+
+			continue
+		}
+
+		log.Infof("Pending review of entry %d wasn't approved within %s, it is served again", location.EntryID, r.pendingMaxAge)
+	}
+
+	return nil
+}
+
+func (r *Reaper) Run(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = DefaultReapInterval
+	}
+
+	for {
+		if err := r.Reap(ctx); err != nil {
+			log.Errorf("Couldn't reap claims and pending reviews: %s", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+		}
+	}
+}
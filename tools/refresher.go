@@ -0,0 +1,69 @@
+package tools
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/YusufOzmen01/veri-kontrol-backend/core/sources"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	DefaultRefreshInterval = time.Minute
+	minRefreshBackoff      = 5 * time.Second
+	maxRefreshBackoff      = 5 * time.Minute
+)
+
+// RunLocationRefresher keeps the locations cache warm until ctx is cancelled, so request handlers never wait
+// for upstream. Refreshes are spread with up to 10% jitter and failures back off exponentially. The texts of new
+// entries are looked up in the background after every refresh, a prefetch which is still running when the next
+// refresh is done is left to finish and the entries it didn't get to wait for the one after.
+func RunLocationRefresher(ctx context.Context, cache sources.Cache, interval time.Duration) {
+	if interval <= 0 {
+		interval = DefaultRefreshInterval
+	}
+
+	backoff := minRefreshBackoff
+
+	prefetching := make(chan struct{}, 1)
+	prefetches := &sync.WaitGroup{}
+	defer prefetches.Wait()
+
+	for {
+		wait := interval + time.Duration(rand.Int63n(int64(interval/10)+1))
+
+		locs, err := RefreshLocations(ctx, cache)
+		if err != nil {
+			log.Errorf("Couldn't refresh locations: %s", err)
+
+			wait = backoff
+
+			backoff *= 2
+			if backoff > maxRefreshBackoff {
+				backoff = maxRefreshBackoff
+			}
+		} else {
+			backoff = minRefreshBackoff
+
+			select {
+			case prefetching <- struct{}{}:
+				prefetches.Add(1)
+				go func() {
+					defer prefetches.Done()
+					defer func() { <-prefetching }()
+
+					PrefetchTexts(ctx, cache, locs)
+				}()
+			default:
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+	}
+}
@@ -0,0 +1,317 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"time"
+
+	"github.com/YusufOzmen01/veri-kontrol-backend/repository/cities"
+	"github.com/YusufOzmen01/veri-kontrol-backend/repository/locations"
+	"github.com/YusufOzmen01/veri-kontrol-backend/repository/reasons"
+	"github.com/YusufOzmen01/veri-kontrol-backend/repository/types"
+	"github.com/YusufOzmen01/veri-kontrol-backend/repository/users"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ErrNoFileSource is returned by Seed when upstream_sources has no file source to write the entries to.
+var ErrNoFileSource = errors.New(`no file source is configured, set upstream_sources to e.g. "seed:file:data/seed.json"`)
+
+// MaxSeed caps every count of SeedOptions.
+const MaxSeed = 10_000
+
+// SeedOptions are how many of each kind of fake data Seed generates. Resolutions are made of the new
+// entries, so there are at most as many as Locations.
+type SeedOptions struct {
+	Locations   int   `json:"locations"`
+	Users       int   `json:"users"`
+	Resolutions int   `json:"resolutions"`
+	RandomSeed  int64 `json:"random_seed"` // The same seed generates the same data, 0 picks one
+}
+
+// SeededUser is only returned by Seed, the Auth-Key isn't shown again.
+type SeededUser struct {
+	ID        primitive.ObjectID `json:"_id"`
+	Name      string             `json:"name"`
+	PermLevel int                `json:"perm_level"`
+	AuthKey   string             `json:"auth_key"`
+}
+
+type SeedResult struct {
+	FeedPath    string        `json:"feed_path"`
+	Locations   int           `json:"locations"`
+	Users       []*SeededUser `json:"users"`
+	Resolutions int           `json:"resolutions"`
+}
+
+// Seeder fills a development database with fake but plausible data: entries in the configured cities written
+// to the file source, volunteers, and resolutions of some of the entries by them.
+type Seeder struct {
+	Locations locations.Repository
+	Users     users.Repository
+	Cities    cities.Repository
+	Reasons   reasons.Repository
+	Types     types.Repository
+}
+
+var (
+	seedFirstNames    = []string{"Ayşe", "Mehmet", "Fatma", "Mustafa", "Zeynep", "Ahmet", "Elif", "Emre", "Hatice", "Yusuf", "Merve", "Hüseyin", "Büşra", "Ali", "Derya", "Can"}
+	seedLastNames     = []string{"Yılmaz", "Kaya", "Demir", "Şahin", "Çelik", "Yıldız", "Aydın", "Öztürk", "Arslan", "Doğan", "Kılıç", "Aslan", "Korkmaz", "Özdemir"}
+	seedNeighborhoods = []string{"Cumhuriyet", "Atatürk", "Fatih", "Yeni", "İstiklal", "Hürriyet", "Barbaros", "Mimar Sinan", "Gazi", "Zafer", "Kurtuluş", "Yavuz Selim"}
+	seedStreets       = []string{"Çınar", "Gül", "Lale", "Menekşe", "Okul", "Cami", "Pazar", "Değirmen", "Bahçe", "Karanfil", "Akasya", "Papatya"}
+	seedNeeds         = []string{
+		"enkaz altında %d kişi var, ses geliyor",
+		"enkaz altında %d kişi var, haber alınamıyor",
+		"%d kişilik aile için çadır ve battaniye lazım",
+		"%d kişiye su ve gıda gerekiyor, bebek maması da lazım",
+		"bina yıkıldı, %d kişi ulaşılamıyor",
+		"%d yaralı var, ambulans gerekiyor",
+	}
+)
+
+func seedPick(rng *rand.Rand, list []string) string {
+	return list[rng.Intn(len(list))]
+}
+
+func seedName(rng *rand.Rand) string {
+	return seedPick(rng, seedFirstNames) + " " + seedPick(rng, seedLastNames)
+}
+
+// seedPhone makes up a mobile number, the 0555 000 prefix keeps it clear of real subscribers.
+func seedPhone(rng *rand.Rand) string {
+	return fmt.Sprintf("0555 000 %02d %02d", rng.Intn(100), rng.Intn(100))
+}
+
+func seedAddress(rng *rand.Rand, city *cities.City) string {
+	return fmt.Sprintf("%s Mahallesi %s Sokak No:%d, %s", seedPick(rng, seedNeighborhoods), seedPick(rng, seedStreets), rng.Intn(80)+1, city.Name)
+}
+
+// seedPoint picks a point inside the city, its polygon is sampled within the bounding box.
+func seedPoint(rng *rand.Rand, city *cities.City) ([]float64, bool) {
+	if len(city.Polygon) == 0 {
+		return nil, false
+	}
+
+	minLat, maxLat := city.Polygon[0][0], city.Polygon[0][0]
+	minLng, maxLng := city.Polygon[0][1], city.Polygon[0][1]
+
+	for _, vertex := range city.Polygon[1:] {
+		minLat, maxLat = math.Min(minLat, vertex[0]), math.Max(maxLat, vertex[0])
+		minLng, maxLng = math.Min(minLng, vertex[1]), math.Max(maxLng, vertex[1])
+	}
+
+	for i := 0; i < 100; i++ {
+		lat := minLat + rng.Float64()*(maxLat-minLat)
+		lng := minLng + rng.Float64()*(maxLng-minLng)
+
+		if city.Contains(lat, lng) {
+			return []float64{lat, lng}, true
+		}
+	}
+
+	return nil, false
+}
+
+func (s *Seeder) generateLocations(rng *rand.Rand, cityList []*cities.City, firstID, count int) []*locations.Location {
+	locs := make([]*locations.Location, 0, count)
+	now := time.Now()
+
+	// Cities without an area are skipped, the attempts are capped in case none has one.
+	for attempts := 0; len(locs) < count && attempts < count*10; attempts++ {
+		city := cityList[rng.Intn(len(cityList))]
+
+		point, ok := seedPoint(rng, city)
+		if !ok {
+			continue
+		}
+
+		address := seedAddress(rng, city)
+		message := fmt.Sprintf("%s %s. %s %s", address, fmt.Sprintf(seedPick(rng, seedNeeds), rng.Intn(6)+1), seedName(rng), seedPhone(rng))
+
+		locs = append(locs, &locations.Location{
+			EntryID:          firstID + len(locs),
+			Loc:              point,
+			Epoch:            int(now.Add(-time.Duration(rng.Int63n(int64(72 * time.Hour)))).Unix()),
+			OriginalMessage:  message,
+			OriginalLocation: address,
+		})
+	}
+
+	return locs
+}
+
+func (s *Seeder) seedUsers(ctx context.Context, rng *rand.Rand, count int) ([]*SeededUser, error) {
+	seeded := make([]*SeededUser, 0, count)
+
+	for i := 0; i < count; i++ {
+		// The first one is an admin to log in with, a few moderators and the rest are volunteers.
+		permLevel := users.PermSubmit
+		switch {
+		case i == 0:
+			permLevel = users.PermAdmin
+		case i%5 == 1:
+			permLevel = users.PermModerator
+		}
+
+		user, authKey, err := s.Users.AddUser(ctx, seedName(rng), "", permLevel, nil)
+		if err != nil {
+			return seeded, err
+		}
+
+		seeded = append(seeded, &SeededUser{ID: user.ID, Name: user.Name, PermLevel: permLevel, AuthKey: authKey})
+	}
+
+	return seeded, nil
+}
+
+// resolve stores a resolution of loc the way /resolve would, by one of the senders, some time after the entry
+// was reported.
+func (s *Seeder) resolve(ctx context.Context, rng *rand.Rand, loc *locations.Location, source string, offset int, senders []*users.User, reasonList []*reasons.Reason, typeList []*types.LocationType) error {
+	reason := reasonList[rng.Intn(len(reasonList))]
+	locationType := typeList[rng.Intn(len(typeList))]
+
+	resolvedAt := time.Unix(int64(loc.Epoch), 0).Add(time.Duration(rng.Int63n(int64(6 * time.Hour))))
+	if resolvedAt.After(time.Now()) {
+		resolvedAt = time.Now()
+	}
+
+	entry := &locations.LocationDB{
+		ID:              primitive.NewObjectIDFromTimestamp(resolvedAt),
+		EntryID:         loc.EntryID + offset,
+		Type:            locationType.ID,
+		TypeCode:        locationType.Code,
+		Location:        loc.Loc,
+		Corrected:       reason.Corrected,
+		OriginalAddress: loc.OriginalLocation,
+		Reason:          reason.Code,
+		Sender:          senders[rng.Intn(len(senders))],
+		TweetContents:   loc.OriginalMessage,
+		Epoch:           loc.Epoch,
+		Status:          locations.StatusResolved,
+		Source:          source,
+		EventID:         ActiveEventID(ctx),
+	}
+
+	if reason.Corrected {
+		entry.CorrectedAddress = loc.OriginalLocation + " (düzeltildi)"
+	}
+
+	return s.Locations.ResolveLocation(ctx, entry)
+}
+
+// Seed appends the new entries to the file source and stores the users and resolutions in Mongo. The app
+// serves the entries from its next feed refresh on.
+func (s *Seeder) Seed(ctx context.Context, opts *SeedOptions) (*SeedResult, error) {
+	if opts.Locations < 0 || opts.Users < 0 || opts.Resolutions < 0 || opts.Locations > MaxSeed || opts.Users > MaxSeed || opts.Resolutions > MaxSeed {
+		return nil, fmt.Errorf("the counts have to be between 0 and %d", MaxSeed)
+	}
+
+	source, offset, ok := fileSourceOf()
+	if !ok {
+		return nil, ErrNoFileSource
+	}
+
+	randomSeed := opts.RandomSeed
+	if randomSeed == 0 {
+		randomSeed = time.Now().UnixNano()
+	}
+	rng := rand.New(rand.NewSource(randomSeed))
+
+	cityList, err := s.Cities.GetCities(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(cityList) == 0 {
+		return nil, fmt.Errorf("there are no cities to put the entries in")
+	}
+
+	existing, err := source.read()
+	if err != nil {
+		return nil, fmt.Errorf("couldn't read %s: %w", source.path, err)
+	}
+
+	firstID := 1
+	for _, loc := range existing {
+		if loc.EntryID >= firstID {
+			firstID = loc.EntryID + 1
+		}
+	}
+
+	locs := s.generateLocations(rng, cityList, firstID, opts.Locations)
+	if err := source.write(append(existing, locs...)); err != nil {
+		return nil, fmt.Errorf("couldn't write %s: %w", source.path, err)
+	}
+
+	result := &SeedResult{FeedPath: source.path, Locations: len(locs)}
+
+	if result.Users, err = s.seedUsers(ctx, rng, opts.Users); err != nil {
+		return result, err
+	}
+
+	resolutions := opts.Resolutions
+	if resolutions > len(locs) {
+		resolutions = len(locs)
+	}
+
+	if resolutions == 0 {
+		return result, nil
+	}
+
+	senders := make([]*users.User, 0)
+	for _, seeded := range result.Users {
+		user, err := s.Users.GetUserByID(ctx, seeded.ID)
+		if err != nil {
+			return result, err
+		}
+
+		senders = append(senders, user)
+	}
+
+	if len(senders) == 0 {
+		if senders, err = s.Users.GetUsers(ctx); err != nil {
+			return result, err
+		}
+	}
+
+	if len(senders) == 0 {
+		return result, fmt.Errorf("resolutions need users, seed some along with them")
+	}
+
+	reasonList, err := s.Reasons.GetReasons(ctx)
+	if err != nil {
+		return result, err
+	}
+
+	allTypes, err := s.Types.GetTypes(ctx)
+	if err != nil {
+		return result, err
+	}
+
+	typeList := make([]*types.LocationType, 0, len(allTypes))
+	for _, locationType := range allTypes {
+		if locationType.Selectable {
+			typeList = append(typeList, locationType)
+		}
+	}
+
+	if len(reasonList) == 0 || len(typeList) == 0 {
+		return result, fmt.Errorf("resolutions need reasons and types, start the app once to create the defaults")
+	}
+
+	for _, i := range rng.Perm(len(locs))[:resolutions] {
+		if err := s.resolve(ctx, rng, locs[i], source.name, offset, senders, reasonList, typeList); err != nil {
+			if errors.Is(err, locations.ErrAlreadyResolved) {
+				continue
+			}
+
+			return result, err
+		}
+
+		result.Resolutions++
+	}
+
+	return result, nil
+}
@@ -0,0 +1,260 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/YusufOzmen01/veri-kontrol-backend/core/sources"
+	"github.com/YusufOzmen01/veri-kontrol-backend/repository/locations"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	DefaultSheetSyncInterval = time.Minute
+
+	// maxPendingRows bounds what is kept while the sheet can't be reached, the oldest rows are dropped first.
+	maxPendingRows = 10_000
+)
+
+// SheetHeader is the first row the sheet is expected to have. Teams may add columns of their own, only
+// entry_id and verified_in_field are read back, by their header. Newer columns go to the end so rows still
+// line up in sheets created before them.
+var SheetHeader = []string{"entry_id", "type", "reason", "corrected_address", "open_address", "apartment", "lat", "lng", "sender", "resolved_at", "verified_in_field", "corrected_lat", "corrected_lng"}
+
+const (
+	sheetEntryIDColumn  = "entry_id"
+	sheetVerifiedColumn = "verified_in_field"
+)
+
+// SheetSync appends every new resolution to the coordination sheet and copies the verified_in_field column
+// back. Resolutions come from the outbox, rows which couldn't be appended yet are kept in memory and are lost
+// on a restart.
+type SheetSync struct {
+	locations locations.Repository
+	sheet     sources.Sheet
+
+	mu      sync.Mutex
+	pending [][]string
+
+	// hasHeader is set once the sheet is known to start with a header row.
+	hasHeader bool
+
+	// verified is what the sheet said on the last pull, only changes are written.
+	verified map[int]bool
+}
+
+func NewSheetSync(locations locations.Repository, sheet sources.Sheet) *SheetSync {
+	return &SheetSync{
+		locations: locations,
+		sheet:     sheet,
+		verified:  make(map[int]bool),
+	}
+}
+
+func sheetRow(location *locations.LocationDB) []string {
+	lat, lng := "", ""
+	if len(location.Location) == 2 {
+		lat = strconv.FormatFloat(location.Location[0], 'f', -1, 64)
+		lng = strconv.FormatFloat(location.Location[1], 'f', -1, 64)
+	}
+
+	correctedLat, correctedLng := "", ""
+	if location.CorrectedLat != nil && location.CorrectedLng != nil {
+		correctedLat = strconv.FormatFloat(*location.CorrectedLat, 'f', -1, 64)
+		correctedLng = strconv.FormatFloat(*location.CorrectedLng, 'f', -1, 64)
+	}
+
+	sender := ""
+	if location.Sender != nil {
+		sender = location.Sender.Name
+	}
+
+	return []string{
+		strconv.Itoa(location.EntryID),
+		strconv.Itoa(location.Type),
+		location.Reason,
+		location.CorrectedAddress,
+		location.OpenAddress,
+		location.Apartment,
+		lat,
+		lng,
+		sender,
+		location.ID.Timestamp().UTC().Format(time.RFC3339),
+		"",
+		correctedLat,
+		correctedLng,
+	}
+}
+
+// Add queues a resolution for the next append. Pending reviews and spam are left out, the same as webhooks.
+// The sheet only gets a row per entry, edits of a resolution aren't appended again.
+func (s *SheetSync) Add(location *locations.LocationDB) {
+	if location.IsPendingReview() || location.Type == locations.TypeSpam || location.Revision > 0 {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.pending = append(s.pending, sheetRow(location))
+	if len(s.pending) > maxPendingRows {
+		s.pending = s.pending[len(s.pending)-maxPendingRows:]
+	}
+}
+
+// HandleResolved is the outbox handler of TopicLocationResolved, the payload is the resolution.
+func (s *SheetSync) HandleResolved(ctx context.Context, payload []byte) error {
+	location := &locations.LocationDB{}
+	if err := json.Unmarshal(payload, location); err != nil {
+		return err
+	}
+
+	s.Add(location)
+
+	return nil
+}
+
+// ensureHeader writes SheetHeader to a sheet which is still empty, Pull finds the columns by it. A sheet which
+// has rows is left alone, its header may have columns of the team's own.
+func (s *SheetSync) ensureHeader(ctx context.Context) error {
+	if s.hasHeader {
+		return nil
+	}
+
+	rows, err := s.sheet.GetRows(ctx)
+	if err != nil {
+		return err
+	}
+
+	if len(rows) == 0 {
+		if err := s.sheet.AppendRows(ctx, [][]string{SheetHeader}); err != nil {
+			return err
+		}
+	}
+
+	s.hasHeader = true
+
+	return nil
+}
+
+// Push appends the queued rows in one request, they stay queued when it fails.
+func (s *SheetSync) Push(ctx context.Context) error {
+	s.mu.Lock()
+	rows := s.pending
+	s.pending = nil
+	s.mu.Unlock()
+
+	if len(rows) == 0 {
+		return nil
+	}
+
+	err := s.ensureHeader(ctx)
+	if err == nil {
+		err = s.sheet.AppendRows(ctx, rows)
+	}
+
+	if err != nil {
+		s.mu.Lock()
+		s.pending = append(rows, s.pending...)
+		s.mu.Unlock()
+
+		return err
+	}
+
+	return nil
+}
+
+func parseVerified(value string) bool {
+	switch strings.ToLowerSpecial(unicode.TurkishCase, strings.TrimSpace(value)) {
+	case "true", "1", "x", "yes", "evet", "doğrulandı", "✓", "✔":
+		return true
+	}
+
+	return false
+}
+
+// Pull reads the verified_in_field column and stores the values which changed since the last pull.
+func (s *SheetSync) Pull(ctx context.Context) error {
+	rows, err := s.sheet.GetRows(ctx)
+	if err != nil || len(rows) == 0 {
+		return err
+	}
+
+	entryColumn, verifiedColumn := -1, -1
+	for i, name := range rows[0] {
+		switch strings.TrimSpace(name) {
+		case sheetEntryIDColumn:
+			entryColumn = i
+		case sheetVerifiedColumn:
+			verifiedColumn = i
+		}
+	}
+
+	if entryColumn < 0 || verifiedColumn < 0 {
+		log.Warnf("Coordination sheet has no %s or %s header, nothing to pull", sheetEntryIDColumn, sheetVerifiedColumn)
+
+		return nil
+	}
+
+	updated := 0
+	for _, row := range rows[1:] {
+		if entryColumn >= len(row) {
+			continue
+		}
+
+		entryID, err := strconv.Atoi(strings.TrimSpace(row[entryColumn]))
+		if err != nil {
+			continue
+		}
+
+		verified := verifiedColumn < len(row) && parseVerified(row[verifiedColumn])
+		if previous, ok := s.verified[entryID]; ok && previous == verified {
+			continue
+		}
+
+		if err := s.locations.SetFieldVerified(ctx, entryID, verified); err != nil {
+			log.Errorf("Couldn't store field verification of entry %d: %s", entryID, err)
+
+			continue
+		}
+
+		s.verified[entryID] = verified
+		updated++
+	}
+
+	if updated > 0 {
+		log.Infof("Pulled %d field verifications from the coordination sheet", updated)
+	}
+
+	return nil
+}
+
+// Run syncs both ways every interval until ctx is cancelled, HandleResolved queues the rows in between.
+func (s *SheetSync) Run(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = DefaultSheetSyncInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.Push(ctx); err != nil {
+				log.Errorf("Couldn't append resolutions to the coordination sheet: %s", err)
+			}
+
+			if err := s.Pull(ctx); err != nil {
+				log.Errorf("Couldn't pull field verifications from the coordination sheet: %s", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/YusufOzmen01/veri-kontrol-backend/repository/locations"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type fakeSheet struct {
+	rows [][]string
+	err  error
+}
+
+func (s *fakeSheet) AppendRows(ctx context.Context, rows [][]string) error {
+	if s.err != nil {
+		return s.err
+	}
+
+	s.rows = append(s.rows, rows...)
+
+	return nil
+}
+
+func (s *fakeSheet) GetRows(ctx context.Context) ([][]string, error) {
+	return s.rows, s.err
+}
+
+func resolvedPayload(t *testing.T, location *locations.LocationDB) []byte {
+	payload, err := json.Marshal(location)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return payload
+}
+
+func TestSheetSyncPush(t *testing.T) {
+	ctx := context.Background()
+	sheet := &fakeSheet{err: errors.New("quota exceeded")}
+	sync := NewSheetSync(locations.NewMemoryRepository(), sheet)
+
+	for _, location := range []*locations.LocationDB{
+		{ID: primitive.NewObjectID(), EntryID: 1, Type: locations.TypeWreckage},
+		{ID: primitive.NewObjectID(), EntryID: 2, Type: locations.TypeSpam},
+		{ID: primitive.NewObjectID(), EntryID: 3, Type: locations.TypeWreckage, Revision: 1},
+		{ID: primitive.NewObjectID(), EntryID: 4, Type: locations.TypeShelter},
+	} {
+		if err := sync.HandleResolved(ctx, resolvedPayload(t, location)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The rows stay queued while the sheet can't be reached.
+	if err := sync.Push(ctx); err == nil {
+		t.Fatal("Push() succeeded with a failing sheet")
+	}
+
+	sheet.err = nil
+	if err := sync.Push(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(sheet.rows) != 3 {
+		t.Fatalf("sheet has %d rows, want the header and 2 resolutions", len(sheet.rows))
+	}
+
+	if sheet.rows[0][0] != SheetHeader[0] || sheet.rows[1][0] != "1" || sheet.rows[2][0] != "4" {
+		t.Fatalf("sheet rows = %v", sheet.rows)
+	}
+
+	// The header is only written once.
+	_ = sync.HandleResolved(ctx, resolvedPayload(t, &locations.LocationDB{ID: primitive.NewObjectID(), EntryID: 5}))
+	if err := sync.Push(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(sheet.rows) != 4 {
+		t.Fatalf("sheet has %d rows, want 4", len(sheet.rows))
+	}
+}
+
+func TestSheetSyncKeepsExistingHeader(t *testing.T) {
+	ctx := context.Background()
+	sheet := &fakeSheet{rows: [][]string{{"entry_id", "notes", "verified_in_field"}}}
+	sync := NewSheetSync(locations.NewMemoryRepository(), sheet)
+
+	sync.Add(&locations.LocationDB{ID: primitive.NewObjectID(), EntryID: 1})
+	if err := sync.Push(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(sheet.rows) != 2 || sheet.rows[0][1] != "notes" {
+		t.Fatalf("sheet rows = %v", sheet.rows)
+	}
+}
+
+func TestSheetSyncPull(t *testing.T) {
+	ctx := context.Background()
+
+	repository := locations.NewMemoryRepository()
+	for _, entryID := range []int{1, 2} {
+		if err := repository.ResolveLocation(ctx, &locations.LocationDB{ID: primitive.NewObjectID(), EntryID: entryID, Location: []float64{36.2, 36.16}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	sheet := &fakeSheet{rows: [][]string{
+		{"verified_in_field", "entry_id"},
+		{"Evet", "1"},
+		{"", "2"},
+		{"x", "not a number"},
+	}}
+
+	if err := NewSheetSync(repository, sheet).Pull(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	for entryID, want := range map[int]bool{1: true, 2: false} {
+		location, err := repository.GetLocation(ctx, entryID)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if location.FieldVerified != want {
+			t.Errorf("entry %d field_verified = %t, want %t", entryID, location.FieldVerified, want)
+		}
+	}
+}
+
+func TestParseVerified(t *testing.T) {
+	for value, want := range map[string]bool{
+		"TRUE":       true,
+		" evet ":     true,
+		"DOĞRULANDI": true,
+		"✓":          true,
+		"":           false,
+		"hayır":      false,
+		"0":          false,
+	} {
+		if got := parseVerified(value); got != want {
+			t.Errorf("parseVerified(%q) = %t, want %t", value, got, want)
+		}
+	}
+}
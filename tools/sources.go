@@ -0,0 +1,446 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/YusufOzmen01/veri-kontrol-backend/core/breaker"
+	"github.com/YusufOzmen01/veri-kontrol-backend/core/network"
+	"github.com/YusufOzmen01/veri-kontrol-backend/repository/locations"
+	log "github.com/sirupsen/logrus"
+)
+
+// Source is an upstream feed of raw entries. Entry IDs only have to be unique within the source, they are
+// moved into the source's own range when merged.
+type Source interface {
+	Name() string
+	Fetch(ctx context.Context) ([]*locations.Location, error)
+	FetchOne(ctx context.Context, entryID int) (*SingleResponse, error)
+}
+
+// sourceKinds are the feed formats a source can be configured with.
+var sourceKinds = map[string]func(name, baseURL string) Source{
+	"afetharita": NewAfetHaritaSource,
+	"json":       NewJSONSource,
+	"file":       NewFileSource,
+}
+
+// SourceIDSpan is the ID range every source gets. The first source keeps its IDs as they are, so the entries
+// resolved before there were several sources still match.
+const SourceIDSpan = 100_000_000
+
+const (
+	upstreamAttempts  = 3
+	upstreamRetryBase = 500 * time.Millisecond
+	breakerThreshold  = 5
+	breakerCooldown   = 30 * time.Second
+)
+
+var registry struct {
+	mu       sync.RWMutex
+	sources  []Source
+	breakers map[string]*breaker.Breaker
+	lastGood map[string][]*locations.Location
+}
+
+func breakerFor(name string) *breaker.Breaker {
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+
+	if registry.breakers == nil {
+		registry.breakers = make(map[string]*breaker.Breaker)
+	}
+
+	b, ok := registry.breakers[name]
+	if !ok {
+		b = breaker.New(breakerThreshold, breakerCooldown)
+		registry.breakers[name] = b
+	}
+
+	return b
+}
+
+// callSource retries fn with jitter behind the source's breaker. Once a source keeps failing it is left alone
+// for a while instead of every request waiting on it.
+func callSource(ctx context.Context, source Source, fn func() error) error {
+	return breakerFor(source.Name()).Do(func() error {
+		return network.Retry(ctx, upstreamAttempts, upstreamRetryBase, fn)
+	})
+}
+
+// lastGoodCopy and setLastGoodCopy hand out and keep copies, the enrichment changes the entries it is given.
+func lastGoodCopy(name string) ([]*locations.Location, bool) {
+	registry.mu.RLock()
+	defer registry.mu.RUnlock()
+
+	locs, ok := registry.lastGood[name]
+	if !ok {
+		return nil, false
+	}
+
+	return copyLocations(locs), true
+}
+
+func setLastGoodCopy(name string, locs []*locations.Location) {
+	locs = copyLocations(locs)
+
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+
+	if registry.lastGood == nil {
+		registry.lastGood = make(map[string][]*locations.Location)
+	}
+
+	registry.lastGood[name] = locs
+}
+
+// copyLocations copies the entries along with their slices and address, nothing is shared with locs.
+func copyLocations(locs []*locations.Location) []*locations.Location {
+	copied := make([]*locations.Location, 0, len(locs))
+	for _, loc := range locs {
+		c := *loc
+		c.Loc = append([]float64(nil), loc.Loc...)
+		c.PhoneNumbers = append([]string(nil), loc.PhoneNumbers...)
+		c.SuggestedLoc = append([]float64(nil), loc.SuggestedLoc...)
+		if loc.Address != nil {
+			address := *loc.Address
+			c.Address = &address
+		}
+
+		copied = append(copied, &c)
+	}
+
+	return copied
+}
+
+// ParseSources reads "name:kind:url" entries separated by commas, for example
+// "afetharita:afetharita:https://apigo.afetharita.com,ahbap:json:https://example.org/feed". A file source has a
+// local path instead of the URL.
+func ParseSources(spec string) ([]Source, error) {
+	list := make([]Source, 0)
+	names := make(map[string]bool)
+
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		parts := strings.SplitN(entry, ":", 3)
+		if len(parts) != 3 {
+			return nil, fmt.Errorf("source %q must be name:kind:url", entry)
+		}
+
+		name, kind, baseURL := parts[0], parts[1], parts[2]
+
+		newSource, ok := sourceKinds[kind]
+		if !ok {
+			return nil, fmt.Errorf("source %s has unknown kind %q", name, kind)
+		}
+
+		if names[name] {
+			return nil, fmt.Errorf("source %s is configured twice", name)
+		}
+		names[name] = true
+
+		if kind == "file" {
+			if baseURL == "" {
+				return nil, fmt.Errorf("source %s must have a path", name)
+			}
+		} else if u, err := url.Parse(baseURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, fmt.Errorf("source %s must have an http or https URL", name)
+		}
+
+		list = append(list, newSource(name, baseURL))
+	}
+
+	// Intake entries start at IntakeIDOffset, the sources have to fit below it.
+	if len(list)*SourceIDSpan > locations.IntakeIDOffset {
+		return nil, fmt.Errorf("at most %d sources are supported", locations.IntakeIDOffset/SourceIDSpan)
+	}
+
+	return list, nil
+}
+
+// SetSources replaces the configured sources, without any the afetharita feed at UpstreamURL is used.
+func SetSources(list []Source) {
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+
+	registry.sources = list
+}
+
+func configuredSources() []Source {
+	registry.mu.RLock()
+	defer registry.mu.RUnlock()
+
+	if len(registry.sources) == 0 {
+		return []Source{NewAfetHaritaSource(locations.SourceAfetHarita, UpstreamURL)}
+	}
+
+	return registry.sources
+}
+
+func sourceOf(entryID int) (Source, int, bool) {
+	list := configuredSources()
+
+	i := entryID / SourceIDSpan
+	if entryID <= 0 || i >= len(list) {
+		return nil, 0, false
+	}
+
+	return list[i], i * SourceIDSpan, true
+}
+
+// dedupKey is empty for entries without text, they can't be told apart by their coordinates alone.
+func dedupKey(location *locations.Location) string {
+	text := strings.Join(strings.FieldsFunc(strings.ToLowerSpecial(unicode.TurkishCase, location.OriginalMessage), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	}), " ")
+
+	if text == "" || len(location.Loc) != 2 {
+		return ""
+	}
+
+	// Four decimals are about 10 metres, different geocoders rarely agree more precisely than that.
+	return fmt.Sprintf("%.4f,%.4f|%s", math.Round(location.Loc[0]*1e4)/1e4, math.Round(location.Loc[1]*1e4)/1e4, text)
+}
+
+func fetchSource(ctx context.Context, i int, source Source) ([]*locations.Location, error) {
+	var locs []*locations.Location
+
+	if err := callSource(ctx, source, func() (err error) {
+		locs, err = source.Fetch(ctx)
+
+		return err
+	}); err != nil {
+		return nil, err
+	}
+
+	moved := make([]*locations.Location, 0, len(locs))
+	for _, loc := range locs {
+		if loc.EntryID <= 0 || loc.EntryID >= SourceIDSpan {
+			continue
+		}
+
+		loc.EntryID += i * SourceIDSpan
+		loc.Source = source.Name()
+
+		moved = append(moved, loc)
+	}
+
+	setLastGoodCopy(source.Name(), moved)
+
+	return moved, nil
+}
+
+// fetchAllSources merges the sources in their configured order. An entry which an earlier source already
+// has, by coordinates and text, is dropped, so the same report isn't checked twice. A source which can't be
+// fetched contributes its last good copy, stale is why, and err is only set when there is nothing to serve.
+func fetchAllSources(ctx context.Context) (merged []*locations.Location, stale error, err error) {
+	merged = make([]*locations.Location, 0)
+	seen := make(map[string]string)
+
+	succeeded := 0
+
+	for i, source := range configuredSources() {
+		locs, fetchErr := fetchSource(ctx, i, source)
+		if fetchErr != nil {
+			log.Errorf("Couldn't fetch source %s: %s", source.Name(), fetchErr)
+
+			var ok bool
+			if locs, ok = lastGoodCopy(source.Name()); !ok {
+				err = fetchErr
+
+				continue
+			}
+
+			stale = fmt.Errorf("serving the last copy of %s: %w", source.Name(), fetchErr)
+		}
+
+		succeeded++
+		dropped := 0
+
+		for _, loc := range locs {
+			if key := dedupKey(loc); key != "" {
+				if other, ok := seen[key]; ok && other != loc.Source {
+					dropped++
+
+					continue
+				}
+
+				seen[key] = loc.Source
+			}
+
+			merged = append(merged, loc)
+		}
+
+		if dropped > 0 {
+			log.Infof("Dropped %d entries of %s which other sources already have", dropped, source.Name())
+		}
+	}
+
+	if succeeded == 0 {
+		return nil, nil, err
+	}
+
+	return merged, stale, nil
+}
+
+type jsonSource struct {
+	name    string
+	baseURL string
+}
+
+// NewJSONSource reads feeds which already use our format: the URL returns {"results": [Location...]} with the
+// text in original_message, and URL/{entry_id} returns {"full_text": ..., "formatted_address": ...}.
+func NewJSONSource(name, baseURL string) Source {
+	return &jsonSource{
+		name:    name,
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+	}
+}
+
+func (s *jsonSource) Name() string {
+	return s.name
+}
+
+func (s *jsonSource) Fetch(ctx context.Context) ([]*locations.Location, error) {
+	var d struct {
+		Locations []*locations.Location `json:"results"`
+	}
+
+	res, status, err := network.ProcessGet(ctx, s.baseURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if status != 200 {
+		return nil, &network.StatusError{Status: status}
+	}
+
+	if err := json.Unmarshal(res, &d); err != nil {
+		return nil, err
+	}
+
+	return d.Locations, nil
+}
+
+func (s *jsonSource) FetchOne(ctx context.Context, entryID int) (*SingleResponse, error) {
+	res, status, err := network.ProcessGet(ctx, fmt.Sprintf("%s/%d", s.baseURL, entryID), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if status != 200 {
+		return nil, &network.StatusError{Status: status}
+	}
+
+	singleData := &SingleResponse{}
+	if err := json.Unmarshal(res, singleData); err != nil {
+		return nil, err
+	}
+
+	return singleData, nil
+}
+
+// fileFeed is the format of the json source, kept in a local file.
+type fileFeed struct {
+	Locations []*locations.Location `json:"results"`
+}
+
+type fileSource struct {
+	name string
+	path string
+}
+
+// NewFileSource reads a local file in the format the json source's URL returns, the texts are the
+// original_message of the entries. It is meant for local development with the data of cmd/seed.
+func NewFileSource(name, path string) Source {
+	return &fileSource{
+		name: name,
+		path: path,
+	}
+}
+
+func (s *fileSource) Name() string {
+	return s.name
+}
+
+func (s *fileSource) read() ([]*locations.Location, error) {
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return make([]*locations.Location, 0), nil
+		}
+
+		return nil, err
+	}
+
+	feed := &fileFeed{}
+	if err := json.Unmarshal(data, feed); err != nil {
+		return nil, err
+	}
+
+	return feed.Locations, nil
+}
+
+func (s *fileSource) Fetch(ctx context.Context) ([]*locations.Location, error) {
+	return s.read()
+}
+
+func (s *fileSource) FetchOne(ctx context.Context, entryID int) (*SingleResponse, error) {
+	locs, err := s.read()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, loc := range locs {
+		if loc.EntryID == entryID {
+			return &SingleResponse{FullText: loc.OriginalMessage, FormattedAddress: loc.OriginalLocation}, nil
+		}
+	}
+
+	return nil, &network.StatusError{Status: 404}
+}
+
+// write replaces the file through a rename, the app reading it never sees half of it.
+func (s *fileSource) write(locs []*locations.Location) error {
+	data, err := json.Marshal(&fileFeed{Locations: locs})
+	if err != nil {
+		return err
+	}
+
+	if dir := filepath.Dir(s.path); dir != "" {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return err
+		}
+	}
+
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, s.path)
+}
+
+// fileSourceOf returns the first configured file source and the offset of its entry IDs in the merged feed.
+func fileSourceOf() (*fileSource, int, bool) {
+	for i, source := range configuredSources() {
+		if file, ok := source.(*fileSource); ok {
+			return file, i * SourceIDSpan, true
+		}
+	}
+
+	return nil, 0, false
+}
@@ -0,0 +1,143 @@
+package tools
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/YusufOzmen01/veri-kontrol-backend/core/metrics"
+	"github.com/YusufOzmen01/veri-kontrol-backend/core/sources"
+	"github.com/YusufOzmen01/veri-kontrol-backend/core/tracing"
+	"github.com/YusufOzmen01/veri-kontrol-backend/repository/locations"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/sync/singleflight"
+)
+
+// TextCacheTTL is how long a looked up text is kept and MaxTextFetches how many lookups may run against the
+// sources at once, both have to be set before the first lookup. MaxPrefetches is how many of those slots the
+// prefetch may hold, the rest are left to the volunteers waiting on a text.
+var (
+	TextCacheTTL   = 24 * time.Hour
+	MaxTextFetches = 8
+	MaxPrefetches  = 2
+)
+
+var textFetches struct {
+	once sync.Once
+	slot chan struct{}
+}
+
+// acquireTextFetch blocks until one of the MaxTextFetches slots is free, the request path and the prefetch
+// share them so together they never flood a source.
+func acquireTextFetch(ctx context.Context) (func(), error) {
+	textFetches.once.Do(func() {
+		n := MaxTextFetches
+		if n < 1 {
+			n = 1
+		}
+
+		textFetches.slot = make(chan struct{}, n)
+	})
+
+	select {
+	case textFetches.slot <- struct{}{}:
+		return func() { <-textFetches.slot }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// flightTimeout bounds an upstream call which is shared, it doesn't end with the request which started it.
+const flightTimeout = time.Minute
+
+var flights singleflight.Group
+
+// shared runs fn once for all the callers asking for the same key at the same time, like every volunteer being
+// served the same entry right after a refresh. fn gets a context of its own so the other callers don't fail
+// when the first one goes away, each caller still stops waiting when its own context ends.
+func shared(ctx context.Context, kind, key string, fn func(ctx context.Context) (interface{}, error)) (interface{}, error) {
+	result := flights.DoChan(kind+":"+key, func() (interface{}, error) {
+		flightCtx, cancel := context.WithTimeout(tracing.Detach(ctx), flightTimeout)
+		defer cancel()
+
+		return fn(flightCtx)
+	})
+
+	select {
+	case res := <-result:
+		metrics.ObserveUpstreamCall(kind, res.Shared)
+
+		return res.Val, res.Err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// PrefetchTexts looks up the texts of the entries which only came with coordinates, so serving them doesn't
+// have to wait on the source. Texts which are still cached are skipped, a failed lookup is retried on the
+// next refresh. The entries whose text was fetched are scored again afterwards.
+func PrefetchTexts(ctx context.Context, cache sources.Cache, locs []*locations.Location) {
+	missing := make(chan int)
+
+	workers := MaxPrefetches
+	if workers > MaxTextFetches {
+		workers = MaxTextFetches
+	}
+	if workers < 1 {
+		workers = 1
+	}
+
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		fetched int
+		failed  int
+	)
+
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for entryID := range missing {
+				_, err := GetSingleLocation(ctx, entryID, cache)
+
+				mu.Lock()
+				if err != nil {
+					failed++
+				} else {
+					fetched++
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+feed:
+	for _, loc := range locs {
+		if loc.OriginalMessage != "" {
+			continue
+		}
+
+		if _, ok := CachedSingleLocation(cache, loc.EntryID); ok {
+			continue
+		}
+
+		select {
+		case missing <- loc.EntryID:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+
+	close(missing)
+	wg.Wait()
+
+	if fetched > 0 || failed > 0 {
+		log.Infof("Prefetched %d entry texts, %d lookups failed", fetched, failed)
+	}
+
+	if fetched > 0 {
+		rescoreLocations(ctx, cache)
+	}
+}
@@ -0,0 +1,104 @@
+package tools
+
+import (
+	"context"
+	"testing"
+
+	"github.com/YusufOzmen01/veri-kontrol-backend/core/language"
+	"github.com/YusufOzmen01/veri-kontrol-backend/core/scoring"
+	"github.com/YusufOzmen01/veri-kontrol-backend/core/sources"
+	"github.com/YusufOzmen01/veri-kontrol-backend/repository/locations"
+)
+
+func TestRescoreLocations(t *testing.T) {
+	ctx := context.Background()
+
+	scorer, err := scoring.Parse(scoring.DefaultWeights)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	previous := KeywordScorer
+	KeywordScorer = scorer
+	defer func() { KeywordScorer = previous }()
+
+	cache := sources.NewCache(1<<20, 1e4, 64)
+	defer cache.Close()
+
+	// Entry 1 came with its text, entry 2 only with coordinates and its text wasn't looked up yet.
+	locs := []*locations.Location{
+		{EntryID: 1, OriginalMessage: "acil yardım"},
+		{EntryID: 2, Loc: []float64{36.2, 36.16}},
+		{EntryID: 3, Loc: []float64{36.2, 36.16}},
+	}
+	enrichLocations(cache, "", nil, locs)
+
+	cache.SetWithTTL(locationsCacheKey, locs, 1, LocationsCacheTTL)
+	cache.Set(textCacheKey(2), &SingleResponse{FullText: "Enkaz altında ses geliyor 0532 123 45 67"}, 1)
+	cache.Wait()
+
+	if changed := rescoreLocations(ctx, cache); changed != 1 {
+		t.Fatalf("rescoreLocations() = %d, want 1", changed)
+	}
+	cache.Wait()
+
+	served, err := GetAllLocations(ctx, cache)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if served[0].Score != 3 || served[1].Score != 18 || served[2].Score != 0 {
+		t.Fatalf("scores = %v, %v, %v", served[0].Score, served[1].Score, served[2].Score)
+	}
+
+	if served[1].Lang == "" || len(served[1].PhoneNumbers) != 1 {
+		t.Fatalf("entry 2 wasn't analyzed again: %+v", served[1])
+	}
+
+	// The slice requests may still be reading isn't changed.
+	if locs[1].Score != 0 {
+		t.Fatal("rescoreLocations() changed the cached entry in place")
+	}
+}
+
+type textSource struct {
+	text string
+}
+
+func (s *textSource) Name() string { return "test" }
+
+func (s *textSource) Fetch(ctx context.Context) ([]*locations.Location, error) { return nil, nil }
+
+func (s *textSource) FetchOne(ctx context.Context, entryID int) (*SingleResponse, error) {
+	return &SingleResponse{FullText: s.text}, nil
+}
+
+func TestLookedUpLang(t *testing.T) {
+	ctx := context.Background()
+
+	SetSources([]Source{&textSource{text: "Em li bajarê Semsûrê ne, alîkarî hene"}})
+	defer SetSources(nil)
+
+	cache := sources.NewCache(1<<20, 1e4, 64)
+	defer cache.Close()
+
+	loc := &locations.Location{EntryID: 7, Loc: []float64{36.2, 36.16}}
+	if lang := EntryLang(cache, loc); lang != "" {
+		t.Fatalf("EntryLang() = %q before the lookup", lang)
+	}
+
+	singleData, err := GetSingleLocation(ctx, loc.EntryID, cache)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cache.Wait()
+
+	if singleData.Lang != language.Kurdish {
+		t.Fatalf("Lang = %q, want %q", singleData.Lang, language.Kurdish)
+	}
+
+	// The entry in the feed is only analyzed again on the next refresh, its language is known right away.
+	if lang := EntryLang(cache, loc); lang != language.Kurdish {
+		t.Fatalf("EntryLang() = %q after the lookup", lang)
+	}
+}
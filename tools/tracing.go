@@ -0,0 +1,38 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/YusufOzmen01/veri-kontrol-backend/core/sources"
+	"github.com/YusufOzmen01/veri-kontrol-backend/core/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// The cache doesn't take a context, so the lookups which can be slow, Redis ones mostly, are traced here.
+
+func cacheGet(ctx context.Context, cache sources.Cache, key interface{}) (interface{}, bool) {
+	_, span := tracing.Start(ctx, "cache.get", trace.WithAttributes(attribute.String("cache.key", fmt.Sprint(key))))
+	defer span.End()
+
+	value, exists := cache.Get(key)
+	span.SetAttributes(attribute.Bool("cache.hit", exists))
+
+	return value, exists
+}
+
+func cacheSet(ctx context.Context, cache sources.Cache, key, value interface{}, ttl time.Duration) {
+	_, span := tracing.Start(ctx, "cache.set", trace.WithAttributes(attribute.String("cache.key", fmt.Sprint(key))))
+	defer span.End()
+
+	cache.SetWithTTL(key, value, 1, ttl)
+}
+
+func cacheDel(ctx context.Context, cache sources.Cache, key interface{}) {
+	_, span := tracing.Start(ctx, "cache.del", trace.WithAttributes(attribute.String("cache.key", fmt.Sprint(key))))
+	defer span.End()
+
+	cache.Del(key)
+}
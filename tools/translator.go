@@ -0,0 +1,259 @@
+package tools
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/YusufOzmen01/veri-kontrol-backend/core/language"
+	"github.com/YusufOzmen01/veri-kontrol-backend/core/network"
+	"github.com/YusufOzmen01/veri-kontrol-backend/core/sources"
+	"github.com/YusufOzmen01/veri-kontrol-backend/core/tracing"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	DefaultDeepLURL = "https://api-free.deepl.com"
+
+	translationCacheTTL   = 7 * 24 * time.Hour
+	translationFailureTTL = 10 * time.Minute
+	translationTimeout    = 5 * time.Second
+
+	// maxTranslations is how many translations run at once, the entries past it are translated when they are
+	// served again.
+	maxTranslations = 4
+)
+
+// Translator translates a report into Turkish. from is the detected language, a provider which doesn't know
+// it detects the language on its own.
+type Translator interface {
+	Translate(ctx context.Context, text, from string) (string, error)
+}
+
+type deepLTranslator struct {
+	baseURL string
+	apiKey  string
+}
+
+// NewDeepLTranslator uses the free API by default, keys of paid accounts need baseURL https://api.deepl.com.
+func NewDeepLTranslator(baseURL, apiKey string) Translator {
+	if baseURL == "" {
+		baseURL = DefaultDeepLURL
+	}
+
+	return &deepLTranslator{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		apiKey:  apiKey,
+	}
+}
+
+func (t *deepLTranslator) Translate(ctx context.Context, text, from string) (string, error) {
+	request := map[string]interface{}{
+		"text":        []string{text},
+		"target_lang": "TR",
+	}
+
+	// DeepL has no Kurdish, it is left to its detection.
+	if from == language.Arabic || from == language.English {
+		request["source_lang"] = strings.ToUpper(from)
+	}
+
+	body, err := json.Marshal(request)
+	if err != nil {
+		return "", err
+	}
+
+	res, status, err := network.ProcessPost(ctx, t.baseURL+"/v2/translate", body, map[string]string{
+		"Authorization": "DeepL-Auth-Key " + t.apiKey,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	if status != 200 {
+		return "", fmt.Errorf("deepl returned status %d", status)
+	}
+
+	var d struct {
+		Translations []struct {
+			Text string `json:"text"`
+		} `json:"translations"`
+	}
+
+	if err := json.Unmarshal(res, &d); err != nil {
+		return "", err
+	}
+
+	if len(d.Translations) == 0 {
+		return "", fmt.Errorf("deepl returned no translation")
+	}
+
+	return d.Translations[0].Text, nil
+}
+
+type googleTranslator struct {
+	apiKey string
+}
+
+func NewGoogleTranslator(apiKey string) Translator {
+	return &googleTranslator{
+		apiKey: apiKey,
+	}
+}
+
+func (t *googleTranslator) Translate(ctx context.Context, text, from string) (string, error) {
+	request := map[string]string{
+		"q":      text,
+		"target": language.Turkish,
+		"format": "text",
+	}
+
+	// Google tells Kurmanji and Sorani apart, which the detection doesn't, so Kurdish is left to Google.
+	if from == language.Arabic || from == language.English {
+		request["source"] = from
+	}
+
+	body, err := json.Marshal(request)
+	if err != nil {
+		return "", err
+	}
+
+	// The key goes in a header, errors of the client include the URL and end up in the logs.
+	res, status, err := network.ProcessPost(ctx, "https://translation.googleapis.com/language/translate/v2", body, map[string]string{
+		"X-goog-api-key": t.apiKey,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	if status != 200 {
+		return "", fmt.Errorf("google translate returned status %d", status)
+	}
+
+	var d struct {
+		Data struct {
+			Translations []struct {
+				TranslatedText string `json:"translatedText"`
+			} `json:"translations"`
+		} `json:"data"`
+	}
+
+	if err := json.Unmarshal(res, &d); err != nil {
+		return "", err
+	}
+
+	if len(d.Data.Translations) == 0 {
+		return "", fmt.Errorf("google translate returned no translation")
+	}
+
+	return d.Data.Translations[0].TranslatedText, nil
+}
+
+// Translation is what is cached for an entry. It only counts for the text it was made from, and a failure is
+// remembered for a while so the provider isn't asked again on every serve.
+type Translation struct {
+	TextHash     string
+	Text         string
+	Failed       bool
+	TranslatedAt time.Time
+}
+
+// The cache may be backed by Redis which needs to know the concrete types it stores.
+func init() {
+	gob.Register(&Translation{})
+}
+
+func translationCacheKey(entryID int) string {
+	return fmt.Sprintf("translation_%d", entryID)
+}
+
+func textHash(text string) string {
+	sum := sha256.Sum256([]byte(text))
+
+	return hex.EncodeToString(sum[:])
+}
+
+// EntryTranslator remembers the translation of every entry, the same entries are served over and over and the
+// providers charge per character. Translations run in the background, serving never waits on the provider.
+type EntryTranslator struct {
+	translator Translator
+	cache      sources.Cache
+	slots      chan struct{}
+}
+
+func NewEntryTranslator(translator Translator, cache sources.Cache) *EntryTranslator {
+	return &EntryTranslator{
+		translator: translator,
+		cache:      cache,
+		slots:      make(chan struct{}, maxTranslations),
+	}
+}
+
+// Translate returns the Turkish translation of the text of an entry when it is cached, and starts translating it
+// otherwise. Turkish entries and the ones without a language aren't translated and return an empty string.
+func (t *EntryTranslator) Translate(ctx context.Context, entryID int, text, from string) string {
+	if text == "" || from == "" || from == language.Turkish {
+		return ""
+	}
+
+	hash := textHash(text)
+
+	if data, exists := cacheGet(ctx, t.cache, translationCacheKey(entryID)); exists {
+		if translation := data.(*Translation); translation.TextHash == hash && translationValid(translation) {
+			return translation.Text
+		}
+	}
+
+	select {
+	case t.slots <- struct{}{}:
+	default:
+		return ""
+	}
+
+	// The request is gone by the time the translation is done.
+	ctx = tracing.Detach(ctx)
+
+	go func() {
+		defer func() { <-t.slots }()
+
+		// Serves of the same entry while it is translated share the call.
+		_, _ = shared(ctx, "translation", fmt.Sprintf("%d:%s", entryID, hash), func(ctx context.Context) (interface{}, error) {
+			return nil, t.translate(ctx, entryID, text, hash, from)
+		})
+	}()
+
+	return ""
+}
+
+func (t *EntryTranslator) translate(ctx context.Context, entryID int, text, hash, from string) error {
+	ctx, cancel := context.WithTimeout(ctx, translationTimeout)
+	defer cancel()
+
+	translation := &Translation{TextHash: hash, TranslatedAt: time.Now()}
+	ttl := translationCacheTTL
+
+	var err error
+	if translation.Text, err = t.translator.Translate(ctx, text, from); err != nil {
+		log.Errorf("Translating entry %d failed: %s", entryID, err)
+
+		translation.Failed = true
+		ttl = translationFailureTTL
+	}
+
+	cacheSet(ctx, t.cache, translationCacheKey(entryID), translation, ttl)
+
+	return err
+}
+
+// translationValid leaves out translations made before the texts were last invalidated.
+func translationValid(translation *Translation) bool {
+	textsMu.Lock()
+	defer textsMu.Unlock()
+
+	return !translation.TranslatedAt.Before(textsValidAfter)
+}
@@ -0,0 +1,132 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/YusufOzmen01/veri-kontrol-backend/core/language"
+	"github.com/YusufOzmen01/veri-kontrol-backend/core/sources"
+)
+
+type countingTranslator struct {
+	mu    sync.Mutex
+	calls int
+	err   error
+}
+
+func (t *countingTranslator) Translate(ctx context.Context, text, from string) (string, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.calls++
+	if t.err != nil {
+		return "", t.err
+	}
+
+	return "çeviri: " + text, nil
+}
+
+func (t *countingTranslator) Calls() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.calls
+}
+
+// translated waits for the background translation of an entry to be cached.
+func translated(t *testing.T, translator *EntryTranslator, entryID int, text string) string {
+	t.Helper()
+
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		translator.cache.Wait()
+
+		if translation := translator.Translate(context.Background(), entryID, text, language.English); translation != "" {
+			return translation
+		}
+
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	return ""
+}
+
+func TestEntryTranslator(t *testing.T) {
+	ctx := context.Background()
+
+	cache := sources.NewCache(1<<20, 1e4, 64)
+	defer cache.Close()
+
+	provider := &countingTranslator{}
+	translator := NewEntryTranslator(provider, cache)
+
+	if translation := translator.Translate(ctx, 1, "help", language.Turkish); translation != "" || provider.Calls() != 0 {
+		t.Fatalf("Translate() of a Turkish entry = %q", translation)
+	}
+
+	// The first serve doesn't wait, the translation is there on the next one.
+	if translation := translator.Translate(ctx, 1, "help", language.English); translation != "" {
+		t.Fatalf("Translate() = %q before the translation was made", translation)
+	}
+
+	if translation := translated(t, translator, 1, "help"); translation != "çeviri: help" {
+		t.Fatalf("Translate() = %q", translation)
+	}
+
+	// A changed text is translated again.
+	if translation := translated(t, translator, 1, "help us"); translation != "çeviri: help us" {
+		t.Fatalf("Translate() = %q after the text changed", translation)
+	}
+
+	calls := provider.Calls()
+
+	InvalidateText(ctx, cache, 1)
+	cache.Wait()
+
+	if translation := translated(t, translator, 1, "help us"); translation == "" || provider.Calls() != calls+1 {
+		t.Fatalf("Translate() = %q, %d calls after the invalidation", translation, provider.Calls())
+	}
+}
+
+func TestEntryTranslatorFailure(t *testing.T) {
+	ctx := context.Background()
+
+	cache := sources.NewCache(1<<20, 1e4, 64)
+	defer cache.Close()
+
+	provider := &countingTranslator{err: errors.New("quota exceeded")}
+	translator := NewEntryTranslator(provider, cache)
+
+	translator.Translate(ctx, 1, "help", language.English)
+
+	deadline := time.Now().Add(time.Second)
+	for provider.Calls() == 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	// Wait for the failure to be cached before serving again.
+	for time.Now().Before(deadline) {
+		cache.Wait()
+
+		if _, exists := cache.Get(translationCacheKey(1)); exists {
+			break
+		}
+
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	for i := 0; i < 3; i++ {
+		if translation := translator.Translate(ctx, 1, "help", language.English); translation != "" {
+			t.Fatalf("Translate() = %q after a failure", translation)
+		}
+	}
+
+	time.Sleep(20 * time.Millisecond)
+
+	if calls := provider.Calls(); calls != 1 {
+		t.Fatalf("the provider was asked %d times, the failure wasn't remembered", calls)
+	}
+}
@@ -0,0 +1,178 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/YusufOzmen01/veri-kontrol-backend/core/webhooks"
+	"github.com/YusufOzmen01/veri-kontrol-backend/repository/locations"
+	webhooksRepository "github.com/YusufOzmen01/veri-kontrol-backend/repository/webhooks"
+	log "github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	EventLocationResolved = "location.resolved"
+	EventLocationUpdated  = "location.updated"
+
+	DefaultWebhookInterval = 10 * time.Second
+	webhookBatchSize       = 100
+	webhookTimeout         = 15 * time.Second
+	webhookLease           = time.Minute
+)
+
+// WebhookEvent is the body every receiver gets, Data is whatever the payload function made of the resolution.
+type WebhookEvent struct {
+	Event string      `json:"event"`
+	At    time.Time   `json:"at"`
+	Data  interface{} `json:"data"`
+}
+
+// WebhookDispatcher turns the resolutions of the outbox into stored deliveries and sends them until they succeed
+// or run out of attempts. Deliveries live in Mongo, so retries survive restarts.
+type WebhookDispatcher struct {
+	webhooks webhooksRepository.Repository
+	payload  func(location *locations.LocationDB) interface{}
+}
+
+func NewWebhookDispatcher(webhooks webhooksRepository.Repository, payload func(location *locations.LocationDB) interface{}) *WebhookDispatcher {
+	return &WebhookDispatcher{
+		webhooks: webhooks,
+		payload:  payload,
+	}
+}
+
+// Enqueue stores a delivery of the resolution for every webhook, a revision of a resolution which is already
+// queued isn't queued again. Edits are sent as location.updated. Pending reviews and spam aren't sent, the same
+// as the public feed.
+func (d *WebhookDispatcher) Enqueue(ctx context.Context, location *locations.LocationDB) error {
+	if location.IsPendingReview() || location.Type == locations.TypeSpam {
+		return nil
+	}
+
+	event := EventLocationResolved
+	if location.Revision > 0 {
+		event = EventLocationUpdated
+	}
+
+	hooks, err := d.webhooks.GetWebhooks(ctx)
+	if err != nil || len(hooks) == 0 {
+		return err
+	}
+
+	body, err := json.Marshal(&WebhookEvent{
+		Event: event,
+		At:    time.Now(),
+		Data:  d.payload(location),
+	})
+	if err != nil {
+		return err
+	}
+
+	deliveries := make([]*webhooksRepository.Delivery, 0, len(hooks))
+	for _, hook := range hooks {
+		deliveries = append(deliveries, &webhooksRepository.Delivery{
+			ID:            primitive.NewObjectIDFromTimestamp(time.Now()),
+			WebhookID:     hook.ID,
+			Event:         event,
+			EntryID:       location.EntryID,
+			ResolutionID:  location.ID,
+			Revision:      location.Revision,
+			Payload:       string(body),
+			Status:        webhooksRepository.DeliveryPending,
+			NextAttemptAt: time.Now(),
+		})
+	}
+
+	return d.webhooks.AddDeliveries(ctx, deliveries)
+}
+
+// HandleResolved is the outbox handler of TopicLocationResolved, the payload is the resolution.
+func (d *WebhookDispatcher) HandleResolved(ctx context.Context, payload []byte) error {
+	location := &locations.LocationDB{}
+	if err := json.Unmarshal(payload, location); err != nil {
+		return err
+	}
+
+	return d.Enqueue(ctx, location)
+}
+
+func (d *WebhookDispatcher) attempt(ctx context.Context, delivery *webhooksRepository.Delivery, hook *webhooksRepository.Webhook) {
+	sendCtx, cancel := context.WithTimeout(ctx, webhookTimeout)
+	defer cancel()
+
+	delivery.Attempts++
+
+	status, err := webhooks.Send(sendCtx, hook.URL, hook.Secret, delivery.ID.Hex(), []byte(delivery.Payload))
+	delivery.LastStatus = status
+
+	switch {
+	case err == nil:
+		now := time.Now()
+
+		delivery.Status = webhooksRepository.DeliveryDelivered
+		delivery.DeliveredAt = &now
+		delivery.LastError = ""
+	case delivery.Attempts >= webhooks.MaxAttempts:
+		delivery.Status = webhooksRepository.DeliveryFailed
+		delivery.LastError = err.Error()
+	default:
+		delivery.NextAttemptAt = time.Now().Add(webhooks.Backoff(delivery.Attempts))
+		delivery.LastError = err.Error()
+	}
+
+	if err := d.webhooks.UpdateDelivery(ctx, delivery); err != nil {
+		log.Errorf("Couldn't update webhook delivery %s: %s", delivery.ID.Hex(), err)
+	}
+}
+
+// Deliver claims and sends up to webhookBatchSize deliveries which are due.
+func (d *WebhookDispatcher) Deliver(ctx context.Context) error {
+	hooks := make(map[primitive.ObjectID]*webhooksRepository.Webhook)
+	for i := 0; i < webhookBatchSize; i++ {
+		delivery, err := d.webhooks.ClaimDueDelivery(ctx, time.Now(), webhookLease)
+		if err == webhooksRepository.ErrNothingDue {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		hook, ok := hooks[delivery.WebhookID]
+		if !ok {
+			hook, err = d.webhooks.GetWebhook(ctx, delivery.WebhookID)
+			if err != nil {
+				log.Errorf("Couldn't load webhook %s: %s", delivery.WebhookID.Hex(), err)
+
+				continue
+			}
+
+			hooks[delivery.WebhookID] = hook
+		}
+
+		d.attempt(ctx, delivery, hook)
+	}
+
+	return nil
+}
+
+// Run delivers until ctx is cancelled. The deliveries are queued by HandleResolved, the outbox handler.
+func (d *WebhookDispatcher) Run(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = DefaultWebhookInterval
+	}
+
+	for {
+		if err := d.Deliver(ctx); err != nil {
+			log.Errorf("Couldn't deliver webhooks: %s", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+		}
+	}
+}
@@ -1,6 +1,9 @@
 package util
 
 import (
+	crand "crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
 	"hash/fnv"
 	"math/rand"
 )
@@ -11,6 +14,11 @@ func Hash(s string) uint32 {
 	return h.Sum32()
 }
 
+func SHA256(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
 var letterRunes = []rune("abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ")
 
 func RandomString(n int) string {
@@ -20,3 +28,13 @@ func RandomString(n int) string {
 	}
 	return string(b)
 }
+
+// SecureRandomString is used for secrets which must not be guessable, like refresh tokens.
+func SecureRandomString(n int) string {
+	b := make([]byte, n)
+	if _, err := crand.Read(b); err != nil {
+		panic(err)
+	}
+
+	return hex.EncodeToString(b)
+}
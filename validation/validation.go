@@ -0,0 +1,114 @@
+package validation
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+type Errors []*FieldError
+
+func (e Errors) Error() string {
+	messages := make([]string, 0, len(e))
+	for _, fieldErr := range e {
+		messages = append(messages, fmt.Sprintf("%s: %s", fieldErr.Field, fieldErr.Message))
+	}
+
+	return strings.Join(messages, ", ")
+}
+
+// NormalizeText composes decomposed Turkish characters (e.g. "i" + combining dot) into their single code
+// point and collapses whitespace, so the same address always ends up stored the same way.
+func NormalizeText(s string) string {
+	return strings.Join(strings.Fields(norm.NFC.String(s)), " ")
+}
+
+// Validator collects every failing field instead of stopping at the first one.
+type Validator struct {
+	errors Errors
+}
+
+func New() *Validator {
+	return &Validator{
+		errors: make(Errors, 0),
+	}
+}
+
+func (v *Validator) Add(field, message string) {
+	v.errors = append(v.errors, &FieldError{
+		Field:   field,
+		Message: message,
+	})
+}
+
+func (v *Validator) Required(field, value string) bool {
+	if value == "" {
+		v.Add(field, "is required")
+
+		return false
+	}
+
+	return true
+}
+
+func (v *Validator) Positive(field string, value int) bool {
+	if value <= 0 {
+		v.Add(field, "must be a positive number")
+
+		return false
+	}
+
+	return true
+}
+
+func (v *Validator) MaxLength(field, value string, max int) bool {
+	if len([]rune(value)) > max {
+		v.Add(field, fmt.Sprintf("must be at most %d characters", max))
+
+		return false
+	}
+
+	return true
+}
+
+func (v *Validator) OneOfInt(field string, value int, allowed []int) bool {
+	for _, a := range allowed {
+		if value == a {
+			return true
+		}
+	}
+
+	v.Add(field, fmt.Sprintf("must be one of %v", allowed))
+
+	return false
+}
+
+// OneOf matches value case insensitively with Turkish casing rules and returns the canonical spelling.
+func (v *Validator) OneOf(field, value string, allowed []string) (string, bool) {
+	lowered := strings.ToLowerSpecial(unicode.TurkishCase, value)
+
+	for _, a := range allowed {
+		if lowered == strings.ToLowerSpecial(unicode.TurkishCase, a) {
+			return a, true
+		}
+	}
+
+	v.Add(field, fmt.Sprintf("must be one of %s", strings.Join(allowed, ", ")))
+
+	return value, false
+}
+
+func (v *Validator) Err() error {
+	if len(v.errors) == 0 {
+		return nil
+	}
+
+	return v.errors
+}